@@ -27,6 +27,7 @@ import (
 	"cold-backend/internal/health"
 	h "cold-backend/internal/http"
 	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
 	"cold-backend/internal/monitoring"
 	"cold-backend/internal/repositories"
 	"cold-backend/internal/services"
@@ -304,6 +305,9 @@ func main() {
 	mode := flag.String("mode", "employee", "Server mode: employee or customer")
 	port := flag.Int("port", 0, "Server port (overrides config)")
 	install := flag.Bool("install", false, "Install PostgreSQL, create database, and setup systemd service")
+	backfillRent := flag.Bool("backfill-rent", false, "Post rent CHARGE ledger entries for the current season, then exit")
+	verifyLedger := flag.Bool("verify-ledger", false, "Verify ledger running balances and cross-check payments, then exit")
+	repairLedger := flag.Bool("repair-ledger", false, "Repair wrong ledger running balances (audited), then exit")
 	flag.Parse()
 
 	// Run install if requested
@@ -425,6 +429,52 @@ func main() {
 	onlineTransactionRepo := repositories.NewOnlineTransactionRepository(pool)
 	pendingSettingChangeRepo := repositories.NewPendingSettingChangeRepository(pool)
 	totpRepo := repositories.NewTOTPRepository(pool)
+	rentTariffRepo := repositories.NewRentTariffRepository(pool)
+
+	// Rent tariff service is shared by both modes so portal and staff balances agree
+	tariffService := services.NewTariffService(rentTariffRepo, systemSettingRepo)
+
+	// Rent accrual posts rent to the ledger (scheduler runs in employee mode only)
+	rentAccrualRepo := repositories.NewRentAccrualRepository(pool)
+	rentAccrualService := services.NewRentAccrualService(entryRepo, roomEntryRepo, services.NewLedgerService(ledgerRepo), tariffService, rentAccrualRepo, systemSettingRepo)
+
+	// Backfill rent charges and exit if requested
+	if *backfillRent {
+		log.Println("Backfilling rent charges for the current season...")
+		runs, err := rentAccrualService.Backfill(context.Background(), 0)
+		if err != nil {
+			log.Fatalf("Rent backfill failed: %v", err)
+		}
+		for _, run := range runs {
+			log.Printf("  as of %s: %d charges, %.2f", run.AsOf.Format("2006-01-02"), run.ChargesPosted, run.AmountPosted)
+		}
+		log.Println("Rent backfill complete")
+		return
+	}
+
+	// Verify (and optionally repair) the ledger and exit if requested
+	ledgerIntegrityService := services.NewLedgerIntegrityService(repositories.NewLedgerIntegrityRepository(pool))
+	if *verifyLedger || *repairLedger {
+		var report *models.LedgerIntegrityReport
+		var err error
+		if *repairLedger {
+			report, err = ledgerIntegrityService.Repair(context.Background(), 0, "")
+		} else {
+			report, err = ledgerIntegrityService.Verify(context.Background())
+		}
+		if err != nil {
+			log.Fatalf("Ledger verification failed: %v", err)
+		}
+		log.Printf("Checked %d ledger entries for %d customers", report.EntriesChecked, report.CustomersChecked)
+		if report.Repaired {
+			log.Printf("Repaired %d running balance rows", report.RowsRepaired)
+		}
+		for _, issue := range report.Issues {
+			log.Printf("  [%s] %s (%s): %s %v", issue.Type, issue.CustomerName, issue.CustomerPhone, issue.Message, issue.LedgerEntryIDs)
+		}
+		log.Printf("%d issue(s) found", len(report.Issues))
+		return
+	}
 
 	// Initialize middleware (needed for both modes)
 	authMiddleware := middleware.NewAuthMiddleware(jwtManager, userRepo)
@@ -470,8 +520,12 @@ func main() {
 			systemSettingRepo,
 			gatePassPickupRepo,
 			ledgerRepo,
+			tariffService,
 		)
 
+		customerPortalService.SetPickupSlotService(services.NewPickupSlotService(repositories.NewPickupSlotRepository(pool), gatePassRepo))
+		customerPortalService.SetApprovalService(services.NewApprovalService(repositories.NewApprovalRepository(pool), userRepo, nil))
+
 		// Initialize customer portal handler
 		customerPortalHandler := handlers.NewCustomerPortalHandler(
 			otpService,
@@ -528,15 +582,30 @@ func main() {
 		entryService := services.NewEntryService(entryRepo, customerRepo, entryEventRepo)
 		entryService.SetSettingRepo(systemSettingRepo)     // Wire SettingRepo for skip thock ranges
 		entryService.SetFamilyMemberRepo(familyMemberRepo) // Wire FamilyMemberRepo for family member auto-assign
-		printerService := services.NewPrinterService()
-		printerHandler := handlers.NewPrinterHandler(printerService)
+		thockNumberRepo := repositories.NewThockNumberRepository(pool)
+		entryService.SetThockNumberRepo(thockNumberRepo) // Wire per-season thock number series
+		printerService := services.NewPrinterService(repositories.NewPrinterRepository(pool))
+		printerService.Start() // Print queue worker (retries jobs whose printer was unreachable)
+		printerHandler := handlers.NewPrinterHandler(printerService, adminActionLogRepo)
 		roomEntryService := services.NewRoomEntryService(roomEntryRepo, roomEntryGatarRepo, entryRepo, entryEventRepo, printerService, roomEntryMediaRepo)
+		gatarStockRepo := repositories.NewGatarStockRepository(pool)
+		warehouseLayoutService := services.NewWarehouseLayoutService(repositories.NewWarehouseLayoutRepository(pool), gatarStockRepo)
+		warehouseLayoutService.SetSettingRepo(systemSettingRepo)  // Wire gatar_overfill_policy
+		roomEntryService.SetLayoutService(warehouseLayoutService) // Validate room/floor/gatars against the warehouse layout
+		gatarStockService := services.NewGatarStockService(gatarStockRepo, warehouseLayoutService)
+		gatarStockService.SetEventRepos(roomEntryRepo, entryEventRepo) // STOCK_MOVED events for stock transfers
+		roomEntryService.SetStockLedger(gatarStockService) // Post room entries and edits to the per-gatar stock ledger
 		systemSettingService := services.NewSystemSettingService(systemSettingRepo)
 		rentPaymentService := services.NewRentPaymentService(rentPaymentRepo)
-		invoiceService := services.NewInvoiceService(invoiceRepo)
+		invoiceService := services.NewInvoiceService(invoiceRepo, entryRepo, customerRepo, systemSettingRepo, tariffService)
 		gatePassService := services.NewGatePassService(gatePassRepo, entryRepo, entryEventRepo, gatePassPickupRepo, roomEntryRepo, gatePassMediaRepo)
+		gatePassService.SetStockLedger(gatarStockService) // Take pickups out of the per-gatar stock ledger
+		gatePassService.SetAmendmentRepo(repositories.NewGatePassAmendmentRepository(pool))
 		ledgerService := services.NewLedgerService(ledgerRepo)
+		ledgerService.SetJournalRepo(repositories.NewJournalRepository(pool))
 		debtService := services.NewDebtService(debtRequestRepo, ledgerService)
+		paymentAllocationService := services.NewPaymentAllocationService(repositories.NewPaymentAllocationRepository(pool), entryRepo, roomEntryRepo, ledgerRepo, tariffService)
+		debtService.SetAllocationService(paymentAllocationService) // Per-thock gate pass decisions
 
 		// Initialize SMS logging and notification service
 		smsLogRepo := repositories.NewSMSLogRepository(pool)
@@ -604,12 +673,13 @@ func main() {
 
 		// Initialize notification service for transaction SMS
 		notificationService := services.NewNotificationService(employeeSMSService, systemSettingRepo)
+		notificationService.SetUserRepo(userRepo) // Page admins about sensor alerts when sensor_alert_phones is empty
+		gatePassService.SetNotificationService(notificationService) // SMS about cancelled, amended and re-issued gate passes
 
 		// Initialize handlers (employee mode)
 		userHandler := handlers.NewUserHandler(userService, adminActionLogRepo)
 		authHandler := handlers.NewAuthHandler(userService, loginLogRepo)
 		customerHandler := handlers.NewCustomerHandler(customerService, entryManagementLogRepo)
-		customerHandler.SetLedgerRepo(ledgerRepo) // Cascade phone changes to ledger
 		entryHandler := handlers.NewEntryHandler(entryService, entryEditLogRepo, entryManagementLogRepo, adminActionLogRepo)
 		roomEntryHandler := handlers.NewRoomEntryHandler(roomEntryService, roomEntryEditLogRepo)
 		entryEventHandler := handlers.NewEntryEventHandler(entryEventRepo)
@@ -619,6 +689,7 @@ func main() {
 		rentPaymentHandler.SetNotificationService(notificationService)
 		rentPaymentHandler.SetCustomerService(customerService)
 		rentPaymentHandler.SetEntryRepo(entryRepo) // For family member name validation
+		rentPaymentHandler.SetAllocationService(paymentAllocationService)
 		invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
 		loginLogHandler := handlers.NewLoginLogHandler(loginLogRepo)
 		// Set OTP repo for customer login logs in admin panel
@@ -710,6 +781,29 @@ func main() {
 			log.Println("[Monitoring] TimescaleDB not available, using in-memory log buffer")
 		}
 
+		// Initialize cold-room sensor ingestion (readings in TimescaleDB, alerts paged by SMS)
+		roomSensorService := services.NewRoomSensorService(timescaleStore, warehouseLayoutService, systemSettingRepo)
+		roomSensorService.SetNotificationService(notificationService)
+		if tsdbPool != nil {
+			roomSensorService.SetMetricsRepo(repositories.NewMetricsRepository(tsdbPool))
+		} else {
+			log.Println("[Sensors] WARNING: TimescaleDB not available - sensor readings are not stored and sensor alerts are OFF")
+		}
+		if brokerURL := os.Getenv("SENSOR_MQTT_URL"); brokerURL != "" {
+			topic := os.Getenv("SENSOR_MQTT_TOPIC")
+			if topic == "" {
+				topic = "coldstore/sensors/#"
+			}
+			roomSensorService.StartMQTT(context.Background(), monitoring.MQTTConfig{
+				BrokerURL: brokerURL,
+				Topic:     topic,
+				ClientID:  os.Getenv("SENSOR_MQTT_CLIENT_ID"),
+				Username:  os.Getenv("SENSOR_MQTT_USER"),
+				Password:  os.Getenv("SENSOR_MQTT_PASSWORD"),
+			})
+		}
+		roomSensorHandler := handlers.NewRoomSensorHandler(roomSensorService)
+
 		// Initialize TOTP service and handler (2FA for admin users)
 		totpService := services.NewTOTPService(userRepo, totpRepo)
 		totpHandler := handlers.NewTOTPHandler(totpService, userRepo, jwtManager)
@@ -744,6 +838,8 @@ func main() {
 
 		// Initialize season service and handler (needs tsdbPool for archiving timeseries data)
 		seasonService := services.NewSeasonService(seasonRequestRepo, userRepo, pool, tsdbPool, jwtManager)
+		seasonService.SetRentAccrualService(rentAccrualService)
+		seasonService.SetThockNumberRepo(thockNumberRepo)
 		seasonHandler := handlers.NewSeasonHandler(seasonService)
 
 		// Initialize node provisioning (infrastructure management)
@@ -756,11 +852,12 @@ func main() {
 		deploymentHandler := handlers.NewDeploymentHandler(deploymentService)
 
 		// Initialize report service (bulk PDF/CSV export with parallel processing)
-		reportService := services.NewReportService(pool, customerRepo, entryRepo, roomEntryRepo, rentPaymentRepo, systemSettingRepo)
+		reportService := services.NewReportService(pool, customerRepo, entryRepo, roomEntryRepo, rentPaymentRepo, systemSettingRepo, tariffService)
 		reportHandler := handlers.NewReportHandler(reportService)
+		reportHandler.SetAccountingExportService(services.NewAccountingExportService(ledgerRepo, rentPaymentRepo, systemSettingRepo))
 
 		// Initialize account handler (optimized single-call endpoint for Account Management)
-		accountHandler := handlers.NewAccountHandler(pool, entryRepo, roomEntryRepo, rentPaymentRepo, gatePassRepo, systemSettingRepo, ledgerRepo)
+		accountHandler := handlers.NewAccountHandler(pool, entryRepo, roomEntryRepo, rentPaymentRepo, gatePassRepo, systemSettingRepo, ledgerRepo, tariffService)
 
 		// Initialize entry room handler (optimized single-call endpoint for Entry Room page)
 		entryRoomHandler := handlers.NewEntryRoomHandler(pool, entryRepo, roomEntryRepo, customerRepo, guardEntryRepo)
@@ -783,8 +880,59 @@ func main() {
 
 		// Initialize ledger and debt handlers
 		ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+		ledgerHandler.SetRentAccrualService(rentAccrualService)
+		ledgerHandler.SetLedgerIntegrityService(ledgerIntegrityService)
+		rentAccrualService.Start()
+		rentAdjustmentService := services.NewRentAdjustmentService(repositories.NewRentAdjustmentRepository(pool), entryRepo, ledgerService, paymentAllocationService, systemSettingRepo)
+		ledgerHandler.SetRentAdjustmentService(rentAdjustmentService)
+		rentAdjustmentService.Start()
 		debtHandler := handlers.NewDebtHandler(debtService)
 
+		// Initialize rent tariff handler (rate card management)
+		rentTariffHandler := handlers.NewRentTariffHandler(tariffService, entryRepo, adminActionLogRepo)
+
+		// Initialize bank reconciliation handler (bank statement import and matching)
+		bankReconciliationService := services.NewBankReconciliationService(repositories.NewBankStatementRepository(pool), ledgerService, customerRepo, systemSettingRepo)
+		bankReconciliationHandler := handlers.NewBankReconciliationHandler(bankReconciliationService, adminActionLogRepo)
+
+		// Initialize warehouse layout handler (rooms, floors, gatars)
+		warehouseLayoutHandler := handlers.NewWarehouseLayoutHandler(warehouseLayoutService, adminActionLogRepo)
+		stockTransferHandler := handlers.NewStockTransferHandler(gatarStockService, adminActionLogRepo)
+
+		// Initialize quality inspection handler (inspections, deterioration alerts, stock aging)
+		qualityInspectionService := services.NewQualityInspectionService(repositories.NewQualityInspectionRepository(pool), gatarStockRepo, roomEntryMediaRepo, systemSettingRepo)
+		qualityInspectionService.SetEventRepos(entryRepo, roomEntryRepo, entryEventRepo)
+		qualityInspectionService.SetNotificationService(notificationService)
+		qualityInspectionService.SetSyncService(mediaSyncService)
+		qualityInspectionHandler := handlers.NewQualityInspectionHandler(qualityInspectionService, adminActionLogRepo)
+
+		// Weighbridge weighments (gross/tare at the guard gate and at gate-pass pickup)
+		weighmentRepo := repositories.NewWeighmentRepository(pool)
+		weighmentService := services.NewWeighmentService(weighmentRepo, guardEntryRepo, gatePassRepo, systemSettingRepo)
+		gatePassService.SetWeighmentService(weighmentService)
+		reportService.SetWeighmentRepo(weighmentRepo)
+		weighmentHandler := handlers.NewWeighmentHandler(weighmentService, adminActionLogRepo)
+
+		// Thock number series (reservations, voids and the auditors' report)
+		thockNumberService := services.NewThockNumberService(thockNumberRepo, systemSettingRepo)
+		thockNumberHandler := handlers.NewThockNumberHandler(thockNumberService, adminActionLogRepo)
+
+		// Signed QR codes on labels and gate passes, resolved by handheld scanners
+		scanService := services.NewScanService(repositories.NewQRKeyRepository(pool), entryRepo, gatePassRepo, gatarStockRepo, thockNumberRepo)
+		scanService.SetPrinterService(printerService)
+		printerService.SetScanService(scanService)
+		scanHandler := handlers.NewScanHandler(scanService, adminActionLogRepo)
+
+		// Vehicle register (guard gate in, linked entries and gate passes, exit with loaded bags)
+		vehicleService := services.NewVehicleService(repositories.NewVehicleRepository(pool), guardEntryRepo, entryRepo, gatePassRepo, systemSettingRepo)
+		guardEntryService.SetVehicleService(vehicleService)
+		vehicleHandler := handlers.NewVehicleHandler(vehicleService, adminActionLogRepo)
+
+		// Pickup slots per loading gate, booked by gate passes, and the unloading queue
+		pickupSlotService := services.NewPickupSlotService(repositories.NewPickupSlotRepository(pool), gatePassRepo)
+		gatePassService.SetPickupSlotService(pickupSlotService)
+		pickupSlotHandler := handlers.NewPickupSlotHandler(pickupSlotService, adminActionLogRepo)
+
 		// Initialize merge history handler
 		mergeHistoryHandler := handlers.NewMergeHistoryHandler(customerRepo, entryRepo, entryManagementLogRepo)
 
@@ -809,6 +957,15 @@ func main() {
 			totpService,
 		)
 
+		// Approval engine (policies, multi-approver decisions and the approvals inbox)
+		approvalService := services.NewApprovalService(repositories.NewApprovalRepository(pool), userRepo, totpService)
+		approvalService.Start() // Expires approval requests and closes those settled elsewhere
+		debtService.SetApprovalService(approvalService)
+		gatePassService.SetApprovalService(approvalService)
+		seasonService.SetApprovalService(approvalService)
+		pendingSettingHandler.SetApprovalService(approvalService)
+		approvalHandler := handlers.NewApprovalHandler(approvalService, adminActionLogRepo)
+
 		// Point-in-time restore service and handler already initialized above for monitoring integration
 
 		// Initialize media sync admin handler (3-2-1 backup dashboard)
@@ -835,7 +992,7 @@ func main() {
 		}
 
 		// Create employee router
-		router := h.NewRouter(userHandler, authHandler, customerHandler, entryHandler, roomEntryHandler, entryEventHandler, systemSettingHandler, rentPaymentHandler, invoiceHandler, loginLogHandler, roomEntryEditLogHandler, entryEditLogHandler, entryManagementLogHandler, adminActionLogHandler, gatePassHandler, seasonHandler, guardEntryHandler, tokenColorHandler, pageHandler, healthHandler, authMiddleware, operationModeMiddleware, monitoringHandler, infraHandler, apiLoggingMiddleware, nodeProvisioningHandler, deploymentHandler, reportHandler, accountHandler, entryRoomHandler, roomVisualizationHandler, itemsInStockHandler, setupHandler, ledgerHandler, debtHandler, mergeHistoryHandler, customerActivityLogHandler, smsHandler, familyMemberHandler, razorpayHandler, pendingSettingHandler, totpHandler, restoreHandler, printerHandler, fileManagerHandler, deletedEntriesHandler, mediaSyncHandler, poolSyncHandler)
+		router := h.NewRouter(userHandler, authHandler, customerHandler, entryHandler, roomEntryHandler, entryEventHandler, systemSettingHandler, rentPaymentHandler, invoiceHandler, loginLogHandler, roomEntryEditLogHandler, entryEditLogHandler, entryManagementLogHandler, adminActionLogHandler, gatePassHandler, seasonHandler, guardEntryHandler, tokenColorHandler, pageHandler, healthHandler, authMiddleware, operationModeMiddleware, monitoringHandler, infraHandler, apiLoggingMiddleware, nodeProvisioningHandler, deploymentHandler, reportHandler, accountHandler, entryRoomHandler, roomVisualizationHandler, itemsInStockHandler, setupHandler, ledgerHandler, debtHandler, mergeHistoryHandler, customerActivityLogHandler, smsHandler, familyMemberHandler, razorpayHandler, pendingSettingHandler, totpHandler, restoreHandler, printerHandler, fileManagerHandler, deletedEntriesHandler, mediaSyncHandler, poolSyncHandler, rentTariffHandler, bankReconciliationHandler, warehouseLayoutHandler, stockTransferHandler, roomSensorHandler, qualityInspectionHandler, weighmentHandler, thockNumberHandler, scanHandler, vehicleHandler, pickupSlotHandler, approvalHandler)
 
 		// Add gallery routes if enabled
 		if cfg.G.Enabled {
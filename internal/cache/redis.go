@@ -288,6 +288,12 @@ func InvalidateSettingCaches(ctx context.Context) {
 	InvalidateKeys(ctx, "account:summary")
 }
 
+// InvalidateTariffCaches clears caches that depend on rent rate cards
+// Called when: CreateTariff, ActivateTariff, DeactivateTariff
+func InvalidateTariffCaches(ctx context.Context) {
+	InvalidateKeys(ctx, "account:summary")
+}
+
 // InvalidatePaymentCaches clears all payment-related caches
 // Called when: CreatePayment
 func InvalidatePaymentCaches(ctx context.Context) {
@@ -14,6 +14,8 @@ import (
 	"cold-backend/internal/middleware"
 	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -33,6 +35,7 @@ type AccountHandler struct {
 	GatePassRepo    *repositories.GatePassRepository
 	SettingsRepo    *repositories.SystemSettingRepository
 	LedgerRepo      *repositories.LedgerRepository
+	TariffService   *services.TariffService
 }
 
 // LedgerPayment represents a payment from the ledger (for display)
@@ -123,6 +126,7 @@ func NewAccountHandler(
 	gatePassRepo *repositories.GatePassRepository,
 	settingsRepo *repositories.SystemSettingRepository,
 	ledgerRepo *repositories.LedgerRepository,
+	tariffService *services.TariffService,
 ) *AccountHandler {
 	h := &AccountHandler{
 		DB:              db,
@@ -132,6 +136,7 @@ func NewAccountHandler(
 		GatePassRepo:    gatePassRepo,
 		SettingsRepo:    settingsRepo,
 		LedgerRepo:      ledgerRepo,
+		TariffService:   tariffService,
 	}
 
 	// Register pre-warm callback for account summary
@@ -192,6 +197,7 @@ func (h *AccountHandler) GetAccountSummary(w http.ResponseWriter, r *http.Reques
 // UsedDebtRequest represents a used debt request for credit tracking
 type UsedDebtRequest struct {
 	CustomerPhone     string
+	ThockNumber       string
 	RequestedQuantity int
 }
 
@@ -207,14 +213,14 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 		familyMemberMap     map[int]map[string]string // customer_id -> name -> relation
 		ledgerCredits       map[string]float64        // phone -> total credits from ledger
 		ledgerPayments      map[string][]repositories.PaymentHistoryItem // phone -> payment history
-		rentPerItem         float64
+		rateCard            *services.RateCard
 		wg                  sync.WaitGroup
 		entriesErr          error
 		roomErr             error
 		paymentsErr         error
 		gatePassErr         error
 		debtErr             error
-		settingsErr         error
+		tariffErr           error
 		ledgerCreditsErr    error
 		ledgerPaymentsErr   error
 	)
@@ -251,23 +257,10 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 		usedDebtRequests, debtErr = h.getUsedDebtRequests(ctx)
 	}()
 
-	// Fetch rent per item setting
+	// Fetch rent rate cards
 	go func() {
 		defer wg.Done()
-		setting, err := h.SettingsRepo.Get(ctx, "rent_per_item")
-		if err != nil {
-			settingsErr = err
-			return
-		}
-		if setting != nil {
-			// Try to parse as number - the value might be stored as a string like "10" or as JSON
-			var val float64
-			if err := json.Unmarshal([]byte(setting.SettingValue), &val); err != nil {
-				// Try parsing as plain string number
-				fmt.Sscanf(setting.SettingValue, "%f", &val)
-			}
-			rentPerItem = val
-		}
+		rateCard, tariffErr = h.TariffService.LoadRateCard(ctx)
 	}()
 
 	// Fetch all family members to get relations
@@ -327,10 +320,10 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 	if paymentsErr != nil {
 		return nil, fmt.Errorf("failed to load payments: %w", paymentsErr)
 	}
-	// Gate pass, debt, settings, and ledger errors are non-fatal
-	if settingsErr != nil {
-		rentPerItem = 0
+	if tariffErr != nil {
+		return nil, tariffErr
 	}
+	// Gate pass, debt, and ledger errors are non-fatal
 	if gatePassErr != nil {
 		completedGatePasses = []CompletedGatePass{}
 	}
@@ -344,10 +337,25 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 		ledgerPayments = make(map[string][]repositories.PaymentHistoryItem)
 	}
 
+	now := timeutil.Now()
+	rentPerItem := rateCard.DefaultRate()
+
+	// Index entries by thock so outgoing/credit quantities are priced on the thock's own rate
+	entryByThock := make(map[string]*models.Entry, len(entries))
+	for _, entry := range entries {
+		entryByThock[entry.ThockNumber] = entry
+	}
+	thockRate := func(thockNumber string) float64 {
+		if entry, ok := entryByThock[thockNumber]; ok {
+			return rateCard.RateFor(entry, now)
+		}
+		return rentPerItem
+	}
+
 	// Build credit map from used debt requests (items taken on credit that are still owed)
 	creditByPhone := make(map[string]float64)
 	for _, dr := range usedDebtRequests {
-		creditByPhone[dr.CustomerPhone] += float64(dr.RequestedQuantity) * rentPerItem
+		creditByPhone[dr.CustomerPhone] += float64(dr.RequestedQuantity) * thockRate(dr.ThockNumber)
 	}
 
 	// Build thock stored quantity map from room entries
@@ -377,7 +385,7 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 		customer := customerMap[phone]
 		storedQty := thockStoredQty[entry.ThockNumber]
 		expectedQty := entry.ExpectedQuantity
-		rent := float64(storedQty) * rentPerItem
+		rent := rateCard.EntryRent(entry, storedQty, now)
 
 		qtyDisplay := ""
 		if expectedQty != storedQty {
@@ -410,7 +418,7 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 		if quantity == 0 {
 			quantity = gp.RequestedQty
 		}
-		rent := float64(quantity) * rentPerItem
+		rent := float64(quantity) * thockRate(gp.ThockNumber)
 
 		customer.Thocks = append(customer.Thocks, ThockInfo{
 			ID:          gp.ID,
@@ -564,9 +572,14 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 			}
 
 			// Calculate canTakeOut: items paid for minus items already picked up
+			// Items are valued at this family member's average rate across their thocks
+			fmRate := rentPerItem
+			if familyMemberQty[fmName] > 0 {
+				fmRate = fmRent / float64(familyMemberQty[fmName])
+			}
 			fmCanTakeOut := 0
-			if rentPerItem > 0 {
-				itemsPaidFor := int(fmPaid / rentPerItem)
+			if fmRate > 0 {
+				itemsPaidFor := int(fmPaid / fmRate)
 				fmCanTakeOut = itemsPaidFor - fmOutgoing
 				if fmCanTakeOut < 0 {
 					fmCanTakeOut = 0
@@ -645,10 +658,9 @@ func (h *AccountHandler) generateAccountSummary(ctx context.Context) (*AccountSu
 					// Each family member is considered fully paid proportionally
 					familyMembers[i].Paid = familyMembers[i].Rent
 					familyMembers[i].Balance = 0
-					// Recalculate canTakeOut based on full payment
-					if rentPerItem > 0 {
-						itemsPaidFor := int(familyMembers[i].Paid / rentPerItem)
-						familyMembers[i].CanTakeOut = itemsPaidFor - familyMembers[i].Outgoing
+					// Recalculate canTakeOut based on full payment (every stored item is paid for)
+					if familyMembers[i].Quantity > 0 {
+						familyMembers[i].CanTakeOut = familyMembers[i].Quantity - familyMembers[i].Outgoing
 						if familyMembers[i].CanTakeOut < 0 {
 							familyMembers[i].CanTakeOut = 0
 						}
@@ -742,7 +754,7 @@ func (h *AccountHandler) getCompletedGatePasses(ctx context.Context) ([]Complete
 // getUsedDebtRequests fetches used debt requests (items taken on credit)
 func (h *AccountHandler) getUsedDebtRequests(ctx context.Context) ([]UsedDebtRequest, error) {
 	query := `
-		SELECT customer_phone, requested_quantity
+		SELECT customer_phone, COALESCE(thock_number, ''), requested_quantity
 		FROM debt_requests
 		WHERE status = 'used'
 	`
@@ -756,7 +768,7 @@ func (h *AccountHandler) getUsedDebtRequests(ctx context.Context) ([]UsedDebtReq
 	var results []UsedDebtRequest
 	for rows.Next() {
 		var dr UsedDebtRequest
-		if err := rows.Scan(&dr.CustomerPhone, &dr.RequestedQuantity); err != nil {
+		if err := rows.Scan(&dr.CustomerPhone, &dr.ThockNumber, &dr.RequestedQuantity); err != nil {
 			return nil, err
 		}
 		results = append(results, dr)
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// ApprovalHandler serves the approvals inbox and the approval policies
+type ApprovalHandler struct {
+	Service         *services.ApprovalService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewApprovalHandler(s *services.ApprovalService, adminActionRepo *repositories.AdminActionLogRepository) *ApprovalHandler {
+	return &ApprovalHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// writeApprovalError maps approval engine errors to HTTP status codes. It reports whether
+// err was one of them, so flows can fall back to their own error mapping.
+func writeApprovalError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repositories.ErrApprovalNotFound), errors.Is(err, repositories.ErrApprovalPolicyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidApproval):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrApprovalCredentials):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, services.ErrApprovalForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrApprovalClosed), errors.Is(err, repositories.ErrApprovalExpired),
+		errors.Is(err, repositories.ErrApprovalAlreadyDecided), errors.Is(err, repositories.ErrApprovalChanged),
+		errors.Is(err, repositories.ErrApprovalPolicyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
+// writeApprovalRecorded answers an approval that still waits for more approvers
+func writeApprovalRecorded(w http.ResponseWriter, approval *models.ApprovalRequest) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  fmt.Sprintf("Approval recorded (%d of %d). Waiting for more approvers.", approval.Approvals, approval.RequiredApprovals),
+		"approval": approval,
+	})
+}
+
+// GetInbox returns the approval requests waiting for the current user's decision
+// GET /api/approvals/inbox
+func (h *ApprovalHandler) GetInbox(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	role, _ := middleware.GetRoleFromContext(r.Context())
+
+	approvals, err := h.Service.Inbox(r.Context(), userID, role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if approvals == nil {
+		approvals = []*models.ApprovalRequest{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// ListApprovals returns recent approval requests
+// GET /api/approvals?flow=&status=&limit=
+func (h *ApprovalHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	approvals, err := h.Service.List(r.Context(), r.URL.Query().Get("flow"), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if approvals == nil {
+		approvals = []*models.ApprovalRequest{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// GetApproval returns an approval request with who decided what
+// GET /api/approvals/{id}
+func (h *ApprovalHandler) GetApproval(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid approval ID", http.StatusBadRequest)
+		return
+	}
+
+	approval, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
+
+// ListPolicies returns the approval policies
+// GET /api/approvals/policies?flow=
+func (h *ApprovalHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Service.ListPolicies(r.Context(), r.URL.Query().Get("flow"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policies == nil {
+		policies = []*models.ApprovalPolicy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+func (h *ApprovalHandler) logPolicyAction(r *http.Request, action string, policy *models.ApprovalPolicy) {
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	ipAddress := getIPAddress(r)
+	panel := "any approver"
+	if len(policy.ApproverUserIDs) > 0 {
+		panel = fmt.Sprintf("users %v", policy.ApproverUserIDs)
+	}
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  action,
+		TargetType:  "approval_policy",
+		TargetID:    &policy.ID,
+		Description: fmt.Sprintf("Approval policy for %s from %.2f: %d of %s with roles %v, self approval %t, password %t, 2FA %s, expiry %dh, active %t",
+			policy.Flow, policy.MinAmount, policy.RequiredApprovals, panel, policy.ApproverRoles,
+			policy.AllowSelfApproval, policy.RequirePassword, policy.TOTPMode, policy.ExpiryHours, policy.IsActive),
+		IPAddress: &ipAddress,
+	})
+}
+
+// CreatePolicy adds a policy tier to a flow
+// POST /api/approvals/policies
+func (h *ApprovalHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var req models.ApprovalPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	policy, err := h.Service.CreatePolicy(r.Context(), &req, userID)
+	if err != nil {
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	h.logPolicyAction(r, "CREATE", policy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// UpdatePolicy replaces a policy's rules
+// PUT /api/approvals/policies/{id}
+func (h *ApprovalHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
+		return
+	}
+	var req models.ApprovalPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	policy, err := h.Service.UpdatePolicy(r.Context(), id, &req, userID)
+	if err != nil {
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	h.logPolicyAction(r, "UPDATE", policy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// BankReconciliationHandler handles bank statement import and the reconciliation queue
+type BankReconciliationHandler struct {
+	Service         *services.BankReconciliationService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewBankReconciliationHandler(s *services.BankReconciliationService, adminActionRepo *repositories.AdminActionLogRepository) *BankReconciliationHandler {
+	return &BankReconciliationHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// ImportStatement uploads a bank statement and matches its credits
+// POST /api/bank-reconciliation/imports (multipart: file=.csv|.xlsx, bank_account)
+func (h *BankReconciliationHandler) ImportStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse multipart form (max 20MB)
+	if err := r.ParseMultipartForm(20 << 20); err != nil {
+		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "No file uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext != ".csv" && ext != ".xlsx" {
+		http.Error(w, "Only .csv and .xlsx statements are supported", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Service.Import(ctx, filepath.Base(header.Filename), r.FormValue("bank_account"), data, userID)
+	if errors.Is(err, services.ErrInvalidBankLine) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to import statement: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.AdminActionRepo.CreateActionLog(ctx, &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "BANK_STATEMENT_IMPORT",
+		TargetType:  "bank_statement_import",
+		TargetID:    &result.Import.ID,
+		Description: fmt.Sprintf("Imported bank statement %s: %d credits, %d matched, %d unmatched",
+			result.Import.FileName, result.Import.LineCount, result.Matched, result.Unmatched),
+	})
+	cache.InvalidatePaymentCaches(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ListImports returns recent statement imports
+// GET /api/bank-reconciliation/imports?limit=50
+func (h *BankReconciliationHandler) ListImports(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	imports, err := h.Service.ListImports(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imports)
+}
+
+// ListLines returns bank lines; status=unmatched (default) is the reconciliation queue
+// GET /api/bank-reconciliation/lines?status=unmatched&import_id=&limit=
+func (h *BankReconciliationHandler) ListLines(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	importID, _ := strconv.Atoi(query.Get("import_id"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > 5000 {
+		limit = 500
+	}
+	status := query.Get("status")
+	if status == "" {
+		status = models.BankLineStatusUnmatched
+	} else if status == "all" {
+		status = ""
+	}
+
+	lines, err := h.Service.ListLines(r.Context(), importID, status, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lines)
+}
+
+// GetCandidates returns the records a bank line could be linked to
+// GET /api/bank-reconciliation/lines/{id}/candidates
+func (h *BankReconciliationHandler) GetCandidates(w http.ResponseWriter, r *http.Request) {
+	lineID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid line ID", http.StatusBadRequest)
+		return
+	}
+
+	candidates, err := h.Service.GetCandidates(r.Context(), lineID)
+	if err != nil {
+		http.Error(w, "Bank line not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candidates)
+}
+
+// LinkLine settles a bank line with an existing payment, online transaction or ledger entry
+// POST /api/bank-reconciliation/lines/{id}/link
+func (h *BankReconciliationHandler) LinkLine(w http.ResponseWriter, r *http.Request) {
+	var req models.LinkBankLineRequest
+	h.resolveLine(w, r, &req, "BANK_LINE_LINK", func(lineID, userID int) (*models.BankStatementLine, error) {
+		return h.Service.Link(r.Context(), lineID, &req, userID)
+	})
+}
+
+// PostLine creates a customer receipt in the ledger from an unmatched bank line
+// POST /api/bank-reconciliation/lines/{id}/post
+func (h *BankReconciliationHandler) PostLine(w http.ResponseWriter, r *http.Request) {
+	var req models.PostBankLineRequest
+	h.resolveLine(w, r, &req, "BANK_LINE_POST", func(lineID, userID int) (*models.BankStatementLine, error) {
+		return h.Service.Post(r.Context(), lineID, &req, userID)
+	})
+}
+
+// IgnoreLine takes a credit that is not a customer receipt out of the queue
+// POST /api/bank-reconciliation/lines/{id}/ignore
+func (h *BankReconciliationHandler) IgnoreLine(w http.ResponseWriter, r *http.Request) {
+	var req models.ResolveBankLineRequest
+	h.resolveLine(w, r, &req, "BANK_LINE_IGNORE", func(lineID, userID int) (*models.BankStatementLine, error) {
+		return h.Service.Ignore(r.Context(), lineID, req.Notes, userID)
+	})
+}
+
+// UnlinkLine returns a matched, linked or ignored line to the queue
+// POST /api/bank-reconciliation/lines/{id}/unlink
+func (h *BankReconciliationHandler) UnlinkLine(w http.ResponseWriter, r *http.Request) {
+	var req models.ResolveBankLineRequest
+	h.resolveLine(w, r, &req, "BANK_LINE_UNLINK", func(lineID, userID int) (*models.BankStatementLine, error) {
+		return h.Service.Unlink(r.Context(), lineID, req.Notes)
+	})
+}
+
+// Rematch retries automatic matching of unmatched lines
+// POST /api/bank-reconciliation/rematch?import_id=
+func (h *BankReconciliationHandler) Rematch(w http.ResponseWriter, r *http.Request) {
+	importID, _ := strconv.Atoi(r.URL.Query().Get("import_id"))
+
+	matched, err := h.Service.Rematch(r.Context(), importID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"matched": matched})
+}
+
+// resolveLine decodes req, applies a reconciliation action to the line in the URL and logs it
+func (h *BankReconciliationHandler) resolveLine(w http.ResponseWriter, r *http.Request, req interface{}, actionType string,
+	apply func(lineID, userID int) (*models.BankStatementLine, error)) {
+	ctx := r.Context()
+	userID, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+	lineID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid line ID", http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	line, err := apply(lineID, userID)
+	if errors.Is(err, services.ErrInvalidBankLine) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, repositories.ErrBankLineConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update bank line: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.AdminActionRepo.CreateActionLog(ctx, &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  actionType,
+		TargetType:  "bank_statement_line",
+		TargetID:    &line.ID,
+		Description: fmt.Sprintf("Bank credit ₹%.2f on %s (%s) is now %s %s",
+			line.Amount, line.TxnDate.Format("02-01-2006"), line.UTR, line.Status, line.MatchedRef),
+	})
+	cache.InvalidatePaymentCaches(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(line)
+}
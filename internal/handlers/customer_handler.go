@@ -22,7 +22,6 @@ const customersCacheKey = "customers:list"
 type CustomerHandler struct {
 	Service           *services.CustomerService
 	ManagementLogRepo *repositories.EntryManagementLogRepository
-	LedgerRepo        *repositories.LedgerRepository
 }
 
 func NewCustomerHandler(s *services.CustomerService, managementLogRepo *repositories.EntryManagementLogRepository) *CustomerHandler {
@@ -40,11 +39,6 @@ func NewCustomerHandler(s *services.CustomerService, managementLogRepo *reposito
 	return h
 }
 
-// SetLedgerRepo sets the ledger repository for cascading phone updates to ledger
-func (h *CustomerHandler) SetLedgerRepo(ledgerRepo *repositories.LedgerRepository) {
-	h.LedgerRepo = ledgerRepo
-}
-
 func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateCustomerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -161,24 +155,12 @@ func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get old phone before update (for cascading to ledger)
-	oldCustomer, _ := h.Service.GetCustomer(context.Background(), id)
-	oldPhone := ""
-	if oldCustomer != nil {
-		oldPhone = oldCustomer.Phone
-	}
-
 	customer, err := h.Service.UpdateCustomer(context.Background(), id, &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Cascade phone change to ledger entries if phone changed
-	if h.LedgerRepo != nil && oldPhone != "" && oldPhone != customer.Phone {
-		_, _ = h.LedgerRepo.UpdateCustomerPhone(context.Background(), oldPhone, customer.Phone)
-	}
-
 	// Invalidate customers cache
 	cache.InvalidateCustomerCaches(r.Context())
 
@@ -294,11 +276,6 @@ func (h *CustomerHandler) MergeCustomers(w http.ResponseWriter, r *http.Request)
 		h.ManagementLogRepo.CreateMergeLog(context.Background(), managementLog)
 	}
 
-	// Cascade phone change to ledger entries (source phone -> target phone)
-	if h.LedgerRepo != nil && sourceCustomer.Phone != targetCustomer.Phone {
-		_, _ = h.LedgerRepo.UpdateCustomerPhone(context.Background(), sourceCustomer.Phone, targetCustomer.Phone)
-	}
-
 	// Invalidate caches
 	cache.InvalidateCustomerCaches(r.Context())
 	cache.InvalidateEntryCaches(r.Context())
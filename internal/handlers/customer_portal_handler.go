@@ -428,6 +428,22 @@ func (h *CustomerPortalHandler) CreateGatePassRequest(w http.ResponseWriter, r *
 	})
 }
 
+// ListPickupSlots returns the pickup slots a customer can book with a gate pass request
+// GET /api/pickup-slots
+func (h *CustomerPortalHandler) ListPickupSlots(w http.ResponseWriter, r *http.Request) {
+	slots, err := h.CustomerPortalService.ListPickupSlots(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to load pickup slots", http.StatusInternalServerError)
+		return
+	}
+	if slots == nil {
+		slots = []*models.PickupSlot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slots)
+}
+
 // Logout clears the customer session
 func (h *CustomerPortalHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	// Clear cookie
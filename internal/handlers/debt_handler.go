@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -176,9 +177,23 @@ func (h *DebtHandler) ApproveDebtRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.DebtService.Approve(ctx, id, userID, userName)
+	// Password and 2FA code, when the approval policy asks for them
+	var creds models.ApprovalCredentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	creds.IPAddress = getIPAddress(r)
+
+	approval, err := h.DebtService.Approve(ctx, id, userID, userName, &creds)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if approval != nil && approval.Status != models.ApprovalStatusApproved {
+		writeApprovalRecorded(w, approval)
 		return
 	}
 
@@ -230,9 +245,11 @@ func (h *DebtHandler) RejectDebtRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.DebtService.Reject(ctx, id, userID, userName, req.RejectionReason)
+	err = h.DebtService.Reject(ctx, id, userID, userName, req.RejectionReason, getIPAddress(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -52,6 +53,10 @@ func (h *EntryHandler) CreateEntry(w http.ResponseWriter, r *http.Request) {
 	}
 
 	entry, err := h.Service.CreateEntry(context.Background(), &req, userID)
+	if errors.Is(err, repositories.ErrThockNumberNotReserved) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -59,6 +64,9 @@ func (h *EntryHandler) CreateEntry(w http.ResponseWriter, r *http.Request) {
 
 	// Log entry creation
 	description := "Created unloading ticket " + entry.ThockNumber + " for " + req.Name + " (" + req.Village + ") - Qty: " + strconv.Itoa(req.ExpectedQuantity)
+	if req.ReservedNumber > 0 {
+		description += " | Reserved number " + strconv.Itoa(req.ReservedNumber)
+	}
 	if req.Remark != "" {
 		description += " | Remark: " + req.Remark
 	}
@@ -360,12 +368,6 @@ func (h *EntryHandler) ReassignEntry(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(entry)
 }
 
-// SkipRange for JSON parsing
-type SkipRangeEntry struct {
-	From int `json:"from"`
-	To   int `json:"to"`
-}
-
 // GetNextThockPreview returns the next thock numbers for both categories considering skip ranges
 func (h *EntryHandler) GetNextThockPreview(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -374,33 +376,16 @@ func (h *EntryHandler) GetNextThockPreview(w http.ResponseWriter, r *http.Reques
 	seedCount, _ := h.Service.GetCountByCategory(ctx, "seed")
 	sellCount, _ := h.Service.GetCountByCategory(ctx, "sell")
 
-	// Get max thock numbers (more accurate when skip ranges exist)
-	maxSeed, _ := h.Service.GetMaxThockNumber(ctx, "seed")
-	maxSell, _ := h.Service.GetMaxThockNumber(ctx, "sell")
-
-	// Calculate base next numbers from MAX
-	nextSeed := maxSeed + 1
-	nextSell := maxSell + 1
-
-	// Get skip ranges from settings if SettingService is available
-	if h.SettingService != nil {
-		// Get seed skip ranges
-		seedSetting, _ := h.SettingService.GetSetting(ctx, "skip_thock_ranges_seed")
-		if seedSetting != nil && seedSetting.SettingValue != "" {
-			var seedRanges []SkipRangeEntry
-			if json.Unmarshal([]byte(seedSetting.SettingValue), &seedRanges) == nil {
-				nextSeed = calculateNextWithSkips(nextSeed, seedRanges)
-			}
-		}
-
-		// Get sell skip ranges
-		sellSetting, _ := h.SettingService.GetSetting(ctx, "skip_thock_ranges_sell")
-		if sellSetting != nil && sellSetting.SettingValue != "" {
-			var sellRanges []SkipRangeEntry
-			if json.Unmarshal([]byte(sellSetting.SettingValue), &sellRanges) == nil {
-				nextSell = calculateNextWithSkips(nextSell, sellRanges)
-			}
-		}
+	// Next numbers from the current season's series, past skip ranges and reserved numbers
+	nextSeed, err := h.Service.PreviewNextThockNumber(ctx, "seed")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nextSell, err := h.Service.PreviewNextThockNumber(ctx, "sell")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	// Format the thock numbers
@@ -418,26 +403,6 @@ func (h *EntryHandler) GetNextThockPreview(w http.ResponseWriter, r *http.Reques
 	})
 }
 
-// calculateNextWithSkips finds the next valid number by skipping ranges
-func calculateNextWithSkips(num int, ranges []SkipRangeEntry) int {
-	// Keep incrementing if the number falls within any skip range
-	for {
-		inSkipRange := false
-		for _, r := range ranges {
-			if num >= r.From && num <= r.To {
-				// Jump to after this range
-				num = r.To + 1
-				inSkipRange = true
-				break
-			}
-		}
-		if !inSkipRange {
-			break
-		}
-	}
-	return num
-}
-
 // padThockNumber formats the thock number based on category
 func padThockNumber(num int, category string) string {
 	if category == "seed" {
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -34,6 +35,21 @@ func (h *GatePassHandler) SetSyncService(s *services.MediaSyncService) {
 	h.SyncService = s
 }
 
+// writeGatePassError reports status changes the gate pass state machine refuses as 409
+func writeGatePassError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrIllegalGatePassTransition),
+		errors.Is(err, repositories.ErrAmendmentDecided),
+		errors.Is(err, repositories.ErrAmendmentPending),
+		errors.Is(err, repositories.ErrGatePassAlreadyReissued):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrAmendmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
 // CreateGatePass issues a new gate pass
 func (h *GatePassHandler) CreateGatePass(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateGatePassRequest
@@ -129,9 +145,16 @@ func (h *GatePassHandler) ApproveGatePass(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = h.Service.ApproveGatePass(context.Background(), id, &req, userID)
+	req.IPAddress = getIPAddress(r)
+	approval, err := h.Service.ApproveGatePass(context.Background(), id, &req, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if !writeApprovalError(w, err) {
+			writeGatePassError(w, err)
+		}
+		return
+	}
+	if approval != nil && approval.Status == models.ApprovalStatusPending {
+		writeApprovalRecorded(w, approval)
 		return
 	}
 
@@ -171,7 +194,7 @@ func (h *GatePassHandler) CompleteGatePass(w http.ResponseWriter, r *http.Reques
 
 	err = h.Service.CompleteGatePass(context.Background(), id, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeGatePassError(w, err)
 		return
 	}
 
@@ -211,7 +234,7 @@ func (h *GatePassHandler) RecordPickup(w http.ResponseWriter, r *http.Request) {
 
 	pickupID, err := h.Service.RecordPickup(context.Background(), &req, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeGatePassError(w, err)
 		return
 	}
 
@@ -258,6 +281,28 @@ func (h *GatePassHandler) GetPickupHistory(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(pickups)
 }
 
+// GetTransitions returns a gate pass's status history
+// GET /api/gate-passes/{id}/transitions
+func (h *GatePassHandler) GetTransitions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := h.Service.GetTransitions(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if transitions == nil {
+		transitions = []*models.GatePassTransition{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transitions)
+}
+
 // ListAllPickups retrieves all pickups with customer info for activity log
 func (h *GatePassHandler) ListAllPickups(w http.ResponseWriter, r *http.Request) {
 	pickups, err := h.Service.GetAllPickups(context.Background())
@@ -407,3 +452,228 @@ func (h *GatePassHandler) SaveMediaMetadata(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(media)
 }
+
+// logGatePassAction records a change to a gate pass in the admin action log
+func (h *GatePassHandler) logGatePassAction(r *http.Request, action string, gatePassID int, description string) {
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  action,
+		TargetType:  "gate_pass",
+		TargetID:    &gatePassID,
+		Description: description,
+		IPAddress:   &ipAddress,
+	})
+}
+
+// CancelGatePass cancels an open gate pass, releasing the bags not yet picked up
+// POST /api/gate-passes/{id}/cancel
+func (h *GatePassHandler) CancelGatePass(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	var req models.CancelGatePassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	gatePass, err := h.Service.CancelGatePass(r.Context(), id, req.Reason, userID)
+	if err != nil {
+		writeGatePassError(w, err)
+		return
+	}
+
+	cache.InvalidateGatePassCaches(r.Context())
+	h.logGatePassAction(r, "CANCEL", id, fmt.Sprintf("Cancelled gate pass #%d for thock %s after %d items picked up. Reason: %s",
+		id, gatePass.ThockNumber, gatePass.TotalPickedUp, req.Reason))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gatePass)
+}
+
+// SetPickupSlot moves an open gate pass to another pickup slot, or clears its slot
+// PUT /api/gate-passes/{id}/pickup-slot
+func (h *GatePassHandler) SetPickupSlot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	var req models.SetPickupSlotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	gatePass, err := h.Service.SetPickupSlot(r.Context(), id, req.PickupSlotID)
+	if err != nil {
+		writeGatePassError(w, err)
+		return
+	}
+
+	cache.InvalidateGatePassCaches(r.Context())
+	description := fmt.Sprintf("Took gate pass #%d for thock %s out of its pickup slot", id, gatePass.ThockNumber)
+	if req.PickupSlotID != nil {
+		description = fmt.Sprintf("Booked gate pass #%d for thock %s into pickup slot %d", id, gatePass.ThockNumber, *req.PickupSlotID)
+	}
+	h.logGatePassAction(r, "PICKUP_SLOT", id, description)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gatePass)
+}
+
+// RequestAmendment asks to change the quantity or gate of an approved gate pass
+// POST /api/gate-passes/{id}/amendments
+func (h *GatePassHandler) RequestAmendment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	var req models.AmendGatePassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	amendment, err := h.Service.RequestAmendment(r.Context(), id, &req, userID)
+	if err != nil {
+		writeGatePassError(w, err)
+		return
+	}
+
+	h.logGatePassAction(r, "AMEND_REQUEST", id, fmt.Sprintf("Asked to amend gate pass #%d from %d items at gate %s to %d items at gate %s. Reason: %s",
+		id, amendment.OldQuantity, amendment.OldGateNo, amendment.NewQuantity, amendment.NewGateNo, amendment.Reason))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(amendment)
+}
+
+// ListAmendments returns a gate pass's amendments
+// GET /api/gate-passes/{id}/amendments
+func (h *GatePassHandler) ListAmendments(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	amendments, err := h.Service.ListAmendments(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if amendments == nil {
+		amendments = []*models.GatePassAmendment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(amendments)
+}
+
+// ListPendingAmendments returns the amendments waiting for approval
+// GET /api/gate-passes/amendments/pending
+func (h *GatePassHandler) ListPendingAmendments(w http.ResponseWriter, r *http.Request) {
+	amendments, err := h.Service.ListPendingAmendments(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if amendments == nil {
+		amendments = []*models.GatePassAmendment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(amendments)
+}
+
+// decideAmendment approves or rejects an amendment
+func (h *GatePassHandler) decideAmendment(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["amendment_id"])
+	if err != nil {
+		http.Error(w, "Invalid amendment ID", http.StatusBadRequest)
+		return
+	}
+	var req models.DecideAmendmentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	role, _ := middleware.GetRoleFromContext(r.Context())
+
+	var amendment *models.GatePassAmendment
+	if approve {
+		amendment, err = h.Service.ApproveAmendment(r.Context(), id, req.Remarks, userID, role == "admin")
+	} else {
+		amendment, err = h.Service.RejectAmendment(r.Context(), id, req.Remarks, userID, role == "admin")
+	}
+	if err != nil {
+		writeGatePassError(w, err)
+		return
+	}
+
+	if approve {
+		cache.InvalidateGatePassCaches(r.Context())
+		h.logGatePassAction(r, "AMEND_APPROVE", amendment.GatePassID, fmt.Sprintf("Approved amendment %d of gate pass #%d: %d items at gate %s",
+			amendment.ID, amendment.GatePassID, amendment.NewQuantity, amendment.NewGateNo))
+	} else {
+		h.logGatePassAction(r, "AMEND_REJECT", amendment.GatePassID, fmt.Sprintf("Rejected amendment %d of gate pass #%d. Remarks: %s",
+			amendment.ID, amendment.GatePassID, req.Remarks))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(amendment)
+}
+
+// ApproveAmendment applies an amendment to its gate pass
+// POST /api/gate-passes/amendments/{amendment_id}/approve
+func (h *GatePassHandler) ApproveAmendment(w http.ResponseWriter, r *http.Request) {
+	h.decideAmendment(w, r, true)
+}
+
+// RejectAmendment turns down an amendment
+// POST /api/gate-passes/amendments/{amendment_id}/reject
+func (h *GatePassHandler) RejectAmendment(w http.ResponseWriter, r *http.Request) {
+	h.decideAmendment(w, r, false)
+}
+
+// ReissueGatePass issues a new gate pass for what an expired one did not deliver
+// POST /api/gate-passes/{id}/reissue
+func (h *GatePassHandler) ReissueGatePass(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	var req models.ReissueGatePassRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	gatePass, err := h.Service.ReissueGatePass(r.Context(), id, &req, userID)
+	if err != nil {
+		writeGatePassError(w, err)
+		return
+	}
+
+	cache.InvalidateGatePassCaches(r.Context())
+	h.logGatePassAction(r, "REISSUE", gatePass.ID, fmt.Sprintf("Re-issued expired gate pass #%d as gate pass #%d for %d items",
+		id, gatePass.ID, gatePass.RequestedQuantity))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(gatePass)
+}
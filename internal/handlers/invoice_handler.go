@@ -3,11 +3,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"cold-backend/internal/middleware"
 	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
 	"cold-backend/internal/services"
 
 	"github.com/gorilla/mux"
@@ -41,6 +45,11 @@ func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The issuing employee is the logged-in user, not whatever the client sends
+	if userID, ok := middleware.GetUserIDFromContext(r.Context()); ok {
+		req.EmployeeID = userID
+	}
+
 	invoice, err := h.Service.CreateInvoice(context.Background(), &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -126,3 +135,77 @@ func (h *InvoiceHandler) GetCustomerInvoices(w http.ResponseWriter, r *http.Requ
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(invoices)
 }
+
+// CancelInvoice cancels a tax invoice and returns the credit note issued for it
+// POST /api/invoices/{id}/cancel
+func (h *InvoiceHandler) CancelInvoice(w http.ResponseWriter, r *http.Request) {
+	role, ok := middleware.GetRoleFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if role != "admin" && role != "accountant" {
+		http.Error(w, "Forbidden - admin or accountant access required", http.StatusForbidden)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CancelInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	creditNote, err := h.Service.CancelInvoice(r.Context(), id, userID, req.Reason)
+	if err != nil {
+		if errors.Is(err, repositories.ErrInvoiceNotCancellable) || errors.Is(err, services.ErrInvoiceCancelReasonRequired) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creditNote)
+}
+
+// GetInvoicePDF renders a tax invoice or credit note as PDF
+// GET /api/invoices/{id}/pdf
+func (h *InvoiceHandler) GetInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	invoice, err := h.Service.GetInvoice(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	company, err := h.Service.GetCompany(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pdfData, err := h.Service.GenerateInvoicePDF(invoice, company)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate PDF: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	filename := strings.ReplaceAll(invoice.InvoiceNumber, "/", "-") + ".pdf"
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Write(pdfData)
+}
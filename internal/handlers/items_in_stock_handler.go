@@ -9,17 +9,20 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"cold-backend/internal/middleware"
+	"cold-backend/internal/repositories"
 )
 
 // ItemsInStockHandler handles items in stock endpoints
 type ItemsInStockHandler struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	LayoutRepo *repositories.WarehouseLayoutRepository
 }
 
 // NewItemsInStockHandler creates a new items in stock handler
 func NewItemsInStockHandler(db *pgxpool.Pool) *ItemsInStockHandler {
 	return &ItemsInStockHandler{
-		DB: db,
+		DB:         db,
+		LayoutRepo: repositories.NewWarehouseLayoutRepository(db),
 	}
 }
 
@@ -45,6 +48,7 @@ type StockSummary struct {
 	TotalQuantity  int                 `json:"total_quantity"`
 	TotalCustomers int                 `json:"total_customers"`
 	CapacityUsed   float64             `json:"capacity_used"`
+	TotalCapacity  int                 `json:"total_capacity"`
 	ByRoom         map[string]RoomStat `json:"by_room"`
 	ByFloor        map[string]int      `json:"by_floor"`
 }
@@ -115,7 +119,7 @@ func (h *ItemsInStockHandler) queryItemsInStock(ctx context.Context) ([]ItemInSt
 				(SELECT SUM(gp.total_picked_up) 
 				 FROM gate_passes gp 
 				 WHERE gp.thock_number = e.thock_number 
-				 AND gp.status IN ('completed', 'partially_completed', 'approved')
+				 AND gp.total_picked_up > 0
 				), 0
 			) as current_qty,
 			re.room_no,
@@ -131,7 +135,7 @@ func (h *ItemsInStockHandler) queryItemsInStock(ctx context.Context) ([]ItemInSt
 			(SELECT SUM(gp.total_picked_up) 
 			 FROM gate_passes gp 
 			 WHERE gp.thock_number = e.thock_number 
-			 AND gp.status IN ('completed', 'partially_completed', 'approved')
+			 AND gp.total_picked_up > 0
 			), 0
 		) > 0
 		ORDER BY re.room_no, re.floor, e.thock_number
@@ -208,9 +212,15 @@ func (h *ItemsInStockHandler) calculateSummary(ctx context.Context) (*StockSumma
 	summary.TotalQuantity = totalQty
 	summary.TotalCustomers = len(uniqueCustomers)
 
-	// Calculate capacity used (total capacity = 140,000 bags)
-	const totalCapacity = 140000
-	summary.CapacityUsed = (float64(totalQty) / float64(totalCapacity)) * 100
+	// Calculate capacity used against the warehouse layout (enabled gatars in active rooms)
+	totalCapacity, err := h.LayoutRepo.TotalCapacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary.TotalCapacity = totalCapacity
+	if totalCapacity > 0 {
+		summary.CapacityUsed = (float64(totalQty) / float64(totalCapacity)) * 100
+	}
 
 	return summary, nil
 }
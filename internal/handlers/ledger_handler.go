@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,13 +10,17 @@ import (
 	"cold-backend/internal/middleware"
 	"cold-backend/internal/models"
 	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
 
 	"github.com/gorilla/mux"
 )
 
 // LedgerHandler handles ledger-related endpoints
 type LedgerHandler struct {
-	LedgerService *services.LedgerService
+	LedgerService     *services.LedgerService
+	AccrualService    *services.RentAccrualService     // Optional, employee mode only
+	IntegrityService  *services.LedgerIntegrityService // Optional, employee mode only
+	AdjustmentService *services.RentAdjustmentService  // Optional, employee mode only
 }
 
 func NewLedgerHandler(ledgerService *services.LedgerService) *LedgerHandler {
@@ -24,6 +29,21 @@ func NewLedgerHandler(ledgerService *services.LedgerService) *LedgerHandler {
 	}
 }
 
+// SetRentAccrualService enables the rent accrual endpoints
+func (h *LedgerHandler) SetRentAccrualService(s *services.RentAccrualService) {
+	h.AccrualService = s
+}
+
+// SetLedgerIntegrityService enables the ledger verify/repair endpoints
+func (h *LedgerHandler) SetLedgerIntegrityService(s *services.LedgerIntegrityService) {
+	h.IntegrityService = s
+}
+
+// SetRentAdjustmentService enables the interest/discount policy endpoints
+func (h *LedgerHandler) SetRentAdjustmentService(s *services.RentAdjustmentService) {
+	h.AdjustmentService = s
+}
+
 // GetCustomerLedger returns all ledger entries for a specific customer
 // GET /api/ledger/customer/{phone}
 func (h *LedgerHandler) GetCustomerLedger(w http.ResponseWriter, r *http.Request) {
@@ -107,6 +127,85 @@ func (h *LedgerHandler) GetCustomerSummary(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(summary)
 }
 
+// customerIDParam parses the {id} path variable of the by-customer-ID routes
+func customerIDParam(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	return id, err == nil && id > 0
+}
+
+// GetCustomerLedgerByID returns all ledger entries for a customer by ID
+// GET /api/ledger/customer-id/{id}
+func (h *LedgerHandler) GetCustomerLedgerByID(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := customerIDParam(r)
+	if !ok {
+		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	entries, err := h.LedgerService.GetCustomerLedgerByID(r.Context(), customerID, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// GetCustomerBalanceByID returns the current balance for a customer by ID
+// GET /api/ledger/balance-id/{id}
+func (h *LedgerHandler) GetCustomerBalanceByID(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := customerIDParam(r)
+	if !ok {
+		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+
+	balance, err := h.LedgerService.GetBalanceByCustomerID(r.Context(), customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"customer_id": customerID,
+		"balance":     balance,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCustomerSummaryByID returns balance summary for a customer by ID
+// GET /api/ledger/summary-id/{id}
+func (h *LedgerHandler) GetCustomerSummaryByID(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := customerIDParam(r)
+	if !ok {
+		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.LedgerService.GetCustomerSummaryByID(r.Context(), customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if summary == nil {
+		http.Error(w, "No ledger entries for this customer", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
 // GetAuditTrail returns all ledger entries with optional filters (admin only)
 // GET /api/ledger/audit
 func (h *LedgerHandler) GetAuditTrail(w http.ResponseWriter, r *http.Request) {
@@ -131,6 +230,9 @@ func (h *LedgerHandler) GetAuditTrail(w http.ResponseWriter, r *http.Request) {
 		EntryType:     models.LedgerEntryType(r.URL.Query().Get("type")),
 	}
 
+	if customerIDStr := r.URL.Query().Get("customer_id"); customerIDStr != "" {
+		filter.CustomerID, _ = strconv.Atoi(customerIDStr)
+	}
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		filter.Limit, _ = strconv.Atoi(limitStr)
 	}
@@ -172,6 +274,7 @@ func (h *LedgerHandler) GetAuditTrail(w http.ResponseWriter, r *http.Request) {
 		auditEntries[i] = models.AuditEntry{
 			ID:               e.ID,
 			Date:             e.CreatedAt,
+			CustomerID:       e.CustomerID,
 			CustomerPhone:    e.CustomerPhone,
 			CustomerName:     e.CustomerName,
 			CustomerSO:       e.CustomerSO,
@@ -324,3 +427,384 @@ func (h *LedgerHandler) GetTotalsByType(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(totals)
 }
+
+// RunRentAccrual posts any rent not yet charged to the ledger (safe to repeat)
+// POST /api/ledger/accrual/run
+func (h *LedgerHandler) RunRentAccrual(w http.ResponseWriter, r *http.Request) {
+	if h.AccrualService == nil {
+		http.Error(w, "Rent accrual not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	run, err := h.AccrualService.Run(r.Context(), timeutil.Now(), models.RentAccrualTriggerManual, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// ListRentAccrualRuns returns recent rent accrual runs
+// GET /api/ledger/accrual/runs
+func (h *LedgerHandler) ListRentAccrualRuns(w http.ResponseWriter, r *http.Request) {
+	if h.AccrualService == nil {
+		http.Error(w, "Rent accrual not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := h.AccrualService.ListRuns(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []*models.RentAccrualRun{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// VerifyIntegrity recomputes running balances and cross-checks payments, online transactions and debt approvals
+// GET /api/ledger/integrity
+func (h *LedgerHandler) VerifyIntegrity(w http.ResponseWriter, r *http.Request) {
+	if h.IntegrityService == nil {
+		http.Error(w, "Ledger verifier not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	report, err := h.IntegrityService.Verify(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RepairIntegrity rewrites wrong running balances and returns the report after repair
+// POST /api/ledger/integrity/repair
+func (h *LedgerHandler) RepairIntegrity(w http.ResponseWriter, r *http.Request) {
+	if h.IntegrityService == nil {
+		http.Error(w, "Ledger verifier not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.IntegrityService.Repair(r.Context(), userID, getIPAddress(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetAccounts returns the chart of accounts
+// GET /api/ledger/accounts
+func (h *LedgerHandler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.LedgerService.ListAccounts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if accounts == nil {
+		accounts = []models.LedgerAccount{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// GetTrialBalance returns closing balances of all accounts
+// GET /api/ledger/trial-balance?as_of=YYYY-MM-DD (default today)
+func (h *LedgerHandler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	asOf, err := parseDateParam(r, "as_of", timeutil.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tb, err := h.LedgerService.GetTrialBalance(r.Context(), asOf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tb)
+}
+
+// GetDayBook returns journal entries for a date range, optionally for one account
+// GET /api/ledger/day-book?from=YYYY-MM-DD&to=YYYY-MM-DD&account=1000 (default today, all accounts)
+func (h *LedgerHandler) GetDayBook(w http.ResponseWriter, r *http.Request) {
+	now := timeutil.Now()
+	from, err := parseDateParam(r, "from", now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(r, "to", from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.LedgerService.GetDayBook(r.Context(), from, to, r.URL.Query().Get("account"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// GetProfitAndLoss returns income, expenses and net profit for a date range
+// GET /api/ledger/profit-loss?from=YYYY-MM-DD&to=YYYY-MM-DD (default current financial year to date)
+func (h *LedgerHandler) GetProfitAndLoss(w http.ResponseWriter, r *http.Request) {
+	now := timeutil.Now()
+	fyStart := time.Date(now.Year(), time.April, 1, 0, 0, 0, 0, timeutil.IST)
+	if now.Before(fyStart) {
+		fyStart = fyStart.AddDate(-1, 0, 0)
+	}
+
+	from, err := parseDateParam(r, "from", fyStart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(r, "to", now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	pl, err := h.LedgerService.GetProfitAndLoss(r.Context(), from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pl)
+}
+
+// parseDateParam parses a YYYY-MM-DD query parameter as an IST date, or returns def if absent
+func parseDateParam(r *http.Request, key string, def time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	t, err := timeutil.ParseInIST("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date, use YYYY-MM-DD", key)
+	}
+	return t, nil
+}
+
+// ListAdjustmentPolicies returns interest and discount policies
+// GET /api/ledger/adjustment-policies?active=true
+func (h *LedgerHandler) ListAdjustmentPolicies(w http.ResponseWriter, r *http.Request) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	policies, err := h.AdjustmentService.ListPolicies(r.Context(), r.URL.Query().Get("active") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policies == nil {
+		policies = []*models.RentAdjustmentPolicy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+// GetAdjustmentPolicy returns a single policy
+// GET /api/ledger/adjustment-policies/{id}
+func (h *LedgerHandler) GetAdjustmentPolicy(w http.ResponseWriter, r *http.Request) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.AdjustmentService.GetPolicy(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Policy not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// CreateAdjustmentPolicy adds an interest or discount policy (admin only)
+// POST /api/ledger/adjustment-policies
+func (h *LedgerHandler) CreateAdjustmentPolicy(w http.ResponseWriter, r *http.Request) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	var req models.RentAdjustmentPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.AdjustmentService.CreatePolicy(r.Context(), &req, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// UpdateAdjustmentPolicy changes a policy's terms (admin only). Postings already made are kept.
+// PUT /api/ledger/adjustment-policies/{id}
+func (h *LedgerHandler) UpdateAdjustmentPolicy(w http.ResponseWriter, r *http.Request) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.RentAdjustmentPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.AdjustmentService.UpdatePolicy(r.Context(), id, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// ActivateAdjustmentPolicy puts a retired policy back in force (admin only)
+// PUT /api/ledger/adjustment-policies/{id}/activate
+func (h *LedgerHandler) ActivateAdjustmentPolicy(w http.ResponseWriter, r *http.Request) {
+	h.setAdjustmentPolicyActive(w, r, true)
+}
+
+// DeactivateAdjustmentPolicy retires a policy (admin only)
+// PUT /api/ledger/adjustment-policies/{id}/deactivate
+func (h *LedgerHandler) DeactivateAdjustmentPolicy(w http.ResponseWriter, r *http.Request) {
+	h.setAdjustmentPolicyActive(w, r, false)
+}
+
+func (h *LedgerHandler) setAdjustmentPolicyActive(w http.ResponseWriter, r *http.Request, active bool) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.AdjustmentService.SetPolicyActive(r.Context(), id, active); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        id,
+		"is_active": active,
+	})
+}
+
+// PreviewRentAdjustments shows the interest and discounts a run would post, without posting
+// GET /api/ledger/adjustments/preview?as_of=YYYY-MM-DD
+func (h *LedgerHandler) PreviewRentAdjustments(w http.ResponseWriter, r *http.Request) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	asOf := timeutil.Now()
+	if asOfStr := r.URL.Query().Get("as_of"); asOfStr != "" {
+		var err error
+		if asOf, err = timeutil.ParseInIST("2006-01-02", asOfStr); err != nil {
+			http.Error(w, "Invalid as_of date. Use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		asOf = timeutil.EndOfDay(asOf)
+	}
+
+	run, err := h.AdjustmentService.Preview(r.Context(), asOf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// RunRentAdjustments posts the interest and discounts due now (safe to repeat)
+// POST /api/ledger/adjustments/run
+func (h *LedgerHandler) RunRentAdjustments(w http.ResponseWriter, r *http.Request) {
+	if h.AdjustmentService == nil {
+		http.Error(w, "Rent adjustments not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	run, err := h.AdjustmentService.Run(r.Context(), timeutil.Now(), models.RentAdjustmentTriggerManual, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -18,6 +19,7 @@ type PendingSettingHandler struct {
 	systemSettingRepo *repositories.SystemSettingRepository
 	userRepo          *repositories.UserRepository
 	totpService       *services.TOTPService
+	approvals         *services.ApprovalService
 }
 
 func NewPendingSettingHandler(
@@ -34,6 +36,27 @@ func NewPendingSettingHandler(
 	}
 }
 
+// SetApprovalService routes setting change approvals through the approval policies
+func (h *PendingSettingHandler) SetApprovalService(approvals *services.ApprovalService) {
+	h.approvals = approvals
+	approvals.OnExpiry(models.ApprovalFlowSetting, h.pendingRepo.Expire)
+}
+
+// settingApprovalSubject describes a setting change to the approval engine
+func settingApprovalSubject(change *models.PendingSettingChange) *models.ApprovalSubject {
+	newValue := change.NewValue
+	if models.IsSensitiveSetting(change.SettingKey) {
+		newValue = models.MaskSensitiveValue(newValue)
+	}
+	requestedBy := change.RequestedBy
+	return &models.ApprovalSubject{
+		Flow:        models.ApprovalFlowSetting,
+		SubjectID:   change.ID,
+		Summary:     fmt.Sprintf("Change setting %s to %s", change.SettingKey, newValue),
+		RequestedBy: &requestedBy,
+	}
+}
+
 // RequestChange initiates a setting change request
 // POST /api/admin/setting-changes
 func (h *PendingSettingHandler) RequestChange(w http.ResponseWriter, r *http.Request) {
@@ -95,6 +118,11 @@ func (h *PendingSettingHandler) RequestChange(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Failed to create request", http.StatusInternalServerError)
 		return
 	}
+	if h.approvals != nil {
+		if _, err := h.approvals.Submit(r.Context(), settingApprovalSubject(change)); err != nil {
+			log.Printf("[PendingSetting] Error opening approval for change %d: %v", change.ID, err)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -192,41 +220,91 @@ func (h *PendingSettingHandler) ApproveChange(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Check that approver is different from requester
-	if change.RequestedBy == userID {
-		http.Error(w, "You cannot approve your own request. Another admin must approve.", http.StatusForbidden)
-		return
-	}
-
-	// Parse and verify password
 	var req models.ApproveSettingChangeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// The approval policy decides who confirms with what and how many must agree
+	if h.approvals != nil {
+		approval, err := h.approvals.Approve(r.Context(), settingApprovalSubject(change), userID, &models.ApprovalCredentials{
+			Password:  req.Password,
+			TOTPCode:  req.TOTPCode,
+			IPAddress: getIPAddress(r),
+		})
+		if err != nil {
+			if !writeApprovalError(w, err) {
+				log.Printf("[PendingSetting] Error approving: %v", err)
+				http.Error(w, "Failed to approve", http.StatusInternalServerError)
+			}
+			return
+		}
+		if approval.Status != models.ApprovalStatusApproved {
+			writeApprovalRecorded(w, approval)
+			return
+		}
+	} else if !h.verifyApprover(w, r, change, userID, &req) {
+		return
+	}
+
+	// Approve the change
+	if err := h.pendingRepo.Approve(r.Context(), id, userID); err != nil {
+		log.Printf("[PendingSetting] Error approving: %v", err)
+		http.Error(w, "Failed to approve", http.StatusInternalServerError)
+		return
+	}
+
+	// Get the full change to apply it
+	change, _ = h.pendingRepo.GetByID(r.Context(), id)
+
+	// Apply the setting change
+	if err := h.systemSettingRepo.Update(r.Context(), change.SettingKey, change.NewValue, userID); err != nil {
+		log.Printf("[PendingSetting] Error applying setting: %v", err)
+		http.Error(w, "Failed to apply setting", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[PendingSetting] Setting '%s' changed by user %d (approved by %d)", change.SettingKey, change.RequestedBy, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Setting change approved and applied",
+	})
+}
+
+// verifyApprover checks the approver without the approval engine: another admin than the
+// requester, with their password and 2FA code if enrolled. It writes the error response.
+func (h *PendingSettingHandler) verifyApprover(w http.ResponseWriter, r *http.Request, change *models.PendingSettingChange, userID int, req *models.ApproveSettingChangeRequest) bool {
+	// Check that approver is different from requester
+	if change.RequestedBy == userID {
+		http.Error(w, "You cannot approve your own request. Another admin must approve.", http.StatusForbidden)
+		return false
+	}
+
 	if req.Password == "" {
 		http.Error(w, "Password is required for approval", http.StatusBadRequest)
-		return
+		return false
 	}
 
 	// Verify approver's password
 	user, err := h.userRepo.Get(r.Context(), userID)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
-		return
+		return false
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 		http.Error(w, "Invalid password", http.StatusUnauthorized)
-		return
+		return false
 	}
 
 	// If approver has 2FA enabled, verify TOTP code
 	if user.TOTPEnabled {
 		if req.TOTPCode == "" {
 			http.Error(w, "2FA code is required for approval", http.StatusBadRequest)
-			return
+			return false
 		}
 
 		ipAddress := getIPAddress(r)
@@ -234,41 +312,18 @@ func (h *PendingSettingHandler) ApproveChange(w http.ResponseWriter, r *http.Req
 		if err != nil {
 			if _, ok := err.(*services.TOTPError); ok {
 				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
+				return false
 			}
 			http.Error(w, "2FA verification failed", http.StatusInternalServerError)
-			return
+			return false
 		}
 		if !valid {
 			http.Error(w, "Invalid 2FA code", http.StatusUnauthorized)
-			return
+			return false
 		}
 	}
 
-	// Approve the change
-	if err := h.pendingRepo.Approve(r.Context(), id, userID); err != nil {
-		log.Printf("[PendingSetting] Error approving: %v", err)
-		http.Error(w, "Failed to approve", http.StatusInternalServerError)
-		return
-	}
-
-	// Get the full change to apply it
-	change, _ = h.pendingRepo.GetByID(r.Context(), id)
-
-	// Apply the setting change
-	if err := h.systemSettingRepo.Update(r.Context(), change.SettingKey, change.NewValue, userID); err != nil {
-		log.Printf("[PendingSetting] Error applying setting: %v", err)
-		http.Error(w, "Failed to apply setting", http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("[PendingSetting] Setting '%s' changed by user %d (approved by %d)", change.SettingKey, change.RequestedBy, userID)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Setting change approved and applied",
-	})
+	return true
 }
 
 // RejectChange rejects a pending setting change
@@ -297,6 +352,21 @@ func (h *PendingSettingHandler) RejectChange(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if h.approvals != nil {
+		change, err := h.pendingRepo.GetByID(r.Context(), id)
+		if err != nil {
+			http.Error(w, "Change request not found", http.StatusNotFound)
+			return
+		}
+		if _, err := h.approvals.Reject(r.Context(), settingApprovalSubject(change), userID, req.Reason, getIPAddress(r)); err != nil {
+			if !writeApprovalError(w, err) {
+				log.Printf("[PendingSetting] Error rejecting: %v", err)
+				http.Error(w, "Failed to reject", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
 	if err := h.pendingRepo.Reject(r.Context(), id, userID, req.Reason); err != nil {
 		log.Printf("[PendingSetting] Error rejecting: %v", err)
 		http.Error(w, "Failed to reject", http.StatusInternalServerError)
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
+
+	"github.com/gorilla/mux"
+)
+
+// PickupSlotHandler handles pickup slots at the loading gates and their queues
+type PickupSlotHandler struct {
+	Service         *services.PickupSlotService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewPickupSlotHandler(s *services.PickupSlotService, adminActionRepo *repositories.AdminActionLogRepository) *PickupSlotHandler {
+	return &PickupSlotHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// writePickupSlotError maps service errors to HTTP status codes
+func writePickupSlotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrPickupSlotNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidPickupSlot):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrPickupSlotExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *PickupSlotHandler) logSlotAction(r *http.Request, action string, slotID *int, description string) {
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  action,
+		TargetType:  "pickup_slot",
+		TargetID:    slotID,
+		Description: description,
+		IPAddress:   &ipAddress,
+	})
+}
+
+// ListSlots returns pickup slots with their bookings, today's by default
+// GET /api/pickup-slots?from=YYYY-MM-DD&to=YYYY-MM-DD&gate=&available=true
+func (h *PickupSlotHandler) ListSlots(w http.ResponseWriter, r *http.Request) {
+	today := timeutil.Now().Format(timeutil.DateLayout)
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = today
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = from
+	}
+
+	slots, err := h.Service.List(r.Context(), from, to, r.URL.Query().Get("gate"), r.URL.Query().Get("available") == "true")
+	if err != nil {
+		writePickupSlotError(w, err)
+		return
+	}
+	if slots == nil {
+		slots = []*models.PickupSlot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slots)
+}
+
+// CreateSlot opens one pickup slot
+// POST /api/pickup-slots
+func (h *PickupSlotHandler) CreateSlot(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePickupSlotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	slot, err := h.Service.Create(r.Context(), &req, userID)
+	if err != nil {
+		writePickupSlotError(w, err)
+		return
+	}
+
+	h.logSlotAction(r, "CREATE", &slot.ID, fmt.Sprintf("Opened pickup slot %s %s-%s at gate %s for %d bags / %d trucks",
+		slot.SlotDate, slot.StartTime, slot.EndTime, slot.Gate, slot.CapacityBags, slot.CapacityTrucks))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(slot)
+}
+
+// GenerateSlots opens back-to-back pickup slots for gates over a range of days
+// POST /api/pickup-slots/generate
+func (h *PickupSlotHandler) GenerateSlots(w http.ResponseWriter, r *http.Request) {
+	var req models.GeneratePickupSlotsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	created, err := h.Service.Generate(r.Context(), &req, userID)
+	if err != nil {
+		writePickupSlotError(w, err)
+		return
+	}
+
+	h.logSlotAction(r, "CREATE", nil, fmt.Sprintf("Opened %d pickup slots of %d minutes from %s to %s at gates %v",
+		created, req.SlotMinutes, req.FromDate, req.ToDate, req.Gates))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"created": created})
+}
+
+// UpdateSlot changes a slot's capacity or closes it for new bookings
+// PUT /api/pickup-slots/{id}
+func (h *PickupSlotHandler) UpdateSlot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid pickup slot ID", http.StatusBadRequest)
+		return
+	}
+	var req models.UpdatePickupSlotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	slot, err := h.Service.Update(r.Context(), id, &req)
+	if err != nil {
+		writePickupSlotError(w, err)
+		return
+	}
+
+	h.logSlotAction(r, "UPDATE", &slot.ID, fmt.Sprintf("Set pickup slot %s %s-%s at gate %s to %d bags / %d trucks, active %t",
+		slot.SlotDate, slot.StartTime, slot.EndTime, slot.Gate, slot.CapacityBags, slot.CapacityTrucks, slot.IsActive))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slot)
+}
+
+// GetQueue returns each loading gate's queue for a day in slot order
+// GET /api/pickup-slots/queue?date=YYYY-MM-DD&gate=
+func (h *PickupSlotHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = timeutil.Now().Format(timeutil.DateLayout)
+	}
+
+	queues, err := h.Service.Queue(r.Context(), date, r.URL.Query().Get("gate"))
+	if err != nil {
+		writePickupSlotError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queues)
+}
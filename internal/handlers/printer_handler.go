@@ -2,86 +2,303 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
 	"cold-backend/internal/services"
+
+	"github.com/gorilla/mux"
 )
 
+// PrinterHandler handles label and receipt printing, the printers at each counter and the print queue
 type PrinterHandler struct {
-	PrinterService *services.PrinterService
+	PrinterService  *services.PrinterService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewPrinterHandler(ps *services.PrinterService, adminActionRepo *repositories.AdminActionLogRepository) *PrinterHandler {
+	return &PrinterHandler{PrinterService: ps, AdminActionRepo: adminActionRepo}
 }
 
-func NewPrinterHandler(ps *services.PrinterService) *PrinterHandler {
-	return &PrinterHandler{PrinterService: ps}
+// writePrinterError maps service errors to HTTP status codes
+func writePrinterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrPrinterNotFound), errors.Is(err, repositories.ErrPrintJobNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidPrintRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
-type PrintRequest struct {
-	Line1  string `json:"line1"`
-	Line2  string `json:"line2"`
-	Copies int    `json:"copies"`
+// writePrintFailure reports a print that could not be queued in the {success, message}
+// shape the print screens read
+func writePrintFailure(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, repositories.ErrPrinterNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidPrintRequest):
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+// writePrintResult reports whether a job printed at once or was left queued for retry
+func writePrintResult(w http.ResponseWriter, job *models.PrintJob, printedMessage string) {
+	message := printedMessage
+	if job.Status != models.PrintJobPrinted {
+		message = fmt.Sprintf("Printer not reachable, job %d queued for retry: %s", job.ID, job.LastError)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": job.Status == models.PrintJobPrinted,
+		"message": message,
+		"job":     job,
+	})
 }
 
-type ReceiptPrintRequest struct {
-	HTML string `json:"html"`
+// PrintLabel prints thock labels at the counter's label printer
+// POST /api/print
+func (h *PrinterHandler) PrintLabel(w http.ResponseWriter, r *http.Request) {
+	var req models.PrintLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	job, err := h.PrinterService.PrintLabel(r.Context(), &req, userID)
+	if err != nil {
+		writePrintFailure(w, err)
+		return
+	}
+	writePrintResult(w, job, "Printed successfully")
 }
 
-func (h *PrinterHandler) Print2Up(w http.ResponseWriter, r *http.Request) {
-	var req PrintRequest
+// PrintReceipt prints a receipt at the counter's receipt printer
+// POST /api/print-receipt
+func (h *PrinterHandler) PrintReceipt(w http.ResponseWriter, r *http.Request) {
+	var req models.PrintReceiptRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
 
-	if req.Copies < 1 {
-		req.Copies = 1
+	job, err := h.PrinterService.PrintReceipt(r.Context(), &req, userID)
+	if err != nil {
+		writePrintFailure(w, err)
+		return
 	}
+	writePrintResult(w, job, "Receipt printed")
+}
 
-	err := h.PrinterService.Print2Up(req.Line1, req.Line2, req.Copies)
+// ListPrinters returns all printers
+// GET /api/printers
+func (h *PrinterHandler) ListPrinters(w http.ResponseWriter, r *http.Request) {
+	printers, err := h.PrinterService.ListPrinters(r.Context())
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+		writePrinterError(w, err)
 		return
 	}
+	if printers == nil {
+		printers = []*models.Printer{}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Printed successfully",
-	})
+	json.NewEncoder(w).Encode(printers)
 }
 
-func (h *PrinterHandler) PrintReceipt(w http.ResponseWriter, r *http.Request) {
-	var req ReceiptPrintRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+// CreatePrinter adds a printer
+// POST /api/printers
+func (h *PrinterHandler) CreatePrinter(w http.ResponseWriter, r *http.Request) {
+	printer := models.Printer{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&printer); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.HTML == "" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "No HTML content provided",
-		})
+	if err := h.PrinterService.CreatePrinter(r.Context(), &printer); err != nil {
+		writePrinterError(w, err)
 		return
 	}
+	h.logPrinterAction(r, "PRINTER_CREATE", &printer, fmt.Sprintf("Added %s printer %s (%s %s) for counter %q",
+		printer.Kind, printer.Name, printer.Protocol, printer.Address, printer.Counter))
 
-	err := h.PrinterService.PrintReceipt(req.HTML)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(printer)
+}
+
+// UpdatePrinter saves a printer's settings
+// PUT /api/printers/{id}
+func (h *PrinterHandler) UpdatePrinter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+		http.Error(w, "Invalid printer ID", http.StatusBadRequest)
+		return
+	}
+	printer := models.Printer{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&printer); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	printer.ID = id
+
+	if err := h.PrinterService.UpdatePrinter(r.Context(), &printer); err != nil {
+		writePrinterError(w, err)
 		return
 	}
+	h.logPrinterAction(r, "PRINTER_UPDATE", &printer, fmt.Sprintf("Updated %s printer %s (%s %s) for counter %q, active: %t",
+		printer.Kind, printer.Name, printer.Protocol, printer.Address, printer.Counter, printer.IsActive))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Receipt printed to HP printer",
+	json.NewEncoder(w).Encode(printer)
+}
+
+// DeletePrinter removes a printer
+// DELETE /api/printers/{id}
+func (h *PrinterHandler) DeletePrinter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid printer ID", http.StatusBadRequest)
+		return
+	}
+	printer, err := h.PrinterService.Repo.GetPrinter(r.Context(), id)
+	if err != nil {
+		writePrinterError(w, err)
+		return
+	}
+	if err := h.PrinterService.DeletePrinter(r.Context(), id); err != nil {
+		writePrinterError(w, err)
+		return
+	}
+	h.logPrinterAction(r, "PRINTER_DELETE", printer, fmt.Sprintf("Removed %s printer %s", printer.Kind, printer.Name))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestPrinter prints a test label or receipt
+// POST /api/printers/{id}/test
+func (h *PrinterHandler) TestPrinter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid printer ID", http.StatusBadRequest)
+		return
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	job, err := h.PrinterService.TestPrinter(r.Context(), id, userID)
+	if err != nil {
+		writePrinterError(w, err)
+		return
+	}
+	writePrintResult(w, job, "Test page printed")
+}
+
+// ListJobs returns recent print jobs
+// GET /api/print-jobs?status=queued|printing|printed|failed|cancelled
+func (h *PrinterHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.PrinterService.ListJobs(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		writePrinterError(w, err)
+		return
+	}
+	if jobs == nil {
+		jobs = []*models.PrintJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// RetryJob queues a failed or cancelled job again
+// POST /api/print-jobs/{id}/retry
+func (h *PrinterHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	job, err := h.PrinterService.RetryJob(r.Context(), id)
+	if err != nil {
+		writePrinterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJob stops a queued or failed job
+// POST /api/print-jobs/{id}/cancel
+func (h *PrinterHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	job, err := h.PrinterService.CancelJob(r.Context(), id)
+	if err != nil {
+		writePrinterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetFakeOutput returns what the fake printer received
+// GET /api/printers/fake/jobs
+func (h *PrinterHandler) GetFakeOutput(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.PrinterService.Fake.Jobs())
+}
+
+// ControlFake clears the fake printer or makes its next sends fail
+// POST /api/printers/fake
+func (h *PrinterHandler) ControlFake(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reset    bool `json:"reset"`
+		FailNext int  `json:"fail_next"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reset {
+		h.PrinterService.Fake.Reset()
+	}
+	if req.FailNext > 0 {
+		h.PrinterService.Fake.FailNext(req.FailNext)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"jobs": len(h.PrinterService.Fake.Jobs())})
+}
+
+// logPrinterAction records a change to the printer setup
+func (h *PrinterHandler) logPrinterAction(r *http.Request, action string, printer *models.Printer, description string) {
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  action,
+		TargetType:  "printer",
+		TargetID:    &printer.ID,
+		Description: description,
+		IPAddress:   &ipAddress,
 	})
 }
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
+
+	"github.com/gorilla/mux"
+)
+
+// QualityInspectionHandler handles quality inspections, deterioration alerts and the stock aging report
+type QualityInspectionHandler struct {
+	Service         *services.QualityInspectionService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewQualityInspectionHandler(s *services.QualityInspectionService, adminActionRepo *repositories.AdminActionLogRepository) *QualityInspectionHandler {
+	return &QualityInspectionHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// CreateInspection records an inspection of a thock or one of its gatars
+// POST /api/quality-inspections
+func (h *QualityInspectionHandler) CreateInspection(w http.ResponseWriter, r *http.Request) {
+	var req models.QualityInspectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	inspection, err := h.Service.Record(r.Context(), &req, userID)
+	if errors.Is(err, services.ErrInvalidInspection) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(inspection)
+}
+
+// ListInspections returns recent inspections
+// GET /api/quality-inspections?thock=&condition=
+func (h *QualityInspectionHandler) ListInspections(w http.ResponseWriter, r *http.Request) {
+	inspections, err := h.Service.List(r.Context(), r.URL.Query().Get("thock"), r.URL.Query().Get("condition"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if inspections == nil {
+		inspections = []*models.QualityInspection{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inspections)
+}
+
+// GetInspection returns one inspection with its photos
+// GET /api/quality-inspections/{id}
+func (h *QualityInspectionHandler) GetInspection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid inspection ID", http.StatusBadRequest)
+		return
+	}
+
+	inspection, err := h.Service.Get(r.Context(), id)
+	if errors.Is(err, repositories.ErrInspectionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inspection)
+}
+
+// ListAlerts returns thocks in store whose latest inspection found them deteriorating
+// GET /api/quality-inspections/alerts
+func (h *QualityInspectionHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := h.Service.ListAlerts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if alerts == nil {
+		alerts = []*models.QualityAlert{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// NotifyOwner records that the owner of a deteriorating thock was told to withdraw early,
+// sending an SMS when send_sms is set
+// POST /api/quality-inspections/{id}/notify-owner
+func (h *QualityInspectionHandler) NotifyOwner(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid inspection ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		SendSMS bool `json:"send_sms"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	inspection, err := h.Service.NotifyOwner(r.Context(), id, userID, req.SendSMS)
+	switch {
+	case errors.Is(err, repositories.ErrInspectionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidInspection):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ipAddress := getIPAddress(r)
+	description := fmt.Sprintf("Told owner of thock %s to withdraw early (inspection %d: rot %.1f%%, sprouting %.1f%%)",
+		inspection.ThockNumber, inspection.ID, inspection.RotPct, inspection.SproutingPct)
+	if req.SendSMS {
+		description += " by SMS"
+	}
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "QUALITY_ALERT_NOTIFY",
+		TargetType:  "quality_inspection",
+		TargetID:    &inspection.ID,
+		Description: description,
+		IPAddress:   &ipAddress,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inspection)
+}
+
+// GetAgingReport returns the stock in store grouped by days in storage
+// GET /api/quality-inspections/aging?min_days=90&condition=deteriorating|uninspected&format=csv
+func (h *QualityInspectionHandler) GetAgingReport(w http.ResponseWriter, r *http.Request) {
+	minDays := 0
+	if v := r.URL.Query().Get("min_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid min_days", http.StatusBadRequest)
+			return
+		}
+		minDays = n
+	}
+
+	report, err := h.Service.AgingReport(r.Context(), minDays, r.URL.Query().Get("condition"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") != "csv" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	filename := fmt.Sprintf("stock_aging_%s.csv", timeutil.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Thock", "Customer", "Variety", "Room/Floor", "Bags", "Stored Since", "Days in Storage",
+		"Last Inspected", "Condition", "Rot %", "Sprouting %"})
+	for _, row := range report.Rows {
+		lastInspected, rot, sprouting := "", "", ""
+		if row.LastInspectedOn != nil {
+			lastInspected = row.LastInspectedOn.Format("2006-01-02")
+		}
+		if row.LastRotPct != nil {
+			rot = strconv.FormatFloat(*row.LastRotPct, 'f', 1, 64)
+		}
+		if row.LastSproutingPct != nil {
+			sprouting = strconv.FormatFloat(*row.LastSproutingPct, 'f', 1, 64)
+		}
+		cw.Write([]string{row.ThockNumber, row.CustomerName, row.Variety, row.Locations, strconv.Itoa(row.Bags),
+			timeutil.FormatIST(row.StoredSince, "2006-01-02"), strconv.Itoa(row.DaysInStorage),
+			lastInspected, row.LastCondition, rot, sprouting})
+	}
+	cw.Flush()
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -26,6 +27,7 @@ type RentPaymentHandler struct {
 	CustomerService     *services.CustomerService
 	AdminActionRepo     *repositories.AdminActionLogRepository
 	EntryRepo           *repositories.EntryRepository
+	AllocationService   *services.PaymentAllocationService
 }
 
 func NewRentPaymentHandler(service *services.RentPaymentService, ledgerService *services.LedgerService, adminActionRepo *repositories.AdminActionLogRepository) *RentPaymentHandler {
@@ -51,6 +53,11 @@ func (h *RentPaymentHandler) SetEntryRepo(entryRepo *repositories.EntryRepositor
 	h.EntryRepo = entryRepo
 }
 
+// SetAllocationService sets the service that splits payments across thocks
+func (h *RentPaymentHandler) SetAllocationService(allocationService *services.PaymentAllocationService) {
+	h.AllocationService = allocationService
+}
+
 func (h *RentPaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -135,7 +142,26 @@ func (h *RentPaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Reques
 		Notes:             req.Notes,
 	}
 
-	if err := h.Service.CreatePayment(ctx, payment); err != nil {
+	// Split the payment across thocks before recording it so a bad split is rejected,
+	// then record the payment and its split together
+	var allocations []models.PaymentAllocation
+	if h.AllocationService != nil && payment.AmountPaid > 0 && req.CustomerPhone != "" {
+		var err error
+		allocations, err = h.AllocationService.Allocate(ctx, req.CustomerPhone, payment.AmountPaid, req.FamilyMemberID, req.EntryID, req.Allocations)
+		if errors.Is(err, services.ErrInvalidAllocation) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to allocate payment: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if payment.EntryID == 0 && len(allocations) > 0 {
+			payment.EntryID = allocations[0].EntryID
+		}
+	}
+
+	if err := h.Service.CreatePaymentWithAllocations(ctx, payment, allocations); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -306,3 +332,117 @@ func (h *RentPaymentHandler) GetPaymentByReceiptNumber(w http.ResponseWriter, r
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(payment)
 }
+
+// GetOutstanding returns the customer's outstanding rent per thock
+// GET /api/rent-payments/outstanding?phone={phone}
+func (h *RentPaymentHandler) GetOutstanding(w http.ResponseWriter, r *http.Request) {
+	phone := r.URL.Query().Get("phone")
+	if phone == "" {
+		http.Error(w, "Phone parameter required", http.StatusBadRequest)
+		return
+	}
+	if h.AllocationService == nil {
+		http.Error(w, "Payment allocation is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	outstanding, err := h.AllocationService.GetOutstanding(r.Context(), phone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outstanding)
+}
+
+// GetAllocations returns how a payment was split across thocks
+// GET /api/rent-payments/{id}/allocations
+func (h *RentPaymentHandler) GetAllocations(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	if h.AllocationService == nil {
+		http.Error(w, "Payment allocation is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	allocations, err := h.AllocationService.GetAllocations(r.Context(), paymentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(allocations)
+}
+
+// UpdateAllocations replaces the split of a payment with a manual one
+// PUT /api/rent-payments/{id}/allocations
+func (h *RentPaymentHandler) UpdateAllocations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	paymentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	if h.AllocationService == nil {
+		http.Error(w, "Payment allocation is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UpdatePaymentAllocationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.Service.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+
+	before, err := h.AllocationService.GetAllocations(ctx, paymentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	allocations, err := h.AllocationService.Reallocate(ctx, payment, req.Allocations, userID)
+	if errors.Is(err, services.ErrInvalidAllocation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update allocations: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	oldValue, _ := json.Marshal(before)
+	newValue, _ := json.Marshal(allocations)
+	oldValueStr, newValueStr := string(oldValue), string(newValue)
+	h.AdminActionRepo.CreateActionLog(ctx, &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "PAYMENT_REALLOCATE",
+		TargetType:  "rent_payment",
+		TargetID:    &payment.ID,
+		Description: fmt.Sprintf("Re-allocated receipt %s (₹%.2f) across %d thock(s)", payment.ReceiptNumber, payment.AmountPaid, len(allocations)),
+		OldValue:    &oldValueStr,
+		NewValue:    &newValueStr,
+	})
+
+	cache.InvalidatePaymentCaches(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(allocations)
+}
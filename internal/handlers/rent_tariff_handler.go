@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
+
+	"github.com/gorilla/mux"
+)
+
+// RentTariffHandler handles rent rate card endpoints
+type RentTariffHandler struct {
+	Service         *services.TariffService
+	EntryRepo       *repositories.EntryRepository
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewRentTariffHandler(s *services.TariffService, entryRepo *repositories.EntryRepository, adminActionRepo *repositories.AdminActionLogRepository) *RentTariffHandler {
+	return &RentTariffHandler{
+		Service:         s,
+		EntryRepo:       entryRepo,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// ListTariffs returns all rate cards
+// GET /api/rent-tariffs?active=true
+func (h *RentTariffHandler) ListTariffs(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("active") == "true"
+
+	tariffs, err := h.Service.ListTariffs(r.Context(), activeOnly)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tariffs == nil {
+		tariffs = []*models.RentTariff{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tariffs)
+}
+
+// GetTariff returns a single rate card
+// GET /api/rent-tariffs/{id}
+func (h *RentTariffHandler) GetTariff(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tariff ID", http.StatusBadRequest)
+		return
+	}
+
+	tariff, err := h.Service.GetTariff(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Tariff not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tariff)
+}
+
+// CreateTariff publishes a new rate card version (admin only)
+// POST /api/rent-tariffs
+func (h *RentTariffHandler) CreateTariff(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateRentTariffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tariff, err := h.Service.CreateTariff(r.Context(), &req, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Rent figures in the account summary depend on the rate cards
+	cache.InvalidateTariffCaches(r.Context())
+
+	h.logAction(r, userID, "CREATE", tariff.ID,
+		fmt.Sprintf("Published rent tariff '%s' effective from %s with %d rates", tariff.Name, tariff.EffectiveFrom.Format("2006-01-02"), len(tariff.Rates)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tariff)
+}
+
+// ActivateTariff puts a retired rate card back in force (admin only)
+// PUT /api/rent-tariffs/{id}/activate
+func (h *RentTariffHandler) ActivateTariff(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, true)
+}
+
+// DeactivateTariff retires a rate card (admin only)
+// PUT /api/rent-tariffs/{id}/deactivate
+func (h *RentTariffHandler) DeactivateTariff(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, false)
+}
+
+func (h *RentTariffHandler) setActive(w http.ResponseWriter, r *http.Request, active bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tariff ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.SetTariffActive(r.Context(), id, active); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	cache.InvalidateTariffCaches(r.Context())
+
+	action := "DEACTIVATE"
+	if active {
+		action = "ACTIVATE"
+	}
+	h.logAction(r, userID, action, id, fmt.Sprintf("Set rent tariff #%d active=%t", id, active))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        id,
+		"is_active": active,
+	})
+}
+
+// QuoteRent prices a thock against the rate cards
+// GET /api/rent-tariffs/quote?thock_number=...&quantity=...&as_of=YYYY-MM-DD
+func (h *RentTariffHandler) QuoteRent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	var entry *models.Entry
+	var err error
+	if idStr := query.Get("entry_id"); idStr != "" {
+		id, convErr := strconv.Atoi(idStr)
+		if convErr != nil {
+			http.Error(w, "Invalid entry_id", http.StatusBadRequest)
+			return
+		}
+		entry, err = h.EntryRepo.Get(ctx, id)
+	} else if thock := query.Get("thock_number"); thock != "" {
+		entry, err = h.EntryRepo.GetByThockNumber(ctx, thock)
+	} else {
+		http.Error(w, "entry_id or thock_number is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	quantity := entry.ActualQuantity
+	if qtyStr := query.Get("quantity"); qtyStr != "" {
+		if quantity, err = strconv.Atoi(qtyStr); err != nil || quantity < 0 {
+			http.Error(w, "Invalid quantity", http.StatusBadRequest)
+			return
+		}
+	}
+
+	asOf := timeutil.Now()
+	if asOfStr := query.Get("as_of"); asOfStr != "" {
+		if asOf, err = timeutil.ParseInIST("2006-01-02", asOfStr); err != nil {
+			http.Error(w, "Invalid as_of date. Use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	quote, err := h.Service.QuoteEntry(ctx, entry, quantity, asOf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
+
+// logAction records a tariff change in the admin action log
+func (h *RentTariffHandler) logAction(r *http.Request, userID int, actionType string, tariffID int, description string) {
+	if h.AdminActionRepo == nil {
+		return
+	}
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(context.Background(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  actionType,
+		TargetType:  "rent_tariff",
+		TargetID:    &tariffID,
+		Description: description,
+		IPAddress:   &ipAddress,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"cold-backend/internal/cache"
+	"cold-backend/internal/models"
 	"cold-backend/internal/services"
 	"cold-backend/internal/timeutil"
 
@@ -21,13 +22,19 @@ const (
 )
 
 type ReportHandler struct {
-	Service *services.ReportService
+	Service       *services.ReportService
+	ExportService *services.AccountingExportService
 }
 
 func NewReportHandler(service *services.ReportService) *ReportHandler {
 	return &ReportHandler{Service: service}
 }
 
+// SetAccountingExportService enables the Tally / journal exports
+func (h *ReportHandler) SetAccountingExportService(service *services.AccountingExportService) {
+	h.ExportService = service
+}
+
 // GetCustomersCSV handles GET /api/reports/customers/csv
 // Query params: filter=all|outstanding|paid
 func (h *ReportHandler) GetCustomersCSV(w http.ResponseWriter, r *http.Request) {
@@ -102,8 +109,13 @@ func (h *ReportHandler) GetSingleCustomerPDF(w http.ResponseWriter, r *http.Requ
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	rentRate, _ := h.Service.GetRentRate(ctx)
-	data, err := h.Service.GetCustomerReportData(ctx, phone, rentRate)
+	rateCard, err := h.Service.GetRateCard(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load rent tariffs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.Service.GetCustomerReportData(ctx, phone, rateCard)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Customer not found: %v", err), http.StatusNotFound)
 		return
@@ -248,3 +260,78 @@ func (h *ReportHandler) GetReportStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Cache", "MISS")
 	w.Write(data)
 }
+
+// accountingVouchers loads the vouchers for the from/to query params (default: today)
+func (h *ReportHandler) accountingVouchers(w http.ResponseWriter, r *http.Request) ([]models.AccountingVoucher, *models.AccountingLedgerNames, string, bool) {
+	if h.ExportService == nil {
+		http.Error(w, "Accounting export not available", http.StatusServiceUnavailable)
+		return nil, nil, "", false
+	}
+
+	today := timeutil.StartOfDay(timeutil.Now())
+	from, err := parseDateParam(r, "from", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, "", false
+	}
+	to, err := parseDateParam(r, "to", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, "", false
+	}
+	if to.Before(from) {
+		http.Error(w, "to date must not be before from date", http.StatusBadRequest)
+		return nil, nil, "", false
+	}
+
+	ctx := r.Context()
+	names := h.ExportService.GetLedgerNames(ctx)
+	vouchers, err := h.ExportService.GetVouchers(ctx, from, to, names)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load vouchers: %v", err), http.StatusInternalServerError)
+		return nil, nil, "", false
+	}
+
+	period := fmt.Sprintf("%s_%s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return vouchers, names, period, true
+}
+
+// GetTallyXML handles GET /api/reports/tally/xml
+// Query params: from, to=YYYY-MM-DD (default today), masters=true to include party ledgers
+func (h *ReportHandler) GetTallyXML(w http.ResponseWriter, r *http.Request) {
+	vouchers, names, period, ok := h.accountingVouchers(w, r)
+	if !ok {
+		return
+	}
+
+	xmlData, err := h.ExportService.GenerateTallyXML(vouchers, names, r.URL.Query().Get("masters") == "true")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate XML: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("tally_%s.xml", period)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Write(xmlData)
+}
+
+// GetJournalCSV handles GET /api/reports/journal/csv
+// Query params: from, to=YYYY-MM-DD (default today)
+func (h *ReportHandler) GetJournalCSV(w http.ResponseWriter, r *http.Request) {
+	vouchers, _, period, ok := h.accountingVouchers(w, r)
+	if !ok {
+		return
+	}
+
+	csvData, err := h.ExportService.GenerateJournalCSV(vouchers)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate CSV: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("journal_%s.csv", period)
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Write(csvData)
+}
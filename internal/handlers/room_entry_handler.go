@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -48,6 +49,14 @@ func (h *RoomEntryHandler) CreateRoomEntry(w http.ResponseWriter, r *http.Reques
 	}
 
 	roomEntry, err := h.Service.CreateRoomEntry(context.Background(), &req, userID)
+	if errors.Is(err, services.ErrInvalidLayout) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrGatarOverfill) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -126,6 +135,14 @@ func (h *RoomEntryHandler) UpdateRoomEntry(w http.ResponseWriter, r *http.Reques
 
 	// Perform update
 	roomEntry, err := h.Service.UpdateRoomEntry(context.Background(), id, &req)
+	if errors.Is(err, services.ErrInvalidLayout) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrGatarOverfill) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/monitoring"
+	"cold-backend/internal/services"
+)
+
+// RoomSensorHandler handles cold-room temperature/humidity readings
+type RoomSensorHandler struct {
+	Service *services.RoomSensorService
+}
+
+func NewRoomSensorHandler(s *services.RoomSensorService) *RoomSensorHandler {
+	return &RoomSensorHandler{Service: s}
+}
+
+// IngestReadings stores readings posted by sensors; authenticated by the X-Sensor-Token header.
+// The body is a reading, an array of readings or {"readings": [...]}.
+// POST /api/sensors/readings
+func (h *RoomSensorHandler) IngestReadings(w http.ResponseWriter, r *http.Request) {
+	if !h.Service.ValidIngestToken(r.Context(), r.Header.Get("X-Sensor-Token")) {
+		http.Error(w, "Invalid sensor token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var readings []monitoring.SensorReading
+	switch trimmed := bytes.TrimSpace(body); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err = json.Unmarshal(trimmed, &readings)
+	default:
+		var req struct {
+			Readings []monitoring.SensorReading `json:"readings"`
+			monitoring.SensorReading
+		}
+		err = json.Unmarshal(trimmed, &req)
+		readings = req.Readings
+		if readings == nil {
+			readings = []monitoring.SensorReading{req.SensorReading}
+		}
+	}
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := h.Service.Ingest(r.Context(), readings)
+	switch {
+	case errors.Is(err, services.ErrInvalidSensorReading):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, monitoring.ErrSensorStoreDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"stored": stored})
+}
+
+// GetLatest returns the latest reading and alert state of every sensor
+// GET /api/sensors/latest
+func (h *RoomSensorHandler) GetLatest(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.Service.Latest(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":    h.Service.Store.SensorsEnabled(),
+		"sensors":    statuses,
+		"thresholds": h.Service.Thresholds(r.Context()),
+	})
+}
+
+// GetTrend returns bucketed readings of a room for the room visualization charts
+// GET /api/sensors/trend?room=1&floor=0&hours=24
+func (h *RoomSensorHandler) GetTrend(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid hours", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	points, err := h.Service.Trend(r.Context(), r.URL.Query().Get("room"), r.URL.Query().Get("floor"), hours)
+	if errors.Is(err, services.ErrInvalidSensorReading) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(points)
+}
@@ -2,27 +2,32 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"cold-backend/internal/cache"
+	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // RoomVisualizationHandler handles room visualization endpoints
 type RoomVisualizationHandler struct {
-	DB              *pgxpool.Pool
-	GatarRepository *repositories.RoomEntryGatarRepository
+	DB        *pgxpool.Pool
+	StockRepo *repositories.GatarStockRepository
+	Layout    *services.WarehouseLayoutService
 }
 
 // NewRoomVisualizationHandler creates a new room visualization handler
 func NewRoomVisualizationHandler(db *pgxpool.Pool) *RoomVisualizationHandler {
+	stockRepo := repositories.NewGatarStockRepository(db)
 	return &RoomVisualizationHandler{
-		DB:              db,
-		GatarRepository: repositories.NewRoomEntryGatarRepository(db),
+		DB:        db,
+		StockRepo: stockRepo,
+		Layout:    services.NewWarehouseLayoutService(repositories.NewWarehouseLayoutRepository(db), stockRepo),
 	}
 }
 
@@ -33,11 +38,13 @@ type FloorStats struct {
 	TotalGatars    int    `json:"total_gatars"`
 	TotalQuantity  int    `json:"total_qty"`
 	EntryCount     int    `json:"entry_count"`
+	CapacityBags   int    `json:"capacity_bags"`
 }
 
 // RoomStats contains statistics for a single room
 type RoomStats struct {
 	RoomNo string       `json:"room_no"`
+	Name   string       `json:"name"`
 	Floors []FloorStats `json:"floors"`
 }
 
@@ -47,6 +54,7 @@ type VisualizationSummary struct {
 	OccupiedGatars  int `json:"occupied_gatars"`
 	TotalGatars     int `json:"total_gatars"`
 	TotalEntryCount int `json:"total_entry_count"`
+	CapacityBags    int `json:"capacity_bags"`
 }
 
 // RoomVisualizationResponse is the response for GetRoomStats
@@ -65,10 +73,12 @@ type GatarItem struct {
 
 // GatarInfo represents a single gatar's data
 type GatarInfo struct {
-	Gatar    string      `json:"gatar"`
-	Occupied bool        `json:"occupied"`
-	Items    []GatarItem `json:"items"`
-	TotalQty int         `json:"total_qty"`
+	Gatar        string      `json:"gatar"`
+	Occupied     bool        `json:"occupied"`
+	Items        []GatarItem `json:"items"`
+	TotalQty     int         `json:"total_qty"`
+	CapacityBags int         `json:"capacity_bags"`
+	Disabled     bool        `json:"disabled"`
 }
 
 // GatarOccupancyResponse is the response for GetGatarOccupancy
@@ -78,45 +88,6 @@ type GatarOccupancyResponse struct {
 	Gatars []GatarInfo `json:"gatars"`
 }
 
-// Gatar ranges for each room/floor (from room-config-1.html)
-var gatarRanges = map[string]map[string]struct{ Start, End, Total int }{
-	"1": {
-		"0": {1, 140, 140},
-		"1": {141, 280, 140},
-		"2": {281, 420, 140},
-		"3": {421, 560, 140},
-		"4": {561, 680, 120},
-	},
-	"2": {
-		"0": {681, 820, 140},
-		"1": {821, 960, 140},
-		"2": {961, 1100, 140},
-		"3": {1101, 1240, 140},
-		"4": {1241, 1360, 120},
-	},
-	"3": {
-		"0": {1361, 1500, 140},
-		"1": {1501, 1640, 140},
-		"2": {1641, 1780, 140},
-		"3": {1781, 1920, 140},
-		"4": {1921, 2040, 120},
-	},
-	"4": {
-		"0": {2041, 2120, 140}, // Room 4 Floor 0: 80 + 60 split range = 140 total
-		"1": {2121, 2260, 140},
-		"2": {2261, 2400, 140},
-		"3": {2401, 2540, 140},
-		"4": {2601, 2720, 120},
-	},
-	"G": {
-		"0": {2727, 2756, 30},
-		"1": {2757, 2784, 28},
-		"2": {2785, 2812, 28},
-		"3": {2813, 2840, 28},
-		"4": {2841, 2868, 28},
-	},
-}
-
 // GetRoomStats returns aggregated statistics for all rooms and floors
 func (h *RoomVisualizationHandler) GetRoomStats(w http.ResponseWriter, r *http.Request) {
 	// Prevent browser caching
@@ -134,15 +105,30 @@ func (h *RoomVisualizationHandler) GetRoomStats(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Query to get stats grouped by room and floor
+	layout, err := h.Layout.ListRooms(ctx, true)
+	if err != nil {
+		http.Error(w, "Failed to load warehouse layout: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Query to get current stock grouped by room and floor from the gatar stock ledger
 	query := `
+		WITH balances AS (
+			SELECT m.room_no, m.floor, m.gatar_no, re.entry_id, SUM(m.quantity) as quantity
+			FROM gatar_stock_movements m
+			JOIN room_entries re ON re.id = m.room_entry_id
+			LEFT JOIN entries e ON e.id = re.entry_id
+			WHERE COALESCE(e.status, 'active') != 'deleted'
+			GROUP BY m.room_no, m.floor, m.gatar_no, m.room_entry_id, re.entry_id
+			HAVING SUM(m.quantity) > 0
+		)
 		SELECT
 			room_no,
 			floor,
-			COUNT(DISTINCT gate_no) as occupied_gatars,
+			COUNT(DISTINCT gatar_no) as occupied_gatars,
 			SUM(quantity) as total_qty,
 			COUNT(DISTINCT entry_id) as entry_count
-		FROM room_entries
+		FROM balances
 		GROUP BY room_no, floor
 		ORDER BY room_no, floor
 	`
@@ -170,18 +156,9 @@ func (h *RoomVisualizationHandler) GetRoomStats(w http.ResponseWriter, r *http.R
 			statsMap[roomNo] = make(map[string]FloorStats)
 		}
 
-		// Get total gatars for this room/floor
-		totalGatars := 0
-		if ranges, ok := gatarRanges[roomNo]; ok {
-			if floorRange, ok := ranges[floor]; ok {
-				totalGatars = floorRange.Total
-			}
-		}
-
 		statsMap[roomNo][floor] = FloorStats{
 			Floor:          floor,
 			OccupiedGatars: occupiedGatars,
-			TotalGatars:    totalGatars,
 			TotalQuantity:  totalQty,
 			EntryCount:     entryCount,
 		}
@@ -189,45 +166,32 @@ func (h *RoomVisualizationHandler) GetRoomStats(w http.ResponseWriter, r *http.R
 
 	// Build response
 	var rooms []RoomStats
-	var totalQty, totalOccupied, totalGatars, totalEntries int
+	var totalQty, totalOccupied, totalGatars, totalEntries, totalCapacity int
 
-	// Process rooms in order (including Room 4 and Gallery)
-	for _, roomNo := range []string{"1", "2", "3", "4", "G"} {
+	// Process rooms and floors in layout order
+	for _, room := range layout {
 		var floors []FloorStats
 
-		// Process floors in order (0-4)
-		for floor := 0; floor <= 4; floor++ {
-			floorStr := strconv.Itoa(floor)
-
-			// Get total gatars for this floor
-			floorTotalGatars := 0
-			if ranges, ok := gatarRanges[roomNo]; ok {
-				if floorRange, ok := ranges[floorStr]; ok {
-					floorTotalGatars = floorRange.Total
-				}
-			}
-
-			if stats, ok := statsMap[roomNo][floorStr]; ok {
-				stats.TotalGatars = floorTotalGatars
-				floors = append(floors, stats)
-				totalQty += stats.TotalQuantity
-				totalOccupied += stats.OccupiedGatars
-				totalEntries += stats.EntryCount
-			} else {
+		for _, f := range room.Floors {
+			stats, ok := statsMap[room.RoomNo][f.Floor]
+			if !ok {
 				// Floor has no entries yet
-				floors = append(floors, FloorStats{
-					Floor:          floorStr,
-					OccupiedGatars: 0,
-					TotalGatars:    floorTotalGatars,
-					TotalQuantity:  0,
-					EntryCount:     0,
-				})
+				stats = FloorStats{Floor: f.Floor}
 			}
-			totalGatars += floorTotalGatars
+			stats.TotalGatars = f.TotalGatars - f.DisabledGatars
+			stats.CapacityBags = f.CapacityBags
+			floors = append(floors, stats)
+
+			totalQty += stats.TotalQuantity
+			totalOccupied += stats.OccupiedGatars
+			totalEntries += stats.EntryCount
+			totalGatars += stats.TotalGatars
+			totalCapacity += stats.CapacityBags
 		}
 
 		rooms = append(rooms, RoomStats{
-			RoomNo: roomNo,
+			RoomNo: room.RoomNo,
+			Name:   room.Name,
 			Floors: floors,
 		})
 	}
@@ -239,6 +203,7 @@ func (h *RoomVisualizationHandler) GetRoomStats(w http.ResponseWriter, r *http.R
 			OccupiedGatars:  totalOccupied,
 			TotalGatars:     totalGatars,
 			TotalEntryCount: totalEntries,
+			CapacityBags:    totalCapacity,
 		},
 	}
 
@@ -268,18 +233,7 @@ func (h *RoomVisualizationHandler) GetGatarOccupancy(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Validate room and floor
-	if _, ok := gatarRanges[roomNo]; !ok {
-		http.Error(w, "Invalid room number", http.StatusBadRequest)
-		return
-	}
-	floorRange, ok := gatarRanges[roomNo][floor]
-	if !ok {
-		http.Error(w, "Invalid floor number", http.StatusBadRequest)
-		return
-	}
-
-	// Check Redis cache first
+	// Check Redis cache first (only valid room/floors are cached)
 	if cached, found := cache.GetCachedFloorData(ctx, roomNo, floor); found {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("X-Cache", "HIT")
@@ -287,20 +241,33 @@ func (h *RoomVisualizationHandler) GetGatarOccupancy(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Query to get items in each gatar (including quantity_breakdown for accurate distribution)
+	// Validate room and floor against the warehouse layout
+	_, layoutGatars, err := h.Layout.GetFloor(ctx, roomNo, floor)
+	if errors.Is(err, repositories.ErrLayoutNotFound) {
+		http.Error(w, "Invalid room or floor: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load warehouse layout: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Query the current bags of each thock in each gatar from the stock ledger
 	query := `
 		SELECT
-			re.gate_no,
-			re.thock_number,
-			re.quantity,
-			COALESCE(e.remark, '') as variety,
-			re.entry_id,
-			COALESCE(re.quantity_breakdown, '') as quantity_breakdown
-		FROM room_entries re
+			m.gatar_no,
+			m.thock_number,
+			SUM(m.quantity) as quantity,
+			COALESCE(MAX(e.remark), '') as variety,
+			re.entry_id
+		FROM gatar_stock_movements m
+		JOIN room_entries re ON re.id = m.room_entry_id
 		LEFT JOIN entries e ON re.entry_id = e.id
-		WHERE re.room_no = $1
-		  AND re.floor = $2
-		ORDER BY re.gate_no, re.created_at DESC
+		WHERE m.room_no = $1
+		  AND m.floor = $2
+		  AND COALESCE(e.status, 'active') != 'deleted'
+		GROUP BY m.gatar_no, m.room_entry_id, m.thock_number, re.entry_id
+		HAVING SUM(m.quantity) > 0
+		ORDER BY m.gatar_no, MAX(m.created_at) DESC
 	`
 
 	rows, err := h.DB.Query(ctx, query, roomNo, floor)
@@ -315,69 +282,33 @@ func (h *RoomVisualizationHandler) GetGatarOccupancy(w http.ResponseWriter, r *h
 	gatarTotals := make(map[string]int)
 
 	for rows.Next() {
-		var gateNo, thockNumber, variety, quantityBreakdown string
-		var quantity, entryID int
+		var gatarNo, quantity, entryID int
+		var item GatarItem
 
-		if err := rows.Scan(&gateNo, &thockNumber, &quantity, &variety, &entryID, &quantityBreakdown); err != nil {
+		if err := rows.Scan(&gatarNo, &item.ThockNumber, &quantity, &item.Variety, &entryID); err != nil {
 			http.Error(w, "Failed to scan row: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		item.Quantity = quantity
+		item.EntryID = entryID
 
-		// Parse gate numbers (comma-separated, e.g., "112, 114, 129, 131")
-		gateNos := strings.Split(gateNo, ",")
-		var cleanGatars []string
-		for _, g := range gateNos {
-			g = strings.TrimSpace(g)
-			if g != "" {
-				cleanGatars = append(cleanGatars, g)
-			}
-		}
-
-		if len(cleanGatars) == 0 {
-			continue
-		}
-
-		// Parse quantity_breakdown (comma-separated, e.g., "25, 24, 24, 24, 16, 21")
-		var breakdownValues []int
-		if quantityBreakdown != "" {
-			parts := strings.Split(quantityBreakdown, ",")
-			for _, p := range parts {
-				p = strings.TrimSpace(p)
-				if val, err := strconv.Atoi(p); err == nil {
-					breakdownValues = append(breakdownValues, val)
-				}
-			}
-		}
-
-		// Calculate per-gatar quantity distribution
-		gatarQuantities := distributeQuantity(quantity, cleanGatars, breakdownValues)
-
-		// Add items to each gatar with their distributed quantity
-		for i, g := range cleanGatars {
-			perGatarQty := gatarQuantities[i]
-
-			item := GatarItem{
-				ThockNumber: thockNumber,
-				Quantity:    perGatarQty,
-				Variety:     variety,
-				EntryID:     entryID,
-			}
-
-			gatarItems[g] = append(gatarItems[g], item)
-			gatarTotals[g] += perGatarQty
-		}
+		g := strconv.Itoa(gatarNo)
+		gatarItems[g] = append(gatarItems[g], item)
+		gatarTotals[g] += quantity
 	}
 
-	// Build response with all gatars in range
+	// Build response with every gatar of the floor
 	var gatars []GatarInfo
-	for g := floorRange.Start; g <= floorRange.End; g++ {
-		gStr := strconv.Itoa(g)
+	for _, g := range layoutGatars {
+		gStr := strconv.Itoa(g.GatarNo)
 		items := gatarItems[gStr]
 		gatars = append(gatars, GatarInfo{
-			Gatar:    gStr,
-			Occupied: len(items) > 0,
-			Items:    items,
-			TotalQty: gatarTotals[gStr],
+			Gatar:        gStr,
+			Occupied:     len(items) > 0,
+			Items:        items,
+			TotalQty:     gatarTotals[gStr],
+			CapacityBags: g.CapacityBags,
+			Disabled:     g.IsDisabled,
 		})
 	}
 
@@ -411,8 +342,13 @@ func (h *RoomVisualizationHandler) GetGatarDetails(w http.ResponseWriter, r *htt
 		return
 	}
 
-	// Query to get all items in this gatar (handle comma-separated gate_no)
-	// Uses array membership check instead of LIKE for better index usage
+	gatarNo, err := strconv.Atoi(gatar)
+	if err != nil {
+		http.Error(w, "Invalid gatar number", http.StatusBadRequest)
+		return
+	}
+
+	// Query the room entries with bags in this gatar and their balance there from the stock ledger
 	query := `
 		SELECT
 			re.id,
@@ -426,15 +362,23 @@ func (h *RoomVisualizationHandler) GetGatarDetails(w http.ResponseWriter, r *htt
 			COALESCE(c.name, '') as customer_name,
 			COALESCE(c.phone, '') as customer_phone,
 			re.created_at,
-			COALESCE(re.quantity_breakdown, '') as quantity_breakdown
-		FROM room_entries re
+			COALESCE(re.quantity_breakdown, '') as quantity_breakdown,
+			b.quantity
+		FROM (
+			SELECT room_entry_id, SUM(quantity) as quantity
+			FROM gatar_stock_movements
+			WHERE gatar_no = $1
+			GROUP BY room_entry_id
+			HAVING SUM(quantity) > 0
+		) b
+		JOIN room_entries re ON re.id = b.room_entry_id
 		LEFT JOIN entries e ON re.entry_id = e.id
 		LEFT JOIN customers c ON e.customer_id = c.id
-		WHERE re.gate_no = $1 OR $1 = ANY(string_to_array(replace(re.gate_no, ' ', ''), ','))
+		WHERE COALESCE(e.status, 'active') != 'deleted'
 		ORDER BY re.created_at DESC
 	`
 
-	rows, err := h.DB.Query(ctx, query, gatar)
+	rows, err := h.DB.Query(ctx, query, gatarNo)
 	if err != nil {
 		http.Error(w, "Failed to query gatar details: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -448,7 +392,7 @@ func (h *RoomVisualizationHandler) GetGatarDetails(w http.ResponseWriter, r *htt
 		Floor             string `json:"floor"`
 		GateNo            string `json:"gate_no"`
 		Quantity          int    `json:"quantity"`
-		DistributedQty    int    `json:"distributed_qty"`
+		DistributedQty    int    `json:"distributed_qty"` // Bags of this entry in the gatar now
 		Remark            string `json:"remark"`
 		Variety           string `json:"variety"`
 		CustomerName      string `json:"customer_name"`
@@ -467,7 +411,7 @@ func (h *RoomVisualizationHandler) GetGatarDetails(w http.ResponseWriter, r *htt
 		if err := rows.Scan(
 			&d.ID, &d.ThockNumber, &d.RoomNo, &d.Floor, &d.GateNo,
 			&d.Quantity, &d.Remark, &d.Variety, &d.CustomerName,
-			&d.CustomerPhone, &createdAt, &d.QuantityBreakdown,
+			&d.CustomerPhone, &createdAt, &d.QuantityBreakdown, &d.DistributedQty,
 		); err != nil {
 			http.Error(w, "Failed to scan row: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -478,41 +422,8 @@ func (h *RoomVisualizationHandler) GetGatarDetails(w http.ResponseWriter, r *htt
 			d.CreatedAt = t.Format("02/01/2006 15:04")
 		}
 
-		// Parse gate numbers to find this gatar's index
-		gateNos := strings.Split(d.GateNo, ",")
-		var cleanGatars []string
-		gatarIndex := -1
-		for _, g := range gateNos {
-			g = strings.TrimSpace(g)
-			if g != "" {
-				cleanGatars = append(cleanGatars, g)
-				if g == gatar {
-					gatarIndex = len(cleanGatars) - 1
-				}
-			}
-		}
-
-		// If this gatar is in the list, calculate its distributed quantity
-		if gatarIndex >= 0 {
-			// Parse quantity_breakdown
-			var breakdownValues []int
-			if d.QuantityBreakdown != "" {
-				parts := strings.Split(d.QuantityBreakdown, ",")
-				for _, p := range parts {
-					p = strings.TrimSpace(p)
-					if val, err := strconv.Atoi(p); err == nil {
-						breakdownValues = append(breakdownValues, val)
-					}
-				}
-			}
-
-			// Calculate distribution for all gatars
-			distribution := distributeQuantity(d.Quantity, cleanGatars, breakdownValues)
-			d.DistributedQty = distribution[gatarIndex]
-			totalDistributedQty += d.DistributedQty
-
-			details = append(details, d)
-		}
+		totalDistributedQty += d.DistributedQty
+		details = append(details, d)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -524,96 +435,7 @@ func (h *RoomVisualizationHandler) GetGatarDetails(w http.ResponseWriter, r *htt
 	})
 }
 
-// Helper function to get room/floor from gatar number
-func getRoomFloorFromGatar(gatarNum int) (string, string) {
-	for roomNo, floors := range gatarRanges {
-		for floor, r := range floors {
-			if gatarNum >= r.Start && gatarNum <= r.End {
-				return roomNo, floor
-			}
-		}
-	}
-	return "", ""
-}
-
-// distributeQuantity distributes total bags across gatars based on breakdown
-// Logic:
-//  1. If only 1 gatar: all bags go to that gatar
-//  2. If breakdown count matches gatar count: map 1:1
-//  3. If breakdown has more items than gatars: distribute breakdown items sequentially
-//     (each gatar gets bags until ~200 capacity, then overflow to next)
-//  4. Fallback: divide total evenly across gatars
-func distributeQuantity(totalQty int, gatars []string, breakdown []int) []int {
-	numGatars := len(gatars)
-	result := make([]int, numGatars)
-
-	if numGatars == 0 {
-		return result
-	}
-
-	// Case 1: Single gatar - all bags go to it
-	if numGatars == 1 {
-		result[0] = totalQty
-		return result
-	}
-
-	// Case 2: Breakdown count matches gatar count - direct 1:1 mapping
-	if len(breakdown) == numGatars {
-		copy(result, breakdown)
-		return result
-	}
-
-	// Case 3: More breakdown items than gatars - distribute sequentially
-	// Each gatar has ~200 bag capacity, fill in order
-	if len(breakdown) > numGatars {
-		const gatarCapacity = 200
-		currentGatar := 0
-		currentGatarBags := 0
-
-		for _, bags := range breakdown {
-			// If current gatar would overflow, try to fit what we can
-			remainingCapacity := gatarCapacity - currentGatarBags
-
-			if bags <= remainingCapacity || currentGatar == numGatars-1 {
-				// Fits in current gatar OR this is the last gatar (must take overflow)
-				result[currentGatar] += bags
-				currentGatarBags += bags
-			} else {
-				// Split between current and next gatar
-				result[currentGatar] += remainingCapacity
-				currentGatar++
-				if currentGatar < numGatars {
-					result[currentGatar] += bags - remainingCapacity
-					currentGatarBags = bags - remainingCapacity
-				}
-			}
-
-			// Move to next gatar if current is at capacity
-			if currentGatarBags >= gatarCapacity && currentGatar < numGatars-1 {
-				currentGatar++
-				currentGatarBags = 0
-			}
-		}
-		return result
-	}
-
-	// Case 4: Fewer breakdown items than gatars OR no breakdown - divide evenly
-	baseQty := totalQty / numGatars
-	remainder := totalQty % numGatars
-
-	for i := 0; i < numGatars; i++ {
-		result[i] = baseQty
-		// Distribute remainder across first few gatars
-		if i < remainder {
-			result[i]++
-		}
-	}
-
-	return result
-}
-
-// GetPerGatarStock returns per-gatar stock data from the room_entry_gatars table
-// This endpoint uses the new per-gatar quantity tracking system
+// GetPerGatarStock returns the current per-gatar stock of a floor from the gatar stock ledger
 func (h *RoomVisualizationHandler) GetPerGatarStock(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
@@ -629,8 +451,7 @@ func (h *RoomVisualizationHandler) GetPerGatarStock(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Get per-gatar stock from the new table
-	stocks, err := h.GatarRepository.GetStockByRoomFloor(ctx, roomNo, floor)
+	stocks, err := h.StockRepo.GetStockByRoomFloor(ctx, roomNo, floor)
 	if err != nil {
 		http.Error(w, "Failed to query gatar stock: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -665,7 +486,7 @@ func (h *RoomVisualizationHandler) GetPerGatarStock(w http.ResponseWriter, r *ht
 	})
 }
 
-// SearchByGatar searches for entries by gatar location using the new per-gatar table
+// SearchByGatar returns the thocks stored in a gatar with their current bags there
 func (h *RoomVisualizationHandler) SearchByGatar(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
@@ -688,8 +509,7 @@ func (h *RoomVisualizationHandler) SearchByGatar(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Search by gatar using the new table
-	results, err := h.GatarRepository.SearchByGatar(ctx, roomNo, floor, gatarNo)
+	results, err := h.StockRepo.SearchByGatar(ctx, roomNo, floor, gatarNo)
 	if err != nil {
 		http.Error(w, "Failed to search gatar: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -704,3 +524,40 @@ func (h *RoomVisualizationHandler) SearchByGatar(w http.ResponseWriter, r *http.
 		"count":    len(results),
 	})
 }
+
+// GetGatarMovements returns the stock ledger of a thock and/or gatar, newest first
+func (h *RoomVisualizationHandler) GetGatarMovements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	thockNumber := r.URL.Query().Get("thock")
+	gatarNo := 0
+	if s := r.URL.Query().Get("gatar"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "Invalid gatar number", http.StatusBadRequest)
+			return
+		}
+		gatarNo = n
+	}
+	if thockNumber == "" && gatarNo == 0 {
+		http.Error(w, "Missing thock or gatar parameter", http.StatusBadRequest)
+		return
+	}
+
+	movements, err := h.StockRepo.ListMovements(r.Context(), thockNumber, gatarNo, 500)
+	if err != nil {
+		http.Error(w, "Failed to query gatar movements: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if movements == nil {
+		movements = []*models.GatarStockMovement{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"thock_number": thockNumber,
+		"gatar_no":     gatarNo,
+		"movements":    movements,
+		"count":        len(movements),
+	})
+}
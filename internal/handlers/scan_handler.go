@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/labelcode"
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// ScanHandler handles scanned QR codes and the signed codes printed on labels and gate passes
+type ScanHandler struct {
+	ScanService     *services.ScanService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewScanHandler(scanService *services.ScanService, adminActionRepo *repositories.AdminActionLogRepository) *ScanHandler {
+	return &ScanHandler{ScanService: scanService, AdminActionRepo: adminActionRepo}
+}
+
+// writeScanError maps scan errors to HTTP status codes
+func writeScanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, labelcode.ErrInvalidCode), errors.Is(err, labelcode.ErrBadSignature), errors.Is(err, labelcode.ErrUnknownKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrScanNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrScanOtherSeason):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeQRCode returns a signed payload as JSON, or as a PNG image with ?format=png
+func (h *ScanHandler) writeQRCode(w http.ResponseWriter, r *http.Request, payload string) {
+	if r.URL.Query().Get("format") == "png" {
+		img, err := labelcode.PNG(payload, 240)
+		if err != nil {
+			http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(img)
+		return
+	}
+
+	qr, err := h.ScanService.QRCode(payload)
+	if err != nil {
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(qr)
+}
+
+// Scan resolves a scanned QR payload to its thock, where its bags are and the next actions
+// POST /api/scan
+func (h *ScanHandler) Scan(w http.ResponseWriter, r *http.Request) {
+	var req models.ScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	role, _ := middleware.GetRoleFromContext(r.Context())
+
+	result, err := h.ScanService.Resolve(r.Context(), strings.TrimSpace(req.Code), role)
+	if err != nil {
+		writeScanError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetThockQR returns the signed code for a thock's labels
+// GET /api/qr/thock/{thock_number}
+func (h *ScanHandler) GetThockQR(w http.ResponseWriter, r *http.Request) {
+	thockNumber := strings.TrimSpace(mux.Vars(r)["thock_number"])
+	if thockNumber == "" {
+		http.Error(w, "Thock number is required", http.StatusBadRequest)
+		return
+	}
+	payload, err := h.ScanService.ThockCode(r.Context(), thockNumber)
+	if err != nil {
+		writeScanError(w, err)
+		return
+	}
+	h.writeQRCode(w, r, payload)
+}
+
+// GetGatePassQR returns the signed code for a gate pass
+// GET /api/qr/gate-pass/{id}
+func (h *ScanHandler) GetGatePassQR(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.ScanService.GatePassRepo.GetGatePass(r.Context(), id); err != nil {
+		http.Error(w, "Gate pass not found", http.StatusNotFound)
+		return
+	}
+	payload, err := h.ScanService.GatePassCode(r.Context(), id)
+	if err != nil {
+		writeScanError(w, err)
+		return
+	}
+	h.writeQRCode(w, r, payload)
+}
+
+// PrintGatePass prints a gate pass slip with its QR code at the counter's receipt printer
+// POST /api/qr/gate-pass/{id}/print
+func (h *ScanHandler) PrintGatePass(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Counter string `json:"counter"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	job, err := h.ScanService.PrintGatePass(r.Context(), id, req.Counter, userID)
+	if errors.Is(err, services.ErrScanNotFound) {
+		http.Error(w, "Gate pass not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writePrintFailure(w, err)
+		return
+	}
+	writePrintResult(w, job, "Gate pass printed")
+}
+
+// RotateKey starts signing codes with a new key; labels already printed keep scanning
+// POST /api/qr/keys/rotate
+func (h *ScanHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+	keyID, err := h.ScanService.RotateKey(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to rotate QR signing key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "QR_KEY_ROTATE",
+		TargetType:  "qr_signing_key",
+		TargetID:    &keyID,
+		Description: fmt.Sprintf("Rotated QR signing key, new key %d", keyID),
+		IPAddress:   &ipAddress,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"key_id": keyID})
+}
@@ -149,13 +149,19 @@ func (h *SeasonHandler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Password == "" {
-		http.Error(w, "Password is required for verification", http.StatusBadRequest)
+	approval, err := h.service.ApproveRequest(r.Context(), id, userID, &models.ApprovalCredentials{
+		Password:  req.Password,
+		TOTPCode:  req.TOTPCode,
+		IPAddress: getIPAddress(r),
+	})
+	if err != nil {
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
-
-	if err := h.service.ApproveRequest(r.Context(), id, userID, req.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if approval != nil && approval.Status != models.ApprovalStatusApproved {
+		writeApprovalRecorded(w, approval)
 		return
 	}
 
@@ -189,8 +195,10 @@ func (h *SeasonHandler) RejectRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.RejectRequest(r.Context(), id, userID, req.Reason); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.service.RejectRequest(r.Context(), id, userID, req.Reason, getIPAddress(r)); err != nil {
+		if !writeApprovalError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+)
+
+// StockTransferHandler handles moving bags between gatars, floors and rooms
+type StockTransferHandler struct {
+	Service         *services.GatarStockService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewStockTransferHandler(s *services.GatarStockService, adminActionRepo *repositories.AdminActionLogRepository) *StockTransferHandler {
+	return &StockTransferHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// CreateTransfer moves bags of a thock to another gatar
+// POST /api/stock-transfers
+func (h *StockTransferHandler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req models.StockTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := h.Service.Transfer(r.Context(), &req, userID)
+	switch {
+	case errors.Is(err, services.ErrInvalidTransfer), errors.Is(err, services.ErrInvalidLayout):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, services.ErrGatarOverfill):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Stock moved - room visualization is stale
+	cache.InvalidateRoomCache(r.Context())
+
+	ipAddress := getIPAddress(r)
+	description := fmt.Sprintf("Moved %d bags of thock %s from gatar %d (Room %s, Floor %s) to gatar %d (Room %s, Floor %s)",
+		transfer.Quantity, transfer.ThockNumber, transfer.FromGatar, transfer.FromRoomNo, transfer.FromFloor,
+		transfer.ToGatar, transfer.ToRoomNo, transfer.ToFloor)
+	if transfer.Reason != "" {
+		description += ": " + transfer.Reason
+	}
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "STOCK_TRANSFER",
+		TargetType:  "stock_transfer",
+		TargetID:    &transfer.ID,
+		Description: description,
+		IPAddress:   &ipAddress,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// ListTransfers returns recent stock transfers
+// GET /api/stock-transfers?thock=1234/50
+func (h *StockTransferHandler) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	transfers, err := h.Service.ListTransfers(r.Context(), r.URL.Query().Get("thock"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if transfers == nil {
+		transfers = []*models.StockTransfer{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
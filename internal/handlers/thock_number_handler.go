@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
+)
+
+// ThockNumberHandler handles thock number reservations, voids, season starts and the audit report
+type ThockNumberHandler struct {
+	Service         *services.ThockNumberService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewThockNumberHandler(s *services.ThockNumberService, adminActionRepo *repositories.AdminActionLogRepository) *ThockNumberHandler {
+	return &ThockNumberHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// writeThockNumberError maps service errors to HTTP status codes
+func writeThockNumberError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidThockNumberRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrThockNumberUnavailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Reserve holds thock numbers back for later entries
+// POST /api/thock-numbers/reserve
+func (h *ThockNumberHandler) Reserve(w http.ResponseWriter, r *http.Request) {
+	var req models.ReserveThockNumbersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	records, err := h.Service.Reserve(r.Context(), &req, userID)
+	if err != nil {
+		writeThockNumberError(w, err)
+		return
+	}
+
+	numbers := make([]string, 0, len(records))
+	for _, rec := range records {
+		numbers = append(numbers, strconv.Itoa(rec.Number))
+	}
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "THOCK_NUMBER_RESERVE",
+		TargetType:  "thock_number",
+		Description: fmt.Sprintf("Reserved %s thock number(s) %s: %s", req.Category, strings.Join(numbers, ", "), req.Reason),
+		IPAddress:   &ipAddress,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(records)
+}
+
+// Void withdraws a thock number for good
+// POST /api/thock-numbers/void
+func (h *ThockNumberHandler) Void(w http.ResponseWriter, r *http.Request) {
+	var req models.VoidThockNumberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	record, err := h.Service.Void(r.Context(), &req, userID)
+	if err != nil {
+		writeThockNumberError(w, err)
+		return
+	}
+
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "THOCK_NUMBER_VOID",
+		TargetType:  "thock_number",
+		TargetID:    &record.ID,
+		Description: fmt.Sprintf("Voided %s thock number %d (season %s): %s", record.Category, record.Number, record.Season, record.Reason),
+		IPAddress:   &ipAddress,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// StartSeason switches numbering to a new season's series
+// POST /api/thock-numbers/season
+func (h *ThockNumberHandler) StartSeason(w http.ResponseWriter, r *http.Request) {
+	var req models.StartThockSeasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.Service.StartSeason(r.Context(), &req, userID); err != nil {
+		writeThockNumberError(w, err)
+		return
+	}
+
+	ipAddress := getIPAddress(r)
+	h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "THOCK_SEASON_START",
+		TargetType:  "thock_number",
+		Description: fmt.Sprintf("Started thock numbering season %s", strings.TrimSpace(req.Season)),
+		IPAddress:   &ipAddress,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"season": strings.TrimSpace(req.Season)})
+}
+
+// GetReport lists a season's series and its reserved, voided, skipped and missing numbers
+// GET /api/thock-numbers/report?season=&category=seed|sell&status=&format=csv
+func (h *ThockNumberHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	report, err := h.Service.Report(r.Context(), q.Get("season"), q.Get("category"), q.Get("status"))
+	if err != nil {
+		writeThockNumberError(w, err)
+		return
+	}
+
+	if q.Get("format") != "csv" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	filename := fmt.Sprintf("thock_numbers_%s_%s.csv", report.Season, timeutil.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Season", "Category", "Number", "Status", "Thock", "Reason", "By", "Date"})
+	for _, rec := range report.Records {
+		cw.Write([]string{rec.Season, rec.Category, strconv.Itoa(rec.Number), rec.Status, rec.ThockNumber,
+			rec.Reason, rec.UserName, timeutil.FormatIST(rec.UpdatedAt, "2006-01-02 15:04")})
+	}
+	for _, category := range []string{"seed", "sell"} {
+		for _, n := range report.Missing[category] {
+			cw.Write([]string{report.Season, category, strconv.Itoa(n), "missing", "", "No entry or record", "", ""})
+		}
+	}
+	cw.Flush()
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/timeutil"
+
+	"github.com/gorilla/mux"
+)
+
+// VehicleHandler handles the register of trucks from the guard gate to their exit
+type VehicleHandler struct {
+	Service         *services.VehicleService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewVehicleHandler(s *services.VehicleService, adminActionRepo *repositories.AdminActionLogRepository) *VehicleHandler {
+	return &VehicleHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// writeVehicleError maps service errors to HTTP status codes
+func writeVehicleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrVehicleVisitNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidVehicleVisit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrVehicleNotInside):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CheckIn books a vehicle in at the guard gate
+// POST /api/vehicles
+func (h *VehicleHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
+	var req models.VehicleCheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	visit, err := h.Service.CheckIn(r.Context(), &req, userID)
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(visit)
+}
+
+// ListVehicles returns the vehicle register, today's by default
+// GET /api/vehicles?from=YYYY-MM-DD&to=YYYY-MM-DD&vehicle_no=
+func (h *VehicleHandler) ListVehicles(w http.ResponseWriter, r *http.Request) {
+	today := timeutil.Now()
+	from, err := parseDateParam(r, "from", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(r, "to", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	visits, err := h.Service.List(r.Context(), r.URL.Query().Get("vehicle_no"), from, to)
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+	if visits == nil {
+		visits = []*models.VehicleVisit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visits)
+}
+
+// GetInside returns the dashboard of vehicles currently inside
+// GET /api/vehicles/inside
+func (h *VehicleHandler) GetInside(w http.ResponseWriter, r *http.Request) {
+	dash, err := h.Service.Inside(r.Context())
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dash)
+}
+
+// GetVehicle returns one vehicle visit
+// GET /api/vehicles/{id}
+func (h *VehicleHandler) GetVehicle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid vehicle visit ID", http.StatusBadRequest)
+		return
+	}
+
+	visit, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visit)
+}
+
+// ListGatePassVehicles returns the vehicles that collected on a gate pass
+// GET /api/vehicles/gate-pass/{id}
+func (h *VehicleHandler) ListGatePassVehicles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid gate pass ID", http.StatusBadRequest)
+		return
+	}
+
+	visits, err := h.Service.ListByGatePass(r.Context(), id)
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+	if visits == nil {
+		visits = []*models.VehicleVisit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visits)
+}
+
+// LinkVehicle links an entry or a gate pass to a vehicle inside
+// POST /api/vehicles/{id}/link
+func (h *VehicleHandler) LinkVehicle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid vehicle visit ID", http.StatusBadRequest)
+		return
+	}
+	var req models.VehicleLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	visit, err := h.Service.Link(r.Context(), id, &req)
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visit)
+}
+
+// GetExitCheck compares the bags counted on a vehicle with what its gate passes allow
+// GET /api/vehicles/{id}/exit-check?quantity_loaded=
+func (h *VehicleHandler) GetExitCheck(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid vehicle visit ID", http.StatusBadRequest)
+		return
+	}
+	var loaded *int
+	if v := r.URL.Query().Get("quantity_loaded"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid quantity_loaded", http.StatusBadRequest)
+			return
+		}
+		loaded = &n
+	}
+
+	check, err := h.Service.ExitCheck(r.Context(), id, loaded)
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
+
+// ExitVehicle books a vehicle out with the bags counted on it. A count that does not
+// match its gate passes returns 409 with the check, unless accept_mismatch is set.
+// POST /api/vehicles/{id}/exit
+func (h *VehicleHandler) ExitVehicle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid vehicle visit ID", http.StatusBadRequest)
+		return
+	}
+	var req models.VehicleExitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	check, err := h.Service.Exit(r.Context(), id, &req, userID)
+	if errors.Is(err, services.ErrVehicleQuantityMismatch) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(check)
+		return
+	}
+	if err != nil {
+		writeVehicleError(w, err)
+		return
+	}
+
+	// A truck let out with a different load than its gate passes is what theft claims turn on
+	if check.Visit.QuantityMismatch {
+		ipAddress := getIPAddress(r)
+		h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+			AdminUserID: userID,
+			ActionType:  "VEHICLE_EXIT_MISMATCH",
+			TargetType:  "vehicle_visit",
+			TargetID:    &check.Visit.ID,
+			Description: fmt.Sprintf("Let %s out with %d bags against %d picked up: %s",
+				check.Visit.VehicleNo, req.QuantityLoaded, check.ExpectedQuantity, check.Visit.ExitRemarks),
+			IPAddress: &ipAddress,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// WarehouseLayoutHandler handles warehouse layout (rooms, floors, gatars) endpoints
+type WarehouseLayoutHandler struct {
+	Service         *services.WarehouseLayoutService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewWarehouseLayoutHandler(s *services.WarehouseLayoutService, adminActionRepo *repositories.AdminActionLogRepository) *WarehouseLayoutHandler {
+	return &WarehouseLayoutHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// ListRooms returns all rooms with their floors and capacity
+// GET /api/warehouse-layout?active=true
+func (h *WarehouseLayoutHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := h.Service.ListRooms(r.Context(), r.URL.Query().Get("active") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rooms == nil {
+		rooms = []*models.WarehouseRoom{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
+}
+
+// GetFloor returns a floor with every gatar on it
+// GET /api/warehouse-layout/rooms/{room}/floors/{floor}
+func (h *WarehouseLayoutHandler) GetFloor(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	floor, gatars, err := h.Service.GetFloor(r.Context(), vars["room"], vars["floor"])
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+	if gatars == nil {
+		gatars = []*models.WarehouseGatar{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"floor":  floor,
+		"gatars": gatars,
+	})
+}
+
+// LocateGatar returns the room and floor of a gatar number
+// GET /api/warehouse-layout/gatars/{gatar}
+func (h *WarehouseLayoutHandler) LocateGatar(w http.ResponseWriter, r *http.Request) {
+	gatarNo, err := strconv.Atoi(mux.Vars(r)["gatar"])
+	if err != nil {
+		http.Error(w, "Invalid gatar number", http.StatusBadRequest)
+		return
+	}
+
+	gatar, err := h.Service.LocateGatar(r.Context(), gatarNo)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gatar)
+}
+
+// SuggestPlacement proposes gatars for a number of bags, keeping the thock together on one floor
+// GET /api/warehouse-layout/suggest?quantity=&room=&floor=&thock_number=
+func (h *WarehouseLayoutHandler) SuggestPlacement(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	quantity, err := strconv.Atoi(query.Get("quantity"))
+	if err != nil {
+		http.Error(w, "Invalid quantity", http.StatusBadRequest)
+		return
+	}
+
+	suggestion, err := h.Service.SuggestPlacement(r.Context(), &models.PlacementSuggestionRequest{
+		Quantity:    quantity,
+		RoomNo:      query.Get("room"),
+		Floor:       query.Get("floor"),
+		ThockNumber: query.Get("thock_number"),
+	})
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestion)
+}
+
+// CreateRoom adds a room (admin only)
+// POST /api/warehouse-layout/rooms
+func (h *WarehouseLayoutHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req models.WarehouseRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.Service.CreateRoom(r.Context(), &req)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_ROOM_CREATE", &room.ID, fmt.Sprintf("Added room %s (%s)", room.RoomNo, room.Name))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(room)
+}
+
+// UpdateRoom edits a room's name, order, active flag and notes (admin only)
+// PUT /api/warehouse-layout/rooms/{room}
+func (h *WarehouseLayoutHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	var req models.WarehouseRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.Service.UpdateRoom(r.Context(), mux.Vars(r)["room"], &req)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_ROOM_UPDATE", &room.ID,
+		fmt.Sprintf("Updated room %s (%s), active=%t", room.RoomNo, room.Name, room.IsActive))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// DeleteRoom removes an unused room with its floors and gatars (admin only)
+// DELETE /api/warehouse-layout/rooms/{room}
+func (h *WarehouseLayoutHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	roomNo := mux.Vars(r)["room"]
+	if err := h.Service.DeleteRoom(r.Context(), roomNo); err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_ROOM_DELETE", nil, "Deleted room "+roomNo)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateFloor adds a floor to a room (admin only)
+// POST /api/warehouse-layout/rooms/{room}/floors
+func (h *WarehouseLayoutHandler) CreateFloor(w http.ResponseWriter, r *http.Request) {
+	var req models.WarehouseFloorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	floor, err := h.Service.CreateFloor(r.Context(), mux.Vars(r)["room"], &req)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_FLOOR_CREATE", &floor.ID,
+		fmt.Sprintf("Added floor %s to room %s", floor.Floor, floor.RoomNo))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(floor)
+}
+
+// DeleteFloor removes an unused floor and its gatars (admin only)
+// DELETE /api/warehouse-layout/rooms/{room}/floors/{floor}
+func (h *WarehouseLayoutHandler) DeleteFloor(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.Service.DeleteFloor(r.Context(), vars["room"], vars["floor"]); err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_FLOOR_DELETE", nil,
+		fmt.Sprintf("Deleted floor %s of room %s", vars["floor"], vars["room"]))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddGatars adds a range of gatars to a floor (admin only)
+// POST /api/warehouse-layout/rooms/{room}/floors/{floor}/gatars
+func (h *WarehouseLayoutHandler) AddGatars(w http.ResponseWriter, r *http.Request) {
+	var req models.AddWarehouseGatarsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	added, err := h.Service.AddGatars(r.Context(), vars["room"], vars["floor"], &req)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_GATARS_ADD", nil,
+		fmt.Sprintf("Added gatars %d-%d (%d bags each) to room %s floor %s",
+			req.FromGatar, req.ToGatar, req.CapacityBags, vars["room"], vars["floor"]))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int64{"added": added})
+}
+
+// UpdateGatars edits capacity, dimensions or disables a range of gatars (admin only)
+// PUT /api/warehouse-layout/rooms/{room}/floors/{floor}/gatars
+func (h *WarehouseLayoutHandler) UpdateGatars(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateWarehouseGatarsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	updated, err := h.Service.UpdateGatars(r.Context(), vars["room"], vars["floor"], &req)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	changes, _ := json.Marshal(req)
+	h.logLayoutChange(r, "LAYOUT_GATARS_UPDATE", nil,
+		fmt.Sprintf("Updated %d gatars in room %s floor %s: %s", updated, vars["room"], vars["floor"], changes))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+}
+
+// DeleteGatars removes a range of unused gatars from a floor (admin only)
+// DELETE /api/warehouse-layout/rooms/{room}/floors/{floor}/gatars?from=&to=
+func (h *WarehouseLayoutHandler) DeleteGatars(w http.ResponseWriter, r *http.Request) {
+	from, err1 := strconv.Atoi(r.URL.Query().Get("from"))
+	to, err2 := strconv.Atoi(r.URL.Query().Get("to"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "from and to gatar numbers are required", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	deleted, err := h.Service.DeleteGatars(r.Context(), vars["room"], vars["floor"], from, to)
+	if err != nil {
+		writeLayoutError(w, err)
+		return
+	}
+
+	h.logLayoutChange(r, "LAYOUT_GATARS_DELETE", nil,
+		fmt.Sprintf("Deleted %d gatars (%d-%d) from room %s floor %s", deleted, from, to, vars["room"], vars["floor"]))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted})
+}
+
+// logLayoutChange records a layout edit and drops the cached room visualization
+func (h *WarehouseLayoutHandler) logLayoutChange(r *http.Request, actionType string, targetID *int, description string) {
+	ctx := r.Context()
+	cache.InvalidateRoomCache(ctx)
+
+	userID, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		return
+	}
+	h.AdminActionRepo.CreateActionLog(ctx, &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  actionType,
+		TargetType:  "warehouse_layout",
+		TargetID:    targetID,
+		Description: description,
+	})
+}
+
+// writeLayoutError maps layout errors to HTTP status codes
+func writeLayoutError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidLayout):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrLayoutNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repositories.ErrLayoutConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cold-backend/internal/middleware"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/services"
+	"cold-backend/internal/weighbridge"
+
+	"github.com/gorilla/mux"
+)
+
+// WeighmentHandler handles weighbridge weighments of trucks at the gate and at pickup
+type WeighmentHandler struct {
+	Service         *services.WeighmentService
+	AdminActionRepo *repositories.AdminActionLogRepository
+}
+
+func NewWeighmentHandler(s *services.WeighmentService, adminActionRepo *repositories.AdminActionLogRepository) *WeighmentHandler {
+	return &WeighmentHandler{
+		Service:         s,
+		AdminActionRepo: adminActionRepo,
+	}
+}
+
+// writeWeighmentError maps service errors to HTTP status codes
+func writeWeighmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrWeighmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidWeighment), errors.Is(err, weighbridge.ErrManualMode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, weighbridge.ErrNoStableReading):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CreateWeighment opens a weighment for a guard entry or a gate pass
+// POST /api/weighments
+func (h *WeighmentHandler) CreateWeighment(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWeighmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	weighment, err := h.Service.Create(r.Context(), &req, userID)
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(weighment)
+}
+
+// ListWeighments returns recent weighments
+// GET /api/weighments?direction=inbound|outbound&thock=&gate_pass_id=
+func (h *WeighmentHandler) ListWeighments(w http.ResponseWriter, r *http.Request) {
+	gatePassID := 0
+	if v := r.URL.Query().Get("gate_pass_id"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid gate_pass_id", http.StatusBadRequest)
+			return
+		}
+		gatePassID = n
+	}
+
+	weighments, err := h.Service.List(r.Context(), r.URL.Query().Get("direction"), r.URL.Query().Get("thock"), gatePassID)
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+	if weighments == nil {
+		weighments = []*models.Weighment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weighments)
+}
+
+// GetWeighment returns one weighment
+// GET /api/weighments/{id}
+func (h *WeighmentHandler) GetWeighment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid weighment ID", http.StatusBadRequest)
+		return
+	}
+
+	weighment, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weighment)
+}
+
+// GetGuardEntryWeighment returns the inbound weighment of a guard entry
+// GET /api/weighments/guard-entry/{id}
+func (h *WeighmentHandler) GetGuardEntryWeighment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid guard entry ID", http.StatusBadRequest)
+		return
+	}
+
+	weighment, err := h.Service.GetByGuardEntry(r.Context(), id)
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weighment)
+}
+
+// CaptureWeight records the gross or tare weight, typed in or read from the weighbridge
+// POST /api/weighments/{id}/capture
+func (h *WeighmentHandler) CaptureWeight(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid weighment ID", http.StatusBadRequest)
+		return
+	}
+	var req models.CaptureWeightRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	weighment, err := h.Service.Capture(r.Context(), id, &req, userID)
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	// Hand-typed weights are what disputes turn on, so they are logged
+	if req.WeightKg > 0 {
+		ipAddress := getIPAddress(r)
+		h.AdminActionRepo.CreateActionLog(r.Context(), &models.AdminActionLog{
+			AdminUserID: userID,
+			ActionType:  "WEIGHT_MANUAL_ENTRY",
+			TargetType:  "weighment",
+			TargetID:    &weighment.ID,
+			Description: fmt.Sprintf("Entered %s weight %.2f kg by hand on weighment %d (%s %s)",
+				req.Stage, req.WeightKg, weighment.ID, weighment.Direction, weighment.VehicleNo),
+			IPAddress: &ipAddress,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weighment)
+}
+
+// LinkThock stores an inbound weighment's average bag weight on a thock
+// POST /api/weighments/{id}/link-thock
+func (h *WeighmentHandler) LinkThock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid weighment ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		ThockNumber string `json:"thock_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	weighment, err := h.Service.LinkThock(r.Context(), id, req.ThockNumber)
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weighment)
+}
+
+// GetThockWeight returns the inbound and outbound weight of a thock
+// GET /api/weighments/thock/{thock_number}
+func (h *WeighmentHandler) GetThockWeight(w http.ResponseWriter, r *http.Request) {
+	thockNumber := mux.Vars(r)["thock_number"]
+	weights, err := h.Service.ThockWeights(r.Context(), []string{thockNumber})
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+	if len(weights) == 0 {
+		http.Error(w, "Thock not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weights[0])
+}
+
+// GetReading takes a stable reading from the weighbridge for the capture screen
+// GET /api/weighbridge/reading
+func (h *WeighmentHandler) GetReading(w http.ResponseWriter, r *http.Request) {
+	reading, err := h.Service.ReadWeighbridge(r.Context())
+	if err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reading)
+}
+
+// GetStatus returns the configured weighbridge mode
+// GET /api/weighbridge/status
+func (h *WeighmentHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	mode := h.Service.Mode(r.Context())
+	status := map[string]interface{}{"mode": mode}
+	if mode == weighbridge.ModeSimulator {
+		status["simulator_weight_kg"] = h.Service.Simulator.Weight()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// SetSimulatorWeight puts a truck on the simulated weighbridge
+// POST /api/weighbridge/simulator
+func (h *WeighmentHandler) SetSimulatorWeight(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		WeightKg float64 `json:"weight_kg"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.Service.SetSimulatorWeight(req.WeightKg); err != nil {
+		writeWeighmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]float64{"weight_kg": req.WeightKg})
+}
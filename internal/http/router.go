@@ -63,6 +63,18 @@ func NewRouter(
 	deletedEntriesHandler *handlers.DeletedEntriesHandler,
 	mediaSyncHandler *handlers.MediaSyncHandler,
 	poolSyncHandler *handlers.PoolSyncHandler,
+	rentTariffHandler *handlers.RentTariffHandler,
+	bankReconciliationHandler *handlers.BankReconciliationHandler,
+	warehouseLayoutHandler *handlers.WarehouseLayoutHandler,
+	stockTransferHandler *handlers.StockTransferHandler,
+	roomSensorHandler *handlers.RoomSensorHandler,
+	qualityInspectionHandler *handlers.QualityInspectionHandler,
+	weighmentHandler *handlers.WeighmentHandler,
+	thockNumberHandler *handlers.ThockNumberHandler,
+	scanHandler *handlers.ScanHandler,
+	vehicleHandler *handlers.VehicleHandler,
+	pickupSlotHandler *handlers.PickupSlotHandler,
+	approvalHandler *handlers.ApprovalHandler,
 ) *mux.Router {
 	r := mux.NewRouter()
 
@@ -276,6 +288,9 @@ func NewRouter(
 	rentPaymentsAPI.HandleFunc("/entry/{entry_id}", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentPaymentHandler.GetPaymentsByEntry)).ServeHTTP).Methods("GET")
 	rentPaymentsAPI.HandleFunc("/phone", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentPaymentHandler.GetPaymentsByPhone)).ServeHTTP).Methods("GET")
 	rentPaymentsAPI.HandleFunc("/receipt/{receipt_number}", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentPaymentHandler.GetPaymentByReceiptNumber)).ServeHTTP).Methods("GET")
+	rentPaymentsAPI.HandleFunc("/outstanding", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentPaymentHandler.GetOutstanding)).ServeHTTP).Methods("GET")
+	rentPaymentsAPI.HandleFunc("/{id}/allocations", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentPaymentHandler.GetAllocations)).ServeHTTP).Methods("GET")
+	rentPaymentsAPI.HandleFunc("/{id}/allocations", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentPaymentHandler.UpdateAllocations)).ServeHTTP).Methods("PUT")
 
 	// Protected API routes - Invoices (employees and admins can create, all can view)
 	invoicesAPI := r.PathPrefix("/api/invoices").Subrouter()
@@ -283,7 +298,9 @@ func NewRouter(
 	invoicesAPI.HandleFunc("", invoiceHandler.CreateInvoice).Methods("POST")
 	invoicesAPI.HandleFunc("", invoiceHandler.ListInvoices).Methods("GET")
 	invoicesAPI.HandleFunc("/{id}", invoiceHandler.GetInvoice).Methods("GET")
-	invoicesAPI.HandleFunc("/number/{number}", invoiceHandler.GetInvoiceByNumber).Methods("GET")
+	invoicesAPI.HandleFunc("/{id}/pdf", invoiceHandler.GetInvoicePDF).Methods("GET")
+	invoicesAPI.HandleFunc("/{id}/cancel", invoiceHandler.CancelInvoice).Methods("POST")
+	invoicesAPI.HandleFunc("/number/{number:.+}", invoiceHandler.GetInvoiceByNumber).Methods("GET")
 	invoicesAPI.HandleFunc("/customer/{customer_id}", invoiceHandler.GetCustomerInvoices).Methods("GET")
 
 	// Protected API routes - Login Logs (admin only)
@@ -472,10 +489,30 @@ func NewRouter(
 	gatePassAPI.HandleFunc("/{id}/complete", operationModeMiddleware.RequireUnloadingMode(
 		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.CompleteGatePass)),
 	).ServeHTTP).Methods("POST")
+	gatePassAPI.HandleFunc("/{id}/cancel", operationModeMiddleware.RequireUnloadingMode(
+		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.CancelGatePass)),
+	).ServeHTTP).Methods("POST")
+	gatePassAPI.HandleFunc("/{id}/reissue", operationModeMiddleware.RequireUnloadingMode(
+		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.ReissueGatePass)),
+	).ServeHTTP).Methods("POST")
+	gatePassAPI.HandleFunc("/{id}/pickup-slot", operationModeMiddleware.RequireUnloadingMode(
+		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.SetPickupSlot)),
+	).ServeHTTP).Methods("PUT")
+	// Amendments to approved gate passes wait for a second person's approval
+	gatePassAPI.HandleFunc("/amendments/pending", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.ListPendingAmendments)).ServeHTTP).Methods("GET")
+	gatePassAPI.HandleFunc("/amendments/{amendment_id:[0-9]+}/approve", operationModeMiddleware.RequireUnloadingMode(
+		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.ApproveAmendment)),
+	).ServeHTTP).Methods("POST")
+	gatePassAPI.HandleFunc("/amendments/{amendment_id:[0-9]+}/reject", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.RejectAmendment)).ServeHTTP).Methods("POST")
+	gatePassAPI.HandleFunc("/{id:[0-9]+}/amendments", gatePassHandler.ListAmendments).Methods("GET")
+	gatePassAPI.HandleFunc("/{id:[0-9]+}/amendments", operationModeMiddleware.RequireUnloadingMode(
+		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.RequestAmendment)),
+	).ServeHTTP).Methods("POST")
 	// Static paths must come before dynamic {id} paths
 	gatePassAPI.HandleFunc("/pickups/all", gatePassHandler.ListAllPickups).Methods("GET")               // All pickups for activity log
 	gatePassAPI.HandleFunc("/pickups/by-thock", gatePassHandler.GetPickupHistoryByThock).Methods("GET") // Pickups by thock number
 	gatePassAPI.HandleFunc("/{id}/pickups", gatePassHandler.GetPickupHistory).Methods("GET")            // View only - allowed in any mode
+	gatePassAPI.HandleFunc("/{id}/transitions", gatePassHandler.GetTransitions).Methods("GET")          // Status history - allowed in any mode
 	gatePassAPI.HandleFunc("/pickup", operationModeMiddleware.RequireUnloadingMode(
 		authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(gatePassHandler.RecordPickup)),
 	).ServeHTTP).Methods("POST")
@@ -675,6 +712,10 @@ func NewRouter(
 		reportAPI.HandleFunc("/daily-summary/csv", reportHandler.GetDailySummaryCSV).Methods("GET")
 		reportAPI.HandleFunc("/daily-summary/pdf", reportHandler.GetDailySummaryPDF).Methods("GET")
 
+		// Accounting exports (Tally XML, CSV journal)
+		reportAPI.HandleFunc("/tally/xml", reportHandler.GetTallyXML).Methods("GET")
+		reportAPI.HandleFunc("/journal/csv", reportHandler.GetJournalCSV).Methods("GET")
+
 		// Report stats (for UI)
 		reportAPI.HandleFunc("/stats", reportHandler.GetReportStats).Methods("GET")
 	}
@@ -694,6 +735,10 @@ func NewRouter(
 		ledgerAPI.HandleFunc("/customer/{phone}", ledgerHandler.GetCustomerLedger).Methods("GET")
 		ledgerAPI.HandleFunc("/balance/{phone}", ledgerHandler.GetCustomerBalance).Methods("GET")
 		ledgerAPI.HandleFunc("/summary/{phone}", ledgerHandler.GetCustomerSummary).Methods("GET")
+		// Same views keyed by customer ID (stable across phone changes and merges)
+		ledgerAPI.HandleFunc("/customer-id/{id}", ledgerHandler.GetCustomerLedgerByID).Methods("GET")
+		ledgerAPI.HandleFunc("/balance-id/{id}", ledgerHandler.GetCustomerBalanceByID).Methods("GET")
+		ledgerAPI.HandleFunc("/summary-id/{id}", ledgerHandler.GetCustomerSummaryByID).Methods("GET")
 		// Admin/accountant only endpoints
 		ledgerAPI.HandleFunc("/audit", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetAuditTrail)).ServeHTTP).Methods("GET")
 		ledgerAPI.HandleFunc("/debtors", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetDebtors)).ServeHTTP).Methods("GET")
@@ -701,6 +746,177 @@ func NewRouter(
 		ledgerAPI.HandleFunc("/totals", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetTotalsByType)).ServeHTTP).Methods("GET")
 		// Admin only - create manual entries
 		ledgerAPI.HandleFunc("/entry", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.CreateEntry)).ServeHTTP).Methods("POST")
+		// Double-entry books for the CA
+		ledgerAPI.HandleFunc("/accounts", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetAccounts)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/trial-balance", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetTrialBalance)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/day-book", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetDayBook)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/profit-loss", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetProfitAndLoss)).ServeHTTP).Methods("GET")
+		// Rent accrual (rent posted as CHARGE entries)
+		ledgerAPI.HandleFunc("/accrual/runs", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.ListRentAccrualRuns)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/integrity", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.VerifyIntegrity)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/integrity/repair", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.RepairIntegrity)).ServeHTTP).Methods("POST")
+		ledgerAPI.HandleFunc("/accrual/run", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.RunRentAccrual)).ServeHTTP).Methods("POST")
+		// Late-payment interest and early-payment discount policies
+		ledgerAPI.HandleFunc("/adjustment-policies", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.ListAdjustmentPolicies)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/adjustment-policies/{id}", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.GetAdjustmentPolicy)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/adjustment-policies", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.CreateAdjustmentPolicy)).ServeHTTP).Methods("POST")
+		ledgerAPI.HandleFunc("/adjustment-policies/{id}", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.UpdateAdjustmentPolicy)).ServeHTTP).Methods("PUT")
+		ledgerAPI.HandleFunc("/adjustment-policies/{id}/activate", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.ActivateAdjustmentPolicy)).ServeHTTP).Methods("PUT")
+		ledgerAPI.HandleFunc("/adjustment-policies/{id}/deactivate", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.DeactivateAdjustmentPolicy)).ServeHTTP).Methods("PUT")
+		ledgerAPI.HandleFunc("/adjustments/preview", authMiddleware.RequireAccountantAccess(http.HandlerFunc(ledgerHandler.PreviewRentAdjustments)).ServeHTTP).Methods("GET")
+		ledgerAPI.HandleFunc("/adjustments/run", authMiddleware.RequireAdmin(http.HandlerFunc(ledgerHandler.RunRentAdjustments)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - Rent Tariffs (versioned rate cards used for all rent calculations)
+	if rentTariffHandler != nil {
+		tariffAPI := r.PathPrefix("/api/rent-tariffs").Subrouter()
+		tariffAPI.Use(authMiddleware.Authenticate)
+		tariffAPI.HandleFunc("", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentTariffHandler.ListTariffs)).ServeHTTP).Methods("GET")
+		tariffAPI.HandleFunc("/quote", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentTariffHandler.QuoteRent)).ServeHTTP).Methods("GET")
+		tariffAPI.HandleFunc("/{id}", authMiddleware.RequireAccountantAccess(http.HandlerFunc(rentTariffHandler.GetTariff)).ServeHTTP).Methods("GET")
+		// Admin only - publish and retire rate cards
+		tariffAPI.HandleFunc("", authMiddleware.RequireAdmin(http.HandlerFunc(rentTariffHandler.CreateTariff)).ServeHTTP).Methods("POST")
+		tariffAPI.HandleFunc("/{id}/activate", authMiddleware.RequireAdmin(http.HandlerFunc(rentTariffHandler.ActivateTariff)).ServeHTTP).Methods("PUT")
+		tariffAPI.HandleFunc("/{id}/deactivate", authMiddleware.RequireAdmin(http.HandlerFunc(rentTariffHandler.DeactivateTariff)).ServeHTTP).Methods("PUT")
+	}
+
+	// Protected API routes - Bank Reconciliation (statement import, matching queue)
+	if bankReconciliationHandler != nil {
+		bankAPI := r.PathPrefix("/api/bank-reconciliation").Subrouter()
+		bankAPI.Use(authMiddleware.Authenticate)
+		bankAPI.Use(authMiddleware.RequireAccountantAccess)
+		bankAPI.HandleFunc("/imports", bankReconciliationHandler.ListImports).Methods("GET")
+		bankAPI.HandleFunc("/imports", bankReconciliationHandler.ImportStatement).Methods("POST")
+		bankAPI.HandleFunc("/lines", bankReconciliationHandler.ListLines).Methods("GET")
+		bankAPI.HandleFunc("/lines/{id}/candidates", bankReconciliationHandler.GetCandidates).Methods("GET")
+		bankAPI.HandleFunc("/lines/{id}/link", bankReconciliationHandler.LinkLine).Methods("POST")
+		bankAPI.HandleFunc("/lines/{id}/post", bankReconciliationHandler.PostLine).Methods("POST")
+		bankAPI.HandleFunc("/lines/{id}/ignore", bankReconciliationHandler.IgnoreLine).Methods("POST")
+		bankAPI.HandleFunc("/lines/{id}/unlink", bankReconciliationHandler.UnlinkLine).Methods("POST")
+		bankAPI.HandleFunc("/rematch", bankReconciliationHandler.Rematch).Methods("POST")
+	}
+
+	// Protected API routes - Warehouse Layout (rooms, floors, gatars and their capacity)
+	if warehouseLayoutHandler != nil {
+		layoutAPI := r.PathPrefix("/api/warehouse-layout").Subrouter()
+		layoutAPI.Use(authMiddleware.Authenticate)
+		layoutAPI.HandleFunc("", warehouseLayoutHandler.ListRooms).Methods("GET")
+		layoutAPI.HandleFunc("/gatars/{gatar}", warehouseLayoutHandler.LocateGatar).Methods("GET")
+		layoutAPI.HandleFunc("/suggest", warehouseLayoutHandler.SuggestPlacement).Methods("GET")
+		layoutAPI.HandleFunc("/rooms/{room}/floors/{floor}", warehouseLayoutHandler.GetFloor).Methods("GET")
+		// Admin only - edit the layout
+		layoutAPI.HandleFunc("/rooms", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.CreateRoom)).ServeHTTP).Methods("POST")
+		layoutAPI.HandleFunc("/rooms/{room}", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.UpdateRoom)).ServeHTTP).Methods("PUT")
+		layoutAPI.HandleFunc("/rooms/{room}", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.DeleteRoom)).ServeHTTP).Methods("DELETE")
+		layoutAPI.HandleFunc("/rooms/{room}/floors", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.CreateFloor)).ServeHTTP).Methods("POST")
+		layoutAPI.HandleFunc("/rooms/{room}/floors/{floor}", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.DeleteFloor)).ServeHTTP).Methods("DELETE")
+		layoutAPI.HandleFunc("/rooms/{room}/floors/{floor}/gatars", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.AddGatars)).ServeHTTP).Methods("POST")
+		layoutAPI.HandleFunc("/rooms/{room}/floors/{floor}/gatars", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.UpdateGatars)).ServeHTTP).Methods("PUT")
+		layoutAPI.HandleFunc("/rooms/{room}/floors/{floor}/gatars", authMiddleware.RequireAdmin(http.HandlerFunc(warehouseLayoutHandler.DeleteGatars)).ServeHTTP).Methods("DELETE")
+	}
+
+	// Protected API routes - Stock Transfers (moving bags between gatars, floors and rooms)
+	if stockTransferHandler != nil {
+		transferAPI := r.PathPrefix("/api/stock-transfers").Subrouter()
+		transferAPI.Use(authMiddleware.Authenticate)
+		transferAPI.HandleFunc("", stockTransferHandler.ListTransfers).Methods("GET")
+		transferAPI.HandleFunc("", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(stockTransferHandler.CreateTransfer)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - Quality Inspections (per-thock inspections, deterioration alerts, stock aging)
+	if qualityInspectionHandler != nil {
+		inspectionAPI := r.PathPrefix("/api/quality-inspections").Subrouter()
+		inspectionAPI.Use(authMiddleware.Authenticate)
+		inspectionAPI.HandleFunc("", qualityInspectionHandler.ListInspections).Methods("GET")
+		inspectionAPI.HandleFunc("", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(qualityInspectionHandler.CreateInspection)).ServeHTTP).Methods("POST")
+		inspectionAPI.HandleFunc("/alerts", qualityInspectionHandler.ListAlerts).Methods("GET")
+		inspectionAPI.HandleFunc("/aging", qualityInspectionHandler.GetAgingReport).Methods("GET")
+		inspectionAPI.HandleFunc("/{id:[0-9]+}", qualityInspectionHandler.GetInspection).Methods("GET")
+		inspectionAPI.HandleFunc("/{id:[0-9]+}/notify-owner", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(qualityInspectionHandler.NotifyOwner)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - Weighments (weighbridge gross/tare weights at the gate and at pickup)
+	if weighmentHandler != nil {
+		gateStaff := authMiddleware.RequireRole("guard", "employee", "admin")
+		weighmentAPI := r.PathPrefix("/api/weighments").Subrouter()
+		weighmentAPI.Use(authMiddleware.Authenticate)
+		weighmentAPI.HandleFunc("", gateStaff(http.HandlerFunc(weighmentHandler.ListWeighments)).ServeHTTP).Methods("GET")
+		weighmentAPI.HandleFunc("", gateStaff(http.HandlerFunc(weighmentHandler.CreateWeighment)).ServeHTTP).Methods("POST")
+		weighmentAPI.HandleFunc("/guard-entry/{id:[0-9]+}", gateStaff(http.HandlerFunc(weighmentHandler.GetGuardEntryWeighment)).ServeHTTP).Methods("GET")
+		weighmentAPI.HandleFunc("/thock/{thock_number}", weighmentHandler.GetThockWeight).Methods("GET")
+		weighmentAPI.HandleFunc("/{id:[0-9]+}", gateStaff(http.HandlerFunc(weighmentHandler.GetWeighment)).ServeHTTP).Methods("GET")
+		weighmentAPI.HandleFunc("/{id:[0-9]+}/capture", gateStaff(http.HandlerFunc(weighmentHandler.CaptureWeight)).ServeHTTP).Methods("POST")
+		weighmentAPI.HandleFunc("/{id:[0-9]+}/link-thock", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(weighmentHandler.LinkThock)).ServeHTTP).Methods("POST")
+
+		weighbridgeAPI := r.PathPrefix("/api/weighbridge").Subrouter()
+		weighbridgeAPI.Use(authMiddleware.Authenticate)
+		weighbridgeAPI.HandleFunc("/status", gateStaff(http.HandlerFunc(weighmentHandler.GetStatus)).ServeHTTP).Methods("GET")
+		weighbridgeAPI.HandleFunc("/reading", gateStaff(http.HandlerFunc(weighmentHandler.GetReading)).ServeHTTP).Methods("GET")
+		weighbridgeAPI.HandleFunc("/simulator", authMiddleware.RequireRole("admin")(http.HandlerFunc(weighmentHandler.SetSimulatorWeight)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - Thock Numbers (per-season series, reservations, voids and audit report)
+	if thockNumberHandler != nil {
+		thockNumberAPI := r.PathPrefix("/api/thock-numbers").Subrouter()
+		thockNumberAPI.Use(authMiddleware.Authenticate)
+		thockNumberAPI.HandleFunc("/report", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(thockNumberHandler.GetReport)).ServeHTTP).Methods("GET")
+		thockNumberAPI.HandleFunc("/reserve", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(thockNumberHandler.Reserve)).ServeHTTP).Methods("POST")
+		thockNumberAPI.HandleFunc("/void", authMiddleware.RequireAdmin(http.HandlerFunc(thockNumberHandler.Void)).ServeHTTP).Methods("POST")
+		thockNumberAPI.HandleFunc("/season", authMiddleware.RequireAdmin(http.HandlerFunc(thockNumberHandler.StartSeason)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - QR codes on labels and gate passes, and handheld scanning
+	if scanHandler != nil {
+		scanAPI := r.PathPrefix("/api/scan").Subrouter()
+		scanAPI.Use(authMiddleware.Authenticate)
+		scanAPI.HandleFunc("", scanHandler.Scan).Methods("POST")
+
+		qrAPI := r.PathPrefix("/api/qr").Subrouter()
+		qrAPI.Use(authMiddleware.Authenticate)
+		qrAPI.HandleFunc("/thock/{thock_number}", scanHandler.GetThockQR).Methods("GET")
+		qrAPI.HandleFunc("/gate-pass/{id:[0-9]+}", scanHandler.GetGatePassQR).Methods("GET")
+		qrAPI.HandleFunc("/gate-pass/{id:[0-9]+}/print", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(scanHandler.PrintGatePass)).ServeHTTP).Methods("POST")
+		qrAPI.HandleFunc("/keys/rotate", authMiddleware.RequireAdmin(http.HandlerFunc(scanHandler.RotateKey)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - Vehicle register (trucks from the guard gate to their exit)
+	if vehicleHandler != nil {
+		gateStaff := authMiddleware.RequireRole("guard", "employee", "admin")
+		vehicleAPI := r.PathPrefix("/api/vehicles").Subrouter()
+		vehicleAPI.Use(authMiddleware.Authenticate)
+		vehicleAPI.HandleFunc("", gateStaff(http.HandlerFunc(vehicleHandler.ListVehicles)).ServeHTTP).Methods("GET")
+		vehicleAPI.HandleFunc("", gateStaff(http.HandlerFunc(vehicleHandler.CheckIn)).ServeHTTP).Methods("POST")
+		vehicleAPI.HandleFunc("/inside", gateStaff(http.HandlerFunc(vehicleHandler.GetInside)).ServeHTTP).Methods("GET")
+		vehicleAPI.HandleFunc("/gate-pass/{id:[0-9]+}", gateStaff(http.HandlerFunc(vehicleHandler.ListGatePassVehicles)).ServeHTTP).Methods("GET")
+		vehicleAPI.HandleFunc("/{id:[0-9]+}", gateStaff(http.HandlerFunc(vehicleHandler.GetVehicle)).ServeHTTP).Methods("GET")
+		vehicleAPI.HandleFunc("/{id:[0-9]+}/link", gateStaff(http.HandlerFunc(vehicleHandler.LinkVehicle)).ServeHTTP).Methods("POST")
+		vehicleAPI.HandleFunc("/{id:[0-9]+}/exit-check", gateStaff(http.HandlerFunc(vehicleHandler.GetExitCheck)).ServeHTTP).Methods("GET")
+		vehicleAPI.HandleFunc("/{id:[0-9]+}/exit", gateStaff(http.HandlerFunc(vehicleHandler.ExitVehicle)).ServeHTTP).Methods("POST")
+	}
+
+	// Protected API routes - Pickup slots per loading gate and the unloading queue
+	if pickupSlotHandler != nil {
+		gateStaff := authMiddleware.RequireRole("guard", "employee", "admin")
+		pickupSlotAPI := r.PathPrefix("/api/pickup-slots").Subrouter()
+		pickupSlotAPI.Use(authMiddleware.Authenticate)
+		pickupSlotAPI.HandleFunc("", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(pickupSlotHandler.ListSlots)).ServeHTTP).Methods("GET")
+		pickupSlotAPI.HandleFunc("", authMiddleware.RequireAdmin(http.HandlerFunc(pickupSlotHandler.CreateSlot)).ServeHTTP).Methods("POST")
+		pickupSlotAPI.HandleFunc("/generate", authMiddleware.RequireAdmin(http.HandlerFunc(pickupSlotHandler.GenerateSlots)).ServeHTTP).Methods("POST")
+		pickupSlotAPI.HandleFunc("/queue", gateStaff(http.HandlerFunc(pickupSlotHandler.GetQueue)).ServeHTTP).Methods("GET")
+		pickupSlotAPI.HandleFunc("/{id:[0-9]+}", authMiddleware.RequireAdmin(http.HandlerFunc(pickupSlotHandler.UpdateSlot)).ServeHTTP).Methods("PUT")
+	}
+
+	// Protected API routes - Approvals inbox and approval policies
+	if approvalHandler != nil {
+		approvalAPI := r.PathPrefix("/api/approvals").Subrouter()
+		approvalAPI.Use(authMiddleware.Authenticate)
+		// Any staff member sees what waits for their decision; the policy decides who may act
+		approvalAPI.HandleFunc("/inbox", approvalHandler.GetInbox).Methods("GET")
+		approvalAPI.HandleFunc("/policies", authMiddleware.RequireAdmin(http.HandlerFunc(approvalHandler.ListPolicies)).ServeHTTP).Methods("GET")
+		approvalAPI.HandleFunc("/policies", authMiddleware.RequireAdmin(http.HandlerFunc(approvalHandler.CreatePolicy)).ServeHTTP).Methods("POST")
+		approvalAPI.HandleFunc("/policies/{id:[0-9]+}", authMiddleware.RequireAdmin(http.HandlerFunc(approvalHandler.UpdatePolicy)).ServeHTTP).Methods("PUT")
+		approvalAPI.HandleFunc("", authMiddleware.RequireAdmin(http.HandlerFunc(approvalHandler.ListApprovals)).ServeHTTP).Methods("GET")
+		approvalAPI.HandleFunc("/{id:[0-9]+}", authMiddleware.RequireAdmin(http.HandlerFunc(approvalHandler.GetApproval)).ServeHTTP).Methods("GET")
 	}
 
 	// Protected API routes - Debt Requests (debt approval workflow)
@@ -806,16 +1022,34 @@ func NewRouter(
 		entryRoomAPI.HandleFunc("/since", entryRoomHandler.GetDelta).Methods("GET")
 	}
 
-	// Protected API routes - Label Printer (server-side proxy to printer)
+	// Protected API routes - Printing (labels and receipts at each counter's printers, via the print queue)
 	if printerHandler != nil {
 		printerAPI := r.PathPrefix("/api/print").Subrouter()
 		printerAPI.Use(authMiddleware.Authenticate)
-		printerAPI.HandleFunc("", printerHandler.Print2Up).Methods("POST")
+		printerAPI.HandleFunc("", printerHandler.PrintLabel).Methods("POST")
 
-		// Receipt printing to HP printer
+		// Receipt printing
 		receiptAPI := r.PathPrefix("/api/print-receipt").Subrouter()
 		receiptAPI.Use(authMiddleware.Authenticate)
 		receiptAPI.HandleFunc("", printerHandler.PrintReceipt).Methods("POST")
+
+		// Printer setup per counter (admin only)
+		printersAPI := r.PathPrefix("/api/printers").Subrouter()
+		printersAPI.Use(authMiddleware.Authenticate)
+		printersAPI.HandleFunc("", printerHandler.ListPrinters).Methods("GET")
+		printersAPI.HandleFunc("", authMiddleware.RequireAdmin(http.HandlerFunc(printerHandler.CreatePrinter)).ServeHTTP).Methods("POST")
+		printersAPI.HandleFunc("/fake/jobs", authMiddleware.RequireAdmin(http.HandlerFunc(printerHandler.GetFakeOutput)).ServeHTTP).Methods("GET")
+		printersAPI.HandleFunc("/fake", authMiddleware.RequireAdmin(http.HandlerFunc(printerHandler.ControlFake)).ServeHTTP).Methods("POST")
+		printersAPI.HandleFunc("/{id:[0-9]+}", authMiddleware.RequireAdmin(http.HandlerFunc(printerHandler.UpdatePrinter)).ServeHTTP).Methods("PUT")
+		printersAPI.HandleFunc("/{id:[0-9]+}", authMiddleware.RequireAdmin(http.HandlerFunc(printerHandler.DeletePrinter)).ServeHTTP).Methods("DELETE")
+		printersAPI.HandleFunc("/{id:[0-9]+}/test", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(printerHandler.TestPrinter)).ServeHTTP).Methods("POST")
+
+		// Print queue
+		printJobsAPI := r.PathPrefix("/api/print-jobs").Subrouter()
+		printJobsAPI.Use(authMiddleware.Authenticate)
+		printJobsAPI.HandleFunc("", printerHandler.ListJobs).Methods("GET")
+		printJobsAPI.HandleFunc("/{id:[0-9]+}/retry", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(printerHandler.RetryJob)).ServeHTTP).Methods("POST")
+		printJobsAPI.HandleFunc("/{id:[0-9]+}/cancel", authMiddleware.RequireRole("employee", "admin")(http.HandlerFunc(printerHandler.CancelJob)).ServeHTTP).Methods("POST")
 	}
 
 	// Protected API routes - Room Visualization (all authenticated users)
@@ -827,6 +1061,17 @@ func NewRouter(
 		vizAPI.HandleFunc("/gatar-details", roomVisualizationHandler.GetGatarDetails).Methods("GET")
 		vizAPI.HandleFunc("/gatar-stock", roomVisualizationHandler.GetPerGatarStock).Methods("GET")
 		vizAPI.HandleFunc("/gatar-search", roomVisualizationHandler.SearchByGatar).Methods("GET")
+		vizAPI.HandleFunc("/gatar-movements", roomVisualizationHandler.GetGatarMovements).Methods("GET")
+	}
+
+	// Cold-room sensors: readings are posted by devices with X-Sensor-Token (no JWT),
+	// latest values and trends feed the room visualization page
+	if roomSensorHandler != nil {
+		r.HandleFunc("/api/sensors/readings", roomSensorHandler.IngestReadings).Methods("POST")
+		sensorAPI := r.PathPrefix("/api/sensors").Subrouter()
+		sensorAPI.Use(authMiddleware.Authenticate)
+		sensorAPI.HandleFunc("/latest", roomSensorHandler.GetLatest).Methods("GET")
+		sensorAPI.HandleFunc("/trend", roomSensorHandler.GetTrend).Methods("GET")
 	}
 
 	// Protected API routes - Items in Stock (all authenticated users)
@@ -891,6 +1136,7 @@ func NewCustomerRouter(
 	customerAPI.Use(authMiddleware.AuthenticateCustomer)
 	customerAPI.HandleFunc("/dashboard", customerPortalHandler.GetDashboard).Methods("GET")
 	customerAPI.HandleFunc("/gate-pass-requests", customerPortalHandler.CreateGatePassRequest).Methods("POST")
+	customerAPI.HandleFunc("/pickup-slots", customerPortalHandler.ListPickupSlots).Methods("GET")
 
 	// Payment routes (Razorpay)
 	if razorpayHandler != nil {
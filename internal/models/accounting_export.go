@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Tally voucher types used by the accounting export
+const (
+	VoucherTypeReceipt    = "Receipt"
+	VoucherTypeSales      = "Sales"
+	VoucherTypeCreditNote = "Credit Note"
+	VoucherTypePayment    = "Payment"
+)
+
+// AccountingLedgerNames maps postings to ledger names in the accountant's books (Tally)
+type AccountingLedgerNames struct {
+	Company        string `json:"company"`
+	Cash           string `json:"cash"`
+	Bank           string `json:"bank"`
+	BankAccount    string `json:"bank_account"`
+	RentIncome     string `json:"rent_income"`
+	InterestIncome string `json:"interest_income"`
+	Discount       string `json:"discount"`
+	Refunds        string `json:"refunds"`
+	DebtorsGroup   string `json:"debtors_group"`
+	PartyFormat    string `json:"party_format"` // Placeholders {name}, {phone}, {so}
+}
+
+// LedgerFor returns the ledger a chart of accounts account is booked to; Customer
+// Receivables is the customer's own party ledger
+func (n *AccountingLedgerNames) LedgerFor(accountCode, party string) string {
+	switch accountCode {
+	case AccountCodeCash:
+		return n.Cash
+	case AccountCodeBankRazorpay:
+		return n.Bank
+	case AccountCodeBank:
+		return n.BankAccount
+	case AccountCodeRefunds:
+		return n.Refunds
+	case AccountCodeRentIncome:
+		return n.RentIncome
+	case AccountCodeInterestIncome:
+		return n.InterestIncome
+	case AccountCodeDiscountsAllowed:
+		return n.Discount
+	}
+	return party
+}
+
+// AccountingVoucher is one ledger entry expressed as a two-line voucher
+type AccountingVoucher struct {
+	LedgerEntryID int       `json:"ledger_entry_id"`
+	Date          time.Time `json:"date"`
+	VoucherType   string    `json:"voucher_type"`
+	VoucherNumber string    `json:"voucher_number"`
+	PartyLedger   string    `json:"party_ledger"`
+	CustomerPhone string    `json:"customer_phone"`
+	Narration     string    `json:"narration"`
+	DebitLedger   string    `json:"debit_ledger"`
+	CreditLedger  string    `json:"credit_ledger"`
+	Amount        float64   `json:"amount"`
+}
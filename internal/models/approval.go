@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// Flows that go through the approval engine
+const (
+	ApprovalFlowGatePass = "gate_pass"
+	ApprovalFlowDebt     = "debt_request"
+	ApprovalFlowSetting  = "setting_change"
+	ApprovalFlowSeason   = "season_request"
+)
+
+// Approval request statuses
+const (
+	ApprovalStatusPending   = "pending"
+	ApprovalStatusApproved  = "approved"
+	ApprovalStatusRejected  = "rejected"
+	ApprovalStatusExpired   = "expired"
+	ApprovalStatusCancelled = "cancelled" // The subject was decided or expired outside the engine
+)
+
+// Approval decisions
+const (
+	ApprovalDecisionApprove = "approve"
+	ApprovalDecisionReject  = "reject"
+)
+
+// When approvers confirm with their 2FA code
+const (
+	ApprovalTOTPNone       = "none"
+	ApprovalTOTPIfEnrolled = "if_enrolled"
+	ApprovalTOTPRequired   = "required"
+)
+
+// ApprovalPolicy is the rule set for a flow from MinAmount upwards
+type ApprovalPolicy struct {
+	ID                  int       `json:"id"`
+	Flow                string    `json:"flow"`
+	MinAmount           float64   `json:"min_amount"`
+	RequiredApprovals   int       `json:"required_approvals"`
+	ApproverRoles       []string  `json:"approver_roles"`
+	ApproverUserIDs     []int     `json:"approver_user_ids"` // Empty = anyone with an approver role
+	AllowSelfApproval   bool      `json:"allow_self_approval"`
+	SelfApprovalUserIDs []int     `json:"self_approval_user_ids"`
+	RequirePassword     bool      `json:"require_password"`
+	TOTPMode            string    `json:"totp_mode"`
+	ExpiryHours         int       `json:"expiry_hours"` // 0 = never
+	IsActive            bool      `json:"is_active"`
+	UpdatedByUserID     *int      `json:"updated_by_user_id,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// ApprovalPolicyRequest creates a policy tier or replaces one's rules
+type ApprovalPolicyRequest struct {
+	Flow                string   `json:"flow"`
+	MinAmount           float64  `json:"min_amount"`
+	RequiredApprovals   int      `json:"required_approvals"`
+	ApproverRoles       []string `json:"approver_roles"`
+	ApproverUserIDs     []int    `json:"approver_user_ids"`
+	AllowSelfApproval   bool     `json:"allow_self_approval"`
+	SelfApprovalUserIDs []int    `json:"self_approval_user_ids"`
+	RequirePassword     bool     `json:"require_password"`
+	TOTPMode            string   `json:"totp_mode"`
+	ExpiryHours         int      `json:"expiry_hours"`
+	IsActive            bool     `json:"is_active"`
+}
+
+// ApprovalRequest is one gate pass, debt request, setting change or season request waiting
+// for approval under a policy
+type ApprovalRequest struct {
+	ID                int                 `json:"id"`
+	Flow              string              `json:"flow"`
+	SubjectID         int                 `json:"subject_id"`
+	Summary           string              `json:"summary"`
+	Terms             string              `json:"terms,omitempty"`
+	Amount            float64             `json:"amount"`
+	PolicyID          int                 `json:"policy_id"`
+	Status            string              `json:"status"`
+	RequestedByUserID *int                `json:"requested_by_user_id,omitempty"`
+	RequestedByName   string              `json:"requested_by_name,omitempty"`
+	Approvals         int                 `json:"approvals"`
+	RequiredApprovals int                 `json:"required_approvals"`
+	ExpiresAt         *time.Time          `json:"expires_at,omitempty"`
+	DecidedAt         *time.Time          `json:"decided_at,omitempty"`
+	CreatedAt         time.Time           `json:"created_at"`
+	Policy            *ApprovalPolicy     `json:"policy,omitempty"`
+	Decisions         []*ApprovalDecision `json:"decisions,omitempty"`
+}
+
+// ApprovalDecision is one approver's approve or reject
+type ApprovalDecision struct {
+	ID                int       `json:"id"`
+	ApprovalRequestID int       `json:"approval_request_id"`
+	UserID            int       `json:"user_id"`
+	UserName          string    `json:"user_name"`
+	Decision          string    `json:"decision"`
+	Comment           string    `json:"comment,omitempty"`
+	PasswordVerified  bool      `json:"password_verified"`
+	TOTPVerified      bool      `json:"totp_verified"`
+	IPAddress         string    `json:"ip_address,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ApprovalSubject describes what a flow puts up for approval
+type ApprovalSubject struct {
+	Flow        string
+	SubjectID   int
+	Amount      float64 // Rupees for debts, bags for gate passes
+	Summary     string
+	Terms       string // What approvers agree to beyond the amount, e.g. a gate pass's gate and expiry
+	RequestedBy *int
+}
+
+// ApprovalCredentials is what an approver confirms a decision with
+type ApprovalCredentials struct {
+	Password  string `json:"password,omitempty"`
+	TOTPCode  string `json:"totp_code,omitempty"`
+	IPAddress string `json:"-"`
+}
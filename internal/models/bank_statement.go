@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// Bank statement line statuses
+const (
+	BankLineStatusUnmatched = "unmatched" // In the reconciliation queue
+	BankLineStatusMatched   = "matched"   // Matched automatically
+	BankLineStatusLinked    = "linked"    // Linked to an existing record by the accountant
+	BankLineStatusPosted    = "posted"    // Ledger entry created from the line
+	BankLineStatusIgnored   = "ignored"   // Not a customer receipt
+)
+
+// BankStatementImport is one uploaded bank statement file
+type BankStatementImport struct {
+	ID               int        `json:"id"`
+	FileName         string     `json:"file_name"`
+	BankAccount      string     `json:"bank_account"`
+	StatementFrom    *time.Time `json:"statement_from,omitempty"`
+	StatementTo      *time.Time `json:"statement_to,omitempty"`
+	LineCount        int        `json:"line_count"`      // Credit lines stored
+	DuplicateCount   int        `json:"duplicate_count"` // Credit lines already imported earlier
+	ImportedByUserID int        `json:"imported_by_user_id"`
+	ImportedByName   string     `json:"imported_by_name,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+
+	// Filled when listing imports
+	MatchedCount   int `json:"matched_count"`
+	UnmatchedCount int `json:"unmatched_count"`
+}
+
+// BankStatementLine is a credit on the bank statement
+type BankStatementLine struct {
+	ID                  int        `json:"id"`
+	ImportID            int        `json:"import_id"`
+	LineNumber          int        `json:"line_number"`
+	TxnDate             time.Time  `json:"txn_date"`
+	Description         string     `json:"description"`
+	Reference           string     `json:"reference"`
+	UTR                 string     `json:"utr"`
+	Amount              float64    `json:"amount"`
+	Balance             *float64   `json:"balance,omitempty"`
+	Status              string     `json:"status"`
+	MatchReason         string     `json:"match_reason,omitempty"`
+	RentPaymentID       *int       `json:"rent_payment_id,omitempty"`
+	OnlineTransactionID *int       `json:"online_transaction_id,omitempty"`
+	LedgerEntryID       *int       `json:"ledger_entry_id,omitempty"`
+	ResolvedByUserID    *int       `json:"resolved_by_user_id,omitempty"`
+	ResolvedByName      string     `json:"resolved_by_name,omitempty"`
+	ResolvedAt          *time.Time `json:"resolved_at,omitempty"`
+	Notes               string     `json:"notes"`
+	LineHash            string     `json:"-"`
+	CreatedAt           time.Time  `json:"created_at"`
+
+	// Matched record, joined for display
+	CustomerName  string `json:"customer_name,omitempty"`
+	CustomerPhone string `json:"customer_phone,omitempty"`
+	MatchedRef    string `json:"matched_ref,omitempty"` // Receipt number, Razorpay order or ledger entry
+}
+
+// BankStatementImportResult is returned after an upload
+type BankStatementImportResult struct {
+	Import    *BankStatementImport `json:"import"`
+	Skipped   int                  `json:"skipped"` // Debits and rows that are not transactions
+	Matched   int                  `json:"matched"`
+	Unmatched int                  `json:"unmatched"`
+}
+
+// BankMatchCandidate is a record a bank line could be linked to
+type BankMatchCandidate struct {
+	Type          string    `json:"type"` // rent_payment | online_transaction | ledger_entry
+	ID            int       `json:"id"`
+	Reference     string    `json:"reference"` // Receipt number, Razorpay order or ledger entry description
+	UTR           string    `json:"utr,omitempty"`
+	CustomerName  string    `json:"customer_name"`
+	CustomerPhone string    `json:"customer_phone"`
+	Amount        float64   `json:"amount"`
+	Date          time.Time `json:"date"`
+	Reason        string    `json:"reason"`
+}
+
+// LinkBankLineRequest links a bank line to exactly one existing record
+type LinkBankLineRequest struct {
+	RentPaymentID       *int   `json:"rent_payment_id,omitempty"`
+	OnlineTransactionID *int   `json:"online_transaction_id,omitempty"`
+	LedgerEntryID       *int   `json:"ledger_entry_id,omitempty"`
+	Notes               string `json:"notes"`
+}
+
+// PostBankLineRequest creates a customer receipt in the ledger from a bank line
+type PostBankLineRequest struct {
+	CustomerPhone    string `json:"customer_phone"`
+	FamilyMemberID   *int   `json:"family_member_id,omitempty"`
+	FamilyMemberName string `json:"family_member_name,omitempty"`
+	Notes            string `json:"notes"`
+}
+
+// ResolveBankLineRequest carries the accountant's note when ignoring or unlinking a line
+type ResolveBankLineRequest struct {
+	Notes string `json:"notes"`
+}
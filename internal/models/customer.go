@@ -9,6 +9,8 @@ type Customer struct {
 	SO                   string     `json:"so"`
 	Village              string     `json:"village"`
 	Address              string     `json:"address"`
+	StateCode            string     `json:"state_code"` // GST state code (place of supply)
+	GSTIN                string     `json:"gstin"`
 	Status               string     `json:"status"`                  // 'active', 'merged', 'inactive'
 	MergedIntoCustomerID *int       `json:"merged_into_customer_id"` // If merged, points to target customer
 	MergedAt             *time.Time `json:"merged_at"`               // When merge happened
@@ -23,6 +25,9 @@ type CreateCustomerRequest struct {
 	SO      string `json:"so"`
 	Village string `json:"village"`
 	Address string `json:"address"`
+	// Optional GST details used on tax invoices
+	StateCode string `json:"state_code"`
+	GSTIN     string `json:"gstin"`
 }
 
 // UpdateCustomerRequest represents the request body for updating a customer
@@ -32,6 +37,9 @@ type UpdateCustomerRequest struct {
 	SO      string `json:"so"`
 	Village string `json:"village"`
 	Address string `json:"address"`
+	// Optional GST details used on tax invoices
+	StateCode string `json:"state_code"`
+	GSTIN     string `json:"gstin"`
 }
 
 // MergeCustomersRequest represents the request body for merging two customers
@@ -16,6 +16,8 @@ const (
 // DebtRequest represents a request for item withdrawal when customer has outstanding balance
 type DebtRequest struct {
 	ID                  int               `json:"id"`
+	CustomerID          int               `json:"customer_id"` // FK to customers (0 if no customer record)
+	FamilyMemberID      *int              `json:"family_member_id,omitempty"`
 	CustomerPhone       string            `json:"customer_phone"`
 	CustomerName        string            `json:"customer_name"`
 	CustomerSO          string            `json:"customer_so"` // S/O (Son Of / Father's Name)
@@ -36,6 +38,7 @@ type DebtRequest struct {
 
 // CreateDebtRequestRequest is used when creating a new debt request
 type CreateDebtRequestRequest struct {
+	CustomerID        int     `json:"customer_id"` // Optional, resolved from customer_phone when 0
 	CustomerPhone     string  `json:"customer_phone" validate:"required"`
 	CustomerName      string  `json:"customer_name" validate:"required"`
 	CustomerSO        string  `json:"customer_so"`
@@ -56,6 +59,7 @@ type RejectDebtRequestRequest struct {
 
 // DebtRequestFilter is used for filtering debt requests
 type DebtRequestFilter struct {
+	CustomerID    int               `json:"customer_id"`
 	CustomerPhone string            `json:"customer_phone"`
 	ThockNumber   string            `json:"thock_number"`
 	Status        DebtRequestStatus `json:"status"`
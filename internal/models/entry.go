@@ -38,6 +38,8 @@ type CreateEntryRequest struct {
 	ExpectedQuantity int    `json:"expected_quantity"`
 	ThockCategory    string `json:"thock_category"`
 	Remark           string `json:"remark"` // Variety/varieties (comma-separated)
+	// Optional thock number reserved earlier for this entry
+	ReservedNumber int `json:"reserved_number,omitempty"`
 }
 
 // UpdateEntryRequest represents the request body for updating an entry
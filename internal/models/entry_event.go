@@ -27,6 +27,7 @@ const (
 	EventTypeQualityCheck = "QUALITY_CHECK"
 	EventTypeReady        = "READY"
 	EventTypeCompleted    = "COMPLETED"
+	EventTypeStockMoved   = "STOCK_MOVED"
 )
 
 // Status constants
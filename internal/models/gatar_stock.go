@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Gatar stock movement types
+const (
+	GatarMovementIn          = "in"           // Room entry
+	GatarMovementEdit        = "edit"         // Room entry correction
+	GatarMovementOut         = "out"          // Gate pass pickup
+	GatarMovementTransferIn  = "transfer_in"  // Internal move, receiving gatar
+	GatarMovementTransferOut = "transfer_out" // Internal move, emptied gatar
+	GatarMovementLegacyIn    = "legacy_in"    // Converted from quantity breakdowns
+	GatarMovementLegacyOut   = "legacy_out"   // Converted from pickups recorded before the ledger
+)
+
+// GatarStockMovement is one change to the bags of a thock in a gatar
+type GatarStockMovement struct {
+	ID              int64     `json:"id"`
+	RoomEntryID     int       `json:"room_entry_id"`
+	ThockNumber     string    `json:"thock_number"`
+	RoomNo          string    `json:"room_no"`
+	Floor           string    `json:"floor"`
+	GatarNo         int       `json:"gatar_no"`
+	Quantity        int       `json:"quantity"` // Positive in, negative out
+	MovementType    string    `json:"movement_type"`
+	SourceType      string    `json:"source_type"`
+	SourceID        int       `json:"source_id"`
+	Quality         string    `json:"quality"`
+	Notes           string    `json:"notes"`
+	CreatedByUserID *int      `json:"created_by_user_id,omitempty"`
+	CreatedByName   string    `json:"created_by_name,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GatarBalance is the bags of one room entry (thock lot) in one gatar
+type GatarBalance struct {
+	RoomEntryID int    `json:"room_entry_id"`
+	ThockNumber string `json:"thock_number"`
+	RoomNo      string `json:"room_no"`
+	Floor       string `json:"floor"`
+	GatarNo     int    `json:"gatar_no"`
+	Quantity    int    `json:"quantity"` // Current balance
+	Placed      int    `json:"placed"`   // Bags placed by room entry and its corrections
+}
@@ -2,6 +2,18 @@ package models
 
 import "time"
 
+// Gate pass statuses. The stored names predate the state machine: "pending" is a requested
+// gate pass and "partially_completed" one that has been partly picked up.
+const (
+	GatePassStatusRequested       = "pending"
+	GatePassStatusApproved        = "approved"
+	GatePassStatusPartiallyPicked = "partially_completed"
+	GatePassStatusCompleted       = "completed"
+	GatePassStatusExpired         = "expired"
+	GatePassStatusCancelled       = "cancelled"
+	GatePassStatusRejected        = "rejected"
+)
+
 type GatePass struct {
 	ID                    int        `json:"id" db:"id"`
 	CustomerID            int        `json:"customer_id" db:"customer_id"`
@@ -28,6 +40,8 @@ type GatePass struct {
 	FinalApprovedQuantity *int       `json:"final_approved_quantity,omitempty" db:"final_approved_quantity"`
 	CreatedByCustomerID   *int       `json:"created_by_customer_id,omitempty" db:"created_by_customer_id"`
 	RequestSource         string     `json:"request_source" db:"request_source"` // "employee" or "customer_portal"
+	ReissuedFromID        *int       `json:"reissued_from_id,omitempty" db:"reissued_from_id"` // Expired gate pass this one replaces
+	PickupSlotID          *int       `json:"pickup_slot_id,omitempty" db:"pickup_slot_id"`     // Booked pickup slot
 }
 
 type CreateGatePassRequest struct {
@@ -43,12 +57,14 @@ type CreateGatePassRequest struct {
 }
 
 type UpdateGatePassRequest struct {
-	ApprovedQuantity int     `json:"approved_quantity"`
-	GateNo           string  `json:"gate_no"`
-	Status           string  `json:"status"`
-	RequestSource    string  `json:"request_source,omitempty"`
-	Remarks          string  `json:"remarks"`
-	ExpiresAt        *string `json:"expires_at,omitempty"` // Custom expiration datetime (ISO format)
+	ApprovedQuantity    int     `json:"approved_quantity"`
+	GateNo              string  `json:"gate_no"`
+	Status              string  `json:"status"`
+	RequestSource       string  `json:"request_source,omitempty"`
+	Remarks             string  `json:"remarks"`
+	ExpiresAt           *string `json:"expires_at,omitempty"`     // Custom expiration datetime (ISO format)
+	PickupSlotID        *int    `json:"pickup_slot_id,omitempty"` // Books or moves the pickup slot
+	ApprovalCredentials         // Password and 2FA code when the approval policy asks for them
 }
 
 type RecordPickupRequest struct {
@@ -58,6 +74,8 @@ type RecordPickupRequest struct {
 	Floor           string           `json:"floor"`
 	Remarks         string           `json:"remarks"`
 	GatarBreakdown  []GatarBreakdown `json:"gatar_breakdown,omitempty"`
+	WeighmentID     *int             `json:"weighment_id,omitempty"`     // Outbound weighment of the collecting truck
+	VehicleVisitID  *int             `json:"vehicle_visit_id,omitempty"` // Collecting vehicle; defaults to the one inside on this gate pass
 }
 
 // CreateCustomerGatePassRequest represents a customer's gate pass request
@@ -67,4 +85,69 @@ type CreateCustomerGatePassRequest struct {
 	FamilyMemberName  string `json:"family_member_name"`
 	RequestedQuantity int    `json:"requested_quantity" binding:"required"`
 	Remarks           string `json:"remarks"`
+	PickupSlotID      *int   `json:"pickup_slot_id"` // Optional pickup slot
+}
+
+// GatePassTransition is one status change of a gate pass. FromStatus is empty for the
+// request that created it; ChangedByUserID is nil for customer requests and automatic expiry.
+type GatePassTransition struct {
+	ID              int       `json:"id" db:"id"`
+	GatePassID      int       `json:"gate_pass_id" db:"gate_pass_id"`
+	FromStatus      string    `json:"from_status" db:"from_status"`
+	ToStatus        string    `json:"to_status" db:"to_status"`
+	Reason          string    `json:"reason" db:"reason"`
+	ChangedByUserID *int      `json:"changed_by_user_id,omitempty" db:"changed_by_user_id"`
+	ChangedByName   string    `json:"changed_by_name,omitempty" db:"changed_by_name"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// CancelGatePassRequest cancels an open gate pass
+type CancelGatePassRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Gate pass amendment statuses
+const (
+	GatePassAmendmentPending  = "pending"
+	GatePassAmendmentApproved = "approved"
+	GatePassAmendmentRejected = "rejected"
+)
+
+// GatePassAmendment is a change to the quantity or gate of an approved gate pass. It takes
+// effect once someone other than the requester (or an admin) approves it.
+type GatePassAmendment struct {
+	ID                int        `json:"id" db:"id"`
+	GatePassID        int        `json:"gate_pass_id" db:"gate_pass_id"`
+	OldQuantity       int        `json:"old_quantity" db:"old_quantity"`
+	NewQuantity       int        `json:"new_quantity" db:"new_quantity"`
+	OldGateNo         string     `json:"old_gate_no" db:"old_gate_no"`
+	NewGateNo         string     `json:"new_gate_no" db:"new_gate_no"`
+	Reason            string     `json:"reason" db:"reason"`
+	Status            string     `json:"status" db:"status"`
+	RequestedByUserID *int       `json:"requested_by_user_id,omitempty" db:"requested_by_user_id"`
+	RequestedByName   string     `json:"requested_by_name,omitempty" db:"requested_by_name"`
+	DecidedByUserID   *int       `json:"decided_by_user_id,omitempty" db:"decided_by_user_id"`
+	DecidedByName     string     `json:"decided_by_name,omitempty" db:"decided_by_name"`
+	DecisionRemarks   string     `json:"decision_remarks" db:"decision_remarks"`
+	DecidedAt         *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	ThockNumber       string     `json:"thock_number,omitempty" db:"thock_number"` // Listing only
+}
+
+// AmendGatePassRequest asks to change an approved gate pass
+type AmendGatePassRequest struct {
+	ApprovedQuantity int    `json:"approved_quantity"`
+	GateNo           string `json:"gate_no"` // Empty keeps the current gate
+	Reason           string `json:"reason"`
+}
+
+// DecideAmendmentRequest carries the approver's remarks on an amendment
+type DecideAmendmentRequest struct {
+	Remarks string `json:"remarks"`
+}
+
+// ReissueGatePassRequest issues a new gate pass for what an expired one did not deliver
+type ReissueGatePassRequest struct {
+	Quantity int    `json:"quantity"` // 0 re-issues all bags not picked up
+	Remarks  string `json:"remarks"`
 }
@@ -20,6 +20,7 @@ type GatePassPickup struct {
 	RoomNo             *string          `json:"room_no,omitempty" db:"room_no"`
 	Floor              *string          `json:"floor,omitempty" db:"floor"`
 	Remarks            *string          `json:"remarks,omitempty" db:"remarks"`
+	VehicleVisitID     *int             `json:"vehicle_visit_id,omitempty" db:"vehicle_visit_id"`
 	CreatedAt          time.Time        `json:"created_at" db:"created_at"`
 	PickedUpByUserName string           `json:"picked_up_by_user_name,omitempty" db:"picked_up_by_user_name"`
 	GatarBreakdown     []GatarBreakdown `json:"gatar_breakdown,omitempty"`
@@ -54,6 +54,7 @@ type CreateGuardEntryRequest struct {
 	Village        string `json:"village"`
 	Mobile         string `json:"mobile"`
 	DriverNo       string `json:"driver_no"`
+	VehicleNo      string `json:"vehicle_no"`    // Books the vehicle into the vehicle register (optional)
 	SeedQuantity   int    `json:"seed_quantity"` // Number of seed bags
 	SellQuantity   int    `json:"sell_quantity"` // Number of sell bags
 	SeedQty1       int    `json:"seed_qty_1"`    // Individual seed quantity 1
@@ -2,17 +2,48 @@ package models
 
 import "time"
 
+// Invoice document types
+const (
+	InvoiceTypeTaxInvoice = "TAX_INVOICE"
+	InvoiceTypeCreditNote = "CREDIT_NOTE" // Issued when a tax invoice is cancelled
+)
+
+// Invoice statuses
+const (
+	InvoiceStatusIssued    = "issued"
+	InvoiceStatusCancelled = "cancelled"
+)
+
 // Invoice represents a generated invoice
 type Invoice struct {
 	ID            int       `json:"id"`
 	InvoiceNumber string    `json:"invoice_number"`
 	CustomerID    *int      `json:"customer_id"`
 	EmployeeID    *int      `json:"employee_id"`
-	TotalAmount   float64   `json:"total_amount"`
+	TotalAmount   float64   `json:"total_amount"` // Taxable amount + GST
 	ItemsCount    int       `json:"items_count"`
 	Notes         string    `json:"notes"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+
+	// GST details (computed on the server)
+	DocumentType      string     `json:"document_type"`   // TAX_INVOICE or CREDIT_NOTE
+	FinancialYear     string     `json:"financial_year"`  // e.g. 2026-27
+	SequenceNumber    int        `json:"sequence_number"` // Gapless within document type and financial year
+	Status            string     `json:"status"`          // issued, cancelled
+	CompanyGSTIN      string     `json:"company_gstin"`
+	CustomerGSTIN     string     `json:"customer_gstin"`
+	PlaceOfSupply     string     `json:"place_of_supply"` // 2-digit GST state code
+	TaxableAmount     float64    `json:"taxable_amount"`
+	CGSTRate          float64    `json:"cgst_rate"`
+	CGSTAmount        float64    `json:"cgst_amount"`
+	SGSTRate          float64    `json:"sgst_rate"`
+	SGSTAmount        float64    `json:"sgst_amount"`
+	IGSTRate          float64    `json:"igst_rate"`
+	IGSTAmount        float64    `json:"igst_amount"`
+	OriginalInvoiceID *int       `json:"original_invoice_id,omitempty"` // Invoice a credit note reverses
+	CancelReason      string     `json:"cancel_reason,omitempty"`
+	CancelledAt       *time.Time `json:"cancelled_at,omitempty"`
 }
 
 // InvoiceItem represents an item included in an invoice
@@ -21,25 +52,48 @@ type InvoiceItem struct {
 	InvoiceID   int       `json:"invoice_id"`
 	EntryID     *int      `json:"entry_id"`
 	ThockNumber string    `json:"thock_number"`
+	Description string    `json:"description"`
+	HSNSAC      string    `json:"hsn_sac"`
 	Quantity    int       `json:"quantity"`
 	Rate        float64   `json:"rate"`
-	Amount      float64   `json:"amount"`
+	Amount      float64   `json:"amount"` // Taxable value (quantity x rate)
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-// CreateInvoiceRequest represents the request to create an invoice
+// CreateInvoiceRequest represents the request to create an invoice.
+// Rates, tax and totals are computed on the server; TotalAmount is only used when there are no items.
 type CreateInvoiceRequest struct {
-	CustomerID  int            `json:"customer_id"`
-	EmployeeID  int            `json:"employee_id"`
-	TotalAmount float64        `json:"total_amount"`
-	Notes       string         `json:"notes"`
-	Items       []InvoiceItem  `json:"items"`
+	CustomerID  int           `json:"customer_id"`
+	EmployeeID  int           `json:"employee_id"`
+	TotalAmount float64       `json:"total_amount"`
+	Notes       string        `json:"notes"`
+	Items       []InvoiceItem `json:"items"`
+}
+
+// CancelInvoiceRequest cancels a tax invoice by issuing a credit note
+type CancelInvoiceRequest struct {
+	Reason string `json:"reason"`
 }
 
 // InvoiceWithDetails includes customer and employee details
 type InvoiceWithDetails struct {
 	Invoice
-	CustomerName string         `json:"customer_name"`
-	EmployeeName string         `json:"employee_name"`
-	Items        []InvoiceItem  `json:"items"`
+	CustomerName    string        `json:"customer_name"`
+	CustomerPhone   string        `json:"customer_phone"`
+	CustomerSO      string        `json:"customer_so"`
+	CustomerVillage string        `json:"customer_village"`
+	CustomerAddress string        `json:"customer_address"`
+	EmployeeName    string        `json:"employee_name"`
+	OriginalNumber  string        `json:"original_invoice_number,omitempty"` // For credit notes
+	Items           []InvoiceItem `json:"items"`
+}
+
+// InvoiceCompany holds the supplier details printed on tax invoices (from system settings)
+type InvoiceCompany struct {
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+	GSTIN     string  `json:"gstin"`
+	StateCode string  `json:"state_code"`
+	SACCode   string  `json:"sac_code"`
+	GSTRate   float64 `json:"gst_rate"` // Percent
 }
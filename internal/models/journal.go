@@ -0,0 +1,134 @@
+package models
+
+import "time"
+
+// Chart of accounts codes (seeded by migrations 034, 039 and 040)
+const (
+	AccountCodeCash             = "1000" // Cash in Hand
+	AccountCodeBankRazorpay     = "1010" // Bank (Razorpay)
+	AccountCodeBank             = "1020" // Bank Account (deposits and transfers from bank reconciliation)
+	AccountCodeReceivables      = "1100" // Customer Receivables
+	AccountCodeRefunds          = "2100" // Refunds
+	AccountCodeRentIncome       = "4000" // Rent Income
+	AccountCodeInterestIncome   = "4100" // Interest Income
+	AccountCodeDiscountsAllowed = "5000" // Discounts Allowed
+)
+
+// LedgerReferenceRentAdjustment is the reference type of interest and discounts posted by
+// rent adjustment policies
+const LedgerReferenceRentAdjustment = "rent_adjustment_policy"
+
+// LedgerReferenceRentAccrual is the reference type of rent charged and reversed by the
+// accrual job (the reference ID is the entry)
+const LedgerReferenceRentAccrual = "entry"
+
+// LedgerReferenceBankStatementLine is the reference type of receipts posted from bank statement credits
+const LedgerReferenceBankStatementLine = "bank_statement_line"
+
+// JournalAccounts returns the accounts a ledger entry debits and credits. The journal and
+// the accounting export both post through it, so the two never disagree. ok is false for
+// entry types without an amount (DEBT_APPROVAL).
+func JournalAccounts(entryType LedgerEntryType, referenceType string) (debit, credit string, ok bool) {
+	switch entryType {
+	case LedgerEntryTypeCharge:
+		if referenceType == LedgerReferenceRentAdjustment {
+			return AccountCodeReceivables, AccountCodeInterestIncome, true
+		}
+		return AccountCodeReceivables, AccountCodeRentIncome, true
+	case LedgerEntryTypeRefund:
+		return AccountCodeReceivables, AccountCodeRefunds, true
+	case LedgerEntryTypePayment:
+		return AccountCodeCash, AccountCodeReceivables, true
+	case LedgerEntryTypeOnlinePayment:
+		if referenceType == LedgerReferenceBankStatementLine {
+			return AccountCodeBank, AccountCodeReceivables, true
+		}
+		return AccountCodeBankRazorpay, AccountCodeReceivables, true
+	case LedgerEntryTypeCredit:
+		if referenceType == LedgerReferenceRentAccrual {
+			return AccountCodeRentIncome, AccountCodeReceivables, true
+		}
+		return AccountCodeDiscountsAllowed, AccountCodeReceivables, true
+	}
+	return "", "", false
+}
+
+// LedgerAccount is an account in the chart of accounts
+type LedgerAccount struct {
+	ID          int       `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	AccountType string    `json:"account_type"` // asset, liability, equity, income, expense
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// JournalEntry is the balanced double-entry posting for one ledger entry
+type JournalEntry struct {
+	ID            int           `json:"id"`
+	LedgerEntryID int           `json:"ledger_entry_id"`
+	PostedAt      time.Time     `json:"posted_at"`
+	Narration     string        `json:"narration"`
+	CustomerPhone string        `json:"customer_phone,omitempty"`
+	EntryType     string        `json:"entry_type"`
+	Lines         []JournalLine `json:"lines"`
+}
+
+// JournalLine is one debit or credit line of a journal entry
+type JournalLine struct {
+	AccountCode string  `json:"account_code"`
+	AccountName string  `json:"account_name"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+}
+
+// TrialBalanceRow is one account's totals in a trial balance
+type TrialBalanceRow struct {
+	AccountCode   string  `json:"account_code"`
+	AccountName   string  `json:"account_name"`
+	AccountType   string  `json:"account_type"`
+	TotalDebit    float64 `json:"total_debit"`
+	TotalCredit   float64 `json:"total_credit"`
+	ClosingDebit  float64 `json:"closing_debit"`  // Net debit balance (0 if credit balance)
+	ClosingCredit float64 `json:"closing_credit"` // Net credit balance (0 if debit balance)
+}
+
+// TrialBalance lists closing balances of every account as of a date
+type TrialBalance struct {
+	AsOf        string            `json:"as_of"` // YYYY-MM-DD (inclusive)
+	Rows        []TrialBalanceRow `json:"rows"`
+	TotalDebit  float64           `json:"total_debit"`
+	TotalCredit float64           `json:"total_credit"`
+	Balanced    bool              `json:"balanced"`
+}
+
+// DayBook lists journal entries in a date range, optionally for one account
+// (account 1000 gives the cash book, 1010 the bank book)
+type DayBook struct {
+	From           string         `json:"from"` // YYYY-MM-DD (inclusive)
+	To             string         `json:"to"`   // YYYY-MM-DD (inclusive)
+	AccountCode    string         `json:"account_code,omitempty"`
+	OpeningBalance *float64       `json:"opening_balance,omitempty"` // Debit minus credit, only with account_code
+	ClosingBalance *float64       `json:"closing_balance,omitempty"`
+	Entries        []JournalEntry `json:"entries"`
+	TotalDebit     float64        `json:"total_debit"`
+	TotalCredit    float64        `json:"total_credit"`
+}
+
+// AccountAmount is an account with an amount (used in P&L)
+type AccountAmount struct {
+	AccountCode string  `json:"account_code"`
+	AccountName string  `json:"account_name"`
+	Amount      float64 `json:"amount"`
+}
+
+// ProfitAndLoss summarises income and expense accounts over a date range
+type ProfitAndLoss struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	Income        []AccountAmount `json:"income"`
+	Expenses      []AccountAmount `json:"expenses"`
+	TotalIncome   float64         `json:"total_income"`
+	TotalExpenses float64         `json:"total_expenses"`
+	NetProfit     float64         `json:"net_profit"`
+}
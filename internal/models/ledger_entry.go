@@ -17,6 +17,7 @@ const (
 // LedgerEntry represents a single entry in the accounting ledger
 type LedgerEntry struct {
 	ID               int             `json:"id"`
+	CustomerID       int             `json:"customer_id"` // FK to customers (0 if no customer record)
 	CustomerPhone    string          `json:"customer_phone"`
 	CustomerName     string          `json:"customer_name"`
 	CustomerSO       string          `json:"customer_so"` // S/O (Son Of / Father's Name)
@@ -33,10 +34,12 @@ type LedgerEntry struct {
 	CreatedByName    string          `json:"created_by_name"`
 	CreatedAt        time.Time       `json:"created_at"`
 	Notes            string          `json:"notes"`
+	IdempotencyKey   string          `json:"idempotency_key,omitempty"` // Set by automated postings (e.g. rent accrual)
 }
 
 // CreateLedgerEntryRequest is used when creating a new ledger entry
 type CreateLedgerEntryRequest struct {
+	CustomerID       int             `json:"customer_id"` // Optional, resolved from customer_phone when 0
 	CustomerPhone    string          `json:"customer_phone" validate:"required"`
 	CustomerName     string          `json:"customer_name" validate:"required"`
 	CustomerSO       string          `json:"customer_so"`
@@ -50,10 +53,12 @@ type CreateLedgerEntryRequest struct {
 	FamilyMemberName string          `json:"family_member_name"`
 	CreatedByUserID  int             `json:"created_by_user_id" validate:"required"`
 	Notes            string          `json:"notes"`
+	IdempotencyKey   string          `json:"-"` // Duplicate keys are rejected, so re-runs never double-post
 }
 
 // LedgerSummary provides summary statistics for a customer
 type LedgerSummary struct {
+	CustomerID     int     `json:"customer_id"`
 	CustomerPhone  string  `json:"customer_phone"`
 	CustomerName   string  `json:"customer_name"`
 	CustomerSO     string  `json:"customer_so"`
@@ -65,6 +70,7 @@ type LedgerSummary struct {
 
 // LedgerFilter is used for filtering ledger entries
 type LedgerFilter struct {
+	CustomerID    int             `json:"customer_id"`
 	CustomerPhone string          `json:"customer_phone"`
 	EntryType     LedgerEntryType `json:"entry_type"`
 	StartDate     *time.Time      `json:"start_date"`
@@ -77,6 +83,7 @@ type LedgerFilter struct {
 type AuditEntry struct {
 	ID               int             `json:"id"`
 	Date             time.Time       `json:"date"`
+	CustomerID       int             `json:"customer_id"`
 	CustomerPhone    string          `json:"customer_phone"`
 	CustomerName     string          `json:"customer_name"`
 	CustomerSO       string          `json:"customer_so"`
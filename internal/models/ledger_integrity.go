@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Ledger integrity issue types
+const (
+	IntegrityIssueRunningBalance      = "running_balance"       // Stored running_balance differs from recomputed value
+	IntegrityIssueMissingPaymentEntry = "missing_payment_entry" // Rent payment without a ledger credit
+	IntegrityIssuePaymentAmount       = "payment_amount"        // Ledger credit differs from rent payment amount
+	IntegrityIssueMissingOnlineEntry  = "missing_online_entry"  // Successful online transaction without a ledger credit
+	IntegrityIssueOnlineAmount        = "online_amount"         // Ledger credit differs from online transaction amount
+	IntegrityIssueMissingDebtApproval = "missing_debt_approval" // Used debt request without a DEBT_APPROVAL entry
+	IntegrityIssueDebtApprovalAmount  = "debt_approval_amount"  // DEBT_APPROVAL entry that moves money
+)
+
+// LedgerIntegrityIssue is one mismatch found by the ledger verifier
+type LedgerIntegrityIssue struct {
+	Type           string  `json:"type"`
+	CustomerID     int     `json:"customer_id"`
+	CustomerPhone  string  `json:"customer_phone"`
+	CustomerName   string  `json:"customer_name"`
+	LedgerEntryIDs []int   `json:"ledger_entry_ids,omitempty"` // Offending ledger rows
+	SourceType     string  `json:"source_type,omitempty"`      // rent_payment, online_transaction, debt_request
+	SourceID       *int    `json:"source_id,omitempty"`
+	Expected       float64 `json:"expected"`
+	Actual         float64 `json:"actual"`
+	Repairable     bool    `json:"repairable"` // Only running balances are repaired automatically
+	Message        string  `json:"message"`
+}
+
+// LedgerIntegrityReport is the result of verifying (and optionally repairing) the ledger
+type LedgerIntegrityReport struct {
+	CheckedAt        time.Time              `json:"checked_at"`
+	CustomersChecked int                    `json:"customers_checked"`
+	EntriesChecked   int                    `json:"entries_checked"`
+	Issues           []LedgerIntegrityIssue `json:"issues"`
+	Repaired         bool                   `json:"repaired"`
+	RowsRepaired     int64                  `json:"rows_repaired"`
+}
+
+// RunningBalanceRow is a ledger row with its stored and recomputed running balance
+type RunningBalanceRow struct {
+	ID              int
+	CustomerID      int
+	CustomerPhone   string
+	CustomerName    string
+	RunningBalance  float64
+	ExpectedBalance float64
+}
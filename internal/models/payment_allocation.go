@@ -0,0 +1,73 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// PaymentAllocation is the part of a rent payment applied to one entry (thock)
+type PaymentAllocation struct {
+	ID                int       `json:"id"`
+	RentPaymentID     int       `json:"rent_payment_id"`
+	ReceiptNumber     string    `json:"receipt_number,omitempty"` // Joined from rent_payments
+	EntryID           int       `json:"entry_id"`
+	CustomerID        int       `json:"customer_id"`
+	ThockNumber       string    `json:"thock_number"`
+	FamilyMemberID    *int      `json:"family_member_id,omitempty"`
+	Amount            float64   `json:"amount"`
+	AllocatedByUserID int       `json:"allocated_by_user_id"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// PaymentAllocationInput splits part of a payment onto one entry
+type PaymentAllocationInput struct {
+	EntryID int     `json:"entry_id"`
+	Amount  float64 `json:"amount"`
+}
+
+// UpdatePaymentAllocationsRequest replaces the allocations of a payment
+type UpdatePaymentAllocationsRequest struct {
+	Allocations []PaymentAllocationInput `json:"allocations"`
+}
+
+// ThockOutstanding is the rent still due on one entry (thock)
+type ThockOutstanding struct {
+	EntryID          int       `json:"entry_id"`
+	ThockNumber      string    `json:"thock_number"`
+	ThockCategory    string    `json:"thock_category"`
+	FamilyMemberID   *int      `json:"family_member_id,omitempty"`
+	FamilyMemberName string    `json:"family_member_name,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	Charged          float64   `json:"charged"`          // Rent posted to the ledger for this thock
+	Allocated        float64   `json:"allocated"`        // Paid through payment allocations
+	UnallocatedPaid  float64   `json:"unallocated_paid"` // Share of unallocated credit applied oldest-first
+	Outstanding      float64   `json:"outstanding"`
+	Unaccrued        float64   `json:"unaccrued"` // Rent due by the rate card that accrual has not posted yet
+}
+
+// CustomerOutstanding breaks a customer's balance down per thock
+type CustomerOutstanding struct {
+	CustomerID        int                `json:"customer_id"`
+	CustomerPhone     string             `json:"customer_phone"`
+	Thocks            []ThockOutstanding `json:"thocks"`
+	OtherOutstanding  float64            `json:"other_outstanding"`  // Charges not tied to a current thock (earlier seasons, manual debits)
+	UnallocatedCredit float64            `json:"unallocated_credit"` // Credit left after every charge is covered
+	TotalOutstanding  float64            `json:"total_outstanding"`
+}
+
+// DueForRelease is what must be paid before the thock's bags can leave: its outstanding and
+// unaccrued rent plus charges not tied to a current thock, less credit left over
+func (c *CustomerOutstanding) DueForRelease(thock *ThockOutstanding) float64 {
+	due := thock.Outstanding + thock.Unaccrued + c.OtherOutstanding - c.UnallocatedCredit
+	return math.Round(max(due, 0)*100) / 100
+}
+
+// Thock returns the outstanding of the given thock number, or nil if it is not a current thock
+func (c *CustomerOutstanding) Thock(thockNumber string) *ThockOutstanding {
+	for i := range c.Thocks {
+		if c.Thocks[i].ThockNumber == thockNumber {
+			return &c.Thocks[i]
+		}
+	}
+	return nil
+}
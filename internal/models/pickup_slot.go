@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// PickupSlot is a time window at a loading gate that gate passes are booked into.
+// A capacity of 0 means no limit.
+type PickupSlot struct {
+	ID              int       `json:"id"`
+	SlotDate        string    `json:"slot_date"`  // YYYY-MM-DD
+	Gate            string    `json:"gate"`       // Loading gate, not the gatar numbers of a gate pass
+	StartTime       string    `json:"start_time"` // HH:MM
+	EndTime         string    `json:"end_time"`   // HH:MM
+	CapacityBags    int       `json:"capacity_bags"`
+	CapacityTrucks  int       `json:"capacity_trucks"`
+	IsActive        bool      `json:"is_active"`
+	BookedBags      int       `json:"booked_bags"`
+	BookedTrucks    int       `json:"booked_trucks"`
+	Available       bool      `json:"available"` // Active, not over and not full
+	CreatedByUserID *int      `json:"created_by_user_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// CreatePickupSlotRequest opens one slot
+type CreatePickupSlotRequest struct {
+	SlotDate       string `json:"slot_date"`
+	Gate           string `json:"gate"`
+	StartTime      string `json:"start_time"`
+	EndTime        string `json:"end_time"`
+	CapacityBags   int    `json:"capacity_bags"`
+	CapacityTrucks int    `json:"capacity_trucks"`
+}
+
+// GeneratePickupSlotsRequest opens back-to-back slots for each gate on each day of a range.
+// Slots that already exist are left as they are.
+type GeneratePickupSlotsRequest struct {
+	FromDate       string   `json:"from_date"`
+	ToDate         string   `json:"to_date"`
+	Gates          []string `json:"gates"`
+	DayStart       string   `json:"day_start"` // HH:MM
+	DayEnd         string   `json:"day_end"`   // HH:MM
+	SlotMinutes    int      `json:"slot_minutes"`
+	CapacityBags   int      `json:"capacity_bags"`
+	CapacityTrucks int      `json:"capacity_trucks"`
+}
+
+// UpdatePickupSlotRequest changes a slot's capacity or closes it for new bookings
+type UpdatePickupSlotRequest struct {
+	CapacityBags   *int  `json:"capacity_bags"`
+	CapacityTrucks *int  `json:"capacity_trucks"`
+	IsActive       *bool `json:"is_active"`
+}
+
+// SetPickupSlotRequest books a gate pass into a slot, or clears its slot when nil
+type SetPickupSlotRequest struct {
+	PickupSlotID *int `json:"pickup_slot_id"`
+}
+
+// Where a queued gate pass stands against its slot's window
+const (
+	PickupSlotUpcoming = "upcoming"
+	PickupSlotNow      = "now"
+	PickupSlotLate     = "late"
+)
+
+// PickupQueueEntry is one gate pass waiting at a loading gate
+type PickupQueueEntry struct {
+	Position     int    `json:"position"`
+	GatePassID   int    `json:"gate_pass_id"`
+	ThockNumber  string `json:"thock_number"`
+	CustomerName string `json:"customer_name"`
+	Status       string `json:"status"`
+	Quantity     int    `json:"quantity"` // Approved bags, or requested until approved
+	PickedUp     int    `json:"picked_up"`
+	Remaining    int    `json:"remaining"`
+	SlotID       int    `json:"slot_id"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	SlotState    string `json:"slot_state"`
+	Arrived      bool   `json:"arrived"` // A vehicle collecting on it is inside
+}
+
+// PickupQueue is a loading gate's queue for one day, in slot order
+type PickupQueue struct {
+	Gate    string              `json:"gate"`
+	Date    string              `json:"date"`
+	Entries []*PickupQueueEntry `json:"entries"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Printer kinds
+const (
+	PrinterKindLabel   = "label"
+	PrinterKindReceipt = "receipt"
+)
+
+// Print job statuses
+const (
+	PrintJobQueued    = "queued"
+	PrintJobPrinting  = "printing"
+	PrintJobPrinted   = "printed"
+	PrintJobFailed    = "failed" // Gave up after max_attempts
+	PrintJobCancelled = "cancelled"
+)
+
+// Printer is a label or receipt printer at a counter
+type Printer struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Counter       string    `json:"counter"` // "" = default for counters without their own printer
+	Kind          string    `json:"kind"`
+	Protocol      string    `json:"protocol"` // zpl, tspl, escpos, bridge or fake
+	Address       string    `json:"address"`
+	LabelWidthMM  int       `json:"label_width_mm"`
+	LabelHeightMM int       `json:"label_height_mm"`
+	LabelColumns  int       `json:"label_columns"` // Labels side by side on the stock
+	DPI           int       `json:"dpi"`
+	CodeType      string    `json:"code_type"` // qr, code128 or none
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PrintJob is one document queued for a printer
+type PrintJob struct {
+	ID              int        `json:"id"`
+	PrinterID       int        `json:"printer_id"`
+	PrinterName     string     `json:"printer_name,omitempty"`
+	JobType         string     `json:"job_type"`
+	Reference       string     `json:"reference"`
+	Copies          int        `json:"copies"`
+	Payload         []byte     `json:"-"`
+	Status          string     `json:"status"`
+	Attempts        int        `json:"attempts"`
+	MaxAttempts     int        `json:"max_attempts"`
+	LastError       string     `json:"last_error"`
+	NextAttemptAt   time.Time  `json:"next_attempt_at"`
+	CreatedByUserID *int       `json:"created_by_user_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	PrintedAt       *time.Time `json:"printed_at,omitempty"`
+}
+
+// PrintLabelRequest prints thock labels at a counter's label printer
+type PrintLabelRequest struct {
+	Counter   string `json:"counter"`
+	PrinterID int    `json:"printer_id"` // Overrides the counter's printer
+	Line1     string `json:"line1"`      // Thock number
+	Line2     string `json:"line2"`      // Customer name
+	Copies    int    `json:"copies"`
+}
+
+// ReceiptLine is one row of a receipt
+type ReceiptLine struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	Bold  bool   `json:"bold"`
+}
+
+// PrintReceiptRequest prints a receipt at a counter's receipt printer: HTML for the
+// print bridge, or title and lines for ESC/POS printers
+type PrintReceiptRequest struct {
+	Counter   string        `json:"counter"`
+	PrinterID int           `json:"printer_id"`
+	Reference string        `json:"reference"`
+	HTML      string        `json:"html"`
+	Title     string        `json:"title"`
+	Lines     []ReceiptLine `json:"lines"`
+	Code      string        `json:"code"` // Printed as a QR code
+	Footer    string        `json:"footer"`
+}
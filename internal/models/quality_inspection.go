@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// Inspection conditions
+const (
+	InspectionConditionGood          = "good"
+	InspectionConditionFair          = "fair"          // Some sprouting or rot, inspect again soon
+	InspectionConditionDeteriorating = "deteriorating" // Owner should withdraw early
+)
+
+// QualityInspection is one periodic check of a thock, or of one gatar of a thock
+type QualityInspection struct {
+	ID                    int               `json:"id"`
+	RoomEntryID           int               `json:"room_entry_id"`
+	ThockNumber           string            `json:"thock_number"`
+	RoomNo                string            `json:"room_no"`
+	Floor                 string            `json:"floor"`
+	GatarNo               *int              `json:"gatar_no,omitempty"`
+	InspectedOn           time.Time         `json:"inspected_on"`
+	InspectorUserID       *int              `json:"inspector_user_id,omitempty"`
+	InspectorName         string            `json:"inspector_name"`
+	SproutingPct          float64           `json:"sprouting_pct"`
+	RotPct                float64           `json:"rot_pct"`
+	SampleBags            int               `json:"sample_bags"`
+	SampleWeightKg        float64           `json:"sample_weight_kg"`
+	AvgBagWeightKg        float64           `json:"avg_bag_weight_kg"` // sample_weight_kg / sample_bags
+	Condition             string            `json:"condition"`
+	Notes                 string            `json:"notes"`
+	OwnerNotifiedAt       *time.Time        `json:"owner_notified_at,omitempty"`
+	OwnerNotifiedByUserID *int              `json:"owner_notified_by_user_id,omitempty"`
+	CreatedAt             time.Time         `json:"created_at"`
+	CustomerName          string            `json:"customer_name,omitempty"` // From joined entries table
+	Photos                []*RoomEntryMedia `json:"photos,omitempty"`
+}
+
+// QualityInspectionRequest records an inspection. GatarNo 0 inspects the whole thock.
+// Condition is worked out from the rot/sprouting alert settings when left empty.
+type QualityInspectionRequest struct {
+	ThockNumber    string                 `json:"thock_number"`
+	GatarNo        int                    `json:"gatar_no"`
+	InspectedOn    string                 `json:"inspected_on"` // YYYY-MM-DD, default today
+	InspectorName  string                 `json:"inspector_name"`
+	SproutingPct   float64                `json:"sprouting_pct"`
+	RotPct         float64                `json:"rot_pct"`
+	SampleBags     int                    `json:"sample_bags"`
+	SampleWeightKg float64                `json:"sample_weight_kg"`
+	Condition      string                 `json:"condition"`
+	Notes          string                 `json:"notes"`
+	Photos         []InspectionPhotoInput `json:"photos,omitempty"`
+}
+
+// InspectionPhotoInput is a photo already uploaded to media storage
+type InspectionPhotoInput struct {
+	FilePath string `json:"file_path"`
+	FileName string `json:"file_name"`
+	FileType string `json:"file_type"`
+	FileSize *int64 `json:"file_size,omitempty"`
+}
+
+// QualityAlert is a thock whose latest inspection found it deteriorating while bags remain in store
+type QualityAlert struct {
+	Inspection  *QualityInspection `json:"inspection"`
+	BagsInStock int                `json:"bags_in_stock"`
+	Phone       string             `json:"phone"`
+	Village     string             `json:"village"`
+}
+
+// StockAgingRow is a thock in store with its age and latest inspection
+type StockAgingRow struct {
+	ThockNumber         string     `json:"thock_number"`
+	CustomerName        string     `json:"customer_name"`
+	Variety             string     `json:"variety"`
+	Locations           string     `json:"locations"` // room/floor pairs holding the thock
+	Bags                int        `json:"bags"`
+	StoredSince         time.Time  `json:"stored_since"`
+	DaysInStorage       int        `json:"days_in_storage"`
+	LastInspectionID    *int       `json:"last_inspection_id,omitempty"`
+	LastInspectedOn     *time.Time `json:"last_inspected_on,omitempty"`
+	LastCondition       string     `json:"last_condition,omitempty"`
+	LastRotPct          *float64   `json:"last_rot_pct,omitempty"`
+	LastSproutingPct    *float64   `json:"last_sprouting_pct,omitempty"`
+	DaysSinceInspection *int       `json:"days_since_inspection,omitempty"`
+}
+
+// StockAgingBucket totals the thocks whose age falls in [MinDays, MaxDays]; MaxDays 0 is open-ended
+type StockAgingBucket struct {
+	Label   string `json:"label"`
+	MinDays int    `json:"min_days"`
+	MaxDays int    `json:"max_days"`
+	Thocks  int    `json:"thocks"`
+	Bags    int    `json:"bags"`
+}
+
+// StockAgingReport groups the stock in store by days in storage
+type StockAgingReport struct {
+	AsOf      time.Time           `json:"as_of"`
+	TotalBags int                 `json:"total_bags"`
+	Buckets   []*StockAgingBucket `json:"buckets"`
+	Rows      []*StockAgingRow    `json:"rows"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Rent accrual run triggers
+const (
+	RentAccrualTriggerScheduled   = "scheduled"
+	RentAccrualTriggerManual      = "manual"
+	RentAccrualTriggerBackfill    = "backfill"
+	RentAccrualTriggerSeasonClose = "season_close"
+)
+
+// RentAccrualRun records one pass of the rent accrual job
+type RentAccrualRun struct {
+	ID              int        `json:"id"`
+	Trigger         string     `json:"trigger"` // scheduled, manual, backfill, season_close
+	AsOf            time.Time  `json:"as_of"`
+	EntriesChecked  int        `json:"entries_checked"`
+	ChargesPosted   int        `json:"charges_posted"`
+	AmountPosted    float64    `json:"amount_posted"`
+	Status          string     `json:"status"` // running, completed, failed
+	ErrorMessage    string     `json:"error_message,omitempty"`
+	StartedByUserID *int       `json:"started_by_user_id,omitempty"`
+	StartedByName   string     `json:"started_by_name,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Rent adjustment policy types
+const (
+	RentAdjustmentInterest = "interest" // Late-payment interest, charged monthly on the outstanding
+	RentAdjustmentDiscount = "discount" // Early-payment discount, credited once when the thock is paid
+)
+
+// Rent adjustment run triggers
+const (
+	RentAdjustmentTriggerScheduled = "scheduled"
+	RentAdjustmentTriggerManual    = "manual"
+)
+
+// RentAdjustmentPolicy is an interest or discount rule evaluated against outstanding rent
+type RentAdjustmentPolicy struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	PolicyType      string     `json:"policy_type"`    // interest or discount
+	ThockCategory   string     `json:"thock_category"` // '', 'seed' or 'sell'
+	RatePercent     float64    `json:"rate_percent"`   // Per month for interest, once for discount
+	EffectiveFrom   time.Time  `json:"effective_from"` // Due date (interest) or window start (discount)
+	EffectiveTo     *time.Time `json:"effective_to"`   // nil = open ended
+	CapPercent      *float64   `json:"cap_percent"`    // Max total per thock as % of its rent
+	CapAmount       *float64   `json:"cap_amount"`     // Max total per thock in rupees
+	GraceDays       int        `json:"grace_days"`     // Interest: days after the due date before a thock is overdue
+	IsActive        bool       `json:"is_active"`
+	Notes           string     `json:"notes"`
+	CreatedByUserID *int       `json:"created_by_user_id,omitempty"`
+	CreatedByName   string     `json:"created_by_name,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// RentAdjustmentPolicyRequest creates or updates a policy
+type RentAdjustmentPolicyRequest struct {
+	Name          string   `json:"name"`
+	PolicyType    string   `json:"policy_type"`
+	ThockCategory string   `json:"thock_category"`
+	RatePercent   float64  `json:"rate_percent"`
+	EffectiveFrom string   `json:"effective_from"` // Format: YYYY-MM-DD
+	EffectiveTo   string   `json:"effective_to"`   // Format: YYYY-MM-DD, empty = open ended
+	CapPercent    *float64 `json:"cap_percent"`
+	CapAmount     *float64 `json:"cap_amount"`
+	GraceDays     int      `json:"grace_days"`
+	Notes         string   `json:"notes"`
+}
+
+// RentAdjustment is one interest charge or discount credit for a thock
+type RentAdjustment struct {
+	PolicyID       int     `json:"policy_id"`
+	PolicyName     string  `json:"policy_name"`
+	PolicyType     string  `json:"policy_type"`
+	CustomerID     int     `json:"customer_id"`
+	CustomerPhone  string  `json:"customer_phone"`
+	CustomerName   string  `json:"customer_name"`
+	CustomerSO     string  `json:"customer_so"`
+	EntryID        int     `json:"entry_id"`
+	ThockNumber    string  `json:"thock_number"`
+	Period         string  `json:"period,omitempty"` // Overdue month for interest (e.g. 2026-11)
+	Base           float64 `json:"base"`             // Outstanding at the start of the month (interest) or rent (discount) the rate applies to
+	Amount         float64 `json:"amount"`
+	Description    string  `json:"description"`
+	LedgerEntryID  *int    `json:"ledger_entry_id,omitempty"` // Set once posted
+	IdempotencyKey string  `json:"-"`
+}
+
+// RentAdjustmentRun is the result of evaluating the policies (preview or posting run)
+type RentAdjustmentRun struct {
+	ID              int              `json:"id,omitempty"` // Set once a posting run is recorded
+	Trigger         string           `json:"trigger,omitempty"`
+	AsOf            time.Time        `json:"as_of"`
+	Preview         bool             `json:"preview"`
+	PoliciesChecked int              `json:"policies_checked"`
+	ThocksChecked   int              `json:"thocks_checked"`
+	InterestTotal   float64          `json:"interest_total"`
+	DiscountTotal   float64          `json:"discount_total"`
+	Adjustments     []RentAdjustment `json:"adjustments"`
+}
@@ -8,6 +8,7 @@ type RentPayment struct {
 	EntryID            int       `json:"entry_id"`
 	FamilyMemberID     *int      `json:"family_member_id,omitempty"`
 	FamilyMemberName   string    `json:"family_member_name,omitempty"`
+	CustomerID         int       `json:"customer_id"` // FK to customers (0 if no customer record)
 	CustomerName       string    `json:"customer_name"`
 	CustomerPhone      string    `json:"customer_phone"`
 	TotalRent          float64   `json:"total_rent"`
@@ -18,6 +19,8 @@ type RentPayment struct {
 	ProcessedByName    string    `json:"processed_by_name,omitempty"` // Joined from users table
 	Notes              string    `json:"notes"`
 	CreatedAt          time.Time `json:"created_at"`
+
+	Allocations []PaymentAllocation `json:"allocations,omitempty"`
 }
 
 type CreateRentPaymentRequest struct {
@@ -31,4 +34,8 @@ type CreateRentPaymentRequest struct {
 	Balance          float64 `json:"balance"`
 	PaymentType      string  `json:"payment_type"` // "cash" or "online"
 	Notes            string  `json:"notes"`
+
+	// Allocations split the payment across thocks. When empty the payment is
+	// allocated oldest-first (EntryID's thock first).
+	Allocations []PaymentAllocationInput `json:"allocations,omitempty"`
 }
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Rent accrual modes for a rate card
+const (
+	RentAccrualOnStorage = "on_storage" // Charged when bags are stored, trued up at season close
+	RentAccrualMonthly   = "monthly"    // Rent to date charged at each month end
+)
+
+// RentTariff is a versioned rent rate card
+// The card with the latest effective_from on or before a thock's storage date prices that thock
+type RentTariff struct {
+	ID              int              `json:"id"`
+	Name            string           `json:"name"`
+	EffectiveFrom   time.Time        `json:"effective_from"`
+	IsActive        bool             `json:"is_active"`
+	AccrualMode     string           `json:"accrual_mode"` // on_storage or monthly
+	Notes           string           `json:"notes"`
+	CreatedByUserID *int             `json:"created_by_user_id,omitempty"`
+	CreatedByName   string           `json:"created_by_name,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	Rates           []RentTariffRate `json:"rates"`
+}
+
+// RentTariffRate is a single line on a rate card
+// Empty ThockCategory/Variety match any category/variety
+type RentTariffRate struct {
+	ID            int     `json:"id"`
+	TariffID      int     `json:"tariff_id"`
+	ThockCategory string  `json:"thock_category"` // '', 'seed' or 'sell'
+	Variety       string  `json:"variety"`        // '' or a variety from entries.remark (e.g. Chipsona 1)
+	MinMonths     int     `json:"min_months"`     // Storage-duration slab, inclusive
+	MaxMonths     *int    `json:"max_months"`     // nil = open ended
+	RatePerBag    float64 `json:"rate_per_bag"`
+}
+
+// CreateRentTariffRequest is used to publish a new rate card version
+type CreateRentTariffRequest struct {
+	Name          string           `json:"name"`
+	EffectiveFrom string           `json:"effective_from"` // Format: YYYY-MM-DD
+	AccrualMode   string           `json:"accrual_mode"`   // on_storage (default) or monthly
+	Notes         string           `json:"notes"`
+	Rates         []RentTariffRate `json:"rates"`
+}
+
+// RentQuote is the result of pricing a thock against the rate cards
+type RentQuote struct {
+	TariffID      int     `json:"tariff_id"`
+	TariffName    string  `json:"tariff_name"`
+	ThockCategory string  `json:"thock_category"`
+	Variety       string  `json:"variety"`
+	StorageMonths int     `json:"storage_months"`
+	RatePerBag    float64 `json:"rate_per_bag"`
+	Quantity      int     `json:"quantity"`
+	Amount        float64 `json:"amount"`
+}
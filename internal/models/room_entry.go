@@ -17,6 +17,7 @@ type RoomEntry struct {
 	UpdatedAt         time.Time         `json:"updated_at"`
 	Variety           string            `json:"variety"` // From joined entries table (entries.remark)
 	Gatars            []RoomEntryGatar  `json:"gatars,omitempty"`
+	CapacityWarnings  []string          `json:"capacity_warnings,omitempty"` // Overfilled gatars when the policy is "warn"
 }
 
 // RoomEntryGatar represents per-gatar quantity breakdown for a room entry
@@ -50,6 +51,7 @@ type CreateRoomEntryRequest struct {
 	QuantityBreakdown string       `json:"quantity_breakdown"`
 	Gatars            []GatarInput `json:"gatars,omitempty"` // Per-gatar quantity breakdown
 	LabelCount        int          `json:"label_count"`      // Number of labels to print (0 = no print)
+	Counter           string       `json:"counter"`          // Counter whose label printer prints them
 }
 
 type UpdateRoomEntryRequest struct {
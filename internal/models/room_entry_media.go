@@ -12,6 +12,7 @@ type RoomEntryMedia struct {
 	FileType         string    `json:"file_type"`
 	FileSize         *int64    `json:"file_size,omitempty"`
 	UploadedByUserID *int      `json:"uploaded_by_user_id,omitempty"`
+	InspectionID     *int      `json:"inspection_id,omitempty"` // Set for media_type "inspection"
 	CreatedAt        time.Time `json:"created_at"`
 
 	// Computed fields (from JOINs)
@@ -0,0 +1,52 @@
+package models
+
+// Scan result kinds
+const (
+	ScanKindThock    = "thock"
+	ScanKindGatePass = "gate_pass"
+)
+
+// Scan actions
+const (
+	ScanActionRoomEntry        = "room_entry"
+	ScanActionPickup           = "pickup"
+	ScanActionApproveGatePass  = "approve_gate_pass"
+	ScanActionCompleteGatePass = "complete_gate_pass"
+	ScanActionTransfer         = "transfer"
+	ScanActionInspect          = "inspect"
+	ScanActionPrintLabels      = "print_labels"
+)
+
+// ScanRequest carries the payload read by a scanner
+type ScanRequest struct {
+	Code string `json:"code"`
+}
+
+// ScanAction is something the scanning user may do next, with the API call that does it
+// and the fields the scanner can fill in
+type ScanAction struct {
+	Action      string                 `json:"action"`
+	Method      string                 `json:"method"`
+	Endpoint    string                 `json:"endpoint"`
+	Prefill     map[string]interface{} `json:"prefill,omitempty"`
+	MaxQuantity int                    `json:"max_quantity,omitempty"`
+}
+
+// ScanResult is what a scanned label or gate pass refers to
+type ScanResult struct {
+	Kind           string          `json:"kind"`
+	Season         string          `json:"season"`
+	ThockNumber    string          `json:"thock_number"`
+	Entry          *Entry          `json:"entry,omitempty"`
+	GatePass       *GatePass       `json:"gate_pass,omitempty"` // The scanned gate pass
+	OpenGatePasses []*GatePass     `json:"open_gate_passes"`    // Pending and approved gate passes of the thock
+	Locations      []*GatarBalance `json:"locations"`           // Gatars holding the thock's bags
+	StoredBags     int             `json:"stored_bags"`
+	Actions        []ScanAction    `json:"actions"`
+}
+
+// QRCodeResponse is a signed payload for printing
+type QRCodeResponse struct {
+	Payload string `json:"payload"`
+	PNG     string `json:"png"` // data: URL of the QR code image
+}
@@ -35,7 +35,8 @@ type InitiateSeasonRequest struct {
 
 // ApproveSeasonRequest is the request body for approving a season request
 type ApproveSeasonRequest struct {
-	Password string `json:"password"` // Approving admin must enter password
+	Password string `json:"password"`            // Approving admin must enter password
+	TOTPCode string `json:"totp_code,omitempty"` // When the approval policy asks for 2FA
 }
 
 // RejectSeasonRequest is the request body for rejecting a season request
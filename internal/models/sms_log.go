@@ -31,6 +31,9 @@ const (
 	SMSTypeBoli             = "boli"          // Buyer arrival notification
 	SMSTypeBoliRate         = "boli_rate"     // Rate update notification
 	SMSTypeBoliComplete     = "boli_complete" // Sale complete notification
+	SMSTypeSensorAlert      = "sensor_alert"  // Cold-room temperature/humidity page
+	SMSTypeQualityAlert     = "quality_alert" // Deteriorating stock, withdraw early
+	SMSTypeGatePass         = "gate_pass"     // Gate pass cancelled, amended or re-issued
 )
 
 // SMS status types
@@ -54,6 +57,8 @@ const (
 	SettingSMSPaymentReceived = "sms_notify_payment_received"
 	SettingSMSPaymentReminder = "sms_notify_payment_reminder"
 	SettingSMSPromotional     = "sms_allow_promotional"
+	SettingSMSQualityAlert    = "sms_notify_quality_alert"
+	SettingSMSGatePass        = "sms_notify_gate_pass"
 )
 
 // WhatsApp setting keys
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// StockTransfer is a move of a thock's bags from one gatar to another
+type StockTransfer struct {
+	ID               int       `json:"id"`
+	ThockNumber      string    `json:"thock_number"`
+	FromRoomNo       string    `json:"from_room_no"`
+	FromFloor        string    `json:"from_floor"`
+	FromGatar        int       `json:"from_gatar"`
+	ToRoomNo         string    `json:"to_room_no"`
+	ToFloor          string    `json:"to_floor"`
+	ToGatar          int       `json:"to_gatar"`
+	Quantity         int       `json:"quantity"`
+	Reason           string    `json:"reason"`
+	MovedByUserID    int       `json:"moved_by_user_id"`
+	MovedByName      string    `json:"moved_by_name,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	CapacityWarnings []string  `json:"capacity_warnings,omitempty"` // Overfilled gatar when the policy is "warn"
+}
+
+// StockTransferRequest moves bags of a thock between gatars
+type StockTransferRequest struct {
+	ThockNumber string `json:"thock_number"`
+	FromGatar   int    `json:"from_gatar"`
+	ToGatar     int    `json:"to_gatar"`
+	Quantity    int    `json:"quantity"`
+	Reason      string `json:"reason"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Thock number statuses
+const (
+	ThockNumberIssued   = "issued"   // Given to an entry
+	ThockNumberReserved = "reserved" // Held for a later entry
+	ThockNumberVoided   = "voided"   // Withdrawn with a reason; never given out
+	ThockNumberSkipped  = "skipped"  // Jumped over by a skip range
+)
+
+// ThockNumberSeries is the numbering of one category of thocks in a season
+type ThockNumberSeries struct {
+	Season      string    `json:"season"`
+	Category    string    `json:"category"`
+	StartNumber int       `json:"start_number"`
+	LastNumber  int       `json:"last_number"` // Highest number taken; start_number - 1 when unused
+	NextNumber  int       `json:"next_number"` // Next number an entry will get
+	Issued      int       `json:"issued"`
+	Reserved    int       `json:"reserved"`
+	Voided      int       `json:"voided"`
+	Skipped     int       `json:"skipped"`
+	Missing     int       `json:"missing"` // Numbers up to last_number with no record
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ThockNumberRecord is one number of a series that was issued, reserved, voided or skipped
+type ThockNumberRecord struct {
+	ID          int       `json:"id"`
+	Season      string    `json:"season"`
+	Category    string    `json:"category"`
+	Number      int       `json:"number"`
+	Status      string    `json:"status"`
+	EntryID     *int      `json:"entry_id,omitempty"`
+	ThockNumber string    `json:"thock_number"`
+	Reason      string    `json:"reason"`
+	UserID      *int      `json:"user_id,omitempty"`
+	UserName    string    `json:"user_name,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ReserveThockNumbersRequest holds numbers back for later entries: either Count next
+// numbers or one specific future Number
+type ReserveThockNumbersRequest struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+	Number   int    `json:"number"`
+	Reason   string `json:"reason"`
+}
+
+// VoidThockNumberRequest withdraws a number for good
+type VoidThockNumberRequest struct {
+	Category string `json:"category"`
+	Number   int    `json:"number"`
+	Reason   string `json:"reason"`
+}
+
+// StartThockSeasonRequest switches numbering to a new season. Zero starts use the defaults.
+type StartThockSeasonRequest struct {
+	Season    string `json:"season"`
+	SeedStart int    `json:"seed_start"`
+	SellStart int    `json:"sell_start"`
+}
+
+// ThockNumberReport lists the numbers of a season that did not go to an entry, for auditors
+type ThockNumberReport struct {
+	Season  string               `json:"season"`
+	Series  []*ThockNumberSeries `json:"series"`
+	Records []*ThockNumberRecord `json:"records"` // Reserved, voided and skipped numbers
+	Missing map[string][]int     `json:"missing"` // Per category: numbers taken before the log existed with no entry
+}
@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// Vehicle visit statuses
+const (
+	VehicleInside = "inside"
+	VehicleExited = "exited"
+)
+
+// Vehicle visit purposes
+const (
+	VehiclePurposeDelivery   = "delivery"   // Truck bringing bags in for storage
+	VehiclePurposeCollection = "collection" // Truck collecting bags on gate passes
+)
+
+// VehicleVisit is one truck's stay from the guard gate to its exit
+type VehicleVisit struct {
+	ID               int                 `json:"id"`
+	VehicleNo        string              `json:"vehicle_no"`
+	DriverName       string              `json:"driver_name"`
+	DriverPhone      string              `json:"driver_phone"`
+	Purpose          string              `json:"purpose"`
+	Status           string              `json:"status"`
+	GuardEntryID     *int                `json:"guard_entry_id,omitempty"`
+	InTime           time.Time           `json:"in_time"`
+	InByUserID       *int                `json:"in_by_user_id,omitempty"`
+	InByName         string              `json:"in_by_name"`
+	Remarks          string              `json:"remarks"`
+	OutTime          *time.Time          `json:"out_time,omitempty"`
+	OutByUserID      *int                `json:"out_by_user_id,omitempty"`
+	OutByName        string              `json:"out_by_name,omitempty"`
+	QuantityLoaded   *int                `json:"quantity_loaded,omitempty"`
+	ExpectedQuantity *int                `json:"expected_quantity,omitempty"` // Bags picked up on its gate passes, fixed at exit
+	QuantityMismatch bool                `json:"quantity_mismatch"`
+	ExitRemarks      string              `json:"exit_remarks"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	Links            []*VehicleVisitLink `json:"links"`
+	MinutesInside    int                 `json:"minutes_inside"`
+}
+
+// VehicleVisitLink ties a visit to an entry it delivered or a gate pass it collects on
+type VehicleVisitLink struct {
+	ID          int    `json:"id"`
+	VisitID     int    `json:"visit_id"`
+	EntryID     *int   `json:"entry_id,omitempty"`
+	GatePassID  *int   `json:"gate_pass_id,omitempty"`
+	ThockNumber string `json:"thock_number"`
+	Quantity    int    `json:"quantity"`            // Expected bags of the entry, or approved bags of the gate pass
+	PickedUp    int    `json:"picked_up,omitempty"` // Bags picked up on the gate pass during this visit
+	Status      string `json:"status,omitempty"`    // Gate pass status
+}
+
+// VehicleCheckInRequest books a truck in at the guard gate
+type VehicleCheckInRequest struct {
+	VehicleNo    string `json:"vehicle_no"`
+	DriverName   string `json:"driver_name"`
+	DriverPhone  string `json:"driver_phone"`
+	Purpose      string `json:"purpose"` // Defaults to collection with gate passes, delivery otherwise
+	GuardEntryID *int   `json:"guard_entry_id"`
+	EntryIDs     []int  `json:"entry_ids"`
+	GatePassIDs  []int  `json:"gate_pass_ids"`
+	Remarks      string `json:"remarks"`
+}
+
+// VehicleLinkRequest links an entry or a gate pass to a vehicle inside
+type VehicleLinkRequest struct {
+	EntryID    *int `json:"entry_id"`
+	GatePassID *int `json:"gate_pass_id"`
+}
+
+// VehicleExitRequest books a truck out with the bags counted on it
+type VehicleExitRequest struct {
+	QuantityLoaded int    `json:"quantity_loaded"`
+	Remarks        string `json:"remarks"`
+	AcceptMismatch bool   `json:"accept_mismatch"` // Let it leave although the count differs; remarks required
+}
+
+// VehicleExitCheck compares what a truck carries with what was picked up on its gate passes
+type VehicleExitCheck struct {
+	Visit            *VehicleVisit `json:"visit"`
+	ExpectedQuantity int           `json:"expected_quantity"`
+	QuantityLoaded   *int          `json:"quantity_loaded,omitempty"`
+	Matches          bool          `json:"matches"`
+	Warnings         []string      `json:"warnings"`
+}
+
+// VehiclesInside is the guard's dashboard of trucks currently in the yard
+type VehiclesInside struct {
+	Count       int             `json:"count"`
+	Delivering  int             `json:"delivering"`
+	Collecting  int             `json:"collecting"`
+	OverdueMins int             `json:"overdue_minutes"`
+	Overdue     int             `json:"overdue"` // Inside longer than OverdueMins
+	Vehicles    []*VehicleVisit `json:"vehicles"`
+}
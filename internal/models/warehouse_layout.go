@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// WarehouseRoom is a cold storage room (or the gallery) in the layout
+type WarehouseRoom struct {
+	ID        int              `json:"id"`
+	RoomNo    string           `json:"room_no"`
+	Name      string           `json:"name"`
+	SortOrder int              `json:"sort_order"`
+	IsActive  bool             `json:"is_active"`
+	Notes     string           `json:"notes"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Floors    []WarehouseFloor `json:"floors"`
+}
+
+// WarehouseFloor is a floor of a room with its gatar totals
+type WarehouseFloor struct {
+	ID        int    `json:"id"`
+	RoomID    int    `json:"room_id"`
+	RoomNo    string `json:"room_no"`
+	Floor     string `json:"floor"`
+	SortOrder int    `json:"sort_order"`
+
+	// Aggregated from warehouse_gatars
+	TotalGatars    int `json:"total_gatars"`
+	DisabledGatars int `json:"disabled_gatars"`
+	CapacityBags   int `json:"capacity_bags"` // Enabled gatars only
+}
+
+// WarehouseGatar is a single storage slot
+type WarehouseGatar struct {
+	ID             int       `json:"id"`
+	FloorID        int       `json:"floor_id"`
+	RoomNo         string    `json:"room_no"`
+	Floor          string    `json:"floor"`
+	GatarNo        int       `json:"gatar_no"`
+	CapacityBags   int       `json:"capacity_bags"`
+	LengthFt       *float64  `json:"length_ft,omitempty"`
+	WidthFt        *float64  `json:"width_ft,omitempty"`
+	HeightFt       *float64  `json:"height_ft,omitempty"`
+	IsDisabled     bool      `json:"is_disabled"`
+	DisabledReason string    `json:"disabled_reason"`
+	Notes          string    `json:"notes"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WarehouseRoomRequest creates or edits a room
+type WarehouseRoomRequest struct {
+	RoomNo    string `json:"room_no"` // Create only; room entries store it
+	Name      string `json:"name"`
+	SortOrder int    `json:"sort_order"`
+	IsActive  *bool  `json:"is_active,omitempty"`
+	Notes     string `json:"notes"`
+}
+
+// WarehouseFloorRequest adds a floor to a room
+type WarehouseFloorRequest struct {
+	Floor     string `json:"floor"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// AddWarehouseGatarsRequest adds a range of gatar numbers to a floor
+type AddWarehouseGatarsRequest struct {
+	FromGatar    int      `json:"from_gatar"`
+	ToGatar      int      `json:"to_gatar"`
+	CapacityBags int      `json:"capacity_bags"`
+	LengthFt     *float64 `json:"length_ft,omitempty"`
+	WidthFt      *float64 `json:"width_ft,omitempty"`
+	HeightFt     *float64 `json:"height_ft,omitempty"`
+}
+
+// UpdateWarehouseGatarsRequest edits a range of gatars on a floor; nil fields are left unchanged
+type UpdateWarehouseGatarsRequest struct {
+	FromGatar      int      `json:"from_gatar"`
+	ToGatar        int      `json:"to_gatar"`
+	CapacityBags   *int     `json:"capacity_bags,omitempty"`
+	LengthFt       *float64 `json:"length_ft,omitempty"`
+	WidthFt        *float64 `json:"width_ft,omitempty"`
+	HeightFt       *float64 `json:"height_ft,omitempty"`
+	IsDisabled     *bool    `json:"is_disabled,omitempty"`
+	DisabledReason *string  `json:"disabled_reason,omitempty"`
+	Notes          *string  `json:"notes,omitempty"`
+}
+
+// PlacementSuggestionRequest asks where to stack a number of bags
+type PlacementSuggestionRequest struct {
+	Quantity    int    `json:"quantity"`
+	RoomNo      string `json:"room_no,omitempty"` // Limit to a room
+	Floor       string `json:"floor,omitempty"`   // Limit to a floor of RoomNo
+	ThockNumber string `json:"thock_number,omitempty"`
+}
+
+// SuggestedGatar is one gatar of a suggested placement
+type SuggestedGatar struct {
+	GatarNo      int `json:"gatar_no"`
+	Quantity     int `json:"quantity"`
+	CapacityBags int `json:"capacity_bags"`
+	Occupied     int `json:"occupied"` // Bags already stored
+}
+
+// PlacementSuggestion is a contiguous run of gatars on one floor for a room entry
+type PlacementSuggestion struct {
+	Quantity          int              `json:"quantity"`
+	RoomNo            string           `json:"room_no"`
+	Floor             string           `json:"floor"`
+	Gatars            []SuggestedGatar `json:"gatars"`
+	GateNo            string           `json:"gate_no"`            // Ready for the room entry form
+	QuantityBreakdown string           `json:"quantity_breakdown"` // Ready for the room entry form
+	Unplaced          int              `json:"unplaced"`           // Bags that did not fit on the floor
+	Message           string           `json:"message,omitempty"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Weighment directions
+const (
+	WeighmentInbound  = "inbound"  // Loaded truck arriving at the guard gate
+	WeighmentOutbound = "outbound" // Truck collecting bags on a gate pass
+)
+
+// Weighment stages
+const (
+	WeighmentStageGross = "gross" // Truck with load
+	WeighmentStageTare  = "tare"  // Empty truck
+)
+
+// WeightSourceManual is a weight typed in by hand; other sources are weighbridge reader modes
+const WeightSourceManual = "manual"
+
+// Weighment is one weighbridge ticket: a truck weighed loaded (gross) and empty (tare)
+type Weighment struct {
+	ID               int        `json:"id"`
+	Direction        string     `json:"direction"`
+	GuardEntryID     *int       `json:"guard_entry_id,omitempty"`
+	GatePassID       *int       `json:"gate_pass_id,omitempty"`
+	GatePassPickupID *int       `json:"gate_pass_pickup_id,omitempty"`
+	ThockNumber      string     `json:"thock_number"`
+	VehicleNo        string     `json:"vehicle_no"`
+	BagCount         int        `json:"bag_count"`
+	GrossKg          *float64   `json:"gross_kg,omitempty"`
+	GrossSource      *string    `json:"gross_source,omitempty"`
+	GrossAt          *time.Time `json:"gross_at,omitempty"`
+	GrossByUserID    *int       `json:"gross_by_user_id,omitempty"`
+	TareKg           *float64   `json:"tare_kg,omitempty"`
+	TareSource       *string    `json:"tare_source,omitempty"`
+	TareAt           *time.Time `json:"tare_at,omitempty"`
+	TareByUserID     *int       `json:"tare_by_user_id,omitempty"`
+	NetKg            *float64   `json:"net_kg,omitempty"` // gross_kg - tare_kg, set once both are captured
+	AvgBagWeightKg   *float64   `json:"avg_bag_weight_kg,omitempty"`
+	Notes            string     `json:"notes"`
+	CreatedByUserID  *int       `json:"created_by_user_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// CreateWeighmentRequest opens a weighment for a guard entry (inbound) or a gate pass (outbound)
+type CreateWeighmentRequest struct {
+	GuardEntryID *int   `json:"guard_entry_id,omitempty"`
+	GatePassID   *int   `json:"gate_pass_id,omitempty"`
+	VehicleNo    string `json:"vehicle_no"`
+	BagCount     int    `json:"bag_count"` // Defaults to the guard entry bags
+	Notes        string `json:"notes"`
+}
+
+// CaptureWeightRequest records the gross or tare weight. WeightKg 0 reads the weighbridge.
+type CaptureWeightRequest struct {
+	Stage    string  `json:"stage"`
+	WeightKg float64 `json:"weight_kg"`
+}
+
+// ThockWeight is the weight of a thock going in and coming out of the store
+type ThockWeight struct {
+	ThockNumber       string   `json:"thock_number"`
+	InboundWeighment  *int     `json:"inbound_weighment_id,omitempty"`
+	BagsIn            int      `json:"bags_in"`
+	NetWeightInKg     *float64 `json:"net_weight_in_kg,omitempty"`
+	AvgBagWeightKg    *float64 `json:"avg_bag_weight_kg,omitempty"`
+	BagsOut           int      `json:"bags_out"` // Bags on weighed pickups
+	NetWeightOutKg    float64  `json:"net_weight_out_kg"`
+	OutboundWeighings int      `json:"outbound_weighings"`
+}
@@ -0,0 +1,273 @@
+package monitoring
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MQTTConfig configures the sensor MQTT subscription
+type MQTTConfig struct {
+	BrokerURL string // tcp://host:1883 or ssl://host:8883
+	Topic     string // e.g. coldstore/sensors/#
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+}
+
+// MQTTSubscriber is a minimal MQTT 3.1.1 client that subscribes to one topic filter at QoS 1
+// and hands every message to a callback. It reconnects with backoff until the context ends.
+type MQTTSubscriber struct {
+	cfg     MQTTConfig
+	handler func(topic string, payload []byte)
+
+	conn    net.Conn
+	writeMu sync.Mutex
+}
+
+func NewMQTTSubscriber(cfg MQTTConfig, handler func(topic string, payload []byte)) *MQTTSubscriber {
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = 60 * time.Second
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = fmt.Sprintf("cold-backend-%d", time.Now().UnixNano()%1000000)
+	}
+	return &MQTTSubscriber{cfg: cfg, handler: handler}
+}
+
+// Run connects and consumes messages until ctx is cancelled
+func (m *MQTTSubscriber) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := m.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("[MQTT] Disconnected from %s: %v (retrying in %s)", m.cfg.BrokerURL, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func (m *MQTTSubscriber) session(ctx context.Context) error {
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	m.conn = conn
+	defer conn.Close()
+
+	// Close the connection when the context ends so the blocked read returns
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	if err := m.write(connectPacket(m.cfg)); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	header, body, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+	if header>>4 != 2 || len(body) < 2 {
+		return errors.New("expected CONNACK")
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("broker refused connection (code %d)", body[1])
+	}
+
+	if err := m.write(subscribePacket(1, m.cfg.Topic)); err != nil {
+		return err
+	}
+	log.Printf("[MQTT] Connected to %s, subscribed to %s", m.cfg.BrokerURL, m.cfg.Topic)
+
+	go m.keepAlive(done)
+	for {
+		conn.SetReadDeadline(time.Now().Add(m.cfg.KeepAlive * 3 / 2))
+		header, body, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+		switch header >> 4 {
+		case 3: // PUBLISH
+			if err := m.handlePublish(header, body); err != nil {
+				return err
+			}
+		case 9: // SUBACK
+			if len(body) >= 3 && body[2] == 0x80 {
+				return fmt.Errorf("broker rejected subscription to %s", m.cfg.Topic)
+			}
+		case 13: // PINGRESP
+		}
+	}
+}
+
+func (m *MQTTSubscriber) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(m.cfg.BrokerURL)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", host)
+	case "tcp", "mqtt", "":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		return dialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported MQTT scheme %q", u.Scheme)
+	}
+}
+
+func (m *MQTTSubscriber) keepAlive(done <-chan struct{}) {
+	ticker := time.NewTicker(m.cfg.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := m.write([]byte{0xC0, 0x00}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (m *MQTTSubscriber) handlePublish(header byte, body []byte) error {
+	qos := (header >> 1) & 0x03
+	if len(body) < 2 {
+		return errors.New("malformed PUBLISH")
+	}
+	topicLen := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+topicLen {
+		return errors.New("malformed PUBLISH")
+	}
+	topic := string(body[2 : 2+topicLen])
+	payload := body[2+topicLen:]
+	if qos > 0 {
+		if len(payload) < 2 {
+			return errors.New("malformed PUBLISH")
+		}
+		packetID := payload[:2]
+		payload = payload[2:]
+		if err := m.write([]byte{0x40, 0x02, packetID[0], packetID[1]}); err != nil {
+			return err
+		}
+	}
+	m.handler(topic, payload)
+	return nil
+}
+
+func (m *MQTTSubscriber) write(packet []byte) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
+	_, err := m.conn.Write(packet)
+	return err
+}
+
+func connectPacket(cfg MQTTConfig) []byte {
+	flags := byte(0x02) // clean session
+	payload := mqttString(cfg.ClientID)
+	if cfg.Username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(cfg.Username)...)
+		if cfg.Password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(cfg.Password)...)
+		}
+	}
+	keepAlive := uint16(cfg.KeepAlive / time.Second)
+	body := append(mqttString("MQTT"), 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	return mqttPacket(0x10, append(body, payload...))
+}
+
+func subscribePacket(packetID uint16, topic string) []byte {
+	body := []byte{byte(packetID >> 8), byte(packetID)}
+	body = append(body, mqttString(topic)...)
+	body = append(body, 1) // QoS 1
+	return mqttPacket(0x82, body)
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// readPacket reads one control packet and returns its first header byte and body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
@@ -0,0 +1,131 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrSensorStoreDisabled is returned when sensor readings arrive but TimescaleDB is not available
+var ErrSensorStoreDisabled = errors.New("sensor storage needs TimescaleDB")
+
+// SensorReading is one temperature/humidity sample from a cold-room sensor
+type SensorReading struct {
+	Time         time.Time `json:"time"`
+	SensorID     string    `json:"sensor_id"`
+	RoomNo       string    `json:"room_no"`
+	Floor        string    `json:"floor"`
+	TemperatureC *float64  `json:"temperature_c,omitempty"`
+	HumidityPct  *float64  `json:"humidity_pct,omitempty"`
+}
+
+// SensorTrendPoint is the average, min and max of a room/floor's readings over one bucket
+type SensorTrendPoint struct {
+	Time           time.Time `json:"time"`
+	TemperatureAvg *float64  `json:"temperature_avg"`
+	TemperatureMin *float64  `json:"temperature_min"`
+	TemperatureMax *float64  `json:"temperature_max"`
+	HumidityAvg    *float64  `json:"humidity_avg"`
+}
+
+func (ts *TimescaleStore) initSensorTables(ctx context.Context) error {
+	_, err := ts.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS room_sensor_readings (
+			time          TIMESTAMPTZ NOT NULL,
+			sensor_id     VARCHAR(100) NOT NULL,
+			room_no       VARCHAR(20) NOT NULL,
+			floor         VARCHAR(20) NOT NULL DEFAULT '',
+			temperature_c DOUBLE PRECISION,
+			humidity_pct  DOUBLE PRECISION
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create room_sensor_readings table: %w", err)
+	}
+
+	ts.pool.Exec(ctx, "SELECT create_hypertable('room_sensor_readings', 'time', if_not_exists => TRUE)")
+	ts.pool.Exec(ctx, "CREATE INDEX IF NOT EXISTS idx_room_sensor_readings_room ON room_sensor_readings (room_no, floor, time DESC)")
+	return nil
+}
+
+// SensorsEnabled reports whether sensor readings can be stored
+func (ts *TimescaleStore) SensorsEnabled() bool {
+	return ts.enabled && ts.pool != nil
+}
+
+// RecordSensorReadings stores a batch of sensor readings
+func (ts *TimescaleStore) RecordSensorReadings(ctx context.Context, readings []SensorReading) error {
+	if !ts.SensorsEnabled() {
+		return ErrSensorStoreDisabled
+	}
+	batch := &pgx.Batch{}
+	for _, r := range readings {
+		batch.Queue(`
+			INSERT INTO room_sensor_readings (time, sensor_id, room_no, floor, temperature_c, humidity_pct)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, r.Time, r.SensorID, r.RoomNo, r.Floor, r.TemperatureC, r.HumidityPct)
+	}
+	return ts.pool.SendBatch(ctx, batch).Close()
+}
+
+// GetLatestSensorReadings returns the last reading of every sensor heard from within maxAge
+func (ts *TimescaleStore) GetLatestSensorReadings(ctx context.Context, maxAge time.Duration) ([]SensorReading, error) {
+	if !ts.SensorsEnabled() {
+		return []SensorReading{}, nil
+	}
+	rows, err := ts.pool.Query(ctx, `
+		SELECT DISTINCT ON (sensor_id, room_no, floor)
+			time, sensor_id, room_no, floor, temperature_c, humidity_pct
+		FROM room_sensor_readings
+		WHERE time > NOW() - $1::interval
+		ORDER BY sensor_id, room_no, floor, time DESC
+	`, maxAge.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := []SensorReading{}
+	for rows.Next() {
+		var r SensorReading
+		if err := rows.Scan(&r.Time, &r.SensorID, &r.RoomNo, &r.Floor, &r.TemperatureC, &r.HumidityPct); err != nil {
+			return nil, err
+		}
+		readings = append(readings, r)
+	}
+	return readings, rows.Err()
+}
+
+// GetSensorTrend returns bucketed readings for a room (all floors when floor is empty)
+func (ts *TimescaleStore) GetSensorTrend(ctx context.Context, roomNo, floor string, duration, bucket time.Duration) ([]SensorTrendPoint, error) {
+	if !ts.SensorsEnabled() {
+		return []SensorTrendPoint{}, nil
+	}
+	rows, err := ts.pool.Query(ctx, `
+		SELECT time_bucket($1::interval, time) AS bucket,
+			AVG(temperature_c), MIN(temperature_c), MAX(temperature_c), AVG(humidity_pct)
+		FROM room_sensor_readings
+		WHERE time > NOW() - $2::interval
+		  AND room_no = $3
+		  AND ($4::text = '' OR floor = $4)
+		GROUP BY bucket
+		ORDER BY bucket
+	`, bucket.String(), duration.String(), roomNo, floor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []SensorTrendPoint{}
+	for rows.Next() {
+		var p SensorTrendPoint
+		if err := rows.Scan(&p.Time, &p.TemperatureAvg, &p.TemperatureMin, &p.TemperatureMax, &p.HumidityAvg); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -94,6 +94,11 @@ func (ts *TimescaleStore) Init() error {
 
 	ts.pool.Exec(ctx, "SELECT create_hypertable('metrics_api', 'time', if_not_exists => TRUE)")
 
+	// Create Cold-Room Sensor Table
+	if err := ts.initSensorTables(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
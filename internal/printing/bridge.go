@@ -0,0 +1,111 @@
+package printing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// bridgeRequest is one call to the print bridge
+type bridgeRequest struct {
+	Endpoint string          `json:"endpoint"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type bridgeLabelBody struct {
+	Line1  string `json:"line1"`
+	Line2  string `json:"line2"`
+	Font1  string `json:"font1"`
+	Font2  string `json:"font2"`
+	Copies int    `json:"copies"`
+	Code   string `json:"code,omitempty"` // QR payload, for bridges that print codes
+}
+
+// bridgeLabel renders the bridge calls for copies of a label: full 2-up rows, then
+// one single label for an odd count
+func bridgeLabel(label Label, copies, columns int) ([]byte, error) {
+	var calls []bridgeRequest
+	add := func(endpoint string, n int) error {
+		body, err := json.Marshal(bridgeLabelBody{Line1: label.Line1, Line2: label.Line2, Font1: "5", Font2: "4", Copies: n, Code: label.Code})
+		if err != nil {
+			return err
+		}
+		calls = append(calls, bridgeRequest{Endpoint: endpoint, Body: body})
+		return nil
+	}
+
+	if columns != 2 {
+		if err := add("/print-full", copies); err != nil {
+			return nil, err
+		}
+		return json.Marshal(calls)
+	}
+	rows, single := rowSplit(copies, columns)
+	if rows > 0 {
+		if err := add("/print-2up", rows); err != nil {
+			return nil, err
+		}
+	}
+	if single {
+		if err := add("/print-full", 1); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(calls)
+}
+
+func bridgeReceipt(html string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"html": html})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal([]bridgeRequest{{Endpoint: "/print-receipt", Body: body}})
+}
+
+// BridgeTarget posts documents to the print bridge HTTP service
+type BridgeTarget struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewBridgeTarget(baseURL string) *BridgeTarget {
+	return &BridgeTarget{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+func (t *BridgeTarget) Send(ctx context.Context, data []byte) error {
+	var calls []bridgeRequest
+	if err := json.Unmarshal(data, &calls); err != nil {
+		return fmt.Errorf("bad print bridge job: %w", err)
+	}
+	for _, call := range calls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.BaseURL+call.Endpoint, bytes.NewReader(call.Body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := t.Client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach print bridge: %w", err)
+		}
+		var printResp struct {
+			Success bool   `json:"success"`
+			Message string `json:"message"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&printResp)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode print bridge response: %w", err)
+		}
+		if !printResp.Success {
+			return fmt.Errorf("print bridge: %s", printResp.Message)
+		}
+	}
+	return nil
+}
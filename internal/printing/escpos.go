@@ -0,0 +1,120 @@
+package printing
+
+import (
+	"bytes"
+	"strings"
+)
+
+// escposWidth is the characters per line of an 80 mm receipt printer in font A
+const escposWidth = 48
+
+var (
+	escInit        = []byte{0x1b, '@'}
+	escAlignLeft   = []byte{0x1b, 'a', 0}
+	escAlignCenter = []byte{0x1b, 'a', 1}
+	escBoldOn      = []byte{0x1b, 'E', 1}
+	escBoldOff     = []byte{0x1b, 'E', 0}
+	escSizeNormal  = []byte{0x1d, '!', 0x00}
+	escSizeDouble  = []byte{0x1d, '!', 0x11}
+	escFeedCut     = []byte{0x1d, 'V', 66, 3} // Feed 3 lines and partial cut
+)
+
+// ESCPOSReceipt renders a receipt for an ESC/POS printer
+func ESCPOSReceipt(r Receipt) []byte {
+	var b bytes.Buffer
+	b.Write(escInit)
+
+	if r.Title != "" {
+		b.Write(escAlignCenter)
+		b.Write(escBoldOn)
+		b.Write(escSizeDouble)
+		b.WriteString(asciiOnly(r.Title) + "\n")
+		b.Write(escSizeNormal)
+		b.Write(escBoldOff)
+		b.WriteString(strings.Repeat("-", escposWidth) + "\n")
+	}
+
+	b.Write(escAlignLeft)
+	for _, line := range r.Lines {
+		if line.Bold {
+			b.Write(escBoldOn)
+		}
+		b.WriteString(receiptRow(asciiOnly(line.Left), asciiOnly(line.Right)) + "\n")
+		if line.Bold {
+			b.Write(escBoldOff)
+		}
+	}
+
+	if r.Code != "" {
+		b.WriteString("\n")
+		b.Write(escAlignCenter)
+		escposQR(&b, r.Code, 6)
+	}
+	if r.Footer != "" {
+		b.Write(escAlignCenter)
+		b.WriteString("\n" + asciiOnly(r.Footer) + "\n")
+	}
+	b.Write(escFeedCut)
+	return b.Bytes()
+}
+
+// ESCPOSLabel prints labels on a receipt printer, one cut slip per copy
+func ESCPOSLabel(m Media, label Label, copies int) []byte {
+	var b bytes.Buffer
+	b.Write(escInit)
+	for i := 0; i < copies; i++ {
+		b.Write(escAlignCenter)
+		b.Write(escBoldOn)
+		b.Write(escSizeDouble)
+		b.WriteString(asciiOnly(label.Line1) + "\n")
+		b.Write(escSizeNormal)
+		b.Write(escBoldOff)
+		b.WriteString(asciiOnly(label.Line2) + "\n")
+		switch m.CodeType {
+		case CodeQR:
+			escposQR(&b, label.code(), 6)
+		case CodeCode128:
+			escposCode128(&b, label.code())
+		}
+		b.Write(escFeedCut)
+	}
+	return b.Bytes()
+}
+
+// receiptRow puts left and right on one line, wrapping left when both do not fit
+func receiptRow(left, right string) string {
+	if right == "" {
+		return left
+	}
+	gap := escposWidth - len(left) - len(right)
+	if gap < 1 {
+		return left + "\n" + strings.Repeat(" ", max(escposWidth-len(right), 0)) + right
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// escposQR prints data as a QR code (GS ( k, model 2)
+func escposQR(b *bytes.Buffer, data string, moduleSize byte) {
+	b.Write([]byte{0x1d, '(', 'k', 4, 0, '1', 'A', '2', 0}) // Model 2
+	b.Write([]byte{0x1d, '(', 'k', 3, 0, '1', 'C', moduleSize})
+	b.Write([]byte{0x1d, '(', 'k', 3, 0, '1', 'E', '1'}) // Error correction M
+	n := len(data) + 3
+	b.Write([]byte{0x1d, '(', 'k', byte(n), byte(n >> 8), '1', 'P', '0'})
+	b.WriteString(data)
+	b.Write([]byte{0x1d, '(', 'k', 3, 0, '1', 'Q', '0'}) // Print
+	b.WriteString("\n")
+}
+
+// escposCode128 prints data as a Code 128 barcode with the text under it
+func escposCode128(b *bytes.Buffer, data string) {
+	data = "{B" + asciiOnly(data)
+	if len(data) > 255 {
+		data = data[:255]
+	}
+	b.Write([]byte{0x1d, 'h', 80}) // Height in dots
+	b.Write([]byte{0x1d, 'w', 2})  // Module width
+	b.Write([]byte{0x1d, 'H', 2})  // Text below
+	b.Write([]byte{0x1d, 'k', 73, byte(len(data))})
+	b.WriteString(data)
+	b.WriteString("\n")
+}
@@ -0,0 +1,71 @@
+package printing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrFakeFailure is returned by the fake printer while it is set to fail
+var ErrFakeFailure = errors.New("fake printer failure")
+
+// fakeJobLimit bounds the jobs the fake keeps
+const fakeJobLimit = 200
+
+// FakeJob is one document received by the fake printer
+type FakeJob struct {
+	Data []byte    `json:"data"`
+	At   time.Time `json:"at"`
+}
+
+// Fake is a printer that keeps what it receives in memory. It can be told to fail the
+// next sends to exercise the print queue's retries.
+type Fake struct {
+	mu       sync.Mutex
+	jobs     []FakeJob
+	failNext int
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Send(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failNext > 0 {
+		f.failNext--
+		return ErrFakeFailure
+	}
+	f.jobs = append(f.jobs, FakeJob{Data: append([]byte(nil), data...), At: time.Now()})
+	if len(f.jobs) > fakeJobLimit {
+		f.jobs = f.jobs[len(f.jobs)-fakeJobLimit:]
+	}
+	return nil
+}
+
+// Jobs returns the documents received, oldest first
+func (f *Fake) Jobs() []FakeJob {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeJob(nil), f.jobs...)
+}
+
+// FailNext makes the next n sends fail
+func (f *Fake) FailNext(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext = n
+}
+
+// Reset forgets received documents and pending failures
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs = nil
+	f.failNext = 0
+}
@@ -0,0 +1,188 @@
+// Package printing renders labels and receipts in printer languages and sends them
+// to printers.
+//
+// Thermal label printers take ZPL or TSPL over raw TCP (port 9100); receipt printers take
+// ESC/POS the same way. The old print bridge (a small HTTP service in front of the label
+// and HP printers) is still supported as a target. The fake target keeps jobs in memory
+// for tests.
+package printing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Printer protocols, stored on each printer
+const (
+	ProtocolZPL    = "zpl"
+	ProtocolTSPL   = "tspl"
+	ProtocolESCPOS = "escpos"
+	ProtocolBridge = "bridge"
+	ProtocolFake   = "fake"
+)
+
+// Codes printed on labels and receipts
+const (
+	CodeQR      = "qr"
+	CodeCode128 = "code128"
+	CodeNone    = "none"
+)
+
+// ErrUnsupported is returned when a printer cannot print a document
+var ErrUnsupported = errors.New("printer does not support this document")
+
+// DefaultTimeout bounds one send to a printer
+const DefaultTimeout = 10 * time.Second
+
+// Media describes the label stock loaded in a label printer
+type Media struct {
+	WidthMM  int    // Width of one label
+	HeightMM int    // Height of one label
+	Columns  int    // Labels side by side on the liner (1 or 2)
+	DPI      int    // 203 or 300
+	CodeType string // CodeQR, CodeCode128 or CodeNone
+}
+
+// dots converts millimetres to printer dots
+func (m Media) dots(mm int) int {
+	return mm * m.DPI * 10 / 254
+}
+
+func (m Media) normalized() Media {
+	if m.WidthMM <= 0 {
+		m.WidthMM = 50
+	}
+	if m.HeightMM <= 0 {
+		m.HeightMM = 25
+	}
+	if m.Columns != 2 {
+		m.Columns = 1
+	}
+	if m.DPI <= 0 {
+		m.DPI = 203
+	}
+	if m.CodeType == "" {
+		m.CodeType = CodeQR
+	}
+	return m
+}
+
+// Label is one thock label: the thock number, the customer, and a code for scanners
+type Label struct {
+	Line1 string // Thock number, printed large
+	Line2 string // Customer name
+	Code  string // Data for the QR code or barcode; the thock number when empty
+}
+
+func (l Label) code() string {
+	if l.Code != "" {
+		return l.Code
+	}
+	return l.Line1
+}
+
+// ReceiptLine is one row of a receipt with optional right-aligned text
+type ReceiptLine struct {
+	Left  string
+	Right string
+	Bold  bool
+}
+
+// Receipt is a plain-text receipt for ESC/POS printers, or HTML for the print bridge
+type Receipt struct {
+	Title  string
+	Lines  []ReceiptLine
+	Code   string // Printed as a QR code under the lines when set
+	Footer string
+	HTML   string // Only the print bridge prints HTML
+}
+
+// RenderLabel renders copies of a label in the printer's language
+func RenderLabel(protocol string, media Media, label Label, copies int) ([]byte, error) {
+	if copies < 1 {
+		copies = 1
+	}
+	media = media.normalized()
+	switch protocol {
+	case ProtocolZPL:
+		return ZPLLabel(media, label, copies), nil
+	case ProtocolTSPL:
+		return TSPLLabel(media, label, copies), nil
+	case ProtocolESCPOS:
+		return ESCPOSLabel(media, label, copies), nil
+	case ProtocolBridge:
+		return bridgeLabel(label, copies, media.Columns)
+	case ProtocolFake:
+		return ZPLLabel(media, label, copies), nil
+	default:
+		return nil, fmt.Errorf("unknown printer protocol %q", protocol)
+	}
+}
+
+// RenderReceipt renders a receipt in the printer's language
+func RenderReceipt(protocol string, receipt Receipt) ([]byte, error) {
+	switch protocol {
+	case ProtocolESCPOS, ProtocolFake:
+		if len(receipt.Lines) == 0 && receipt.Title == "" {
+			return nil, fmt.Errorf("%w: ESC/POS printers need receipt lines, not HTML", ErrUnsupported)
+		}
+		return ESCPOSReceipt(receipt), nil
+	case ProtocolBridge:
+		if receipt.HTML == "" {
+			return nil, fmt.Errorf("%w: the print bridge prints HTML receipts", ErrUnsupported)
+		}
+		return bridgeReceipt(receipt.HTML)
+	case ProtocolZPL, ProtocolTSPL:
+		return nil, fmt.Errorf("%w: %s printers print labels only", ErrUnsupported, protocol)
+	default:
+		return nil, fmt.Errorf("unknown printer protocol %q", protocol)
+	}
+}
+
+// Target delivers rendered documents to a printer
+type Target interface {
+	Send(ctx context.Context, data []byte) error
+}
+
+// NewTarget returns the target for a printer. The fake is shared so tests can read
+// back what was printed.
+func NewTarget(protocol, address string, fake *Fake) (Target, error) {
+	switch protocol {
+	case ProtocolZPL, ProtocolTSPL, ProtocolESCPOS:
+		if address == "" {
+			return nil, errors.New("printer address is not set")
+		}
+		if !strings.Contains(address, ":") {
+			address += ":9100"
+		}
+		return &RawTarget{Address: address, Timeout: DefaultTimeout}, nil
+	case ProtocolBridge:
+		if address == "" {
+			return nil, errors.New("print bridge URL is not set")
+		}
+		return NewBridgeTarget(address), nil
+	case ProtocolFake:
+		if fake == nil {
+			return nil, errors.New("fake printer is not available")
+		}
+		return fake, nil
+	default:
+		return nil, fmt.Errorf("unknown printer protocol %q", protocol)
+	}
+}
+
+// asciiOnly replaces characters outside printable ASCII, which device fonts lack
+func asciiOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
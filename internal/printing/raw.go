@@ -0,0 +1,30 @@
+package printing
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// RawTarget sends documents to a printer's raw port (JetDirect, usually 9100)
+type RawTarget struct {
+	Address string
+	Timeout time.Duration
+}
+
+func (t *RawTarget) Send(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	_, err = conn.Write(data)
+	return err
+}
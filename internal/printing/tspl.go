@@ -0,0 +1,56 @@
+package printing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TSPLLabel renders copies of a label in TSPL (TSC and compatible printers). On 2-up
+// stock an odd count ends with a row holding only the left label.
+func TSPLLabel(m Media, label Label, copies int) []byte {
+	m = m.normalized()
+	l := newLabelLayout(m)
+	rows, single := rowSplit(copies, m.Columns)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SIZE %d mm,%d mm\r\nGAP 2 mm,0 mm\r\nDIRECTION 1\r\nCODEPAGE UTF-8\r\n", m.WidthMM*m.Columns, m.HeightMM)
+	if rows > 0 {
+		tsplRow(&b, m, l, label, m.Columns)
+		fmt.Fprintf(&b, "PRINT %d\r\n", rows)
+	}
+	if single {
+		tsplRow(&b, m, l, label, 1)
+		b.WriteString("PRINT 1\r\n")
+	}
+	return []byte(b.String())
+}
+
+func tsplRow(b *strings.Builder, m Media, l labelLayout, label Label, columns int) {
+	// Font "0" is the scalable font; its size is given in points
+	pt1 := fitHeight(l.line1Height, l.textWidth, label.Line1) * 72 / m.DPI
+	pt2 := l.line2Height * 72 / m.DPI
+
+	b.WriteString("CLS\r\n")
+	for c := 0; c < columns; c++ {
+		x := c*l.width + l.margin
+		y := l.margin
+		fmt.Fprintf(b, "TEXT %d,%d,\"0\",0,%d,%d,\"%s\"\r\n", x, y, pt1, pt1, tsplEscape(label.Line1))
+		y += l.line1Height + l.margin/2
+		fmt.Fprintf(b, "TEXT %d,%d,\"0\",0,%d,%d,\"%s\"\r\n", x, y, pt2, pt2, tsplEscape(label.Line2))
+
+		switch m.CodeType {
+		case CodeQR:
+			fmt.Fprintf(b, "QRCODE %d,%d,M,%d,A,0,\"%s\"\r\n",
+				(c+1)*l.width-l.margin-l.codeSize, l.margin, l.qrModule, tsplEscape(label.code()))
+		case CodeCode128:
+			fmt.Fprintf(b, "BARCODE %d,%d,\"128\",%d,0,0,2,2,\"%s\"\r\n",
+				x, l.height-l.margin-l.codeSize, l.codeSize, tsplEscape(label.code()))
+		}
+	}
+}
+
+// tsplEscape escapes double quotes, which end a TSPL string
+func tsplEscape(s string) string {
+	r := strings.NewReplacer(`"`, `\["]`, "\r", " ", "\n", " ")
+	return r.Replace(s)
+}
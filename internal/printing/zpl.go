@@ -0,0 +1,109 @@
+package printing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelLayout places the two lines and the code on one label, in dots
+type labelLayout struct {
+	width, height int // One label
+	margin        int
+	line1Height   int
+	line2Height   int
+	textWidth     int
+	codeSize      int // QR code: side; barcode: height
+	qrModule      int // Dots per QR module
+}
+
+// qrModules is the side of the QR codes we print: version 4 (33 modules) holds a signed
+// label payload at medium error correction
+const qrModules = 33
+
+func newLabelLayout(m Media) labelLayout {
+	l := labelLayout{width: m.dots(m.WidthMM), height: m.dots(m.HeightMM)}
+	l.margin = m.dots(2)
+	l.line1Height = l.height * 30 / 100
+	l.line2Height = l.height * 18 / 100
+	l.textWidth = l.width - 2*l.margin
+
+	switch m.CodeType {
+	case CodeQR:
+		l.qrModule = l.height * 60 / 100 / qrModules
+		if l.qrModule < 1 {
+			l.qrModule = 1
+		}
+		l.codeSize = l.qrModule * qrModules
+		l.textWidth -= l.codeSize + l.margin
+	case CodeCode128:
+		l.codeSize = l.height - 3*l.margin - l.line1Height - l.line2Height
+		if l.codeSize < l.margin {
+			l.codeSize = l.margin
+		}
+	}
+	return l
+}
+
+// fitHeight shrinks a font height so text fits width; device fonts are about 0.55 wide
+func fitHeight(height, width int, text string) int {
+	if n := len([]rune(text)); n > 0 && height*55*n/100 > width {
+		height = width * 100 / (55 * n)
+	}
+	return height
+}
+
+// rowSplit returns how many full rows to print and whether a half row is left over
+func rowSplit(copies, columns int) (rows int, single bool) {
+	if columns == 2 {
+		return copies / 2, copies%2 == 1
+	}
+	return copies, false
+}
+
+// ZPLLabel renders copies of a label in ZPL II. On 2-up stock an odd count ends with a
+// row holding only the left label.
+func ZPLLabel(m Media, label Label, copies int) []byte {
+	m = m.normalized()
+	l := newLabelLayout(m)
+	rows, single := rowSplit(copies, m.Columns)
+
+	var b strings.Builder
+	if rows > 0 {
+		zplFormat(&b, m, l, label, m.Columns, rows)
+	}
+	if single {
+		zplFormat(&b, m, l, label, 1, 1)
+	}
+	return []byte(b.String())
+}
+
+func zplFormat(b *strings.Builder, m Media, l labelLayout, label Label, columns, quantity int) {
+	b.WriteString("^XA\n^CI28\n")
+	fmt.Fprintf(b, "^PW%d\n^LL%d\n^LH0,0\n", l.width*m.Columns, l.height)
+	for c := 0; c < columns; c++ {
+		x := c*l.width + l.margin
+		y := l.margin
+		h1 := fitHeight(l.line1Height, l.textWidth, label.Line1)
+		fmt.Fprintf(b, "^FO%d,%d^A0N,%d,%d^FB%d,1,0,L^FH^FD%s^FS\n",
+			x, y, h1, h1, l.textWidth, zplEscape(label.Line1))
+		y += l.line1Height + l.margin/2
+		fmt.Fprintf(b, "^FO%d,%d^A0N,%d,%d^FB%d,2,0,L^FH^FD%s^FS\n",
+			x, y, l.line2Height, l.line2Height, l.textWidth, zplEscape(label.Line2))
+
+		switch m.CodeType {
+		case CodeQR:
+			fmt.Fprintf(b, "^FO%d,%d^BQN,2,%d^FH^FDMA,%s^FS\n",
+				(c+1)*l.width-l.margin-l.codeSize, l.margin, l.qrModule, zplEscape(label.code()))
+		case CodeCode128:
+			fmt.Fprintf(b, "^FO%d,%d^BY2^BCN,%d,N,N,N^FH^FD%s^FS\n",
+				x, l.height-l.margin-l.codeSize, l.codeSize, zplEscape(label.code()))
+		}
+	}
+	fmt.Fprintf(b, "^PQ%d\n^XZ\n", quantity)
+}
+
+// zplEscape hex-escapes the characters ZPL treats as commands; fields use ^FH
+func zplEscape(s string) string {
+	r := strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E", "\n", " ", "\r", " ")
+	return r.Replace(s)
+}
@@ -0,0 +1,407 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrApprovalNotFound is returned for an unknown approval request
+	ErrApprovalNotFound = errors.New("approval request not found")
+	// ErrApprovalClosed is returned when deciding on an approval request that is no longer pending
+	ErrApprovalClosed = errors.New("approval request is no longer pending")
+	// ErrApprovalOpen is returned when a subject that already has an open approval request gets another
+	ErrApprovalOpen = errors.New("an approval request is already open for this")
+	// ErrApprovalExpired is returned when deciding on an approval request past its expiry
+	ErrApprovalExpired = errors.New("approval request has expired")
+	// ErrApprovalAlreadyDecided is returned when an approver decides on the same request twice
+	ErrApprovalAlreadyDecided = errors.New("you have already decided on this approval request")
+	// ErrApprovalChanged is returned when the amount or terms of an approval request changed while approving it
+	ErrApprovalChanged = errors.New("approval request changed, review it again")
+	// ErrApprovalPolicyNotFound is returned for an unknown policy or a flow without an active one
+	ErrApprovalPolicyNotFound = errors.New("approval policy not found")
+	// ErrApprovalPolicyExists is returned for a second policy tier at the same amount
+	ErrApprovalPolicyExists = errors.New("an approval policy for this flow and amount already exists")
+)
+
+type ApprovalRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewApprovalRepository(db *pgxpool.Pool) *ApprovalRepository {
+	return &ApprovalRepository{DB: db}
+}
+
+const approvalPolicyColumns = `p.id, p.flow, p.min_amount::float8, p.required_approvals, p.approver_roles, p.approver_user_ids,
+	       p.allow_self_approval, p.self_approval_user_ids, p.require_password, p.totp_mode, p.expiry_hours,
+	       p.is_active, p.updated_by_user_id, p.created_at, p.updated_at`
+
+func approvalPolicyDest(p *models.ApprovalPolicy) []interface{} {
+	return []interface{}{&p.ID, &p.Flow, &p.MinAmount, &p.RequiredApprovals, &p.ApproverRoles, &p.ApproverUserIDs,
+		&p.AllowSelfApproval, &p.SelfApprovalUserIDs, &p.RequirePassword, &p.TOTPMode, &p.ExpiryHours,
+		&p.IsActive, &p.UpdatedByUserID, &p.CreatedAt, &p.UpdatedAt}
+}
+
+const approvalRequestSelect = `
+	SELECT ar.id, ar.flow, ar.subject_id, ar.summary, ar.terms, ar.amount::float8, ar.policy_id, ar.status,
+	       ar.requested_by_user_id, COALESCE(u.name, u.email, ''),
+	       (SELECT COUNT(*) FROM approval_decisions d WHERE d.approval_request_id = ar.id AND d.decision = 'approve')::int,
+	       ar.expires_at, ar.decided_at, ar.created_at,
+	       ` + approvalPolicyColumns + `
+	FROM approval_requests ar
+	JOIN approval_policies p ON p.id = ar.policy_id
+	LEFT JOIN users u ON u.id = ar.requested_by_user_id`
+
+// approvalSubjectOpen is true while the gate pass, debt request, setting change or season
+// request behind an approval request still waits for a decision
+const approvalSubjectOpen = `(CASE ar.flow
+	    WHEN 'gate_pass' THEN EXISTS (SELECT 1 FROM gate_passes g WHERE g.id = ar.subject_id
+	        AND g.status = 'pending' AND (g.expires_at IS NULL OR g.expires_at > CURRENT_TIMESTAMP))
+	    WHEN 'debt_request' THEN EXISTS (SELECT 1 FROM debt_requests dr WHERE dr.id = ar.subject_id
+	        AND dr.status = 'pending' AND (dr.expires_at IS NULL OR dr.expires_at > CURRENT_TIMESTAMP))
+	    WHEN 'setting_change' THEN EXISTS (SELECT 1 FROM pending_setting_changes sc WHERE sc.id = ar.subject_id
+	        AND sc.status = 'pending' AND sc.expires_at > CURRENT_TIMESTAMP)
+	    WHEN 'season_request' THEN EXISTS (SELECT 1 FROM season_requests sr WHERE sr.id = ar.subject_id
+	        AND sr.status = 'pending')
+	    ELSE TRUE END)`
+
+func scanApprovalRequest(row pgx.Row) (*models.ApprovalRequest, error) {
+	a := &models.ApprovalRequest{Policy: &models.ApprovalPolicy{}}
+	dest := []interface{}{&a.ID, &a.Flow, &a.SubjectID, &a.Summary, &a.Terms, &a.Amount, &a.PolicyID, &a.Status,
+		&a.RequestedByUserID, &a.RequestedByName, &a.Approvals,
+		&a.ExpiresAt, &a.DecidedAt, &a.CreatedAt}
+	if err := row.Scan(append(dest, approvalPolicyDest(a.Policy)...)...); err != nil {
+		return nil, err
+	}
+	a.RequiredApprovals = a.Policy.RequiredApprovals
+	return a, nil
+}
+
+func (r *ApprovalRepository) listRequests(ctx context.Context, query string, args ...interface{}) ([]*models.ApprovalRequest, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*models.ApprovalRequest
+	for rows.Next() {
+		a, err := scanApprovalRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, a)
+	}
+	return requests, rows.Err()
+}
+
+// ListPolicies returns the policies of a flow, or of all flows when flow is empty
+func (r *ApprovalRepository) ListPolicies(ctx context.Context, flow string) ([]*models.ApprovalPolicy, error) {
+	rows, err := r.DB.Query(ctx, `SELECT `+approvalPolicyColumns+`
+		FROM approval_policies p
+		WHERE $1::text = '' OR p.flow = $1::text
+		ORDER BY p.flow, p.min_amount`, flow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.ApprovalPolicy
+	for rows.Next() {
+		p := &models.ApprovalPolicy{}
+		if err := rows.Scan(approvalPolicyDest(p)...); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// GetPolicy returns one policy
+func (r *ApprovalRepository) GetPolicy(ctx context.Context, id int) (*models.ApprovalPolicy, error) {
+	p := &models.ApprovalPolicy{}
+	err := r.DB.QueryRow(ctx, `SELECT `+approvalPolicyColumns+` FROM approval_policies p WHERE p.id = $1`, id).Scan(approvalPolicyDest(p)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApprovalPolicyNotFound
+	}
+	return p, err
+}
+
+// ResolvePolicy returns the active policy tier of a flow that applies to an amount
+func (r *ApprovalRepository) ResolvePolicy(ctx context.Context, flow string, amount float64) (*models.ApprovalPolicy, error) {
+	p := &models.ApprovalPolicy{}
+	err := r.DB.QueryRow(ctx, `SELECT `+approvalPolicyColumns+`
+		FROM approval_policies p
+		WHERE p.flow = $1 AND p.is_active AND p.min_amount <= $2
+		ORDER BY p.min_amount DESC
+		LIMIT 1`, flow, amount).Scan(approvalPolicyDest(p)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApprovalPolicyNotFound
+	}
+	return p, err
+}
+
+// CreatePolicy adds a policy tier
+func (r *ApprovalRepository) CreatePolicy(ctx context.Context, p *models.ApprovalPolicy) error {
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO approval_policies (flow, min_amount, required_approvals, approver_roles, approver_user_ids,
+			allow_self_approval, self_approval_user_ids, require_password, totp_mode, expiry_hours, is_active, updated_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at`,
+		p.Flow, p.MinAmount, p.RequiredApprovals, p.ApproverRoles, p.ApproverUserIDs,
+		p.AllowSelfApproval, p.SelfApprovalUserIDs, p.RequirePassword, p.TOTPMode, p.ExpiryHours, p.IsActive, p.UpdatedByUserID,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrApprovalPolicyExists
+	}
+	return err
+}
+
+// UpdatePolicy replaces a policy's rules; requests already open follow the new rules
+func (r *ApprovalRepository) UpdatePolicy(ctx context.Context, p *models.ApprovalPolicy) error {
+	result, err := r.DB.Exec(ctx, `
+		UPDATE approval_policies
+		SET min_amount = $2, required_approvals = $3, approver_roles = $4, approver_user_ids = $5,
+		    allow_self_approval = $6, self_approval_user_ids = $7, require_password = $8, totp_mode = $9,
+		    expiry_hours = $10, is_active = $11, updated_by_user_id = $12, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		p.ID, p.MinAmount, p.RequiredApprovals, p.ApproverRoles, p.ApproverUserIDs,
+		p.AllowSelfApproval, p.SelfApprovalUserIDs, p.RequirePassword, p.TOTPMode,
+		p.ExpiryHours, p.IsActive, p.UpdatedByUserID)
+	if isUniqueViolation(err) {
+		return ErrApprovalPolicyExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrApprovalPolicyNotFound
+	}
+	return nil
+}
+
+// Open starts an approval request under a policy, expiring after the policy's expiry hours.
+// Returns ErrApprovalOpen if the subject already has one open.
+func (r *ApprovalRepository) Open(ctx context.Context, a *models.ApprovalRequest) error {
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO approval_requests (flow, subject_id, summary, terms, amount, policy_id, requested_by_user_id, expires_at)
+		SELECT $1::text, $2::int, $3::text, $7::text, $4::numeric, p.id, $6::int,
+		       CASE WHEN p.expiry_hours > 0 THEN CURRENT_TIMESTAMP + p.expiry_hours * INTERVAL '1 hour' END
+		FROM approval_policies p
+		WHERE p.id = $5
+		RETURNING id`,
+		a.Flow, a.SubjectID, a.Summary, a.Amount, a.PolicyID, a.RequestedByUserID, a.Terms,
+	).Scan(&a.ID)
+	if isUniqueViolation(err) {
+		return ErrApprovalOpen
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrApprovalPolicyNotFound
+	}
+	return err
+}
+
+// Reprice moves an open approval request to a new amount, terms and the policy tier for them.
+// The approvals given so far were for the old amount and terms, so they are dropped.
+func (r *ApprovalRepository) Reprice(ctx context.Context, a *models.ApprovalRequest) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE approval_requests SET amount = $2, summary = $3, terms = $4, policy_id = $5
+		WHERE id = $1 AND status = 'pending'`, a.ID, a.Amount, a.Summary, a.Terms, a.PolicyID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrApprovalClosed
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM approval_decisions WHERE approval_request_id = $1 AND decision = 'approve'`, a.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Get returns an approval request with its decisions
+func (r *ApprovalRepository) Get(ctx context.Context, id int) (*models.ApprovalRequest, error) {
+	a, err := scanApprovalRequest(r.DB.QueryRow(ctx, approvalRequestSelect+` WHERE ar.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.Decisions, err = r.ListDecisions(ctx, id)
+	return a, err
+}
+
+// GetLatestForSubject returns the most recent approval request of a subject
+func (r *ApprovalRepository) GetLatestForSubject(ctx context.Context, flow string, subjectID int) (*models.ApprovalRequest, error) {
+	a, err := scanApprovalRequest(r.DB.QueryRow(ctx, approvalRequestSelect+`
+		WHERE ar.flow = $1 AND ar.subject_id = $2
+		ORDER BY ar.created_at DESC, ar.id DESC
+		LIMIT 1`, flow, subjectID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApprovalNotFound
+	}
+	return a, err
+}
+
+// ListDecisions returns the decisions on an approval request in the order they were made
+func (r *ApprovalRepository) ListDecisions(ctx context.Context, approvalID int) ([]*models.ApprovalDecision, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT d.id, d.approval_request_id, d.user_id, COALESCE(u.name, u.email, ''), d.decision, d.comment,
+		       d.password_verified, d.totp_verified, d.ip_address, d.created_at
+		FROM approval_decisions d
+		LEFT JOIN users u ON u.id = d.user_id
+		WHERE d.approval_request_id = $1
+		ORDER BY d.created_at, d.id`, approvalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*models.ApprovalDecision
+	for rows.Next() {
+		d := &models.ApprovalDecision{}
+		if err := rows.Scan(&d.ID, &d.ApprovalRequestID, &d.UserID, &d.UserName, &d.Decision, &d.Comment,
+			&d.PasswordVerified, &d.TOTPVerified, &d.IPAddress, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// List returns recent approval requests, optionally of one flow and status
+func (r *ApprovalRepository) List(ctx context.Context, flow, status string, limit int) ([]*models.ApprovalRequest, error) {
+	return r.listRequests(ctx, approvalRequestSelect+`
+		WHERE ($1::text = '' OR ar.flow = $1::text) AND ($2::text = '' OR ar.status = $2::text)
+		ORDER BY ar.created_at DESC, ar.id DESC
+		LIMIT $3`, flow, status, limit)
+}
+
+// Inbox returns the open approval requests a user may still approve: their role and panel
+// fit the policy, the request is not their own unless the policy allows it, and they have
+// not decided on it yet
+func (r *ApprovalRepository) Inbox(ctx context.Context, userID int, role string) ([]*models.ApprovalRequest, error) {
+	return r.listRequests(ctx, approvalRequestSelect+`
+		WHERE ar.status = 'pending' AND (ar.expires_at IS NULL OR ar.expires_at > CURRENT_TIMESTAMP)
+		  AND $2::text = ANY(p.approver_roles)
+		  AND (cardinality(p.approver_user_ids) = 0 OR $1::int = ANY(p.approver_user_ids))
+		  AND (ar.requested_by_user_id IS DISTINCT FROM $1::int OR p.allow_self_approval OR $1::int = ANY(p.self_approval_user_ids))
+		  AND NOT EXISTS (SELECT 1 FROM approval_decisions d WHERE d.approval_request_id = ar.id AND d.user_id = $1::int)
+		  AND `+approvalSubjectOpen+`
+		ORDER BY ar.expires_at NULLS LAST, ar.created_at`, userID, role)
+}
+
+// Decide records an approver's decision. A rejection closes the request; an approval closes
+// it as approved once the policy's number of approvals is reached. An approval only counts
+// while the request keeps the amount and terms the approver saw in seen.
+func (r *ApprovalRepository) Decide(ctx context.Context, d *models.ApprovalDecision, seen *models.ApprovalRequest) (*models.ApprovalRequest, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status, terms string
+	var expired bool
+	var amount float64
+	var required int
+	err = tx.QueryRow(ctx, `
+		SELECT ar.status, (ar.expires_at IS NOT NULL AND ar.expires_at <= CURRENT_TIMESTAMP), ar.terms, ar.amount::float8, p.required_approvals
+		FROM approval_requests ar
+		JOIN approval_policies p ON p.id = ar.policy_id
+		WHERE ar.id = $1
+		FOR UPDATE OF ar`, d.ApprovalRequestID).Scan(&status, &expired, &terms, &amount, &required)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != models.ApprovalStatusPending {
+		return nil, ErrApprovalClosed
+	}
+	if expired {
+		return nil, ErrApprovalExpired
+	}
+	if d.Decision == models.ApprovalDecisionApprove && seen != nil && (terms != seen.Terms || amount != seen.Amount) {
+		return nil, ErrApprovalChanged
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO approval_decisions (approval_request_id, user_id, decision, comment, password_verified, totp_verified, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		d.ApprovalRequestID, d.UserID, d.Decision, d.Comment, d.PasswordVerified, d.TOTPVerified, d.IPAddress)
+	if isUniqueViolation(err) {
+		return nil, ErrApprovalAlreadyDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Decision == models.ApprovalDecisionReject {
+		status = models.ApprovalStatusRejected
+	} else {
+		var approvals int
+		if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM approval_decisions WHERE approval_request_id = $1 AND decision = 'approve'`,
+			d.ApprovalRequestID).Scan(&approvals); err != nil {
+			return nil, err
+		}
+		if approvals >= required {
+			status = models.ApprovalStatusApproved
+		}
+	}
+	if status != models.ApprovalStatusPending {
+		if _, err := tx.Exec(ctx, `UPDATE approval_requests SET status = $2, decided_at = CURRENT_TIMESTAMP WHERE id = $1`,
+			d.ApprovalRequestID, status); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, d.ApprovalRequestID)
+}
+
+// ExpireDue closes the open approval requests past their expiry and returns them
+func (r *ApprovalRepository) ExpireDue(ctx context.Context) ([]*models.ApprovalRequest, error) {
+	rows, err := r.DB.Query(ctx, `
+		UPDATE approval_requests SET status = 'expired', decided_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP
+		RETURNING id, flow, subject_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expired []*models.ApprovalRequest
+	for rows.Next() {
+		a := &models.ApprovalRequest{Status: models.ApprovalStatusExpired}
+		if err := rows.Scan(&a.ID, &a.Flow, &a.SubjectID); err != nil {
+			return nil, err
+		}
+		expired = append(expired, a)
+	}
+	return expired, rows.Err()
+}
+
+// CancelSettled closes the open approval requests whose subject was decided, cancelled or
+// expired outside the approval engine
+func (r *ApprovalRepository) CancelSettled(ctx context.Context) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE approval_requests ar SET status = 'cancelled', decided_at = CURRENT_TIMESTAMP
+		WHERE ar.status = 'pending' AND NOT `+approvalSubjectOpen)
+	return err
+}
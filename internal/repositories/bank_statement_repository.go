@@ -0,0 +1,245 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrBankLineConflict is returned when a bank line is not in a state the change applies to,
+// or the record it is linked to already settles another bank line
+var ErrBankLineConflict = errors.New("bank statement line conflict")
+
+// bankLineSelect is the column list scanned by scanBankLine
+const bankLineSelect = `
+	SELECT l.id, l.import_id, l.line_number, l.txn_date, l.description, l.reference, l.utr, l.amount, l.balance,
+	       l.status, l.match_reason, l.rent_payment_id, l.online_transaction_id, l.ledger_entry_id,
+	       l.resolved_by_user_id, COALESCE(u.name, ''), l.resolved_at, l.notes, l.line_hash, l.created_at,
+	       COALESCE(rp.customer_name, ot.customer_name, le.customer_name, ''),
+	       COALESCE(rp.customer_phone, ot.customer_phone, le.customer_phone, ''),
+	       COALESCE(rp.receipt_number, ot.razorpay_order_id, 'LE-' || le.id::text, '')
+	FROM bank_statement_lines l
+	LEFT JOIN users u ON u.id = l.resolved_by_user_id
+	LEFT JOIN rent_payments rp ON rp.id = l.rent_payment_id
+	LEFT JOIN online_transactions ot ON ot.id = l.online_transaction_id
+	LEFT JOIN ledger_entries le ON le.id = l.ledger_entry_id`
+
+type BankStatementRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewBankStatementRepository(db *pgxpool.Pool) *BankStatementRepository {
+	return &BankStatementRepository{DB: db}
+}
+
+// CreateImport stores an import and its credit lines in one transaction.
+// Lines already imported from an earlier statement (same hash) are skipped.
+// Returns the lines that were stored.
+func (r *BankStatementRepository) CreateImport(ctx context.Context, imp *models.BankStatementImport, lines []*models.BankStatementLine) ([]*models.BankStatementLine, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO bank_statement_imports (file_name, bank_account, statement_from, statement_to, imported_by_user_id)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+		 RETURNING id, created_at`,
+		imp.FileName, imp.BankAccount, imp.StatementFrom, imp.StatementTo, imp.ImportedByUserID,
+	).Scan(&imp.ID, &imp.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import: %w", err)
+	}
+
+	var stored []*models.BankStatementLine
+	for _, l := range lines {
+		err := tx.QueryRow(ctx,
+			`INSERT INTO bank_statement_lines (import_id, line_number, txn_date, description, reference, utr, amount, balance, line_hash)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			 ON CONFLICT (line_hash) DO NOTHING
+			 RETURNING id, status, created_at`,
+			imp.ID, l.LineNumber, l.TxnDate, l.Description, l.Reference, l.UTR, l.Amount, l.Balance, l.LineHash,
+		).Scan(&l.ID, &l.Status, &l.CreatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			imp.DuplicateCount++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to store line %d: %w", l.LineNumber, err)
+		}
+		l.ImportID = imp.ID
+		stored = append(stored, l)
+	}
+
+	imp.LineCount = len(stored)
+	if _, err := tx.Exec(ctx,
+		`UPDATE bank_statement_imports SET line_count = $2, duplicate_count = $3 WHERE id = $1`,
+		imp.ID, imp.LineCount, imp.DuplicateCount); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// ListImports returns recent imports (newest first) with their match counts
+func (r *BankStatementRepository) ListImports(ctx context.Context, limit int) ([]*models.BankStatementImport, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT i.id, i.file_name, i.bank_account, i.statement_from, i.statement_to, i.line_count, i.duplicate_count,
+		        COALESCE(i.imported_by_user_id, 0), COALESCE(u.name, ''), i.created_at,
+		        COUNT(l.id) FILTER (WHERE l.status <> 'unmatched'),
+		        COUNT(l.id) FILTER (WHERE l.status = 'unmatched')
+		 FROM bank_statement_imports i
+		 LEFT JOIN users u ON u.id = i.imported_by_user_id
+		 LEFT JOIN bank_statement_lines l ON l.import_id = i.id
+		 GROUP BY i.id, u.name
+		 ORDER BY i.created_at DESC
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imports := []*models.BankStatementImport{}
+	for rows.Next() {
+		var i models.BankStatementImport
+		if err := rows.Scan(&i.ID, &i.FileName, &i.BankAccount, &i.StatementFrom, &i.StatementTo, &i.LineCount, &i.DuplicateCount,
+			&i.ImportedByUserID, &i.ImportedByName, &i.CreatedAt, &i.MatchedCount, &i.UnmatchedCount); err != nil {
+			return nil, err
+		}
+		imports = append(imports, &i)
+	}
+	return imports, rows.Err()
+}
+
+// GetLine returns a single bank line
+func (r *BankStatementRepository) GetLine(ctx context.Context, id int) (*models.BankStatementLine, error) {
+	return scanBankLine(r.DB.QueryRow(ctx, bankLineSelect+` WHERE l.id = $1`, id))
+}
+
+// ListLines returns bank lines oldest first, filtered by import (0 = all) and status ("" = all)
+func (r *BankStatementRepository) ListLines(ctx context.Context, importID int, status string, limit int) ([]*models.BankStatementLine, error) {
+	rows, err := r.DB.Query(ctx,
+		bankLineSelect+`
+		 WHERE ($1 = 0 OR l.import_id = $1) AND ($2 = '' OR l.status = $2)
+		 ORDER BY l.txn_date, l.id
+		 LIMIT $3`, importID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []*models.BankStatementLine{}
+	for rows.Next() {
+		l, err := scanBankLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// SetMatch moves a line from one of the from statuses to status, recording the matched record.
+// Returns ErrBankLineConflict if the line is not in a from status or the record settles another line.
+func (r *BankStatementRepository) SetMatch(ctx context.Context, l *models.BankStatementLine, from ...string) error {
+	result, err := r.DB.Exec(ctx,
+		`UPDATE bank_statement_lines
+		 SET status = $2, match_reason = $3, rent_payment_id = $4, online_transaction_id = $5, ledger_entry_id = $6,
+		     resolved_by_user_id = $7, resolved_at = CASE WHEN $7::int IS NULL THEN NULL ELSE NOW() END, notes = $8
+		 WHERE id = $1 AND status = ANY($9)`,
+		l.ID, l.Status, l.MatchReason, l.RentPaymentID, l.OnlineTransactionID, l.LedgerEntryID,
+		l.ResolvedByUserID, l.Notes, from,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%w: the record already settles another bank line", ErrBankLineConflict)
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return fmt.Errorf("%w: the linked record does not exist", ErrBankLineConflict)
+	}
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: line %d is not %v", ErrBankLineConflict, l.ID, from)
+	}
+	return nil
+}
+
+// FindCandidates returns unlinked rent payments, successful online transactions and
+// receipt ledger entries that carry utr, or have this amount and a date in [start, end).
+// Ledger entries already represented by a payment or online transaction are left out.
+func (r *BankStatementRepository) FindCandidates(ctx context.Context, utr string, amount float64, start, end time.Time) ([]models.BankMatchCandidate, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT 'rent_payment', rp.id, rp.receipt_number, '', COALESCE(rp.customer_name, ''), rp.customer_phone, rp.amount_paid,
+		        COALESCE(rp.payment_date, rp.created_at),
+		        CASE WHEN $1 <> '' AND rp.notes ILIKE '%' || $1 || '%' THEN 'utr' ELSE 'amount_date' END
+		 FROM rent_payments rp
+		 WHERE NOT EXISTS (SELECT 1 FROM bank_statement_lines l WHERE l.rent_payment_id = rp.id)
+		   AND (($1 <> '' AND rp.notes ILIKE '%' || $1 || '%')
+		        OR (ABS(rp.amount_paid - $2) < 0.005 AND COALESCE(rp.payment_date, rp.created_at) >= $3 AND COALESCE(rp.payment_date, rp.created_at) < $4))
+
+		 UNION ALL
+
+		 SELECT 'online_transaction', ot.id, ot.razorpay_order_id, COALESCE(ot.utr_number, ''), COALESCE(ot.customer_name, ''), ot.customer_phone,
+		        ot.total_amount, COALESCE(ot.completed_at, ot.created_at),
+		        CASE WHEN $1 <> '' AND ot.utr_number = $1 THEN 'utr' ELSE 'amount_date' END
+		 FROM online_transactions ot
+		 WHERE ot.status = 'success'
+		   AND NOT EXISTS (SELECT 1 FROM bank_statement_lines l WHERE l.online_transaction_id = ot.id)
+		   AND (($1 <> '' AND ot.utr_number = $1)
+		        OR ((ABS(ot.total_amount - $2) < 0.005 OR ABS(ot.amount - $2) < 0.005)
+		            AND COALESCE(ot.completed_at, ot.created_at) >= $3 AND COALESCE(ot.completed_at, ot.created_at) < $4))
+
+		 UNION ALL
+
+		 SELECT 'ledger_entry', le.id, COALESCE(le.description, ''), '', COALESCE(le.customer_name, ''), le.customer_phone, le.credit, le.created_at,
+		        CASE WHEN $1 <> '' AND (le.description ILIKE '%' || $1 || '%' OR le.notes ILIKE '%' || $1 || '%') THEN 'utr' ELSE 'amount_date' END
+		 FROM ledger_entries le
+		 WHERE le.entry_type IN ('PAYMENT', 'ONLINE_PAYMENT')
+		   AND COALESCE(le.reference_type, '') NOT IN ('payment', 'online_transaction')
+		   AND NOT EXISTS (SELECT 1 FROM bank_statement_lines l WHERE l.ledger_entry_id = le.id)
+		   AND (($1 <> '' AND (le.description ILIKE '%' || $1 || '%' OR le.notes ILIKE '%' || $1 || '%'))
+		        OR (ABS(le.credit - $2) < 0.005 AND le.created_at >= $3 AND le.created_at < $4))
+
+		 ORDER BY 9 DESC, 8`,
+		utr, amount, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []models.BankMatchCandidate{}
+	for rows.Next() {
+		var c models.BankMatchCandidate
+		if err := rows.Scan(&c.Type, &c.ID, &c.Reference, &c.UTR, &c.CustomerName, &c.CustomerPhone, &c.Amount, &c.Date, &c.Reason); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// scanBankLine scans one bankLineSelect row
+func scanBankLine(row pgx.Row) (*models.BankStatementLine, error) {
+	var l models.BankStatementLine
+	err := row.Scan(&l.ID, &l.ImportID, &l.LineNumber, &l.TxnDate, &l.Description, &l.Reference, &l.UTR, &l.Amount, &l.Balance,
+		&l.Status, &l.MatchReason, &l.RentPaymentID, &l.OnlineTransactionID, &l.LedgerEntryID,
+		&l.ResolvedByUserID, &l.ResolvedByName, &l.ResolvedAt, &l.Notes, &l.LineHash, &l.CreatedAt,
+		&l.CustomerName, &l.CustomerPhone, &l.MatchedRef)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
@@ -17,23 +17,23 @@ func NewCustomerRepository(db *pgxpool.Pool) *CustomerRepository {
 
 func (r *CustomerRepository) Create(ctx context.Context, c *models.Customer) error {
 	return r.DB.QueryRow(ctx,
-		`INSERT INTO customers(name, phone, so, village, address)
-         VALUES($1, $2, $3, $4, $5)
+		`INSERT INTO customers(name, phone, so, village, address, state_code, gstin)
+         VALUES($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
          RETURNING id, created_at, updated_at`,
-		c.Name, c.Phone, c.SO, c.Village, c.Address,
+		c.Name, c.Phone, c.SO, c.Village, c.Address, c.StateCode, c.GSTIN,
 	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
 }
 
 func (r *CustomerRepository) Get(ctx context.Context, id int) (*models.Customer, error) {
 	row := r.DB.QueryRow(ctx,
 		`SELECT id, name, phone, COALESCE(so, '') as so, COALESCE(village, '') as village, COALESCE(address, '') as address,
-		 COALESCE(status, 'active') as status, merged_into_customer_id, merged_at,
+		 COALESCE(state_code, '') as state_code, COALESCE(gstin, '') as gstin, COALESCE(status, 'active') as status, merged_into_customer_id, merged_at,
 		 created_at, updated_at
          FROM customers WHERE id=$1`, id)
 
 	var customer models.Customer
 	err := row.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.SO, &customer.Village,
-		&customer.Address, &customer.Status, &customer.MergedIntoCustomerID, &customer.MergedAt,
+		&customer.Address, &customer.StateCode, &customer.GSTIN, &customer.Status, &customer.MergedIntoCustomerID, &customer.MergedAt,
 		&customer.CreatedAt, &customer.UpdatedAt)
 	return &customer, err
 }
@@ -41,13 +41,13 @@ func (r *CustomerRepository) Get(ctx context.Context, id int) (*models.Customer,
 func (r *CustomerRepository) GetByPhone(ctx context.Context, phone string) (*models.Customer, error) {
 	row := r.DB.QueryRow(ctx,
 		`SELECT id, name, phone, COALESCE(so, '') as so, COALESCE(village, '') as village, COALESCE(address, '') as address,
-		 COALESCE(status, 'active') as status, merged_into_customer_id, merged_at,
+		 COALESCE(state_code, '') as state_code, COALESCE(gstin, '') as gstin, COALESCE(status, 'active') as status, merged_into_customer_id, merged_at,
 		 created_at, updated_at
          FROM customers WHERE phone=$1`, phone)
 
 	var customer models.Customer
 	err := row.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.SO, &customer.Village,
-		&customer.Address, &customer.Status, &customer.MergedIntoCustomerID, &customer.MergedAt,
+		&customer.Address, &customer.StateCode, &customer.GSTIN, &customer.Status, &customer.MergedIntoCustomerID, &customer.MergedAt,
 		&customer.CreatedAt, &customer.UpdatedAt)
 	return &customer, err
 }
@@ -55,7 +55,7 @@ func (r *CustomerRepository) GetByPhone(ctx context.Context, phone string) (*mod
 func (r *CustomerRepository) List(ctx context.Context) ([]*models.Customer, error) {
 	rows, err := r.DB.Query(ctx,
 		`SELECT id, name, phone, COALESCE(so, '') as so, COALESCE(village, '') as village, COALESCE(address, '') as address,
-		 COALESCE(status, 'active') as status, merged_into_customer_id, merged_at,
+		 COALESCE(state_code, '') as state_code, COALESCE(gstin, '') as gstin, COALESCE(status, 'active') as status, merged_into_customer_id, merged_at,
 		 created_at, updated_at
          FROM customers ORDER BY created_at DESC`)
 	if err != nil {
@@ -67,7 +67,7 @@ func (r *CustomerRepository) List(ctx context.Context) ([]*models.Customer, erro
 	for rows.Next() {
 		var customer models.Customer
 		err := rows.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.SO, &customer.Village,
-			&customer.Address, &customer.Status, &customer.MergedIntoCustomerID, &customer.MergedAt,
+			&customer.Address, &customer.StateCode, &customer.GSTIN, &customer.Status, &customer.MergedIntoCustomerID, &customer.MergedAt,
 			&customer.CreatedAt, &customer.UpdatedAt)
 		if err != nil {
 			return nil, err
@@ -80,9 +80,11 @@ func (r *CustomerRepository) List(ctx context.Context) ([]*models.Customer, erro
 func (r *CustomerRepository) Update(ctx context.Context, c *models.Customer) error {
 	// Update customer
 	_, err := r.DB.Exec(ctx,
-		`UPDATE customers SET name=$1, phone=$2, so=$3, village=$4, address=$5, updated_at=CURRENT_TIMESTAMP
+		`UPDATE customers SET name=$1, phone=$2, so=$3, village=$4, address=$5,
+         state_code=COALESCE(NULLIF($7, ''), state_code), gstin=COALESCE(NULLIF($8, ''), gstin),
+         updated_at=CURRENT_TIMESTAMP
          WHERE id=$6`,
-		c.Name, c.Phone, c.SO, c.Village, c.Address, c.ID)
+		c.Name, c.Phone, c.SO, c.Village, c.Address, c.ID, c.StateCode, c.GSTIN)
 	if err != nil {
 		return err
 	}
@@ -92,7 +94,20 @@ func (r *CustomerRepository) Update(ctx context.Context, c *models.Customer) err
 		`UPDATE entries SET name=$1, phone=$2, so=$3, village=$4, updated_at=CURRENT_TIMESTAMP
          WHERE customer_id=$5`,
 		c.Name, c.Phone, c.SO, c.Village, c.ID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Ledger, payments and debt requests are keyed by customer_id; keep their display phone in sync
+	for _, table := range []string{"ledger_entries", "rent_payments", "debt_requests"} {
+		_, err = r.DB.Exec(ctx,
+			`UPDATE `+table+` SET customer_phone=$1 WHERE customer_id=$2 AND customer_phone<>$1`,
+			c.Phone, c.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *CustomerRepository) Delete(ctx context.Context, id int) error {
@@ -144,8 +159,8 @@ func (r *CustomerRepository) MergeCustomers(ctx context.Context, sourceID, targe
 	// Collect payment details BEFORE moving (for audit trail)
 	paymentRows, err := tx.Query(ctx, `
 		SELECT id, amount_paid, COALESCE(receipt_number, ''), TO_CHAR(payment_date, 'DD/MM/YYYY')
-		FROM rent_payments WHERE customer_phone=$1`,
-		sourcePhone)
+		FROM rent_payments WHERE customer_id=$1 OR (customer_id IS NULL AND customer_phone=$2)`,
+		sourceID, sourcePhone)
 	if err != nil {
 		return 0, 0, nil, err
 	}
@@ -197,9 +212,29 @@ func (r *CustomerRepository) MergeCustomers(ctx context.Context, sourceID, targe
 	// Transfer all rent payments from source customer to target customer
 	_, err = tx.Exec(ctx, `
 		UPDATE rent_payments
-		SET customer_name=$1, customer_phone=$2
-		WHERE customer_phone=$3`,
-		targetName, targetPhone, sourcePhone)
+		SET customer_id=$1, customer_name=$2, customer_phone=$3
+		WHERE customer_id=$4 OR (customer_id IS NULL AND customer_phone=$5)`,
+		targetID, targetName, targetPhone, sourceID, sourcePhone)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	// Move the ledger history (remember the owner so the merge can be undone)
+	_, err = tx.Exec(ctx, `
+		UPDATE ledger_entries
+		SET original_customer_id=COALESCE(original_customer_id, $1), customer_id=$2, customer_phone=$3
+		WHERE customer_id=$1 OR (customer_id IS NULL AND customer_phone=$4)`,
+		sourceID, targetID, targetPhone, sourcePhone)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	// Move open debt requests (remember the owner so the merge can be undone)
+	_, err = tx.Exec(ctx, `
+		UPDATE debt_requests
+		SET original_customer_id=COALESCE(original_customer_id, $1), customer_id=$2, customer_phone=$3
+		WHERE customer_id=$1 OR (customer_id IS NULL AND customer_phone=$4)`,
+		sourceID, targetID, targetPhone, sourcePhone)
 	if err != nil {
 		return 0, 0, nil, err
 	}
@@ -258,7 +293,8 @@ func (r *CustomerRepository) GetMergedCustomers(ctx context.Context) ([]*models.
 // UndoMerge fully reverses a merge:
 // 1. Moves all entries back to source customer
 // 2. Moves specified payments back to source customer (using IDs from merge log)
-// 3. Sets source customer status back to active
+// 3. Moves ledger history and debt requests back to source customer
+// 4. Sets source customer status back to active
 func (r *CustomerRepository) UndoMerge(ctx context.Context, sourceCustomerID int, paymentIDs []int) error {
 	// Start transaction
 	tx, err := r.DB.Begin(ctx)
@@ -293,14 +329,34 @@ func (r *CustomerRepository) UndoMerge(ctx context.Context, sourceCustomerID int
 	if len(paymentIDs) > 0 {
 		_, err = tx.Exec(ctx, `
 			UPDATE rent_payments
-			SET customer_name = $1, customer_phone = $2
-			WHERE id = ANY($3)`,
-			sourceName, sourcePhone, paymentIDs)
+			SET customer_id = $1, customer_name = $2, customer_phone = $3
+			WHERE id = ANY($4)`,
+			sourceCustomerID, sourceName, sourcePhone, paymentIDs)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Move ledger history back to source customer (rows moved during merge)
+	_, err = tx.Exec(ctx, `
+		UPDATE ledger_entries
+		SET customer_id = $1, customer_phone = $2, original_customer_id = NULL
+		WHERE original_customer_id = $1`,
+		sourceCustomerID, sourcePhone)
+	if err != nil {
+		return err
+	}
+
+	// Move debt requests back to source customer (rows moved during merge)
+	_, err = tx.Exec(ctx, `
+		UPDATE debt_requests
+		SET customer_id = $1, customer_phone = $2, original_customer_id = NULL
+		WHERE original_customer_id = $1`,
+		sourceCustomerID, sourcePhone)
+	if err != nil {
+		return err
+	}
+
 	// Set source customer status back to active
 	_, err = tx.Exec(ctx, `
 		UPDATE customers
@@ -29,12 +29,15 @@ func (r *DebtRequestRepository) Create(ctx context.Context, req *models.CreateDe
 		INSERT INTO debt_requests (
 			customer_phone, customer_name, customer_so, thock_number,
 			requested_quantity, current_balance, requested_by_user_id,
-			requested_by_name, status, expires_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'pending', $9)
-		RETURNING id, created_at
+			requested_by_name, status, expires_at, customer_id, family_member_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'pending', $9,
+			COALESCE(NULLIF($10, 0), (SELECT COALESCE(c.merged_into_customer_id, c.id) FROM customers c WHERE c.phone = $1)),
+			(SELECT e.family_member_id FROM entries e WHERE e.thock_number = $4 ORDER BY e.id DESC LIMIT 1))
+		RETURNING id, COALESCE(customer_id, 0), family_member_id, created_at
 	`
 
-	var id int
+	var id, customerID int
+	var familyMemberID *int
 	var createdAt time.Time
 	err := r.DB.QueryRow(ctx, query,
 		req.CustomerPhone,
@@ -46,7 +49,8 @@ func (r *DebtRequestRepository) Create(ctx context.Context, req *models.CreateDe
 		requestedByUserID,
 		requestedByName,
 		expiresAt,
-	).Scan(&id, &createdAt)
+		req.CustomerID,
+	).Scan(&id, &customerID, &familyMemberID, &createdAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create debt request: %w", err)
@@ -54,6 +58,8 @@ func (r *DebtRequestRepository) Create(ctx context.Context, req *models.CreateDe
 
 	return &models.DebtRequest{
 		ID:                id,
+		CustomerID:        customerID,
+		FamilyMemberID:    familyMemberID,
 		CustomerPhone:     req.CustomerPhone,
 		CustomerName:      req.CustomerName,
 		CustomerSO:        req.CustomerSO,
@@ -71,7 +77,7 @@ func (r *DebtRequestRepository) Create(ctx context.Context, req *models.CreateDe
 // GetByID returns a debt request by ID
 func (r *DebtRequestRepository) GetByID(ctx context.Context, id int) (*models.DebtRequest, error) {
 	query := `
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
+		SELECT id, COALESCE(customer_id, 0), family_member_id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
 			thock_number, requested_quantity, current_balance,
 			requested_by_user_id, COALESCE(requested_by_name, '') as requested_by_name,
 			status, approved_by_user_id, COALESCE(approved_by_name, '') as approved_by_name,
@@ -88,7 +94,7 @@ func (r *DebtRequestRepository) GetByID(ctx context.Context, id int) (*models.De
 	var expiresAt *time.Time
 
 	err := r.DB.QueryRow(ctx, query, id).Scan(
-		&d.ID, &d.CustomerPhone, &d.CustomerName, &d.CustomerSO,
+		&d.ID, &d.CustomerID, &d.FamilyMemberID, &d.CustomerPhone, &d.CustomerName, &d.CustomerSO,
 		&d.ThockNumber, &d.RequestedQuantity, &d.CurrentBalance,
 		&d.RequestedByUserID, &d.RequestedByName,
 		&d.Status, &approvedByUserID, &d.ApprovedByName,
@@ -117,7 +123,7 @@ func (r *DebtRequestRepository) GetPending(ctx context.Context) ([]models.DebtRe
 	r.ExpireOldRequests(ctx)
 
 	query := `
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
+		SELECT id, COALESCE(customer_id, 0), family_member_id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
 			thock_number, requested_quantity, current_balance,
 			requested_by_user_id, COALESCE(requested_by_name, '') as requested_by_name,
 			status, approved_by_user_id, COALESCE(approved_by_name, '') as approved_by_name,
@@ -134,14 +140,14 @@ func (r *DebtRequestRepository) GetPending(ctx context.Context) ([]models.DebtRe
 // GetByCustomer returns debt requests for a customer
 func (r *DebtRequestRepository) GetByCustomer(ctx context.Context, customerPhone string) ([]models.DebtRequest, error) {
 	query := `
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
+		SELECT id, COALESCE(customer_id, 0), family_member_id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
 			thock_number, requested_quantity, current_balance,
 			requested_by_user_id, COALESCE(requested_by_name, '') as requested_by_name,
 			status, approved_by_user_id, COALESCE(approved_by_name, '') as approved_by_name,
 			approved_at, COALESCE(rejection_reason, '') as rejection_reason,
 			gate_pass_id, created_at, expires_at
 		FROM debt_requests
-		WHERE customer_phone = $1
+		WHERE ` + customerByPhone + `
 		ORDER BY created_at DESC
 	`
 
@@ -151,14 +157,14 @@ func (r *DebtRequestRepository) GetByCustomer(ctx context.Context, customerPhone
 // GetApprovedForCustomerAndThock returns an approved (not used) debt request for a specific customer and thock
 func (r *DebtRequestRepository) GetApprovedForCustomerAndThock(ctx context.Context, customerPhone, thockNumber string) (*models.DebtRequest, error) {
 	query := `
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
+		SELECT id, COALESCE(customer_id, 0), family_member_id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
 			thock_number, requested_quantity, current_balance,
 			requested_by_user_id, COALESCE(requested_by_name, '') as requested_by_name,
 			status, approved_by_user_id, COALESCE(approved_by_name, '') as approved_by_name,
 			approved_at, COALESCE(rejection_reason, '') as rejection_reason,
 			gate_pass_id, created_at, expires_at
 		FROM debt_requests
-		WHERE customer_phone = $1 AND thock_number = $2 AND status = 'approved'
+		WHERE ` + customerByPhone + ` AND thock_number = $2 AND status = 'approved'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
@@ -179,6 +185,12 @@ func (r *DebtRequestRepository) GetAll(ctx context.Context, filter *models.DebtR
 	var args []interface{}
 	argNum := 1
 
+	if filter.CustomerID > 0 {
+		conditions = append(conditions, fmt.Sprintf("customer_id = $%d", argNum))
+		args = append(args, filter.CustomerID)
+		argNum++
+	}
+
 	if filter.CustomerPhone != "" {
 		conditions = append(conditions, fmt.Sprintf("customer_phone = $%d", argNum))
 		args = append(args, filter.CustomerPhone)
@@ -226,7 +238,7 @@ func (r *DebtRequestRepository) GetAll(ctx context.Context, filter *models.DebtR
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
+		SELECT id, COALESCE(customer_id, 0), family_member_id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
 			thock_number, requested_quantity, current_balance,
 			requested_by_user_id, COALESCE(requested_by_name, '') as requested_by_name,
 			status, approved_by_user_id, COALESCE(approved_by_name, '') as approved_by_name,
@@ -323,6 +335,12 @@ func (r *DebtRequestRepository) ExpireOldRequests(ctx context.Context) error {
 	return err
 }
 
+// Expire expires one pending request, when its approval runs out of time
+func (r *DebtRequestRepository) Expire(ctx context.Context, id int) error {
+	_, err := r.DB.Exec(ctx, `UPDATE debt_requests SET status = 'expired' WHERE id = $1 AND status = 'pending'`, id)
+	return err
+}
+
 // GetPendingSummary returns summary of pending requests for dashboard
 func (r *DebtRequestRepository) GetPendingSummary(ctx context.Context) (*models.PendingDebtRequestSummary, error) {
 	query := `
@@ -380,7 +398,7 @@ func (r *DebtRequestRepository) queryDebtRequestsWithArgs(ctx context.Context, q
 		var expiresAt *time.Time
 
 		err := rows.Scan(
-			&d.ID, &d.CustomerPhone, &d.CustomerName, &d.CustomerSO,
+			&d.ID, &d.CustomerID, &d.FamilyMemberID, &d.CustomerPhone, &d.CustomerName, &d.CustomerSO,
 			&d.ThockNumber, &d.RequestedQuantity, &d.CurrentBalance,
 			&d.RequestedByUserID, &d.RequestedByName,
 			&d.Status, &approvedByUserID, &d.ApprovedByName,
@@ -2,9 +2,11 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"cold-backend/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -27,121 +29,93 @@ func (r *EntryRepository) Create(ctx context.Context, e *models.Entry) error {
 	return r.CreateWithSkipRanges(ctx, e, nil)
 }
 
-// CreateWithSkipRanges creates an entry with thock number that skips specified ranges
+// CreateWithSkipRanges creates an entry with the next thock number of the current season,
+// skipping the specified ranges
 func (r *EntryRepository) CreateWithSkipRanges(ctx context.Context, e *models.Entry, skipRanges []SkipRange) error {
+	return r.CreateNumbered(ctx, e, skipRanges, 0)
+}
+
+// CreateNumbered creates an entry numbered from its category's series in the current season.
+// The series stays locked until the entry is written, so concurrent entries never share a
+// number and a failed insert leaves no gap. A reservedNumber > 0 uses that reserved number.
+func (r *EntryRepository) CreateNumbered(ctx context.Context, e *models.Entry, skipRanges []SkipRange, reservedNumber int) error {
 	if e.ThockCategory != "seed" && e.ThockCategory != "sell" {
 		return fmt.Errorf("invalid thock category: %s", e.ThockCategory)
 	}
 
-	// Determine the base offset for the category
-	var baseOffset int
-	if e.ThockCategory == "seed" {
-		baseOffset = 1 // SEED starts at 1
-	} else {
-		baseOffset = 1501 // SELL starts at 1501
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	// If no skip ranges, use the original simple query
-	if len(skipRanges) == 0 {
-		query := `
-			WITH next_num AS (
-				SELECT COALESCE(COUNT(*), 0) + $1 as num
-				FROM entries
-				WHERE thock_category = $2
-			)
-			INSERT INTO entries(customer_id, phone, name, village, so, expected_quantity, thock_category, thock_number, remark, created_by_user_id, family_member_id, family_member_name)
-			SELECT $3, $4, $5, $6, $7, $8::integer, $9::text,
-				CASE WHEN $9::text = 'seed'
-					THEN LPAD(num::text, 4, '0') || '/' || $8::text
-					ELSE num::text || '/' || $8::text
-				END,
-				$10,
-				$11,
-				$12,
-				$13
-			FROM next_num
-			RETURNING id, thock_number, created_at, updated_at
-		`
-
-		return r.DB.QueryRow(ctx, query,
-			baseOffset,           // $1
-			e.ThockCategory,      // $2
-			e.CustomerID,         // $3
-			e.Phone,              // $4
-			e.Name,               // $5
-			e.Village,            // $6
-			e.SO,                 // $7
-			e.ExpectedQuantity,   // $8
-			e.ThockCategory,      // $9
-			e.Remark,             // $10
-			e.CreatedByUserID,    // $11
-			e.FamilyMemberID,     // $12
-			e.FamilyMemberName,   // $13
-		).Scan(&e.ID, &e.ThockNumber, &e.CreatedAt, &e.UpdatedAt)
+	var season string
+	if err := tx.QueryRow(ctx, currentThockSeasonQuery).Scan(&season); err != nil {
+		return fmt.Errorf("failed to get thock season: %w", err)
 	}
-
-	// With skip ranges, use MAX thock number to find the highest used number
-	// This correctly handles entries created after skip ranges
-	var maxThock int
-	query := `
-		SELECT COALESCE(MAX(
-			CAST(SPLIT_PART(thock_number, '/', 1) AS INTEGER)
-		), $1 - 1)
-		FROM entries
-		WHERE thock_category = $2
-	`
-	err := r.DB.QueryRow(ctx, query, baseOffset, e.ThockCategory).Scan(&maxThock)
+	last, err := lockThockSeries(ctx, tx, season, e.ThockCategory)
 	if err != nil {
-		return fmt.Errorf("failed to get max thock number: %w", err)
+		return fmt.Errorf("failed to lock thock series: %w", err)
 	}
 
-	// Next number is max + 1
-	nextNum := maxThock + 1
-
-	// Apply skip ranges - if next number is in a skip range, jump past it
-	for {
-		inSkipRange := false
-		for _, sr := range skipRanges {
-			if nextNum >= sr.From && nextNum <= sr.To {
-				nextNum = sr.To + 1
-				inSkipRange = true
-				break
-			}
+	number := reservedNumber
+	if reservedNumber > 0 {
+		var status string
+		err := tx.QueryRow(ctx,
+			`SELECT status FROM thock_number_log WHERE season = $1 AND category = $2 AND number = $3 FOR UPDATE`,
+			season, e.ThockCategory, reservedNumber).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && status != models.ThockNumberReserved) {
+			return fmt.Errorf("%w: %s number %d", ErrThockNumberNotReserved, e.ThockCategory, reservedNumber)
 		}
-		if !inSkipRange {
-			break
+		if err != nil {
+			return err
 		}
-	}
-
-	// Format the thock number
-	var thockNumber string
-	if e.ThockCategory == "seed" {
-		thockNumber = fmt.Sprintf("%04d/%d", nextNum, e.ExpectedQuantity)
 	} else {
-		thockNumber = fmt.Sprintf("%d/%d", nextNum, e.ExpectedQuantity)
+		number, err = nextFreeThockNumber(ctx, tx, season, e.ThockCategory, last+1, skipRanges, true)
+		if err != nil {
+			return fmt.Errorf("failed to get next thock number: %w", err)
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE thock_number_series SET last_number = $3, updated_at = NOW() WHERE season = $1 AND category = $2`,
+			season, e.ThockCategory, number)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Insert with the calculated thock number
-	insertQuery := `
-		INSERT INTO entries(customer_id, phone, name, village, so, expected_quantity, thock_category, thock_number, remark, created_by_user_id, family_member_id, family_member_name)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, thock_number, created_at, updated_at
-	`
-
-	return r.DB.QueryRow(ctx, insertQuery,
-		e.CustomerID,         // $1
-		e.Phone,              // $2
-		e.Name,               // $3
-		e.Village,            // $4
-		e.SO,                 // $5
-		e.ExpectedQuantity,   // $6
-		e.ThockCategory,      // $7
-		thockNumber,          // $8
-		e.Remark,             // $9
-		e.CreatedByUserID,    // $10
-		e.FamilyMemberID,     // $11
-		e.FamilyMemberName,   // $12
+	thockNumber := formatThockNumber(e.ThockCategory, number, e.ExpectedQuantity)
+	err = tx.QueryRow(ctx,
+		`INSERT INTO entries(customer_id, phone, name, village, so, expected_quantity, thock_category, thock_number, remark, created_by_user_id, family_member_id, family_member_name)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		 RETURNING id, thock_number, created_at, updated_at`,
+		e.CustomerID,       // $1
+		e.Phone,            // $2
+		e.Name,             // $3
+		e.Village,          // $4
+		e.SO,               // $5
+		e.ExpectedQuantity, // $6
+		e.ThockCategory,    // $7
+		thockNumber,        // $8
+		e.Remark,           // $9
+		e.CreatedByUserID,  // $10
+		e.FamilyMemberID,   // $11
+		e.FamilyMemberName, // $12
 	).Scan(&e.ID, &e.ThockNumber, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO thock_number_log (season, category, number, status, entry_id, thock_number, user_id)
+		 VALUES ($1, $2, $3, 'issued', $4, $5, $6)
+		 ON CONFLICT (season, category, number)
+		 DO UPDATE SET status = 'issued', entry_id = EXCLUDED.entry_id, thock_number = EXCLUDED.thock_number,
+		     user_id = EXCLUDED.user_id, updated_at = NOW()`,
+		season, e.ThockCategory, number, e.ID, e.ThockNumber, e.CreatedByUserID)
+	if err != nil {
+		return fmt.Errorf("failed to record thock number: %w", err)
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *EntryRepository) Get(ctx context.Context, id int) (*models.Entry, error) {
@@ -357,6 +331,26 @@ func (r *EntryRepository) GetByThockNumber(ctx context.Context, thockNumber stri
 	return &entry, err
 }
 
+// FindByScannedThock returns the entry a label's thock number refers to. The quantity
+// after the slash changes when an entry is edited, so an older label is matched on the
+// number before it.
+func (r *EntryRepository) FindByScannedThock(ctx context.Context, thockNumber string) (*models.Entry, error) {
+	entry, err := r.GetByThockNumber(ctx, thockNumber)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return entry, err
+	}
+
+	var current string
+	err = r.DB.QueryRow(ctx,
+		`SELECT thock_number FROM entries
+		 WHERE SPLIT_PART(thock_number, '/', 1) = SPLIT_PART($1, '/', 1) AND COALESCE(status, 'active') != 'deleted'
+		 ORDER BY id DESC LIMIT 1`, thockNumber).Scan(&current)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByThockNumber(ctx, current)
+}
+
 // ReassignCustomer reassigns an entry to a different customer
 // Tracks the transfer by updating status and transferred_to fields
 func (r *EntryRepository) ReassignCustomer(ctx context.Context, entryID int, newCustomerID int, name, phone, village, so string, familyMemberID *int, familyMemberName string) error {
@@ -0,0 +1,391 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrGatarStockShort is returned when a gatar no longer holds the bags a transfer moves out
+var ErrGatarStockShort = errors.New("not enough bags in gatar")
+
+// GatarStockRepository stores the per-gatar stock ledger (gatar_stock_movements)
+type GatarStockRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewGatarStockRepository(db *pgxpool.Pool) *GatarStockRepository {
+	return &GatarStockRepository{DB: db}
+}
+
+// lockThockStock holds a thock's stock for the rest of the transaction. Every change to the
+// thock's ledger (room entries, edits, pickups, transfers) takes it before reading balances.
+func lockThockStock(ctx context.Context, tx pgx.Tx, thockNumber string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('gatar_stock:' || $1::text))`, thockNumber)
+	return err
+}
+
+func insertMovements(ctx context.Context, tx pgx.Tx, movements []*models.GatarStockMovement) error {
+	for _, m := range movements {
+		err := tx.QueryRow(ctx,
+			`INSERT INTO gatar_stock_movements (room_entry_id, thock_number, room_no, floor, gatar_no, quantity,
+			                                    movement_type, source_type, source_id, quality, notes, created_by_user_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			 RETURNING id, created_at`,
+			m.RoomEntryID, m.ThockNumber, m.RoomNo, m.Floor, m.GatarNo, m.Quantity,
+			m.MovementType, m.SourceType, m.SourceID, m.Quality, m.Notes, m.CreatedByUserID,
+		).Scan(&m.ID, &m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record gatar %d movement: %w", m.GatarNo, err)
+		}
+	}
+	return nil
+}
+
+// GatarSpaceCheck decides whether the bags a change adds to gatars fit. It runs inside the
+// change's transaction with those gatars locked: adding is the bags going into each gatar,
+// stock what it holds now and capacity the bag capacity of the gatars in the layout.
+type GatarSpaceCheck func(adding, stock, capacity map[int]int) error
+
+// checkGatarSpace locks the gatars that movements add bags to and runs check against their
+// current stock. Gatars are locked in number order so concurrent changes cannot deadlock.
+func checkGatarSpace(ctx context.Context, tx pgx.Tx, movements []*models.GatarStockMovement, check GatarSpaceCheck) error {
+	if check == nil {
+		return nil
+	}
+	net := make(map[int]int)
+	for _, m := range movements {
+		net[m.GatarNo] += m.Quantity
+	}
+	adding := make(map[int]int)
+	var gatars []int
+	for g, qty := range net {
+		if qty > 0 {
+			adding[g] = qty
+			gatars = append(gatars, g)
+		}
+	}
+	if len(gatars) == 0 {
+		return nil
+	}
+	sort.Ints(gatars)
+
+	capacity, err := scanGatarCounts(tx.Query(ctx,
+		`SELECT gatar_no, capacity_bags FROM warehouse_gatars
+		 WHERE gatar_no = ANY($1)
+		 ORDER BY gatar_no
+		 FOR UPDATE`, gatars))
+	if err != nil {
+		return fmt.Errorf("failed to lock gatars: %w", err)
+	}
+	stock, err := scanGatarCounts(tx.Query(ctx,
+		`SELECT m.gatar_no, SUM(m.quantity)
+		 FROM gatar_stock_movements m
+		 JOIN room_entries re ON re.id = m.room_entry_id
+		 LEFT JOIN entries e ON e.id = re.entry_id
+		 WHERE m.gatar_no = ANY($1)
+		   AND COALESCE(e.status, 'active') != 'deleted'
+		 GROUP BY m.gatar_no`, gatars))
+	if err != nil {
+		return fmt.Errorf("failed to load gatar stock: %w", err)
+	}
+	return check(adding, stock, capacity)
+}
+
+// scanGatarCounts reads gatar number / count rows into a map
+func scanGatarCounts(rows pgx.Rows, err error) (map[int]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var gatarNo, count int
+		if err := rows.Scan(&gatarNo, &count); err != nil {
+			return nil, err
+		}
+		counts[gatarNo] = count
+	}
+	return counts, rows.Err()
+}
+
+// balanceQuery sums the ledger per room entry and gatar, leaving out deleted entries
+const balanceQuery = `
+	SELECT m.room_entry_id, m.thock_number, m.room_no, m.floor, m.gatar_no,
+	       SUM(m.quantity),
+	       SUM(CASE WHEN m.movement_type IN ('in', 'edit', 'legacy_in') THEN m.quantity ELSE 0 END)
+	FROM gatar_stock_movements m
+	JOIN room_entries re ON re.id = m.room_entry_id
+	LEFT JOIN entries e ON e.id = re.entry_id
+	WHERE COALESCE(e.status, 'active') != 'deleted'`
+
+// stockQuerier is satisfied by both the pool and a transaction
+type stockQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// ListBalances returns the gatars holding stock, per room entry; thockNumber "" lists all thocks
+func (r *GatarStockRepository) ListBalances(ctx context.Context, thockNumber string) ([]*models.GatarBalance, error) {
+	return thockBalances(ctx, r.DB, thockNumber)
+}
+
+func thockBalances(ctx context.Context, q stockQuerier, thockNumber string) ([]*models.GatarBalance, error) {
+	rows, err := q.Query(ctx, balanceQuery+`
+		  AND ($1 = '' OR m.thock_number = $1)
+		GROUP BY m.room_entry_id, m.thock_number, m.room_no, m.floor, m.gatar_no
+		HAVING SUM(m.quantity) <> 0
+		ORDER BY m.room_entry_id, m.gatar_no`, thockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return collectBalances(rows)
+}
+
+// ListEntryBalances returns every gatar a room entry has used, including emptied ones
+func (r *GatarStockRepository) ListEntryBalances(ctx context.Context, roomEntryID int) ([]*models.GatarBalance, error) {
+	return entryBalances(ctx, r.DB, roomEntryID)
+}
+
+func entryBalances(ctx context.Context, q stockQuerier, roomEntryID int) ([]*models.GatarBalance, error) {
+	rows, err := q.Query(ctx, balanceQuery+`
+		  AND m.room_entry_id = $1
+		GROUP BY m.room_entry_id, m.thock_number, m.room_no, m.floor, m.gatar_no
+		ORDER BY m.gatar_no`, roomEntryID)
+	if err != nil {
+		return nil, err
+	}
+	return collectBalances(rows)
+}
+
+func collectBalances(rows pgx.Rows) ([]*models.GatarBalance, error) {
+	defer rows.Close()
+
+	var balances []*models.GatarBalance
+	for rows.Next() {
+		b := &models.GatarBalance{}
+		if err := rows.Scan(&b.RoomEntryID, &b.ThockNumber, &b.RoomNo, &b.Floor, &b.GatarNo, &b.Quantity, &b.Placed); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// GatarStock represents stock information for a single gatar
+type GatarStock struct {
+	GatarNo     int    `json:"gatar_no"`
+	Quantity    int    `json:"quantity"`
+	Variety     string `json:"variety"`
+	ThockNumber string `json:"thock_number"`
+	Quality     string `json:"quality"`
+	RoomEntryID int    `json:"room_entry_id"`
+}
+
+// GetStockByRoomFloor returns the current bags in each gatar of a floor
+func (r *GatarStockRepository) GetStockByRoomFloor(ctx context.Context, roomNo string, floor string) ([]GatarStock, error) {
+	rows, err := r.DB.Query(ctx,
+		`WITH balances AS (
+		     SELECT m.room_entry_id, m.thock_number, m.gatar_no, SUM(m.quantity) AS quantity,
+		            MAX(NULLIF(m.quality, '')) AS quality, COALESCE(MAX(e.remark), '') AS variety
+		     FROM gatar_stock_movements m
+		     JOIN room_entries re ON re.id = m.room_entry_id
+		     LEFT JOIN entries e ON e.id = re.entry_id
+		     WHERE m.room_no = $1 AND m.floor = $2
+		       AND COALESCE(e.status, 'active') != 'deleted'
+		     GROUP BY m.room_entry_id, m.thock_number, m.gatar_no
+		     HAVING SUM(m.quantity) > 0
+		 )
+		 SELECT gatar_no, SUM(quantity),
+		        STRING_AGG(DISTINCT variety, ', '),
+		        STRING_AGG(DISTINCT thock_number, ', '),
+		        COALESCE(MAX(quality), ''),
+		        MAX(room_entry_id)
+		 FROM balances
+		 GROUP BY gatar_no
+		 ORDER BY gatar_no`, roomNo, floor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []GatarStock
+	for rows.Next() {
+		var s GatarStock
+		err := rows.Scan(&s.GatarNo, &s.Quantity, &s.Variety, &s.ThockNumber, &s.Quality, &s.RoomEntryID)
+		if err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, s)
+	}
+	return stocks, rows.Err()
+}
+
+// GatarSearchResult represents a search result with gatar-level details
+type GatarSearchResult struct {
+	RoomEntryID int    `json:"room_entry_id"`
+	EntryID     int    `json:"entry_id"`
+	ThockNumber string `json:"thock_number"`
+	RoomNo      string `json:"room_no"`
+	Floor       string `json:"floor"`
+	GatarNo     int    `json:"gatar_no"`
+	Quantity    int    `json:"quantity"`
+	Quality     string `json:"quality"`
+	Variety     string `json:"variety"`
+	CustomerID  int    `json:"customer_id"`
+}
+
+// SearchByGatar returns the room entries with bags in a gatar and how many each has there
+func (r *GatarStockRepository) SearchByGatar(ctx context.Context, roomNo string, floor string, gatarNo int) ([]GatarSearchResult, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT m.room_entry_id, re.entry_id, m.thock_number, m.room_no, m.floor,
+		        m.gatar_no, SUM(m.quantity), COALESCE(MAX(NULLIF(m.quality, '')), ''),
+		        COALESCE(MAX(e.remark), '') as variety, COALESCE(MAX(e.customer_id), 0)
+		 FROM gatar_stock_movements m
+		 JOIN room_entries re ON re.id = m.room_entry_id
+		 LEFT JOIN entries e ON e.id = re.entry_id
+		 WHERE m.room_no = $1 AND m.floor = $2 AND m.gatar_no = $3
+		   AND COALESCE(e.status, 'active') != 'deleted'
+		 GROUP BY m.room_entry_id, re.entry_id, m.thock_number, m.room_no, m.floor, m.gatar_no
+		 HAVING SUM(m.quantity) > 0
+		 ORDER BY MAX(m.created_at) DESC`, roomNo, floor, gatarNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []GatarSearchResult
+	for rows.Next() {
+		var r GatarSearchResult
+		err := rows.Scan(&r.RoomEntryID, &r.EntryID, &r.ThockNumber, &r.RoomNo, &r.Floor,
+			&r.GatarNo, &r.Quantity, &r.Quality, &r.Variety, &r.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// ListMovements returns ledger rows for a thock and/or gatar, newest first
+func (r *GatarStockRepository) ListMovements(ctx context.Context, thockNumber string, gatarNo int, limit int) ([]*models.GatarStockMovement, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT m.id, m.room_entry_id, m.thock_number, m.room_no, m.floor, m.gatar_no, m.quantity,
+		        m.movement_type, m.source_type, m.source_id, m.quality, m.notes,
+		        m.created_by_user_id, COALESCE(u.name, ''), m.created_at
+		 FROM gatar_stock_movements m
+		 LEFT JOIN users u ON u.id = m.created_by_user_id
+		 WHERE ($1 = '' OR m.thock_number = $1)
+		   AND ($2 = 0 OR m.gatar_no = $2)
+		 ORDER BY m.created_at DESC, m.id DESC
+		 LIMIT $3`, thockNumber, gatarNo, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []*models.GatarStockMovement
+	for rows.Next() {
+		m := &models.GatarStockMovement{}
+		err := rows.Scan(&m.ID, &m.RoomEntryID, &m.ThockNumber, &m.RoomNo, &m.Floor, &m.GatarNo, &m.Quantity,
+			&m.MovementType, &m.SourceType, &m.SourceID, &m.Quality, &m.Notes,
+			&m.CreatedByUserID, &m.CreatedByName, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// CreateTransfer saves a stock transfer and its ledger movements in one transaction. It holds a
+// lock on the thock's stock and re-checks the source balances, so concurrent transfers cannot
+// move the same bags twice; check runs with the destination gatar locked.
+func (r *GatarStockRepository) CreateTransfer(ctx context.Context, t *models.StockTransfer, movements []*models.GatarStockMovement, check GatarSpaceCheck) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockThockStock(ctx, tx, t.ThockNumber); err != nil {
+		return err
+	}
+	for _, m := range movements {
+		if m.Quantity >= 0 {
+			continue
+		}
+		var balance int
+		err := tx.QueryRow(ctx,
+			`SELECT COALESCE(SUM(quantity), 0) FROM gatar_stock_movements
+			 WHERE room_entry_id = $1 AND thock_number = $2 AND room_no = $3 AND floor = $4 AND gatar_no = $5`,
+			m.RoomEntryID, m.ThockNumber, m.RoomNo, m.Floor, m.GatarNo,
+		).Scan(&balance)
+		if err != nil {
+			return err
+		}
+		if balance < -m.Quantity {
+			return fmt.Errorf("%w: thock %s has %d bags left in gatar %d", ErrGatarStockShort, m.ThockNumber, balance, m.GatarNo)
+		}
+	}
+
+	if err := checkGatarSpace(ctx, tx, movements, check); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO stock_transfers (thock_number, from_room_no, from_floor, from_gatar,
+		                              to_room_no, to_floor, to_gatar, quantity, reason, moved_by_user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id, created_at`,
+		t.ThockNumber, t.FromRoomNo, t.FromFloor, t.FromGatar,
+		t.ToRoomNo, t.ToFloor, t.ToGatar, t.Quantity, t.Reason, t.MovedByUserID,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range movements {
+		m.SourceID = t.ID
+	}
+	if err := insertMovements(ctx, tx, movements); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListTransfers returns stock transfers, newest first; thockNumber "" lists all thocks
+func (r *GatarStockRepository) ListTransfers(ctx context.Context, thockNumber string, limit int) ([]*models.StockTransfer, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT t.id, t.thock_number, t.from_room_no, t.from_floor, t.from_gatar,
+		        t.to_room_no, t.to_floor, t.to_gatar, t.quantity, t.reason,
+		        COALESCE(t.moved_by_user_id, 0), COALESCE(u.name, ''), t.created_at
+		 FROM stock_transfers t
+		 LEFT JOIN users u ON u.id = t.moved_by_user_id
+		 WHERE ($1 = '' OR t.thock_number = $1)
+		 ORDER BY t.created_at DESC, t.id DESC
+		 LIMIT $2`, thockNumber, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []*models.StockTransfer
+	for rows.Next() {
+		t := &models.StockTransfer{}
+		err := rows.Scan(&t.ID, &t.ThockNumber, &t.FromRoomNo, &t.FromFloor, &t.FromGatar,
+			&t.ToRoomNo, &t.ToFloor, &t.ToGatar, &t.Quantity, &t.Reason,
+			&t.MovedByUserID, &t.MovedByName, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrAmendmentNotFound is returned for an unknown amendment
+	ErrAmendmentNotFound = errors.New("gate pass amendment not found")
+	// ErrAmendmentDecided is returned when an amendment was approved or rejected already
+	ErrAmendmentDecided = errors.New("gate pass amendment was already decided")
+	// ErrAmendmentPending is returned when a gate pass already has an amendment waiting for approval
+	ErrAmendmentPending = errors.New("gate pass already has an amendment waiting for approval")
+)
+
+type GatePassAmendmentRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewGatePassAmendmentRepository(db *pgxpool.Pool) *GatePassAmendmentRepository {
+	return &GatePassAmendmentRepository{DB: db}
+}
+
+const amendmentColumns = `
+	a.id, a.gate_pass_id, a.old_quantity, a.new_quantity, a.old_gate_no, a.new_gate_no,
+	a.reason, a.status, a.requested_by_user_id, COALESCE(ru.name, ''),
+	a.decided_by_user_id, COALESCE(du.name, ''), a.decision_remarks, a.decided_at, a.created_at,
+	gp.thock_number`
+
+const amendmentFrom = `
+	FROM gate_pass_amendments a
+	JOIN gate_passes gp ON gp.id = a.gate_pass_id
+	LEFT JOIN users ru ON ru.id = a.requested_by_user_id
+	LEFT JOIN users du ON du.id = a.decided_by_user_id`
+
+func scanAmendment(row pgx.Row) (*models.GatePassAmendment, error) {
+	a := &models.GatePassAmendment{}
+	err := row.Scan(&a.ID, &a.GatePassID, &a.OldQuantity, &a.NewQuantity, &a.OldGateNo, &a.NewGateNo,
+		&a.Reason, &a.Status, &a.RequestedByUserID, &a.RequestedByName,
+		&a.DecidedByUserID, &a.DecidedByName, &a.DecisionRemarks, &a.DecidedAt, &a.CreatedAt,
+		&a.ThockNumber)
+	return a, err
+}
+
+func (r *GatePassAmendmentRepository) list(ctx context.Context, where string, args ...interface{}) ([]*models.GatePassAmendment, error) {
+	rows, err := r.DB.Query(ctx, `SELECT `+amendmentColumns+amendmentFrom+` WHERE `+where+` ORDER BY a.created_at DESC, a.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var amendments []*models.GatePassAmendment
+	for rows.Next() {
+		a, err := scanAmendment(rows)
+		if err != nil {
+			return nil, err
+		}
+		amendments = append(amendments, a)
+	}
+	return amendments, rows.Err()
+}
+
+// Create records an amendment waiting for approval
+func (r *GatePassAmendmentRepository) Create(ctx context.Context, a *models.GatePassAmendment) error {
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO gate_pass_amendments (
+			gate_pass_id, old_quantity, new_quantity, old_gate_no, new_gate_no, reason, requested_by_user_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at
+	`, a.GatePassID, a.OldQuantity, a.NewQuantity, a.OldGateNo, a.NewGateNo, a.Reason, a.RequestedByUserID,
+	).Scan(&a.ID, &a.Status, &a.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAmendmentPending
+	}
+	return err
+}
+
+// Get returns an amendment
+func (r *GatePassAmendmentRepository) Get(ctx context.Context, id int) (*models.GatePassAmendment, error) {
+	a, err := scanAmendment(r.DB.QueryRow(ctx, `SELECT `+amendmentColumns+amendmentFrom+` WHERE a.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAmendmentNotFound
+	}
+	return a, err
+}
+
+// ListByGatePass returns a gate pass's amendments, newest first
+func (r *GatePassAmendmentRepository) ListByGatePass(ctx context.Context, gatePassID int) ([]*models.GatePassAmendment, error) {
+	return r.list(ctx, `a.gate_pass_id = $1`, gatePassID)
+}
+
+// ListPending returns the amendments waiting for approval
+func (r *GatePassAmendmentRepository) ListPending(ctx context.Context) ([]*models.GatePassAmendment, error) {
+	return r.list(ctx, `a.status = 'pending'`)
+}
+
+// Approve applies a pending amendment to its gate pass and gives it a fresh pickup window.
+// The gate pass must still be approved or partially picked with fewer bags picked up
+// than the new quantity; otherwise ErrGatePassStatusChanged is returned.
+func (r *GatePassAmendmentRepository) Approve(ctx context.Context, a *models.GatePassAmendment, userID int, remarks string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE gate_pass_amendments
+		SET status = 'approved', decided_by_user_id = $1, decision_remarks = $2, decided_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status = 'pending'
+		RETURNING status, decided_at
+	`, userID, remarks, a.ID).Scan(&a.Status, &a.DecidedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAmendmentDecided
+	}
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE gate_passes
+		SET approved_quantity = $1, gate_no = $2,
+		    approval_expires_at = CURRENT_TIMESTAMP + INTERVAL '15 hours',
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status IN ('approved', 'partially_completed') AND total_picked_up < $1
+	`, a.NewQuantity, a.NewGateNo, a.GatePassID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrGatePassStatusChanged
+	}
+
+	a.DecidedByUserID = &userID
+	a.DecisionRemarks = remarks
+	return tx.Commit(ctx)
+}
+
+// Reject turns down a pending amendment
+func (r *GatePassAmendmentRepository) Reject(ctx context.Context, a *models.GatePassAmendment, userID int, remarks string) error {
+	err := r.DB.QueryRow(ctx, `
+		UPDATE gate_pass_amendments
+		SET status = 'rejected', decided_by_user_id = $1, decision_remarks = $2, decided_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status = 'pending'
+		RETURNING status, decided_at
+	`, userID, remarks, a.ID).Scan(&a.Status, &a.DecidedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAmendmentDecided
+	}
+	if err != nil {
+		return err
+	}
+	a.DecidedByUserID = &userID
+	a.DecisionRemarks = remarks
+	return nil
+}
+
+// RejectPending turns down any amendment still waiting on a gate pass that was closed
+func (r *GatePassAmendmentRepository) RejectPending(ctx context.Context, gatePassID int, userID *int, remarks string) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE gate_pass_amendments
+		SET status = 'rejected', decided_by_user_id = $1, decision_remarks = $2, decided_at = CURRENT_TIMESTAMP
+		WHERE gate_pass_id = $3 AND status = 'pending'
+	`, userID, remarks, gatePassID)
+	return err
+}
@@ -2,9 +2,11 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"cold-backend/internal/models"
@@ -51,17 +53,22 @@ func (r *GatePassRepository) CreateGatePass(ctx context.Context, gatePass *model
 		INSERT INTO gate_passes (
 			customer_id, thock_number, entry_id, family_member_id, family_member_name,
 			requested_quantity, payment_verified, payment_amount, issued_by_user_id, remarks,
-			expires_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP + INTERVAL '30 hours')
+			expires_at, reissued_from_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP + INTERVAL '30 hours', $11)
 		RETURNING id, issued_at, expires_at, created_at, updated_at
 	`
 
-	return r.DB.QueryRow(ctx, query,
+	err = r.DB.QueryRow(ctx, query,
 		gatePass.CustomerID, gatePass.ThockNumber, gatePass.EntryID,
 		gatePass.FamilyMemberID, gatePass.FamilyMemberName,
 		gatePass.RequestedQuantity, gatePass.PaymentVerified,
 		gatePass.PaymentAmount, gatePass.IssuedByUserID, gatePass.Remarks,
+		gatePass.ReissuedFromID,
 	).Scan(&gatePass.ID, &gatePass.IssuedAt, &gatePass.ExpiresAt, &gatePass.CreatedAt, &gatePass.UpdatedAt)
+	if isUniqueViolation(err) && gatePass.ReissuedFromID != nil {
+		return ErrGatePassAlreadyReissued
+	}
+	return err
 }
 
 // GetGatePass retrieves a gate pass by ID
@@ -70,7 +77,8 @@ func (r *GatePassRepository) GetGatePass(ctx context.Context, id int) (*models.G
 		SELECT id, customer_id, thock_number, entry_id, family_member_id, family_member_name,
 		       requested_quantity, approved_quantity, gate_no, status, payment_verified, payment_amount,
 		       issued_by_user_id, approved_by_user_id, issued_at, expires_at, completed_at,
-		       remarks, created_at, updated_at, total_picked_up, approval_expires_at, final_approved_quantity
+		       remarks, created_at, updated_at, total_picked_up, approval_expires_at, final_approved_quantity,
+		       COALESCE(request_source, 'employee'), reissued_from_id, pickup_slot_id
 		FROM gate_passes
 		WHERE id = $1
 	`
@@ -84,6 +92,7 @@ func (r *GatePassRepository) GetGatePass(ctx context.Context, id int) (*models.G
 		&gatePass.IssuedByUserID, &gatePass.ApprovedByUserID, &gatePass.IssuedAt,
 		&gatePass.ExpiresAt, &gatePass.CompletedAt, &gatePass.Remarks, &gatePass.CreatedAt, &gatePass.UpdatedAt,
 		&gatePass.TotalPickedUp, &gatePass.ApprovalExpiresAt, &gatePass.FinalApprovedQuantity,
+		&gatePass.RequestSource, &gatePass.ReissuedFromID, &gatePass.PickupSlotID,
 	)
 
 	if err != nil {
@@ -93,6 +102,13 @@ func (r *GatePassRepository) GetGatePass(ctx context.Context, id int) (*models.G
 	return gatePass, nil
 }
 
+// FindReissue returns the gate pass that re-issued an expired one, or pgx.ErrNoRows
+func (r *GatePassRepository) FindReissue(ctx context.Context, expiredID int) (int, error) {
+	var id int
+	err := r.DB.QueryRow(ctx, `SELECT id FROM gate_passes WHERE reissued_from_id = $1`, expiredID).Scan(&id)
+	return id, err
+}
+
 // ListAllGatePasses retrieves all gate passes with customer and user details
 func (r *GatePassRepository) ListAllGatePasses(ctx context.Context) ([]map[string]interface{}, error) {
 	query := `
@@ -244,13 +260,15 @@ func (r *GatePassRepository) ListPendingGatePasses(ctx context.Context) ([]map[s
 			(SELECT string_agg(DISTINCT gate_no, ', ') FROM room_entries WHERE thock_number = gp.thock_number) as gatars,
 			(SELECT COALESCE(SUM(quantity), 0) FROM room_entries WHERE thock_number = gp.thock_number) as total_qty,
 			(SELECT string_agg(quantity_breakdown, ', ') FROM room_entries WHERE thock_number = gp.thock_number) as qty_breakdown,
-			(SELECT string_agg(DISTINCT NULLIF(remark, ''), ', ') FROM room_entries WHERE thock_number = gp.thock_number) as remark
+			(SELECT string_agg(DISTINCT NULLIF(remark, ''), ', ') FROM room_entries WHERE thock_number = gp.thock_number) as remark,
+			` + pickupSlotColumns + `
 		FROM gate_passes gp
 		JOIN customers c ON gp.customer_id = c.id
 		LEFT JOIN entries e ON gp.entry_id = e.id
 		LEFT JOIN users iu ON gp.issued_by_user_id = iu.id
+		LEFT JOIN pickup_slots ps ON ps.id = gp.pickup_slot_id
 		WHERE gp.status = 'pending'
-		ORDER BY gp.issued_at ASC
+		ORDER BY ps.slot_date ASC NULLS LAST, ps.start_time ASC NULLS LAST, gp.slot_booked_at ASC, gp.issued_at ASC
 	`
 
 	rows, err := r.DB.Query(ctx, query)
@@ -272,6 +290,7 @@ func (r *GatePassRepository) ListPendingGatePasses(ctx context.Context) ([]map[s
 			paymentVerified, isExpired                                                                                    bool
 			paymentAmount                                                                                                 *float64
 			issuedAt, expiresAt                                                                                           interface{}
+			slot                                                                                                          pickupSlotRef
 		)
 
 		err := rows.Scan(
@@ -282,6 +301,7 @@ func (r *GatePassRepository) ListPendingGatePasses(ctx context.Context) ([]map[s
 			&customerID, &customerName, &customerPhone, &customerVillage,
 			&entryID, &entryQty, &issuedByName,
 			&rooms, &floors, &gatars, &totalQty, &qtyBreakdown, &remark,
+			&slot.id, &slot.gate, &slot.date, &slot.start, &slot.end,
 		)
 		if err != nil {
 			return nil, err
@@ -348,6 +368,7 @@ func (r *GatePassRepository) ListPendingGatePasses(ctx context.Context) ([]map[s
 		if remark != nil {
 			gatePass["remark"] = *remark
 		}
+		slot.set(gatePass)
 
 		gatePasses = append(gatePasses, gatePass)
 	}
@@ -355,38 +376,130 @@ func (r *GatePassRepository) ListPendingGatePasses(ctx context.Context) ([]map[s
 	return gatePasses, rows.Err()
 }
 
-// UpdateGatePass updates gate pass details and sets 15-hour expiration when approved
-func (r *GatePassRepository) UpdateGatePass(ctx context.Context, id int, approvedQty int, gateNo, status, remarks string, approvedByUserID int) error {
+// ErrGatePassStatusChanged is returned when a gate pass is no longer in the status a
+// change was checked against, because another request changed it first
+var ErrGatePassStatusChanged = errors.New("gate pass status was changed by another request")
+
+// ErrGatePassAlreadyReissued is returned when an expired gate pass has been re-issued before
+var ErrGatePassAlreadyReissued = errors.New("gate pass has already been re-issued")
+
+// ErrPickupVehicleNotLinked is returned when a pickup names a vehicle that is not inside on its gate pass
+var ErrPickupVehicleNotLinked = errors.New("vehicle is not inside on this gate pass")
+
+// execTransition runs an UPDATE that is guarded by the gate pass's current status and
+// returns its new status, and records a change of status in gate_pass_transitions in the
+// same transaction. t.ToStatus is set to the status the update left.
+func (r *GatePassRepository) execTransition(ctx context.Context, t *models.GatePassTransition, query string, args ...interface{}) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := transitionTx(ctx, tx, t, query, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// transitionTx runs a guarded status update inside tx and records the transition when the
+// status changed
+func transitionTx(ctx context.Context, tx pgx.Tx, t *models.GatePassTransition, query string, args ...interface{}) error {
+	var status string
+	err := tx.QueryRow(ctx, query, args...).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrGatePassStatusChanged
+	}
+	if err != nil {
+		return err
+	}
+	t.ToStatus = status
+
+	if status != t.FromStatus {
+		return insertTransition(ctx, tx, t)
+	}
+	return nil
+}
+
+// gatePassQuerier is satisfied by both the pool and a transaction
+type gatePassQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertTransition(ctx context.Context, q gatePassQuerier, t *models.GatePassTransition) error {
+	return q.QueryRow(ctx, `
+		INSERT INTO gate_pass_transitions (gate_pass_id, from_status, to_status, reason, changed_by_user_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		RETURNING id, created_at
+	`, t.GatePassID, t.FromStatus, t.ToStatus, t.Reason, t.ChangedByUserID).Scan(&t.ID, &t.CreatedAt)
+}
+
+// RecordTransition records a status change made outside the guarded updates, such as the
+// request that created a gate pass
+func (r *GatePassRepository) RecordTransition(ctx context.Context, t *models.GatePassTransition) error {
+	return insertTransition(ctx, r.DB, t)
+}
+
+// ListTransitions returns a gate pass's status history, oldest first
+func (r *GatePassRepository) ListTransitions(ctx context.Context, gatePassID int) ([]*models.GatePassTransition, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT t.id, t.gate_pass_id, COALESCE(t.from_status, ''), t.to_status, t.reason,
+		       t.changed_by_user_id, COALESCE(u.name, ''), t.created_at
+		FROM gate_pass_transitions t
+		LEFT JOIN users u ON u.id = t.changed_by_user_id
+		WHERE t.gate_pass_id = $1
+		ORDER BY t.created_at, t.id
+	`, gatePassID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []*models.GatePassTransition
+	for rows.Next() {
+		t := &models.GatePassTransition{}
+		if err := rows.Scan(&t.ID, &t.GatePassID, &t.FromStatus, &t.ToStatus, &t.Reason,
+			&t.ChangedByUserID, &t.ChangedByName, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// UpdateGatePass updates gate pass details and moves it from t.FromStatus to t.ToStatus,
+// setting the 15-hour pickup window when approved
+func (r *GatePassRepository) UpdateGatePass(ctx context.Context, id int, approvedQty int, gateNo, remarks string, approvedByUserID int, t *models.GatePassTransition) error {
 	query := `
 		UPDATE gate_passes
 		SET approved_quantity = $1, gate_no = $2, status = $3::text, remarks = $4,
 		    approved_by_user_id = $5,
 		    approval_expires_at = CASE WHEN $3::text = 'approved' THEN CURRENT_TIMESTAMP + INTERVAL '15 hours' ELSE approval_expires_at END,
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $6
+		WHERE id = $6 AND status = $7
+		RETURNING status
 	`
 
-	_, err := r.DB.Exec(ctx, query, approvedQty, gateNo, status, remarks, approvedByUserID, id)
-	return err
+	return r.execTransition(ctx, t, query, approvedQty, gateNo, t.ToStatus, remarks, approvedByUserID, id, t.FromStatus)
 }
 
 // UpdateGatePassWithSource updates gate pass details including request_source
-func (r *GatePassRepository) UpdateGatePassWithSource(ctx context.Context, id int, approvedQty int, gateNo, status, requestSource, remarks string, approvedByUserID int) error {
+func (r *GatePassRepository) UpdateGatePassWithSource(ctx context.Context, id int, approvedQty int, gateNo, requestSource, remarks string, approvedByUserID int, t *models.GatePassTransition) error {
 	query := `
 		UPDATE gate_passes
 		SET approved_quantity = $1, gate_no = $2, status = $3::text, request_source = $4, remarks = $5,
 		    approved_by_user_id = $6,
 		    approval_expires_at = CASE WHEN $3::text = 'approved' THEN CURRENT_TIMESTAMP + INTERVAL '15 hours' ELSE approval_expires_at END,
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $7
+		WHERE id = $7 AND status = $8
+		RETURNING status
 	`
 
-	_, err := r.DB.Exec(ctx, query, approvedQty, gateNo, status, requestSource, remarks, approvedByUserID, id)
-	return err
+	return r.execTransition(ctx, t, query, approvedQty, gateNo, t.ToStatus, requestSource, remarks, approvedByUserID, id, t.FromStatus)
 }
 
 // UpdateGatePassWithExpiration updates gate pass with custom expiration time
-func (r *GatePassRepository) UpdateGatePassWithExpiration(ctx context.Context, id int, approvedQty int, gateNo, status, remarks string, approvedByUserID int, expiresAt *time.Time) error {
+func (r *GatePassRepository) UpdateGatePassWithExpiration(ctx context.Context, id int, approvedQty int, gateNo, remarks string, approvedByUserID int, expiresAt *time.Time, t *models.GatePassTransition) error {
 	query := `
 		UPDATE gate_passes
 		SET approved_quantity = $1, gate_no = $2, status = $3::text, remarks = $4,
@@ -394,51 +507,169 @@ func (r *GatePassRepository) UpdateGatePassWithExpiration(ctx context.Context, i
 		    expires_at = $6,
 		    approval_expires_at = $6,
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $7
+		WHERE id = $7 AND status = $8
+		RETURNING status
 	`
 
-	_, err := r.DB.Exec(ctx, query, approvedQty, gateNo, status, remarks, approvedByUserID, expiresAt, id)
-	return err
+	return r.execTransition(ctx, t, query, approvedQty, gateNo, t.ToStatus, remarks, approvedByUserID, expiresAt, id, t.FromStatus)
 }
 
-// UpdatePickupQuantity updates the total picked up quantity
-func (r *GatePassRepository) UpdatePickupQuantity(ctx context.Context, gatePassID int, additionalQty int) error {
+// SetStatus moves a gate pass from t.FromStatus to t.ToStatus without changing anything else
+func (r *GatePassRepository) SetStatus(ctx context.Context, id int, t *models.GatePassTransition) error {
+	query := `
+		UPDATE gate_passes
+		SET status = $1::text, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3
+		RETURNING status
+	`
+
+	return r.execTransition(ctx, t, query, t.ToStatus, id, t.FromStatus)
+}
+
+// CancelGatePass cancels an open gate pass. Bags already picked up stay out; the rest of
+// the approved quantity is released back to the entry.
+func (r *GatePassRepository) CancelGatePass(ctx context.Context, id int, t *models.GatePassTransition) error {
+	query := `
+		UPDATE gate_passes
+		SET status = 'cancelled',
+		    final_approved_quantity = total_picked_up,
+		    remarks = CASE WHEN COALESCE(remarks, '') = '' THEN $1 ELSE remarks || ' | Cancelled: ' || $1 END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3
+		RETURNING status
+	`
+
+	return r.execTransition(ctx, t, query, t.Reason, id, t.FromStatus)
+}
+
+// RecordPickup adds a pickup to the total picked up and saves the pickup, its gatar breakdown
+// and its stock ledger rows in one transaction. The gate pass becomes completed once the
+// approved quantity is picked up, otherwise partially picked. The update fails with
+// ErrGatePassStatusChanged if the status moved on or the pickup would exceed the approved
+// quantity. plan receives the thock's current ledger balances, read under the thock's stock
+// lock, and returns the movements that take the bags out; their source is set to the new pickup.
+func (r *GatePassRepository) RecordPickup(ctx context.Context, thockNumber string, pickup *models.GatePassPickup, breakdown []models.GatarBreakdown, t *models.GatePassTransition, plan func(balances []*models.GatarBalance) []*models.GatarStockMovement) error {
 	query := `
 		UPDATE gate_passes
 		SET total_picked_up = total_picked_up + $1,
 		    status = CASE
-		        WHEN total_picked_up + $1 >= requested_quantity THEN 'completed'
+		        WHEN total_picked_up + $1 >= COALESCE(approved_quantity, requested_quantity) THEN 'completed'
 		        WHEN total_picked_up + $1 > 0 THEN 'partially_completed'
 		        ELSE status
 		    END,
 		    completed_at = CASE
-		        WHEN total_picked_up + $1 >= requested_quantity THEN CURRENT_TIMESTAMP
+		        WHEN total_picked_up + $1 >= COALESCE(approved_quantity, requested_quantity) THEN CURRENT_TIMESTAMP
 		        ELSE completed_at
 		    END,
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $2
+		WHERE id = $2 AND status = $3
+		  AND total_picked_up + $1 <= COALESCE(approved_quantity, requested_quantity)
+		RETURNING status
 	`
 
-	_, err := r.DB.Exec(ctx, query, additionalQty, gatePassID)
-	return err
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if plan != nil {
+		if err := lockThockStock(ctx, tx, thockNumber); err != nil {
+			return err
+		}
+	}
+	if err := transitionTx(ctx, tx, t, query, pickup.PickupQuantity, pickup.GatePassID, t.FromStatus); err != nil {
+		return err
+	}
+
+	// The collecting vehicle must be inside on this gate pass; without one, the latest such
+	// vehicle is used
+	var visitID *int
+	err = tx.QueryRow(ctx, `
+		SELECT v.id FROM vehicle_visits v
+		JOIN vehicle_visit_links l ON l.visit_id = v.id
+		WHERE l.gate_pass_id = $1 AND v.status = 'inside'
+		  AND ($2::int IS NULL OR v.id = $2)
+		ORDER BY v.in_time DESC, v.id DESC
+		LIMIT 1
+	`, pickup.GatePassID, pickup.VehicleVisitID).Scan(&visitID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if pickup.VehicleVisitID != nil {
+			return fmt.Errorf("%w: visit %d", ErrPickupVehicleNotLinked, *pickup.VehicleVisitID)
+		}
+	} else if err != nil {
+		return err
+	}
+	pickup.VehicleVisitID = visitID
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO gate_pass_pickups (
+			gate_pass_id, pickup_quantity, picked_up_by_user_id, room_no, floor, remarks, vehicle_visit_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, pickup_time, created_at
+	`, pickup.GatePassID, pickup.PickupQuantity, pickup.PickedUpByUserID,
+		pickup.RoomNo, pickup.Floor, pickup.Remarks, pickup.VehicleVisitID,
+	).Scan(&pickup.ID, &pickup.PickupTime, &pickup.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save pickup: %w", err)
+	}
+
+	for _, g := range breakdown {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO gate_pass_pickup_gatars (pickup_id, gatar_no, quantity)
+			VALUES ($1, $2, $3)
+		`, pickup.ID, g.GatarNo, g.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to save gatar breakdown: %w", err)
+		}
+	}
+
+	if plan != nil {
+		balances, err := thockBalances(ctx, tx, thockNumber)
+		if err != nil {
+			return err
+		}
+		movements := plan(balances)
+		for _, m := range movements {
+			m.SourceID = pickup.ID
+		}
+		if err := insertMovements(ctx, tx, movements); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
 }
 
 // ExpireGatePasses marks gate passes as expired if their time windows have passed
 // - PENDING passes: Expire after 30 hours (expires_at) if not approved
 // - APPROVED passes: Expire after 15 hours (approval_expires_at) if not picked up
+// Each expiry is recorded in gate_pass_transitions.
 func (r *GatePassRepository) ExpireGatePasses(ctx context.Context) error {
 	query := `
-		UPDATE gate_passes
-		SET status = 'expired',
-		    final_approved_quantity = total_picked_up,
-		    updated_at = CURRENT_TIMESTAMP
-		WHERE (
-		    -- Expire PENDING gate passes after 30-hour approval window
-		    (status = 'pending' AND expires_at IS NOT NULL AND expires_at < CURRENT_TIMESTAMP)
-		    OR
-		    -- Expire APPROVED/PARTIALLY_COMPLETED gate passes after 15-hour pickup window
-		    (status IN ('approved', 'partially_completed') AND approval_expires_at IS NOT NULL AND approval_expires_at < CURRENT_TIMESTAMP)
+		WITH due AS (
+			SELECT id, status FROM gate_passes
+			WHERE (
+			    -- Expire PENDING gate passes after 30-hour approval window
+			    (status = 'pending' AND expires_at IS NOT NULL AND expires_at < CURRENT_TIMESTAMP)
+			    OR
+			    -- Expire APPROVED/PARTIALLY_COMPLETED gate passes after 15-hour pickup window
+			    (status IN ('approved', 'partially_completed') AND approval_expires_at IS NOT NULL AND approval_expires_at < CURRENT_TIMESTAMP)
+			)
+			FOR UPDATE
+		), expired AS (
+			UPDATE gate_passes gp
+			SET status = 'expired',
+			    final_approved_quantity = gp.total_picked_up,
+			    updated_at = CURRENT_TIMESTAMP
+			FROM due
+			WHERE gp.id = due.id
+			RETURNING gp.id, due.status AS from_status
 		)
+		INSERT INTO gate_pass_transitions (gate_pass_id, from_status, to_status, reason)
+		SELECT id, from_status, 'expired',
+		       CASE WHEN from_status = 'pending' THEN 'Not approved within the approval window'
+		            ELSE 'Not picked up within the pickup window' END
+		FROM expired
 	`
 
 	_, err := r.DB.Exec(ctx, query)
@@ -508,20 +739,22 @@ func (r *GatePassRepository) GetExpiredGatePasses(ctx context.Context) ([]map[st
 	return expiredPasses, rows.Err()
 }
 
-// CompleteGatePass marks gate pass as completed
-func (r *GatePassRepository) CompleteGatePass(ctx context.Context, id int) error {
+// CompleteGatePass marks gate pass as completed, closing it even if not everything
+// approved was picked up
+func (r *GatePassRepository) CompleteGatePass(ctx context.Context, id int, t *models.GatePassTransition) error {
 	query := `
 		UPDATE gate_passes
 		SET status = 'completed', completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1
+		WHERE id = $1 AND status = $2
+		RETURNING status
 	`
 
-	_, err := r.DB.Exec(ctx, query, id)
-	return err
+	return r.execTransition(ctx, t, query, id, t.FromStatus)
 }
 
-// CreateCustomerGatePass creates a gate pass from customer portal (status = pending, no expiration)
-func (r *GatePassRepository) CreateCustomerGatePass(ctx context.Context, customerID int, thockNumber string, requestedQuantity int, remarks string, entryID int, familyMemberID *int, familyMemberName string) (*models.GatePass, error) {
+// CreateCustomerGatePass creates a gate pass from customer portal (status = pending, no expiration).
+// A pickup slot, if given, is booked in the same transaction; if it cannot be booked nothing is created.
+func (r *GatePassRepository) CreateCustomerGatePass(ctx context.Context, customerID int, thockNumber string, requestedQuantity int, remarks string, entryID int, familyMemberID *int, familyMemberName string, pickupSlotID *int) (*models.GatePass, error) {
 	// Check for duplicate gate pass (same customer, same thock, same quantity within 10 seconds)
 	isDuplicate, err := r.CheckDuplicateGatePass(ctx, customerID, thockNumber, requestedQuantity)
 	if err != nil {
@@ -555,7 +788,13 @@ func (r *GatePassRepository) CreateCustomerGatePass(ctx context.Context, custome
 		gatePass.Remarks = &remarks
 	}
 
-	err = r.DB.QueryRow(ctx, query, customerID, thockNumber, entryID, familyMemberID, familyMemberName, requestedQuantity, customerID, remarks).Scan(
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, query, customerID, thockNumber, entryID, familyMemberID, familyMemberName, requestedQuantity, customerID, remarks).Scan(
 		&gatePass.ID, &gatePass.IssuedAt, &gatePass.CreatedAt, &gatePass.UpdatedAt,
 	)
 
@@ -563,7 +802,14 @@ func (r *GatePassRepository) CreateCustomerGatePass(ctx context.Context, custome
 		return nil, err
 	}
 
-	return gatePass, nil
+	if pickupSlotID != nil {
+		if err := bookSlot(ctx, tx, gatePass.ID, *pickupSlotID, requestedQuantity); err != nil {
+			return nil, err
+		}
+		gatePass.PickupSlotID = pickupSlotID
+	}
+
+	return gatePass, tx.Commit(ctx)
 }
 
 // ListByCustomerID retrieves all gate passes for a customer
@@ -577,10 +823,12 @@ func (r *GatePassRepository) ListByCustomerID(ctx context.Context, customerID in
 			gp.request_source,
 			gp.family_member_id, gp.family_member_name,
 			e.id as entry_id, e.expected_quantity as entry_quantity,
-			au.name as approved_by_name
+			au.name as approved_by_name,
+			` + pickupSlotColumns + `
 		FROM gate_passes gp
 		LEFT JOIN entries e ON gp.entry_id = e.id
 		LEFT JOIN users au ON gp.approved_by_user_id = au.id
+		LEFT JOIN pickup_slots ps ON ps.id = gp.pickup_slot_id
 		WHERE gp.customer_id = $1
 		ORDER BY gp.issued_at DESC
 	`
@@ -604,6 +852,7 @@ func (r *GatePassRepository) ListByCustomerID(ctx context.Context, customerID in
 			paymentAmount                                                  *float64
 			issuedAt                                                       interface{}
 			expiresAt, approvalExpiresAt, completedAt                      *interface{}
+			slot                                                           pickupSlotRef
 		)
 
 		err := rows.Scan(
@@ -612,6 +861,7 @@ func (r *GatePassRepository) ListByCustomerID(ctx context.Context, customerID in
 			&totalPickedUp, &approvalExpiresAt, &finalApprovedQty, &requestSource,
 			&familyMemberID, &familyMemberName,
 			&entryID, &entryQty, &approvedByName,
+			&slot.id, &slot.gate, &slot.date, &slot.start, &slot.end,
 		)
 		if err != nil {
 			return nil, err
@@ -665,6 +915,7 @@ func (r *GatePassRepository) ListByCustomerID(ctx context.Context, customerID in
 		if approvedByName != nil {
 			gatePass["approved_by_name"] = *approvedByName
 		}
+		slot.set(gatePass)
 
 		gatePasses = append(gatePasses, gatePass)
 	}
@@ -673,19 +924,21 @@ func (r *GatePassRepository) ListByCustomerID(ctx context.Context, customerID in
 }
 
 // GetTotalApprovedQuantityForEntry calculates the total approved quantity
-// across all completed and approved gate passes for a specific entry
+// across all completed and approved gate passes for a specific entry, plus the bags
+// already picked up on expired and cancelled ones
 // This is used to prevent overselling - customer can't request more than available stock
 func (r *GatePassRepository) GetTotalApprovedQuantityForEntry(ctx context.Context, entryID int) (int, error) {
 	query := `
 		SELECT COALESCE(SUM(
 			CASE
+				WHEN status IN ('expired', 'cancelled') THEN total_picked_up
 				WHEN approved_quantity IS NOT NULL THEN approved_quantity
 				ELSE requested_quantity
 			END
 		), 0)
 		FROM gate_passes
 		WHERE entry_id = $1
-		AND status IN ('pending', 'approved', 'completed', 'partially_completed')
+		AND status IN ('pending', 'approved', 'completed', 'partially_completed', 'expired', 'cancelled')
 	`
 
 	var totalApproved int
@@ -718,3 +971,42 @@ func (r *GatePassRepository) GetPendingQuantityForEntry(ctx context.Context, ent
 
 	return pendingQty, nil
 }
+
+// ListOpenByThock returns the pending, approved and partially completed gate passes of a thock
+func (r *GatePassRepository) ListOpenByThock(ctx context.Context, thockNumber string) ([]*models.GatePass, error) {
+	query := `
+		SELECT id, customer_id, thock_number, entry_id, family_member_id, family_member_name,
+		       requested_quantity, approved_quantity, gate_no, status, payment_verified, payment_amount,
+		       issued_by_user_id, approved_by_user_id, issued_at, expires_at, completed_at,
+		       remarks, created_at, updated_at, total_picked_up, approval_expires_at, final_approved_quantity
+		FROM gate_passes
+		WHERE thock_number = $1
+		AND status IN ('pending', 'approved', 'partially_completed')
+		ORDER BY id
+	`
+
+	rows, err := r.DB.Query(ctx, query, thockNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var gatePasses []*models.GatePass
+	for rows.Next() {
+		gatePass := &models.GatePass{}
+		err := rows.Scan(
+			&gatePass.ID, &gatePass.CustomerID, &gatePass.ThockNumber, &gatePass.EntryID,
+			&gatePass.FamilyMemberID, &gatePass.FamilyMemberName,
+			&gatePass.RequestedQuantity, &gatePass.ApprovedQuantity, &gatePass.GateNo,
+			&gatePass.Status, &gatePass.PaymentVerified, &gatePass.PaymentAmount,
+			&gatePass.IssuedByUserID, &gatePass.ApprovedByUserID, &gatePass.IssuedAt,
+			&gatePass.ExpiresAt, &gatePass.CompletedAt, &gatePass.Remarks, &gatePass.CreatedAt, &gatePass.UpdatedAt,
+			&gatePass.TotalPickedUp, &gatePass.ApprovalExpiresAt, &gatePass.FinalApprovedQuantity,
+		)
+		if err != nil {
+			return nil, err
+		}
+		gatePasses = append(gatePasses, gatePass)
+	}
+	return gatePasses, rows.Err()
+}
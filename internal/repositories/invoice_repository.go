@@ -2,12 +2,39 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrInvoiceNotCancellable is returned when cancelling a credit note or an already cancelled invoice
+var ErrInvoiceNotCancellable = errors.New("only issued tax invoices can be cancelled")
+
+// invoiceNumberPrefixes maps document types to their number prefix
+var invoiceNumberPrefixes = map[string]string{
+	models.InvoiceTypeTaxInvoice: "INV",
+	models.InvoiceTypeCreditNote: "CN",
+}
+
+// invoiceSelect is the column list scanned by scanInvoice
+const invoiceSelect = `
+	SELECT i.id, i.invoice_number, i.customer_id, i.employee_id, i.total_amount,
+	       i.items_count, COALESCE(i.notes, ''), i.created_at, i.updated_at,
+	       i.document_type, COALESCE(i.financial_year, ''), COALESCE(i.sequence_number, 0), i.status,
+	       COALESCE(i.company_gstin, ''), COALESCE(i.customer_gstin, ''), COALESCE(i.place_of_supply, ''),
+	       i.taxable_amount, i.cgst_rate, i.cgst_amount, i.sgst_rate, i.sgst_amount, i.igst_rate, i.igst_amount,
+	       i.original_invoice_id, COALESCE(i.cancel_reason, ''), i.cancelled_at,
+	       COALESCE(c.name, ''), COALESCE(c.phone, ''), COALESCE(c.so, ''), COALESCE(c.village, ''), COALESCE(c.address, ''),
+	       COALESCE(u.name, ''), COALESCE(o.invoice_number, '')
+	FROM invoices i
+	LEFT JOIN customers c ON i.customer_id = c.id
+	LEFT JOIN users u ON i.employee_id = u.id
+	LEFT JOIN invoices o ON i.original_invoice_id = o.id`
+
 type InvoiceRepository struct {
 	DB *pgxpool.Pool
 }
@@ -16,90 +43,142 @@ func NewInvoiceRepository(db *pgxpool.Pool) *InvoiceRepository {
 	return &InvoiceRepository{DB: db}
 }
 
-// GenerateInvoiceNumber generates a unique invoice number
-func (r *InvoiceRepository) GenerateInvoiceNumber(ctx context.Context) (string, error) {
-	// PERFORMANCE FIX: Use database sequence instead of COUNT for O(1) performance
-	var nextNum int
-	err := r.DB.QueryRow(ctx, "SELECT nextval('invoice_number_sequence')").Scan(&nextNum)
+// nextInvoiceNumber takes the next number of a document series inside tx.
+// The series row stays locked until tx ends, so numbers are gapless and never reused.
+func nextInvoiceNumber(ctx context.Context, tx pgx.Tx, documentType, financialYear string) (int, string, error) {
+	var seq int
+	err := tx.QueryRow(ctx,
+		`INSERT INTO invoice_number_series (document_type, financial_year, last_number)
+		 VALUES ($1, $2, 1)
+		 ON CONFLICT (document_type, financial_year)
+		 DO UPDATE SET last_number = invoice_number_series.last_number + 1
+		 RETURNING last_number`,
+		documentType, financialYear,
+	).Scan(&seq)
 	if err != nil {
-		return "", fmt.Errorf("failed to get next invoice number: %w", err)
+		return 0, "", fmt.Errorf("failed to get next invoice number: %w", err)
 	}
 
-	invoiceNumber := fmt.Sprintf("INV-%06d", nextNum)
-	return invoiceNumber, nil
+	// GST allows at most 16 characters: INV/26-27/00001
+	number := fmt.Sprintf("%s/%s/%05d", invoiceNumberPrefixes[documentType], financialYear[2:], seq)
+	return seq, number, nil
 }
 
-// Create creates a new invoice with items
-func (r *InvoiceRepository) Create(ctx context.Context, invoice *models.Invoice, items []models.InvoiceItem) error {
-	// Start transaction
-	tx, err := r.DB.Begin(ctx)
+// insertInvoice numbers and writes an invoice with its items inside tx
+func insertInvoice(ctx context.Context, tx pgx.Tx, invoice *models.Invoice, items []models.InvoiceItem) error {
+	var err error
+	invoice.SequenceNumber, invoice.InvoiceNumber, err = nextInvoiceNumber(ctx, tx, invoice.DocumentType, invoice.FinancialYear)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	invoice.ItemsCount = len(items)
+	invoice.Status = models.InvoiceStatusIssued
 
-	// Generate invoice number if not provided
-	if invoice.InvoiceNumber == "" {
-		invoiceNumber, err := r.GenerateInvoiceNumber(ctx)
-		if err != nil {
-			return err
-		}
-		invoice.InvoiceNumber = invoiceNumber
-	}
-
-	// Insert invoice
 	err = tx.QueryRow(ctx,
-		`INSERT INTO invoices(invoice_number, customer_id, employee_id, total_amount, items_count, notes)
-		 VALUES($1, $2, $3, $4, $5, $6)
+		`INSERT INTO invoices(invoice_number, customer_id, employee_id, total_amount, items_count, notes,
+		                      document_type, financial_year, sequence_number, status,
+		                      company_gstin, customer_gstin, place_of_supply, taxable_amount,
+		                      cgst_rate, cgst_amount, sgst_rate, sgst_amount, igst_rate, igst_amount,
+		                      original_invoice_id)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''),
+		        $14, $15, $16, $17, $18, $19, $20, $21)
 		 RETURNING id, created_at, updated_at`,
 		invoice.InvoiceNumber, invoice.CustomerID, invoice.EmployeeID,
-		invoice.TotalAmount, len(items), invoice.Notes,
+		invoice.TotalAmount, invoice.ItemsCount, invoice.Notes,
+		invoice.DocumentType, invoice.FinancialYear, invoice.SequenceNumber, invoice.Status,
+		invoice.CompanyGSTIN, invoice.CustomerGSTIN, invoice.PlaceOfSupply, invoice.TaxableAmount,
+		invoice.CGSTRate, invoice.CGSTAmount, invoice.SGSTRate, invoice.SGSTAmount, invoice.IGSTRate, invoice.IGSTAmount,
+		invoice.OriginalInvoiceID,
 	).Scan(&invoice.ID, &invoice.CreatedAt, &invoice.UpdatedAt)
-
 	if err != nil {
 		return err
 	}
 
-	// Insert invoice items
 	for _, item := range items {
 		_, err = tx.Exec(ctx,
-			`INSERT INTO invoice_items(invoice_id, entry_id, thock_number, quantity, rate, amount)
-			 VALUES($1, $2, $3, $4, $5, $6)`,
-			invoice.ID, item.EntryID, item.ThockNumber, item.Quantity, item.Rate, item.Amount,
+			`INSERT INTO invoice_items(invoice_id, entry_id, thock_number, description, hsn_sac, quantity, rate, amount)
+			 VALUES($1, $2, $3, $4, $5, $6, $7, $8)`,
+			invoice.ID, item.EntryID, item.ThockNumber, item.Description, item.HSNSAC, item.Quantity, item.Rate, item.Amount,
 		)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// Create creates a new invoice with items, numbered in its financial-year series
+func (r *InvoiceRepository) Create(ctx context.Context, invoice *models.Invoice, items []models.InvoiceItem) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
 
-	// Commit transaction
+	if err := insertInvoice(ctx, tx, invoice, items); err != nil {
+		return err
+	}
 	return tx.Commit(ctx)
 }
 
-// Get retrieves an invoice by ID with items
-func (r *InvoiceRepository) Get(ctx context.Context, id int) (*models.InvoiceWithDetails, error) {
-	// Get invoice
-	var invoice models.InvoiceWithDetails
-	err := r.DB.QueryRow(ctx,
-		`SELECT i.id, i.invoice_number, i.customer_id, i.employee_id, i.total_amount,
-		        i.items_count, i.notes, i.created_at, i.updated_at,
-		        COALESCE(c.name, '') as customer_name, COALESCE(u.name, '') as employee_name
-		 FROM invoices i
-		 LEFT JOIN customers c ON i.customer_id = c.id
-		 LEFT JOIN users u ON i.employee_id = u.id
-		 WHERE i.id = $1`, id,
-	).Scan(&invoice.ID, &invoice.InvoiceNumber, &invoice.CustomerID, &invoice.EmployeeID,
-		&invoice.TotalAmount, &invoice.ItemsCount, &invoice.Notes, &invoice.CreatedAt,
-		&invoice.UpdatedAt, &invoice.CustomerName, &invoice.EmployeeName)
+// Cancel marks a tax invoice cancelled and issues its credit note in one transaction
+func (r *InvoiceRepository) Cancel(ctx context.Context, originalID int, reason string, creditNote *models.Invoice, items []models.InvoiceItem) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the invoice so it cannot be cancelled twice
+	var documentType, status string
+	err = tx.QueryRow(ctx,
+		`SELECT document_type, status FROM invoices WHERE id = $1 FOR UPDATE`, originalID,
+	).Scan(&documentType, &status)
+	if err != nil {
+		return err
+	}
+	if documentType != models.InvoiceTypeTaxInvoice || status != models.InvoiceStatusIssued {
+		return ErrInvoiceNotCancellable
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE invoices SET status = $2, cancel_reason = $3, cancelled_at = NOW(), updated_at = NOW()
+		 WHERE id = $1`,
+		originalID, models.InvoiceStatusCancelled, reason)
+	if err != nil {
+		return err
+	}
+
+	creditNote.OriginalInvoiceID = &originalID
+	if err := insertInvoice(ctx, tx, creditNote, items); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
 
+// scanInvoice scans one invoiceSelect row
+func scanInvoice(row pgx.Row) (*models.InvoiceWithDetails, error) {
+	var i models.InvoiceWithDetails
+	err := row.Scan(&i.ID, &i.InvoiceNumber, &i.CustomerID, &i.EmployeeID, &i.TotalAmount,
+		&i.ItemsCount, &i.Notes, &i.CreatedAt, &i.UpdatedAt,
+		&i.DocumentType, &i.FinancialYear, &i.SequenceNumber, &i.Status,
+		&i.CompanyGSTIN, &i.CustomerGSTIN, &i.PlaceOfSupply,
+		&i.TaxableAmount, &i.CGSTRate, &i.CGSTAmount, &i.SGSTRate, &i.SGSTAmount, &i.IGSTRate, &i.IGSTAmount,
+		&i.OriginalInvoiceID, &i.CancelReason, &i.CancelledAt,
+		&i.CustomerName, &i.CustomerPhone, &i.CustomerSO, &i.CustomerVillage, &i.CustomerAddress,
+		&i.EmployeeName, &i.OriginalNumber)
 	if err != nil {
 		return nil, err
 	}
+	return &i, nil
+}
 
-	// Get invoice items
+// getItems loads the items of an invoice
+func (r *InvoiceRepository) getItems(ctx context.Context, invoiceID int) ([]models.InvoiceItem, error) {
 	rows, err := r.DB.Query(ctx,
-		`SELECT id, invoice_id, entry_id, thock_number, quantity, rate, amount, created_at
-		 FROM invoice_items WHERE invoice_id = $1`, id,
+		`SELECT id, invoice_id, entry_id, COALESCE(thock_number, ''), COALESCE(description, ''), COALESCE(hsn_sac, ''),
+		        quantity, rate, amount, created_at
+		 FROM invoice_items WHERE invoice_id = $1 ORDER BY id`, invoiceID,
 	)
 	if err != nil {
 		return nil, err
@@ -109,73 +188,47 @@ func (r *InvoiceRepository) Get(ctx context.Context, id int) (*models.InvoiceWit
 	var items []models.InvoiceItem
 	for rows.Next() {
 		var item models.InvoiceItem
-		err := rows.Scan(&item.ID, &item.InvoiceID, &item.EntryID, &item.ThockNumber,
+		err := rows.Scan(&item.ID, &item.InvoiceID, &item.EntryID, &item.ThockNumber, &item.Description, &item.HSNSAC,
 			&item.Quantity, &item.Rate, &item.Amount, &item.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
 		items = append(items, item)
 	}
-
-	invoice.Items = items
-	return &invoice, nil
+	return items, rows.Err()
 }
 
-// GetByInvoiceNumber retrieves an invoice by invoice number
-func (r *InvoiceRepository) GetByInvoiceNumber(ctx context.Context, invoiceNumber string) (*models.InvoiceWithDetails, error) {
-	var invoice models.InvoiceWithDetails
-	err := r.DB.QueryRow(ctx,
-		`SELECT i.id, i.invoice_number, i.customer_id, i.employee_id, i.total_amount,
-		        i.items_count, i.notes, i.created_at, i.updated_at,
-		        COALESCE(c.name, '') as customer_name, COALESCE(u.name, '') as employee_name
-		 FROM invoices i
-		 LEFT JOIN customers c ON i.customer_id = c.id
-		 LEFT JOIN users u ON i.employee_id = u.id
-		 WHERE i.invoice_number = $1`, invoiceNumber,
-	).Scan(&invoice.ID, &invoice.InvoiceNumber, &invoice.CustomerID, &invoice.EmployeeID,
-		&invoice.TotalAmount, &invoice.ItemsCount, &invoice.Notes, &invoice.CreatedAt,
-		&invoice.UpdatedAt, &invoice.CustomerName, &invoice.EmployeeName)
-
+// Get retrieves an invoice by ID with items
+func (r *InvoiceRepository) Get(ctx context.Context, id int) (*models.InvoiceWithDetails, error) {
+	invoice, err := scanInvoice(r.DB.QueryRow(ctx, invoiceSelect+` WHERE i.id = $1`, id))
 	if err != nil {
 		return nil, err
 	}
 
-	// Get invoice items
-	rows, err := r.DB.Query(ctx,
-		`SELECT id, invoice_id, entry_id, thock_number, quantity, rate, amount, created_at
-		 FROM invoice_items WHERE invoice_id = $1`, invoice.ID,
-	)
+	invoice.Items, err = r.getItems(ctx, invoice.ID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return invoice, nil
+}
 
-	var items []models.InvoiceItem
-	for rows.Next() {
-		var item models.InvoiceItem
-		err := rows.Scan(&item.ID, &item.InvoiceID, &item.EntryID, &item.ThockNumber,
-			&item.Quantity, &item.Rate, &item.Amount, &item.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		items = append(items, item)
+// GetByInvoiceNumber retrieves an invoice by invoice number
+func (r *InvoiceRepository) GetByInvoiceNumber(ctx context.Context, invoiceNumber string) (*models.InvoiceWithDetails, error) {
+	invoice, err := scanInvoice(r.DB.QueryRow(ctx, invoiceSelect+` WHERE i.invoice_number = $1`, invoiceNumber))
+	if err != nil {
+		return nil, err
 	}
 
-	invoice.Items = items
-	return &invoice, nil
+	invoice.Items, err = r.getItems(ctx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
 }
 
 // List returns all invoices
 func (r *InvoiceRepository) List(ctx context.Context) ([]*models.InvoiceWithDetails, error) {
-	rows, err := r.DB.Query(ctx,
-		`SELECT i.id, i.invoice_number, i.customer_id, i.employee_id, i.total_amount,
-		        i.items_count, i.notes, i.created_at, i.updated_at,
-		        COALESCE(c.name, '') as customer_name, COALESCE(u.name, '') as employee_name
-		 FROM invoices i
-		 LEFT JOIN customers c ON i.customer_id = c.id
-		 LEFT JOIN users u ON i.employee_id = u.id
-		 ORDER BY i.created_at DESC`,
-	)
+	rows, err := r.DB.Query(ctx, invoiceSelect+` ORDER BY i.created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -183,26 +236,19 @@ func (r *InvoiceRepository) List(ctx context.Context) ([]*models.InvoiceWithDeta
 
 	var invoices []*models.InvoiceWithDetails
 	for rows.Next() {
-		var invoice models.InvoiceWithDetails
-		err := rows.Scan(&invoice.ID, &invoice.InvoiceNumber, &invoice.CustomerID,
-			&invoice.EmployeeID, &invoice.TotalAmount, &invoice.ItemsCount, &invoice.Notes,
-			&invoice.CreatedAt, &invoice.UpdatedAt, &invoice.CustomerName, &invoice.EmployeeName)
+		invoice, err := scanInvoice(rows)
 		if err != nil {
 			return nil, err
 		}
-		invoices = append(invoices, &invoice)
+		invoices = append(invoices, invoice)
 	}
 
-	return invoices, nil
+	return invoices, rows.Err()
 }
 
 // GetByCustomer returns all invoices for a customer
 func (r *InvoiceRepository) GetByCustomer(ctx context.Context, customerID int) ([]*models.Invoice, error) {
-	rows, err := r.DB.Query(ctx,
-		`SELECT id, invoice_number, customer_id, employee_id, total_amount, items_count,
-		        notes, created_at, updated_at
-		 FROM invoices WHERE customer_id = $1 ORDER BY created_at DESC`, customerID,
-	)
+	rows, err := r.DB.Query(ctx, invoiceSelect+` WHERE i.customer_id = $1 ORDER BY i.created_at DESC`, customerID)
 	if err != nil {
 		return nil, err
 	}
@@ -210,15 +256,12 @@ func (r *InvoiceRepository) GetByCustomer(ctx context.Context, customerID int) (
 
 	var invoices []*models.Invoice
 	for rows.Next() {
-		var invoice models.Invoice
-		err := rows.Scan(&invoice.ID, &invoice.InvoiceNumber, &invoice.CustomerID,
-			&invoice.EmployeeID, &invoice.TotalAmount, &invoice.ItemsCount, &invoice.Notes,
-			&invoice.CreatedAt, &invoice.UpdatedAt)
+		invoice, err := scanInvoice(rows)
 		if err != nil {
 			return nil, err
 		}
-		invoices = append(invoices, &invoice)
+		invoices = append(invoices, &invoice.Invoice)
 	}
 
-	return invoices, nil
+	return invoices, rows.Err()
 }
@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type JournalRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewJournalRepository(db *pgxpool.Pool) *JournalRepository {
+	return &JournalRepository{DB: db}
+}
+
+// journalPosting is one side of a journal before it is written
+type journalPosting struct {
+	accountCode string
+	debit       float64
+	credit      float64
+}
+
+// journalPostingsFor maps a customer ledger entry to balanced journal lines.
+// Returns nil for entries without an amount (DEBT_APPROVAL).
+func journalPostingsFor(entryType models.LedgerEntryType, referenceType string, debit, credit float64) ([]journalPosting, error) {
+	if debit <= 0 && credit <= 0 {
+		return nil, nil
+	}
+
+	debitAccount, creditAccount, ok := models.JournalAccounts(entryType, referenceType)
+	if !ok {
+		return nil, fmt.Errorf("no journal mapping for entry type %s", entryType)
+	}
+
+	// Customer owes more (receivables go up) on the debit, paid or was credited on the credit
+	amount := credit
+	if debitAccount == models.AccountCodeReceivables {
+		amount = debit
+	}
+	return []journalPosting{
+		{accountCode: debitAccount, debit: amount},
+		{accountCode: creditAccount, credit: amount},
+	}, nil
+}
+
+// postJournal writes the journal for a ledger entry inside the ledger entry's transaction
+func postJournal(ctx context.Context, tx pgx.Tx, ledgerEntryID int, postedAt time.Time, entry *models.CreateLedgerEntryRequest) error {
+	postings, err := journalPostingsFor(entry.EntryType, entry.ReferenceType, entry.Debit, entry.Credit)
+	if err != nil || len(postings) == 0 {
+		return err
+	}
+
+	var journalID int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO journal_entries (ledger_entry_id, posted_at, narration, customer_phone)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING id`,
+		ledgerEntryID, postedAt, entry.Description, entry.CustomerPhone,
+	).Scan(&journalID)
+	if err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	for _, p := range postings {
+		result, err := tx.Exec(ctx,
+			`INSERT INTO journal_lines (journal_entry_id, account_id, debit, credit)
+			 SELECT $1, id, $3, $4 FROM ledger_accounts WHERE code = $2`,
+			journalID, p.accountCode, p.debit, p.credit,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create journal line: %w", err)
+		}
+		if result.RowsAffected() == 0 {
+			return fmt.Errorf("ledger account %s not found", p.accountCode)
+		}
+	}
+	return nil
+}
+
+// ListAccounts returns the chart of accounts
+func (r *JournalRepository) ListAccounts(ctx context.Context) ([]models.LedgerAccount, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT id, code, name, account_type, is_active, created_at
+		 FROM ledger_accounts
+		 ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.LedgerAccount
+	for rows.Next() {
+		var a models.LedgerAccount
+		if err := rows.Scan(&a.ID, &a.Code, &a.Name, &a.AccountType, &a.IsActive, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetAccountTotals returns debit/credit totals per account for journals posted in [from, to).
+// A zero from means "since the beginning".
+func (r *JournalRepository) GetAccountTotals(ctx context.Context, from, to time.Time) ([]models.TrialBalanceRow, error) {
+	query := `
+		SELECT a.code, a.name, a.account_type,
+		       COALESCE(t.total_debit, 0), COALESCE(t.total_credit, 0)
+		FROM ledger_accounts a
+		LEFT JOIN (
+			SELECT l.account_id, SUM(l.debit) as total_debit, SUM(l.credit) as total_credit
+			FROM journal_lines l
+			JOIN journal_entries j ON j.id = l.journal_entry_id
+			WHERE j.posted_at >= $1 AND j.posted_at < $2
+			GROUP BY l.account_id
+		) t ON t.account_id = a.id
+		ORDER BY a.code
+	`
+	rows, err := r.DB.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.TrialBalanceRow
+	for rows.Next() {
+		var row models.TrialBalanceRow
+		if err := rows.Scan(&row.AccountCode, &row.AccountName, &row.AccountType, &row.TotalDebit, &row.TotalCredit); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// GetAccountBalance returns debit minus credit for an account over journals posted before the given time
+func (r *JournalRepository) GetAccountBalance(ctx context.Context, accountCode string, before time.Time) (float64, error) {
+	var balance float64
+	err := r.DB.QueryRow(ctx, `
+		SELECT COALESCE(SUM(l.debit) - SUM(l.credit), 0)
+		FROM journal_lines l
+		JOIN journal_entries j ON j.id = l.journal_entry_id
+		JOIN ledger_accounts a ON a.id = l.account_id
+		WHERE a.code = $1 AND j.posted_at < $2
+	`, accountCode, before).Scan(&balance)
+	return balance, err
+}
+
+// GetJournals returns journals posted in [from, to) with their lines, oldest first.
+// If accountCode is set only journals touching that account are returned.
+func (r *JournalRepository) GetJournals(ctx context.Context, from, to time.Time, accountCode string) ([]models.JournalEntry, error) {
+	query := `
+		SELECT j.id, j.ledger_entry_id, j.posted_at, COALESCE(j.narration, ''), COALESCE(j.customer_phone, ''),
+		       le.entry_type, a.code, a.name, l.debit, l.credit
+		FROM journal_entries j
+		JOIN ledger_entries le ON le.id = j.ledger_entry_id
+		JOIN journal_lines l ON l.journal_entry_id = j.id
+		JOIN ledger_accounts a ON a.id = l.account_id
+		WHERE j.posted_at >= $1 AND j.posted_at < $2
+		  AND ($3 = '' OR EXISTS (
+		      SELECT 1 FROM journal_lines fl
+		      JOIN ledger_accounts fa ON fa.id = fl.account_id
+		      WHERE fl.journal_entry_id = j.id AND fa.code = $3
+		  ))
+		ORDER BY j.posted_at, j.id, l.debit DESC, l.id
+	`
+	rows, err := r.DB.Query(ctx, query, from, to, accountCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var journals []models.JournalEntry
+	for rows.Next() {
+		var j models.JournalEntry
+		var line models.JournalLine
+		if err := rows.Scan(
+			&j.ID, &j.LedgerEntryID, &j.PostedAt, &j.Narration, &j.CustomerPhone,
+			&j.EntryType, &line.AccountCode, &line.AccountName, &line.Debit, &line.Credit,
+		); err != nil {
+			return nil, err
+		}
+		if n := len(journals); n > 0 && journals[n-1].ID == j.ID {
+			journals[n-1].Lines = append(journals[n-1].Lines, line)
+			continue
+		}
+		j.Lines = []models.JournalLine{line}
+		journals = append(journals, j)
+	}
+	return journals, rows.Err()
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// expectedBalanceSelect recomputes every ledger row's running balance from scratch.
+// Rows are grouped per customer (phone for rows without a customer) in posting order.
+const expectedBalanceSelect = `
+	SELECT id, COALESCE(customer_id, 0) AS customer_id, customer_phone, customer_name, running_balance,
+		SUM(debit - credit) OVER (
+			PARTITION BY COALESCE(customer_id::text, 'phone:' || customer_phone)
+			ORDER BY created_at, id
+		) AS expected_balance
+	FROM ledger_entries`
+
+// balanceTolerance ignores sub-paisa rounding differences
+const balanceTolerance = 0.005
+
+// LedgerIntegrityRepository runs the read-heavy checks behind the ledger verifier
+type LedgerIntegrityRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewLedgerIntegrityRepository(db *pgxpool.Pool) *LedgerIntegrityRepository {
+	return &LedgerIntegrityRepository{DB: db}
+}
+
+// CountLedger returns the number of ledger rows and distinct customers in the ledger
+func (r *LedgerIntegrityRepository) CountLedger(ctx context.Context) (entries int, customers int, err error) {
+	err = r.DB.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT COALESCE(customer_id::text, 'phone:' || customer_phone))
+		 FROM ledger_entries`).Scan(&entries, &customers)
+	return entries, customers, err
+}
+
+// GetRunningBalanceMismatches returns ledger rows whose stored running balance is wrong
+func (r *LedgerIntegrityRepository) GetRunningBalanceMismatches(ctx context.Context) ([]models.RunningBalanceRow, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT id, customer_id, customer_phone, customer_name, running_balance, expected_balance
+		 FROM (`+expectedBalanceSelect+`) x
+		 WHERE ABS(running_balance - expected_balance) > $1
+		 ORDER BY customer_id, customer_phone, id`,
+		balanceTolerance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.RunningBalanceRow
+	for rows.Next() {
+		var row models.RunningBalanceRow
+		if err := rows.Scan(&row.ID, &row.CustomerID, &row.CustomerPhone, &row.CustomerName,
+			&row.RunningBalance, &row.ExpectedBalance); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// GetPaymentMismatches returns rent payments with no ledger credit or a credit of a different amount
+func (r *LedgerIntegrityRepository) GetPaymentMismatches(ctx context.Context) ([]models.LedgerIntegrityIssue, error) {
+	return r.querySourceIssues(ctx, "rent_payment", `
+		SELECT rp.id, COALESCE(rp.customer_id, 0), rp.customer_phone, rp.customer_name,
+		       rp.amount_paid, le.id, COALESCE(le.credit, 0)
+		FROM rent_payments rp
+		LEFT JOIN ledger_entries le ON le.reference_type = 'payment' AND le.reference_id = rp.id
+		WHERE rp.amount_paid > 0
+		  AND (le.id IS NULL OR ABS(le.credit - rp.amount_paid) > $1)
+		ORDER BY rp.id`,
+		models.IntegrityIssueMissingPaymentEntry, models.IntegrityIssuePaymentAmount)
+}
+
+// GetOnlineTransactionMismatches returns successful online payments with no ledger credit or a wrong amount
+func (r *LedgerIntegrityRepository) GetOnlineTransactionMismatches(ctx context.Context) ([]models.LedgerIntegrityIssue, error) {
+	return r.querySourceIssues(ctx, "online_transaction", `
+		SELECT ot.id, ot.customer_id, ot.customer_phone, ot.customer_name,
+		       ot.amount, le.id, COALESCE(le.credit, 0)
+		FROM online_transactions ot
+		LEFT JOIN ledger_entries le ON le.id = ot.ledger_entry_id
+		WHERE ot.status = 'success'
+		  AND (le.id IS NULL OR ABS(le.credit - ot.amount) > $1)
+		ORDER BY ot.id`,
+		models.IntegrityIssueMissingOnlineEntry, models.IntegrityIssueOnlineAmount)
+}
+
+// querySourceIssues scans (source id, customer, expected, ledger id, actual) rows into issues.
+// A missing ledger row becomes missingType, an amount difference becomes amountType.
+func (r *LedgerIntegrityRepository) querySourceIssues(ctx context.Context, sourceType, query, missingType, amountType string) ([]models.LedgerIntegrityIssue, error) {
+	rows, err := r.DB.Query(ctx, query, balanceTolerance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []models.LedgerIntegrityIssue
+	for rows.Next() {
+		var sourceID int
+		var ledgerID *int
+		issue := models.LedgerIntegrityIssue{SourceType: sourceType}
+		if err := rows.Scan(&sourceID, &issue.CustomerID, &issue.CustomerPhone, &issue.CustomerName,
+			&issue.Expected, &ledgerID, &issue.Actual); err != nil {
+			return nil, err
+		}
+		issue.SourceID = &sourceID
+		if ledgerID == nil {
+			issue.Type = missingType
+			issue.Message = fmt.Sprintf("%s #%d of %.2f has no ledger entry", sourceType, sourceID, issue.Expected)
+		} else {
+			issue.Type = amountType
+			issue.LedgerEntryIDs = []int{*ledgerID}
+			issue.Message = fmt.Sprintf("%s #%d is %.2f but ledger entry #%d credits %.2f",
+				sourceType, sourceID, issue.Expected, *ledgerID, issue.Actual)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// GetDebtApprovalMismatches returns used debt requests without an audit entry and
+// DEBT_APPROVAL entries that carry an amount (they must never move the balance)
+func (r *LedgerIntegrityRepository) GetDebtApprovalMismatches(ctx context.Context) ([]models.LedgerIntegrityIssue, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT dr.id, COALESCE(dr.customer_id, 0), dr.customer_phone, dr.customer_name
+		FROM debt_requests dr
+		WHERE dr.status = 'used'
+		  AND NOT EXISTS (
+			SELECT 1 FROM ledger_entries le
+			WHERE le.entry_type = 'DEBT_APPROVAL' AND le.reference_type = 'debt_request' AND le.reference_id = dr.id
+		  )
+		ORDER BY dr.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []models.LedgerIntegrityIssue
+	for rows.Next() {
+		var sourceID int
+		issue := models.LedgerIntegrityIssue{
+			Type:       models.IntegrityIssueMissingDebtApproval,
+			SourceType: "debt_request",
+		}
+		if err := rows.Scan(&sourceID, &issue.CustomerID, &issue.CustomerPhone, &issue.CustomerName); err != nil {
+			return nil, err
+		}
+		issue.SourceID = &sourceID
+		issue.Message = fmt.Sprintf("debt_request #%d was used but has no DEBT_APPROVAL ledger entry", sourceID)
+		issues = append(issues, issue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	amountRows, err := r.DB.Query(ctx, `
+		SELECT id, COALESCE(customer_id, 0), customer_phone, customer_name, reference_id, debit - credit
+		FROM ledger_entries
+		WHERE entry_type = 'DEBT_APPROVAL' AND (debit <> 0 OR credit <> 0)
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer amountRows.Close()
+
+	for amountRows.Next() {
+		var ledgerID int
+		issue := models.LedgerIntegrityIssue{
+			Type:       models.IntegrityIssueDebtApprovalAmount,
+			SourceType: "debt_request",
+		}
+		if err := amountRows.Scan(&ledgerID, &issue.CustomerID, &issue.CustomerPhone, &issue.CustomerName,
+			&issue.SourceID, &issue.Actual); err != nil {
+			return nil, err
+		}
+		issue.LedgerEntryIDs = []int{ledgerID}
+		issue.Message = fmt.Sprintf("DEBT_APPROVAL ledger entry #%d changes the balance by %.2f", ledgerID, issue.Actual)
+		issues = append(issues, issue)
+	}
+	return issues, amountRows.Err()
+}
+
+// RepairRunningBalances rewrites every wrong running balance and records the audit
+// log in the same transaction. New ledger entries are blocked while it runs.
+func (r *LedgerIntegrityRepository) RepairRunningBalances(ctx context.Context, auditLog *models.AdminActionLog) (int64, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start repair: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE ledger_entries IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return 0, fmt.Errorf("failed to lock ledger: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE ledger_entries le
+		SET running_balance = x.expected_balance
+		FROM (`+expectedBalanceSelect+`) x
+		WHERE le.id = x.id AND ABS(le.running_balance - x.expected_balance) > $1`,
+		balanceTolerance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to repair running balances: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO admin_action_logs (
+			admin_user_id, action_type, target_type, target_id,
+			description, old_value, new_value, ip_address, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())`,
+		auditLog.AdminUserID, auditLog.ActionType, auditLog.TargetType, auditLog.TargetID,
+		auditLog.Description, auditLog.OldValue, auditLog.NewValue, auditLog.IPAddress,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record repair: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit repair: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// FirstAdminUserID returns the oldest admin account, used to attribute CLI repairs
+func (r *LedgerIntegrityRepository) FirstAdminUserID(ctx context.Context) (int, error) {
+	var id int
+	err := r.DB.QueryRow(ctx, `SELECT id FROM users WHERE role = 'admin' ORDER BY id LIMIT 1`).Scan(&id)
+	return id, err
+}
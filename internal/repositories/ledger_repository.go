@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,6 +13,23 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrDuplicateLedgerEntry is returned when an entry with the same idempotency key already exists
+var ErrDuplicateLedgerEntry = errors.New("ledger entry already exists for idempotency key")
+
+// customerByPhone matches rows of the customer that currently owns the phone in $1.
+// Ledger, rent payment and debt request rows are keyed by customer_id; rows without
+// a customer record fall back to customer_phone.
+const customerByPhone = `(customer_id = (SELECT COALESCE(c.merged_into_customer_id, c.id) FROM customers c WHERE c.phone = $1)
+	OR (customer_id IS NULL AND customer_phone = $1))`
+
+// ledgerEntryColumns is the column list scanned by scanLedgerEntries
+const ledgerEntryColumns = `id, COALESCE(customer_id, 0), customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
+	entry_type, COALESCE(description, '') as description, debit, credit, running_balance,
+	reference_id, COALESCE(reference_type, '') as reference_type,
+	family_member_id, COALESCE(family_member_name, '') as family_member_name,
+	created_by_user_id, COALESCE(created_by_name, '') as created_by_name,
+	created_at, COALESCE(notes, '') as notes`
+
 type LedgerRepository struct {
 	DB *pgxpool.Pool
 }
@@ -20,10 +38,21 @@ func NewLedgerRepository(db *pgxpool.Pool) *LedgerRepository {
 	return &LedgerRepository{DB: db}
 }
 
-// Create creates a new ledger entry and calculates running balance
+// Create creates a new ledger entry, calculates running balance and posts its journal
 func (r *LedgerRepository) Create(ctx context.Context, entry *models.CreateLedgerEntryRequest) (*models.LedgerEntry, error) {
+	// Resolve the owning customer so history survives phone changes and merges
+	if entry.CustomerID == 0 {
+		entry.CustomerID, _ = r.CustomerIDForPhone(ctx, entry.CustomerPhone)
+	}
+
 	// Get current balance for customer
-	currentBalance, err := r.GetBalance(ctx, entry.CustomerPhone)
+	var currentBalance float64
+	var err error
+	if entry.CustomerID > 0 {
+		currentBalance, err = r.GetBalanceByCustomerID(ctx, entry.CustomerID)
+	} else {
+		currentBalance, err = r.GetBalance(ctx, entry.CustomerPhone)
+	}
 	if err != nil {
 		currentBalance = 0 // First entry for this customer
 	}
@@ -47,14 +76,22 @@ func (r *LedgerRepository) Create(ctx context.Context, entry *models.CreateLedge
 			customer_phone, customer_name, customer_so, entry_type, description,
 			debit, credit, running_balance, reference_id, reference_type,
 			family_member_id, family_member_name,
-			created_by_user_id, created_by_name, notes
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			created_by_user_id, created_by_name, notes, idempotency_key, customer_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''), NULLIF($17, 0))
+		ON CONFLICT (idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
 		RETURNING id, created_at
 	`
 
+	// Ledger entry and its double-entry journal are written together
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ledger entry: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	var id int
 	var createdAt time.Time
-	err = r.DB.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		entry.CustomerPhone,
 		entry.CustomerName,
 		entry.CustomerSO,
@@ -70,14 +107,27 @@ func (r *LedgerRepository) Create(ctx context.Context, entry *models.CreateLedge
 		entry.CreatedByUserID,
 		createdByName,
 		entry.Notes,
+		entry.IdempotencyKey,
+		entry.CustomerID,
 	).Scan(&id, &createdAt)
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDuplicateLedgerEntry
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ledger entry: %w", err)
 	}
 
+	if err := postJournal(ctx, tx, id, createdAt, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create ledger entry: %w", err)
+	}
+
 	return &models.LedgerEntry{
 		ID:               id,
+		CustomerID:       entry.CustomerID,
 		CustomerPhone:    entry.CustomerPhone,
 		CustomerName:     entry.CustomerName,
 		CustomerSO:       entry.CustomerSO,
@@ -94,16 +144,37 @@ func (r *LedgerRepository) Create(ctx context.Context, entry *models.CreateLedge
 		CreatedByName:    createdByName,
 		CreatedAt:        createdAt,
 		Notes:            entry.Notes,
+		IdempotencyKey:   entry.IdempotencyKey,
 	}, nil
 }
 
-// GetBalance returns the current balance for a customer
+// CustomerIDForPhone returns the customer that owns a phone number (following merges), or 0
+func (r *LedgerRepository) CustomerIDForPhone(ctx context.Context, phone string) (int, error) {
+	var id int
+	err := r.DB.QueryRow(ctx,
+		"SELECT COALESCE(merged_into_customer_id, id) FROM customers WHERE phone = $1",
+		phone).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// GetBalanceByCustomerID returns the current balance for a customer
+func (r *LedgerRepository) GetBalanceByCustomerID(ctx context.Context, customerID int) (float64, error) {
+	var balance float64
+	err := r.DB.QueryRow(ctx,
+		"SELECT COALESCE(SUM(debit) - SUM(credit), 0) FROM ledger_entries WHERE customer_id = $1",
+		customerID).Scan(&balance)
+	return balance, err
+}
+
+// GetBalance returns the current balance for the customer that owns a phone number
 func (r *LedgerRepository) GetBalance(ctx context.Context, customerPhone string) (float64, error) {
 	query := `
 		SELECT COALESCE(SUM(debit) - SUM(credit), 0) as balance
 		FROM ledger_entries
-		WHERE customer_phone = $1
-	`
+		WHERE ` + customerByPhone
 
 	var balance float64
 	err := r.DB.QueryRow(ctx, query, customerPhone).Scan(&balance)
@@ -113,26 +184,39 @@ func (r *LedgerRepository) GetBalance(ctx context.Context, customerPhone string)
 	return balance, nil
 }
 
-// GetByCustomer returns all ledger entries for a customer
+// GetByCustomer returns all ledger entries for the customer that owns a phone number
 func (r *LedgerRepository) GetByCustomer(ctx context.Context, customerPhone string, limit, offset int) ([]models.LedgerEntry, error) {
 	if limit <= 0 {
 		limit = 100
 	}
 
-	query := `
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
-			entry_type, COALESCE(description, '') as description, debit, credit, running_balance,
-			reference_id, COALESCE(reference_type, '') as reference_type,
-			family_member_id, COALESCE(family_member_name, '') as family_member_name,
-			created_by_user_id, COALESCE(created_by_name, '') as created_by_name,
-			created_at, COALESCE(notes, '') as notes
+	query := `SELECT ` + ledgerEntryColumns + `
 		FROM ledger_entries
-		WHERE customer_phone = $1
+		WHERE ` + customerByPhone + `
 		ORDER BY created_at DESC, id DESC
-		LIMIT $2 OFFSET $3
-	`
+		LIMIT $2 OFFSET $3`
+
+	return r.queryEntries(ctx, query, customerPhone, limit, offset)
+}
+
+// GetByCustomerID returns all ledger entries for a customer
+func (r *LedgerRepository) GetByCustomerID(ctx context.Context, customerID int, limit, offset int) ([]models.LedgerEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
 
-	rows, err := r.DB.Query(ctx, query, customerPhone, limit, offset)
+	query := `SELECT ` + ledgerEntryColumns + `
+		FROM ledger_entries
+		WHERE customer_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	return r.queryEntries(ctx, query, customerID, limit, offset)
+}
+
+// queryEntries runs a query selecting ledgerEntryColumns and scans the rows
+func (r *LedgerRepository) queryEntries(ctx context.Context, query string, args ...interface{}) ([]models.LedgerEntry, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -141,22 +225,20 @@ func (r *LedgerRepository) GetByCustomer(ctx context.Context, customerPhone stri
 	var entries []models.LedgerEntry
 	for rows.Next() {
 		var e models.LedgerEntry
-		var refID *int
 		err := rows.Scan(
-			&e.ID, &e.CustomerPhone, &e.CustomerName, &e.CustomerSO,
+			&e.ID, &e.CustomerID, &e.CustomerPhone, &e.CustomerName, &e.CustomerSO,
 			&e.EntryType, &e.Description, &e.Debit, &e.Credit, &e.RunningBalance,
-			&refID, &e.ReferenceType,
+			&e.ReferenceID, &e.ReferenceType,
 			&e.FamilyMemberID, &e.FamilyMemberName,
 			&e.CreatedByUserID, &e.CreatedByName, &e.CreatedAt, &e.Notes,
 		)
 		if err != nil {
 			return nil, err
 		}
-		e.ReferenceID = refID
 		entries = append(entries, e)
 	}
 
-	return entries, nil
+	return entries, rows.Err()
 }
 
 // GetAll returns all ledger entries with optional filters (for audit)
@@ -165,6 +247,12 @@ func (r *LedgerRepository) GetAll(ctx context.Context, filter *models.LedgerFilt
 	var args []interface{}
 	argNum := 1
 
+	if filter.CustomerID > 0 {
+		conditions = append(conditions, fmt.Sprintf("customer_id = $%d", argNum))
+		args = append(args, filter.CustomerID)
+		argNum++
+	}
+
 	if filter.CustomerPhone != "" {
 		// Use LIKE for fuzzy/partial search on phone OR name
 		conditions = append(conditions, fmt.Sprintf("(customer_phone LIKE $%d OR LOWER(customer_name) LIKE LOWER($%d))", argNum, argNum))
@@ -201,133 +289,102 @@ func (r *LedgerRepository) GetAll(ctx context.Context, filter *models.LedgerFilt
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, customer_phone, customer_name, COALESCE(customer_so, '') as customer_so,
-			entry_type, COALESCE(description, '') as description, debit, credit, running_balance,
-			reference_id, COALESCE(reference_type, '') as reference_type,
-			family_member_id, COALESCE(family_member_name, '') as family_member_name,
-			created_by_user_id, COALESCE(created_by_name, '') as created_by_name,
-			created_at, COALESCE(notes, '') as notes
+		SELECT %s
 		FROM ledger_entries
 		%s
 		ORDER BY created_at DESC, id DESC
 		LIMIT $%d OFFSET $%d
-	`, whereClause, argNum, argNum+1)
+	`, ledgerEntryColumns, whereClause, argNum, argNum+1)
 
 	args = append(args, limit, filter.Offset)
 
-	rows, err := r.DB.Query(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return r.queryEntries(ctx, query, args...)
+}
 
-	var entries []models.LedgerEntry
-	for rows.Next() {
-		var e models.LedgerEntry
-		var refID *int
-		err := rows.Scan(
-			&e.ID, &e.CustomerPhone, &e.CustomerName, &e.CustomerSO,
-			&e.EntryType, &e.Description, &e.Debit, &e.Credit, &e.RunningBalance,
-			&refID, &e.ReferenceType,
-			&e.FamilyMemberID, &e.FamilyMemberName,
-			&e.CreatedByUserID, &e.CreatedByName, &e.CreatedAt, &e.Notes,
-		)
-		if err != nil {
-			return nil, err
-		}
-		e.ReferenceID = refID
-		entries = append(entries, e)
-	}
+// ledgerSummarySelect aggregates ledger rows per customer. Rows are grouped by
+// customer_id (phone only for rows without a customer) and show the customer's current phone.
+const ledgerSummarySelect = `
+	SELECT
+		COALESCE(le.customer_id, 0),
+		COALESCE(MAX(c.phone), MAX(le.customer_phone)) as customer_phone,
+		COALESCE(MAX(c.name), MAX(le.customer_name)) as customer_name,
+		COALESCE(MAX(c.so), MAX(le.customer_so), '') as customer_so,
+		COALESCE(SUM(le.debit), 0) as total_debit,
+		COALESCE(SUM(le.credit), 0) as total_credit,
+		COALESCE(SUM(le.debit) - SUM(le.credit), 0) as current_balance,
+		COUNT(*) as entry_count
+	FROM ledger_entries le
+	LEFT JOIN customers c ON c.id = le.customer_id`
+
+// ledgerSummaryGroupBy groups ledgerSummarySelect by customer
+const ledgerSummaryGroupBy = `GROUP BY le.customer_id, CASE WHEN le.customer_id IS NULL THEN le.customer_phone END`
+
+// GetPostedBetween returns entries with an amount created in [start, end), oldest first (for accounting exports)
+func (r *LedgerRepository) GetPostedBetween(ctx context.Context, start, end time.Time) ([]models.LedgerEntry, error) {
+	query := `SELECT ` + ledgerEntryColumns + `
+		FROM ledger_entries
+		WHERE created_at >= $1 AND created_at < $2 AND (debit > 0 OR credit > 0)
+		ORDER BY created_at, id`
 
-	return entries, nil
+	return r.queryEntries(ctx, query, start, end)
 }
 
-// GetSummaryByCustomer returns balance summary for a customer
+// GetSummaryByCustomer returns balance summary for the customer that owns a phone number
 func (r *LedgerRepository) GetSummaryByCustomer(ctx context.Context, customerPhone string) (*models.LedgerSummary, error) {
-	query := `
-		SELECT
-			customer_phone,
-			MAX(customer_name) as customer_name,
-			COALESCE(MAX(customer_so), '') as customer_so,
-			COALESCE(SUM(debit), 0) as total_debit,
-			COALESCE(SUM(credit), 0) as total_credit,
-			COALESCE(SUM(debit) - SUM(credit), 0) as current_balance,
-			COUNT(*) as entry_count
-		FROM ledger_entries
-		WHERE customer_phone = $1
-		GROUP BY customer_phone
-	`
-
-	var s models.LedgerSummary
-	err := r.DB.QueryRow(ctx, query, customerPhone).Scan(
-		&s.CustomerPhone, &s.CustomerName, &s.CustomerSO,
-		&s.TotalDebit, &s.TotalCredit, &s.CurrentBalance, &s.EntryCount,
-	)
+	customerID, err := r.CustomerIDForPhone(ctx, customerPhone)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil // No entries for this customer
-		}
 		return nil, err
 	}
-	return &s, nil
-}
+	if customerID > 0 {
+		return r.GetSummaryByCustomerID(ctx, customerID)
+	}
 
-// GetAllCustomerBalances returns balance summaries for all customers
-func (r *LedgerRepository) GetAllCustomerBalances(ctx context.Context) ([]models.LedgerSummary, error) {
-	query := `
-		SELECT
-			customer_phone,
-			MAX(customer_name) as customer_name,
-			COALESCE(MAX(customer_so), '') as customer_so,
-			COALESCE(SUM(debit), 0) as total_debit,
-			COALESCE(SUM(credit), 0) as total_credit,
-			COALESCE(SUM(debit) - SUM(credit), 0) as current_balance,
-			COUNT(*) as entry_count
-		FROM ledger_entries
-		GROUP BY customer_phone
-		ORDER BY current_balance DESC
-	`
+	summaries, err := r.querySummaries(ctx,
+		ledgerSummarySelect+` WHERE le.customer_id IS NULL AND le.customer_phone = $1 `+ledgerSummaryGroupBy,
+		customerPhone)
+	if err != nil || len(summaries) == 0 {
+		return nil, err // No entries for this customer
+	}
+	return &summaries[0], nil
+}
 
-	rows, err := r.DB.Query(ctx, query)
-	if err != nil {
-		return nil, err
+// GetSummaryByCustomerID returns balance summary for a customer
+func (r *LedgerRepository) GetSummaryByCustomerID(ctx context.Context, customerID int) (*models.LedgerSummary, error) {
+	summaries, err := r.querySummaries(ctx,
+		ledgerSummarySelect+` WHERE le.customer_id = $1 `+ledgerSummaryGroupBy,
+		customerID)
+	if err != nil || len(summaries) == 0 {
+		return nil, err // No entries for this customer
 	}
-	defer rows.Close()
+	return &summaries[0], nil
+}
 
-	var summaries []models.LedgerSummary
-	for rows.Next() {
-		var s models.LedgerSummary
-		err := rows.Scan(
-			&s.CustomerPhone, &s.CustomerName, &s.CustomerSO,
-			&s.TotalDebit, &s.TotalCredit, &s.CurrentBalance, &s.EntryCount,
-		)
-		if err != nil {
-			return nil, err
-		}
-		summaries = append(summaries, s)
+// GetSummaryByCustomerIDBefore is GetSummaryByCustomerID counting only entries created before the given time
+func (r *LedgerRepository) GetSummaryByCustomerIDBefore(ctx context.Context, customerID int, before time.Time) (*models.LedgerSummary, error) {
+	summaries, err := r.querySummaries(ctx,
+		ledgerSummarySelect+` WHERE le.customer_id = $1 AND le.created_at < $2 `+ledgerSummaryGroupBy,
+		customerID, before)
+	if err != nil || len(summaries) == 0 {
+		return nil, err
 	}
+	return &summaries[0], nil
+}
 
-	return summaries, nil
+// GetAllCustomerBalances returns balance summaries for all customers
+func (r *LedgerRepository) GetAllCustomerBalances(ctx context.Context) ([]models.LedgerSummary, error) {
+	return r.querySummaries(ctx, ledgerSummarySelect+` `+ledgerSummaryGroupBy+` ORDER BY current_balance DESC`)
 }
 
 // GetDebtors returns customers with positive balance (they owe money)
 func (r *LedgerRepository) GetDebtors(ctx context.Context) ([]models.LedgerSummary, error) {
-	query := `
-		SELECT
-			customer_phone,
-			MAX(customer_name) as customer_name,
-			COALESCE(MAX(customer_so), '') as customer_so,
-			COALESCE(SUM(debit), 0) as total_debit,
-			COALESCE(SUM(credit), 0) as total_credit,
-			COALESCE(SUM(debit) - SUM(credit), 0) as current_balance,
-			COUNT(*) as entry_count
-		FROM ledger_entries
-		GROUP BY customer_phone
-		HAVING SUM(debit) - SUM(credit) > 0
-		ORDER BY current_balance DESC
-	`
+	return r.querySummaries(ctx, ledgerSummarySelect+` `+ledgerSummaryGroupBy+`
+		HAVING SUM(le.debit) - SUM(le.credit) > 0
+		ORDER BY current_balance DESC`)
+}
 
-	rows, err := r.DB.Query(ctx, query)
+// querySummaries runs a ledgerSummarySelect query and scans the rows
+func (r *LedgerRepository) querySummaries(ctx context.Context, query string, args ...interface{}) ([]models.LedgerSummary, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -337,7 +394,7 @@ func (r *LedgerRepository) GetDebtors(ctx context.Context) ([]models.LedgerSumma
 	for rows.Next() {
 		var s models.LedgerSummary
 		err := rows.Scan(
-			&s.CustomerPhone, &s.CustomerName, &s.CustomerSO,
+			&s.CustomerID, &s.CustomerPhone, &s.CustomerName, &s.CustomerSO,
 			&s.TotalDebit, &s.TotalCredit, &s.CurrentBalance, &s.EntryCount,
 		)
 		if err != nil {
@@ -346,7 +403,7 @@ func (r *LedgerRepository) GetDebtors(ctx context.Context) ([]models.LedgerSumma
 		summaries = append(summaries, s)
 	}
 
-	return summaries, nil
+	return summaries, rows.Err()
 }
 
 // CountByType returns count of entries by type
@@ -394,7 +451,7 @@ func (r *LedgerRepository) GetTotalsByType(ctx context.Context) (map[models.Ledg
 func (r *LedgerRepository) GetTotalCredit(ctx context.Context, customerPhone string) (float64, error) {
 	var total float64
 	err := r.DB.QueryRow(ctx,
-		"SELECT COALESCE(SUM(credit), 0) FROM ledger_entries WHERE customer_phone = $1",
+		"SELECT COALESCE(SUM(credit), 0) FROM ledger_entries WHERE "+customerByPhone,
 		customerPhone).Scan(&total)
 	return total, err
 }
@@ -404,19 +461,19 @@ func (r *LedgerRepository) GetTotalCreditByFamilyMember(ctx context.Context, cus
 	var total float64
 	err := r.DB.QueryRow(ctx,
 		`SELECT COALESCE(SUM(credit), 0) FROM ledger_entries
-		 WHERE customer_phone = $1
+		 WHERE `+customerByPhone+`
 		 AND (family_member_name = $2 OR (family_member_name IS NULL AND $2 = '') OR (family_member_name = '' AND $2 = ''))`,
 		customerPhone, familyMemberName).Scan(&total)
 	return total, err
 }
 
-// GetAllTotalCredits returns total credits for all customers (bulk query)
+// GetAllTotalCredits returns total credits for all customers keyed by current phone (bulk query)
 func (r *LedgerRepository) GetAllTotalCredits(ctx context.Context) (map[string]float64, error) {
 	query := `
-		SELECT customer_phone, COALESCE(SUM(credit), 0) as total_credit
-		FROM ledger_entries
-		GROUP BY customer_phone
-	`
+		SELECT COALESCE(MAX(c.phone), MAX(le.customer_phone)), COALESCE(SUM(le.credit), 0) as total_credit
+		FROM ledger_entries le
+		LEFT JOIN customers c ON c.id = le.customer_id
+		` + ledgerSummaryGroupBy
 
 	rows, err := r.DB.Query(ctx, query)
 	if err != nil {
@@ -437,14 +494,16 @@ func (r *LedgerRepository) GetAllTotalCredits(ctx context.Context) (map[string]f
 	return result, nil
 }
 
-// GetAllPaymentHistory returns payment history for all customers (bulk query)
+// GetAllPaymentHistory returns payment history for all customers keyed by current phone (bulk query)
 func (r *LedgerRepository) GetAllPaymentHistory(ctx context.Context) (map[string][]PaymentHistoryItem, error) {
 	query := `
-		SELECT customer_phone, id, credit, entry_type, COALESCE(description, ''), COALESCE(notes, ''),
-		       family_member_id, COALESCE(family_member_name, ''), created_at
-		FROM ledger_entries
-		WHERE credit > 0
-		ORDER BY created_at DESC
+		SELECT COALESCE(c.phone, le.customer_phone), le.id, le.credit, le.entry_type,
+		       COALESCE(le.description, ''), COALESCE(le.notes, ''),
+		       le.family_member_id, COALESCE(le.family_member_name, ''), le.created_at
+		FROM ledger_entries le
+		LEFT JOIN customers c ON c.id = le.customer_id
+		WHERE le.credit > 0
+		ORDER BY le.created_at DESC
 	`
 
 	rows, err := r.DB.Query(ctx, query)
@@ -492,7 +551,7 @@ func (r *LedgerRepository) GetCreditsByFamilyMember(ctx context.Context, custome
 		SELECT family_member_id, COALESCE(family_member_name, '') as family_member_name,
 		       COALESCE(SUM(credit), 0) as total_credit
 		FROM ledger_entries
-		WHERE customer_phone = $1 AND credit > 0
+		WHERE `+customerByPhone+` AND credit > 0
 		GROUP BY family_member_id, family_member_name
 		ORDER BY total_credit DESC
 	`
@@ -515,18 +574,6 @@ func (r *LedgerRepository) GetCreditsByFamilyMember(ctx context.Context, custome
 	return results, nil
 }
 
-// UpdateCustomerPhone updates the phone number in all ledger entries for a customer
-// Used when customer phone changes or when customers are merged
-func (r *LedgerRepository) UpdateCustomerPhone(ctx context.Context, oldPhone, newPhone string) (int64, error) {
-	result, err := r.DB.Exec(ctx,
-		"UPDATE ledger_entries SET customer_phone = $1 WHERE customer_phone = $2",
-		newPhone, oldPhone)
-	if err != nil {
-		return 0, fmt.Errorf("failed to update ledger entries phone: %w", err)
-	}
-	return result.RowsAffected(), nil
-}
-
 // GetPaymentHistory returns recent payments (credits) for a customer
 func (r *LedgerRepository) GetPaymentHistory(ctx context.Context, customerPhone string, limit int) ([]PaymentHistoryItem, error) {
 	if limit <= 0 {
@@ -537,7 +584,7 @@ func (r *LedgerRepository) GetPaymentHistory(ctx context.Context, customerPhone
 		SELECT id, credit, entry_type, COALESCE(description, ''), COALESCE(notes, ''),
 		       family_member_id, COALESCE(family_member_name, ''), created_at
 		FROM ledger_entries
-		WHERE customer_phone = $1 AND credit > 0
+		WHERE `+customerByPhone+` AND credit > 0
 		ORDER BY created_at DESC
 		LIMIT $2
 	`
@@ -560,3 +607,60 @@ func (r *LedgerRepository) GetPaymentHistory(ctx context.Context, customerPhone
 
 	return payments, nil
 }
+
+// GetAccruedRentByScope returns the rent already charged by the accrual job net of its
+// reversals, keyed by accrual scope (the second segment of "rent:{scope}:..." idempotency keys)
+func (r *LedgerRepository) GetAccruedRentByScope(ctx context.Context) (map[string]float64, error) {
+	return r.queryAmountsByKey(ctx, `
+		SELECT split_part(idempotency_key, ':', 2) as scope, COALESCE(SUM(debit - credit), 0)
+		FROM ledger_entries
+		WHERE idempotency_key LIKE 'rent:%'
+		GROUP BY scope
+	`)
+}
+
+// GetAccruedRentByScopeForCustomer is GetAccruedRentByScope for one customer,
+// counting only entries created before the given time when before is set
+func (r *LedgerRepository) GetAccruedRentByScopeForCustomer(ctx context.Context, customerID int, before *time.Time) (map[string]float64, error) {
+	query := `
+		SELECT split_part(idempotency_key, ':', 2) as scope, COALESCE(SUM(debit - credit), 0)
+		FROM ledger_entries
+		WHERE customer_id = $1 AND idempotency_key LIKE 'rent:%'`
+	args := []interface{}{customerID}
+	if before != nil {
+		query += ` AND created_at < $2`
+		args = append(args, *before)
+	}
+	return r.queryAmountsByKey(ctx, query+` GROUP BY scope`, args...)
+}
+
+// GetPostedByKeyPrefix returns the amount (debit or credit) of every entry whose
+// idempotency key starts with prefix, keyed by idempotency key
+func (r *LedgerRepository) GetPostedByKeyPrefix(ctx context.Context, prefix string) (map[string]float64, error) {
+	return r.queryAmountsByKey(ctx, `
+		SELECT idempotency_key, debit + credit
+		FROM ledger_entries
+		WHERE idempotency_key LIKE $1 || '%'
+	`, prefix)
+}
+
+// queryAmountsByKey scans (key, amount) rows into a map
+func (r *LedgerRepository) queryAmountsByKey(ctx context.Context, query string, args ...interface{}) (map[string]float64, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]float64)
+	for rows.Next() {
+		var scope string
+		var total float64
+		if err := rows.Scan(&scope, &total); err != nil {
+			return nil, err
+		}
+		result[scope] = total
+	}
+
+	return result, rows.Err()
+}
@@ -543,6 +543,40 @@ func (r *MetricsRepository) ResolveAlert(ctx context.Context, alertID int) error
 	return err
 }
 
+// GetLastOpenAlert returns the newest unresolved alert for a source and metric, or nil
+func (r *MetricsRepository) GetLastOpenAlert(ctx context.Context, alertType, source, metricName string) (*models.MonitoringAlert, error) {
+	query := `
+		SELECT id, time, severity
+		FROM monitoring_alerts
+		WHERE alert_type = $1 AND source = $2 AND metric_name = $3 AND resolved = FALSE
+		ORDER BY time DESC
+		LIMIT 1`
+
+	a := models.MonitoringAlert{AlertType: alertType, Source: source, MetricName: &metricName}
+	err := r.pool.QueryRow(ctx, query, alertType, source, metricName).Scan(&a.ID, &a.Time, &a.Severity)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ResolveAlertsFor resolves every open alert for a source and metric once it is back in range
+func (r *MetricsRepository) ResolveAlertsFor(ctx context.Context, alertType, source, metricName string) (int64, error) {
+	query := `
+		UPDATE monitoring_alerts
+		SET resolved = TRUE, resolved_at = NOW()
+		WHERE alert_type = $1 AND source = $2 AND metric_name = $3 AND resolved = FALSE`
+
+	tag, err := r.pool.Exec(ctx, query, alertType, source, metricName)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
 // GetAlertSummary returns alert statistics
 func (r *MetricsRepository) GetAlertSummary(ctx context.Context) (*models.AlertSummary, error) {
 	query := `
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PaymentAllocationRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPaymentAllocationRepository(db *pgxpool.Pool) *PaymentAllocationRepository {
+	return &PaymentAllocationRepository{DB: db}
+}
+
+// Replace swaps the allocations of a payment in one transaction
+func (r *PaymentAllocationRepository) Replace(ctx context.Context, paymentID int, allocations []models.PaymentAllocation) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the payment so concurrent re-allocations are applied one after the other
+	if _, err := tx.Exec(ctx, `SELECT id FROM rent_payments WHERE id = $1 FOR UPDATE`, paymentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM payment_allocations WHERE rent_payment_id = $1`, paymentID); err != nil {
+		return err
+	}
+
+	if err := insertAllocations(ctx, tx, paymentID, allocations); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertAllocations writes the allocations of a payment inside tx
+func insertAllocations(ctx context.Context, tx pgx.Tx, paymentID int, allocations []models.PaymentAllocation) error {
+	for _, a := range allocations {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO payment_allocations (rent_payment_id, entry_id, customer_id, thock_number, family_member_id, amount, allocated_by_user_id)
+			 VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, NULLIF($7, 0))`,
+			paymentID, a.EntryID, a.CustomerID, a.ThockNumber, a.FamilyMemberID, a.Amount, a.AllocatedByUserID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByPayment returns the allocations of a payment
+func (r *PaymentAllocationRepository) GetByPayment(ctx context.Context, paymentID int) ([]models.PaymentAllocation, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT pa.id, pa.rent_payment_id, rp.receipt_number, pa.entry_id, COALESCE(pa.customer_id, 0),
+		        pa.thock_number, pa.family_member_id, pa.amount, COALESCE(pa.allocated_by_user_id, 0), pa.created_at
+		 FROM payment_allocations pa
+		 JOIN rent_payments rp ON rp.id = pa.rent_payment_id
+		 WHERE pa.rent_payment_id = $1
+		 ORDER BY pa.id`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allocations := []models.PaymentAllocation{}
+	for rows.Next() {
+		var a models.PaymentAllocation
+		if err := rows.Scan(&a.ID, &a.RentPaymentID, &a.ReceiptNumber, &a.EntryID, &a.CustomerID,
+			&a.ThockNumber, &a.FamilyMemberID, &a.Amount, &a.AllocatedByUserID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+// GetTotalsByEntry returns the amount allocated to each of a customer's entries,
+// ignoring allocations of excludePaymentID (0 = none) and, when before is set,
+// of payments received at or after it
+func (r *PaymentAllocationRepository) GetTotalsByEntry(ctx context.Context, customerID, excludePaymentID int, before *time.Time) (map[int]float64, error) {
+	query := `SELECT pa.entry_id, SUM(pa.amount)
+		 FROM payment_allocations pa
+		 JOIN entries e ON e.id = pa.entry_id
+		 JOIN rent_payments rp ON rp.id = pa.rent_payment_id
+		 WHERE e.customer_id = $1 AND pa.rent_payment_id <> $2`
+	args := []interface{}{customerID, excludePaymentID}
+	if before != nil {
+		query += ` AND rp.payment_date < $3`
+		args = append(args, *before)
+	}
+	rows, err := r.DB.Query(ctx, query+` GROUP BY pa.entry_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[int]float64)
+	for rows.Next() {
+		var entryID int
+		var total float64
+		if err := rows.Scan(&entryID, &total); err != nil {
+			return nil, err
+		}
+		totals[entryID] = total
+	}
+	return totals, rows.Err()
+}
@@ -214,6 +214,12 @@ func (r *PendingSettingChangeRepository) ExpireOld(ctx context.Context) error {
 	return err
 }
 
+// Expire marks one pending change as expired, when its approval runs out of time
+func (r *PendingSettingChangeRepository) Expire(ctx context.Context, id int) error {
+	_, err := r.DB.Exec(ctx, `UPDATE pending_setting_changes SET status = 'expired' WHERE id = $1 AND status = 'pending'`, id)
+	return err
+}
+
 // IsProtectedSetting checks if a setting requires dual admin approval
 func (r *PendingSettingChangeRepository) IsProtectedSetting(ctx context.Context, settingKey string) (bool, error) {
 	var count int
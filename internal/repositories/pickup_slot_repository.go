@@ -0,0 +1,327 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrPickupSlotNotFound is returned for an unknown pickup slot
+	ErrPickupSlotNotFound = errors.New("pickup slot not found")
+	// ErrPickupSlotExists is returned when a gate already has a slot starting at that time
+	ErrPickupSlotExists = errors.New("a pickup slot already starts at that time on that gate")
+	// ErrPickupSlotClosed is returned when booking a slot that is over or closed for bookings
+	ErrPickupSlotClosed = errors.New("pickup slot is closed for bookings")
+	// ErrPickupSlotFull is returned when a booking would go over a slot's capacity
+	ErrPickupSlotFull = errors.New("pickup slot is full")
+)
+
+type PickupSlotRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPickupSlotRepository(db *pgxpool.Pool) *PickupSlotRepository {
+	return &PickupSlotRepository{DB: db}
+}
+
+// Gate passes holding a place in a slot: open ones, and completed ones that used it
+const slotHolderStatuses = `('pending', 'approved', 'partially_completed', 'completed')`
+
+// pickupSlotColumns selects the slot ps booked by gate pass gp in the map listings
+const pickupSlotColumns = `ps.id, ps.gate, ps.slot_date::text, to_char(ps.start_time, 'HH24:MI'), to_char(ps.end_time, 'HH24:MI')`
+
+// pickupSlotRef holds the pickupSlotColumns of one row
+type pickupSlotRef struct {
+	id                     *int
+	gate, date, start, end *string
+}
+
+func (p *pickupSlotRef) set(gatePass map[string]interface{}) {
+	if p.id == nil {
+		return
+	}
+	gatePass["pickup_slot_id"] = *p.id
+	gatePass["pickup_slot"] = map[string]interface{}{
+		"id":         *p.id,
+		"gate":       *p.gate,
+		"slot_date":  *p.date,
+		"start_time": *p.start,
+		"end_time":   *p.end,
+	}
+}
+
+// pickupSlotAvailable is true for slots that take bookings: active, not over and not full
+const pickupSlotAvailable = `(s.is_active AND s.slot_date + s.end_time > CURRENT_TIMESTAMP
+	        AND (s.capacity_bags = 0 OR b.bags < s.capacity_bags)
+	        AND (s.capacity_trucks = 0 OR b.trucks < s.capacity_trucks))`
+
+const pickupSlotSelect = `
+	SELECT s.id, s.slot_date::text, s.gate, to_char(s.start_time, 'HH24:MI'), to_char(s.end_time, 'HH24:MI'),
+	       s.capacity_bags, s.capacity_trucks, s.is_active, b.bags, b.trucks,
+	       ` + pickupSlotAvailable + `,
+	       s.created_by_user_id, s.created_at, s.updated_at
+	FROM pickup_slots s
+	CROSS JOIN LATERAL (
+	    SELECT COALESCE(SUM(COALESCE(gp.approved_quantity, gp.requested_quantity)), 0)::int AS bags,
+	           COUNT(gp.id)::int AS trucks
+	    FROM gate_passes gp
+	    WHERE gp.pickup_slot_id = s.id AND gp.status IN ` + slotHolderStatuses + `
+	) b`
+
+func scanPickupSlot(row pgx.Row) (*models.PickupSlot, error) {
+	s := &models.PickupSlot{}
+	err := row.Scan(&s.ID, &s.SlotDate, &s.Gate, &s.StartTime, &s.EndTime,
+		&s.CapacityBags, &s.CapacityTrucks, &s.IsActive, &s.BookedBags, &s.BookedTrucks,
+		&s.Available,
+		&s.CreatedByUserID, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// Create opens a slot
+func (r *PickupSlotRepository) Create(ctx context.Context, s *models.PickupSlot) error {
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO pickup_slots (slot_date, gate, start_time, end_time, capacity_bags, capacity_trucks, created_by_user_id)
+		VALUES ($1::date, $2, $3::time, $4::time, $5, $6, $7)
+		RETURNING id
+	`, s.SlotDate, s.Gate, s.StartTime, s.EndTime, s.CapacityBags, s.CapacityTrucks, s.CreatedByUserID,
+	).Scan(&s.ID)
+	if isUniqueViolation(err) {
+		return ErrPickupSlotExists
+	}
+	return err
+}
+
+// CreateMany opens slots, skipping any that already exist, and returns how many were opened
+func (r *PickupSlotRepository) CreateMany(ctx context.Context, slots []*models.PickupSlot) (int, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	created := 0
+	for _, s := range slots {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO pickup_slots (slot_date, gate, start_time, end_time, capacity_bags, capacity_trucks, created_by_user_id)
+			VALUES ($1::date, $2, $3::time, $4::time, $5, $6, $7)
+			ON CONFLICT (slot_date, gate, start_time) DO NOTHING
+		`, s.SlotDate, s.Gate, s.StartTime, s.EndTime, s.CapacityBags, s.CapacityTrucks, s.CreatedByUserID)
+		if err != nil {
+			return 0, err
+		}
+		created += int(tag.RowsAffected())
+	}
+	return created, tx.Commit(ctx)
+}
+
+// Get returns a slot with its bookings
+func (r *PickupSlotRepository) Get(ctx context.Context, id int) (*models.PickupSlot, error) {
+	s, err := scanPickupSlot(r.DB.QueryRow(ctx, pickupSlotSelect+` WHERE s.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPickupSlotNotFound
+	}
+	return s, err
+}
+
+// List returns the slots of the days from..to (YYYY-MM-DD) in time order. gate "" matches
+// all gates; availableOnly leaves out slots that are over, closed or full.
+func (r *PickupSlotRepository) List(ctx context.Context, from, to, gate string, availableOnly bool) ([]*models.PickupSlot, error) {
+	rows, err := r.DB.Query(ctx, pickupSlotSelect+`
+		WHERE s.slot_date BETWEEN $1::date AND $2::date
+		  AND ($3 = '' OR s.gate = $3)
+		  AND (NOT $4::bool OR `+pickupSlotAvailable+`)
+		ORDER BY s.slot_date, s.start_time, s.gate`, from, to, gate, availableOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []*models.PickupSlot
+	for rows.Next() {
+		s, err := scanPickupSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, s)
+	}
+	return slots, rows.Err()
+}
+
+// Update changes a slot's capacity or whether it takes bookings. Lowering the capacity
+// below what is booked leaves existing bookings alone and stops new ones.
+func (r *PickupSlotRepository) Update(ctx context.Context, id int, req *models.UpdatePickupSlotRequest) error {
+	tag, err := r.DB.Exec(ctx, `
+		UPDATE pickup_slots
+		SET capacity_bags = COALESCE($1, capacity_bags),
+		    capacity_trucks = COALESCE($2, capacity_trucks),
+		    is_active = COALESCE($3, is_active),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`, req.CapacityBags, req.CapacityTrucks, req.IsActive, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPickupSlotNotFound
+	}
+	return nil
+}
+
+// Book puts an open gate pass into a slot for the given number of bags, and moves its
+// expiry out to the end of the slot so it does not lapse while waiting for it. The slot
+// row is locked so concurrent bookings cannot overfill it.
+func (r *PickupSlotRepository) Book(ctx context.Context, gatePassID, slotID, bags int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := bookSlot(ctx, tx, gatePassID, slotID, bags); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func bookSlot(ctx context.Context, tx pgx.Tx, gatePassID, slotID, bags int) error {
+	var capacityBags, capacityTrucks int
+	var open bool
+	err := tx.QueryRow(ctx, `
+		SELECT capacity_bags, capacity_trucks, is_active AND slot_date + end_time > CURRENT_TIMESTAMP
+		FROM pickup_slots WHERE id = $1
+		FOR UPDATE
+	`, slotID).Scan(&capacityBags, &capacityTrucks, &open)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPickupSlotNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !open {
+		return ErrPickupSlotClosed
+	}
+
+	var bookedBags, bookedTrucks int
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(COALESCE(approved_quantity, requested_quantity)), 0)::int, COUNT(*)::int
+		FROM gate_passes
+		WHERE pickup_slot_id = $1 AND id <> $2 AND status IN `+slotHolderStatuses,
+		slotID, gatePassID).Scan(&bookedBags, &bookedTrucks)
+	if err != nil {
+		return err
+	}
+	if (capacityBags > 0 && bookedBags+bags > capacityBags) || (capacityTrucks > 0 && bookedTrucks+1 > capacityTrucks) {
+		return ErrPickupSlotFull
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE gate_passes
+		SET slot_booked_at = CASE WHEN pickup_slot_id IS DISTINCT FROM $1 THEN CURRENT_TIMESTAMP ELSE slot_booked_at END,
+		    pickup_slot_id = $1,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status IN ('pending', 'approved', 'partially_completed')
+	`, slotID, gatePassID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrGatePassStatusChanged
+	}
+	return extendToSlot(ctx, tx, gatePassID)
+}
+
+// Clear takes an open gate pass out of its slot
+func (r *PickupSlotRepository) Clear(ctx context.Context, gatePassID int) error {
+	tag, err := r.DB.Exec(ctx, `
+		UPDATE gate_passes
+		SET pickup_slot_id = NULL, slot_booked_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('pending', 'approved', 'partially_completed')
+	`, gatePassID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrGatePassStatusChanged
+	}
+	return nil
+}
+
+// ExtendToSlot moves a gate pass's expiry out to the end of its slot if that is later,
+// for use after approval has set a fresh pickup window
+func (r *PickupSlotRepository) ExtendToSlot(ctx context.Context, gatePassID int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := extendToSlot(ctx, tx, gatePassID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func extendToSlot(ctx context.Context, tx pgx.Tx, gatePassID int) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE gate_passes gp
+		SET expires_at = GREATEST(gp.expires_at, ps.slot_date + ps.end_time),
+		    approval_expires_at = CASE WHEN gp.approval_expires_at IS NULL THEN NULL
+		                               ELSE GREATEST(gp.approval_expires_at, ps.slot_date + ps.end_time) END
+		FROM pickup_slots ps
+		WHERE gp.id = $1 AND ps.id = gp.pickup_slot_id
+	`, gatePassID)
+	return err
+}
+
+// Queue returns the open gate passes booked on a day, in queue order per gate: by slot,
+// then by when they were booked. gate "" returns every gate.
+func (r *PickupSlotRepository) Queue(ctx context.Context, date, gate string) ([]*models.PickupQueue, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT ps.gate, ps.id, to_char(ps.start_time, 'HH24:MI'), to_char(ps.end_time, 'HH24:MI'),
+		       CASE WHEN ps.slot_date + ps.end_time < CURRENT_TIMESTAMP THEN 'late'
+		            WHEN ps.slot_date + ps.start_time <= CURRENT_TIMESTAMP THEN 'now'
+		            ELSE 'upcoming' END,
+		       gp.id, gp.thock_number, c.name, gp.status,
+		       COALESCE(gp.approved_quantity, gp.requested_quantity), gp.total_picked_up,
+		       EXISTS (
+		           SELECT 1 FROM vehicle_visit_links l
+		           JOIN vehicle_visits v ON v.id = l.visit_id
+		           WHERE l.gate_pass_id = gp.id AND v.status = 'inside'
+		       )
+		FROM gate_passes gp
+		JOIN pickup_slots ps ON ps.id = gp.pickup_slot_id
+		JOIN customers c ON c.id = gp.customer_id
+		WHERE ps.slot_date = $1::date
+		  AND ($2 = '' OR ps.gate = $2)
+		  AND gp.status IN ('pending', 'approved', 'partially_completed')
+		ORDER BY ps.gate, ps.start_time, gp.slot_booked_at, gp.id
+	`, date, gate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queues := []*models.PickupQueue{}
+	var current *models.PickupQueue
+	for rows.Next() {
+		var gateName string
+		e := &models.PickupQueueEntry{}
+		if err := rows.Scan(&gateName, &e.SlotID, &e.StartTime, &e.EndTime, &e.SlotState,
+			&e.GatePassID, &e.ThockNumber, &e.CustomerName, &e.Status,
+			&e.Quantity, &e.PickedUp, &e.Arrived); err != nil {
+			return nil, err
+		}
+		if current == nil || current.Gate != gateName {
+			current = &models.PickupQueue{Gate: gateName, Date: date, Entries: []*models.PickupQueueEntry{}}
+			queues = append(queues, current)
+		}
+		e.Remaining = e.Quantity - e.PickedUp
+		e.Position = len(current.Entries) + 1
+		current.Entries = append(current.Entries, e)
+	}
+	return queues, rows.Err()
+}
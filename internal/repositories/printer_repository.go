@@ -0,0 +1,254 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrPrinterNotFound is returned when a printer does not exist or none is set up for a counter
+	ErrPrinterNotFound = errors.New("printer not found")
+	// ErrPrintJobNotFound is returned when a print job does not exist or is not in a state the action allows
+	ErrPrintJobNotFound = errors.New("print job not found")
+)
+
+type PrinterRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPrinterRepository(db *pgxpool.Pool) *PrinterRepository {
+	return &PrinterRepository{DB: db}
+}
+
+const printerSelect = `
+	SELECT id, name, counter, kind, protocol, address, label_width_mm, label_height_mm, label_columns,
+	       dpi, code_type, is_active, created_at, updated_at
+	FROM printers`
+
+func scanPrinter(row pgx.Row) (*models.Printer, error) {
+	p := &models.Printer{}
+	err := row.Scan(&p.ID, &p.Name, &p.Counter, &p.Kind, &p.Protocol, &p.Address, &p.LabelWidthMM, &p.LabelHeightMM,
+		&p.LabelColumns, &p.DPI, &p.CodeType, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPrinterNotFound
+	}
+	return p, err
+}
+
+// ListPrinters returns all printers by counter
+func (r *PrinterRepository) ListPrinters(ctx context.Context) ([]*models.Printer, error) {
+	rows, err := r.DB.Query(ctx, printerSelect+` ORDER BY counter, kind, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var printers []*models.Printer
+	for rows.Next() {
+		p, err := scanPrinter(rows)
+		if err != nil {
+			return nil, err
+		}
+		printers = append(printers, p)
+	}
+	return printers, rows.Err()
+}
+
+// GetPrinter returns one printer
+func (r *PrinterRepository) GetPrinter(ctx context.Context, id int) (*models.Printer, error) {
+	return scanPrinter(r.DB.QueryRow(ctx, printerSelect+` WHERE id = $1`, id))
+}
+
+// FindPrinter returns the active printer of a kind at a counter, or the default one
+func (r *PrinterRepository) FindPrinter(ctx context.Context, kind, counter string) (*models.Printer, error) {
+	return scanPrinter(r.DB.QueryRow(ctx, printerSelect+`
+		WHERE kind = $1 AND is_active AND (counter = $2 OR counter = '')
+		ORDER BY (counter = $2) DESC, id
+		LIMIT 1`, kind, counter))
+}
+
+// CreatePrinter adds a printer
+func (r *PrinterRepository) CreatePrinter(ctx context.Context, p *models.Printer) error {
+	return r.DB.QueryRow(ctx,
+		`INSERT INTO printers (name, counter, kind, protocol, address, label_width_mm, label_height_mm, label_columns, dpi, code_type, is_active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id, created_at, updated_at`,
+		p.Name, p.Counter, p.Kind, p.Protocol, p.Address, p.LabelWidthMM, p.LabelHeightMM, p.LabelColumns, p.DPI, p.CodeType, p.IsActive,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// UpdatePrinter saves a printer's settings
+func (r *PrinterRepository) UpdatePrinter(ctx context.Context, p *models.Printer) error {
+	err := r.DB.QueryRow(ctx,
+		`UPDATE printers SET name = $2, counter = $3, kind = $4, protocol = $5, address = $6, label_width_mm = $7,
+		     label_height_mm = $8, label_columns = $9, dpi = $10, code_type = $11, is_active = $12, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING created_at, updated_at`,
+		p.ID, p.Name, p.Counter, p.Kind, p.Protocol, p.Address, p.LabelWidthMM, p.LabelHeightMM, p.LabelColumns, p.DPI, p.CodeType, p.IsActive,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPrinterNotFound
+	}
+	return err
+}
+
+// DeletePrinter removes a printer and its jobs
+func (r *PrinterRepository) DeletePrinter(ctx context.Context, id int) error {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM printers WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPrinterNotFound
+	}
+	return nil
+}
+
+const printJobSelect = `
+	SELECT j.id, j.printer_id, p.name, j.job_type, j.reference, j.copies, j.payload, j.status, j.attempts,
+	       j.max_attempts, j.last_error, j.next_attempt_at, j.created_by_user_id, j.created_at, j.updated_at, j.printed_at
+	FROM print_jobs j
+	JOIN printers p ON p.id = j.printer_id`
+
+func scanPrintJob(row pgx.Row) (*models.PrintJob, error) {
+	j := &models.PrintJob{}
+	err := row.Scan(&j.ID, &j.PrinterID, &j.PrinterName, &j.JobType, &j.Reference, &j.Copies, &j.Payload, &j.Status, &j.Attempts,
+		&j.MaxAttempts, &j.LastError, &j.NextAttemptAt, &j.CreatedByUserID, &j.CreatedAt, &j.UpdatedAt, &j.PrintedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPrintJobNotFound
+	}
+	return j, err
+}
+
+func collectPrintJobs(rows pgx.Rows) ([]*models.PrintJob, error) {
+	defer rows.Close()
+	var jobs []*models.PrintJob
+	for rows.Next() {
+		j, err := scanPrintJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// CreateJob queues a job. A job created as printing is sent right away by its creator.
+func (r *PrinterRepository) CreateJob(ctx context.Context, j *models.PrintJob) error {
+	return r.DB.QueryRow(ctx,
+		`INSERT INTO print_jobs (printer_id, job_type, reference, copies, payload, status, attempts, created_by_user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id, max_attempts, next_attempt_at, created_at, updated_at`,
+		j.PrinterID, j.JobType, j.Reference, j.Copies, j.Payload, j.Status, j.Attempts, j.CreatedByUserID,
+	).Scan(&j.ID, &j.MaxAttempts, &j.NextAttemptAt, &j.CreatedAt, &j.UpdatedAt)
+}
+
+// GetJob returns one job
+func (r *PrinterRepository) GetJob(ctx context.Context, id int) (*models.PrintJob, error) {
+	return scanPrintJob(r.DB.QueryRow(ctx, printJobSelect+` WHERE j.id = $1`, id))
+}
+
+// ListJobs returns recent jobs, newest first; status "" matches all
+func (r *PrinterRepository) ListJobs(ctx context.Context, status string, limit int) ([]*models.PrintJob, error) {
+	rows, err := r.DB.Query(ctx, printJobSelect+`
+		WHERE ($1 = '' OR j.status = $1)
+		ORDER BY j.id DESC
+		LIMIT $2`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectPrintJobs(rows)
+}
+
+// ClaimDueJobs marks up to limit queued jobs that are due as printing and returns them.
+// Jobs claimed by another server are skipped.
+func (r *PrinterRepository) ClaimDueJobs(ctx context.Context, limit int) ([]*models.PrintJob, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM print_jobs
+			WHERE status = 'queued' AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE print_jobs j SET status = 'printing', attempts = j.attempts + 1, updated_at = NOW()
+			FROM due WHERE j.id = due.id
+			RETURNING j.*
+		)
+		SELECT j.id, j.printer_id, p.name, j.job_type, j.reference, j.copies, j.payload, j.status, j.attempts,
+		       j.max_attempts, j.last_error, j.next_attempt_at, j.created_by_user_id, j.created_at, j.updated_at, j.printed_at
+		FROM claimed j
+		JOIN printers p ON p.id = j.printer_id
+		ORDER BY j.id`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectPrintJobs(rows)
+}
+
+// RequeueStale puts back jobs left printing longer than age, e.g. by a restart mid-send
+func (r *PrinterRepository) RequeueStale(ctx context.Context, age time.Duration) (int64, error) {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE print_jobs SET status = 'queued', updated_at = NOW()
+		 WHERE status = 'printing' AND updated_at < NOW() - make_interval(secs => $1)`,
+		age.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MarkPrinted records a successful send
+func (r *PrinterRepository) MarkPrinted(ctx context.Context, j *models.PrintJob) error {
+	return r.DB.QueryRow(ctx,
+		`UPDATE print_jobs SET status = 'printed', last_error = '', printed_at = NOW(), updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING status, printed_at, updated_at`, j.ID,
+	).Scan(&j.Status, &j.PrintedAt, &j.UpdatedAt)
+}
+
+// MarkAttemptFailed records a failed send: the job is retried at retryAt, or fails for
+// good once it has used its attempts
+func (r *PrinterRepository) MarkAttemptFailed(ctx context.Context, j *models.PrintJob, sendErr string, retryAt time.Time) error {
+	return r.DB.QueryRow(ctx,
+		`UPDATE print_jobs SET
+		     status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'queued' END,
+		     last_error = $2, next_attempt_at = $3, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING status, last_error, next_attempt_at, updated_at`, j.ID, sendErr, retryAt,
+	).Scan(&j.Status, &j.LastError, &j.NextAttemptAt, &j.UpdatedAt)
+}
+
+// Retry queues a failed or cancelled job again with fresh attempts
+func (r *PrinterRepository) Retry(ctx context.Context, id int) error {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE print_jobs SET status = 'queued', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		 WHERE id = $1 AND status IN ('queued', 'failed', 'cancelled')`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPrintJobNotFound
+	}
+	return nil
+}
+
+// Cancel stops a queued or failed job from printing
+func (r *PrinterRepository) Cancel(ctx context.Context, id int) error {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE print_jobs SET status = 'cancelled', updated_at = NOW()
+		 WHERE id = $1 AND status IN ('queued', 'failed')`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPrintJobNotFound
+	}
+	return nil
+}
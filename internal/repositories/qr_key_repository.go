@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"cold-backend/internal/labelcode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QRKeyRepository stores the keys that sign label and gate pass QR codes
+type QRKeyRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewQRKeyRepository(db *pgxpool.Pool) *QRKeyRepository {
+	return &QRKeyRepository{DB: db}
+}
+
+func newQRSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// LoadKeys returns all signing keys, creating the first one if there is none
+func (r *QRKeyRepository) LoadKeys(ctx context.Context) (labelcode.Keys, error) {
+	secret, err := newQRSecret()
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.DB.Exec(ctx,
+		`INSERT INTO qr_signing_keys (secret)
+		 SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM qr_signing_keys)`, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(ctx, `SELECT id, secret FROM qr_signing_keys`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := labelcode.Keys{}
+	for rows.Next() {
+		var id int
+		var secretHex string
+		if err := rows.Scan(&id, &secretHex); err != nil {
+			return nil, err
+		}
+		key, err := hex.DecodeString(secretHex)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	return keys, rows.Err()
+}
+
+// AddKey creates a new key that signs codes from now on
+func (r *QRKeyRepository) AddKey(ctx context.Context, userID int) (int, error) {
+	secret, err := newQRSecret()
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = r.DB.QueryRow(ctx,
+		`INSERT INTO qr_signing_keys (secret, created_by_user_id) VALUES ($1, NULLIF($2::int, 0)) RETURNING id`,
+		secret, userID).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrInspectionNotFound is returned when a quality inspection does not exist
+var ErrInspectionNotFound = errors.New("quality inspection not found")
+
+type QualityInspectionRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewQualityInspectionRepository(db *pgxpool.Pool) *QualityInspectionRepository {
+	return &QualityInspectionRepository{DB: db}
+}
+
+// inspectionSelect reads inspections with the owner's name
+const inspectionSelect = `
+	SELECT qi.id, qi.room_entry_id, qi.thock_number, qi.room_no, qi.floor, qi.gatar_no,
+	       qi.inspected_on, qi.inspector_user_id, COALESCE(NULLIF(qi.inspector_name, ''), u.name, ''),
+	       qi.sprouting_pct::float8, qi.rot_pct::float8, qi.sample_bags, qi.sample_weight_kg::float8,
+	       qi.condition, qi.notes, qi.owner_notified_at, qi.owner_notified_by_user_id, qi.created_at,
+	       COALESCE(e.name, '')
+	FROM quality_inspections qi
+	JOIN room_entries re ON re.id = qi.room_entry_id
+	LEFT JOIN entries e ON e.id = re.entry_id
+	LEFT JOIN users u ON u.id = qi.inspector_user_id`
+
+func scanInspection(row pgx.Row) (*models.QualityInspection, error) {
+	i := &models.QualityInspection{}
+	err := row.Scan(&i.ID, &i.RoomEntryID, &i.ThockNumber, &i.RoomNo, &i.Floor, &i.GatarNo,
+		&i.InspectedOn, &i.InspectorUserID, &i.InspectorName,
+		&i.SproutingPct, &i.RotPct, &i.SampleBags, &i.SampleWeightKg,
+		&i.Condition, &i.Notes, &i.OwnerNotifiedAt, &i.OwnerNotifiedByUserID, &i.CreatedAt,
+		&i.CustomerName)
+	if err != nil {
+		return nil, err
+	}
+	if i.SampleBags > 0 {
+		i.AvgBagWeightKg = i.SampleWeightKg / float64(i.SampleBags)
+	}
+	return i, nil
+}
+
+// Create saves an inspection and its photos in one transaction
+func (r *QualityInspectionRepository) Create(ctx context.Context, i *models.QualityInspection, photos []*models.RoomEntryMedia) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO quality_inspections (room_entry_id, thock_number, room_no, floor, gatar_no, inspected_on,
+		                                  inspector_user_id, inspector_name, sprouting_pct, rot_pct,
+		                                  sample_bags, sample_weight_kg, condition, notes)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		 RETURNING id, created_at`,
+		i.RoomEntryID, i.ThockNumber, i.RoomNo, i.Floor, i.GatarNo, i.InspectedOn,
+		i.InspectorUserID, i.InspectorName, i.SproutingPct, i.RotPct,
+		i.SampleBags, i.SampleWeightKg, i.Condition, i.Notes,
+	).Scan(&i.ID, &i.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range photos {
+		p.InspectionID = &i.ID
+		err := tx.QueryRow(ctx,
+			`INSERT INTO room_entry_media (room_entry_id, thock_number, media_type, file_path, file_name,
+			                               file_type, file_size, uploaded_by_user_id, inspection_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			 RETURNING id, created_at`,
+			p.RoomEntryID, p.ThockNumber, p.MediaType, p.FilePath, p.FileName,
+			p.FileType, p.FileSize, p.UploadedByUserID, p.InspectionID,
+		).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Get returns one inspection
+func (r *QualityInspectionRepository) Get(ctx context.Context, id int) (*models.QualityInspection, error) {
+	i, err := scanInspection(r.DB.QueryRow(ctx, inspectionSelect+` WHERE qi.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInspectionNotFound
+	}
+	return i, err
+}
+
+// List returns inspections, newest first; thockNumber and condition "" match all
+func (r *QualityInspectionRepository) List(ctx context.Context, thockNumber, condition string, limit int) ([]*models.QualityInspection, error) {
+	rows, err := r.DB.Query(ctx, inspectionSelect+`
+		WHERE ($1 = '' OR qi.thock_number = $1)
+		  AND ($2 = '' OR qi.condition = $2)
+		ORDER BY qi.inspected_on DESC, qi.id DESC
+		LIMIT $3`, thockNumber, condition, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inspections []*models.QualityInspection
+	for rows.Next() {
+		i, err := scanInspection(rows)
+		if err != nil {
+			return nil, err
+		}
+		inspections = append(inspections, i)
+	}
+	return inspections, rows.Err()
+}
+
+// MarkOwnerNotified records that the owner was told about a deteriorating inspection
+func (r *QualityInspectionRepository) MarkOwnerNotified(ctx context.Context, id, userID int) error {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE quality_inspections SET owner_notified_at = NOW(), owner_notified_by_user_id = $2 WHERE id = $1`,
+		id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInspectionNotFound
+	}
+	return nil
+}
+
+// thockStockQuery is the bags each thock still has in store, leaving out deleted entries
+const thockStockQuery = `
+	SELECT m.thock_number, SUM(m.quantity) AS bags,
+	       string_agg(DISTINCT m.room_no || '/' || m.floor, ', ') AS locations
+	FROM gatar_stock_movements m
+	JOIN room_entries re ON re.id = m.room_entry_id
+	LEFT JOIN entries e ON e.id = re.entry_id
+	WHERE COALESCE(e.status, 'active') != 'deleted'
+	GROUP BY m.thock_number
+	HAVING SUM(m.quantity) > 0`
+
+// ListAlerts returns thocks still in store whose latest inspection found them deteriorating
+func (r *QualityInspectionRepository) ListAlerts(ctx context.Context) ([]*models.QualityAlert, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH stock AS (`+thockStockQuery+`),
+		latest AS (
+			SELECT DISTINCT ON (thock_number) id, thock_number
+			FROM quality_inspections
+			ORDER BY thock_number, inspected_on DESC, id DESC
+		)
+		SELECT qi.id, qi.room_entry_id, qi.thock_number, qi.room_no, qi.floor, qi.gatar_no,
+		       qi.inspected_on, qi.inspector_user_id, COALESCE(NULLIF(qi.inspector_name, ''), u.name, ''),
+		       qi.sprouting_pct::float8, qi.rot_pct::float8, qi.sample_bags, qi.sample_weight_kg::float8,
+		       qi.condition, qi.notes, qi.owner_notified_at, qi.owner_notified_by_user_id, qi.created_at,
+		       COALESCE(e.name, ''), s.bags, COALESCE(e.phone, ''), COALESCE(e.village, '')
+		FROM latest l
+		JOIN quality_inspections qi ON qi.id = l.id
+		JOIN stock s ON s.thock_number = qi.thock_number
+		JOIN room_entries re ON re.id = qi.room_entry_id
+		LEFT JOIN entries e ON e.id = re.entry_id
+		LEFT JOIN users u ON u.id = qi.inspector_user_id
+		WHERE qi.condition = 'deteriorating'
+		ORDER BY qi.owner_notified_at NULLS FIRST, qi.inspected_on DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*models.QualityAlert
+	for rows.Next() {
+		i := &models.QualityInspection{}
+		a := &models.QualityAlert{Inspection: i}
+		err := rows.Scan(&i.ID, &i.RoomEntryID, &i.ThockNumber, &i.RoomNo, &i.Floor, &i.GatarNo,
+			&i.InspectedOn, &i.InspectorUserID, &i.InspectorName,
+			&i.SproutingPct, &i.RotPct, &i.SampleBags, &i.SampleWeightKg,
+			&i.Condition, &i.Notes, &i.OwnerNotifiedAt, &i.OwnerNotifiedByUserID, &i.CreatedAt,
+			&i.CustomerName, &a.BagsInStock, &a.Phone, &a.Village)
+		if err != nil {
+			return nil, err
+		}
+		if i.SampleBags > 0 {
+			i.AvgBagWeightKg = i.SampleWeightKg / float64(i.SampleBags)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// ListAging returns every thock in store with when it was first loaded and its latest inspection,
+// oldest first
+func (r *QualityInspectionRepository) ListAging(ctx context.Context) ([]*models.StockAgingRow, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH stock AS (`+thockStockQuery+`),
+		stored AS (
+			SELECT thock_number, MIN(created_at) AS stored_since, MIN(entry_id) AS entry_id
+			FROM room_entries
+			GROUP BY thock_number
+		),
+		latest AS (
+			SELECT DISTINCT ON (thock_number) id, thock_number, inspected_on, condition,
+			       rot_pct::float8 AS rot_pct, sprouting_pct::float8 AS sprouting_pct
+			FROM quality_inspections
+			ORDER BY thock_number, inspected_on DESC, id DESC
+		)
+		SELECT s.thock_number, COALESCE(e.name, ''), COALESCE(e.remark, ''), s.locations, s.bags,
+		       st.stored_since, l.id, l.inspected_on, COALESCE(l.condition, ''), l.rot_pct, l.sprouting_pct
+		FROM stock s
+		JOIN stored st ON st.thock_number = s.thock_number
+		LEFT JOIN entries e ON e.id = st.entry_id
+		LEFT JOIN latest l ON l.thock_number = s.thock_number
+		ORDER BY st.stored_since, s.thock_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aging []*models.StockAgingRow
+	for rows.Next() {
+		a := &models.StockAgingRow{}
+		err := rows.Scan(&a.ThockNumber, &a.CustomerName, &a.Variety, &a.Locations, &a.Bags,
+			&a.StoredSince, &a.LastInspectionID, &a.LastInspectedOn, &a.LastCondition, &a.LastRotPct, &a.LastSproutingPct)
+		if err != nil {
+			return nil, err
+		}
+		aging = append(aging, a)
+	}
+	return aging, rows.Err()
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RentAccrualRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRentAccrualRepository(db *pgxpool.Pool) *RentAccrualRepository {
+	return &RentAccrualRepository{DB: db}
+}
+
+// CreateRun records the start of an accrual run
+func (r *RentAccrualRepository) CreateRun(ctx context.Context, run *models.RentAccrualRun) error {
+	return r.DB.QueryRow(ctx,
+		`INSERT INTO rent_accrual_runs (trigger, as_of, status, started_by_user_id)
+		 VALUES ($1, $2, 'running', $3)
+		 RETURNING id, status, started_at`,
+		run.Trigger, run.AsOf, run.StartedByUserID,
+	).Scan(&run.ID, &run.Status, &run.StartedAt)
+}
+
+// CompleteRun stores the totals of a finished run (status completed or failed)
+func (r *RentAccrualRepository) CompleteRun(ctx context.Context, run *models.RentAccrualRun) error {
+	now := time.Now()
+	run.CompletedAt = &now
+	_, err := r.DB.Exec(ctx,
+		`UPDATE rent_accrual_runs
+		 SET entries_checked = $2, charges_posted = $3, amount_posted = $4,
+		     status = $5, error_message = NULLIF($6, ''), completed_at = $7
+		 WHERE id = $1`,
+		run.ID, run.EntriesChecked, run.ChargesPosted, run.AmountPosted,
+		run.Status, run.ErrorMessage, now,
+	)
+	return err
+}
+
+// GetLastCompletedRun returns the most recent completed run for a trigger, or nil
+func (r *RentAccrualRepository) GetLastCompletedRun(ctx context.Context, trigger string) (*models.RentAccrualRun, error) {
+	runs, err := r.list(ctx, `WHERE a.trigger = $1 AND a.status = 'completed'`, 1, trigger)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// ListRuns returns the most recent accrual runs
+func (r *RentAccrualRepository) ListRuns(ctx context.Context, limit int) ([]*models.RentAccrualRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return r.list(ctx, "", limit)
+}
+
+func (r *RentAccrualRepository) list(ctx context.Context, where string, limit int, args ...interface{}) ([]*models.RentAccrualRun, error) {
+	query := fmt.Sprintf(`
+		SELECT a.id, a.trigger, a.as_of, a.entries_checked, a.charges_posted, a.amount_posted,
+		       a.status, COALESCE(a.error_message, ''), a.started_by_user_id, COALESCE(u.name, ''),
+		       a.started_at, a.completed_at
+		FROM rent_accrual_runs a
+		LEFT JOIN users u ON a.started_by_user_id = u.id
+		%s
+		ORDER BY a.started_at DESC, a.id DESC
+		LIMIT %d`, where, limit)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.RentAccrualRun
+	for rows.Next() {
+		var run models.RentAccrualRun
+		if err := rows.Scan(
+			&run.ID, &run.Trigger, &run.AsOf, &run.EntriesChecked, &run.ChargesPosted, &run.AmountPosted,
+			&run.Status, &run.ErrorMessage, &run.StartedByUserID, &run.StartedByName,
+			&run.StartedAt, &run.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rentAdjustmentPolicySelect is the column list scanned by scanPolicy
+const rentAdjustmentPolicySelect = `
+	SELECT p.id, p.name, p.policy_type, p.thock_category, p.rate_percent, p.effective_from, p.effective_to,
+	       p.cap_percent, p.cap_amount, p.grace_days, p.is_active, COALESCE(p.notes, ''),
+	       p.created_by_user_id, COALESCE(u.name, ''), p.created_at, p.updated_at
+	FROM rent_adjustment_policies p
+	LEFT JOIN users u ON p.created_by_user_id = u.id`
+
+type RentAdjustmentRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRentAdjustmentRepository(db *pgxpool.Pool) *RentAdjustmentRepository {
+	return &RentAdjustmentRepository{DB: db}
+}
+
+// Create inserts a new policy (active)
+func (r *RentAdjustmentRepository) Create(ctx context.Context, p *models.RentAdjustmentPolicy) error {
+	return r.DB.QueryRow(ctx,
+		`INSERT INTO rent_adjustment_policies (name, policy_type, thock_category, rate_percent, effective_from, effective_to,
+		                                       cap_percent, cap_amount, grace_days, notes, created_by_user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id, is_active, created_at, updated_at`,
+		p.Name, p.PolicyType, p.ThockCategory, p.RatePercent, p.EffectiveFrom, p.EffectiveTo,
+		p.CapPercent, p.CapAmount, p.GraceDays, p.Notes, p.CreatedByUserID,
+	).Scan(&p.ID, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// Update changes the terms of a policy. Amounts already posted are not touched.
+func (r *RentAdjustmentRepository) Update(ctx context.Context, p *models.RentAdjustmentPolicy) error {
+	result, err := r.DB.Exec(ctx,
+		`UPDATE rent_adjustment_policies
+		 SET name = $2, policy_type = $3, thock_category = $4, rate_percent = $5, effective_from = $6,
+		     effective_to = $7, cap_percent = $8, cap_amount = $9, grace_days = $10, notes = $11, updated_at = NOW()
+		 WHERE id = $1`,
+		p.ID, p.Name, p.PolicyType, p.ThockCategory, p.RatePercent, p.EffectiveFrom,
+		p.EffectiveTo, p.CapPercent, p.CapAmount, p.GraceDays, p.Notes,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("policy %d not found", p.ID)
+	}
+	return nil
+}
+
+// SetActive activates or retires a policy
+func (r *RentAdjustmentRepository) SetActive(ctx context.Context, id int, active bool) error {
+	result, err := r.DB.Exec(ctx,
+		`UPDATE rent_adjustment_policies SET is_active = $2, updated_at = NOW() WHERE id = $1`, id, active)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("policy %d not found", id)
+	}
+	return nil
+}
+
+// Get returns a single policy
+func (r *RentAdjustmentRepository) Get(ctx context.Context, id int) (*models.RentAdjustmentPolicy, error) {
+	return scanPolicy(r.DB.QueryRow(ctx, rentAdjustmentPolicySelect+` WHERE p.id = $1`, id))
+}
+
+// List returns all policies (newest first), optionally only active ones
+func (r *RentAdjustmentRepository) List(ctx context.Context, activeOnly bool) ([]*models.RentAdjustmentPolicy, error) {
+	rows, err := r.DB.Query(ctx,
+		rentAdjustmentPolicySelect+` WHERE ($1 = FALSE OR p.is_active = TRUE) ORDER BY p.effective_from DESC, p.id DESC`,
+		activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.RentAdjustmentPolicy
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// RecordRun stores a completed posting run
+func (r *RentAdjustmentRepository) RecordRun(ctx context.Context, run *models.RentAdjustmentRun, userID int) error {
+	return r.DB.QueryRow(ctx,
+		`INSERT INTO rent_adjustment_runs (trigger, as_of, thocks_checked, postings, interest_total, discount_total, started_by_user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0))
+		 RETURNING id`,
+		run.Trigger, run.AsOf, run.ThocksChecked, len(run.Adjustments), run.InterestTotal, run.DiscountTotal, userID,
+	).Scan(&run.ID)
+}
+
+// GetLastRunAt returns the as-of time of the latest run for a trigger, or nil if there is none
+func (r *RentAdjustmentRepository) GetLastRunAt(ctx context.Context, trigger string) (*time.Time, error) {
+	var asOf *time.Time
+	err := r.DB.QueryRow(ctx,
+		`SELECT MAX(as_of) FROM rent_adjustment_runs WHERE trigger = $1`, trigger,
+	).Scan(&asOf)
+	return asOf, err
+}
+
+// scanPolicy scans one rentAdjustmentPolicySelect row
+func scanPolicy(row pgx.Row) (*models.RentAdjustmentPolicy, error) {
+	var p models.RentAdjustmentPolicy
+	err := row.Scan(&p.ID, &p.Name, &p.PolicyType, &p.ThockCategory, &p.RatePercent, &p.EffectiveFrom, &p.EffectiveTo,
+		&p.CapPercent, &p.CapAmount, &p.GraceDays, &p.IsActive, &p.Notes,
+		&p.CreatedByUserID, &p.CreatedByName, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
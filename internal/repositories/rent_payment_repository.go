@@ -32,7 +32,7 @@ func (r *RentPaymentRepository) GenerateReceiptNumber(ctx context.Context) (stri
 func (r *RentPaymentRepository) CheckDuplicatePayment(ctx context.Context, customerPhone string, amountPaid float64) (bool, error) {
 	query := `
 		SELECT COUNT(*) FROM rent_payments
-		WHERE customer_phone = $1
+		WHERE ` + customerByPhone + `
 		AND amount_paid = $2
 		AND created_at > NOW() - INTERVAL '10 seconds'
 	`
@@ -45,6 +45,11 @@ func (r *RentPaymentRepository) CheckDuplicatePayment(ctx context.Context, custo
 }
 
 func (r *RentPaymentRepository) Create(ctx context.Context, payment *models.RentPayment) error {
+	return r.CreateWithAllocations(ctx, payment, nil)
+}
+
+// CreateWithAllocations records a payment and its split across thocks in one transaction
+func (r *RentPaymentRepository) CreateWithAllocations(ctx context.Context, payment *models.RentPayment, allocations []models.PaymentAllocation) error {
 	// Check for duplicate payment (same customer, same amount within 10 seconds)
 	isDuplicate, err := r.CheckDuplicatePayment(ctx, payment.CustomerPhone, payment.AmountPaid)
 	if err != nil {
@@ -60,13 +65,20 @@ func (r *RentPaymentRepository) Create(ctx context.Context, payment *models.Rent
 		return err
 	}
 
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO rent_payments (receipt_number, entry_id, family_member_id, family_member_name, customer_name, customer_phone, total_rent, amount_paid, balance, processed_by_user_id, notes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, payment_date, created_at
+		INSERT INTO rent_payments (receipt_number, entry_id, family_member_id, family_member_name, customer_name, customer_phone, total_rent, amount_paid, balance, processed_by_user_id, notes, customer_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+		        COALESCE(NULLIF($12, 0), (SELECT COALESCE(c.merged_into_customer_id, c.id) FROM customers c WHERE c.phone = $6)))
+		RETURNING id, COALESCE(customer_id, 0), payment_date, created_at
 	`
 
-	err = r.DB.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		receiptNumber,
 		payment.EntryID,
 		payment.FamilyMemberID,
@@ -78,20 +90,33 @@ func (r *RentPaymentRepository) Create(ctx context.Context, payment *models.Rent
 		payment.Balance,
 		payment.ProcessedByUserID,
 		payment.Notes,
-	).Scan(&payment.ID, &payment.PaymentDate, &payment.CreatedAt)
+		payment.CustomerID,
+	).Scan(&payment.ID, &payment.CustomerID, &payment.PaymentDate, &payment.CreatedAt)
 
 	if err != nil {
 		return err
 	}
 
+	for i := range allocations {
+		allocations[i].RentPaymentID = payment.ID
+		allocations[i].AllocatedByUserID = payment.ProcessedByUserID
+	}
+	if err := insertAllocations(ctx, tx, payment.ID, allocations); err != nil {
+		return fmt.Errorf("failed to save payment allocations: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
 	payment.ReceiptNumber = receiptNumber
+	payment.Allocations = allocations
 	return nil
 }
 
 func (r *RentPaymentRepository) GetByEntryID(ctx context.Context, entryID int) ([]*models.RentPayment, error) {
 	query := `
 		SELECT id, receipt_number, entry_id, family_member_id, COALESCE(family_member_name, ''),
-		       customer_name, customer_phone, total_rent, amount_paid, balance,
+		       COALESCE(customer_id, 0), customer_name, customer_phone, total_rent, amount_paid, balance,
 		       payment_date, COALESCE(processed_by_user_id, 0), COALESCE(notes, ''), created_at
 		FROM rent_payments
 		WHERE entry_id = $1
@@ -113,6 +138,7 @@ func (r *RentPaymentRepository) GetByEntryID(ctx context.Context, entryID int) (
 			&payment.EntryID,
 			&payment.FamilyMemberID,
 			&payment.FamilyMemberName,
+			&payment.CustomerID,
 			&payment.CustomerName,
 			&payment.CustomerPhone,
 			&payment.TotalRent,
@@ -135,10 +161,10 @@ func (r *RentPaymentRepository) GetByEntryID(ctx context.Context, entryID int) (
 func (r *RentPaymentRepository) GetByPhone(ctx context.Context, phone string) ([]*models.RentPayment, error) {
 	query := `
 		SELECT id, receipt_number, entry_id, family_member_id, COALESCE(family_member_name, ''),
-		       customer_name, customer_phone, total_rent, amount_paid, balance,
+		       COALESCE(customer_id, 0), customer_name, customer_phone, total_rent, amount_paid, balance,
 		       payment_date, COALESCE(processed_by_user_id, 0), COALESCE(notes, ''), created_at
 		FROM rent_payments
-		WHERE customer_phone = $1
+		WHERE ` + customerByPhone + `
 		ORDER BY payment_date DESC
 	`
 
@@ -157,6 +183,7 @@ func (r *RentPaymentRepository) GetByPhone(ctx context.Context, phone string) ([
 			&payment.EntryID,
 			&payment.FamilyMemberID,
 			&payment.FamilyMemberName,
+			&payment.CustomerID,
 			&payment.CustomerName,
 			&payment.CustomerPhone,
 			&payment.TotalRent,
@@ -179,7 +206,7 @@ func (r *RentPaymentRepository) GetByPhone(ctx context.Context, phone string) ([
 func (r *RentPaymentRepository) GetByFamilyMemberID(ctx context.Context, familyMemberID int) ([]*models.RentPayment, error) {
 	query := `
 		SELECT id, receipt_number, entry_id, family_member_id, COALESCE(family_member_name, ''),
-		       customer_name, customer_phone, total_rent, amount_paid, balance,
+		       COALESCE(customer_id, 0), customer_name, customer_phone, total_rent, amount_paid, balance,
 		       payment_date, COALESCE(processed_by_user_id, 0), COALESCE(notes, ''), created_at
 		FROM rent_payments
 		WHERE family_member_id = $1
@@ -201,6 +228,7 @@ func (r *RentPaymentRepository) GetByFamilyMemberID(ctx context.Context, familyM
 			&payment.EntryID,
 			&payment.FamilyMemberID,
 			&payment.FamilyMemberName,
+			&payment.CustomerID,
 			&payment.CustomerName,
 			&payment.CustomerPhone,
 			&payment.TotalRent,
@@ -224,7 +252,7 @@ func (r *RentPaymentRepository) List(ctx context.Context) ([]*models.RentPayment
 	// JOIN with users table to get employee name - eliminates N+1 queries
 	query := `
 		SELECT rp.id, rp.receipt_number, rp.entry_id, rp.family_member_id, COALESCE(rp.family_member_name, ''),
-		       rp.customer_name, rp.customer_phone,
+		       COALESCE(rp.customer_id, 0), rp.customer_name, rp.customer_phone,
 		       rp.total_rent, rp.amount_paid, rp.balance, rp.payment_date,
 		       COALESCE(rp.processed_by_user_id, 0), COALESCE(u.name, 'Unknown'),
 		       COALESCE(rp.notes, ''), rp.created_at
@@ -248,6 +276,7 @@ func (r *RentPaymentRepository) List(ctx context.Context) ([]*models.RentPayment
 			&payment.EntryID,
 			&payment.FamilyMemberID,
 			&payment.FamilyMemberName,
+			&payment.CustomerID,
 			&payment.CustomerName,
 			&payment.CustomerPhone,
 			&payment.TotalRent,
@@ -271,7 +300,7 @@ func (r *RentPaymentRepository) List(ctx context.Context) ([]*models.RentPayment
 func (r *RentPaymentRepository) GetByReceiptNumber(ctx context.Context, receiptNumber string) (*models.RentPayment, error) {
 	query := `
 		SELECT id, receipt_number, entry_id, family_member_id, COALESCE(family_member_name, ''),
-		       customer_name, customer_phone, total_rent, amount_paid, balance,
+		       COALESCE(customer_id, 0), customer_name, customer_phone, total_rent, amount_paid, balance,
 		       payment_date, COALESCE(processed_by_user_id, 0), COALESCE(notes, ''), created_at
 		FROM rent_payments
 		WHERE receipt_number = $1
@@ -284,6 +313,7 @@ func (r *RentPaymentRepository) GetByReceiptNumber(ctx context.Context, receiptN
 		&payment.EntryID,
 		&payment.FamilyMemberID,
 		&payment.FamilyMemberName,
+		&payment.CustomerID,
 		&payment.CustomerName,
 		&payment.CustomerPhone,
 		&payment.TotalRent,
@@ -300,3 +330,61 @@ func (r *RentPaymentRepository) GetByReceiptNumber(ctx context.Context, receiptN
 
 	return payment, nil
 }
+
+func (r *RentPaymentRepository) GetByID(ctx context.Context, id int) (*models.RentPayment, error) {
+	query := `
+		SELECT id, receipt_number, entry_id, family_member_id, COALESCE(family_member_name, ''),
+		       COALESCE(customer_id, 0), customer_name, customer_phone, total_rent, amount_paid, balance,
+		       payment_date, COALESCE(processed_by_user_id, 0), COALESCE(notes, ''), created_at
+		FROM rent_payments
+		WHERE id = $1
+	`
+
+	payment := &models.RentPayment{}
+	err := r.DB.QueryRow(ctx, query, id).Scan(
+		&payment.ID,
+		&payment.ReceiptNumber,
+		&payment.EntryID,
+		&payment.FamilyMemberID,
+		&payment.FamilyMemberName,
+		&payment.CustomerID,
+		&payment.CustomerName,
+		&payment.CustomerPhone,
+		&payment.TotalRent,
+		&payment.AmountPaid,
+		&payment.Balance,
+		&payment.PaymentDate,
+		&payment.ProcessedByUserID,
+		&payment.Notes,
+		&payment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetReceiptNumbers returns the receipt numbers of the given payments keyed by payment ID
+func (r *RentPaymentRepository) GetReceiptNumbers(ctx context.Context, ids []int) (map[int]string, error) {
+	receipts := make(map[int]string, len(ids))
+	if len(ids) == 0 {
+		return receipts, nil
+	}
+
+	rows, err := r.DB.Query(ctx, `SELECT id, receipt_number FROM rent_payments WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var receipt string
+		if err := rows.Scan(&id, &receipt); err != nil {
+			return nil, err
+		}
+		receipts[id] = receipt
+	}
+	return receipts, rows.Err()
+}
@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RentTariffRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRentTariffRepository(db *pgxpool.Pool) *RentTariffRepository {
+	return &RentTariffRepository{DB: db}
+}
+
+// Create inserts a rate card together with its rate lines
+func (r *RentTariffRepository) Create(ctx context.Context, tariff *models.RentTariff) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO rent_tariffs (name, effective_from, is_active, accrual_mode, notes, created_by_user_id)
+		 VALUES ($1, $2, TRUE, $3, $4, $5)
+		 RETURNING id, is_active, created_at`,
+		tariff.Name, tariff.EffectiveFrom, tariff.AccrualMode, tariff.Notes, tariff.CreatedByUserID,
+	).Scan(&tariff.ID, &tariff.IsActive, &tariff.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create tariff: %w", err)
+	}
+
+	for i := range tariff.Rates {
+		rate := &tariff.Rates[i]
+		rate.TariffID = tariff.ID
+		err = tx.QueryRow(ctx,
+			`INSERT INTO rent_tariff_rates (tariff_id, thock_category, variety, min_months, max_months, rate_per_bag)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 RETURNING id`,
+			rate.TariffID, rate.ThockCategory, rate.Variety, rate.MinMonths, rate.MaxMonths, rate.RatePerBag,
+		).Scan(&rate.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create tariff rate: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Get returns a single rate card with its rate lines
+func (r *RentTariffRepository) Get(ctx context.Context, id int) (*models.RentTariff, error) {
+	query := `
+		SELECT t.id, t.name, t.effective_from, t.is_active, t.accrual_mode, COALESCE(t.notes, ''),
+		       t.created_by_user_id, COALESCE(u.name, ''), t.created_at
+		FROM rent_tariffs t
+		LEFT JOIN users u ON t.created_by_user_id = u.id
+		WHERE t.id = $1
+	`
+	var t models.RentTariff
+	err := r.DB.QueryRow(ctx, query, id).Scan(
+		&t.ID, &t.Name, &t.EffectiveFrom, &t.IsActive, &t.AccrualMode, &t.Notes,
+		&t.CreatedByUserID, &t.CreatedByName, &t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := r.listRates(ctx, []int{t.ID})
+	if err != nil {
+		return nil, err
+	}
+	t.Rates = rates[t.ID]
+	return &t, nil
+}
+
+// List returns all rate cards (newest first), optionally only active ones
+func (r *RentTariffRepository) List(ctx context.Context, activeOnly bool) ([]*models.RentTariff, error) {
+	query := `
+		SELECT t.id, t.name, t.effective_from, t.is_active, t.accrual_mode, COALESCE(t.notes, ''),
+		       t.created_by_user_id, COALESCE(u.name, ''), t.created_at
+		FROM rent_tariffs t
+		LEFT JOIN users u ON t.created_by_user_id = u.id
+		WHERE ($1 = FALSE OR t.is_active = TRUE)
+		ORDER BY t.effective_from DESC, t.id DESC
+	`
+	rows, err := r.DB.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tariffs []*models.RentTariff
+	var ids []int
+	for rows.Next() {
+		var t models.RentTariff
+		if err := rows.Scan(
+			&t.ID, &t.Name, &t.EffectiveFrom, &t.IsActive, &t.AccrualMode, &t.Notes,
+			&t.CreatedByUserID, &t.CreatedByName, &t.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		tariffs = append(tariffs, &t)
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return tariffs, nil
+	}
+
+	rates, err := r.listRates(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tariffs {
+		t.Rates = rates[t.ID]
+	}
+	return tariffs, nil
+}
+
+// SetActive activates or retires a rate card
+func (r *RentTariffRepository) SetActive(ctx context.Context, id int, active bool) error {
+	result, err := r.DB.Exec(ctx, `UPDATE rent_tariffs SET is_active = $2 WHERE id = $1`, id, active)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("tariff %d not found", id)
+	}
+	return nil
+}
+
+// listRates loads rate lines for the given tariffs keyed by tariff ID
+func (r *RentTariffRepository) listRates(ctx context.Context, tariffIDs []int) (map[int][]models.RentTariffRate, error) {
+	query := `
+		SELECT id, tariff_id, thock_category, variety, min_months, max_months, rate_per_bag
+		FROM rent_tariff_rates
+		WHERE tariff_id = ANY($1)
+		ORDER BY tariff_id, thock_category, variety, min_months
+	`
+	rows, err := r.DB.Query(ctx, query, tariffIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make(map[int][]models.RentTariffRate)
+	for rows.Next() {
+		var rt models.RentTariffRate
+		if err := rows.Scan(
+			&rt.ID, &rt.TariffID, &rt.ThockCategory, &rt.Variety,
+			&rt.MinMonths, &rt.MaxMonths, &rt.RatePerBag,
+		); err != nil {
+			return nil, err
+		}
+		rates[rt.TariffID] = append(rates[rt.TariffID], rt)
+	}
+	return rates, rows.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"cold-backend/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -81,49 +82,6 @@ func (r *RoomEntryGatarRepository) GetByRoomFloorGatar(ctx context.Context, room
 	return gatars, nil
 }
 
-// GatarStock represents stock information for a single gatar
-type GatarStock struct {
-	GatarNo     int    `json:"gatar_no"`
-	Quantity    int    `json:"quantity"`
-	Variety     string `json:"variety"`
-	ThockNumber string `json:"thock_number"`
-	Quality     string `json:"quality"`
-	RoomEntryID int    `json:"room_entry_id"`
-}
-
-// GetStockByRoomFloor returns per-gatar stock counts for visualization
-func (r *RoomEntryGatarRepository) GetStockByRoomFloor(ctx context.Context, roomNo string, floor string) ([]GatarStock, error) {
-	rows, err := r.DB.Query(ctx,
-		`SELECT reg.gatar_no, SUM(reg.quantity) as total_qty,
-		        STRING_AGG(DISTINCT COALESCE(e.remark, ''), ', ') as variety,
-		        STRING_AGG(DISTINCT re.thock_number, ', ') as thock_numbers,
-		        COALESCE(MAX(reg.quality), '') as quality,
-		        MAX(reg.room_entry_id) as room_entry_id
-         FROM room_entry_gatars reg
-         JOIN room_entries re ON reg.room_entry_id = re.id
-         LEFT JOIN entries e ON re.entry_id = e.id
-         WHERE re.room_no = $1 AND re.floor = $2
-           AND COALESCE(e.status, 'active') != 'deleted'
-           AND reg.quantity > 0
-         GROUP BY reg.gatar_no
-         ORDER BY reg.gatar_no`, roomNo, floor)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var stocks []GatarStock
-	for rows.Next() {
-		var s GatarStock
-		err := rows.Scan(&s.GatarNo, &s.Quantity, &s.Variety, &s.ThockNumber, &s.Quality, &s.RoomEntryID)
-		if err != nil {
-			return nil, err
-		}
-		stocks = append(stocks, s)
-	}
-	return stocks, nil
-}
-
 // ReduceQuantity reduces the quantity in a specific gatar entry
 func (r *RoomEntryGatarRepository) ReduceQuantity(ctx context.Context, id int, amount int) error {
 	_, err := r.DB.Exec(ctx,
@@ -148,47 +106,19 @@ func (r *RoomEntryGatarRepository) UpdateBatch(ctx context.Context, roomEntryID
 	return r.CreateBatch(ctx, roomEntryID, gatars)
 }
 
-// GatarSearchResult represents a search result with gatar-level details
-type GatarSearchResult struct {
-	RoomEntryID int    `json:"room_entry_id"`
-	EntryID     int    `json:"entry_id"`
-	ThockNumber string `json:"thock_number"`
-	RoomNo      string `json:"room_no"`
-	Floor       string `json:"floor"`
-	GatarNo     int    `json:"gatar_no"`
-	Quantity    int    `json:"quantity"`
-	Quality     string `json:"quality"`
-	Variety     string `json:"variety"`
-	CustomerID  int    `json:"customer_id"`
-}
-
-// SearchByGatar finds all entries in a specific gatar location
-func (r *RoomEntryGatarRepository) SearchByGatar(ctx context.Context, roomNo string, floor string, gatarNo int) ([]GatarSearchResult, error) {
-	rows, err := r.DB.Query(ctx,
-		`SELECT reg.room_entry_id, re.entry_id, re.thock_number, re.room_no, re.floor,
-		        reg.gatar_no, reg.quantity, COALESCE(reg.quality, ''),
-		        COALESCE(e.remark, '') as variety, COALESCE(e.customer_id, 0)
-         FROM room_entry_gatars reg
-         JOIN room_entries re ON reg.room_entry_id = re.id
-         LEFT JOIN entries e ON re.entry_id = e.id
-         WHERE re.room_no = $1 AND re.floor = $2 AND reg.gatar_no = $3
-           AND COALESCE(e.status, 'active') != 'deleted'
-           AND reg.quantity > 0
-         ORDER BY reg.created_at DESC`, roomNo, floor, gatarNo)
-	if err != nil {
-		return nil, err
+// replaceRoomEntryGatars replaces the gatar entries of a room entry inside a transaction
+func replaceRoomEntryGatars(ctx context.Context, tx pgx.Tx, roomEntryID int, gatars []models.GatarInput) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM room_entry_gatars WHERE room_entry_id = $1`, roomEntryID); err != nil {
+		return err
 	}
-	defer rows.Close()
-
-	var results []GatarSearchResult
-	for rows.Next() {
-		var r GatarSearchResult
-		err := rows.Scan(&r.RoomEntryID, &r.EntryID, &r.ThockNumber, &r.RoomNo, &r.Floor,
-			&r.GatarNo, &r.Quantity, &r.Quality, &r.Variety, &r.CustomerID)
+	for _, g := range gatars {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO room_entry_gatars(room_entry_id, gatar_no, quantity, quality, remark)
+             VALUES($1, $2, $3, $4, $5)`,
+			roomEntryID, g.GatarNo, g.Quantity, g.Quality, g.Remark)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		results = append(results, r)
 	}
-	return results, nil
+	return nil
 }
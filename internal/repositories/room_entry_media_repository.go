@@ -23,8 +23,8 @@ func (r *RoomEntryMediaRepository) Create(ctx context.Context, media *models.Roo
 		INSERT INTO room_entry_media (
 			room_entry_id, thock_number, media_type,
 			file_path, file_name, file_type,
-			file_size, uploaded_by_user_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			file_size, uploaded_by_user_id, inspection_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id, created_at
 	`
 	err := r.pool.QueryRow(ctx, query,
@@ -36,6 +36,7 @@ func (r *RoomEntryMediaRepository) Create(ctx context.Context, media *models.Roo
 		media.FileType,
 		media.FileSize,
 		media.UploadedByUserID,
+		media.InspectionID,
 	).Scan(&media.ID, &media.CreatedAt)
 	return err
 }
@@ -45,7 +46,7 @@ func (r *RoomEntryMediaRepository) ListByRoomEntryID(ctx context.Context, roomEn
 		SELECT
 			m.id, m.room_entry_id, m.thock_number, m.media_type,
 			m.file_path, m.file_name, m.file_type, m.file_size,
-			m.uploaded_by_user_id, m.created_at,
+			m.uploaded_by_user_id, m.created_at, m.inspection_id,
 			COALESCE(u.name, u.email, 'Unknown') as uploaded_by_user_name
 		FROM room_entry_media m
 		LEFT JOIN users u ON m.uploaded_by_user_id = u.id
@@ -73,6 +74,7 @@ func (r *RoomEntryMediaRepository) ListByRoomEntryID(ctx context.Context, roomEn
 			&media.FileSize,
 			&media.UploadedByUserID,
 			&media.CreatedAt,
+			&media.InspectionID,
 			&media.UploadedByUserName,
 		)
 		if err != nil {
@@ -95,7 +97,7 @@ func (r *RoomEntryMediaRepository) ListByThockNumber(ctx context.Context, thockN
 		SELECT
 			m.id, m.room_entry_id, m.thock_number, m.media_type,
 			m.file_path, m.file_name, m.file_type, m.file_size,
-			m.uploaded_by_user_id, m.created_at,
+			m.uploaded_by_user_id, m.created_at, m.inspection_id,
 			COALESCE(u.name, u.email, 'Unknown') as uploaded_by_user_name
 		FROM room_entry_media m
 		LEFT JOIN users u ON m.uploaded_by_user_id = u.id
@@ -123,6 +125,7 @@ func (r *RoomEntryMediaRepository) ListByThockNumber(ctx context.Context, thockN
 			&media.FileSize,
 			&media.UploadedByUserID,
 			&media.CreatedAt,
+			&media.InspectionID,
 			&media.UploadedByUserName,
 		)
 		if err != nil {
@@ -139,3 +142,55 @@ func (r *RoomEntryMediaRepository) ListByThockNumber(ctx context.Context, thockN
 
 	return mediaList, rows.Err()
 }
+
+// ListByInspectionIDs returns the photos of quality inspections, keyed by inspection ID
+func (r *RoomEntryMediaRepository) ListByInspectionIDs(ctx context.Context, inspectionIDs []int) (map[int][]*models.RoomEntryMedia, error) {
+	query := `
+		SELECT
+			m.id, m.room_entry_id, m.thock_number, m.media_type,
+			m.file_path, m.file_name, m.file_type, m.file_size,
+			m.uploaded_by_user_id, m.created_at, m.inspection_id,
+			COALESCE(u.name, u.email, 'Unknown') as uploaded_by_user_name
+		FROM room_entry_media m
+		LEFT JOIN users u ON m.uploaded_by_user_id = u.id
+		WHERE m.inspection_id = ANY($1)
+		ORDER BY m.created_at
+	`
+
+	rows, err := r.pool.Query(ctx, query, inspectionIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := make(map[int][]*models.RoomEntryMedia)
+	for rows.Next() {
+		media := &models.RoomEntryMedia{}
+		err := rows.Scan(
+			&media.ID,
+			&media.RoomEntryID,
+			&media.ThockNumber,
+			&media.MediaType,
+			&media.FilePath,
+			&media.FileName,
+			&media.FileType,
+			&media.FileSize,
+			&media.UploadedByUserID,
+			&media.CreatedAt,
+			&media.InspectionID,
+			&media.UploadedByUserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		media.DownloadURL = fmt.Sprintf(
+			"/api/files/download?root=bulk&path=%s&mode=inline",
+			url.QueryEscape(media.FilePath),
+		)
+
+		photos[*media.InspectionID] = append(photos[*media.InspectionID], media)
+	}
+
+	return photos, rows.Err()
+}
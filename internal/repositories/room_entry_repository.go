@@ -24,6 +24,45 @@ func (r *RoomEntryRepository) Create(ctx context.Context, re *models.RoomEntry)
 	).Scan(&re.ID, &re.CreatedAt, &re.UpdatedAt)
 }
 
+// CreateWithStock saves a room entry, its per-gatar quantities and its stock ledger rows in one
+// transaction, holding the thock's stock lock. check runs with the receiving gatars locked; an
+// error from it saves nothing.
+func (r *RoomEntryRepository) CreateWithStock(ctx context.Context, re *models.RoomEntry, gatars []models.GatarInput, movements []*models.GatarStockMovement, check GatarSpaceCheck) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockThockStock(ctx, tx, re.ThockNumber); err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO room_entries(entry_id, thock_number, room_no, floor, gate_no, remark, quantity, quantity_breakdown, created_by_user_id)
+         VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+         RETURNING id, created_at, updated_at`,
+		re.EntryID, re.ThockNumber, re.RoomNo, re.Floor, re.GateNo, re.Remark, re.Quantity, re.QuantityBreakdown, re.CreatedByUserID,
+	).Scan(&re.ID, &re.CreatedAt, &re.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := replaceRoomEntryGatars(ctx, tx, re.ID, gatars); err != nil {
+		return err
+	}
+
+	for _, m := range movements {
+		m.RoomEntryID = re.ID
+		m.SourceID = re.ID
+	}
+	if err := checkGatarSpace(ctx, tx, movements, check); err != nil {
+		return err
+	}
+	if err := insertMovements(ctx, tx, movements); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 func (r *RoomEntryRepository) Get(ctx context.Context, id int) (*models.RoomEntry, error) {
 	row := r.DB.QueryRow(ctx,
 		`SELECT re.id, re.entry_id, re.thock_number, re.room_no, re.floor, re.gate_no, re.remark, re.quantity,
@@ -121,6 +160,52 @@ func (r *RoomEntryRepository) Update(ctx context.Context, id int, re *models.Roo
 	).Scan(&re.UpdatedAt)
 }
 
+// UpdateWithStock saves an edited room entry and posts the change to the stock ledger in one
+// transaction, holding the thock's stock lock. Per-gatar quantities are replaced when gatars is not empty. plan receives the
+// entry's current ledger balances and returns the movements to post; check runs with the
+// receiving gatars locked, and an error from either saves nothing.
+func (r *RoomEntryRepository) UpdateWithStock(ctx context.Context, id int, re *models.RoomEntry, gatars []models.GatarInput, plan func(balances []*models.GatarBalance) []*models.GatarStockMovement, check GatarSpaceCheck) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockThockStock(ctx, tx, re.ThockNumber); err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx,
+		`UPDATE room_entries
+         SET room_no=$1, floor=$2, gate_no=$3, remark=$4, quantity=$5, quantity_breakdown=$6, updated_at=NOW()
+         WHERE id=$7
+         RETURNING updated_at`,
+		re.RoomNo, re.Floor, re.GateNo, re.Remark, re.Quantity, re.QuantityBreakdown, id,
+	).Scan(&re.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if len(gatars) > 0 {
+		if err := replaceRoomEntryGatars(ctx, tx, id, gatars); err != nil {
+			return err
+		}
+	}
+
+	if plan != nil {
+		balances, err := entryBalances(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		movements := plan(balances)
+		if err := checkGatarSpace(ctx, tx, movements, check); err != nil {
+			return err
+		}
+		if err := insertMovements(ctx, tx, movements); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
 // ReduceQuantity reduces the quantity in a room entry (for gate pass pickups)
 func (r *RoomEntryRepository) ReduceQuantity(ctx context.Context, thockNumber, roomNo, floor string, quantity int) error {
 	query := `
@@ -151,6 +236,32 @@ func (r *RoomEntryRepository) GetTotalQuantityByThockNumber(ctx context.Context,
 	return totalQuantity, err
 }
 
+// GetStoredQuantityByThock returns the bags put into rooms per thock number, counting
+// only room entries of entries that are not deleted (the quantity reports bill on)
+func (r *RoomEntryRepository) GetStoredQuantityByThock(ctx context.Context) (map[string]int, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT re.thock_number, COALESCE(SUM(re.quantity), 0)
+         FROM room_entries re
+         LEFT JOIN entries e ON re.entry_id = e.id
+         WHERE COALESCE(e.status, 'active') != 'deleted'
+         GROUP BY re.thock_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quantities := make(map[string]int)
+	for rows.Next() {
+		var thockNumber string
+		var quantity int
+		if err := rows.Scan(&thockNumber, &quantity); err != nil {
+			return nil, err
+		}
+		quantities[thockNumber] = quantity
+	}
+	return quantities, rows.Err()
+}
+
 // ListByThockNumber returns all room entries for a specific truck
 func (r *RoomEntryRepository) ListByThockNumber(ctx context.Context, thockNumber string) ([]*models.RoomEntry, error) {
 	// Filter out room entries where the parent entry is deleted
@@ -0,0 +1,389 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrThockNumberNotReserved is returned when an entry asks for a number that is not held for it
+	ErrThockNumberNotReserved = errors.New("thock number is not reserved")
+	// ErrThockNumberUnavailable is returned when a number cannot be reserved or voided
+	ErrThockNumberUnavailable = errors.New("thock number is not available")
+)
+
+// thockSeriesStarts are the first numbers of a season's series
+var thockSeriesStarts = map[string]int{"seed": 1, "sell": 1501}
+
+// currentThockSeasonQuery is the season whose series numbers new thocks
+const currentThockSeasonQuery = `
+	SELECT COALESCE(
+		(SELECT NULLIF(TRIM(setting_value), '') FROM system_settings WHERE setting_key = 'thock_season'),
+		to_char(CURRENT_DATE, 'YYYY'))`
+
+// thockQuerier is satisfied by both the pool and a transaction
+type thockQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// formatThockNumber builds the printed thock number: seed numbers are zero-padded
+func formatThockNumber(category string, number, quantity int) string {
+	if category == "seed" {
+		return fmt.Sprintf("%04d/%d", number, quantity)
+	}
+	return fmt.Sprintf("%d/%d", number, quantity)
+}
+
+// lockThockSeries creates the season's series of a category if needed and locks it until
+// tx ends, returning the last number taken
+func lockThockSeries(ctx context.Context, tx pgx.Tx, season, category string) (int, error) {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO thock_number_series (season, category, start_number, last_number)
+		 VALUES ($1, $2, $3::int, $3::int - 1)
+		 ON CONFLICT (season, category) DO NOTHING`,
+		season, category, thockSeriesStarts[category])
+	if err != nil {
+		return 0, err
+	}
+	var last int
+	err = tx.QueryRow(ctx,
+		`SELECT last_number FROM thock_number_series WHERE season = $1 AND category = $2 FOR UPDATE`,
+		season, category).Scan(&last)
+	return last, err
+}
+
+// nextFreeThockNumber returns the first number from n on that is not in a skip range and
+// not already recorded. With record set, numbers jumped over by skip ranges are logged.
+func nextFreeThockNumber(ctx context.Context, q thockQuerier, season, category string, n int, skipRanges []SkipRange, record bool) (int, error) {
+	for {
+		jumped := false
+		for _, sr := range skipRanges {
+			if n >= sr.From && n <= sr.To {
+				if record {
+					_, err := q.Exec(ctx,
+						`INSERT INTO thock_number_log (season, category, number, status, reason)
+						 SELECT $1, $2, g, 'skipped', $5
+						 FROM generate_series($3::int, $4::int) AS g
+						 ON CONFLICT (season, category, number) DO NOTHING`,
+						season, category, n, sr.To, fmt.Sprintf("Skip range %d-%d", sr.From, sr.To))
+					if err != nil {
+						return 0, err
+					}
+				}
+				n = sr.To + 1
+				jumped = true
+				break
+			}
+		}
+		if jumped {
+			continue
+		}
+
+		var taken bool
+		err := q.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM thock_number_log WHERE season = $1 AND category = $2 AND number = $3)`,
+			season, category, n).Scan(&taken)
+		if err != nil {
+			return 0, err
+		}
+		if !taken {
+			return n, nil
+		}
+		n++
+	}
+}
+
+type ThockNumberRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewThockNumberRepository(db *pgxpool.Pool) *ThockNumberRepository {
+	return &ThockNumberRepository{DB: db}
+}
+
+// CurrentSeason returns the season new thocks are numbered in
+func (r *ThockNumberRepository) CurrentSeason(ctx context.Context) (string, error) {
+	var season string
+	err := r.DB.QueryRow(ctx, currentThockSeasonQuery).Scan(&season)
+	return season, err
+}
+
+// PeekNext returns the number the next entry of a category would get, without taking it
+func (r *ThockNumberRepository) PeekNext(ctx context.Context, category string, skipRanges []SkipRange) (int, error) {
+	season, err := r.CurrentSeason(ctx)
+	if err != nil {
+		return 0, err
+	}
+	last := thockSeriesStarts[category] - 1
+	err = r.DB.QueryRow(ctx,
+		`SELECT last_number FROM thock_number_series WHERE season = $1 AND category = $2`,
+		season, category).Scan(&last)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	return nextFreeThockNumber(ctx, r.DB, season, category, last+1, skipRanges, false)
+}
+
+// Reserve holds numbers of the current season for later entries: the next count numbers, or
+// one specific number that has not been reached yet
+func (r *ThockNumberRepository) Reserve(ctx context.Context, category string, count, number int, skipRanges []SkipRange, reason string, userID int) ([]*models.ThockNumberRecord, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var season string
+	if err := tx.QueryRow(ctx, currentThockSeasonQuery).Scan(&season); err != nil {
+		return nil, err
+	}
+	last, err := lockThockSeries(ctx, tx, season, category)
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers []int
+	if number > 0 {
+		if number <= last {
+			return nil, fmt.Errorf("%w: %s number %d has already been passed", ErrThockNumberUnavailable, category, number)
+		}
+		free, err := nextFreeThockNumber(ctx, tx, season, category, number, skipRanges, false)
+		if err != nil {
+			return nil, err
+		}
+		if free != number {
+			return nil, fmt.Errorf("%w: %s number %d is skipped, reserved or voided", ErrThockNumberUnavailable, category, number)
+		}
+		numbers = append(numbers, number)
+	} else {
+		next := last + 1
+		for i := 0; i < count; i++ {
+			n, err := nextFreeThockNumber(ctx, tx, season, category, next, skipRanges, true)
+			if err != nil {
+				return nil, err
+			}
+			numbers = append(numbers, n)
+			next = n + 1
+		}
+		_, err := tx.Exec(ctx,
+			`UPDATE thock_number_series SET last_number = $3, updated_at = NOW() WHERE season = $1 AND category = $2`,
+			season, category, next-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]*models.ThockNumberRecord, 0, len(numbers))
+	for _, n := range numbers {
+		rec := &models.ThockNumberRecord{Season: season, Category: category, Number: n,
+			Status: models.ThockNumberReserved, Reason: reason, UserID: &userID}
+		err := tx.QueryRow(ctx,
+			`INSERT INTO thock_number_log (season, category, number, status, reason, user_id)
+			 VALUES ($1, $2, $3, 'reserved', $4, $5)
+			 RETURNING id, created_at, updated_at`,
+			season, category, n, reason, userID,
+		).Scan(&rec.ID, &rec.CreatedAt, &rec.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, tx.Commit(ctx)
+}
+
+// Void withdraws a number of the current season for good. Numbers given to entries can
+// only be voided once the entry is deleted.
+func (r *ThockNumberRepository) Void(ctx context.Context, category string, number int, reason string, userID int) (*models.ThockNumberRecord, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var season string
+	if err := tx.QueryRow(ctx, currentThockSeasonQuery).Scan(&season); err != nil {
+		return nil, err
+	}
+	if _, err := lockThockSeries(ctx, tx, season, category); err != nil {
+		return nil, err
+	}
+
+	var status, thockNumber string
+	var entryID *int
+	err = tx.QueryRow(ctx,
+		`SELECT status, entry_id, thock_number FROM thock_number_log
+		 WHERE season = $1 AND category = $2 AND number = $3 FOR UPDATE`,
+		season, category, number).Scan(&status, &entryID, &thockNumber)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		// Not reached yet, or lost before numbers were logged
+	case err != nil:
+		return nil, err
+	case status == models.ThockNumberVoided:
+		return nil, fmt.Errorf("%w: %s number %d is already voided", ErrThockNumberUnavailable, category, number)
+	case status == models.ThockNumberIssued && entryID != nil:
+		// The quantity part of a thock number changes when the entry is edited
+		var active bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM entries
+				WHERE id = $1 AND thock_category = $2 AND COALESCE(status, 'active') != 'deleted'
+				  AND CASE WHEN thock_number ~ '^[0-9]+/' THEN CAST(SPLIT_PART(thock_number, '/', 1) AS INTEGER) END = $3)`,
+			*entryID, category, number).Scan(&active)
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			return nil, fmt.Errorf("%w: %s is in use - delete the entry before voiding its number", ErrThockNumberUnavailable, thockNumber)
+		}
+	}
+
+	rec := &models.ThockNumberRecord{}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO thock_number_log (season, category, number, status, reason, user_id)
+		 VALUES ($1, $2, $3, 'voided', $4, $5)
+		 ON CONFLICT (season, category, number)
+		 DO UPDATE SET status = 'voided', reason = EXCLUDED.reason, user_id = EXCLUDED.user_id, updated_at = NOW()
+		 RETURNING id, season, category, number, status, entry_id, thock_number, reason, user_id, created_at, updated_at`,
+		season, category, number, reason, userID,
+	).Scan(&rec.ID, &rec.Season, &rec.Category, &rec.Number, &rec.Status, &rec.EntryID, &rec.ThockNumber,
+		&rec.Reason, &rec.UserID, &rec.CreatedAt, &rec.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rec, tx.Commit(ctx)
+}
+
+// StartSeason switches numbering to a new season, opening its series at the given starts
+func (r *ThockNumberRepository) StartSeason(ctx context.Context, season string, starts map[string]int, userID int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, category := range []string{"seed", "sell"} {
+		start := starts[category]
+		if start <= 0 {
+			start = thockSeriesStarts[category]
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO thock_number_series (season, category, start_number, last_number)
+			 VALUES ($1, $2, $3::int, $3::int - 1)
+			 ON CONFLICT (season, category) DO NOTHING`,
+			season, category, start)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO system_settings (setting_key, setting_value, description, updated_by_user_id)
+		 VALUES ('thock_season', $1, 'Season whose series numbers new thocks; switched when a new season starts', NULLIF($2::int, 0))
+		 ON CONFLICT (setting_key) DO UPDATE SET setting_value = EXCLUDED.setting_value,
+		     updated_by_user_id = EXCLUDED.updated_by_user_id, updated_at = NOW()`,
+		season, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListSeries returns the series of a season with how their numbers were used
+func (r *ThockNumberRepository) ListSeries(ctx context.Context, season string) ([]*models.ThockNumberSeries, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT s.season, s.category, s.start_number, s.last_number, s.created_at, s.updated_at,
+		       COUNT(l.id) FILTER (WHERE l.status = 'issued')::int,
+		       COUNT(l.id) FILTER (WHERE l.status = 'reserved')::int,
+		       COUNT(l.id) FILTER (WHERE l.status = 'voided')::int,
+		       COUNT(l.id) FILTER (WHERE l.status = 'skipped')::int,
+		       GREATEST(s.last_number - s.start_number + 1
+		                - COUNT(l.id) FILTER (WHERE l.number BETWEEN s.start_number AND s.last_number), 0)::int
+		FROM thock_number_series s
+		LEFT JOIN thock_number_log l ON l.season = s.season AND l.category = s.category
+		WHERE s.season = $1
+		GROUP BY s.season, s.category, s.start_number, s.last_number, s.created_at, s.updated_at
+		ORDER BY s.category`, season)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []*models.ThockNumberSeries
+	for rows.Next() {
+		s := &models.ThockNumberSeries{}
+		err := rows.Scan(&s.Season, &s.Category, &s.StartNumber, &s.LastNumber, &s.CreatedAt, &s.UpdatedAt,
+			&s.Issued, &s.Reserved, &s.Voided, &s.Skipped, &s.Missing)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+// ListRecords returns the numbers of a season with the given status, or every number that
+// did not go to an entry when status is ""
+func (r *ThockNumberRepository) ListRecords(ctx context.Context, season, category, status string) ([]*models.ThockNumberRecord, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT l.id, l.season, l.category, l.number, l.status, l.entry_id, l.thock_number, l.reason,
+		       l.user_id, COALESCE(u.name, ''), l.created_at, l.updated_at
+		FROM thock_number_log l
+		LEFT JOIN users u ON u.id = l.user_id
+		WHERE l.season = $1
+		  AND ($2 = '' OR l.category = $2)
+		  AND (($3 = '' AND l.status != 'issued') OR l.status = $3)
+		ORDER BY l.category, l.number`, season, category, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*models.ThockNumberRecord
+	for rows.Next() {
+		rec := &models.ThockNumberRecord{}
+		err := rows.Scan(&rec.ID, &rec.Season, &rec.Category, &rec.Number, &rec.Status, &rec.EntryID, &rec.ThockNumber,
+			&rec.Reason, &rec.UserID, &rec.UserName, &rec.CreatedAt, &rec.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// ListMissing returns numbers of a series up to its last number that have no record:
+// numbers taken before the log existed whose entries are gone
+func (r *ThockNumberRepository) ListMissing(ctx context.Context, season, category string) ([]int, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT g
+		FROM thock_number_series s
+		CROSS JOIN LATERAL generate_series(s.start_number, s.last_number) AS g
+		WHERE s.season = $1 AND s.category = $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM thock_number_log l
+			WHERE l.season = s.season AND l.category = s.category AND l.number = g)
+		ORDER BY g`, season, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []int
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		missing = append(missing, n)
+	}
+	return missing, rows.Err()
+}
@@ -143,6 +143,28 @@ func (r *UserRepository) SetBackupCodes(ctx context.Context, userID int, hashedC
 	return err
 }
 
+// ListAdminPhones returns the phone numbers of active admins that have one
+func (r *UserRepository) ListAdminPhones(ctx context.Context) ([]string, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT DISTINCT phone FROM users
+         WHERE role='admin' AND is_active AND COALESCE(phone, '') <> ''
+         ORDER BY phone`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var phones []string
+	for rows.Next() {
+		var phone string
+		if err := rows.Scan(&phone); err != nil {
+			return nil, err
+		}
+		phones = append(phones, phone)
+	}
+	return phones, rows.Err()
+}
+
 // GetAdminsWithout2FA returns admin users who don't have 2FA enabled
 func (r *UserRepository) GetAdminsWithout2FA(ctx context.Context) ([]*models.User, error) {
 	rows, err := r.DB.Query(ctx,
@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrVehicleVisitNotFound is returned for an unknown vehicle visit
+	ErrVehicleVisitNotFound = errors.New("vehicle visit not found")
+	// ErrVehicleAlreadyInside is returned when a vehicle is booked in while still inside
+	ErrVehicleAlreadyInside = errors.New("vehicle is already inside")
+	// ErrVehicleNotInside is returned when a vehicle that already left is changed
+	ErrVehicleNotInside = errors.New("vehicle has already exited")
+)
+
+type VehicleRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewVehicleRepository(db *pgxpool.Pool) *VehicleRepository {
+	return &VehicleRepository{DB: db}
+}
+
+const vehicleVisitSelect = `
+	SELECT v.id, v.vehicle_no, v.driver_name, v.driver_phone, v.purpose, v.status, v.guard_entry_id,
+	       v.in_time, v.in_by_user_id, COALESCE(iu.name, ''), v.remarks,
+	       v.out_time, v.out_by_user_id, COALESCE(ou.name, ''),
+	       v.quantity_loaded, v.expected_quantity, v.quantity_mismatch, v.exit_remarks,
+	       v.created_at, v.updated_at,
+	       (EXTRACT(EPOCH FROM (COALESCE(v.out_time, CURRENT_TIMESTAMP) - v.in_time)) / 60)::int
+	FROM vehicle_visits v
+	LEFT JOIN users iu ON iu.id = v.in_by_user_id
+	LEFT JOIN users ou ON ou.id = v.out_by_user_id`
+
+func scanVehicleVisit(row pgx.Row) (*models.VehicleVisit, error) {
+	v := &models.VehicleVisit{}
+	err := row.Scan(&v.ID, &v.VehicleNo, &v.DriverName, &v.DriverPhone, &v.Purpose, &v.Status, &v.GuardEntryID,
+		&v.InTime, &v.InByUserID, &v.InByName, &v.Remarks,
+		&v.OutTime, &v.OutByUserID, &v.OutByName,
+		&v.QuantityLoaded, &v.ExpectedQuantity, &v.QuantityMismatch, &v.ExitRemarks,
+		&v.CreatedAt, &v.UpdatedAt,
+		&v.MinutesInside)
+	return v, err
+}
+
+func (r *VehicleRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.VehicleVisit, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var visits []*models.VehicleVisit
+	for rows.Next() {
+		v, err := scanVehicleVisit(rows)
+		if err != nil {
+			return nil, err
+		}
+		visits = append(visits, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return visits, r.loadLinks(ctx, visits)
+}
+
+// CheckIn books a vehicle in together with the entries and gate passes it came for
+func (r *VehicleRepository) CheckIn(ctx context.Context, v *models.VehicleVisit, entryIDs, gatePassIDs []int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO vehicle_visits (vehicle_no, driver_name, driver_phone, purpose, guard_entry_id, in_by_user_id, remarks)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, in_time, created_at, updated_at
+	`, v.VehicleNo, v.DriverName, v.DriverPhone, v.Purpose, v.GuardEntryID, v.InByUserID, v.Remarks,
+	).Scan(&v.ID, &v.Status, &v.InTime, &v.CreatedAt, &v.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrVehicleAlreadyInside
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range entryIDs {
+		if err := addVehicleLink(ctx, tx, v.ID, &id, nil); err != nil {
+			return err
+		}
+	}
+	for _, id := range gatePassIDs {
+		if err := addVehicleLink(ctx, tx, v.ID, nil, &id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func addVehicleLink(ctx context.Context, tx pgx.Tx, visitID int, entryID, gatePassID *int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO vehicle_visit_links (visit_id, entry_id, gate_pass_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, visitID, entryID, gatePassID)
+	return err
+}
+
+// AddLink links an entry or a gate pass to a vehicle still inside
+func (r *VehicleRepository) AddLink(ctx context.Context, visitID int, entryID, gatePassID *int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM vehicle_visits WHERE id = $1 FOR UPDATE`, visitID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVehicleVisitNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.VehicleInside {
+		return ErrVehicleNotInside
+	}
+	if err := addVehicleLink(ctx, tx, visitID, entryID, gatePassID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Get returns a vehicle visit with its links
+func (r *VehicleRepository) Get(ctx context.Context, id int) (*models.VehicleVisit, error) {
+	v, err := scanVehicleVisit(r.DB.QueryRow(ctx, vehicleVisitSelect+` WHERE v.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrVehicleVisitNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, r.loadLinks(ctx, []*models.VehicleVisit{v})
+}
+
+// ListInside returns the vehicles currently inside, longest inside first
+func (r *VehicleRepository) ListInside(ctx context.Context) ([]*models.VehicleVisit, error) {
+	return r.list(ctx, vehicleVisitSelect+` WHERE v.status = 'inside' ORDER BY v.in_time, v.id`)
+}
+
+// List returns the register between two times, newest first. vehicleNo "" matches all.
+func (r *VehicleRepository) List(ctx context.Context, vehicleNo string, from, to time.Time, limit int) ([]*models.VehicleVisit, error) {
+	return r.list(ctx, vehicleVisitSelect+`
+		WHERE ($1 = '' OR v.vehicle_no = $1)
+		  AND v.in_time >= $2 AND v.in_time <= $3
+		ORDER BY v.in_time DESC, v.id DESC
+		LIMIT $4`, vehicleNo, from, to, limit)
+}
+
+// ListByGatePass returns the visits of vehicles that collected on a gate pass
+func (r *VehicleRepository) ListByGatePass(ctx context.Context, gatePassID int) ([]*models.VehicleVisit, error) {
+	return r.list(ctx, vehicleVisitSelect+`
+		WHERE v.id IN (SELECT visit_id FROM vehicle_visit_links WHERE gate_pass_id = $1)
+		ORDER BY v.in_time DESC, v.id DESC`, gatePassID)
+}
+
+// Exit books a vehicle out; ErrVehicleNotInside if it already left
+func (r *VehicleRepository) Exit(ctx context.Context, v *models.VehicleVisit, userID, loaded, expected int, mismatch bool, remarks string) error {
+	err := r.DB.QueryRow(ctx, `
+		UPDATE vehicle_visits
+		SET status = 'exited', out_time = CURRENT_TIMESTAMP, out_by_user_id = $1,
+		    quantity_loaded = $2, expected_quantity = $3, quantity_mismatch = $4, exit_remarks = $5,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND status = 'inside'
+		RETURNING status, out_time, updated_at
+	`, userID, loaded, expected, mismatch, remarks, v.ID).Scan(&v.Status, &v.OutTime, &v.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVehicleNotInside
+	}
+	if err != nil {
+		return err
+	}
+	v.OutByUserID = &userID
+	v.QuantityLoaded = &loaded
+	v.ExpectedQuantity = &expected
+	v.QuantityMismatch = mismatch
+	v.ExitRemarks = remarks
+	return nil
+}
+
+// loadLinks fills in each visit's entries and gate passes. Bags picked up on a gate pass
+// count for the visit the pickup was loaded onto.
+func (r *VehicleRepository) loadLinks(ctx context.Context, visits []*models.VehicleVisit) error {
+	if len(visits) == 0 {
+		return nil
+	}
+	ids := make([]int, len(visits))
+	byID := make(map[int]*models.VehicleVisit, len(visits))
+	for n, v := range visits {
+		ids[n] = v.ID
+		byID[v.ID] = v
+		v.Links = []*models.VehicleVisitLink{}
+	}
+
+	rows, err := r.DB.Query(ctx, `
+		SELECT l.id, l.visit_id, l.entry_id, l.gate_pass_id,
+		       COALESCE(e.thock_number, gp.thock_number, ''),
+		       COALESCE(e.expected_quantity, gp.approved_quantity, gp.requested_quantity, 0),
+		       COALESCE((
+		           SELECT SUM(p.pickup_quantity) FROM gate_pass_pickups p
+		           WHERE p.gate_pass_id = l.gate_pass_id AND p.vehicle_visit_id = l.visit_id
+		       ), 0)::int,
+		       COALESCE(gp.status, '')
+		FROM vehicle_visit_links l
+		LEFT JOIN entries e ON e.id = l.entry_id
+		LEFT JOIN gate_passes gp ON gp.id = l.gate_pass_id
+		WHERE l.visit_id = ANY($1::int[])
+		ORDER BY l.id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := &models.VehicleVisitLink{}
+		if err := rows.Scan(&l.ID, &l.VisitID, &l.EntryID, &l.GatePassID, &l.ThockNumber, &l.Quantity, &l.PickedUp, &l.Status); err != nil {
+			return err
+		}
+		byID[l.VisitID].Links = append(byID[l.VisitID].Links, l)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,336 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrLayoutConflict is returned when a room, floor or gatar number already exists
+var ErrLayoutConflict = errors.New("warehouse layout conflict")
+
+// ErrLayoutNotFound is returned when a room, floor or gatar is not in the layout
+var ErrLayoutNotFound = errors.New("not in warehouse layout")
+
+type WarehouseLayoutRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewWarehouseLayoutRepository(db *pgxpool.Pool) *WarehouseLayoutRepository {
+	return &WarehouseLayoutRepository{DB: db}
+}
+
+// floorSelect aggregates gatar totals per floor; scanned by scanFloor
+const floorSelect = `
+	SELECT f.id, f.room_id, r.room_no, f.floor, f.sort_order,
+	       COUNT(g.id),
+	       COUNT(g.id) FILTER (WHERE g.is_disabled),
+	       COALESCE(SUM(g.capacity_bags) FILTER (WHERE NOT g.is_disabled), 0)
+	FROM warehouse_floors f
+	JOIN warehouse_rooms r ON r.id = f.room_id
+	LEFT JOIN warehouse_gatars g ON g.floor_id = f.id`
+
+// gatarSelect is the column list scanned by collectGatars
+const gatarSelect = `
+	SELECT g.id, g.floor_id, r.room_no, f.floor, g.gatar_no, g.capacity_bags,
+	       g.length_ft::float8, g.width_ft::float8, g.height_ft::float8,
+	       g.is_disabled, g.disabled_reason, g.notes, g.updated_at
+	FROM warehouse_gatars g
+	JOIN warehouse_floors f ON f.id = g.floor_id
+	JOIN warehouse_rooms r ON r.id = f.room_id`
+
+// ListRooms returns all rooms with their floors in display order
+func (r *WarehouseLayoutRepository) ListRooms(ctx context.Context) ([]*models.WarehouseRoom, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT id, room_no, name, sort_order, is_active, notes, created_at, updated_at
+		 FROM warehouse_rooms
+		 ORDER BY sort_order, room_no`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []*models.WarehouseRoom
+	byID := make(map[int]*models.WarehouseRoom)
+	for rows.Next() {
+		room := &models.WarehouseRoom{Floors: []models.WarehouseFloor{}}
+		if err := rows.Scan(&room.ID, &room.RoomNo, &room.Name, &room.SortOrder, &room.IsActive,
+			&room.Notes, &room.CreatedAt, &room.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+		byID[room.ID] = room
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	floorRows, err := r.DB.Query(ctx, floorSelect+`
+		GROUP BY f.id, r.room_no
+		ORDER BY f.sort_order, f.floor`)
+	if err != nil {
+		return nil, err
+	}
+	defer floorRows.Close()
+
+	for floorRows.Next() {
+		floor, err := scanFloor(floorRows)
+		if err != nil {
+			return nil, err
+		}
+		if room, ok := byID[floor.RoomID]; ok {
+			room.Floors = append(room.Floors, *floor)
+		}
+	}
+	return rooms, floorRows.Err()
+}
+
+// GetRoom returns a room by number, without floors
+func (r *WarehouseLayoutRepository) GetRoom(ctx context.Context, roomNo string) (*models.WarehouseRoom, error) {
+	room := &models.WarehouseRoom{}
+	err := r.DB.QueryRow(ctx,
+		`SELECT id, room_no, name, sort_order, is_active, notes, created_at, updated_at
+		 FROM warehouse_rooms WHERE room_no = $1`, roomNo,
+	).Scan(&room.ID, &room.RoomNo, &room.Name, &room.SortOrder, &room.IsActive,
+		&room.Notes, &room.CreatedAt, &room.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("room %s: %w", roomNo, ErrLayoutNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// CreateRoom inserts a room
+func (r *WarehouseLayoutRepository) CreateRoom(ctx context.Context, room *models.WarehouseRoom) error {
+	err := r.DB.QueryRow(ctx,
+		`INSERT INTO warehouse_rooms (room_no, name, sort_order, is_active, notes)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at, updated_at`,
+		room.RoomNo, room.Name, room.SortOrder, room.IsActive, room.Notes,
+	).Scan(&room.ID, &room.CreatedAt, &room.UpdatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: room %s already exists", ErrLayoutConflict, room.RoomNo)
+	}
+	return err
+}
+
+// UpdateRoom saves a room's name, order, active flag and notes
+func (r *WarehouseLayoutRepository) UpdateRoom(ctx context.Context, room *models.WarehouseRoom) error {
+	err := r.DB.QueryRow(ctx,
+		`UPDATE warehouse_rooms
+		 SET name = $2, sort_order = $3, is_active = $4, notes = $5, updated_at = NOW()
+		 WHERE room_no = $1
+		 RETURNING id, created_at, updated_at`,
+		room.RoomNo, room.Name, room.SortOrder, room.IsActive, room.Notes,
+	).Scan(&room.ID, &room.CreatedAt, &room.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("room %s: %w", room.RoomNo, ErrLayoutNotFound)
+	}
+	return err
+}
+
+// DeleteRoom removes a room together with its floors and gatars
+func (r *WarehouseLayoutRepository) DeleteRoom(ctx context.Context, roomNo string) error {
+	result, err := r.DB.Exec(ctx, `DELETE FROM warehouse_rooms WHERE room_no = $1`, roomNo)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("room %s: %w", roomNo, ErrLayoutNotFound)
+	}
+	return nil
+}
+
+// GetFloor returns a floor with its gatar totals
+func (r *WarehouseLayoutRepository) GetFloor(ctx context.Context, roomNo, floor string) (*models.WarehouseFloor, error) {
+	row := r.DB.QueryRow(ctx, floorSelect+`
+		WHERE r.room_no = $1 AND f.floor = $2
+		GROUP BY f.id, r.room_no`, roomNo, floor)
+	f, err := scanFloor(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("room %s floor %s: %w", roomNo, floor, ErrLayoutNotFound)
+	}
+	return f, err
+}
+
+// CreateFloor adds a floor to a room
+func (r *WarehouseLayoutRepository) CreateFloor(ctx context.Context, f *models.WarehouseFloor) error {
+	err := r.DB.QueryRow(ctx,
+		`INSERT INTO warehouse_floors (room_id, floor, sort_order)
+		 SELECT id, $2::varchar, $3::int FROM warehouse_rooms WHERE room_no = $1
+		 RETURNING id, room_id`,
+		f.RoomNo, f.Floor, f.SortOrder,
+	).Scan(&f.ID, &f.RoomID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("room %s: %w", f.RoomNo, ErrLayoutNotFound)
+	}
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: room %s already has floor %s", ErrLayoutConflict, f.RoomNo, f.Floor)
+	}
+	return err
+}
+
+// DeleteFloor removes a floor and its gatars
+func (r *WarehouseLayoutRepository) DeleteFloor(ctx context.Context, roomNo, floor string) error {
+	result, err := r.DB.Exec(ctx,
+		`DELETE FROM warehouse_floors f
+		 USING warehouse_rooms r
+		 WHERE r.id = f.room_id AND r.room_no = $1 AND f.floor = $2`, roomNo, floor)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("room %s floor %s: %w", roomNo, floor, ErrLayoutNotFound)
+	}
+	return nil
+}
+
+// ListGatars returns the gatars of a floor in number order
+func (r *WarehouseLayoutRepository) ListGatars(ctx context.Context, roomNo, floor string) ([]*models.WarehouseGatar, error) {
+	rows, err := r.DB.Query(ctx, gatarSelect+`
+		WHERE r.room_no = $1 AND f.floor = $2
+		ORDER BY g.gatar_no`, roomNo, floor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectGatars(rows)
+}
+
+// GetGatars returns the layout rows for the given gatar numbers; unknown numbers are left out
+func (r *WarehouseLayoutRepository) GetGatars(ctx context.Context, gatarNos []int) ([]*models.WarehouseGatar, error) {
+	rows, err := r.DB.Query(ctx, gatarSelect+`
+		WHERE g.gatar_no = ANY($1)
+		ORDER BY g.gatar_no`, gatarNos)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectGatars(rows)
+}
+
+// ListAllGatars returns every gatar of active rooms in layout order
+func (r *WarehouseLayoutRepository) ListAllGatars(ctx context.Context) ([]*models.WarehouseGatar, error) {
+	rows, err := r.DB.Query(ctx, gatarSelect+`
+		WHERE r.is_active
+		ORDER BY r.sort_order, r.room_no, f.sort_order, f.floor, g.gatar_no`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectGatars(rows)
+}
+
+// AddGatars inserts gatars from..to on a floor.
+// Returns ErrLayoutConflict if any number in the range already exists anywhere in the building.
+func (r *WarehouseLayoutRepository) AddGatars(ctx context.Context, floorID int, req *models.AddWarehouseGatarsRequest) (int64, error) {
+	result, err := r.DB.Exec(ctx,
+		`INSERT INTO warehouse_gatars (floor_id, gatar_no, capacity_bags, length_ft, width_ft, height_ft)
+		 SELECT $1::int, g, $4::int, $5::numeric, $6::numeric, $7::numeric FROM generate_series($2::int, $3::int) AS g`,
+		floorID, req.FromGatar, req.ToGatar, req.CapacityBags, req.LengthFt, req.WidthFt, req.HeightFt,
+	)
+	if isUniqueViolation(err) {
+		return 0, fmt.Errorf("%w: gatars %d-%d overlap existing gatar numbers", ErrLayoutConflict, req.FromGatar, req.ToGatar)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// UpdateGatars applies the non-nil fields of req to the floor's gatars in from..to
+func (r *WarehouseLayoutRepository) UpdateGatars(ctx context.Context, floorID int, req *models.UpdateWarehouseGatarsRequest) (int64, error) {
+	result, err := r.DB.Exec(ctx,
+		`UPDATE warehouse_gatars
+		 SET capacity_bags   = COALESCE($4, capacity_bags),
+		     length_ft       = COALESCE($5, length_ft),
+		     width_ft        = COALESCE($6, width_ft),
+		     height_ft       = COALESCE($7, height_ft),
+		     is_disabled     = COALESCE($8, is_disabled),
+		     disabled_reason = CASE WHEN $8::boolean = FALSE THEN '' ELSE COALESCE($9, disabled_reason) END,
+		     notes           = COALESCE($10, notes),
+		     updated_at      = NOW()
+		 WHERE floor_id = $1 AND gatar_no BETWEEN $2 AND $3`,
+		floorID, req.FromGatar, req.ToGatar, req.CapacityBags, req.LengthFt, req.WidthFt, req.HeightFt,
+		req.IsDisabled, req.DisabledReason, req.Notes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeleteGatars removes the floor's gatars in from..to
+func (r *WarehouseLayoutRepository) DeleteGatars(ctx context.Context, floorID, from, to int) (int64, error) {
+	result, err := r.DB.Exec(ctx,
+		`DELETE FROM warehouse_gatars WHERE floor_id = $1 AND gatar_no BETWEEN $2 AND $3`, floorID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// CountRoomEntries counts room entries stored in a room, optionally limited to a floor
+// and to gatar numbers from..to (0, 0 means any gatar)
+func (r *WarehouseLayoutRepository) CountRoomEntries(ctx context.Context, roomNo, floor string, from, to int) (int, error) {
+	var count int
+	err := r.DB.QueryRow(ctx,
+		`SELECT COUNT(*) FROM room_entries re
+		 WHERE re.room_no = $1
+		   AND ($2 = '' OR re.floor = $2)
+		   AND ($3 = 0 OR EXISTS (
+		       SELECT 1 FROM unnest(string_to_array(replace(re.gate_no, ' ', ''), ',')) AS g
+		       WHERE g ~ '^[0-9]+$' AND g::int BETWEEN $3 AND $4))`,
+		roomNo, floor, from, to,
+	).Scan(&count)
+	return count, err
+}
+
+// TotalCapacity returns the bag capacity of all enabled gatars in active rooms
+func (r *WarehouseLayoutRepository) TotalCapacity(ctx context.Context) (int, error) {
+	var total int
+	err := r.DB.QueryRow(ctx,
+		`SELECT COALESCE(SUM(g.capacity_bags), 0)
+		 FROM warehouse_gatars g
+		 JOIN warehouse_floors f ON f.id = g.floor_id
+		 JOIN warehouse_rooms r ON r.id = f.room_id
+		 WHERE r.is_active AND NOT g.is_disabled`,
+	).Scan(&total)
+	return total, err
+}
+
+func scanFloor(row pgx.Row) (*models.WarehouseFloor, error) {
+	f := &models.WarehouseFloor{}
+	err := row.Scan(&f.ID, &f.RoomID, &f.RoomNo, &f.Floor, &f.SortOrder,
+		&f.TotalGatars, &f.DisabledGatars, &f.CapacityBags)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func collectGatars(rows pgx.Rows) ([]*models.WarehouseGatar, error) {
+	var gatars []*models.WarehouseGatar
+	for rows.Next() {
+		g := &models.WarehouseGatar{}
+		if err := rows.Scan(&g.ID, &g.FloorID, &g.RoomNo, &g.Floor, &g.GatarNo, &g.CapacityBags,
+			&g.LengthFt, &g.WidthFt, &g.HeightFt, &g.IsDisabled, &g.DisabledReason, &g.Notes, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		gatars = append(gatars, g)
+	}
+	return gatars, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
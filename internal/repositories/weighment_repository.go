@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"cold-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrWeighmentNotFound is returned when a weighment does not exist
+var ErrWeighmentNotFound = errors.New("weighment not found")
+
+type WeighmentRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewWeighmentRepository(db *pgxpool.Pool) *WeighmentRepository {
+	return &WeighmentRepository{DB: db}
+}
+
+const weighmentSelect = `
+	SELECT id, direction, guard_entry_id, gate_pass_id, gate_pass_pickup_id, thock_number, vehicle_no, bag_count,
+	       gross_kg::float8, gross_source, gross_at, gross_by_user_id,
+	       tare_kg::float8, tare_source, tare_at, tare_by_user_id,
+	       net_kg::float8, notes, created_by_user_id, created_at, updated_at
+	FROM weighments`
+
+func scanWeighment(row pgx.Row) (*models.Weighment, error) {
+	w := &models.Weighment{}
+	err := row.Scan(&w.ID, &w.Direction, &w.GuardEntryID, &w.GatePassID, &w.GatePassPickupID, &w.ThockNumber, &w.VehicleNo, &w.BagCount,
+		&w.GrossKg, &w.GrossSource, &w.GrossAt, &w.GrossByUserID,
+		&w.TareKg, &w.TareSource, &w.TareAt, &w.TareByUserID,
+		&w.NetKg, &w.Notes, &w.CreatedByUserID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if w.NetKg != nil && w.BagCount > 0 {
+		avg := *w.NetKg / float64(w.BagCount)
+		w.AvgBagWeightKg = &avg
+	}
+	return w, nil
+}
+
+// Create opens a weighment
+func (r *WeighmentRepository) Create(ctx context.Context, w *models.Weighment) error {
+	return r.DB.QueryRow(ctx,
+		`INSERT INTO weighments (direction, guard_entry_id, gate_pass_id, thock_number, vehicle_no, bag_count, notes, created_by_user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id, created_at, updated_at`,
+		w.Direction, w.GuardEntryID, w.GatePassID, w.ThockNumber, w.VehicleNo, w.BagCount, w.Notes, w.CreatedByUserID,
+	).Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+}
+
+// Get returns one weighment
+func (r *WeighmentRepository) Get(ctx context.Context, id int) (*models.Weighment, error) {
+	w, err := scanWeighment(r.DB.QueryRow(ctx, weighmentSelect+` WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWeighmentNotFound
+	}
+	return w, err
+}
+
+// GetByGuardEntry returns the inbound weighment of a guard entry
+func (r *WeighmentRepository) GetByGuardEntry(ctx context.Context, guardEntryID int) (*models.Weighment, error) {
+	w, err := scanWeighment(r.DB.QueryRow(ctx, weighmentSelect+` WHERE guard_entry_id = $1`, guardEntryID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWeighmentNotFound
+	}
+	return w, err
+}
+
+// List returns weighments, newest first. Inbound weighments match a thock through the
+// entries linked to them; direction and thockNumber "" match all, gatePassID 0 matches all.
+func (r *WeighmentRepository) List(ctx context.Context, direction, thockNumber string, gatePassID, limit int) ([]*models.Weighment, error) {
+	rows, err := r.DB.Query(ctx, weighmentSelect+`
+		WHERE ($1 = '' OR direction = $1)
+		  AND ($2 = '' OR thock_number = $2
+		       OR id IN (SELECT inbound_weighment_id FROM entries WHERE thock_number = $2))
+		  AND ($3::int = 0 OR gate_pass_id = $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4`, direction, thockNumber, gatePassID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var weighments []*models.Weighment
+	for rows.Next() {
+		w, err := scanWeighment(rows)
+		if err != nil {
+			return nil, err
+		}
+		weighments = append(weighments, w)
+	}
+	return weighments, rows.Err()
+}
+
+// SetWeight records the gross or tare weight
+func (r *WeighmentRepository) SetWeight(ctx context.Context, id int, stage string, weightKg float64, source string, userID int) error {
+	query := `UPDATE weighments SET gross_kg = $2, gross_source = $3, gross_at = NOW(), gross_by_user_id = $4, updated_at = NOW() WHERE id = $1`
+	if stage == models.WeighmentStageTare {
+		query = `UPDATE weighments SET tare_kg = $2, tare_source = $3, tare_at = NOW(), tare_by_user_id = $4, updated_at = NOW() WHERE id = $1`
+	}
+	tag, err := r.DB.Exec(ctx, query, id, weightKg, source, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWeighmentNotFound
+	}
+	return nil
+}
+
+// AttachPickup ties an outbound weighment to the pickup it weighed
+func (r *WeighmentRepository) AttachPickup(ctx context.Context, id, pickupID, bags int) error {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE weighments SET gate_pass_pickup_id = $2, bag_count = $3, updated_at = NOW()
+		 WHERE id = $1 AND direction = 'outbound'`,
+		id, pickupID, bags)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWeighmentNotFound
+	}
+	return nil
+}
+
+// LinkEntry stores the inbound weighment's average bag weight on a thock's entry,
+// along with the thock's net weight at that average. Returns false if the thock has no entry.
+func (r *WeighmentRepository) LinkEntry(ctx context.Context, weighmentID int, thockNumber string, avgBagWeightKg float64) (bool, error) {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE entries
+		 SET inbound_weighment_id = $1, avg_bag_weight_kg = $3, net_weight_kg = ROUND(($3::numeric * expected_quantity), 2),
+		     updated_at = NOW()
+		 WHERE thock_number = $2 AND COALESCE(status, 'active') != 'deleted'`,
+		weighmentID, thockNumber, avgBagWeightKg)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListLinkedThocks returns the thocks whose inbound weight came from a weighment
+func (r *WeighmentRepository) ListLinkedThocks(ctx context.Context, weighmentID int) ([]string, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT thock_number FROM entries WHERE inbound_weighment_id = $1 ORDER BY thock_number`, weighmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var thocks []string
+	for rows.Next() {
+		var thock string
+		if err := rows.Scan(&thock); err != nil {
+			return nil, err
+		}
+		thocks = append(thocks, thock)
+	}
+	return thocks, rows.Err()
+}
+
+// ThockWeights returns the inbound and outbound weights of the given thocks
+func (r *WeighmentRepository) ThockWeights(ctx context.Context, thockNumbers []string) ([]*models.ThockWeight, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT e.thock_number, e.inbound_weighment_id, e.expected_quantity,
+		       e.net_weight_kg::float8, e.avg_bag_weight_kg::float8,
+		       COALESCE(o.bags, 0), COALESCE(o.net_kg, 0), COALESCE(o.weighings, 0)
+		FROM entries e
+		LEFT JOIN (
+			SELECT thock_number, SUM(bag_count)::int AS bags, SUM(net_kg)::float8 AS net_kg, COUNT(*)::int AS weighings
+			FROM weighments
+			WHERE direction = 'outbound' AND net_kg IS NOT NULL
+			GROUP BY thock_number
+		) o ON o.thock_number = e.thock_number
+		WHERE e.thock_number = ANY($1::text[]) AND COALESCE(e.status, 'active') != 'deleted'
+		ORDER BY e.thock_number`, thockNumbers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var weights []*models.ThockWeight
+	for rows.Next() {
+		t := &models.ThockWeight{}
+		err := rows.Scan(&t.ThockNumber, &t.InboundWeighment, &t.BagsIn, &t.NetWeightInKg, &t.AvgBagWeightKg,
+			&t.BagsOut, &t.NetWeightOutKg, &t.OutboundWeighings)
+		if err != nil {
+			return nil, err
+		}
+		weights = append(weights, t)
+	}
+	return weights, rows.Err()
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// AccountingExportService turns the customer ledger into vouchers the
+// accountant can import into Tally (XML) or any other package (CSV journal).
+// Vouchers use the journal's accounts (models.JournalAccounts), with Customer
+// Receivables as the party ledger:
+//
+//	PAYMENT         Receipt      Dr Cash           / Cr Party
+//	ONLINE_PAYMENT  Receipt      Dr Bank           / Cr Party
+//	CHARGE          Sales        Dr Party          / Cr Rent (or Interest) Income
+//	CREDIT          Credit Note  Dr Discount       / Cr Party
+//	REFUND          Payment      Dr Party          / Cr Refunds
+type AccountingExportService struct {
+	LedgerRepo      *repositories.LedgerRepository
+	RentPaymentRepo *repositories.RentPaymentRepository
+	SettingsRepo    *repositories.SystemSettingRepository
+}
+
+func NewAccountingExportService(ledgerRepo *repositories.LedgerRepository, rentPaymentRepo *repositories.RentPaymentRepository, settingsRepo *repositories.SystemSettingRepository) *AccountingExportService {
+	return &AccountingExportService{
+		LedgerRepo:      ledgerRepo,
+		RentPaymentRepo: rentPaymentRepo,
+		SettingsRepo:    settingsRepo,
+	}
+}
+
+// GetLedgerNames reads the Tally ledger-name mapping from system settings
+func (s *AccountingExportService) GetLedgerNames(ctx context.Context) *models.AccountingLedgerNames {
+	setting := func(key, def string) string {
+		st, err := s.SettingsRepo.Get(ctx, key)
+		if err != nil || st == nil || strings.TrimSpace(st.SettingValue) == "" {
+			return def
+		}
+		return strings.TrimSpace(st.SettingValue)
+	}
+
+	return &models.AccountingLedgerNames{
+		Company:        setting("tally_company_name", setting("company_name", "")),
+		Cash:           setting("tally_ledger_cash", "Cash"),
+		Bank:           setting("tally_ledger_bank", "Bank"),
+		BankAccount:    setting("tally_ledger_bank_account", setting("tally_ledger_bank", "Bank")),
+		RentIncome:     setting("tally_ledger_rent_income", "Cold Storage Rent"),
+		InterestIncome: setting("tally_ledger_interest_income", "Interest on Late Payment"),
+		Discount:       setting("tally_ledger_discount", "Discount Allowed"),
+		Refunds:        setting("tally_ledger_refunds", "Refunds"),
+		DebtorsGroup:   setting("tally_debtors_group", "Sundry Debtors"),
+		PartyFormat:    setting("tally_party_ledger_format", "{name} ({phone})"),
+	}
+}
+
+// GetVouchers returns the vouchers for ledger entries posted between two IST dates (inclusive)
+func (s *AccountingExportService) GetVouchers(ctx context.Context, from, to time.Time, names *models.AccountingLedgerNames) ([]models.AccountingVoucher, error) {
+	entries, err := s.LedgerRepo.GetPostedBetween(ctx, timeutil.StartOfDay(from), nextDay(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger entries: %w", err)
+	}
+
+	// Receipts are numbered with the rent payment's receipt number
+	var paymentIDs []int
+	for _, e := range entries {
+		if e.ReferenceType == "payment" && e.ReferenceID != nil {
+			paymentIDs = append(paymentIDs, *e.ReferenceID)
+		}
+	}
+	receipts, err := s.RentPaymentRepo.GetReceiptNumbers(ctx, paymentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load receipt numbers: %w", err)
+	}
+
+	vouchers := make([]models.AccountingVoucher, 0, len(entries))
+	for _, e := range entries {
+		party := partyLedgerName(names.PartyFormat, e)
+		v := models.AccountingVoucher{
+			LedgerEntryID: e.ID,
+			Date:          timeutil.ToIST(e.CreatedAt),
+			VoucherNumber: fmt.Sprintf("LE-%d", e.ID),
+			PartyLedger:   party,
+			CustomerPhone: e.CustomerPhone,
+			Narration:     e.Description,
+			Amount:        e.Debit + e.Credit,
+		}
+		if e.Notes != "" {
+			v.Narration += " | " + e.Notes
+		}
+
+		debitAccount, creditAccount, ok := models.JournalAccounts(e.EntryType, e.ReferenceType)
+		if !ok {
+			continue // DEBT_APPROVAL carries no amount
+		}
+		v.DebitLedger, v.CreditLedger = names.LedgerFor(debitAccount, party), names.LedgerFor(creditAccount, party)
+		switch e.EntryType {
+		case models.LedgerEntryTypePayment, models.LedgerEntryTypeOnlinePayment:
+			v.VoucherType = models.VoucherTypeReceipt
+		case models.LedgerEntryTypeCharge:
+			v.VoucherType = models.VoucherTypeSales
+		case models.LedgerEntryTypeCredit:
+			v.VoucherType = models.VoucherTypeCreditNote
+		case models.LedgerEntryTypeRefund:
+			v.VoucherType = models.VoucherTypePayment
+		}
+
+		if e.ReferenceType == "payment" && e.ReferenceID != nil && receipts[*e.ReferenceID] != "" {
+			v.VoucherNumber = receipts[*e.ReferenceID]
+		}
+		vouchers = append(vouchers, v)
+	}
+	return vouchers, nil
+}
+
+// partyLedgerName fills the party ledger format for a ledger entry's customer
+func partyLedgerName(format string, e models.LedgerEntry) string {
+	name := strings.NewReplacer(
+		"{name}", e.CustomerName,
+		"{phone}", e.CustomerPhone,
+		"{so}", e.CustomerSO,
+	).Replace(format)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// GenerateJournalCSV renders vouchers as a generic two-line-per-voucher journal
+func (s *AccountingExportService) GenerateJournalCSV(vouchers []models.AccountingVoucher) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	writer.Write([]string{"Date", "Voucher Type", "Voucher No", "Ledger", "Debit", "Credit", "Narration", "Customer Phone", "Ledger Entry ID"})
+	for _, v := range vouchers {
+		date := v.Date.Format("2006-01-02")
+		amount := fmt.Sprintf("%.2f", v.Amount)
+		entryID := fmt.Sprintf("%d", v.LedgerEntryID)
+		writer.Write([]string{date, v.VoucherType, v.VoucherNumber, v.DebitLedger, amount, "", v.Narration, v.CustomerPhone, entryID})
+		writer.Write([]string{date, v.VoucherType, v.VoucherNumber, v.CreditLedger, "", amount, v.Narration, v.CustomerPhone, entryID})
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// Tally import envelope (Import Data / Vouchers)
+type tallyEnvelope struct {
+	XMLName      xml.Name `xml:"ENVELOPE"`
+	TallyRequest string   `xml:"HEADER>TALLYREQUEST"`
+	ImportData   struct {
+		ReportName   string         `xml:"REQUESTDESC>REPORTNAME"`
+		Company      string         `xml:"REQUESTDESC>STATICVARIABLES>SVCURRENTCOMPANY,omitempty"`
+		TallyMessage []tallyMessage `xml:"REQUESTDATA>TALLYMESSAGE"`
+	} `xml:"BODY>IMPORTDATA"`
+}
+
+type tallyMessage struct {
+	UDF     string        `xml:"xmlns:UDF,attr"`
+	Ledger  *tallyLedger  `xml:"LEDGER,omitempty"`
+	Voucher *tallyVoucher `xml:"VOUCHER,omitempty"`
+}
+
+type tallyLedger struct {
+	Name         string `xml:"NAME,attr"`
+	Action       string `xml:"ACTION,attr"`
+	NameList     string `xml:"NAME.LIST>NAME"`
+	Parent       string `xml:"PARENT"`
+	IsBillWiseOn string `xml:"ISBILLWISEON"`
+}
+
+type tallyVoucher struct {
+	RemoteID        string             `xml:"REMOTEID,attr"`
+	VchType         string             `xml:"VCHTYPE,attr"`
+	Action          string             `xml:"ACTION,attr"`
+	Date            string             `xml:"DATE"`
+	VoucherTypeName string             `xml:"VOUCHERTYPENAME"`
+	VoucherNumber   string             `xml:"VOUCHERNUMBER"`
+	PartyLedgerName string             `xml:"PARTYLEDGERNAME"`
+	Narration       string             `xml:"NARRATION"`
+	LedgerEntries   []tallyLedgerEntry `xml:"ALLLEDGERENTRIES.LIST"`
+}
+
+type tallyLedgerEntry struct {
+	LedgerName       string `xml:"LEDGERNAME"`
+	IsDeemedPositive string `xml:"ISDEEMEDPOSITIVE"`
+	Amount           string `xml:"AMOUNT"`
+}
+
+// GenerateTallyXML renders vouchers as a Tally "Import Data" envelope.
+// With masters the party ledgers are created under the debtors group first.
+// Each voucher carries a REMOTEID from its ledger entry, so importing the same
+// range twice is rejected by Tally instead of duplicating vouchers.
+func (s *AccountingExportService) GenerateTallyXML(vouchers []models.AccountingVoucher, names *models.AccountingLedgerNames, masters bool) ([]byte, error) {
+	var env tallyEnvelope
+	env.TallyRequest = "Import Data"
+	env.ImportData.ReportName = "Vouchers"
+	env.ImportData.Company = names.Company
+
+	if masters {
+		parties := make(map[string]bool)
+		for _, v := range vouchers {
+			parties[v.PartyLedger] = true
+		}
+		sorted := make([]string, 0, len(parties))
+		for party := range parties {
+			sorted = append(sorted, party)
+		}
+		sort.Strings(sorted)
+
+		env.ImportData.ReportName = "All Masters"
+		for _, party := range sorted {
+			env.ImportData.TallyMessage = append(env.ImportData.TallyMessage, tallyMessage{
+				UDF: "TallyUDF",
+				Ledger: &tallyLedger{
+					Name:         party,
+					Action:       "Create",
+					NameList:     party,
+					Parent:       names.DebtorsGroup,
+					IsBillWiseOn: "No",
+				},
+			})
+		}
+	}
+
+	for _, v := range vouchers {
+		amount := fmt.Sprintf("%.2f", v.Amount)
+		env.ImportData.TallyMessage = append(env.ImportData.TallyMessage, tallyMessage{
+			UDF: "TallyUDF",
+			Voucher: &tallyVoucher{
+				RemoteID:        fmt.Sprintf("cold-ledger-%d", v.LedgerEntryID),
+				VchType:         v.VoucherType,
+				Action:          "Create",
+				Date:            v.Date.Format("20060102"),
+				VoucherTypeName: v.VoucherType,
+				VoucherNumber:   v.VoucherNumber,
+				PartyLedgerName: v.PartyLedger,
+				Narration:       v.Narration,
+				LedgerEntries: []tallyLedgerEntry{
+					// Tally: debits are negative and deemed positive
+					{LedgerName: v.DebitLedger, IsDeemedPositive: "Yes", Amount: "-" + amount},
+					{LedgerName: v.CreditLedger, IsDeemedPositive: "No", Amount: amount},
+				},
+			},
+		})
+	}
+
+	out, err := xml.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// approvalSweepInterval is how often expired and settled approval requests are closed
+const approvalSweepInterval = time.Minute
+
+var (
+	// ErrInvalidApproval is returned for a decision missing what the policy asks for, or a bad policy
+	ErrInvalidApproval = errors.New("invalid approval")
+	// ErrApprovalForbidden is returned when the user may not decide on the request
+	ErrApprovalForbidden = errors.New("not allowed to approve this request")
+	// ErrApprovalCredentials is returned for a wrong password or 2FA code
+	ErrApprovalCredentials = errors.New("approval not confirmed")
+)
+
+// ApprovalExpiry expires a flow's subject when its approval request runs out of time
+type ApprovalExpiry func(ctx context.Context, subjectID int) error
+
+// ApprovalService decides approvals for gate passes, debts, protected settings and seasons
+// under configurable policies. Each flow asks it for a decision before it acts: Approve
+// records the approver's decision and reports the request approved once the policy's number
+// of approvals is reached; Reject closes it.
+type ApprovalService struct {
+	Repo     *repositories.ApprovalRepository
+	UserRepo *repositories.UserRepository
+	TOTP     *TOTPService
+	expiries map[string]ApprovalExpiry
+	stopCh   chan struct{}
+}
+
+func NewApprovalService(repo *repositories.ApprovalRepository, userRepo *repositories.UserRepository, totp *TOTPService) *ApprovalService {
+	return &ApprovalService{
+		Repo:     repo,
+		UserRepo: userRepo,
+		TOTP:     totp,
+		expiries: make(map[string]ApprovalExpiry),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// OnExpiry sets what happens to a flow's subject when its approval request expires. Flows
+// without one just start a fresh approval request on the next decision.
+func (s *ApprovalService) OnExpiry(flow string, expire ApprovalExpiry) {
+	s.expiries[flow] = expire
+}
+
+// Start sweeps approval requests in the background
+func (s *ApprovalService) Start() {
+	go func() {
+		log.Println("[Approval] Sweeper started")
+		ticker := time.NewTicker(approvalSweepInterval)
+		defer ticker.Stop()
+		for {
+			s.Sweep(context.Background())
+			select {
+			case <-s.stopCh:
+				log.Println("[Approval] Sweeper stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the background sweeper
+func (s *ApprovalService) Stop() {
+	close(s.stopCh)
+}
+
+// Sweep expires approval requests past their time and closes those whose subject was
+// settled elsewhere
+func (s *ApprovalService) Sweep(ctx context.Context) {
+	expired, err := s.Repo.ExpireDue(ctx)
+	if err != nil {
+		log.Printf("[Approval] Failed to expire approval requests: %v", err)
+	}
+	for _, a := range expired {
+		if expire := s.expiries[a.Flow]; expire != nil {
+			if err := expire(ctx, a.SubjectID); err != nil {
+				log.Printf("[Approval] Failed to expire %s %d: %v", a.Flow, a.SubjectID, err)
+			}
+		}
+	}
+	if err := s.Repo.CancelSettled(ctx); err != nil {
+		log.Printf("[Approval] Failed to close settled approval requests: %v", err)
+	}
+}
+
+// Submit opens the approval request of a subject, or returns the one already open
+func (s *ApprovalService) Submit(ctx context.Context, subject *models.ApprovalSubject) (*models.ApprovalRequest, error) {
+	return s.openFor(ctx, subject, true)
+}
+
+// openFor returns the subject's approval request, opening one if needed. With reprice an open
+// request takes the subject's amount and terms.
+func (s *ApprovalService) openFor(ctx context.Context, subject *models.ApprovalSubject, reprice bool) (*models.ApprovalRequest, error) {
+	latest, err := s.Repo.GetLatestForSubject(ctx, subject.Flow, subject.SubjectID)
+	if err != nil && !errors.Is(err, repositories.ErrApprovalNotFound) {
+		return nil, err
+	}
+	if latest != nil {
+		switch latest.Status {
+		case models.ApprovalStatusPending:
+			if !reprice {
+				return latest, nil
+			}
+			return s.reprice(ctx, latest, subject)
+		case models.ApprovalStatusApproved:
+			// Approved, but the flow failed to act on it; a retry reuses the approval unless
+			// it asks for more or on other terms than were approved
+			if subject.Amount <= latest.Amount && subject.Terms == latest.Terms {
+				return latest, nil
+			}
+		case models.ApprovalStatusExpired:
+			if s.expiries[subject.Flow] != nil {
+				return nil, repositories.ErrApprovalExpired
+			}
+		}
+	}
+
+	policy, err := s.Repo.ResolvePolicy(ctx, subject.Flow, subject.Amount)
+	if err != nil {
+		return nil, err
+	}
+	approval := &models.ApprovalRequest{
+		Flow:              subject.Flow,
+		SubjectID:         subject.SubjectID,
+		Summary:           subject.Summary,
+		Terms:             subject.Terms,
+		Amount:            subject.Amount,
+		PolicyID:          policy.ID,
+		RequestedByUserID: subject.RequestedBy,
+	}
+	err = s.Repo.Open(ctx, approval)
+	if errors.Is(err, repositories.ErrApprovalOpen) {
+		// Opened by another approver at the same moment
+		return s.Repo.GetLatestForSubject(ctx, subject.Flow, subject.SubjectID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.Get(ctx, approval.ID)
+}
+
+// reprice moves an open request to a new amount or terms and the policy tier for them. The
+// approvals already given were for something else, so they no longer count.
+func (s *ApprovalService) reprice(ctx context.Context, approval *models.ApprovalRequest, subject *models.ApprovalSubject) (*models.ApprovalRequest, error) {
+	if subject.Amount == approval.Amount && subject.Terms == approval.Terms {
+		return approval, nil
+	}
+	policy, err := s.Repo.ResolvePolicy(ctx, subject.Flow, subject.Amount)
+	if err != nil {
+		return nil, err
+	}
+	approval.Amount = subject.Amount
+	approval.Summary = subject.Summary
+	approval.Terms = subject.Terms
+	approval.PolicyID = policy.ID
+	if err := s.Repo.Reprice(ctx, approval); err != nil {
+		return nil, err
+	}
+	return s.Repo.Get(ctx, approval.ID)
+}
+
+// Approve records a user's approval of a subject and returns its approval request, which is
+// approved once enough approvers have agreed
+func (s *ApprovalService) Approve(ctx context.Context, subject *models.ApprovalSubject, userID int, creds *models.ApprovalCredentials) (*models.ApprovalRequest, error) {
+	if creds == nil {
+		creds = &models.ApprovalCredentials{}
+	}
+	approval, err := s.openFor(ctx, subject, true)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.UserRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user not found", ErrApprovalForbidden)
+	}
+	if err := checkApprover(approval, user); err != nil {
+		return nil, err
+	}
+	if approval.Status == models.ApprovalStatusApproved {
+		return approval, nil
+	}
+	passwordOK, totpOK, err := s.confirm(ctx, approval.Policy, user, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.Decide(ctx, &models.ApprovalDecision{
+		ApprovalRequestID: approval.ID,
+		UserID:            userID,
+		Decision:          models.ApprovalDecisionApprove,
+		PasswordVerified:  passwordOK,
+		TOTPVerified:      totpOK,
+		IPAddress:         creds.IPAddress,
+	}, approval)
+}
+
+// Reject closes a subject's approval request as rejected. Any approver, or the requester
+// withdrawing, can reject.
+func (s *ApprovalService) Reject(ctx context.Context, subject *models.ApprovalSubject, userID int, reason, ipAddress string) (*models.ApprovalRequest, error) {
+	approval, err := s.openFor(ctx, subject, false)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.UserRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user not found", ErrApprovalForbidden)
+	}
+	isRequester := approval.RequestedByUserID != nil && *approval.RequestedByUserID == userID
+	if err := checkApprover(approval, user); err != nil && !isRequester {
+		return nil, err
+	}
+	if approval.Status == models.ApprovalStatusApproved {
+		// Nothing left to decide; the flow rejects its subject itself
+		return approval, nil
+	}
+
+	return s.Repo.Decide(ctx, &models.ApprovalDecision{
+		ApprovalRequestID: approval.ID,
+		UserID:            userID,
+		Decision:          models.ApprovalDecisionReject,
+		Comment:           reason,
+		IPAddress:         ipAddress,
+	}, nil)
+}
+
+func containsInt(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkApprover checks the user's role, panel membership and self-approval against the policy
+func checkApprover(approval *models.ApprovalRequest, user *models.User) error {
+	policy := approval.Policy
+	if !user.IsActive {
+		return fmt.Errorf("%w: your account is inactive", ErrApprovalForbidden)
+	}
+	roleOK := false
+	for _, role := range policy.ApproverRoles {
+		if role == user.Role {
+			roleOK = true
+			break
+		}
+	}
+	if !roleOK {
+		return fmt.Errorf("%w: only %s can approve this", ErrApprovalForbidden, strings.Join(policy.ApproverRoles, " or "))
+	}
+	if len(policy.ApproverUserIDs) > 0 && !containsInt(policy.ApproverUserIDs, user.ID) {
+		return fmt.Errorf("%w: you are not on the approver panel for this", ErrApprovalForbidden)
+	}
+	if approval.RequestedByUserID != nil && *approval.RequestedByUserID == user.ID &&
+		!policy.AllowSelfApproval && !containsInt(policy.SelfApprovalUserIDs, user.ID) {
+		return fmt.Errorf("%w: you cannot approve your own request, another approver must", ErrApprovalForbidden)
+	}
+	return nil
+}
+
+// confirm checks the approver's password and 2FA code when the policy asks for them
+func (s *ApprovalService) confirm(ctx context.Context, policy *models.ApprovalPolicy, user *models.User, creds *models.ApprovalCredentials) (passwordOK, totpOK bool, err error) {
+	if policy.RequirePassword {
+		if creds.Password == "" {
+			return false, false, fmt.Errorf("%w: password is required to approve", ErrInvalidApproval)
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)) != nil {
+			return false, false, fmt.Errorf("%w: invalid password", ErrApprovalCredentials)
+		}
+		passwordOK = true
+	}
+
+	needTOTP := policy.TOTPMode == models.ApprovalTOTPRequired ||
+		(policy.TOTPMode == models.ApprovalTOTPIfEnrolled && user.TOTPEnabled)
+	if policy.TOTPMode == models.ApprovalTOTPRequired && !user.TOTPEnabled {
+		return false, false, fmt.Errorf("%w: enable 2FA to approve this", ErrApprovalForbidden)
+	}
+	if !needTOTP {
+		return passwordOK, false, nil
+	}
+	if creds.TOTPCode == "" {
+		return false, false, fmt.Errorf("%w: 2FA code is required to approve", ErrInvalidApproval)
+	}
+	if s.TOTP == nil {
+		return false, false, fmt.Errorf("%w: 2FA is not available", ErrInvalidApproval)
+	}
+	valid, err := s.TOTP.Verify(ctx, user.ID, creds.TOTPCode, creds.IPAddress)
+	if err != nil {
+		var totpErr *TOTPError
+		if errors.As(err, &totpErr) {
+			return false, false, fmt.Errorf("%w: %v", ErrInvalidApproval, err)
+		}
+		return false, false, err
+	}
+	if !valid {
+		return false, false, fmt.Errorf("%w: invalid 2FA code", ErrApprovalCredentials)
+	}
+	return passwordOK, true, nil
+}
+
+// Inbox returns the approval requests waiting for the user's decision
+func (s *ApprovalService) Inbox(ctx context.Context, userID int, role string) ([]*models.ApprovalRequest, error) {
+	return s.Repo.Inbox(ctx, userID, role)
+}
+
+// List returns recent approval requests, optionally of one flow and status
+func (s *ApprovalService) List(ctx context.Context, flow, status string, limit int) ([]*models.ApprovalRequest, error) {
+	return s.Repo.List(ctx, flow, status, limit)
+}
+
+// Get returns an approval request with its decisions
+func (s *ApprovalService) Get(ctx context.Context, id int) (*models.ApprovalRequest, error) {
+	return s.Repo.Get(ctx, id)
+}
+
+// ListPolicies returns the policies of a flow, or of all flows when flow is empty
+func (s *ApprovalService) ListPolicies(ctx context.Context, flow string) ([]*models.ApprovalPolicy, error) {
+	return s.Repo.ListPolicies(ctx, flow)
+}
+
+func validApprovalFlow(flow string) bool {
+	switch flow {
+	case models.ApprovalFlowGatePass, models.ApprovalFlowDebt, models.ApprovalFlowSetting, models.ApprovalFlowSeason:
+		return true
+	}
+	return false
+}
+
+// protectedApprovalFloor is the least a protected flow's policy may ask of its approvers
+type protectedApprovalFloor struct {
+	selfApprovers []int // The only users who may approve their own requests
+	totp          bool  // 2FA at least for approvers who enrolled
+}
+
+// protectedApprovalFlows keep the rules protected settings and seasons had before the approval
+// engine: another admin confirming with their password (and 2FA for settings); user 2 could
+// approve their own season request
+var protectedApprovalFlows = map[string]protectedApprovalFloor{
+	models.ApprovalFlowSetting: {totp: true},
+	models.ApprovalFlowSeason:  {selfApprovers: []int{2}},
+}
+
+// checkProtectedPolicy keeps protected setting and season policies from allowing self-approval
+// or approval without a password, so one admin cannot approve their own change
+func checkProtectedPolicy(req *models.ApprovalPolicyRequest) error {
+	floor, protected := protectedApprovalFlows[req.Flow]
+	if !protected {
+		return nil
+	}
+	if req.AllowSelfApproval {
+		return fmt.Errorf("%w: %s approvals cannot allow self-approval", ErrInvalidApproval, req.Flow)
+	}
+	for _, id := range req.SelfApprovalUserIDs {
+		if !containsInt(floor.selfApprovers, id) {
+			return fmt.Errorf("%w: %s approvals cannot add self-approvers", ErrInvalidApproval, req.Flow)
+		}
+	}
+	if !req.RequirePassword {
+		return fmt.Errorf("%w: %s approvals must require the approver's password", ErrInvalidApproval, req.Flow)
+	}
+	if floor.totp && req.TOTPMode == models.ApprovalTOTPNone {
+		return fmt.Errorf("%w: %s approvals must ask enrolled approvers for their 2FA code", ErrInvalidApproval, req.Flow)
+	}
+	return nil
+}
+
+// approvalPolicyFromRequest validates a policy request and turns it into a policy
+func approvalPolicyFromRequest(req *models.ApprovalPolicyRequest, userID int) (*models.ApprovalPolicy, error) {
+	if !validApprovalFlow(req.Flow) {
+		return nil, fmt.Errorf("%w: unknown flow %q", ErrInvalidApproval, req.Flow)
+	}
+	if req.MinAmount < 0 {
+		return nil, fmt.Errorf("%w: min_amount cannot be negative", ErrInvalidApproval)
+	}
+	if req.RequiredApprovals < 1 {
+		return nil, fmt.Errorf("%w: at least one approval is required", ErrInvalidApproval)
+	}
+	var roles []string
+	for _, role := range req.ApproverRoles {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("%w: at least one approver role is required", ErrInvalidApproval)
+	}
+	if len(req.ApproverUserIDs) > 0 && req.RequiredApprovals > len(req.ApproverUserIDs) {
+		return nil, fmt.Errorf("%w: %d approvals required but only %d approvers on the panel", ErrInvalidApproval, req.RequiredApprovals, len(req.ApproverUserIDs))
+	}
+	switch req.TOTPMode {
+	case "":
+		req.TOTPMode = models.ApprovalTOTPNone
+	case models.ApprovalTOTPNone, models.ApprovalTOTPIfEnrolled, models.ApprovalTOTPRequired:
+	default:
+		return nil, fmt.Errorf("%w: totp_mode must be none, if_enrolled or required", ErrInvalidApproval)
+	}
+	if req.ExpiryHours < 0 {
+		return nil, fmt.Errorf("%w: expiry_hours cannot be negative", ErrInvalidApproval)
+	}
+	if err := checkProtectedPolicy(req); err != nil {
+		return nil, err
+	}
+
+	policy := &models.ApprovalPolicy{
+		Flow:                req.Flow,
+		MinAmount:           req.MinAmount,
+		RequiredApprovals:   req.RequiredApprovals,
+		ApproverRoles:       roles,
+		ApproverUserIDs:     req.ApproverUserIDs,
+		AllowSelfApproval:   req.AllowSelfApproval,
+		SelfApprovalUserIDs: req.SelfApprovalUserIDs,
+		RequirePassword:     req.RequirePassword,
+		TOTPMode:            req.TOTPMode,
+		ExpiryHours:         req.ExpiryHours,
+		IsActive:            req.IsActive,
+		UpdatedByUserID:     &userID,
+	}
+	if policy.ApproverUserIDs == nil {
+		policy.ApproverUserIDs = []int{}
+	}
+	if policy.SelfApprovalUserIDs == nil {
+		policy.SelfApprovalUserIDs = []int{}
+	}
+	return policy, nil
+}
+
+// CreatePolicy adds a policy tier to a flow from an amount upwards
+func (s *ApprovalService) CreatePolicy(ctx context.Context, req *models.ApprovalPolicyRequest, userID int) (*models.ApprovalPolicy, error) {
+	policy, err := approvalPolicyFromRequest(req, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.CreatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetPolicy(ctx, policy.ID)
+}
+
+// UpdatePolicy replaces a policy's rules. A flow's base tier (from 0) stays active at 0 so
+// every request has a policy.
+func (s *ApprovalService) UpdatePolicy(ctx context.Context, id int, req *models.ApprovalPolicyRequest, userID int) (*models.ApprovalPolicy, error) {
+	existing, err := s.Repo.GetPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	req.Flow = existing.Flow
+	policy, err := approvalPolicyFromRequest(req, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing.MinAmount == 0 && (policy.MinAmount != 0 || !policy.IsActive) {
+		return nil, fmt.Errorf("%w: the base policy of a flow must stay active at amount 0", ErrInvalidApproval)
+	}
+	policy.ID = id
+	if err := s.Repo.UpdatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetPolicy(ctx, id)
+}
+
+// approvalPending reports whether an approval request still waits for more approvers
+func approvalPending(approval *models.ApprovalRequest) bool {
+	return approval != nil && approval.Status != models.ApprovalStatusApproved
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// ErrInvalidBankLine is returned when a reconciliation action does not apply to a bank line
+var ErrInvalidBankLine = errors.New("invalid bank reconciliation")
+
+// BankReconciliationService imports bank statements and matches their credits
+// to rent payments, Razorpay transactions and receipt ledger entries.
+//
+// A credit is matched automatically when exactly one record carries its UTR,
+// or, failing that, exactly one record has the same amount within the date
+// window. Everything else waits in the reconciliation queue for the accountant.
+type BankReconciliationService struct {
+	Repo          *repositories.BankStatementRepository
+	LedgerService *LedgerService
+	CustomerRepo  *repositories.CustomerRepository
+	SettingsRepo  *repositories.SystemSettingRepository
+}
+
+func NewBankReconciliationService(
+	repo *repositories.BankStatementRepository,
+	ledgerService *LedgerService,
+	customerRepo *repositories.CustomerRepository,
+	settingsRepo *repositories.SystemSettingRepository,
+) *BankReconciliationService {
+	return &BankReconciliationService{
+		Repo:          repo,
+		LedgerService: ledgerService,
+		CustomerRepo:  customerRepo,
+		SettingsRepo:  settingsRepo,
+	}
+}
+
+// Import parses a statement file, stores its new credit lines and matches them
+func (s *BankReconciliationService) Import(ctx context.Context, fileName, bankAccount string, data []byte, userID int) (*models.BankStatementImportResult, error) {
+	lines, skipped, err := ParseBankStatement(fileName, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBankLine, err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: no credit lines found in %s", ErrInvalidBankLine, fileName)
+	}
+
+	imp := &models.BankStatementImport{
+		FileName:         fileName,
+		BankAccount:      strings.TrimSpace(bankAccount),
+		ImportedByUserID: userID,
+	}
+	seen := make(map[string]int)
+	for _, l := range lines {
+		if imp.StatementFrom == nil || l.TxnDate.Before(*imp.StatementFrom) {
+			imp.StatementFrom = &l.TxnDate
+		}
+		if imp.StatementTo == nil || l.TxnDate.After(*imp.StatementTo) {
+			imp.StatementTo = &l.TxnDate
+		}
+		l.LineHash = bankLineHash(imp.BankAccount, l, seen)
+	}
+
+	stored, err := s.Repo.CreateImport(ctx, imp, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := s.autoMatch(ctx, stored)
+	log.Printf("[BankRecon] Imported %s: %d credits (%d already imported), %d matched",
+		fileName, imp.LineCount, imp.DuplicateCount, matched)
+
+	return &models.BankStatementImportResult{
+		Import:    imp,
+		Skipped:   skipped,
+		Matched:   matched,
+		Unmatched: len(stored) - matched,
+	}, nil
+}
+
+// Rematch retries automatic matching of the unmatched lines of an import (0 = all imports),
+// e.g. after the missing payments were recorded
+func (s *BankReconciliationService) Rematch(ctx context.Context, importID int) (int, error) {
+	lines, err := s.Repo.ListLines(ctx, importID, models.BankLineStatusUnmatched, 10000)
+	if err != nil {
+		return 0, err
+	}
+	return s.autoMatch(ctx, lines), nil
+}
+
+// ListImports returns recent statement imports
+func (s *BankReconciliationService) ListImports(ctx context.Context, limit int) ([]*models.BankStatementImport, error) {
+	return s.Repo.ListImports(ctx, limit)
+}
+
+// ListLines returns bank lines; status "unmatched" is the reconciliation queue
+func (s *BankReconciliationService) ListLines(ctx context.Context, importID int, status string, limit int) ([]*models.BankStatementLine, error) {
+	return s.Repo.ListLines(ctx, importID, status, limit)
+}
+
+// GetCandidates returns the records a bank line could be linked to, UTR matches first
+func (s *BankReconciliationService) GetCandidates(ctx context.Context, lineID int) ([]models.BankMatchCandidate, error) {
+	line, err := s.Repo.GetLine(ctx, lineID)
+	if err != nil {
+		return nil, err
+	}
+	return s.candidates(ctx, line)
+}
+
+// Link settles a bank line with an existing rent payment, online transaction or ledger entry
+func (s *BankReconciliationService) Link(ctx context.Context, lineID int, req *models.LinkBankLineRequest, userID int) (*models.BankStatementLine, error) {
+	targets := 0
+	for _, id := range []*int{req.RentPaymentID, req.OnlineTransactionID, req.LedgerEntryID} {
+		if id != nil {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return nil, fmt.Errorf("%w: link exactly one of rent_payment_id, online_transaction_id, ledger_entry_id", ErrInvalidBankLine)
+	}
+
+	line := &models.BankStatementLine{
+		ID:                  lineID,
+		Status:              models.BankLineStatusLinked,
+		MatchReason:         "manual",
+		RentPaymentID:       req.RentPaymentID,
+		OnlineTransactionID: req.OnlineTransactionID,
+		LedgerEntryID:       req.LedgerEntryID,
+		ResolvedByUserID:    &userID,
+		Notes:               req.Notes,
+	}
+	if err := s.Repo.SetMatch(ctx, line, models.BankLineStatusUnmatched, models.BankLineStatusMatched); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetLine(ctx, lineID)
+}
+
+// Post records an unmatched bank credit as a customer receipt in the ledger
+func (s *BankReconciliationService) Post(ctx context.Context, lineID int, req *models.PostBankLineRequest, userID int) (*models.BankStatementLine, error) {
+	line, err := s.Repo.GetLine(ctx, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if line.Status != models.BankLineStatusUnmatched {
+		return nil, fmt.Errorf("%w: line %d is already %s", ErrInvalidBankLine, lineID, line.Status)
+	}
+
+	customer, err := s.CustomerRepo.GetByPhone(ctx, strings.TrimSpace(req.CustomerPhone))
+	if err != nil || customer == nil {
+		return nil, fmt.Errorf("%w: customer %s not found", ErrInvalidBankLine, req.CustomerPhone)
+	}
+
+	description := fmt.Sprintf("Bank credit %s", timeutil.ToIST(line.TxnDate).Format("02-01-2006"))
+	if line.UTR != "" {
+		description += " | UTR: " + line.UTR
+	}
+	notes := line.Description
+	if req.Notes != "" {
+		notes = req.Notes + " | " + notes
+	}
+
+	entry, err := s.LedgerService.CreateEntry(ctx, &models.CreateLedgerEntryRequest{
+		CustomerID:       customer.ID,
+		CustomerPhone:    customer.Phone,
+		CustomerName:     customer.Name,
+		CustomerSO:       customer.SO,
+		EntryType:        models.LedgerEntryTypeOnlinePayment,
+		Description:      description,
+		Credit:           line.Amount,
+		ReferenceID:      &line.ID,
+		ReferenceType:    models.LedgerReferenceBankStatementLine,
+		FamilyMemberID:   req.FamilyMemberID,
+		FamilyMemberName: req.FamilyMemberName,
+		CreatedByUserID:  userID,
+		Notes:            notes,
+		IdempotencyKey:   fmt.Sprintf("bank:%d", line.ID),
+	})
+	if errors.Is(err, repositories.ErrDuplicateLedgerEntry) {
+		return nil, fmt.Errorf("%w: a ledger entry was already posted for line %d", ErrInvalidBankLine, lineID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line.Status = models.BankLineStatusPosted
+	line.MatchReason = "posted"
+	line.RentPaymentID, line.OnlineTransactionID = nil, nil
+	line.LedgerEntryID = &entry.ID
+	line.ResolvedByUserID = &userID
+	line.Notes = req.Notes
+	if err := s.Repo.SetMatch(ctx, line, models.BankLineStatusUnmatched); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetLine(ctx, lineID)
+}
+
+// Ignore takes a credit that is not a customer receipt out of the queue
+func (s *BankReconciliationService) Ignore(ctx context.Context, lineID int, notes string, userID int) (*models.BankStatementLine, error) {
+	if strings.TrimSpace(notes) == "" {
+		return nil, fmt.Errorf("%w: a note is required to ignore a bank line", ErrInvalidBankLine)
+	}
+	line := &models.BankStatementLine{
+		ID:               lineID,
+		Status:           models.BankLineStatusIgnored,
+		MatchReason:      "ignored",
+		ResolvedByUserID: &userID,
+		Notes:            notes,
+	}
+	if err := s.Repo.SetMatch(ctx, line, models.BankLineStatusUnmatched); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetLine(ctx, lineID)
+}
+
+// Unlink returns a matched, linked or ignored line to the queue.
+// Posted lines keep their ledger entry; reverse it in the ledger instead.
+func (s *BankReconciliationService) Unlink(ctx context.Context, lineID int, notes string) (*models.BankStatementLine, error) {
+	line := &models.BankStatementLine{
+		ID:     lineID,
+		Status: models.BankLineStatusUnmatched,
+		Notes:  notes,
+	}
+	if err := s.Repo.SetMatch(ctx, line, models.BankLineStatusMatched, models.BankLineStatusLinked, models.BankLineStatusIgnored); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetLine(ctx, lineID)
+}
+
+// autoMatch matches lines that have a single candidate. Returns how many matched.
+func (s *BankReconciliationService) autoMatch(ctx context.Context, lines []*models.BankStatementLine) int {
+	matched := 0
+	for _, line := range lines {
+		candidates, err := s.candidates(ctx, line)
+		if err != nil {
+			log.Printf("[BankRecon] Failed to find matches for line %d: %v", line.ID, err)
+			continue
+		}
+		match := pickBankMatch(candidates)
+		if match == nil {
+			continue
+		}
+
+		line.Status = models.BankLineStatusMatched
+		line.MatchReason = match.Reason
+		switch match.Type {
+		case "rent_payment":
+			line.RentPaymentID = &match.ID
+		case "online_transaction":
+			line.OnlineTransactionID = &match.ID
+		case "ledger_entry":
+			line.LedgerEntryID = &match.ID
+		}
+		if err := s.Repo.SetMatch(ctx, line, models.BankLineStatusUnmatched); err != nil {
+			// Another line took the record first; leave this one for the accountant
+			line.Status, line.MatchReason = models.BankLineStatusUnmatched, ""
+			line.RentPaymentID, line.OnlineTransactionID, line.LedgerEntryID = nil, nil, nil
+			continue
+		}
+		matched++
+	}
+	return matched
+}
+
+// candidates looks for records within the configured date window around the bank date
+func (s *BankReconciliationService) candidates(ctx context.Context, line *models.BankStatementLine) ([]models.BankMatchCandidate, error) {
+	window := s.dateWindowDays(ctx)
+	day := timeutil.StartOfDay(line.TxnDate)
+	start := day.AddDate(0, 0, -window)
+	end := day.AddDate(0, 0, window+1)
+	return s.Repo.FindCandidates(ctx, line.UTR, line.Amount, start, end)
+}
+
+func (s *BankReconciliationService) dateWindowDays(ctx context.Context) int {
+	setting, err := s.SettingsRepo.Get(ctx, "bank_reconciliation_date_window_days")
+	if err != nil || setting == nil {
+		return 3
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(setting.SettingValue))
+	if err != nil || days < 0 {
+		return 3
+	}
+	return days
+}
+
+// pickBankMatch returns the only UTR match, else the only amount/date match
+func pickBankMatch(candidates []models.BankMatchCandidate) *models.BankMatchCandidate {
+	var byUTR, byAmount []*models.BankMatchCandidate
+	for i := range candidates {
+		if candidates[i].Reason == "utr" {
+			byUTR = append(byUTR, &candidates[i])
+		} else {
+			byAmount = append(byAmount, &candidates[i])
+		}
+	}
+	switch {
+	case len(byUTR) == 1:
+		return byUTR[0]
+	case len(byUTR) == 0 && len(byAmount) == 1:
+		return byAmount[0]
+	}
+	return nil
+}
+
+// bankLineHash identifies a bank line across overlapping statements of the same account.
+// Identical lines within one file (same day, amount and narration) are told apart by occurrence.
+func bankLineHash(bankAccount string, l *models.BankStatementLine, seen map[string]int) string {
+	balance := ""
+	if l.Balance != nil {
+		balance = fmt.Sprintf("%.2f", *l.Balance)
+	}
+	key := strings.Join([]string{
+		bankAccount,
+		l.TxnDate.Format("2006-01-02"),
+		fmt.Sprintf("%.2f", l.Amount),
+		l.Reference,
+		l.Description,
+		balance,
+	}, "|")
+	seen[key]++
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s#%d", key, seen[key])))
+	return hex.EncodeToString(sum[:])
+}
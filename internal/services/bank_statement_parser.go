@@ -0,0 +1,417 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/timeutil"
+)
+
+// Bank statement column kinds, detected from the header row
+const (
+	bankColDate        = "date"
+	bankColDescription = "description"
+	bankColReference   = "reference"
+	bankColCredit      = "credit"
+	bankColDebit       = "debit"
+	bankColAmount      = "amount"
+	bankColDrCr        = "drcr"
+	bankColBalance     = "balance"
+)
+
+// bankStatementDateLayouts are the date formats Indian banks use in statement exports
+var bankStatementDateLayouts = []string{
+	"02/01/2006", "02-01-2006", "02.01.2006", "2006-01-02", "02/01/06", "02-01-06",
+	"02-Jan-2006", "02 Jan 2006", "02-Jan-06", "02 Jan 06", "Jan 02, 2006", "2/1/2006",
+	"02/01/2006 15:04:05", "02/01/2006 15:04", "02-01-2006 15:04:05", "2006-01-02 15:04:05",
+}
+
+var (
+	// UPI / IMPS reference (RRN) is 12 digits
+	rrnPattern = regexp.MustCompile(`\b\d{12}\b`)
+	// NEFT (16) and RTGS (22) UTRs start with the 4-letter bank code
+	neftUTRPattern = regexp.MustCompile(`\b[A-Z]{4}[A-Z0-9]{12}(?:[A-Z0-9]{6})?\b`)
+)
+
+// ParseBankStatement reads the credit lines of a bank statement export (.csv or .xlsx).
+// The header row is found among the first rows by its column names, so bank
+// preambles (account details, period) are skipped. Debits and rows that are
+// not transactions (totals, footers) are counted in skipped.
+func ParseBankStatement(fileName string, data []byte) (lines []*models.BankStatementLine, skipped int, err error) {
+	var records [][]string
+	if strings.EqualFold(path.Ext(fileName), ".xlsx") {
+		records, err = readXLSXRows(data)
+	} else {
+		records, err = readCSVRows(data)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	headerRow, cols := findBankHeader(records)
+	if headerRow < 0 {
+		return nil, 0, errors.New("no header row with a date and a credit/amount column found")
+	}
+
+	for i := headerRow + 1; i < len(records); i++ {
+		row := records[i]
+		cell := func(kind string) string {
+			if idx, ok := cols[kind]; ok && idx < len(row) {
+				return strings.TrimSpace(row[idx])
+			}
+			return ""
+		}
+
+		date, ok := parseStatementDate(cell(bankColDate))
+		if !ok {
+			if strings.Join(row, "") != "" {
+				skipped++
+			}
+			continue
+		}
+
+		amount := statementCredit(cell(bankColCredit), cell(bankColAmount), cell(bankColDrCr))
+		if amount <= 0 {
+			skipped++
+			continue
+		}
+
+		line := &models.BankStatementLine{
+			LineNumber:  i + 1,
+			TxnDate:     date,
+			Description: strings.Join(strings.Fields(cell(bankColDescription)), " "),
+			Reference:   cell(bankColReference),
+			Amount:      amount,
+		}
+		if balance, ok := parseStatementAmount(cell(bankColBalance)); ok {
+			line.Balance = &balance
+		}
+		line.UTR = extractUTR(line.Reference, line.Description)
+		lines = append(lines, line)
+	}
+	return lines, skipped, nil
+}
+
+// readCSVRows reads a comma, semicolon or tab separated statement
+func readCSVRows(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	switch {
+	case bytes.Count(firstLine, []byte("\t")) > bytes.Count(firstLine, []byte(",")):
+		reader.Comma = '\t'
+	case bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")):
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	return records, nil
+}
+
+// findBankHeader returns the header row index and the column index of each kind
+func findBankHeader(records [][]string) (int, map[string]int) {
+	for i := 0; i < len(records) && i < 30; i++ {
+		cols := make(map[string]int)
+		for j, h := range records[i] {
+			kind := bankColumnKind(h)
+			if kind == "" {
+				continue
+			}
+			// "Txn Date" wins over a later "Value Date"
+			if _, seen := cols[kind]; !seen {
+				cols[kind] = j
+			}
+		}
+		_, hasDate := cols[bankColDate]
+		_, hasCredit := cols[bankColCredit]
+		_, hasAmount := cols[bankColAmount]
+		if hasDate && (hasCredit || hasAmount) {
+			return i, cols
+		}
+	}
+	return -1, nil
+}
+
+// bankColumnKind classifies a header cell
+func bankColumnKind(header string) string {
+	h := strings.ToLower(strings.Join(strings.Fields(header), " "))
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(h, w) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case h == "":
+		return ""
+	case has("balance"):
+		return bankColBalance
+	case has("date"):
+		return bankColDate
+	case has("narration", "description", "particular", "remark", "details"):
+		return bankColDescription
+	case has("chq", "cheque", "ref", "utr"):
+		return bankColReference
+	case has("credit", "deposit") || h == "cr" || h == "cr amount":
+		return bankColCredit
+	case has("debit", "withdrawal") || h == "dr" || h == "dr amount":
+		return bankColDebit
+	case h == "cr/dr" || h == "dr/cr" || h == "type" || h == "txn type":
+		return bankColDrCr
+	case has("amount"):
+		return bankColAmount
+	}
+	return ""
+}
+
+// statementCredit returns the credited amount of a row (0 for debits)
+func statementCredit(credit, amount, drcr string) float64 {
+	if credit != "" {
+		value, _ := parseStatementAmount(credit)
+		return value
+	}
+
+	value, ok := parseStatementAmount(amount)
+	if !ok || value < 0 {
+		return 0
+	}
+	switch strings.ToUpper(strings.TrimSpace(drcr)) {
+	case "CR", "C", "CREDIT":
+		return value
+	case "":
+		// Single signed column, optionally suffixed "Cr"/"Dr"
+		if strings.HasSuffix(strings.ToUpper(strings.TrimSpace(amount)), "DR") {
+			return 0
+		}
+		return value
+	}
+	return 0
+}
+
+// parseStatementAmount parses "1,23,456.00", "₹ 500 Cr", "(250.00)"
+func parseStatementAmount(s string) (float64, bool) {
+	s = strings.NewReplacer(",", "", "₹", "", "INR", "", "Rs.", "", " ", "").Replace(strings.TrimSpace(s))
+	upper := strings.ToUpper(s)
+	s = strings.TrimSuffix(strings.TrimSuffix(upper, "CR"), "DR")
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	s = strings.Trim(s, "()")
+	if s == "" || s == "-" {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		value = -value
+	}
+	return math.Round(value*100) / 100, true
+}
+
+// parseStatementDate parses a statement date (IST), including Excel serial dates
+func parseStatementDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range bankStatementDateLayouts {
+		if t, err := timeutil.ParseInIST(layout, s); err == nil {
+			return timeutil.StartOfDay(t), true
+		}
+	}
+
+	// Excel stores dates as days since 1899-12-30
+	if serial, err := strconv.ParseFloat(s, 64); err == nil && serial > 20000 && serial < 80000 {
+		t := time.Date(1899, 12, 30, 0, 0, 0, 0, timeutil.IST).AddDate(0, 0, int(serial))
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// extractUTR finds the UPI/IMPS/NEFT/RTGS reference of a bank line
+func extractUTR(reference, description string) string {
+	ref := strings.ToUpper(strings.TrimSpace(reference))
+	if isUTR(ref) {
+		return ref
+	}
+	// Some banks zero-pad the 12-digit UPI reference to 16 digits
+	if len(ref) > 12 && strings.Trim(ref, "0123456789") == "" && strings.TrimLeft(ref[:len(ref)-12], "0") == "" {
+		return ref[len(ref)-12:]
+	}
+
+	desc := strings.ToUpper(description)
+	if m := rrnPattern.FindString(desc); m != "" {
+		return m
+	}
+	for _, m := range neftUTRPattern.FindAllString(desc, -1) {
+		if isUTR(m) {
+			return m
+		}
+	}
+	return ""
+}
+
+// isUTR reports whether s is a 12-digit RRN or a NEFT/RTGS UTR (mostly digits after the bank code)
+func isUTR(s string) bool {
+	if rrnPattern.MatchString(s) && len(s) == 12 {
+		return true
+	}
+	if !neftUTRPattern.MatchString(s) || (len(s) != 16 && len(s) != 22) {
+		return false
+	}
+	digits := 0
+	for _, c := range s[4:] {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	return digits >= 8
+}
+
+// readXLSXRows reads the first worksheet of an .xlsx file as text rows
+func readXLSXRows(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %w", err)
+	}
+
+	files := make(map[string]*zip.File)
+	var sheets []string
+	for _, f := range zr.File {
+		files[f.Name] = f
+		if strings.HasPrefix(f.Name, "xl/worksheets/sheet") && strings.HasSuffix(f.Name, ".xml") {
+			sheets = append(sheets, f.Name)
+		}
+	}
+	if len(sheets) == 0 {
+		return nil, errors.New("invalid XLSX file: no worksheet")
+	}
+	sort.Strings(sheets)
+	if _, ok := files["xl/worksheets/sheet1.xml"]; ok {
+		sheets[0] = "xl/worksheets/sheet1.xml"
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeZipXML(f, &sst); err != nil {
+			return nil, fmt.Errorf("invalid XLSX shared strings: %w", err)
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeZipXML(files[sheets[0]], &sheet); err != nil {
+		return nil, fmt.Errorf("invalid XLSX worksheet: %w", err)
+	}
+
+	var records [][]string
+	for _, row := range sheet.Rows {
+		// Keep row positions so line numbers match the spreadsheet
+		for row.Number > len(records)+1 {
+			records = append(records, nil)
+		}
+
+		var record []string
+		for i, c := range row.Cells {
+			col := xlsxColumn(c.Ref, i)
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			switch c.Type {
+			case "s":
+				if idx, err := strconv.Atoi(c.Value); err == nil && idx >= 0 && idx < len(shared) {
+					record[col] = shared[idx]
+				}
+			case "inlineStr":
+				record[col] = c.Inline.String()
+			default:
+				record[col] = c.Value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// xlsxText is a shared or inline string, plain or made of rich-text runs
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+// xlsxColumn converts the letters of a cell reference ("C12") to a 0-based column
+func xlsxColumn(ref string, fallback int) int {
+	col := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+	}
+	if col == 0 {
+		return fallback
+	}
+	return col - 1
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, 100<<20)).Decode(v)
+}
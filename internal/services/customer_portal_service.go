@@ -2,11 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"log"
 
 	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
 )
 
 type CustomerPortalService struct {
@@ -18,6 +20,9 @@ type CustomerPortalService struct {
 	SystemSettingRepo  *repositories.SystemSettingRepository
 	GatePassPickupRepo *repositories.GatePassPickupRepository
 	LedgerRepo         *repositories.LedgerRepository
+	TariffService      *TariffService
+	Slots              *PickupSlotService
+	Approvals          *ApprovalService
 }
 
 func NewCustomerPortalService(
@@ -29,6 +34,7 @@ func NewCustomerPortalService(
 	systemSettingRepo *repositories.SystemSettingRepository,
 	gatePassPickupRepo *repositories.GatePassPickupRepository,
 	ledgerRepo *repositories.LedgerRepository,
+	tariffService *TariffService,
 ) *CustomerPortalService {
 	return &CustomerPortalService{
 		CustomerRepo:       customerRepo,
@@ -39,9 +45,28 @@ func NewCustomerPortalService(
 		SystemSettingRepo:  systemSettingRepo,
 		GatePassPickupRepo: gatePassPickupRepo,
 		LedgerRepo:         ledgerRepo,
+		TariffService:      tariffService,
 	}
 }
 
+// SetPickupSlotService lets customers book a pickup slot with their gate pass request
+func (s *CustomerPortalService) SetPickupSlotService(slots *PickupSlotService) {
+	s.Slots = slots
+}
+
+// SetApprovalService opens an approval request for each gate pass customers request
+func (s *CustomerPortalService) SetApprovalService(approvals *ApprovalService) {
+	s.Approvals = approvals
+}
+
+// ListPickupSlots returns the pickup slots customers can book
+func (s *CustomerPortalService) ListPickupSlots(ctx context.Context) ([]*models.PickupSlot, error) {
+	if s.Slots == nil {
+		return []*models.PickupSlot{}, nil
+	}
+	return s.Slots.ListForCustomers(ctx)
+}
+
 // ThockInfo represents dashboard data for a single truck
 type ThockInfo struct {
 	ThockNumber      string  `json:"thock_number"`
@@ -85,16 +110,12 @@ func (s *CustomerPortalService) GetDashboardData(ctx context.Context, customerID
 		return nil, fmt.Errorf("failed to get customer: %w", err)
 	}
 
-	// Get rent_per_item setting from system settings
-	var systemRentPerItem float64 = 0.0 // No hardcoded fallback - use API only
-	if s.SystemSettingRepo != nil {
-		rentSetting, err := s.SystemSettingRepo.Get(ctx, "rent_per_item")
-		if err == nil && rentSetting != nil {
-			if parsed, parseErr := strconv.ParseFloat(rentSetting.SettingValue, 64); parseErr == nil {
-				systemRentPerItem = parsed
-			}
-		}
+	// Load rent rate cards (same pricing as account management and reports)
+	rateCard, err := s.TariffService.LoadRateCard(ctx)
+	if err != nil {
+		return nil, err
 	}
+	now := timeutil.Now()
 
 	// Get all entries for this customer
 	entries, err := s.EntryRepo.ListByCustomer(ctx, customerID)
@@ -140,8 +161,6 @@ func (s *CustomerPortalService) GetDashboardData(ctx context.Context, customerID
 	var totalRent, totalPaid, totalBalance float64
 
 	// For each entry, calculate truck info
-	rentPerItem := systemRentPerItem
-
 	for _, entry := range entries {
 		// Get family member ID (0 if not assigned)
 		fmID := 0
@@ -175,7 +194,8 @@ func (s *CustomerPortalService) GetDashboardData(ctx context.Context, customerID
 		// Get pending quantity from gate passes
 		pendingQty, _ := s.GatePassRepo.GetPendingQuantityForEntry(ctx, entry.ID)
 
-		// Calculate rent for this entry
+		// Calculate rent for this entry from the rate card
+		rentPerItem := rateCard.RateFor(entry, now)
 		entryTotalRent := float64(originalEntered) * rentPerItem
 
 		// Calculate effective available inventory
@@ -301,16 +321,12 @@ func (s *CustomerPortalService) CreateGatePassRequest(ctx context.Context, custo
 		return nil, fmt.Errorf("customer not found")
 	}
 
-	// Get rent_per_item setting
-	var rentPerItem float64 = 0.0
-	if s.SystemSettingRepo != nil {
-		rentSetting, err := s.SystemSettingRepo.Get(ctx, "rent_per_item")
-		if err == nil && rentSetting != nil {
-			if parsed, parseErr := strconv.ParseFloat(rentSetting.SettingValue, 64); parseErr == nil {
-				rentPerItem = parsed
-			}
-		}
+	// Get the per-bag rent for this thock from the rate card
+	rateCard, err := s.TariffService.LoadRateCard(ctx)
+	if err != nil {
+		return nil, err
 	}
+	rentPerItem := rateCard.RateFor(entry, timeutil.Now())
 
 	// Get ORIGINAL entered quantity from room entries
 	originalEntered, err := s.RoomEntryRepo.GetTotalQuantityByThockNumber(ctx, request.ThockNumber)
@@ -421,6 +437,21 @@ func (s *CustomerPortalService) CreateGatePassRequest(ctx context.Context, custo
 		}
 	}
 
+	// Check the pickup slot before creating anything; the booking itself is re-checked when
+	// the gate pass is saved
+	if request.PickupSlotID != nil {
+		if s.Slots == nil {
+			return nil, fmt.Errorf("pickup slots are not available")
+		}
+		slot, err := s.Slots.Repo.Get(ctx, *request.PickupSlotID)
+		if err != nil {
+			return nil, fmt.Errorf("pickup slot not found")
+		}
+		if !slot.Available {
+			return nil, fmt.Errorf("pickup slot %s %s-%s at gate %s is no longer available, please pick another", slot.SlotDate, slot.StartTime, slot.EndTime, slot.Gate)
+		}
+	}
+
 	// Create gate pass
 	gatePass, err := s.GatePassRepo.CreateCustomerGatePass(
 		ctx,
@@ -431,12 +462,27 @@ func (s *CustomerPortalService) CreateGatePassRequest(ctx context.Context, custo
 		entry.ID,
 		request.FamilyMemberID,
 		request.FamilyMemberName,
+		request.PickupSlotID,
 	)
 
 	if err != nil {
+		if err := pickupSlotError(err); errors.Is(err, ErrInvalidPickupSlot) {
+			// The slot filled up or closed since it was checked
+			return nil, fmt.Errorf("%w, please pick another slot", err)
+		}
 		return nil, fmt.Errorf("failed to create gate pass: %w", err)
 	}
 
+	// Start the gate pass's status history
+	if err := s.GatePassRepo.RecordTransition(ctx, &models.GatePassTransition{
+		GatePassID: gatePass.ID,
+		ToStatus:   gatePass.Status,
+		Reason:     "Requested on customer portal",
+	}); err != nil {
+		log.Printf("[GatePass] Failed to record request of gate pass %d: %v", gatePass.ID, err)
+	}
+	submitGatePassApproval(ctx, s.Approvals, gatePass)
+
 	return gatePass, nil
 }
 
@@ -28,6 +28,9 @@ func (s *CustomerService) CreateCustomer(ctx context.Context, req *models.Create
 		SO:      req.SO,
 		Village: req.Village,
 		Address: req.Address,
+
+		StateCode: req.StateCode,
+		GSTIN:     req.GSTIN,
 	}
 
 	if err := s.Repo.Create(ctx, customer); err != nil {
@@ -73,6 +76,9 @@ func (s *CustomerService) UpdateCustomer(ctx context.Context, id int, req *model
 		SO:      req.SO,
 		Village: req.Village,
 		Address: req.Address,
+
+		StateCode: req.StateCode,
+		GSTIN:     req.GSTIN,
 	}
 
 	if err := s.Repo.Update(ctx, customer); err != nil {
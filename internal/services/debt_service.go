@@ -3,14 +3,17 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
 )
 
 type DebtService struct {
-	DebtRepo      *repositories.DebtRequestRepository
-	LedgerService *LedgerService
+	DebtRepo          *repositories.DebtRequestRepository
+	LedgerService     *LedgerService
+	AllocationService *PaymentAllocationService
+	Approvals         *ApprovalService
 }
 
 func NewDebtService(debtRepo *repositories.DebtRequestRepository, ledgerService *LedgerService) *DebtService {
@@ -20,6 +23,32 @@ func NewDebtService(debtRepo *repositories.DebtRequestRepository, ledgerService
 	}
 }
 
+// SetAllocationService enables per-thock gate pass decisions
+func (s *DebtService) SetAllocationService(allocationService *PaymentAllocationService) {
+	s.AllocationService = allocationService
+}
+
+// SetApprovalService routes debt approvals through the approval policies; a debt request
+// whose approval expires is expired with it
+func (s *DebtService) SetApprovalService(approvals *ApprovalService) {
+	s.Approvals = approvals
+	approvals.OnExpiry(models.ApprovalFlowDebt, s.DebtRepo.Expire)
+}
+
+// debtApprovalSubject describes a debt request to the approval engine; the amount is the
+// customer's outstanding balance
+func debtApprovalSubject(request *models.DebtRequest) *models.ApprovalSubject {
+	requestedBy := request.RequestedByUserID
+	return &models.ApprovalSubject{
+		Flow:      models.ApprovalFlowDebt,
+		SubjectID: request.ID,
+		Amount:    request.CurrentBalance,
+		Summary: fmt.Sprintf("Items out on credit for %s (%s), thock %s, %d bags, outstanding Rs %.2f",
+			request.CustomerName, request.CustomerPhone, request.ThockNumber, request.RequestedQuantity, request.CurrentBalance),
+		RequestedBy: &requestedBy,
+	}
+}
+
 // CreateRequest creates a new debt request
 func (s *DebtService) CreateRequest(ctx context.Context, req *models.CreateDebtRequestRequest, requestedByUserID int, requestedByName string) (*models.DebtRequest, error) {
 	// Try to get balance from ledger first
@@ -34,7 +63,16 @@ func (s *DebtService) CreateRequest(ctx context.Context, req *models.CreateDebtR
 	// If ledger has no balance but frontend sent balance > 0, trust it
 	// (balance might come from rent system which is separate from ledger)
 
-	return s.DebtRepo.Create(ctx, req, requestedByUserID, requestedByName)
+	request, err := s.DebtRepo.Create(ctx, req, requestedByUserID, requestedByName)
+	if err != nil {
+		return nil, err
+	}
+	if s.Approvals != nil {
+		if _, err := s.Approvals.Submit(ctx, debtApprovalSubject(request)); err != nil {
+			log.Printf("[Debt] Failed to open approval for debt request %d: %v", request.ID, err)
+		}
+	}
+	return request, nil
 }
 
 // GetByID returns a debt request by ID
@@ -57,31 +95,44 @@ func (s *DebtService) GetAll(ctx context.Context, filter *models.DebtRequestFilt
 	return s.DebtRepo.GetAll(ctx, filter)
 }
 
-// Approve approves a debt request
-func (s *DebtService) Approve(ctx context.Context, id int, approvedByUserID int, approvedByName string) error {
+// Approve approves a debt request. Under an approval policy that needs more approvers the
+// approval is recorded and the request stays pending; the returned approval request (nil
+// without the approval engine) tells which.
+func (s *DebtService) Approve(ctx context.Context, id int, approvedByUserID int, approvedByName string, creds *models.ApprovalCredentials) (*models.ApprovalRequest, error) {
 	// Get the request first to validate
 	request, err := s.DebtRepo.GetByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to get debt request: %w", err)
+		return nil, fmt.Errorf("failed to get debt request: %w", err)
 	}
 	if request == nil {
-		return fmt.Errorf("debt request not found")
+		return nil, fmt.Errorf("debt request not found")
 	}
 	if request.Status != models.DebtRequestStatusPending {
-		return fmt.Errorf("debt request is not in pending status")
+		return nil, fmt.Errorf("debt request is not in pending status")
+	}
+
+	var approval *models.ApprovalRequest
+	if s.Approvals != nil {
+		approval, err = s.Approvals.Approve(ctx, debtApprovalSubject(request), approvedByUserID, creds)
+		if err != nil {
+			return nil, err
+		}
+		if approvalPending(approval) {
+			return approval, nil
+		}
 	}
 
 	// Approve the request
 	err = s.DebtRepo.Approve(ctx, id, approvedByUserID, approvedByName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return approval, nil
 }
 
 // Reject rejects a debt request
-func (s *DebtService) Reject(ctx context.Context, id int, approvedByUserID int, approvedByName, reason string) error {
+func (s *DebtService) Reject(ctx context.Context, id int, approvedByUserID int, approvedByName, reason, ipAddress string) error {
 	// Get the request first to validate
 	request, err := s.DebtRepo.GetByID(ctx, id)
 	if err != nil {
@@ -94,6 +145,12 @@ func (s *DebtService) Reject(ctx context.Context, id int, approvedByUserID int,
 		return fmt.Errorf("debt request is not in pending status")
 	}
 
+	if s.Approvals != nil {
+		if _, err := s.Approvals.Reject(ctx, debtApprovalSubject(request), approvedByUserID, reason, ipAddress); err != nil {
+			return err
+		}
+	}
+
 	return s.DebtRepo.Reject(ctx, id, approvedByUserID, approvedByName, reason)
 }
 
@@ -143,13 +200,28 @@ func (s *DebtService) GetApprovedForCustomerAndThock(ctx context.Context, custom
 	return s.DebtRepo.GetApprovedForCustomerAndThock(ctx, customerPhone, thockNumber)
 }
 
-// CanCreateGatePass checks if a gate pass can be created (either no balance or has debt approval)
+// CanCreateGatePass checks if a gate pass can be created (either no balance or has debt approval).
+// Current thocks are decided on what is due before their release (see DueForRelease);
+// other thocks on the customer's balance.
 func (s *DebtService) CanCreateGatePass(ctx context.Context, customerPhone, thockNumber string) (bool, *models.DebtRequest, float64, error) {
-	// Check if customer has outstanding balance
-	hasBalance, balance, err := s.LedgerService.HasOutstandingBalance(ctx, customerPhone)
-	if err != nil {
-		// No ledger entries = no balance
-		return true, nil, 0, nil
+	var hasBalance bool
+	var balance float64
+	var thock *models.ThockOutstanding
+	outstanding := s.customerOutstanding(ctx, customerPhone)
+	if outstanding != nil {
+		thock = outstanding.Thock(thockNumber)
+	}
+	if thock != nil {
+		balance = outstanding.DueForRelease(thock)
+		hasBalance = balance >= 0.01
+	} else {
+		// Check if customer has outstanding balance
+		var err error
+		hasBalance, balance, err = s.LedgerService.HasOutstandingBalance(ctx, customerPhone)
+		if err != nil {
+			// No ledger entries = no balance
+			return true, nil, 0, nil
+		}
 	}
 
 	if !hasBalance {
@@ -172,6 +244,19 @@ func (s *DebtService) CanCreateGatePass(ctx context.Context, customerPhone, thoc
 	return false, nil, balance, nil
 }
 
+// customerOutstanding returns the customer's per-thock outstanding, or nil to fall back to the customer balance
+func (s *DebtService) customerOutstanding(ctx context.Context, customerPhone string) *models.CustomerOutstanding {
+	if s.AllocationService == nil {
+		return nil
+	}
+	outstanding, err := s.AllocationService.GetOutstanding(ctx, customerPhone)
+	if err != nil {
+		log.Printf("[Debt] Per-thock outstanding unavailable for %s: %v", customerPhone, err)
+		return nil
+	}
+	return outstanding
+}
+
 // GetPendingSummary returns summary of pending requests for dashboard
 func (s *DebtService) GetPendingSummary(ctx context.Context) (*models.PendingDebtRequestSummary, error) {
 	return s.DebtRepo.GetPendingSummary(ctx)
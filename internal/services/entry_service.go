@@ -21,6 +21,7 @@ type EntryService struct {
 	EntryEventRepo   *repositories.EntryEventRepository
 	SettingRepo      *repositories.SystemSettingRepository
 	FamilyMemberRepo *repositories.FamilyMemberRepository
+	ThockNumberRepo  *repositories.ThockNumberRepository
 }
 
 func NewEntryService(entryRepo *repositories.EntryRepository, customerRepo *repositories.CustomerRepository, entryEventRepo *repositories.EntryEventRepository) *EntryService {
@@ -41,14 +42,24 @@ func (s *EntryService) SetSettingRepo(repo *repositories.SystemSettingRepository
 	s.SettingRepo = repo
 }
 
+// SetThockNumberRepo enables previews of the next thock number from the season's series
+func (s *EntryService) SetThockNumberRepo(repo *repositories.ThockNumberRepository) {
+	s.ThockNumberRepo = repo
+}
+
 // getSkipRanges retrieves skip ranges from settings for a given category
-func (s *EntryService) getSkipRanges(ctx context.Context, category string) []SkipRange {
-	if s.SettingRepo == nil {
+func (s *EntryService) getSkipRanges(ctx context.Context, category string) []repositories.SkipRange {
+	return loadSkipRanges(ctx, s.SettingRepo, category)
+}
+
+// loadSkipRanges reads the thock number ranges to skip for a category from settings
+func loadSkipRanges(ctx context.Context, settingRepo *repositories.SystemSettingRepository, category string) []repositories.SkipRange {
+	if settingRepo == nil {
 		return nil
 	}
 
 	key := "skip_thock_ranges_" + category
-	setting, err := s.SettingRepo.Get(ctx, key)
+	setting, err := settingRepo.Get(ctx, key)
 	if err != nil || setting == nil || setting.SettingValue == "" {
 		return nil
 	}
@@ -57,7 +68,11 @@ func (s *EntryService) getSkipRanges(ctx context.Context, category string) []Ski
 	if err := json.Unmarshal([]byte(setting.SettingValue), &ranges); err != nil {
 		return nil
 	}
-	return ranges
+	repoRanges := make([]repositories.SkipRange, 0, len(ranges))
+	for _, r := range ranges {
+		repoRanges = append(repoRanges, repositories.SkipRange{From: r.From, To: r.To})
+	}
+	return repoRanges
 }
 
 func (s *EntryService) CreateEntry(ctx context.Context, req *models.CreateEntryRequest, userID int) (*models.Entry, error) {
@@ -156,13 +171,7 @@ func (s *EntryService) CreateEntry(ctx context.Context, req *models.CreateEntryR
 		CreatedByUserID:  userID,
 	}
 
-	// Convert SkipRange to repositories.SkipRange
-	var repoSkipRanges []repositories.SkipRange
-	for _, r := range skipRanges {
-		repoSkipRanges = append(repoSkipRanges, repositories.SkipRange{From: r.From, To: r.To})
-	}
-
-	if err := s.EntryRepo.CreateWithSkipRanges(ctx, entry, repoSkipRanges); err != nil {
+	if err := s.EntryRepo.CreateNumbered(ctx, entry, skipRanges, req.ReservedNumber); err != nil {
 		return nil, err
 	}
 
@@ -212,6 +221,33 @@ func (s *EntryService) GetMaxThockNumber(ctx context.Context, category string) (
 	return s.EntryRepo.GetMaxThockNumber(ctx, category)
 }
 
+// PreviewNextThockNumber returns the number the next entry of a category will get
+func (s *EntryService) PreviewNextThockNumber(ctx context.Context, category string) (int, error) {
+	if category != "seed" && category != "sell" {
+		return 0, errors.New("category must be 'seed' or 'sell'")
+	}
+	skipRanges := s.getSkipRanges(ctx, category)
+	if s.ThockNumberRepo != nil {
+		return s.ThockNumberRepo.PeekNext(ctx, category, skipRanges)
+	}
+
+	maxThock, err := s.EntryRepo.GetMaxThockNumber(ctx, category)
+	if err != nil {
+		return 0, err
+	}
+	next := maxThock + 1
+	for jumped := true; jumped; {
+		jumped = false
+		for _, r := range skipRanges {
+			if next >= r.From && next <= r.To {
+				next = r.To + 1
+				jumped = true
+			}
+		}
+	}
+	return next, nil
+}
+
 // ReassignEntry reassigns an entry to a different customer (optionally to a specific family member)
 func (s *EntryService) ReassignEntry(ctx context.Context, entryID int, newCustomerID int, familyMemberID *int, familyMemberName string) (*models.Entry, *models.Customer, error) {
 	// Get the new customer
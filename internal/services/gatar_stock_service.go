@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// GatarStockService posts room entries, edits and pickups to the per-gatar stock ledger
+type GatarStockService struct {
+	Repo           *repositories.GatarStockRepository
+	Layout         *WarehouseLayoutService
+	RoomEntryRepo  *repositories.RoomEntryRepository
+	EntryEventRepo *repositories.EntryEventRepository
+}
+
+func NewGatarStockService(repo *repositories.GatarStockRepository, layout *WarehouseLayoutService) *GatarStockService {
+	return &GatarStockService{Repo: repo, Layout: layout}
+}
+
+// SetEventRepos enables STOCK_MOVED events on the entry timeline for stock transfers
+func (s *GatarStockService) SetEventRepos(roomEntryRepo *repositories.RoomEntryRepository, entryEventRepo *repositories.EntryEventRepository) {
+	s.RoomEntryRepo = roomEntryRepo
+	s.EntryEventRepo = entryEventRepo
+}
+
+// RoomEntryMovements returns the ledger rows that put the bags of a new room entry into its
+// gatars; the room entry ID is filled in when the entry is saved
+func (s *GatarStockService) RoomEntryMovements(ctx context.Context, roomEntry *models.RoomEntry, gatars []models.GatarInput) ([]*models.GatarStockMovement, error) {
+	capacities, err := s.Layout.loadCapacities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	planned := capacities.placement(roomEntry.Quantity, roomEntry.GateNo, roomEntry.QuantityBreakdown, gatarQuantities(gatars))
+
+	userID := roomEntry.CreatedByUserID
+	var movements []*models.GatarStockMovement
+	for _, g := range sortedGatars(planned) {
+		if planned[g] == 0 {
+			continue
+		}
+		movements = append(movements, &models.GatarStockMovement{
+			ThockNumber:     roomEntry.ThockNumber,
+			RoomNo:          roomEntry.RoomNo,
+			Floor:           roomEntry.Floor,
+			GatarNo:         g,
+			Quantity:        planned[g],
+			MovementType:    models.GatarMovementIn,
+			SourceType:      "room_entry",
+			Quality:         gatarQuality(gatars, g),
+			CreatedByUserID: &userID,
+		})
+	}
+	return movements, nil
+}
+
+// RoomEntryEditPlan returns a function that, given the entry's current ledger balances, posts
+// the difference between an edited room entry's placement and what it placed before, so bags
+// already picked up stay picked up
+func (s *GatarStockService) RoomEntryEditPlan(ctx context.Context, roomEntry *models.RoomEntry, gatars []models.GatarInput) (func([]*models.GatarBalance) []*models.GatarStockMovement, error) {
+	capacities, err := s.Layout.loadCapacities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	planned := capacities.placement(roomEntry.Quantity, roomEntry.GateNo, roomEntry.QuantityBreakdown, gatarQuantities(gatars))
+
+	return func(balances []*models.GatarBalance) []*models.GatarStockMovement {
+		placed := make(map[int]int)
+		location := make(map[int]*models.GatarBalance)
+		for _, b := range balances {
+			placed[b.GatarNo] += b.Placed
+			location[b.GatarNo] = b
+			if _, ok := planned[b.GatarNo]; !ok {
+				planned[b.GatarNo] = 0
+			}
+		}
+
+		var movements []*models.GatarStockMovement
+		for _, g := range sortedGatars(planned) {
+			delta := planned[g] - placed[g]
+			if delta == 0 {
+				continue
+			}
+			roomNo, floor := roomEntry.RoomNo, roomEntry.Floor
+			if b, ok := location[g]; ok && planned[g] == 0 {
+				// Taken off a gatar the entry no longer lists
+				roomNo, floor = b.RoomNo, b.Floor
+			}
+			movements = append(movements, &models.GatarStockMovement{
+				RoomEntryID:  roomEntry.ID,
+				ThockNumber:  roomEntry.ThockNumber,
+				RoomNo:       roomNo,
+				Floor:        floor,
+				GatarNo:      g,
+				Quantity:     delta,
+				MovementType: models.GatarMovementEdit,
+				SourceType:   "room_entry",
+				SourceID:     roomEntry.ID,
+				Quality:      gatarQuality(gatars, g),
+				Notes:        "Room entry edited",
+			})
+		}
+		return movements
+	}, nil
+}
+
+// PickupPlan returns a function that, given the thock's current ledger balances, takes the
+// bags of a gate pass pickup out of the thock's gatars
+func (s *GatarStockService) PickupPlan(pickup *models.GatePassPickup, thockNumber string, breakdown []models.GatarBreakdown) func([]*models.GatarBalance) []*models.GatarStockMovement {
+	recorded := make(map[int]int, len(breakdown))
+	for _, b := range breakdown {
+		recorded[b.GatarNo] += b.Quantity
+	}
+	roomNo, floor := "", ""
+	if pickup.RoomNo != nil {
+		roomNo = *pickup.RoomNo
+	}
+	if pickup.Floor != nil {
+		floor = *pickup.Floor
+	}
+
+	return func(balances []*models.GatarBalance) []*models.GatarStockMovement {
+		movements, missing := takeFromBalances(balances, roomNo, floor, pickup.PickupQuantity, recorded)
+		userID := pickup.PickedUpByUserID
+		for _, m := range movements {
+			m.MovementType = models.GatarMovementOut
+			m.SourceType = "gate_pass_pickup"
+			m.CreatedByUserID = &userID
+		}
+		if missing > 0 {
+			log.Printf("[GatarStock] Pickup %d: %d bags of thock %s not found in any gatar", pickup.ID, missing, thockNumber)
+		}
+		return movements
+	}
+}
+
+// takeFromBalances takes quantity bags out of a thock's balances: first from the gatars the
+// pickup recorded, then from the pickup's room/floor in gatar order, then from the thock's
+// other gatars. Balances are reduced in place. Returns the movements (without type or source)
+// and the bags that could not be found.
+func takeFromBalances(balances []*models.GatarBalance, roomNo, floor string, quantity int, recorded map[int]int) ([]*models.GatarStockMovement, int) {
+	ordered := append([]*models.GatarBalance(nil), balances...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		aHere := a.RoomNo == roomNo && a.Floor == floor
+		bHere := b.RoomNo == roomNo && b.Floor == floor
+		if aHere != bHere {
+			return aHere
+		}
+		if a.GatarNo != b.GatarNo {
+			return a.GatarNo < b.GatarNo
+		}
+		return a.RoomEntryID < b.RoomEntryID
+	})
+
+	wanted := make(map[int]int, len(recorded))
+	for g, qty := range recorded {
+		wanted[g] = qty
+	}
+	taken := make(map[*models.GatarBalance]int)
+	var order []*models.GatarBalance
+	take := func(b *models.GatarBalance, qty int) {
+		if taken[b] == 0 {
+			order = append(order, b)
+		}
+		taken[b] += qty
+		b.Quantity -= qty
+		quantity -= qty
+	}
+	for _, b := range ordered {
+		if qty := min(wanted[b.GatarNo], b.Quantity, quantity); qty > 0 {
+			wanted[b.GatarNo] -= qty
+			take(b, qty)
+		}
+	}
+	for _, b := range ordered {
+		if qty := min(b.Quantity, quantity); qty > 0 {
+			take(b, qty)
+		}
+	}
+
+	movements := make([]*models.GatarStockMovement, 0, len(order))
+	for _, b := range order {
+		movements = append(movements, &models.GatarStockMovement{
+			RoomEntryID: b.RoomEntryID,
+			ThockNumber: b.ThockNumber,
+			RoomNo:      b.RoomNo,
+			Floor:       b.Floor,
+			GatarNo:     b.GatarNo,
+			Quantity:    -taken[b],
+		})
+	}
+	return movements, quantity
+}
+
+// gatarQuantities returns the per-gatar quantities entered with a room entry, if any
+func gatarQuantities(gatars []models.GatarInput) map[int]int {
+	if len(gatars) == 0 {
+		return nil
+	}
+	quantities := make(map[int]int, len(gatars))
+	for _, g := range gatars {
+		quantities[g.GatarNo] += g.Quantity
+	}
+	return quantities
+}
+
+func gatarQuality(gatars []models.GatarInput, gatarNo int) string {
+	for _, g := range gatars {
+		if g.GatarNo == gatarNo {
+			return g.Quality
+		}
+	}
+	return ""
+}
+
+func sortedGatars(quantities map[int]int) []int {
+	gatars := make([]int, 0, len(quantities))
+	for g := range quantities {
+		gatars = append(gatars, g)
+	}
+	sort.Ints(gatars)
+	return gatars
+}
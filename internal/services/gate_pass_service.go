@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"cold-backend/internal/models"
@@ -12,6 +14,9 @@ import (
 	"cold-backend/internal/timeutil"
 )
 
+// ErrInvalidGatePassChange is returned for a cancel, amendment or re-issue that makes no sense
+var ErrInvalidGatePassChange = errors.New("invalid gate pass change")
+
 type GatePassService struct {
 	GatePassRepo   *repositories.GatePassRepository
 	EntryRepo      *repositories.EntryRepository
@@ -19,6 +24,12 @@ type GatePassService struct {
 	PickupRepo     *repositories.GatePassPickupRepository
 	RoomEntryRepo  *repositories.RoomEntryRepository
 	MediaRepo      *repositories.GatePassMediaRepository
+	StockLedger    *GatarStockService
+	Weighments     *WeighmentService
+	AmendmentRepo  *repositories.GatePassAmendmentRepository
+	Notifier       *NotificationService
+	Slots          *PickupSlotService
+	Approvals      *ApprovalService
 }
 
 func NewGatePassService(
@@ -39,8 +50,79 @@ func NewGatePassService(
 	}
 }
 
+// SetStockLedger enables taking pickups out of the per-gatar stock ledger
+func (s *GatePassService) SetStockLedger(ledger *GatarStockService) {
+	s.StockLedger = ledger
+}
+
+// SetWeighmentService enables tying outbound truck weighments to pickups
+func (s *GatePassService) SetWeighmentService(weighments *WeighmentService) {
+	s.Weighments = weighments
+}
+
+// SetAmendmentRepo enables amending the quantity or gate of approved gate passes
+func (s *GatePassService) SetAmendmentRepo(repo *repositories.GatePassAmendmentRepository) {
+	s.AmendmentRepo = repo
+}
+
+// SetNotificationService enables SMS to customers about cancelled, amended and re-issued gate passes
+func (s *GatePassService) SetNotificationService(notifier *NotificationService) {
+	s.Notifier = notifier
+}
+
+// SetPickupSlotService enables booking gate passes into pickup slots on approval
+func (s *GatePassService) SetPickupSlotService(slots *PickupSlotService) {
+	s.Slots = slots
+}
+
+// SetApprovalService routes gate pass approvals through the approval policies. Gate passes
+// expire on their own pickup window, so no expiry hook is registered; an approval request
+// left open on an expired gate pass is closed by the engine's background sweep.
+func (s *GatePassService) SetApprovalService(approvals *ApprovalService) {
+	s.Approvals = approvals
+}
+
+// gatePassApprovalSubject describes a gate pass for the given number of bags to the approval engine
+func gatePassApprovalSubject(gatePass *models.GatePass, bags int) *models.ApprovalSubject {
+	return &models.ApprovalSubject{
+		Flow:        models.ApprovalFlowGatePass,
+		SubjectID:   gatePass.ID,
+		Amount:      float64(bags),
+		Summary:     fmt.Sprintf("Gate pass #%d for thock %s, %d bags", gatePass.ID, gatePass.ThockNumber, bags),
+		RequestedBy: gatePass.IssuedByUserID,
+	}
+}
+
+// gatePassApprovalTerms is what an approver agrees to beyond the number of bags. Approvers
+// choosing another gate, expiry or pickup slot start the approval over.
+func gatePassApprovalTerms(req *models.UpdateGatePassRequest) string {
+	terms := "gate " + req.GateNo
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		terms += ", expires " + *req.ExpiresAt
+	}
+	if req.PickupSlotID != nil {
+		terms += fmt.Sprintf(", pickup slot %d", *req.PickupSlotID)
+	}
+	return terms
+}
+
+// submitGatePassApproval opens the approval request of a newly requested gate pass
+func submitGatePassApproval(ctx context.Context, approvals *ApprovalService, gatePass *models.GatePass) {
+	if approvals == nil {
+		return
+	}
+	if _, err := approvals.Submit(ctx, gatePassApprovalSubject(gatePass, gatePass.RequestedQuantity)); err != nil {
+		log.Printf("[GatePass] Failed to open approval for gate pass %d: %v", gatePass.ID, err)
+	}
+}
+
 // CreateGatePass creates a gate pass and logs the event
 func (s *GatePassService) CreateGatePass(ctx context.Context, req *models.CreateGatePassRequest, userID int) (*models.GatePass, error) {
+	return s.issueGatePass(ctx, req, userID, nil)
+}
+
+// issueGatePass creates a gate pass, optionally as the re-issue of an expired one
+func (s *GatePassService) issueGatePass(ctx context.Context, req *models.CreateGatePassRequest, userID int, reissuedFromID *int) (*models.GatePass, error) {
 	// Verify payment if required
 	if !req.PaymentVerified {
 		return nil, errors.New("payment must be verified before issuing gate pass")
@@ -81,7 +163,8 @@ func (s *GatePassService) CreateGatePass(ctx context.Context, req *models.Create
 		PaymentVerified:   req.PaymentVerified,
 		PaymentAmount:     &req.PaymentAmount,
 		IssuedByUserID:    &userID,
-		Status:            "pending",
+		Status:            models.GatePassStatusRequested,
+		ReissuedFromID:    reissuedFromID,
 	}
 
 	if req.Remarks != "" {
@@ -92,6 +175,12 @@ func (s *GatePassService) CreateGatePass(ctx context.Context, req *models.Create
 	if err != nil {
 		return nil, err
 	}
+	if reissuedFromID != nil {
+		s.recordRequested(ctx, gatePass, fmt.Sprintf("Re-issued from expired gate pass #%d", *reissuedFromID), userID)
+	} else {
+		s.recordRequested(ctx, gatePass, "Issued by employee", userID)
+	}
+	submitGatePassApproval(ctx, s.Approvals, gatePass)
 
 	// Log GATE_PASS_ISSUED event (2nd last event)
 	if req.EntryID != nil {
@@ -108,6 +197,20 @@ func (s *GatePassService) CreateGatePass(ctx context.Context, req *models.Create
 	return gatePass, nil
 }
 
+// recordRequested starts the status history of a new gate pass
+func (s *GatePassService) recordRequested(ctx context.Context, gatePass *models.GatePass, reason string, userID int) {
+	t := newGatePassTransition(gatePass, gatePass.Status, reason, userID)
+	t.FromStatus = ""
+	if err := s.GatePassRepo.RecordTransition(ctx, t); err != nil {
+		log.Printf("[GatePass] Failed to record request of gate pass %d: %v", gatePass.ID, err)
+	}
+}
+
+// GetTransitions returns a gate pass's status history
+func (s *GatePassService) GetTransitions(ctx context.Context, gatePassID int) ([]*models.GatePassTransition, error) {
+	return s.GatePassRepo.ListTransitions(ctx, gatePassID)
+}
+
 // ListAllGatePasses retrieves all gate passes
 func (s *GatePassService) ListAllGatePasses(ctx context.Context) ([]map[string]interface{}, error) {
 	return s.GatePassRepo.ListAllGatePasses(ctx)
@@ -118,68 +221,48 @@ func (s *GatePassService) ListPendingGatePasses(ctx context.Context) ([]map[stri
 	return s.GatePassRepo.ListPendingGatePasses(ctx)
 }
 
-// ApproveGatePass approves a gate pass and updates quantity/gate
-func (s *GatePassService) ApproveGatePass(ctx context.Context, id int, req *models.UpdateGatePassRequest, userID int) error {
+// ApproveGatePass approves a gate pass and updates quantity/gate. With an approval policy
+// in place it returns the approval, still pending while more approvers are needed; the gate
+// pass is only approved once it is approved.
+func (s *GatePassService) ApproveGatePass(ctx context.Context, id int, req *models.UpdateGatePassRequest, userID int) (*models.ApprovalRequest, error) {
 	gatePass, err := s.GatePassRepo.GetGatePass(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Allow rejecting approved gate passes if no pickups yet
-	if req.Status == "rejected" {
-		if gatePass.Status != "pending" && gatePass.Status != "approved" {
-			return errors.New("gate pass cannot be rejected - status is " + gatePass.Status)
-		}
-		if gatePass.TotalPickedUp > 0 {
-			return errors.New("cannot reject gate pass - items already picked up")
-		}
-	} else if gatePass.Status != "pending" {
-		return errors.New("gate pass is not pending")
+	// The approve screen approves, rejects, or updates a request while it stays requested
+	switch req.Status {
+	case models.GatePassStatusRequested, models.GatePassStatusApproved, models.GatePassStatusRejected:
+	default:
+		return nil, fmt.Errorf("status must be %s, %s or %s", models.GatePassStatusRequested, models.GatePassStatusApproved, models.GatePassStatusRejected)
+	}
+	if err := checkGatePassTransition(gatePass, req.Status); err != nil {
+		return nil, err
+	}
+	if req.Status == models.GatePassStatusRejected && gatePass.TotalPickedUp > 0 {
+		return nil, fmt.Errorf("%w: cannot reject gate pass - items already picked up", ErrIllegalGatePassTransition)
 	}
 
 	// Check if gate pass has expired (30 hours from issue time)
-	if gatePass.ExpiresAt != nil && timeutil.Now().After(*gatePass.ExpiresAt) {
+	if gatePass.Status == models.GatePassStatusRequested && gatePass.ExpiresAt != nil && timeutil.Now().After(*gatePass.ExpiresAt) {
 		// Auto-expire the gate pass
-		s.GatePassRepo.UpdateGatePass(ctx, id, 0, "", "expired", "Auto-expired: Not approved within 30 hours", userID)
-		return errors.New("gate pass has expired - not approved within 30 hours")
+		t := newGatePassTransition(gatePass, models.GatePassStatusExpired, "Auto-expired: Not approved within 30 hours", userID)
+		if err := s.GatePassRepo.SetStatus(ctx, id, t); err != nil {
+			log.Printf("[GatePass] Failed to expire gate pass %d: %v", id, err)
+		}
+		return nil, fmt.Errorf("%w: gate pass has expired - not approved within 30 hours", ErrIllegalGatePassTransition)
 	}
 
 	// Validate approved quantity against available inventory
-	if req.Status == "approved" && gatePass.EntryID != nil {
-		// Get current inventory from room entries
-		currentInventory, err := s.RoomEntryRepo.GetTotalQuantityByThockNumber(ctx, gatePass.ThockNumber)
-		if err != nil {
-			currentInventory = 0
-		}
-
-		// Calculate total already allocated to other gate passes (excluding this one)
-		// This includes pending, approved, and partially_completed gate passes
-		totalAllocated, err := s.GatePassRepo.GetTotalApprovedQuantityForEntry(ctx, *gatePass.EntryID)
-		if err != nil {
-			totalAllocated = 0
-		}
-		// Subtract this gate pass's requested quantity since it's already included in the total
-		totalAllocated -= gatePass.RequestedQuantity
-
-		// Calculate available inventory
-		availableInventory := currentInventory - totalAllocated
-		if availableInventory < 0 {
-			availableInventory = 0
-		}
-
-		// Validate approved quantity doesn't exceed available stock
-		if req.ApprovedQuantity > availableInventory {
-			return errors.New("insufficient inventory: approved quantity (" +
-				strconv.Itoa(req.ApprovedQuantity) + ") exceeds available stock (" +
-				strconv.Itoa(availableInventory) + "). Current inventory: " +
-				strconv.Itoa(currentInventory) + ", already allocated: " +
-				strconv.Itoa(totalAllocated) + ")")
+	if req.Status == models.GatePassStatusApproved && gatePass.EntryID != nil {
+		if err := s.checkInventory(ctx, gatePass, req.ApprovedQuantity); err != nil {
+			return nil, err
 		}
 	}
 
 	// Determine expiration time for approval
 	var expiresAt *time.Time
-	if req.Status == "approved" {
+	if req.Status == models.GatePassStatusApproved {
 		if req.ExpiresAt != nil && *req.ExpiresAt != "" {
 			// Custom expiration time set by employee
 			parsedTime, parseErr := time.Parse(time.RFC3339, *req.ExpiresAt)
@@ -187,7 +270,7 @@ func (s *GatePassService) ApproveGatePass(ctx context.Context, id int, req *mode
 				// Try parsing without timezone
 				parsedTime, parseErr = time.Parse("2006-01-02T15:04", *req.ExpiresAt)
 				if parseErr != nil {
-					return errors.New("invalid expiration time format")
+					return nil, errors.New("invalid expiration time format")
 				}
 			}
 			expiresAt = &parsedTime
@@ -202,21 +285,66 @@ func (s *GatePassService) ApproveGatePass(ctx context.Context, id int, req *mode
 		}
 	}
 
+	// The approval policy decides who approves and how many must agree before the gate pass moves
+	var approval *models.ApprovalRequest
+	if s.Approvals != nil {
+		creds := req.ApprovalCredentials
+		switch req.Status {
+		case models.GatePassStatusApproved:
+			subject := gatePassApprovalSubject(gatePass, req.ApprovedQuantity)
+			subject.Terms = gatePassApprovalTerms(req)
+			approval, err = s.Approvals.Approve(ctx, subject, userID, &creds)
+			if err != nil {
+				return nil, err
+			}
+			if approvalPending(approval) {
+				return approval, nil
+			}
+		case models.GatePassStatusRejected:
+			approval, err = s.Approvals.Reject(ctx, gatePassApprovalSubject(gatePass, gatePass.RequestedQuantity), userID, req.Remarks, creds.IPAddress)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Book the pickup slot first so a full slot stops the approval
+	bookSlot := req.PickupSlotID != nil && req.Status != models.GatePassStatusRejected && s.Slots != nil
+	if bookSlot {
+		if err := s.Slots.Book(ctx, gatePass, req.PickupSlotID, req.ApprovedQuantity); err != nil {
+			return nil, gatePassTransitionError(gatePass, err)
+		}
+	}
+
 	// Use UpdateGatePassWithExpiration if expiration is set
+	t := newGatePassTransition(gatePass, req.Status, req.Remarks, userID)
 	if expiresAt != nil {
-		err = s.GatePassRepo.UpdateGatePassWithExpiration(ctx, id, req.ApprovedQuantity, req.GateNo, req.Status, req.Remarks, userID, expiresAt)
+		err = s.GatePassRepo.UpdateGatePassWithExpiration(ctx, id, req.ApprovedQuantity, req.GateNo, req.Remarks, userID, expiresAt, t)
 	} else if req.RequestSource != "" {
-		err = s.GatePassRepo.UpdateGatePassWithSource(ctx, id, req.ApprovedQuantity, req.GateNo, req.Status, req.RequestSource, req.Remarks, userID)
+		err = s.GatePassRepo.UpdateGatePassWithSource(ctx, id, req.ApprovedQuantity, req.GateNo, req.RequestSource, req.Remarks, userID, t)
 	} else {
-		err = s.GatePassRepo.UpdateGatePass(ctx, id, req.ApprovedQuantity, req.GateNo, req.Status, req.Remarks, userID)
+		err = s.GatePassRepo.UpdateGatePass(ctx, id, req.ApprovedQuantity, req.GateNo, req.Remarks, userID, t)
 	}
 
 	if err != nil {
-		return err
+		if bookSlot {
+			// Put the gate pass back in the slot it had
+			if slotErr := s.Slots.Book(ctx, gatePass, gatePass.PickupSlotID, slotBags(gatePass)); slotErr != nil {
+				log.Printf("[GatePass] Failed to restore pickup slot of gate pass %d: %v", id, slotErr)
+			}
+		}
+		return nil, gatePassTransitionError(gatePass, err)
+	}
+
+	// Approval starts a fresh pickup window; keep it open until the end of a later slot
+	if expiresAt != nil && s.Slots != nil && (bookSlot || gatePass.PickupSlotID != nil) {
+		if err := s.Slots.Repo.ExtendToSlot(ctx, id); err != nil {
+			log.Printf("[GatePass] Failed to extend gate pass %d to its pickup slot: %v", id, err)
+		}
 	}
 
 	// Log GATE_PASS_REJECTED event if status is rejected
-	if req.Status == "rejected" && gatePass.EntryID != nil {
+	if req.Status == models.GatePassStatusRejected && gatePass.EntryID != nil {
 		event := &models.EntryEvent{
 			EntryID:         *gatePass.EntryID,
 			EventType:       "GATE_PASS_REJECTED",
@@ -227,7 +355,7 @@ func (s *GatePassService) ApproveGatePass(ctx context.Context, id int, req *mode
 		s.EntryEventRepo.Create(ctx, event)
 	}
 
-	return nil
+	return approval, nil
 }
 
 // CompleteGatePass marks items as taken out (LAST event)
@@ -237,9 +365,9 @@ func (s *GatePassService) CompleteGatePass(ctx context.Context, id int, userID i
 		return err
 	}
 
-	// Allow completion from approved or partially_completed status
-	if gatePass.Status != "approved" && gatePass.Status != "partially_completed" {
-		return errors.New("gate pass must be approved or partially completed before completion")
+	// Allow completion from approved or partially picked status
+	if err := checkGatePassTransition(gatePass, models.GatePassStatusCompleted); err != nil {
+		return err
 	}
 
 	// CRITICAL FIX: Validate that items were actually picked up via RecordPickup
@@ -248,9 +376,10 @@ func (s *GatePassService) CompleteGatePass(ctx context.Context, id int, userID i
 		return errors.New("cannot complete: no items picked up yet. Use Record Pickup to log items before completing")
 	}
 
-	err = s.GatePassRepo.CompleteGatePass(ctx, id)
+	reason := fmt.Sprintf("Closed after %d items picked up", gatePass.TotalPickedUp)
+	err = s.GatePassRepo.CompleteGatePass(ctx, id, newGatePassTransition(gatePass, models.GatePassStatusCompleted, reason, userID))
 	if err != nil {
-		return err
+		return gatePassTransitionError(gatePass, err)
 	}
 
 	// Log ITEMS_OUT event (LAST event)
@@ -298,9 +427,9 @@ func (s *GatePassService) RecordPickup(ctx context.Context, req *models.RecordPi
 		return 0, err
 	}
 
-	// Validate gate pass status
-	if gatePass.Status != "approved" && gatePass.Status != "partially_completed" {
-		return 0, errors.New("gate pass must be approved to record pickup")
+	// Validate gate pass status: a pickup leaves it partially picked or completed
+	if err := checkGatePassTransition(gatePass, models.GatePassStatusPartiallyPicked); err != nil {
+		return 0, err
 	}
 
 	// Check if expired
@@ -322,6 +451,17 @@ func (s *GatePassService) RecordPickup(ctx context.Context, req *models.RecordPi
 		return 0, errors.New("pickup quantity must be greater than zero")
 	}
 
+	// The truck's weighment, if any, must belong to this gate pass
+	if req.WeighmentID != nil && s.Weighments != nil {
+		weighment, err := s.Weighments.Get(ctx, *req.WeighmentID)
+		if err != nil {
+			return 0, err
+		}
+		if weighment.GatePassID == nil || *weighment.GatePassID != req.GatePassID {
+			return 0, fmt.Errorf("weighment %d is not for gate pass %d", weighment.ID, req.GatePassID)
+		}
+	}
+
 	// CRITICAL FIX: Auto-fill storage location from room_entries if not provided
 	// This ensures inventory is ALWAYS reduced when pickup is recorded
 	roomNo := req.RoomNo
@@ -358,6 +498,7 @@ func (s *GatePassService) RecordPickup(ctx context.Context, req *models.RecordPi
 		GatePassID:       req.GatePassID,
 		PickupQuantity:   req.PickupQuantity,
 		PickedUpByUserID: userID,
+		VehicleVisitID:   req.VehicleVisitID,
 	}
 
 	pickup.RoomNo = &roomNo
@@ -367,29 +508,23 @@ func (s *GatePassService) RecordPickup(ctx context.Context, req *models.RecordPi
 		pickup.Remarks = &req.Remarks
 	}
 
-	// CRITICAL FIX: Execute all database operations in sequence with proper error handling
-	// TODO: Implement proper database transactions to ensure atomicity
-
-	// Step 1: Create pickup record
-	err = s.PickupRepo.CreatePickup(ctx, pickup)
-	if err != nil {
-		return 0, errors.New("failed to create pickup record: " + err.Error())
+	// Claim the quantity on the gate pass and save the pickup, its gatar breakdown and its
+	// stock ledger rows in one transaction. The update is guarded by the status read above
+	// and the approved quantity, so concurrent pickups cannot overdraw it.
+	t := newGatePassTransition(gatePass, "", fmt.Sprintf("Picked up %d items", req.PickupQuantity), userID) // The update decides the new status
+	var plan func([]*models.GatarBalance) []*models.GatarStockMovement
+	if s.StockLedger != nil {
+		plan = s.StockLedger.PickupPlan(pickup, gatePass.ThockNumber, req.GatarBreakdown)
 	}
-
-	// Step 1b: Save gatar breakdown if provided
-	if len(req.GatarBreakdown) > 0 {
-		err = s.PickupRepo.CreateGatarBreakdown(ctx, pickup.ID, req.GatarBreakdown)
-		if err != nil {
-			// Non-critical - log but don't fail the pickup
-			// The main pickup is already recorded
-		}
+	if err := s.GatePassRepo.RecordPickup(ctx, gatePass.ThockNumber, pickup, req.GatarBreakdown, t, plan); err != nil {
+		return 0, gatePassTransitionError(gatePass, err)
 	}
 
-	// Step 2: Update gate pass total_picked_up and status
-	err = s.GatePassRepo.UpdatePickupQuantity(ctx, req.GatePassID, req.PickupQuantity)
-	if err != nil {
-		return 0, errors.New("CRITICAL ERROR: pickup created but gate pass update failed - " +
-			"manual intervention required for gate pass ID " + strconv.Itoa(req.GatePassID) + ": " + err.Error())
+	// Tie the truck's weighment to this pickup
+	if req.WeighmentID != nil && s.Weighments != nil {
+		if err := s.Weighments.AttachPickup(ctx, *req.WeighmentID, pickup); err != nil {
+			log.Printf("[Weighment] Failed to attach weighment %d to pickup %d: %v", *req.WeighmentID, pickup.ID, err)
+		}
 	}
 
 	// NOTE: We intentionally do NOT reduce room_entries.quantity here
@@ -467,3 +602,311 @@ func (s *GatePassService) SaveMediaMetadata(ctx context.Context, media *models.G
 func (s *GatePassService) GetMediaByGatePassID(ctx context.Context, gatePassID int) ([]models.GatePassMedia, error) {
 	return s.MediaRepo.ListByGatePassID(ctx, gatePassID)
 }
+
+// checkInventory fails if a gate pass's entry does not have quantity bags in storage
+// beyond what its other gate passes hold
+func (s *GatePassService) checkInventory(ctx context.Context, gatePass *models.GatePass, quantity int) error {
+	// Get current inventory from room entries
+	currentInventory, err := s.RoomEntryRepo.GetTotalQuantityByThockNumber(ctx, gatePass.ThockNumber)
+	if err != nil {
+		currentInventory = 0
+	}
+
+	// Calculate total already allocated to other gate passes (excluding this one)
+	// This includes pending, approved, and partially_completed gate passes
+	totalAllocated, err := s.GatePassRepo.GetTotalApprovedQuantityForEntry(ctx, *gatePass.EntryID)
+	if err != nil {
+		totalAllocated = 0
+	}
+	// Subtract this gate pass's own allocation since it's already included in the total
+	if gatePass.ApprovedQuantity != nil {
+		totalAllocated -= *gatePass.ApprovedQuantity
+	} else {
+		totalAllocated -= gatePass.RequestedQuantity
+	}
+
+	// Calculate available inventory
+	availableInventory := currentInventory - totalAllocated
+	if availableInventory < 0 {
+		availableInventory = 0
+	}
+
+	// Validate approved quantity doesn't exceed available stock
+	if quantity > availableInventory {
+		return errors.New("insufficient inventory: approved quantity (" +
+			strconv.Itoa(quantity) + ") exceeds available stock (" +
+			strconv.Itoa(availableInventory) + "). Current inventory: " +
+			strconv.Itoa(currentInventory) + ", already allocated: " +
+			strconv.Itoa(totalAllocated) + ")")
+	}
+	return nil
+}
+
+// notifyCustomer sends a gate pass SMS in the background
+func (s *GatePassService) notifyCustomer(gatePass *models.GatePass, send func(ctx context.Context, entry *models.Entry) error) {
+	if s.Notifier == nil {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		entry, err := s.EntryRepo.GetByThockNumber(ctx, gatePass.ThockNumber)
+		if err != nil {
+			log.Printf("[GatePass] No entry to notify for gate pass %d: %v", gatePass.ID, err)
+			return
+		}
+		if err := send(ctx, entry); err != nil {
+			log.Printf("[GatePass] Failed to notify customer about gate pass %d: %v", gatePass.ID, err)
+		}
+	}()
+}
+
+// logEntryEvent records a gate pass event on its entry's timeline
+func (s *GatePassService) logEntryEvent(ctx context.Context, gatePass *models.GatePass, eventType, status, notes string, userID int) {
+	if gatePass.EntryID == nil {
+		return
+	}
+	s.EntryEventRepo.Create(ctx, &models.EntryEvent{
+		EntryID:         *gatePass.EntryID,
+		EventType:       eventType,
+		Status:          status,
+		Notes:           notes,
+		CreatedByUserID: userID,
+	})
+}
+
+// CancelGatePass cancels an open gate pass issued by mistake. Bags already picked up stay
+// out; the rest of the approved quantity is released back to the entry.
+func (s *GatePassService) CancelGatePass(ctx context.Context, id int, reason string, userID int) (*models.GatePass, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required to cancel a gate pass", ErrInvalidGatePassChange)
+	}
+	gatePass, err := s.GatePassRepo.GetGatePass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGatePassTransition(gatePass, models.GatePassStatusCancelled); err != nil {
+		return nil, err
+	}
+
+	t := newGatePassTransition(gatePass, models.GatePassStatusCancelled, reason, userID)
+	if err := s.GatePassRepo.CancelGatePass(ctx, id, t); err != nil {
+		return nil, gatePassTransitionError(gatePass, err)
+	}
+	if s.AmendmentRepo != nil {
+		if err := s.AmendmentRepo.RejectPending(ctx, id, &userID, "Gate pass cancelled"); err != nil {
+			log.Printf("[GatePass] Failed to close amendments of cancelled gate pass %d: %v", id, err)
+		}
+	}
+
+	released := gatePassRemaining(gatePass)
+	s.logEntryEvent(ctx, gatePass, "GATE_PASS_CANCELLED", models.GatePassStatusCancelled,
+		fmt.Sprintf("Gate pass #%d cancelled, %d items released. Reason: %s", id, released, reason), userID)
+
+	gatePass.Status = models.GatePassStatusCancelled
+	gatePass.FinalApprovedQuantity = &gatePass.TotalPickedUp
+	s.notifyCustomer(gatePass, func(ctx context.Context, entry *models.Entry) error {
+		return s.Notifier.NotifyGatePassCancelled(ctx, entry, gatePass, reason)
+	})
+	return gatePass, nil
+}
+
+// RequestAmendment asks to change the quantity or gate of an approved gate pass, for
+// example to let the customer take more after a partial pickup. The change waits for
+// approval by someone other than the requester.
+func (s *GatePassService) RequestAmendment(ctx context.Context, id int, req *models.AmendGatePassRequest, userID int) (*models.GatePassAmendment, error) {
+	if s.AmendmentRepo == nil {
+		return nil, errors.New("gate pass amendments are not available")
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required to amend a gate pass", ErrInvalidGatePassChange)
+	}
+	gatePass, err := s.GatePassRepo.GetGatePass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if gatePass.Status != models.GatePassStatusApproved && gatePass.Status != models.GatePassStatusPartiallyPicked {
+		return nil, fmt.Errorf("%w: only approved gate passes can be amended; gate pass #%d is %s", ErrIllegalGatePassTransition, id, gatePass.Status)
+	}
+
+	amendment := &models.GatePassAmendment{
+		GatePassID:        id,
+		OldQuantity:       gatePass.RequestedQuantity,
+		NewQuantity:       req.ApprovedQuantity,
+		NewGateNo:         strings.TrimSpace(req.GateNo),
+		Reason:            req.Reason,
+		RequestedByUserID: &userID,
+	}
+	if gatePass.ApprovedQuantity != nil {
+		amendment.OldQuantity = *gatePass.ApprovedQuantity
+	}
+	if gatePass.GateNo != nil {
+		amendment.OldGateNo = *gatePass.GateNo
+	}
+	if amendment.NewGateNo == "" {
+		amendment.NewGateNo = amendment.OldGateNo
+	}
+	if amendment.NewQuantity == amendment.OldQuantity && amendment.NewGateNo == amendment.OldGateNo {
+		return nil, fmt.Errorf("%w: the amendment changes nothing", ErrInvalidGatePassChange)
+	}
+	if amendment.NewQuantity <= gatePass.TotalPickedUp {
+		return nil, fmt.Errorf("%w: %d items are already picked up; complete or cancel the gate pass instead of amending it to %d",
+			ErrInvalidGatePassChange, gatePass.TotalPickedUp, amendment.NewQuantity)
+	}
+	if gatePass.EntryID != nil {
+		if err := s.checkInventory(ctx, gatePass, amendment.NewQuantity); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGatePassChange, err)
+		}
+	}
+
+	if err := s.AmendmentRepo.Create(ctx, amendment); err != nil {
+		return nil, err
+	}
+	return amendment, nil
+}
+
+// ApproveAmendment applies an amendment to its gate pass, which gets a fresh pickup window
+func (s *GatePassService) ApproveAmendment(ctx context.Context, amendmentID int, remarks string, userID int, isAdmin bool) (*models.GatePassAmendment, error) {
+	if s.AmendmentRepo == nil {
+		return nil, errors.New("gate pass amendments are not available")
+	}
+	amendment, err := s.AmendmentRepo.Get(ctx, amendmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAmendmentDecider(amendment, userID, isAdmin); err != nil {
+		return nil, err
+	}
+	gatePass, err := s.GatePassRepo.GetGatePass(ctx, amendment.GatePassID)
+	if err != nil {
+		return nil, err
+	}
+	// Stock may have been allocated elsewhere since the amendment was asked for
+	if gatePass.EntryID != nil {
+		if err := s.checkInventory(ctx, gatePass, amendment.NewQuantity); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGatePassChange, err)
+		}
+	}
+
+	if err := s.AmendmentRepo.Approve(ctx, amendment, userID, strings.TrimSpace(remarks)); err != nil {
+		if errors.Is(err, repositories.ErrGatePassStatusChanged) {
+			return nil, fmt.Errorf("%w: gate pass #%d is %s with %d items picked up; the amendment no longer applies",
+				ErrIllegalGatePassTransition, gatePass.ID, gatePass.Status, gatePass.TotalPickedUp)
+		}
+		return nil, err
+	}
+
+	s.logEntryEvent(ctx, gatePass, "GATE_PASS_AMENDED", gatePass.Status,
+		fmt.Sprintf("Gate pass #%d amended from %d items at gate %s to %d items at gate %s. Reason: %s",
+			gatePass.ID, amendment.OldQuantity, amendment.OldGateNo, amendment.NewQuantity, amendment.NewGateNo, amendment.Reason), userID)
+	s.notifyCustomer(gatePass, func(ctx context.Context, entry *models.Entry) error {
+		return s.Notifier.NotifyGatePassAmended(ctx, entry, gatePass, amendment)
+	})
+	return amendment, nil
+}
+
+// RejectAmendment turns down an amendment; the gate pass stays as it was
+func (s *GatePassService) RejectAmendment(ctx context.Context, amendmentID int, remarks string, userID int, isAdmin bool) (*models.GatePassAmendment, error) {
+	if s.AmendmentRepo == nil {
+		return nil, errors.New("gate pass amendments are not available")
+	}
+	amendment, err := s.AmendmentRepo.Get(ctx, amendmentID)
+	if err != nil {
+		return nil, err
+	}
+	// The requester may withdraw their own amendment
+	if amendment.RequestedByUserID == nil || *amendment.RequestedByUserID != userID {
+		if err := checkAmendmentDecider(amendment, userID, isAdmin); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.AmendmentRepo.Reject(ctx, amendment, userID, strings.TrimSpace(remarks)); err != nil {
+		return nil, err
+	}
+	return amendment, nil
+}
+
+// checkAmendmentDecider requires a second person to approve an amendment; admins may
+// approve their own
+func checkAmendmentDecider(amendment *models.GatePassAmendment, userID int, isAdmin bool) error {
+	if amendment.Status != models.GatePassAmendmentPending {
+		return repositories.ErrAmendmentDecided
+	}
+	if !isAdmin && amendment.RequestedByUserID != nil && *amendment.RequestedByUserID == userID {
+		return fmt.Errorf("%w: an amendment must be approved by someone other than the person who asked for it", ErrInvalidGatePassChange)
+	}
+	return nil
+}
+
+// ListAmendments returns a gate pass's amendments
+func (s *GatePassService) ListAmendments(ctx context.Context, gatePassID int) ([]*models.GatePassAmendment, error) {
+	if s.AmendmentRepo == nil {
+		return nil, nil
+	}
+	return s.AmendmentRepo.ListByGatePass(ctx, gatePassID)
+}
+
+// ListPendingAmendments returns the amendments waiting for approval
+func (s *GatePassService) ListPendingAmendments(ctx context.Context) ([]*models.GatePassAmendment, error) {
+	if s.AmendmentRepo == nil {
+		return nil, nil
+	}
+	return s.AmendmentRepo.ListPending(ctx)
+}
+
+// ReissueGatePass issues a new gate pass, waiting for approval, for the bags an expired
+// one did not deliver. An expired gate pass can be re-issued once.
+func (s *GatePassService) ReissueGatePass(ctx context.Context, id int, req *models.ReissueGatePassRequest, userID int) (*models.GatePass, error) {
+	expired, err := s.GatePassRepo.GetGatePass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if expired.Status != models.GatePassStatusExpired {
+		return nil, fmt.Errorf("%w: only expired gate passes can be re-issued; gate pass #%d is %s", ErrIllegalGatePassTransition, id, expired.Status)
+	}
+	if reissueID, err := s.GatePassRepo.FindReissue(ctx, id); err == nil {
+		return nil, fmt.Errorf("%w as gate pass #%d", repositories.ErrGatePassAlreadyReissued, reissueID)
+	}
+
+	remaining := gatePassRemaining(expired)
+	quantity := req.Quantity
+	if quantity == 0 {
+		quantity = remaining
+	}
+	if quantity <= 0 || quantity > remaining {
+		return nil, fmt.Errorf("%w: gate pass #%d has %d items left to re-issue", ErrInvalidGatePassChange, id, remaining)
+	}
+
+	remarks := strings.TrimSpace(req.Remarks)
+	if remarks == "" {
+		remarks = fmt.Sprintf("Re-issued from expired gate pass #%d", id)
+	}
+	reissued, err := s.issueGatePass(ctx, &models.CreateGatePassRequest{
+		CustomerID:        expired.CustomerID,
+		ThockNumber:       expired.ThockNumber,
+		EntryID:           expired.EntryID,
+		FamilyMemberID:    expired.FamilyMemberID,
+		FamilyMemberName:  expired.FamilyMemberName,
+		RequestedQuantity: quantity,
+		PaymentVerified:   true, // Verified when the expired gate pass was issued or approved
+		Remarks:           remarks,
+	}, userID, &id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyCustomer(reissued, func(ctx context.Context, entry *models.Entry) error {
+		return s.Notifier.NotifyGatePassReissued(ctx, entry, expired, reissued)
+	})
+	return reissued, nil
+}
+
+// SetPickupSlot moves an open gate pass to another pickup slot, or out of its slot when
+// slotID is nil
+func (s *GatePassService) SetPickupSlot(ctx context.Context, id int, slotID *int) (*models.GatePass, error) {
+	if s.Slots == nil {
+		return nil, errors.New("pickup slots are not enabled")
+	}
+	return s.Slots.SetGatePassSlot(ctx, id, slotID)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// ErrIllegalGatePassTransition is returned for a status change the gate pass state machine
+// does not allow, or when another request changed the gate pass first
+var ErrIllegalGatePassTransition = errors.New("illegal gate pass transition")
+
+// gatePassTransitions lists the statuses each status can move to:
+//
+//	requested → approved → partially_picked → completed
+//	    any open status → expired / cancelled; requested or approved → rejected
+//
+// Completed, expired, cancelled and rejected are final. A requested gate pass may be
+// updated without leaving requested, and each pickup of a partially picked one keeps it
+// partially picked until the last bag.
+var gatePassTransitions = map[string][]string{
+	models.GatePassStatusRequested: {
+		models.GatePassStatusRequested,
+		models.GatePassStatusApproved,
+		models.GatePassStatusRejected,
+		models.GatePassStatusExpired,
+		models.GatePassStatusCancelled,
+	},
+	models.GatePassStatusApproved: {
+		models.GatePassStatusPartiallyPicked,
+		models.GatePassStatusCompleted,
+		models.GatePassStatusRejected,
+		models.GatePassStatusExpired,
+		models.GatePassStatusCancelled,
+	},
+	models.GatePassStatusPartiallyPicked: {
+		models.GatePassStatusPartiallyPicked,
+		models.GatePassStatusCompleted,
+		models.GatePassStatusExpired,
+		models.GatePassStatusCancelled,
+	},
+}
+
+// CanTransitionGatePass reports whether a gate pass in status from may move to status to
+func CanTransitionGatePass(from, to string) bool {
+	for _, next := range gatePassTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGatePassTransition returns ErrIllegalGatePassTransition if gp may not move to status to
+func checkGatePassTransition(gp *models.GatePass, to string) error {
+	if !CanTransitionGatePass(gp.Status, to) {
+		return fmt.Errorf("%w: gate pass #%d is %s and cannot become %s", ErrIllegalGatePassTransition, gp.ID, gp.Status, to)
+	}
+	return nil
+}
+
+// newGatePassTransition starts the history record of moving gp to status to
+func newGatePassTransition(gp *models.GatePass, to, reason string, userID int) *models.GatePassTransition {
+	t := &models.GatePassTransition{
+		GatePassID: gp.ID,
+		FromStatus: gp.Status,
+		ToStatus:   to,
+		Reason:     reason,
+	}
+	if userID > 0 {
+		t.ChangedByUserID = &userID
+	}
+	return t
+}
+
+// gatePassTransitionError turns a lost race on the status guard into an illegal transition
+func gatePassTransitionError(gp *models.GatePass, err error) error {
+	if errors.Is(err, repositories.ErrGatePassStatusChanged) {
+		return fmt.Errorf("%w: gate pass #%d is no longer %s: %v", ErrIllegalGatePassTransition, gp.ID, gp.Status, err)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
 	"regexp"
 
 	"cold-backend/internal/models"
@@ -11,12 +12,18 @@ import (
 
 type GuardEntryService struct {
 	GuardEntryRepo *repositories.GuardEntryRepository
+	Vehicles       *VehicleService
 }
 
 func NewGuardEntryService(repo *repositories.GuardEntryRepository) *GuardEntryService {
 	return &GuardEntryService{GuardEntryRepo: repo}
 }
 
+// SetVehicleService books the vehicle of a guard entry into the vehicle register
+func (s *GuardEntryService) SetVehicleService(vehicles *VehicleService) {
+	s.Vehicles = vehicles
+}
+
 // CreateGuardEntry creates a new guard entry with validation
 func (s *GuardEntryService) CreateGuardEntry(ctx context.Context, req *models.CreateGuardEntryRequest, userID int) (*models.GuardEntry, error) {
 	// Validate customer name
@@ -45,6 +52,12 @@ func (s *GuardEntryService) CreateGuardEntry(ctx context.Context, req *models.Cr
 		return nil, errors.New("driver number must be exactly 10 digits")
 	}
 
+	// Validate vehicle_no if provided
+	vehicleNo := NormalizeVehicleNo(req.VehicleNo)
+	if vehicleNo != "" && !vehicleNoRegex.MatchString(vehicleNo) {
+		return nil, errors.New("vehicle number must be 4 to 12 letters and digits")
+	}
+
 	entry := &models.GuardEntry{
 		CustomerID:      req.CustomerID,
 		FamilyMemberID:  req.FamilyMemberID,
@@ -71,6 +84,18 @@ func (s *GuardEntryService) CreateGuardEntry(ctx context.Context, req *models.Cr
 		return nil, err
 	}
 
+	// The arrival is recorded either way; a vehicle already booked in is only logged
+	if vehicleNo != "" && s.Vehicles != nil {
+		_, err := s.Vehicles.CheckIn(ctx, &models.VehicleCheckInRequest{
+			VehicleNo:    vehicleNo,
+			Purpose:      models.VehiclePurposeDelivery,
+			GuardEntryID: &entry.ID,
+		}, userID)
+		if err != nil {
+			log.Printf("[GuardEntry] Failed to book in vehicle %s for guard entry %d: %v", vehicleNo, entry.ID, err)
+		}
+	}
+
 	return entry, nil
 }
 
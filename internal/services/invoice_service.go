@@ -1,28 +1,67 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+
+	"github.com/jung-kurt/gofpdf/v2"
 )
 
+// ErrInvoiceCancelReasonRequired is returned when cancelling an invoice without a reason
+var ErrInvoiceCancelReasonRequired = errors.New("cancellation reason is required")
+
 type InvoiceService struct {
-	repo *repositories.InvoiceRepository
+	repo          *repositories.InvoiceRepository
+	entryRepo     *repositories.EntryRepository
+	customerRepo  *repositories.CustomerRepository
+	settingsRepo  *repositories.SystemSettingRepository
+	tariffService *TariffService
 }
 
-func NewInvoiceService(repo *repositories.InvoiceRepository) *InvoiceService {
-	return &InvoiceService{repo: repo}
+func NewInvoiceService(repo *repositories.InvoiceRepository, entryRepo *repositories.EntryRepository, customerRepo *repositories.CustomerRepository, settingsRepo *repositories.SystemSettingRepository, tariffService *TariffService) *InvoiceService {
+	return &InvoiceService{repo: repo, entryRepo: entryRepo, customerRepo: customerRepo, settingsRepo: settingsRepo, tariffService: tariffService}
 }
 
+// CreateInvoice prices the items from the rent tariff, applies GST and issues a numbered tax invoice
 func (s *InvoiceService) CreateInvoice(ctx context.Context, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
+	if req.CustomerID <= 0 {
+		return nil, errors.New("customer_id is required")
+	}
+	customer, err := s.customerRepo.Get(ctx, req.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("customer not found: %w", err)
+	}
+
+	company, err := s.GetCompany(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.priceItems(ctx, req, company); err != nil {
+		return nil, err
+	}
+
 	invoice := &models.Invoice{
-		CustomerID:  &req.CustomerID,
-		EmployeeID:  &req.EmployeeID,
-		TotalAmount: req.TotalAmount,
-		Notes:       req.Notes,
+		CustomerID:    &req.CustomerID,
+		EmployeeID:    &req.EmployeeID,
+		Notes:         req.Notes,
+		DocumentType:  models.InvoiceTypeTaxInvoice,
+		FinancialYear: FinancialYear(timeutil.Now()),
+		CompanyGSTIN:  company.GSTIN,
+		CustomerGSTIN: customer.GSTIN,
+		PlaceOfSupply: placeOfSupply(customer, company),
 	}
+	applyGST(invoice, req.TotalAmount, company)
 
-	err := s.repo.Create(ctx, invoice, req.Items)
+	err = s.repo.Create(ctx, invoice, req.Items)
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +69,144 @@ func (s *InvoiceService) CreateInvoice(ctx context.Context, req *models.CreateIn
 	return invoice, nil
 }
 
+// CancelInvoice cancels a tax invoice by issuing a credit note for its full value
+func (s *InvoiceService) CancelInvoice(ctx context.Context, id int, employeeID int, reason string) (*models.InvoiceWithDetails, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, ErrInvoiceCancelReasonRequired
+	}
+
+	original, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	creditNote := original.Invoice
+	creditNote.ID = 0
+	creditNote.EmployeeID = &employeeID
+	creditNote.DocumentType = models.InvoiceTypeCreditNote
+	creditNote.FinancialYear = FinancialYear(timeutil.Now())
+	creditNote.Notes = fmt.Sprintf("Against invoice %s: %s", original.InvoiceNumber, reason)
+	creditNote.CancelReason = ""
+	creditNote.CancelledAt = nil
+
+	if err := s.repo.Cancel(ctx, id, reason, &creditNote, original.Items); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, creditNote.ID)
+}
+
+// priceItems sets each thock item's rate from the rent tariff and recomputes the taxable total
+// Every item must be linked to an entry; the rate supplied by the client is never used
+func (s *InvoiceService) priceItems(ctx context.Context, req *models.CreateInvoiceRequest, company *models.InvoiceCompany) error {
+	rateCard, err := s.tariffService.LoadRateCard(ctx)
+	if err != nil {
+		return err
+	}
+	now := timeutil.Now()
+
+	var total float64
+	for i := range req.Items {
+		item := &req.Items[i]
+
+		var entry *models.Entry
+		var err error
+		switch {
+		case item.EntryID != nil:
+			entry, err = s.entryRepo.Get(ctx, *item.EntryID)
+		case item.ThockNumber != "":
+			entry, err = s.entryRepo.GetByThockNumber(ctx, item.ThockNumber)
+		default:
+			return fmt.Errorf("item %d is not linked to a thock", i+1)
+		}
+		if err != nil {
+			return fmt.Errorf("thock not found for item %d: %w", i+1, err)
+		}
+		item.EntryID = &entry.ID
+		item.ThockNumber = entry.ThockNumber
+		item.Rate = rateCard.RateFor(entry, now)
+		if item.Quantity <= 0 || item.Rate < 0 {
+			return fmt.Errorf("invalid quantity or rate for item %d", i+1)
+		}
+		if item.Description == "" {
+			item.Description = "Cold storage rent - Thock " + item.ThockNumber
+		}
+		item.HSNSAC = company.SACCode
+		item.Amount = roundRupees(float64(item.Quantity) * item.Rate)
+		total += item.Amount
+	}
+
+	if len(req.Items) > 0 {
+		req.TotalAmount = roundRupees(total)
+	}
+	return nil
+}
+
+// applyGST splits tax into CGST+SGST when the place of supply is the company's state, otherwise IGST
+func applyGST(invoice *models.Invoice, taxable float64, company *models.InvoiceCompany) {
+	invoice.TaxableAmount = roundRupees(taxable)
+	if company.StateCode == "" || invoice.PlaceOfSupply == company.StateCode {
+		invoice.CGSTRate = company.GSTRate / 2
+		invoice.SGSTRate = company.GSTRate / 2
+		invoice.CGSTAmount = roundRupees(invoice.TaxableAmount * invoice.CGSTRate / 100)
+		invoice.SGSTAmount = roundRupees(invoice.TaxableAmount * invoice.SGSTRate / 100)
+	} else {
+		invoice.IGSTRate = company.GSTRate
+		invoice.IGSTAmount = roundRupees(invoice.TaxableAmount * invoice.IGSTRate / 100)
+	}
+	invoice.TotalAmount = roundRupees(invoice.TaxableAmount + invoice.CGSTAmount + invoice.SGSTAmount + invoice.IGSTAmount)
+}
+
+// placeOfSupply is the customer's GST state: explicit state code, then GSTIN prefix, then the company's state
+func placeOfSupply(customer *models.Customer, company *models.InvoiceCompany) string {
+	if customer.StateCode != "" {
+		return customer.StateCode
+	}
+	if len(customer.GSTIN) >= 2 {
+		return customer.GSTIN[:2]
+	}
+	return company.StateCode
+}
+
+// FinancialYear returns the Indian financial year (April-March) containing t, e.g. "2026-27"
+func FinancialYear(t time.Time) string {
+	t = timeutil.ToIST(t)
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// GetCompany reads the supplier details for tax invoices from system settings
+func (s *InvoiceService) GetCompany(ctx context.Context) (*models.InvoiceCompany, error) {
+	setting := func(key string) string {
+		st, err := s.settingsRepo.Get(ctx, key)
+		if err != nil || st == nil {
+			return ""
+		}
+		return strings.TrimSpace(st.SettingValue)
+	}
+
+	company := &models.InvoiceCompany{
+		Name:      setting("company_name"),
+		Address:   setting("company_address"),
+		GSTIN:     strings.ToUpper(setting("company_gstin")),
+		StateCode: setting("company_state_code"),
+		SACCode:   setting("invoice_sac_code"),
+	}
+	if company.StateCode == "" && len(company.GSTIN) >= 2 {
+		company.StateCode = company.GSTIN[:2]
+	}
+	if rate := setting("invoice_gst_rate"); rate != "" {
+		gstRate, err := strconv.ParseFloat(rate, 64)
+		if err != nil || gstRate < 0 || gstRate > 28 {
+			return nil, fmt.Errorf("invalid invoice_gst_rate setting: %q", rate)
+		}
+		company.GSTRate = gstRate
+	}
+	return company, nil
+}
+
 func (s *InvoiceService) GetInvoice(ctx context.Context, id int) (*models.InvoiceWithDetails, error) {
 	return s.repo.Get(ctx, id)
 }
@@ -45,3 +222,99 @@ func (s *InvoiceService) ListInvoices(ctx context.Context) ([]*models.InvoiceWit
 func (s *InvoiceService) GetCustomerInvoices(ctx context.Context, customerID int) ([]*models.Invoice, error) {
 	return s.repo.GetByCustomer(ctx, customerID)
 }
+
+// GenerateInvoicePDF renders a tax invoice or credit note
+func (s *InvoiceService) GenerateInvoicePDF(invoice *models.InvoiceWithDetails, company *models.InvoiceCompany) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.AddPage()
+
+	title := "TAX INVOICE"
+	if invoice.DocumentType == models.InvoiceTypeCreditNote {
+		title = "CREDIT NOTE"
+	}
+
+	// Supplier header
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(190, 9, company.Name, "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	if company.Address != "" {
+		pdf.CellFormat(190, 5, company.Address, "", 1, "C", false, 0, "")
+	}
+	if company.GSTIN != "" {
+		pdf.CellFormat(190, 5, fmt.Sprintf("GSTIN: %s   State Code: %s", company.GSTIN, company.StateCode), "", 1, "C", false, 0, "")
+	}
+	pdf.Ln(3)
+	pdf.SetFont("Arial", "B", 13)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.CellFormat(190, 8, title, "1", 1, "C", true, 0, "")
+
+	// Document and buyer details
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(95, 6, fmt.Sprintf("No: %s", invoice.InvoiceNumber), "LT", 0, "L", false, 0, "")
+	pdf.CellFormat(95, 6, fmt.Sprintf("Date: %s", timeutil.FormatIST(invoice.CreatedAt, "02-Jan-2006")), "RT", 1, "L", false, 0, "")
+	if invoice.OriginalNumber != "" {
+		pdf.CellFormat(190, 6, fmt.Sprintf("Against Invoice: %s", invoice.OriginalNumber), "LR", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(95, 6, fmt.Sprintf("Billed To: %s", invoice.CustomerName), "L", 0, "L", false, 0, "")
+	pdf.CellFormat(95, 6, fmt.Sprintf("Phone: %s", invoice.CustomerPhone), "R", 1, "L", false, 0, "")
+	pdf.CellFormat(95, 6, fmt.Sprintf("S/O: %s", invoice.CustomerSO), "L", 0, "L", false, 0, "")
+	pdf.CellFormat(95, 6, fmt.Sprintf("Village: %s", invoice.CustomerVillage), "R", 1, "L", false, 0, "")
+	pdf.CellFormat(95, 6, fmt.Sprintf("Customer GSTIN: %s", invoice.CustomerGSTIN), "LB", 0, "L", false, 0, "")
+	pdf.CellFormat(95, 6, fmt.Sprintf("Place of Supply: %s", invoice.PlaceOfSupply), "RB", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	// Items
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(200, 200, 200)
+	pdf.CellFormat(10, 7, "#", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(80, 7, "Description", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(20, 7, "SAC", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(20, 7, "Qty", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(25, 7, "Rate", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(35, 7, "Amount", "1", 1, "C", true, 0, "")
+
+	pdf.SetFont("Arial", "", 10)
+	for i, item := range invoice.Items {
+		pdf.CellFormat(10, 6, strconv.Itoa(i+1), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(80, 6, item.Description, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 6, item.HSNSAC, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(20, 6, strconv.Itoa(item.Quantity), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(25, 6, fmt.Sprintf("%.2f", item.Rate), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 6, fmt.Sprintf("%.2f", item.Amount), "1", 1, "R", false, 0, "")
+	}
+
+	// Tax summary
+	totalRow := func(label string, amount float64) {
+		pdf.CellFormat(155, 6, label, "1", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 6, fmt.Sprintf("%.2f", amount), "1", 1, "R", false, 0, "")
+	}
+	totalRow("Taxable Value", invoice.TaxableAmount)
+	if invoice.IGSTRate > 0 {
+		totalRow(fmt.Sprintf("IGST @ %.2f%%", invoice.IGSTRate), invoice.IGSTAmount)
+	} else {
+		totalRow(fmt.Sprintf("CGST @ %.2f%%", invoice.CGSTRate), invoice.CGSTAmount)
+		totalRow(fmt.Sprintf("SGST @ %.2f%%", invoice.SGSTRate), invoice.SGSTAmount)
+	}
+	pdf.SetFont("Arial", "B", 11)
+	totalRow("Total (Rs.)", invoice.TotalAmount)
+
+	if invoice.Status == models.InvoiceStatusCancelled {
+		pdf.Ln(4)
+		pdf.SetTextColor(200, 0, 0)
+		pdf.CellFormat(190, 8, fmt.Sprintf("CANCELLED: %s", invoice.CancelReason), "", 1, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	pdf.Ln(12)
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(190, 6, fmt.Sprintf("For %s", company.Name), "", 1, "R", false, 0, "")
+	pdf.Ln(10)
+	pdf.CellFormat(190, 6, "Authorised Signatory", "", 1, "R", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
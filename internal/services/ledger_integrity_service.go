@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// LedgerIntegrityService recomputes ledger running balances from scratch and
+// cross-checks the ledger against rent payments, online transactions and debt
+// approvals. Only running balances are repaired automatically; missing or
+// mismatched postings are reported for an accountant to correct.
+type LedgerIntegrityService struct {
+	IntegrityRepo *repositories.LedgerIntegrityRepository
+}
+
+func NewLedgerIntegrityService(integrityRepo *repositories.LedgerIntegrityRepository) *LedgerIntegrityService {
+	return &LedgerIntegrityService{IntegrityRepo: integrityRepo}
+}
+
+// Verify checks the whole ledger and returns every mismatch found
+func (s *LedgerIntegrityService) Verify(ctx context.Context) (*models.LedgerIntegrityReport, error) {
+	report := &models.LedgerIntegrityReport{
+		CheckedAt: time.Now(),
+		Issues:    []models.LedgerIntegrityIssue{},
+	}
+
+	var err error
+	report.EntriesChecked, report.CustomersChecked, err = s.IntegrityRepo.CountLedger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count ledger: %w", err)
+	}
+
+	balanceRows, err := s.IntegrityRepo.GetRunningBalanceMismatches(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recompute running balances: %w", err)
+	}
+	report.Issues = append(report.Issues, runningBalanceIssues(balanceRows)...)
+
+	checks := []struct {
+		name  string
+		check func(context.Context) ([]models.LedgerIntegrityIssue, error)
+	}{
+		{"rent payments", s.IntegrityRepo.GetPaymentMismatches},
+		{"online transactions", s.IntegrityRepo.GetOnlineTransactionMismatches},
+		{"debt approvals", s.IntegrityRepo.GetDebtApprovalMismatches},
+	}
+	for _, c := range checks {
+		issues, err := c.check(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cross-check %s: %w", c.name, err)
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+
+	return report, nil
+}
+
+// Repair fixes wrong running balances inside one transaction together with an
+// admin_action_logs record, then returns the report verified after the repair.
+// userID 0 attributes the repair to the first admin (CLI use).
+func (s *LedgerIntegrityService) Repair(ctx context.Context, userID int, ipAddress string) (*models.LedgerIntegrityReport, error) {
+	before, err := s.Verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var repairable []models.LedgerIntegrityIssue
+	for _, issue := range before.Issues {
+		if issue.Repairable {
+			repairable = append(repairable, issue)
+		}
+	}
+
+	if userID == 0 {
+		userID, err = s.IntegrityRepo.FirstAdminUserID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("no admin user to record the repair: %w", err)
+		}
+	}
+
+	oldValue, _ := json.Marshal(repairable)
+	oldValueStr := string(oldValue)
+	auditLog := &models.AdminActionLog{
+		AdminUserID: userID,
+		ActionType:  "LEDGER_REPAIR",
+		TargetType:  "ledger_entries",
+		Description: fmt.Sprintf("Repaired ledger running balances for %d customer(s)", len(repairable)),
+		OldValue:    &oldValueStr,
+	}
+	if ipAddress != "" {
+		auditLog.IPAddress = &ipAddress
+	}
+
+	rowsRepaired, err := s.IntegrityRepo.RepairRunningBalances(ctx, auditLog)
+	if err != nil {
+		return nil, err
+	}
+	if rowsRepaired > 0 {
+		cache.InvalidatePaymentCaches(ctx)
+	}
+	log.Printf("[LedgerIntegrity] Repaired %d running balance row(s) for %d customer(s)", rowsRepaired, len(repairable))
+
+	after, err := s.Verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	after.Repaired = true
+	after.RowsRepaired = rowsRepaired
+	return after, nil
+}
+
+// runningBalanceIssues groups wrong running balances into one issue per customer.
+// Expected/Actual are the customer's first wrong row; later rows usually follow from it.
+func runningBalanceIssues(rows []models.RunningBalanceRow) []models.LedgerIntegrityIssue {
+	var issues []models.LedgerIntegrityIssue
+	for _, row := range rows {
+		n := len(issues)
+		if n > 0 && issues[n-1].CustomerID == row.CustomerID &&
+			(row.CustomerID != 0 || issues[n-1].CustomerPhone == row.CustomerPhone) {
+			issues[n-1].LedgerEntryIDs = append(issues[n-1].LedgerEntryIDs, row.ID)
+			continue
+		}
+		issues = append(issues, models.LedgerIntegrityIssue{
+			Type:           models.IntegrityIssueRunningBalance,
+			CustomerID:     row.CustomerID,
+			CustomerPhone:  row.CustomerPhone,
+			CustomerName:   row.CustomerName,
+			LedgerEntryIDs: []int{row.ID},
+			Expected:       roundRupees(row.ExpectedBalance),
+			Actual:         row.RunningBalance,
+			Repairable:     true,
+		})
+	}
+	for i := range issues {
+		issues[i].Message = fmt.Sprintf("%d ledger row(s) have a wrong running balance (first: #%d stores %.2f, expected %.2f)",
+			len(issues[i].LedgerEntryIDs), issues[i].LedgerEntryIDs[0], issues[i].Actual, issues[i].Expected)
+	}
+	return issues
+}
@@ -3,13 +3,16 @@ package services
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
 )
 
 type LedgerService struct {
-	LedgerRepo *repositories.LedgerRepository
+	LedgerRepo  *repositories.LedgerRepository
+	JournalRepo *repositories.JournalRepository // Double-entry books (trial balance, day book, P&L)
 }
 
 func NewLedgerService(ledgerRepo *repositories.LedgerRepository) *LedgerService {
@@ -18,6 +21,11 @@ func NewLedgerService(ledgerRepo *repositories.LedgerRepository) *LedgerService
 	}
 }
 
+// SetJournalRepo enables the double-entry book reports
+func (s *LedgerService) SetJournalRepo(journalRepo *repositories.JournalRepository) {
+	s.JournalRepo = journalRepo
+}
+
 // CreateEntry creates a new ledger entry
 func (s *LedgerService) CreateEntry(ctx context.Context, entry *models.CreateLedgerEntryRequest) (*models.LedgerEntry, error) {
 	// Validate entry type
@@ -144,11 +152,21 @@ func (s *LedgerService) GetBalance(ctx context.Context, customerPhone string) (f
 	return s.LedgerRepo.GetBalance(ctx, customerPhone)
 }
 
+// GetBalanceByCustomerID returns the current balance for a customer by ID
+func (s *LedgerService) GetBalanceByCustomerID(ctx context.Context, customerID int) (float64, error) {
+	return s.LedgerRepo.GetBalanceByCustomerID(ctx, customerID)
+}
+
 // GetCustomerLedger returns all ledger entries for a customer
 func (s *LedgerService) GetCustomerLedger(ctx context.Context, customerPhone string, limit, offset int) ([]models.LedgerEntry, error) {
 	return s.LedgerRepo.GetByCustomer(ctx, customerPhone, limit, offset)
 }
 
+// GetCustomerLedgerByID returns all ledger entries for a customer by ID
+func (s *LedgerService) GetCustomerLedgerByID(ctx context.Context, customerID int, limit, offset int) ([]models.LedgerEntry, error) {
+	return s.LedgerRepo.GetByCustomerID(ctx, customerID, limit, offset)
+}
+
 // GetAllEntries returns all ledger entries with optional filters (for audit)
 func (s *LedgerService) GetAllEntries(ctx context.Context, filter *models.LedgerFilter) ([]models.LedgerEntry, error) {
 	return s.LedgerRepo.GetAll(ctx, filter)
@@ -159,6 +177,11 @@ func (s *LedgerService) GetCustomerSummary(ctx context.Context, customerPhone st
 	return s.LedgerRepo.GetSummaryByCustomer(ctx, customerPhone)
 }
 
+// GetCustomerSummaryByID returns balance summary for a customer by ID
+func (s *LedgerService) GetCustomerSummaryByID(ctx context.Context, customerID int) (*models.LedgerSummary, error) {
+	return s.LedgerRepo.GetSummaryByCustomerID(ctx, customerID)
+}
+
 // GetAllCustomerBalances returns balance summaries for all customers
 func (s *LedgerService) GetAllCustomerBalances(ctx context.Context) ([]models.LedgerSummary, error) {
 	return s.LedgerRepo.GetAllCustomerBalances(ctx)
@@ -182,3 +205,133 @@ func (s *LedgerService) HasOutstandingBalance(ctx context.Context, customerPhone
 	}
 	return balance > 0, balance, nil
 }
+
+// ListAccounts returns the chart of accounts
+func (s *LedgerService) ListAccounts(ctx context.Context) ([]models.LedgerAccount, error) {
+	if s.JournalRepo == nil {
+		return nil, fmt.Errorf("journal not configured")
+	}
+	return s.JournalRepo.ListAccounts(ctx)
+}
+
+// GetTrialBalance returns closing balances of all accounts at the end of asOf (IST date)
+func (s *LedgerService) GetTrialBalance(ctx context.Context, asOf time.Time) (*models.TrialBalance, error) {
+	if s.JournalRepo == nil {
+		return nil, fmt.Errorf("journal not configured")
+	}
+
+	rows, err := s.JournalRepo.GetAccountTotals(ctx, time.Time{}, nextDay(asOf))
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &models.TrialBalance{
+		AsOf: timeutil.FormatIST(asOf, "2006-01-02"),
+		Rows: make([]models.TrialBalanceRow, 0, len(rows)),
+	}
+	for _, row := range rows {
+		net := roundRupees(row.TotalDebit - row.TotalCredit)
+		if net >= 0 {
+			row.ClosingDebit = net
+		} else {
+			row.ClosingCredit = -net
+		}
+		tb.TotalDebit += row.ClosingDebit
+		tb.TotalCredit += row.ClosingCredit
+		tb.Rows = append(tb.Rows, row)
+	}
+	tb.TotalDebit = roundRupees(tb.TotalDebit)
+	tb.TotalCredit = roundRupees(tb.TotalCredit)
+	tb.Balanced = tb.TotalDebit == tb.TotalCredit
+	return tb, nil
+}
+
+// GetDayBook returns journals posted between two IST dates (inclusive).
+// With an account code it becomes that account's book (1000 = cash book, 1010 = Razorpay book, 1020 = bank book).
+func (s *LedgerService) GetDayBook(ctx context.Context, from, to time.Time, accountCode string) (*models.DayBook, error) {
+	if s.JournalRepo == nil {
+		return nil, fmt.Errorf("journal not configured")
+	}
+
+	start := timeutil.StartOfDay(from)
+	end := nextDay(to)
+	journals, err := s.JournalRepo.GetJournals(ctx, start, end, accountCode)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &models.DayBook{
+		From:        timeutil.FormatIST(from, "2006-01-02"),
+		To:          timeutil.FormatIST(to, "2006-01-02"),
+		AccountCode: accountCode,
+		Entries:     journals,
+	}
+	if book.Entries == nil {
+		book.Entries = []models.JournalEntry{}
+	}
+
+	// Totals are for the selected account only when filtering, otherwise for all lines
+	for _, j := range journals {
+		for _, line := range j.Lines {
+			if accountCode != "" && line.AccountCode != accountCode {
+				continue
+			}
+			book.TotalDebit += line.Debit
+			book.TotalCredit += line.Credit
+		}
+	}
+	book.TotalDebit = roundRupees(book.TotalDebit)
+	book.TotalCredit = roundRupees(book.TotalCredit)
+
+	if accountCode != "" {
+		opening, err := s.JournalRepo.GetAccountBalance(ctx, accountCode, start)
+		if err != nil {
+			return nil, err
+		}
+		opening = roundRupees(opening)
+		closing := roundRupees(opening + book.TotalDebit - book.TotalCredit)
+		book.OpeningBalance = &opening
+		book.ClosingBalance = &closing
+	}
+	return book, nil
+}
+
+// GetProfitAndLoss returns income, expenses and net profit between two IST dates (inclusive)
+func (s *LedgerService) GetProfitAndLoss(ctx context.Context, from, to time.Time) (*models.ProfitAndLoss, error) {
+	if s.JournalRepo == nil {
+		return nil, fmt.Errorf("journal not configured")
+	}
+
+	rows, err := s.JournalRepo.GetAccountTotals(ctx, timeutil.StartOfDay(from), nextDay(to))
+	if err != nil {
+		return nil, err
+	}
+
+	pl := &models.ProfitAndLoss{
+		From:     timeutil.FormatIST(from, "2006-01-02"),
+		To:       timeutil.FormatIST(to, "2006-01-02"),
+		Income:   []models.AccountAmount{},
+		Expenses: []models.AccountAmount{},
+	}
+	for _, row := range rows {
+		switch row.AccountType {
+		case "income":
+			amount := roundRupees(row.TotalCredit - row.TotalDebit)
+			pl.Income = append(pl.Income, models.AccountAmount{AccountCode: row.AccountCode, AccountName: row.AccountName, Amount: amount})
+			pl.TotalIncome += amount
+		case "expense":
+			amount := roundRupees(row.TotalDebit - row.TotalCredit)
+			pl.Expenses = append(pl.Expenses, models.AccountAmount{AccountCode: row.AccountCode, AccountName: row.AccountName, Amount: amount})
+			pl.TotalExpenses += amount
+		}
+	}
+	pl.TotalIncome = roundRupees(pl.TotalIncome)
+	pl.TotalExpenses = roundRupees(pl.TotalExpenses)
+	pl.NetProfit = roundRupees(pl.TotalIncome - pl.TotalExpenses)
+	return pl, nil
+}
+
+// nextDay returns 00:00 IST on the day after t (exclusive end of an inclusive date range)
+func nextDay(t time.Time) time.Time {
+	return timeutil.StartOfDay(t).AddDate(0, 0, 1)
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"cold-backend/internal/models"
 	"cold-backend/internal/repositories"
@@ -13,6 +14,7 @@ import (
 type NotificationService struct {
 	SMSService  sms.SMSProvider
 	SettingRepo *repositories.SystemSettingRepository
+	UserRepo    *repositories.UserRepository
 }
 
 // NewNotificationService creates a new notification service
@@ -26,6 +28,11 @@ func NewNotificationService(
 	}
 }
 
+// SetUserRepo enables paging active admins when sensor_alert_phones is empty
+func (s *NotificationService) SetUserRepo(repo *repositories.UserRepository) {
+	s.UserRepo = repo
+}
+
 // isEnabled checks if a notification type is enabled
 func (s *NotificationService) isEnabled(ctx context.Context, settingKey string) bool {
 	if s.SettingRepo == nil {
@@ -101,3 +108,94 @@ func (s *NotificationService) NotifyPaymentReceived(ctx context.Context, custome
 
 	return s.SMSService.SendSMS(customer.Phone, message, models.SMSTypePaymentReceived, customer.ID)
 }
+
+// NotifySensorAlert pages the numbers in sensor_alert_phones about a cold-room sensor alert,
+// or the active admins when no numbers are set
+func (s *NotificationService) NotifySensorAlert(ctx context.Context, message string) error {
+	if s.SettingRepo == nil {
+		return nil
+	}
+	var phones []string
+	if setting, err := s.SettingRepo.Get(ctx, "sensor_alert_phones"); err == nil && setting != nil {
+		for _, phone := range strings.Split(setting.SettingValue, ",") {
+			if phone = strings.TrimSpace(phone); phone != "" {
+				phones = append(phones, phone)
+			}
+		}
+	}
+	if len(phones) == 0 && s.UserRepo != nil {
+		// Nobody configured: page the admins
+		admins, err := s.UserRepo.ListAdminPhones(ctx)
+		if err != nil {
+			return err
+		}
+		phones = admins
+	}
+
+	var firstErr error
+	for _, phone := range phones {
+		if err := s.SMSService.SendSMS(phone, message, models.SMSTypeSensorAlert, 0); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NotifyQualityAlert advises the owner of a deteriorating thock to withdraw it early
+func (s *NotificationService) NotifyQualityAlert(ctx context.Context, entry *models.Entry, bagsInStock int) error {
+	if !s.isEnabled(ctx, models.SettingSMSQualityAlert) {
+		return nil
+	}
+
+	if entry == nil || entry.Phone == "" {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"Dear %s, inspection of your %d bags (Thock: %s) at Cold Storage shows the stock is deteriorating. Please plan to withdraw early.",
+		entry.Name, bagsInStock, entry.ThockNumber,
+	)
+
+	return s.SMSService.SendSMS(entry.Phone, message, models.SMSTypeQualityAlert, entry.CustomerID)
+}
+
+// notifyGatePass sends a gate pass update to the owner of the gate pass's thock
+func (s *NotificationService) notifyGatePass(ctx context.Context, entry *models.Entry, message string) error {
+	if !s.isEnabled(ctx, models.SettingSMSGatePass) {
+		return nil
+	}
+
+	if entry == nil || entry.Phone == "" {
+		return nil
+	}
+
+	return s.SMSService.SendSMS(entry.Phone, message, models.SMSTypeGatePass, entry.CustomerID)
+}
+
+// NotifyGatePassCancelled tells the customer a gate pass was cancelled
+func (s *NotificationService) NotifyGatePassCancelled(ctx context.Context, entry *models.Entry, gatePass *models.GatePass, reason string) error {
+	message := fmt.Sprintf(
+		"Dear %s, Gate Pass #%d (Thock: %s) has been cancelled. Reason: %s. Items picked up: %d.",
+		entry.Name, gatePass.ID, gatePass.ThockNumber, reason, gatePass.TotalPickedUp,
+	)
+	return s.notifyGatePass(ctx, entry, message)
+}
+
+// NotifyGatePassAmended tells the customer the quantity or gate of a gate pass changed
+func (s *NotificationService) NotifyGatePassAmended(ctx context.Context, entry *models.Entry, gatePass *models.GatePass, amendment *models.GatePassAmendment) error {
+	message := fmt.Sprintf(
+		"Dear %s, Gate Pass #%d (Thock: %s) is now for %d items at gate %s. Remaining: %d items. Thank you!",
+		entry.Name, gatePass.ID, gatePass.ThockNumber, amendment.NewQuantity, amendment.NewGateNo,
+		amendment.NewQuantity-gatePass.TotalPickedUp,
+	)
+	return s.notifyGatePass(ctx, entry, message)
+}
+
+// NotifyGatePassReissued tells the customer an expired gate pass was replaced by a new one
+func (s *NotificationService) NotifyGatePassReissued(ctx context.Context, entry *models.Entry, expired, reissued *models.GatePass) error {
+	message := fmt.Sprintf(
+		"Dear %s, expired Gate Pass #%d (Thock: %s) has been re-issued as Gate Pass #%d for %d items. Thank you!",
+		entry.Name, expired.ID, expired.ThockNumber, reissued.ID, reissued.RequestedQuantity,
+	)
+	return s.notifyGatePass(ctx, entry, message)
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// ErrInvalidAllocation is returned when a manual split does not fit the payment or the thocks' outstanding
+var ErrInvalidAllocation = errors.New("invalid payment allocation")
+
+// PaymentAllocationService splits rent payments across thocks and works out
+// what is still due on each thock.
+//
+// A thock's charge is the rent the accrual job posted for its scope. Explicit
+// allocations pay their thock directly. All other credit (unallocated payments,
+// online payments, discounts) settles charges not tied to a current thock
+// first and then the thocks oldest-first. Rent the rate card puts on a thock
+// beyond what accrual has posted is reported separately as unaccrued.
+type PaymentAllocationService struct {
+	AllocationRepo *repositories.PaymentAllocationRepository
+	EntryRepo      *repositories.EntryRepository
+	RoomEntryRepo  *repositories.RoomEntryRepository
+	LedgerRepo     *repositories.LedgerRepository
+	TariffService  *TariffService
+}
+
+func NewPaymentAllocationService(
+	allocationRepo *repositories.PaymentAllocationRepository,
+	entryRepo *repositories.EntryRepository,
+	roomEntryRepo *repositories.RoomEntryRepository,
+	ledgerRepo *repositories.LedgerRepository,
+	tariffService *TariffService,
+) *PaymentAllocationService {
+	return &PaymentAllocationService{
+		AllocationRepo: allocationRepo,
+		EntryRepo:      entryRepo,
+		RoomEntryRepo:  roomEntryRepo,
+		LedgerRepo:     ledgerRepo,
+		TariffService:  tariffService,
+	}
+}
+
+// GetOutstanding returns the per-thock outstanding of the customer with this phone
+func (s *PaymentAllocationService) GetOutstanding(ctx context.Context, customerPhone string) (*models.CustomerOutstanding, error) {
+	customerID, err := s.LedgerRepo.CustomerIDForPhone(ctx, customerPhone)
+	if err != nil {
+		return nil, err
+	}
+	if customerID == 0 {
+		return &models.CustomerOutstanding{CustomerPhone: customerPhone, Thocks: []models.ThockOutstanding{}}, nil
+	}
+	return s.outstanding(ctx, customerID, nil, nil)
+}
+
+// GetOutstandingByCustomerID returns the per-thock outstanding of a customer
+func (s *PaymentAllocationService) GetOutstandingByCustomerID(ctx context.Context, customerID int) (*models.CustomerOutstanding, error) {
+	return s.outstanding(ctx, customerID, nil, nil)
+}
+
+// GetOutstandingAt returns the per-thock outstanding of a customer as it stood at the
+// given time: only thocks, ledger entries and payments from before it count
+func (s *PaymentAllocationService) GetOutstandingAt(ctx context.Context, customerID int, at time.Time) (*models.CustomerOutstanding, error) {
+	return s.outstanding(ctx, customerID, nil, &at)
+}
+
+// GetAllocations returns how a payment was split across thocks
+func (s *PaymentAllocationService) GetAllocations(ctx context.Context, paymentID int) ([]models.PaymentAllocation, error) {
+	return s.AllocationRepo.GetByPayment(ctx, paymentID)
+}
+
+// Allocate splits amount across the customer's thocks before the payment is recorded.
+// Manual inputs are validated as given; without inputs the amount goes to
+// preferredEntryID first and then oldest-first, limited to familyMemberID when set.
+func (s *PaymentAllocationService) Allocate(ctx context.Context, customerPhone string, amount float64, familyMemberID *int, preferredEntryID int, inputs []models.PaymentAllocationInput) ([]models.PaymentAllocation, error) {
+	customerID, err := s.LedgerRepo.CustomerIDForPhone(ctx, customerPhone)
+	if err != nil {
+		return nil, err
+	}
+	if customerID == 0 {
+		if len(inputs) > 0 {
+			return nil, fmt.Errorf("%w: customer %s not found", ErrInvalidAllocation, customerPhone)
+		}
+		return nil, nil
+	}
+
+	outstanding, err := s.outstanding(ctx, customerID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) > 0 {
+		return manualAllocations(outstanding, amount, inputs)
+	}
+	return autoAllocations(outstanding, amount, familyMemberID, preferredEntryID), nil
+}
+
+// Reallocate replaces the split of an existing payment with a manual one.
+// The payment is validated as if it had not been received yet.
+func (s *PaymentAllocationService) Reallocate(ctx context.Context, payment *models.RentPayment, inputs []models.PaymentAllocationInput, userID int) ([]models.PaymentAllocation, error) {
+	if payment.CustomerID == 0 {
+		return nil, fmt.Errorf("%w: payment %s has no customer", ErrInvalidAllocation, payment.ReceiptNumber)
+	}
+
+	outstanding, err := s.outstanding(ctx, payment.CustomerID, payment, nil)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := manualAllocations(outstanding, payment.AmountPaid, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range allocations {
+		allocations[i].RentPaymentID = payment.ID
+		allocations[i].AllocatedByUserID = userID
+	}
+	if err := s.AllocationRepo.Replace(ctx, payment.ID, allocations); err != nil {
+		return nil, err
+	}
+	return s.AllocationRepo.GetByPayment(ctx, payment.ID)
+}
+
+// outstanding computes the customer's per-thock balances. exclude (optional) is
+// left out entirely, both its ledger credit and its allocations. before (optional)
+// computes them as they stood at that time; unaccrued rent is then not worked out.
+func (s *PaymentAllocationService) outstanding(ctx context.Context, customerID int, exclude *models.RentPayment, before *time.Time) (*models.CustomerOutstanding, error) {
+	entries, err := s.EntryRepo.ListByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entries: %w", err)
+	}
+	accrued, err := s.LedgerRepo.GetAccruedRentByScopeForCustomer(ctx, customerID, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load accrued rent: %w", err)
+	}
+	excludeID := 0
+	if exclude != nil {
+		excludeID = exclude.ID
+	}
+	allocated, err := s.AllocationRepo.GetTotalsByEntry(ctx, customerID, excludeID, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocations: %w", err)
+	}
+	var summary *models.LedgerSummary
+	if before != nil {
+		summary, err = s.LedgerRepo.GetSummaryByCustomerIDBefore(ctx, customerID, *before)
+	} else {
+		summary, err = s.LedgerRepo.GetSummaryByCustomerID(ctx, customerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger summary: %w", err)
+	}
+	var rateCard *RateCard
+	if before == nil {
+		if rateCard, err = s.TariffService.LoadRateCard(ctx); err != nil {
+			return nil, err
+		}
+	}
+	now := timeutil.Now()
+
+	result := &models.CustomerOutstanding{CustomerID: customerID, Thocks: []models.ThockOutstanding{}}
+	var totalDebit, totalCredit float64
+	if summary != nil {
+		result.CustomerPhone = summary.CustomerPhone
+		totalDebit, totalCredit = summary.TotalDebit, summary.TotalCredit
+	}
+	if exclude != nil {
+		totalCredit -= exclude.AmountPaid
+	}
+
+	// Entries come newest-first
+	var thockCharged, thockAllocated float64
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if before != nil && !entry.CreatedAt.Before(*before) {
+			continue // Stored later
+		}
+		scope := fmt.Sprintf("%d-%d", entry.ID, entry.CreatedAt.Unix())
+		thock := models.ThockOutstanding{
+			EntryID:          entry.ID,
+			ThockNumber:      entry.ThockNumber,
+			ThockCategory:    entry.ThockCategory,
+			FamilyMemberID:   entry.FamilyMemberID,
+			FamilyMemberName: entry.FamilyMemberName,
+			CreatedAt:        entry.CreatedAt,
+			Charged:          roundRupees(accrued[scope]),
+			Allocated:        roundRupees(allocated[entry.ID]),
+		}
+		// Rent accrual bills on the bags put into rooms; what it has not posted yet still counts
+		if rateCard != nil {
+			stored, err := s.RoomEntryRepo.GetTotalQuantityByThockNumber(ctx, entry.ThockNumber)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load stored quantity: %w", err)
+			}
+			thock.Unaccrued = roundRupees(max(rateCard.EntryRent(entry, stored, now)-thock.Charged, 0))
+		}
+		thockCharged += thock.Charged
+		thockAllocated += thock.Allocated
+		result.Thocks = append(result.Thocks, thock)
+	}
+
+	// Credit that is not allocated to a thock, plus anything allocated beyond a thock's charge
+	pool := totalCredit - thockAllocated
+	for i := range result.Thocks {
+		t := &result.Thocks[i]
+		t.Outstanding = t.Charged - t.Allocated
+		if t.Outstanding < 0 {
+			pool -= t.Outstanding
+			t.Outstanding = 0
+		}
+	}
+	pool = max(pool, 0)
+
+	// Older charges (earlier seasons, manual debits) are settled first
+	other := max(totalDebit-thockCharged, 0)
+	paid := min(pool, other)
+	other -= paid
+	pool -= paid
+
+	for i := range result.Thocks {
+		t := &result.Thocks[i]
+		applied := min(pool, t.Outstanding)
+		t.UnallocatedPaid = roundRupees(applied)
+		t.Outstanding = roundRupees(t.Outstanding - applied)
+		pool -= applied
+		result.TotalOutstanding += t.Outstanding
+	}
+
+	result.OtherOutstanding = roundRupees(other)
+	result.UnallocatedCredit = roundRupees(pool)
+	result.TotalOutstanding = roundRupees(result.TotalOutstanding + other)
+	return result, nil
+}
+
+// manualAllocations validates a split entered by the accountant
+func manualAllocations(outstanding *models.CustomerOutstanding, amount float64, inputs []models.PaymentAllocationInput) ([]models.PaymentAllocation, error) {
+	thocks := make(map[int]*models.ThockOutstanding, len(outstanding.Thocks))
+	for i := range outstanding.Thocks {
+		thocks[outstanding.Thocks[i].EntryID] = &outstanding.Thocks[i]
+	}
+
+	var allocations []models.PaymentAllocation
+	index := make(map[int]int) // entry ID -> position in allocations
+	var total float64
+	for _, input := range inputs {
+		thock, ok := thocks[input.EntryID]
+		if !ok {
+			return nil, fmt.Errorf("%w: entry %d is not a current thock of this customer", ErrInvalidAllocation, input.EntryID)
+		}
+		if input.Amount <= 0 {
+			return nil, fmt.Errorf("%w: amount for thock %s must be positive", ErrInvalidAllocation, thock.ThockNumber)
+		}
+
+		total += input.Amount
+		if i, ok := index[input.EntryID]; ok {
+			allocations[i].Amount = roundRupees(allocations[i].Amount + input.Amount)
+			continue
+		}
+		index[input.EntryID] = len(allocations)
+		allocations = append(allocations, newAllocation(outstanding.CustomerID, thock, roundRupees(input.Amount)))
+	}
+
+	for _, a := range allocations {
+		if due := thocks[a.EntryID].Outstanding; a.Amount > due+0.005 {
+			return nil, fmt.Errorf("%w: %.2f allocated to thock %s exceeds its outstanding %.2f",
+				ErrInvalidAllocation, a.Amount, a.ThockNumber, due)
+		}
+	}
+	if total > amount+0.005 {
+		return nil, fmt.Errorf("%w: allocations total %.2f exceeds payment %.2f", ErrInvalidAllocation, total, amount)
+	}
+	return allocations, nil
+}
+
+// autoAllocations spreads amount over the outstanding thocks, preferred entry first
+// and then oldest-first. Any amount left over stays unallocated credit.
+func autoAllocations(outstanding *models.CustomerOutstanding, amount float64, familyMemberID *int, preferredEntryID int) []models.PaymentAllocation {
+	order := make([]*models.ThockOutstanding, 0, len(outstanding.Thocks))
+	for i := range outstanding.Thocks {
+		if outstanding.Thocks[i].EntryID == preferredEntryID {
+			order = append([]*models.ThockOutstanding{&outstanding.Thocks[i]}, order...)
+			continue
+		}
+		if familyMemberID != nil && (outstanding.Thocks[i].FamilyMemberID == nil || *outstanding.Thocks[i].FamilyMemberID != *familyMemberID) {
+			continue
+		}
+		order = append(order, &outstanding.Thocks[i])
+	}
+
+	var allocations []models.PaymentAllocation
+	remaining := roundRupees(amount)
+	for _, thock := range order {
+		if remaining < 0.01 {
+			break
+		}
+		share := roundRupees(min(remaining, thock.Outstanding))
+		if share < 0.01 {
+			continue
+		}
+		allocations = append(allocations, newAllocation(outstanding.CustomerID, thock, share))
+		remaining = roundRupees(remaining - share)
+	}
+	return allocations
+}
+
+func newAllocation(customerID int, thock *models.ThockOutstanding, amount float64) models.PaymentAllocation {
+	return models.PaymentAllocation{
+		EntryID:        thock.EntryID,
+		CustomerID:     customerID,
+		ThockNumber:    thock.ThockNumber,
+		FamilyMemberID: thock.FamilyMemberID,
+		Amount:         amount,
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// ErrInvalidPickupSlot is returned for slots that cannot be opened, changed or booked
+var ErrInvalidPickupSlot = errors.New("invalid pickup slot")
+
+const (
+	// Generating more slots than this at once is taken as a mistake in the request
+	maxGeneratedPickupSlots = 2000
+	// How many days ahead customers see slots on the portal
+	customerPickupSlotDays = 7
+)
+
+// PickupSlotService manages pickup slots at the loading gates, books gate passes into them
+// and builds each gate's queue
+type PickupSlotService struct {
+	Repo         *repositories.PickupSlotRepository
+	GatePassRepo *repositories.GatePassRepository
+}
+
+func NewPickupSlotService(repo *repositories.PickupSlotRepository, gatePassRepo *repositories.GatePassRepository) *PickupSlotService {
+	return &PickupSlotService{Repo: repo, GatePassRepo: gatePassRepo}
+}
+
+func parseSlotDate(field, value string) (time.Time, error) {
+	t, err := timeutil.ParseInIST(timeutil.DateLayout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be YYYY-MM-DD", ErrInvalidPickupSlot, field)
+	}
+	return t, nil
+}
+
+// parseSlotClock parses HH:MM into minutes after midnight
+func parseSlotClock(field, value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be HH:MM", ErrInvalidPickupSlot, field)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatSlotClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func checkSlotCapacity(bags, trucks int) error {
+	if bags < 0 || trucks < 0 {
+		return fmt.Errorf("%w: capacity cannot be negative", ErrInvalidPickupSlot)
+	}
+	return nil
+}
+
+// Create opens one slot
+func (s *PickupSlotService) Create(ctx context.Context, req *models.CreatePickupSlotRequest, userID int) (*models.PickupSlot, error) {
+	date, err := parseSlotDate("slot_date", req.SlotDate)
+	if err != nil {
+		return nil, err
+	}
+	start, err := parseSlotClock("start_time", req.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseSlotClock("end_time", req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, fmt.Errorf("%w: end_time must be after start_time", ErrInvalidPickupSlot)
+	}
+	gate := strings.TrimSpace(req.Gate)
+	if gate == "" {
+		return nil, fmt.Errorf("%w: gate is required", ErrInvalidPickupSlot)
+	}
+	if err := checkSlotCapacity(req.CapacityBags, req.CapacityTrucks); err != nil {
+		return nil, err
+	}
+
+	slot := &models.PickupSlot{
+		SlotDate:        date.Format(timeutil.DateLayout),
+		Gate:            gate,
+		StartTime:       formatSlotClock(start),
+		EndTime:         formatSlotClock(end),
+		CapacityBags:    req.CapacityBags,
+		CapacityTrucks:  req.CapacityTrucks,
+		CreatedByUserID: &userID,
+	}
+	if err := s.Repo.Create(ctx, slot); err != nil {
+		return nil, err
+	}
+	return s.Repo.Get(ctx, slot.ID)
+}
+
+// Generate opens back-to-back slots for each gate on each day of a range and returns how
+// many were opened; slots that already exist are skipped
+func (s *PickupSlotService) Generate(ctx context.Context, req *models.GeneratePickupSlotsRequest, userID int) (int, error) {
+	from, err := parseSlotDate("from_date", req.FromDate)
+	if err != nil {
+		return 0, err
+	}
+	to, err := parseSlotDate("to_date", req.ToDate)
+	if err != nil {
+		return 0, err
+	}
+	if to.Before(from) {
+		return 0, fmt.Errorf("%w: to_date is before from_date", ErrInvalidPickupSlot)
+	}
+	dayStart, err := parseSlotClock("day_start", req.DayStart)
+	if err != nil {
+		return 0, err
+	}
+	dayEnd, err := parseSlotClock("day_end", req.DayEnd)
+	if err != nil {
+		return 0, err
+	}
+	if req.SlotMinutes < 15 || req.SlotMinutes > dayEnd-dayStart {
+		return 0, fmt.Errorf("%w: slot_minutes must be at least 15 and fit between day_start and day_end", ErrInvalidPickupSlot)
+	}
+	if err := checkSlotCapacity(req.CapacityBags, req.CapacityTrucks); err != nil {
+		return 0, err
+	}
+
+	var gates []string
+	for _, g := range req.Gates {
+		if g = strings.TrimSpace(g); g != "" {
+			gates = append(gates, g)
+		}
+	}
+	if len(gates) == 0 {
+		return 0, fmt.Errorf("%w: at least one gate is required", ErrInvalidPickupSlot)
+	}
+
+	var slots []*models.PickupSlot
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, gate := range gates {
+			for start := dayStart; start+req.SlotMinutes <= dayEnd; start += req.SlotMinutes {
+				slots = append(slots, &models.PickupSlot{
+					SlotDate:        day.Format(timeutil.DateLayout),
+					Gate:            gate,
+					StartTime:       formatSlotClock(start),
+					EndTime:         formatSlotClock(start + req.SlotMinutes),
+					CapacityBags:    req.CapacityBags,
+					CapacityTrucks:  req.CapacityTrucks,
+					CreatedByUserID: &userID,
+				})
+			}
+		}
+		if len(slots) > maxGeneratedPickupSlots {
+			return 0, fmt.Errorf("%w: more than %d slots requested at once", ErrInvalidPickupSlot, maxGeneratedPickupSlots)
+		}
+	}
+	return s.Repo.CreateMany(ctx, slots)
+}
+
+// Update changes a slot's capacity or closes it for new bookings
+func (s *PickupSlotService) Update(ctx context.Context, id int, req *models.UpdatePickupSlotRequest) (*models.PickupSlot, error) {
+	bags, trucks := 0, 0
+	if req.CapacityBags != nil {
+		bags = *req.CapacityBags
+	}
+	if req.CapacityTrucks != nil {
+		trucks = *req.CapacityTrucks
+	}
+	if err := checkSlotCapacity(bags, trucks); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.Update(ctx, id, req); err != nil {
+		return nil, err
+	}
+	return s.Repo.Get(ctx, id)
+}
+
+// List returns the slots of the days from..to (YYYY-MM-DD), optionally for one gate
+func (s *PickupSlotService) List(ctx context.Context, from, to, gate string, availableOnly bool) ([]*models.PickupSlot, error) {
+	fromDate, err := parseSlotDate("from", from)
+	if err != nil {
+		return nil, err
+	}
+	toDate, err := parseSlotDate("to", to)
+	if err != nil {
+		return nil, err
+	}
+	if toDate.Before(fromDate) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidPickupSlot)
+	}
+	return s.Repo.List(ctx, fromDate.Format(timeutil.DateLayout), toDate.Format(timeutil.DateLayout), strings.TrimSpace(gate), availableOnly)
+}
+
+// ListForCustomers returns the slots customers can book on the portal over the coming days
+func (s *PickupSlotService) ListForCustomers(ctx context.Context) ([]*models.PickupSlot, error) {
+	today := timeutil.Now()
+	return s.Repo.List(ctx, today.Format(timeutil.DateLayout), today.AddDate(0, 0, customerPickupSlotDays).Format(timeutil.DateLayout), "", true)
+}
+
+// Queue returns each loading gate's queue for a day
+func (s *PickupSlotService) Queue(ctx context.Context, date, gate string) ([]*models.PickupQueue, error) {
+	day, err := parseSlotDate("date", date)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.Queue(ctx, day.Format(timeutil.DateLayout), strings.TrimSpace(gate))
+}
+
+// slotBags is the number of bags a gate pass takes up in its slot
+func slotBags(gatePass *models.GatePass) int {
+	if gatePass.ApprovedQuantity != nil {
+		return *gatePass.ApprovedQuantity
+	}
+	return gatePass.RequestedQuantity
+}
+
+// Book puts a gate pass into a slot for the given number of bags; slotID nil clears its slot
+func (s *PickupSlotService) Book(ctx context.Context, gatePass *models.GatePass, slotID *int, bags int) error {
+	if slotID == nil {
+		return s.Repo.Clear(ctx, gatePass.ID)
+	}
+	return pickupSlotError(s.Repo.Book(ctx, gatePass.ID, *slotID, bags))
+}
+
+// pickupSlotError reports a slot that cannot take a booking as ErrInvalidPickupSlot
+func pickupSlotError(err error) error {
+	if errors.Is(err, repositories.ErrPickupSlotNotFound) || errors.Is(err, repositories.ErrPickupSlotClosed) || errors.Is(err, repositories.ErrPickupSlotFull) {
+		return fmt.Errorf("%w: %v", ErrInvalidPickupSlot, err)
+	}
+	return err
+}
+
+// SetGatePassSlot books an open gate pass into a slot, or clears its slot, for rescheduling
+func (s *PickupSlotService) SetGatePassSlot(ctx context.Context, gatePassID int, slotID *int) (*models.GatePass, error) {
+	gatePass, err := s.GatePassRepo.GetGatePass(ctx, gatePassID)
+	if err != nil {
+		return nil, err
+	}
+	switch gatePass.Status {
+	case models.GatePassStatusRequested, models.GatePassStatusApproved, models.GatePassStatusPartiallyPicked:
+	default:
+		return nil, fmt.Errorf("%w: gate pass #%d is %s", ErrInvalidPickupSlot, gatePass.ID, gatePass.Status)
+	}
+
+	if err := s.Book(ctx, gatePass, slotID, slotBags(gatePass)); err != nil {
+		return nil, gatePassTransitionError(gatePass, err)
+	}
+	return s.GatePassRepo.GetGatePass(ctx, gatePassID)
+}
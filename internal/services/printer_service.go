@@ -1,142 +1,405 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"log"
+	"strings"
 	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/printing"
+	"cold-backend/internal/repositories"
 )
 
+// ErrInvalidPrintRequest is returned for printer settings or print requests that make no sense
+var ErrInvalidPrintRequest = errors.New("invalid print request")
+
 const (
-	PrinterURL = "http://192.168.15.101:5000"
+	printQueueInterval = 5 * time.Second
+	printQueueBatch    = 10
+	printRetryBase     = 15 * time.Second
+	printRetryMax      = 10 * time.Minute
+	printStaleAfter    = 2 * time.Minute // A job printing this long was cut off by a restart
+	maxLabelCopies     = 500
 )
 
+// PrinterService renders labels and receipts for the printer at each counter and sends
+// them through the print queue. A print is tried at once; if the printer cannot be
+// reached the job stays queued and the background worker retries it with backoff.
 type PrinterService struct {
-	client  *http.Client
-	baseURL string
+	Repo *repositories.PrinterRepository
+	Fake *printing.Fake // Target of printers with the fake protocol
+	Scan *ScanService   // Optional: signs the QR code on thock labels
+
+	wake   chan struct{}
+	stopCh chan struct{}
 }
 
-type PrintFullRequest struct {
-	Line1  string `json:"line1"`
-	Line2  string `json:"line2"`
-	Font1  string `json:"font1"`
-	Font2  string `json:"font2"`
-	Copies int    `json:"copies"`
+func NewPrinterService(repo *repositories.PrinterRepository) *PrinterService {
+	return &PrinterService{
+		Repo:   repo,
+		Fake:   printing.NewFake(),
+		wake:   make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+	}
 }
 
-type PrintResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+func printerMedia(p *models.Printer) printing.Media {
+	return printing.Media{
+		WidthMM:  p.LabelWidthMM,
+		HeightMM: p.LabelHeightMM,
+		Columns:  p.LabelColumns,
+		DPI:      p.DPI,
+		CodeType: p.CodeType,
+	}
 }
 
-func NewPrinterService() *PrinterService {
-	return &PrinterService{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		baseURL: PrinterURL,
+// SetScanService makes thock labels carry a signed QR payload instead of the bare thock number
+func (s *PrinterService) SetScanService(scan *ScanService) {
+	s.Scan = scan
+}
+
+// choosePrinter returns the printer asked for, or the counter's printer of the kind
+func (s *PrinterService) choosePrinter(ctx context.Context, kind, counter string, printerID int) (*models.Printer, error) {
+	if printerID > 0 {
+		p, err := s.Repo.GetPrinter(ctx, printerID)
+		if err != nil {
+			return nil, err
+		}
+		if p.Kind != kind || !p.IsActive {
+			return nil, fmt.Errorf("%w: printer %s is not an active %s printer", ErrInvalidPrintRequest, p.Name, kind)
+		}
+		return p, nil
+	}
+	p, err := s.Repo.FindPrinter(ctx, kind, strings.TrimSpace(counter))
+	if errors.Is(err, repositories.ErrPrinterNotFound) {
+		return nil, fmt.Errorf("no %s printer is set up for counter %q: %w", kind, counter, err)
 	}
+	return p, err
 }
 
-// Print2Up prints 2-up labels (side by side) with thock number and customer name
-// copies is the exact number of labels wanted
-func (s *PrinterService) Print2Up(thockNumber, customerName string, copies int) error {
+// PrintThockLabels prints copies of a thock's label at a counter
+func (s *PrinterService) PrintThockLabels(ctx context.Context, counter string, printerID int, thockNumber, customerName string, copies, userID int) (*models.PrintJob, error) {
+	if strings.TrimSpace(thockNumber) == "" {
+		return nil, fmt.Errorf("%w: thock number is required", ErrInvalidPrintRequest)
+	}
 	if copies < 1 {
 		copies = 1
 	}
+	if copies > maxLabelCopies {
+		return nil, fmt.Errorf("%w: at most %d labels at once", ErrInvalidPrintRequest, maxLabelCopies)
+	}
 
-	// 2-up prints 2 labels per sheet
-	// For odd numbers, print (n-1)/2 sheets of 2-up, then 1 single label
-	twoUpCopies := copies / 2
-	singleLabel := copies % 2
-
-	// Print 2-up labels if needed
-	if twoUpCopies > 0 {
-		req := PrintFullRequest{
-			Line1:  thockNumber,
-			Line2:  customerName,
-			Font1:  "5",
-			Font2:  "4",
-			Copies: twoUpCopies,
-		}
-		if err := s.sendPrintRequest("/print-2up", req); err != nil {
-			return err
+	printer, err := s.choosePrinter(ctx, models.PrinterKindLabel, counter, printerID)
+	if err != nil {
+		return nil, err
+	}
+	label := printing.Label{Line1: thockNumber, Line2: customerName}
+	if s.Scan != nil {
+		if label.Code, err = s.Scan.ThockCode(ctx, thockNumber); err != nil {
+			return nil, fmt.Errorf("failed to sign label code: %w", err)
 		}
 	}
+	payload, err := printing.RenderLabel(printer.Protocol, printerMedia(printer), label, copies)
+	if err != nil {
+		return nil, err
+	}
+	return s.submit(ctx, printer, models.PrinterKindLabel, thockNumber, copies, payload, userID)
+}
 
-	// Print single label if odd count
-	if singleLabel > 0 {
-		req := PrintFullRequest{
-			Line1:  thockNumber,
-			Line2:  customerName,
-			Font1:  "5",
-			Font2:  "4",
-			Copies: 1,
-		}
-		if err := s.sendPrintRequest("/print-full", req); err != nil {
-			return err
-		}
+// PrintLabel prints labels from the label screen
+func (s *PrinterService) PrintLabel(ctx context.Context, req *models.PrintLabelRequest, userID int) (*models.PrintJob, error) {
+	return s.PrintThockLabels(ctx, req.Counter, req.PrinterID, req.Line1, req.Line2, req.Copies, userID)
+}
+
+// PrintReceipt prints a receipt at a counter
+func (s *PrinterService) PrintReceipt(ctx context.Context, req *models.PrintReceiptRequest, userID int) (*models.PrintJob, error) {
+	if req.HTML == "" && req.Title == "" && len(req.Lines) == 0 {
+		return nil, fmt.Errorf("%w: no receipt content provided", ErrInvalidPrintRequest)
+	}
+	printer, err := s.choosePrinter(ctx, models.PrinterKindReceipt, req.Counter, req.PrinterID)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	receipt := printing.Receipt{Title: req.Title, Code: req.Code, Footer: req.Footer, HTML: req.HTML}
+	for _, line := range req.Lines {
+		receipt.Lines = append(receipt.Lines, printing.ReceiptLine{Left: line.Left, Right: line.Right, Bold: line.Bold})
+	}
+	payload, err := printing.RenderReceipt(printer.Protocol, receipt)
+	if errors.Is(err, printing.ErrUnsupported) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPrintRequest, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reference := req.Reference
+	if reference == "" {
+		reference = req.Title
+	}
+	return s.submit(ctx, printer, models.PrinterKindReceipt, reference, 1, payload, userID)
 }
 
-// PrintReceipt sends HTML content to HP printer
-func (s *PrinterService) PrintReceipt(html string) error {
-	reqData := map[string]string{"html": html}
-	jsonData, err := json.Marshal(reqData)
+// TestPrinter prints a test label or receipt
+func (s *PrinterService) TestPrinter(ctx context.Context, id, userID int) (*models.PrintJob, error) {
+	printer, err := s.Repo.GetPrinter(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to marshal receipt request: %w", err)
+		return nil, err
 	}
 
-	resp, err := s.client.Post(
-		s.baseURL+"/print-receipt",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	var payload []byte
+	if printer.Kind == models.PrinterKindLabel {
+		payload, err = printing.RenderLabel(printer.Protocol, printerMedia(printer), printing.Label{Line1: "TEST", Line2: printer.Name}, 1)
+	} else {
+		payload, err = printing.RenderReceipt(printer.Protocol, printing.Receipt{
+			Title: "Test receipt",
+			Lines: []printing.ReceiptLine{{Left: "Printer", Right: printer.Name}, {Left: "Counter", Right: printer.Counter}},
+			Code:  "TEST",
+			HTML:  "<html><body><h3>Test receipt</h3><p>" + printer.Name + "</p></body></html>",
+		})
+	}
 	if err != nil {
-		return fmt.Errorf("failed to send receipt request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return s.submit(ctx, printer, printer.Kind, "TEST", 1, payload, userID)
+}
 
-	var printResp PrintResponse
-	if err := json.NewDecoder(resp.Body).Decode(&printResp); err != nil {
-		return fmt.Errorf("failed to decode receipt response: %w", err)
+// submit queues a job and tries it at once. A failed first try is not an error: the
+// job stays queued for the worker.
+func (s *PrinterService) submit(ctx context.Context, printer *models.Printer, jobType, reference string, copies int, payload []byte, userID int) (*models.PrintJob, error) {
+	job := &models.PrintJob{
+		PrinterID:   printer.ID,
+		PrinterName: printer.Name,
+		JobType:     jobType,
+		Reference:   reference,
+		Copies:      copies,
+		Payload:     payload,
+		Status:      models.PrintJobPrinting,
+		Attempts:    1,
+	}
+	if userID > 0 {
+		job.CreatedByUserID = &userID
+	}
+	if err := s.Repo.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to queue print job: %w", err)
 	}
 
-	if !printResp.Success {
-		return fmt.Errorf("receipt print failed: %s", printResp.Message)
+	// Finish the send even if the client goes away
+	s.attempt(context.WithoutCancel(ctx), job, printer)
+	return job, nil
+}
+
+// attempt sends a claimed job and records the outcome
+func (s *PrinterService) attempt(ctx context.Context, job *models.PrintJob, printer *models.Printer) {
+	err := s.send(ctx, job, printer)
+	if err == nil {
+		if err := s.Repo.MarkPrinted(ctx, job); err != nil {
+			log.Printf("[Printer] Job %d printed but not marked: %v", job.ID, err)
+		}
+		return
 	}
 
-	return nil
+	retryAt := time.Now().Add(printRetryDelay(job.Attempts))
+	if markErr := s.Repo.MarkAttemptFailed(ctx, job, err.Error(), retryAt); markErr != nil {
+		log.Printf("[Printer] Failed to record failure of job %d: %v", job.ID, markErr)
+		return
+	}
+	if job.Status == models.PrintJobFailed {
+		log.Printf("[Printer] Job %d (%s on %s) failed after %d attempts: %v", job.ID, job.Reference, job.PrinterName, job.Attempts, err)
+	} else {
+		log.Printf("[Printer] Job %d (%s on %s) attempt %d failed, retrying: %v", job.ID, job.Reference, job.PrinterName, job.Attempts, err)
+	}
 }
 
-func (s *PrinterService) sendPrintRequest(endpoint string, req PrintFullRequest) error {
-	jsonData, err := json.Marshal(req)
+func (s *PrinterService) send(ctx context.Context, job *models.PrintJob, printer *models.Printer) error {
+	if printer == nil {
+		var err error
+		if printer, err = s.Repo.GetPrinter(ctx, job.PrinterID); err != nil {
+			return err
+		}
+	}
+	if !printer.IsActive {
+		return fmt.Errorf("printer %s is disabled", printer.Name)
+	}
+	target, err := printing.NewTarget(printer.Protocol, printer.Address, s.Fake)
 	if err != nil {
-		return fmt.Errorf("failed to marshal print request: %w", err)
+		return err
+	}
+	return target.Send(ctx, job.Payload)
+}
+
+// printRetryDelay doubles the wait after each failed attempt
+func printRetryDelay(attempts int) time.Duration {
+	delay := printRetryBase
+	for i := 1; i < attempts && delay < printRetryMax; i++ {
+		delay *= 2
 	}
+	return min(delay, printRetryMax)
+}
+
+// Start runs the print queue worker
+func (s *PrinterService) Start() {
+	go func() {
+		log.Println("[Printer] Print queue worker started")
+		ticker := time.NewTicker(printQueueInterval)
+		defer ticker.Stop()
+		for {
+			s.processQueue()
+			select {
+			case <-s.stopCh:
+				log.Println("[Printer] Print queue worker stopped")
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Stop stops the print queue worker
+func (s *PrinterService) Stop() {
+	close(s.stopCh)
+}
+
+// wakeQueue makes the worker look at the queue now
+func (s *PrinterService) wakeQueue() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
 
-	resp, err := s.client.Post(
-		s.baseURL+endpoint,
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+// processQueue sends the jobs that are due
+func (s *PrinterService) processQueue() {
+	ctx := context.Background()
+
+	if n, err := s.Repo.RequeueStale(ctx, printStaleAfter); err != nil {
+		log.Printf("[Printer] Failed to requeue stale jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("[Printer] Requeued %d interrupted jobs", n)
+	}
+
+	jobs, err := s.Repo.ClaimDueJobs(ctx, printQueueBatch)
 	if err != nil {
-		return fmt.Errorf("failed to send print request: %w", err)
+		log.Printf("[Printer] Failed to claim jobs: %v", err)
+		return
 	}
-	defer resp.Body.Close()
+	for _, job := range jobs {
+		s.attempt(ctx, job, nil)
+	}
+}
+
+// ListJobs returns recent print jobs
+func (s *PrinterService) ListJobs(ctx context.Context, status string) ([]*models.PrintJob, error) {
+	return s.Repo.ListJobs(ctx, status, 200)
+}
 
-	var printResp PrintResponse
-	if err := json.NewDecoder(resp.Body).Decode(&printResp); err != nil {
-		return fmt.Errorf("failed to decode print response: %w", err)
+// RetryJob queues a failed or cancelled job again
+func (s *PrinterService) RetryJob(ctx context.Context, id int) (*models.PrintJob, error) {
+	if err := s.Repo.Retry(ctx, id); err != nil {
+		return nil, err
 	}
+	s.wakeQueue()
+	return s.Repo.GetJob(ctx, id)
+}
 
-	if !printResp.Success {
-		return fmt.Errorf("print failed: %s", printResp.Message)
+// CancelJob stops a queued or failed job
+func (s *PrinterService) CancelJob(ctx context.Context, id int) (*models.PrintJob, error) {
+	if err := s.Repo.Cancel(ctx, id); err != nil {
+		return nil, err
 	}
+	return s.Repo.GetJob(ctx, id)
+}
 
+// ListPrinters returns all printers
+func (s *PrinterService) ListPrinters(ctx context.Context) ([]*models.Printer, error) {
+	return s.Repo.ListPrinters(ctx)
+}
+
+// validatePrinter checks a printer's settings and fills in label defaults
+func validatePrinter(p *models.Printer) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Counter = strings.TrimSpace(p.Counter)
+	p.Address = strings.TrimSpace(p.Address)
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPrintRequest)
+	}
+
+	switch p.Kind {
+	case models.PrinterKindLabel:
+	case models.PrinterKindReceipt:
+		if p.Protocol == printing.ProtocolZPL || p.Protocol == printing.ProtocolTSPL {
+			return fmt.Errorf("%w: receipt printers use escpos, bridge or fake", ErrInvalidPrintRequest)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be 'label' or 'receipt'", ErrInvalidPrintRequest)
+	}
+
+	switch p.Protocol {
+	case printing.ProtocolZPL, printing.ProtocolTSPL, printing.ProtocolESCPOS, printing.ProtocolBridge:
+		if p.Address == "" {
+			return fmt.Errorf("%w: address is required", ErrInvalidPrintRequest)
+		}
+	case printing.ProtocolFake:
+	default:
+		return fmt.Errorf("%w: protocol must be zpl, tspl, escpos, bridge or fake", ErrInvalidPrintRequest)
+	}
+	if p.Protocol == printing.ProtocolBridge && !strings.HasPrefix(p.Address, "http://") && !strings.HasPrefix(p.Address, "https://") {
+		return fmt.Errorf("%w: print bridge address must be an http(s) URL", ErrInvalidPrintRequest)
+	}
+
+	if p.LabelWidthMM == 0 {
+		p.LabelWidthMM = 50
+	}
+	if p.LabelHeightMM == 0 {
+		p.LabelHeightMM = 25
+	}
+	if p.LabelColumns == 0 {
+		p.LabelColumns = 2
+	}
+	if p.DPI == 0 {
+		p.DPI = 203
+	}
+	if p.CodeType == "" {
+		p.CodeType = printing.CodeQR
+	}
+	if p.LabelWidthMM < 10 || p.LabelWidthMM > 200 || p.LabelHeightMM < 10 || p.LabelHeightMM > 200 {
+		return fmt.Errorf("%w: label size must be 10-200 mm", ErrInvalidPrintRequest)
+	}
+	if p.LabelColumns != 1 && p.LabelColumns != 2 {
+		return fmt.Errorf("%w: label columns must be 1 or 2", ErrInvalidPrintRequest)
+	}
+	if p.DPI != 203 && p.DPI != 300 && p.DPI != 600 {
+		return fmt.Errorf("%w: dpi must be 203, 300 or 600", ErrInvalidPrintRequest)
+	}
+	switch p.CodeType {
+	case printing.CodeQR, printing.CodeCode128, printing.CodeNone:
+	default:
+		return fmt.Errorf("%w: code type must be qr, code128 or none", ErrInvalidPrintRequest)
+	}
 	return nil
 }
 
+// CreatePrinter adds a printer
+func (s *PrinterService) CreatePrinter(ctx context.Context, p *models.Printer) error {
+	if err := validatePrinter(p); err != nil {
+		return err
+	}
+	return s.Repo.CreatePrinter(ctx, p)
+}
+
+// UpdatePrinter saves a printer's settings
+func (s *PrinterService) UpdatePrinter(ctx context.Context, p *models.Printer) error {
+	if err := validatePrinter(p); err != nil {
+		return err
+	}
+	return s.Repo.UpdatePrinter(ctx, p)
+}
+
+// DeletePrinter removes a printer
+func (s *PrinterService) DeletePrinter(ctx context.Context, id int) error {
+	return s.Repo.DeletePrinter(ctx, id)
+}
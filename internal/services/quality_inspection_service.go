@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// ErrInvalidInspection is returned for inspections that cannot be recorded
+var ErrInvalidInspection = errors.New("invalid quality inspection")
+
+// Days-in-storage bands of the stock aging report
+var stockAgingBands = [][2]int{{0, 30}, {31, 60}, {61, 90}, {91, 120}, {121, 180}, {181, 240}, {241, 0}}
+
+// QualityInspectionService records periodic quality inspections of stored thocks, flags
+// deteriorating stock so owners can be told to withdraw early, and reports stock age
+type QualityInspectionService struct {
+	Repo           *repositories.QualityInspectionRepository
+	StockRepo      *repositories.GatarStockRepository
+	MediaRepo      *repositories.RoomEntryMediaRepository
+	SettingRepo    *repositories.SystemSettingRepository
+	EntryRepo      *repositories.EntryRepository
+	RoomEntryRepo  *repositories.RoomEntryRepository
+	EntryEventRepo *repositories.EntryEventRepository
+	Notifier       *NotificationService
+	SyncService    *MediaSyncService
+}
+
+func NewQualityInspectionService(repo *repositories.QualityInspectionRepository, stockRepo *repositories.GatarStockRepository, mediaRepo *repositories.RoomEntryMediaRepository, settingRepo *repositories.SystemSettingRepository) *QualityInspectionService {
+	return &QualityInspectionService{Repo: repo, StockRepo: stockRepo, MediaRepo: mediaRepo, SettingRepo: settingRepo}
+}
+
+// SetEventRepos enables QUALITY_CHECK events on the entry timeline and owner lookups
+func (s *QualityInspectionService) SetEventRepos(entryRepo *repositories.EntryRepository, roomEntryRepo *repositories.RoomEntryRepository, entryEventRepo *repositories.EntryEventRepository) {
+	s.EntryRepo = entryRepo
+	s.RoomEntryRepo = roomEntryRepo
+	s.EntryEventRepo = entryEventRepo
+}
+
+// SetNotificationService enables SMS to owners of deteriorating thocks
+func (s *QualityInspectionService) SetNotificationService(notifier *NotificationService) {
+	s.Notifier = notifier
+}
+
+// SetSyncService enables cloud sync of inspection photos
+func (s *QualityInspectionService) SetSyncService(syncService *MediaSyncService) {
+	s.SyncService = syncService
+}
+
+// Record saves an inspection of a thock (or one of its gatars) that still has bags in store
+func (s *QualityInspectionService) Record(ctx context.Context, req *models.QualityInspectionRequest, userID int) (*models.QualityInspection, error) {
+	req.ThockNumber = strings.TrimSpace(req.ThockNumber)
+	if err := validateInspection(req); err != nil {
+		return nil, err
+	}
+
+	inspectedOn := timeutil.StartOfDay(timeutil.Now())
+	if req.InspectedOn != "" {
+		day, err := timeutil.ParseInIST(timeutil.DateLayout, req.InspectedOn)
+		if err != nil {
+			return nil, fmt.Errorf("%w: inspected_on must be YYYY-MM-DD", ErrInvalidInspection)
+		}
+		if day.After(inspectedOn) {
+			return nil, fmt.Errorf("%w: inspected_on cannot be in the future", ErrInvalidInspection)
+		}
+		inspectedOn = day
+	}
+
+	// Attach the inspection to the room entry holding the most bags of the thock (in the gatar)
+	balances, err := s.StockRepo.ListBalances(ctx, req.ThockNumber)
+	if err != nil {
+		return nil, err
+	}
+	var target *models.GatarBalance
+	for _, b := range balances {
+		if b.Quantity <= 0 || (req.GatarNo > 0 && b.GatarNo != req.GatarNo) {
+			continue
+		}
+		if target == nil || b.Quantity > target.Quantity {
+			target = b
+		}
+	}
+	if target == nil {
+		if req.GatarNo > 0 {
+			return nil, fmt.Errorf("%w: thock %s has no bags in gatar %d", ErrInvalidInspection, req.ThockNumber, req.GatarNo)
+		}
+		return nil, fmt.Errorf("%w: thock %s has no bags in store", ErrInvalidInspection, req.ThockNumber)
+	}
+
+	inspection := &models.QualityInspection{
+		RoomEntryID:     target.RoomEntryID,
+		ThockNumber:     req.ThockNumber,
+		RoomNo:          target.RoomNo,
+		Floor:           target.Floor,
+		InspectedOn:     inspectedOn,
+		InspectorUserID: &userID,
+		InspectorName:   strings.TrimSpace(req.InspectorName),
+		SproutingPct:    req.SproutingPct,
+		RotPct:          req.RotPct,
+		SampleBags:      req.SampleBags,
+		SampleWeightKg:  req.SampleWeightKg,
+		Condition:       req.Condition,
+		Notes:           strings.TrimSpace(req.Notes),
+	}
+	if req.GatarNo > 0 {
+		inspection.GatarNo = &req.GatarNo
+	}
+	if inspection.SampleBags > 0 {
+		inspection.AvgBagWeightKg = inspection.SampleWeightKg / float64(inspection.SampleBags)
+	}
+	if inspection.Condition == "" {
+		inspection.Condition = s.conditionFor(ctx, req.RotPct, req.SproutingPct)
+	}
+
+	photos := make([]*models.RoomEntryMedia, 0, len(req.Photos))
+	for _, p := range req.Photos {
+		photos = append(photos, &models.RoomEntryMedia{
+			RoomEntryID:      target.RoomEntryID,
+			ThockNumber:      req.ThockNumber,
+			MediaType:        "inspection",
+			FilePath:         p.FilePath,
+			FileName:         p.FileName,
+			FileType:         p.FileType,
+			FileSize:         p.FileSize,
+			UploadedByUserID: &userID,
+		})
+	}
+
+	if err := s.Repo.Create(ctx, inspection, photos); err != nil {
+		return nil, err
+	}
+	inspection.Photos = photos
+
+	if s.SyncService != nil {
+		for _, p := range photos {
+			var fileSize int64
+			if p.FileSize != nil {
+				fileSize = *p.FileSize
+			}
+			go s.SyncService.EnqueueMedia(context.Background(), "room_entry", p.ID, p.FilePath, p.FileName, fileSize, p.ThockNumber, p.MediaType)
+		}
+	}
+
+	s.logInspectionEvent(ctx, inspection, userID)
+	if inspection.Condition == models.InspectionConditionDeteriorating {
+		log.Printf("[Quality] Thock %s inspected as deteriorating (rot %.1f%%, sprouting %.1f%%)",
+			inspection.ThockNumber, inspection.RotPct, inspection.SproutingPct)
+	}
+	return inspection, nil
+}
+
+// Get returns an inspection with its photos
+func (s *QualityInspectionService) Get(ctx context.Context, id int) (*models.QualityInspection, error) {
+	inspection, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachPhotos(ctx, []*models.QualityInspection{inspection}); err != nil {
+		return nil, err
+	}
+	return inspection, nil
+}
+
+// List returns recent inspections with their photos, optionally for one thock or condition
+func (s *QualityInspectionService) List(ctx context.Context, thockNumber, condition string) ([]*models.QualityInspection, error) {
+	inspections, err := s.Repo.List(ctx, strings.TrimSpace(thockNumber), condition, 500)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachPhotos(ctx, inspections); err != nil {
+		return nil, err
+	}
+	return inspections, nil
+}
+
+// ListAlerts returns thocks still in store whose latest inspection found them deteriorating,
+// owners not yet told first
+func (s *QualityInspectionService) ListAlerts(ctx context.Context) ([]*models.QualityAlert, error) {
+	return s.Repo.ListAlerts(ctx)
+}
+
+// NotifyOwner marks a deteriorating inspection's owner as told to withdraw early, sending an
+// SMS when sendSMS is set (otherwise staff told the owner in person or by phone)
+func (s *QualityInspectionService) NotifyOwner(ctx context.Context, id, userID int, sendSMS bool) (*models.QualityInspection, error) {
+	inspection, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if inspection.Condition != models.InspectionConditionDeteriorating {
+		return nil, fmt.Errorf("%w: inspection %d did not find the thock deteriorating", ErrInvalidInspection, id)
+	}
+
+	if sendSMS {
+		if s.Notifier == nil || s.EntryRepo == nil || !s.Notifier.isEnabled(ctx, models.SettingSMSQualityAlert) {
+			return nil, fmt.Errorf("%w: quality alert SMS is turned off", ErrInvalidInspection)
+		}
+		entry, err := s.EntryRepo.GetByThockNumber(ctx, inspection.ThockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Phone == "" {
+			return nil, fmt.Errorf("%w: thock %s has no phone number", ErrInvalidInspection, inspection.ThockNumber)
+		}
+		bags := 0
+		balances, err := s.StockRepo.ListBalances(ctx, inspection.ThockNumber)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range balances {
+			bags += b.Quantity
+		}
+		if err := s.Notifier.NotifyQualityAlert(ctx, entry, bags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.Repo.MarkOwnerNotified(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.Get(ctx, id)
+}
+
+// AgingReport groups the thocks in store by days since they were first loaded. minDays and
+// condition (latest inspection; "uninspected" for none) narrow the rows, not the bands.
+func (s *QualityInspectionService) AgingReport(ctx context.Context, minDays int, condition string) (*models.StockAgingReport, error) {
+	rows, err := s.Repo.ListAging(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeutil.Now()
+	report := &models.StockAgingReport{AsOf: now, Rows: []*models.StockAgingRow{}}
+	for _, band := range stockAgingBands {
+		label := fmt.Sprintf("%d-%d days", band[0], band[1])
+		if band[1] == 0 {
+			label = fmt.Sprintf("%d+ days", band[0])
+		}
+		report.Buckets = append(report.Buckets, &models.StockAgingBucket{Label: label, MinDays: band[0], MaxDays: band[1]})
+	}
+
+	today := timeutil.StartOfDay(now)
+	for _, row := range rows {
+		row.DaysInStorage = int(today.Sub(timeutil.StartOfDay(row.StoredSince)).Hours() / 24)
+		if row.LastInspectedOn != nil {
+			days := int(today.Sub(timeutil.StartOfDay(*row.LastInspectedOn)).Hours() / 24)
+			row.DaysSinceInspection = &days
+		}
+
+		report.TotalBags += row.Bags
+		for _, b := range report.Buckets {
+			if row.DaysInStorage >= b.MinDays && (b.MaxDays == 0 || row.DaysInStorage <= b.MaxDays) {
+				b.Thocks++
+				b.Bags += row.Bags
+				break
+			}
+		}
+
+		if row.DaysInStorage < minDays {
+			continue
+		}
+		switch {
+		case condition == "":
+		case condition == "uninspected" && row.LastCondition == "":
+		case condition == row.LastCondition:
+		default:
+			continue
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+func validateInspection(req *models.QualityInspectionRequest) error {
+	switch {
+	case req.ThockNumber == "":
+		return fmt.Errorf("%w: thock number is required", ErrInvalidInspection)
+	case req.GatarNo < 0:
+		return fmt.Errorf("%w: invalid gatar number", ErrInvalidInspection)
+	case req.SproutingPct < 0 || req.SproutingPct > 100:
+		return fmt.Errorf("%w: sprouting must be 0-100%%", ErrInvalidInspection)
+	case req.RotPct < 0 || req.RotPct > 100:
+		return fmt.Errorf("%w: rot must be 0-100%%", ErrInvalidInspection)
+	case req.SampleBags < 0 || req.SampleWeightKg < 0:
+		return fmt.Errorf("%w: sample bags and weight cannot be negative", ErrInvalidInspection)
+	case req.SampleWeightKg > 0 && req.SampleBags == 0:
+		return fmt.Errorf("%w: sample weight needs the number of bags weighed", ErrInvalidInspection)
+	}
+	switch req.Condition {
+	case "", models.InspectionConditionGood, models.InspectionConditionFair, models.InspectionConditionDeteriorating:
+	default:
+		return fmt.Errorf("%w: condition must be good, fair or deteriorating", ErrInvalidInspection)
+	}
+	for _, p := range req.Photos {
+		if p.FilePath == "" || p.FileName == "" || strings.Contains(p.FilePath, "..") {
+			return fmt.Errorf("%w: invalid photo path", ErrInvalidInspection)
+		}
+	}
+	return nil
+}
+
+// conditionFor grades an inspection from the rot/sprouting alert settings: at or above either
+// limit is deteriorating, at or above half of either is fair
+func (s *QualityInspectionService) conditionFor(ctx context.Context, rotPct, sproutingPct float64) string {
+	rotLimit := s.percentSetting(ctx, "inspection_rot_alert_pct", 5)
+	sproutLimit := s.percentSetting(ctx, "inspection_sprouting_alert_pct", 10)
+	switch {
+	case rotPct >= rotLimit || sproutingPct >= sproutLimit:
+		return models.InspectionConditionDeteriorating
+	case rotPct >= rotLimit/2 || sproutingPct >= sproutLimit/2:
+		return models.InspectionConditionFair
+	}
+	return models.InspectionConditionGood
+}
+
+func (s *QualityInspectionService) percentSetting(ctx context.Context, key string, fallback float64) float64 {
+	if s.SettingRepo == nil {
+		return fallback
+	}
+	setting, err := s.SettingRepo.Get(ctx, key)
+	if err != nil || setting == nil {
+		return fallback
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(setting.SettingValue), 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func (s *QualityInspectionService) attachPhotos(ctx context.Context, inspections []*models.QualityInspection) error {
+	if s.MediaRepo == nil || len(inspections) == 0 {
+		return nil
+	}
+	ids := make([]int, len(inspections))
+	for n, i := range inspections {
+		ids[n] = i.ID
+	}
+	photos, err := s.MediaRepo.ListByInspectionIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, i := range inspections {
+		i.Photos = photos[i.ID]
+	}
+	return nil
+}
+
+// logInspectionEvent adds a QUALITY_CHECK event to the entry timeline
+func (s *QualityInspectionService) logInspectionEvent(ctx context.Context, inspection *models.QualityInspection, userID int) {
+	if s.RoomEntryRepo == nil || s.EntryEventRepo == nil {
+		return
+	}
+	roomEntry, err := s.RoomEntryRepo.Get(ctx, inspection.RoomEntryID)
+	if err != nil {
+		return
+	}
+	where := fmt.Sprintf("Room %s, Floor %s", inspection.RoomNo, inspection.Floor)
+	if inspection.GatarNo != nil {
+		where += fmt.Sprintf(", Gatar %d", *inspection.GatarNo)
+	}
+	notes := fmt.Sprintf("Inspected %s (%s): sprouting %.1f%%, rot %.1f%% - %s",
+		inspection.InspectedOn.Format("02 Jan 2006"), where, inspection.SproutingPct, inspection.RotPct, inspection.Condition)
+	if inspection.SampleBags > 0 {
+		notes += fmt.Sprintf("; sample %d bags avg %.1f kg", inspection.SampleBags, inspection.AvgBagWeightKg)
+	}
+	status := models.StatusInStorage
+	if inspection.Condition == models.InspectionConditionDeteriorating {
+		status = models.StatusOnHold
+	}
+	// Don't fail the inspection if the event fails
+	s.EntryEventRepo.Create(ctx, &models.EntryEvent{
+		EntryID:         roomEntry.EntryID,
+		EventType:       models.EventTypeQualityCheck,
+		Status:          status,
+		Notes:           notes,
+		CreatedByUserID: userID,
+	})
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// RentAccrualService posts rent as CHARGE ledger entries so the ledger's
+// running_balance reflects rent actually owed.
+//
+// Each entry (thock) has an accrual scope "{entryID}-{createdUnix}" (entry IDs
+// restart every season, the creation time keeps scopes unique). A run computes
+// the rent due for the scope and posts only the difference to what was already
+// charged, keyed "rent:{scope}:{period}:{charged}:{target}". Re-running a period
+// therefore never double-charges. Rent is billed on the bags put into rooms, the
+// same quantity the reports use; when it drops the difference is reversed with a
+// CREDIT entry.
+type RentAccrualService struct {
+	EntryRepo     *repositories.EntryRepository
+	RoomEntryRepo *repositories.RoomEntryRepository
+	LedgerService *LedgerService
+	TariffService *TariffService
+	AccrualRepo   *repositories.RentAccrualRepository
+	SettingsRepo  *repositories.SystemSettingRepository
+
+	mu     sync.Mutex // One run at a time
+	stopCh chan struct{}
+}
+
+func NewRentAccrualService(
+	entryRepo *repositories.EntryRepository,
+	roomEntryRepo *repositories.RoomEntryRepository,
+	ledgerService *LedgerService,
+	tariffService *TariffService,
+	accrualRepo *repositories.RentAccrualRepository,
+	settingsRepo *repositories.SystemSettingRepository,
+) *RentAccrualService {
+	return &RentAccrualService{
+		EntryRepo:     entryRepo,
+		RoomEntryRepo: roomEntryRepo,
+		LedgerService: ledgerService,
+		TariffService: tariffService,
+		AccrualRepo:   accrualRepo,
+		SettingsRepo:  settingsRepo,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Run accrues rent for all current-season entries as of the given time
+func (s *RentAccrualService) Run(ctx context.Context, asOf time.Time, trigger string, userID int) (*models.RentAccrualRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := &models.RentAccrualRun{Trigger: trigger, AsOf: asOf}
+	if userID > 0 {
+		run.StartedByUserID = &userID
+	}
+	if err := s.AccrualRepo.CreateRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to record accrual run: %w", err)
+	}
+
+	runErr := s.accrue(ctx, run)
+	run.Status = "completed"
+	if runErr != nil {
+		run.Status = "failed"
+		run.ErrorMessage = runErr.Error()
+	}
+	if err := s.AccrualRepo.CompleteRun(ctx, run); err != nil {
+		log.Printf("[RentAccrual] Failed to complete run %d: %v", run.ID, err)
+	}
+	if run.ChargesPosted > 0 {
+		cache.InvalidatePaymentCaches(ctx)
+	}
+
+	log.Printf("[RentAccrual] Run %d (%s): %d entries checked, %d charges posted (%.2f)",
+		run.ID, trigger, run.EntriesChecked, run.ChargesPosted, run.AmountPosted)
+	return run, runErr
+}
+
+// accrue posts the missing rent for every entry and fills in the run totals
+func (s *RentAccrualService) accrue(ctx context.Context, run *models.RentAccrualRun) error {
+	rateCard, err := s.TariffService.LoadRateCard(ctx)
+	if err != nil {
+		return err
+	}
+	entries, err := s.EntryRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load entries: %w", err)
+	}
+	stored, err := s.RoomEntryRepo.GetStoredQuantityByThock(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load stored quantities: %w", err)
+	}
+	accrued, err := s.LedgerService.LedgerRepo.GetAccruedRentByScope(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load accrued rent: %w", err)
+	}
+
+	seasonClose := run.Trigger == models.RentAccrualTriggerSeasonClose
+	lastMonthEnd := startOfMonth(run.AsOf).Add(-time.Nanosecond)
+
+	for _, entry := range entries {
+		scope := fmt.Sprintf("%d-%d", entry.ID, entry.CreatedAt.Unix())
+		quantity := stored[entry.ThockNumber]
+		if entry.CreatedAt.After(run.AsOf) || (quantity <= 0 && accrued[scope] == 0) {
+			continue // Nothing stored yet
+		}
+		run.EntriesChecked++
+
+		// Work out which period this run charges the entry for
+		var period string
+		var chargeAsOf time.Time
+		switch {
+		case seasonClose:
+			period, chargeAsOf = "season-close", run.AsOf
+		case rateCard.AccrualMode(entry) == models.RentAccrualMonthly:
+			if entry.CreatedAt.After(lastMonthEnd) {
+				continue // Charged at the first month end after storage
+			}
+			period, chargeAsOf = timeutil.FormatIST(lastMonthEnd, "2006-01"), lastMonthEnd
+		default:
+			period, chargeAsOf = "storage", entry.CreatedAt
+		}
+
+		target := roundRupees(rateCard.EntryRent(entry, quantity, chargeAsOf))
+		delta := roundRupees(target - accrued[scope])
+		if math.Abs(delta) < 0.01 {
+			continue // Already charged
+		}
+
+		refID := entry.ID
+		req := &models.CreateLedgerEntryRequest{
+			CustomerPhone:    entry.Phone,
+			CustomerName:     entry.Name,
+			CustomerSO:       entry.SO,
+			EntryType:        models.LedgerEntryTypeCharge,
+			Description:      fmt.Sprintf("Rent for thock %s (%d bags, %s)", entry.ThockNumber, quantity, period),
+			Debit:            delta,
+			ReferenceID:      &refID,
+			ReferenceType:    models.LedgerReferenceRentAccrual,
+			FamilyMemberID:   entry.FamilyMemberID,
+			FamilyMemberName: entry.FamilyMemberName,
+			CreatedByUserID:  0, // System
+			IdempotencyKey:   fmt.Sprintf("rent:%s:%s:%.2f:%.2f", scope, period, accrued[scope], target),
+		}
+		if delta < 0 {
+			// Fewer bags than were charged for (room entry edited down or deleted)
+			req.EntryType = models.LedgerEntryTypeCredit
+			req.Description = fmt.Sprintf("Rent reversed for thock %s (%d bags, %s)", entry.ThockNumber, quantity, period)
+			req.Debit, req.Credit = 0, -delta
+		}
+		_, err := s.LedgerService.CreateEntry(ctx, req)
+		if errors.Is(err, repositories.ErrDuplicateLedgerEntry) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to post rent for thock %s: %w", entry.ThockNumber, err)
+		}
+
+		accrued[scope] = target
+		run.ChargesPosted++
+		run.AmountPosted += delta
+	}
+
+	run.AmountPosted = roundRupees(run.AmountPosted)
+	return nil
+}
+
+// Backfill accrues the current season up to now, including every month end
+// since the first entry so monthly rate cards get their historical charges
+func (s *RentAccrualService) Backfill(ctx context.Context, userID int) ([]*models.RentAccrualRun, error) {
+	entries, err := s.EntryRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entries: %w", err)
+	}
+
+	now := timeutil.Now()
+	var runs []*models.RentAccrualRun
+	if len(entries) > 0 {
+		earliest := entries[len(entries)-1].CreatedAt // List is newest first
+		for month := startOfMonth(earliest).AddDate(0, 1, 0); month.Before(now); month = month.AddDate(0, 1, 0) {
+			// A run as of the 1st charges the month that just ended
+			run, err := s.Run(ctx, month, models.RentAccrualTriggerBackfill, userID)
+			if err != nil {
+				return runs, err
+			}
+			runs = append(runs, run)
+		}
+	}
+
+	run, err := s.Run(ctx, now, models.RentAccrualTriggerBackfill, userID)
+	if err != nil {
+		return runs, err
+	}
+	return append(runs, run), nil
+}
+
+// CloseSeason trues up every entry to its full rent before the season is archived
+func (s *RentAccrualService) CloseSeason(ctx context.Context, userID int) (*models.RentAccrualRun, error) {
+	return s.Run(ctx, timeutil.Now(), models.RentAccrualTriggerSeasonClose, userID)
+}
+
+// ListRuns returns recent accrual runs
+func (s *RentAccrualService) ListRuns(ctx context.Context, limit int) ([]*models.RentAccrualRun, error) {
+	return s.AccrualRepo.ListRuns(ctx, limit)
+}
+
+// Start runs the accrual job once a day (IST) in the background
+func (s *RentAccrualService) Start() {
+	go func() {
+		log.Println("[RentAccrual] Scheduler started (daily)")
+		s.runScheduled()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				log.Println("[RentAccrual] Scheduler stopped")
+				return
+			case <-ticker.C:
+				s.runScheduled()
+			}
+		}
+	}()
+}
+
+// Stop stops the background scheduler
+func (s *RentAccrualService) Stop() {
+	close(s.stopCh)
+}
+
+// runScheduled runs the daily accrual unless it is disabled or already done today
+func (s *RentAccrualService) runScheduled() {
+	ctx := context.Background()
+
+	if setting, err := s.SettingsRepo.Get(ctx, "rent_accrual_enabled"); err == nil && setting != nil && setting.SettingValue != "true" {
+		return
+	}
+
+	now := timeutil.Now()
+	last, err := s.AccrualRepo.GetLastCompletedRun(ctx, models.RentAccrualTriggerScheduled)
+	if err != nil {
+		log.Printf("[RentAccrual] Failed to check last run: %v", err)
+		return
+	}
+	if last != nil && timeutil.StartOfDay(last.AsOf).Equal(timeutil.StartOfDay(now)) {
+		return
+	}
+
+	if _, err := s.Run(ctx, now, models.RentAccrualTriggerScheduled, 0); err != nil {
+		log.Printf("[RentAccrual] Scheduled run failed: %v", err)
+	}
+}
+
+// startOfMonth returns 00:00 IST on the first day of t's month
+func startOfMonth(t time.Time) time.Time {
+	ist := timeutil.ToIST(t)
+	return time.Date(ist.Year(), ist.Month(), 1, 0, 0, 0, 0, timeutil.IST)
+}
+
+// roundRupees rounds an amount to paise
+func roundRupees(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cold-backend/internal/cache"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// RentAdjustmentService evaluates interest and discount policies against each
+// thock's outstanding rent and posts the result as CHARGE/CREDIT ledger entries.
+//
+// A thock falls due grace_days after the policy's effective_from or its storage
+// date, whichever is later. Interest is charged for every month it is overdue on
+// the outstanding it had when that month started, so a month posted late is
+// charged as it would have been on time. A discount is credited once, when the
+// thock is fully paid inside the policy window. Postings are keyed per policy,
+// thock scope and month, so a run never posts twice.
+type RentAdjustmentService struct {
+	PolicyRepo        *repositories.RentAdjustmentRepository
+	EntryRepo         *repositories.EntryRepository
+	LedgerService     *LedgerService
+	AllocationService *PaymentAllocationService
+	SettingsRepo      *repositories.SystemSettingRepository
+
+	mu     sync.Mutex // One run at a time
+	stopCh chan struct{}
+}
+
+func NewRentAdjustmentService(
+	policyRepo *repositories.RentAdjustmentRepository,
+	entryRepo *repositories.EntryRepository,
+	ledgerService *LedgerService,
+	allocationService *PaymentAllocationService,
+	settingsRepo *repositories.SystemSettingRepository,
+) *RentAdjustmentService {
+	return &RentAdjustmentService{
+		PolicyRepo:        policyRepo,
+		EntryRepo:         entryRepo,
+		LedgerService:     ledgerService,
+		AllocationService: allocationService,
+		SettingsRepo:      settingsRepo,
+		stopCh:            make(chan struct{}),
+	}
+}
+
+// ListPolicies returns all policies, optionally only active ones
+func (s *RentAdjustmentService) ListPolicies(ctx context.Context, activeOnly bool) ([]*models.RentAdjustmentPolicy, error) {
+	return s.PolicyRepo.List(ctx, activeOnly)
+}
+
+// GetPolicy returns a single policy
+func (s *RentAdjustmentService) GetPolicy(ctx context.Context, id int) (*models.RentAdjustmentPolicy, error) {
+	return s.PolicyRepo.Get(ctx, id)
+}
+
+// CreatePolicy validates and stores a new policy
+func (s *RentAdjustmentService) CreatePolicy(ctx context.Context, req *models.RentAdjustmentPolicyRequest, userID int) (*models.RentAdjustmentPolicy, error) {
+	policy, err := policyFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if userID > 0 {
+		policy.CreatedByUserID = &userID
+	}
+	if err := s.PolicyRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy changes the terms of a policy; postings already made are kept
+func (s *RentAdjustmentService) UpdatePolicy(ctx context.Context, id int, req *models.RentAdjustmentPolicyRequest) (*models.RentAdjustmentPolicy, error) {
+	policy, err := policyFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	policy.ID = id
+	if err := s.PolicyRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+	return s.PolicyRepo.Get(ctx, id)
+}
+
+// SetPolicyActive activates or retires a policy
+func (s *RentAdjustmentService) SetPolicyActive(ctx context.Context, id int, active bool) error {
+	return s.PolicyRepo.SetActive(ctx, id, active)
+}
+
+// policyFromRequest validates a create/update request
+func policyFromRequest(req *models.RentAdjustmentPolicyRequest) (*models.RentAdjustmentPolicy, error) {
+	policy := &models.RentAdjustmentPolicy{
+		Name:          strings.TrimSpace(req.Name),
+		PolicyType:    strings.ToLower(strings.TrimSpace(req.PolicyType)),
+		ThockCategory: strings.ToLower(strings.TrimSpace(req.ThockCategory)),
+		RatePercent:   req.RatePercent,
+		CapPercent:    req.CapPercent,
+		CapAmount:     req.CapAmount,
+		GraceDays:     req.GraceDays,
+		Notes:         req.Notes,
+	}
+
+	if policy.Name == "" {
+		return nil, fmt.Errorf("policy name is required")
+	}
+	if policy.PolicyType != models.RentAdjustmentInterest && policy.PolicyType != models.RentAdjustmentDiscount {
+		return nil, fmt.Errorf("policy_type must be %s or %s", models.RentAdjustmentInterest, models.RentAdjustmentDiscount)
+	}
+	if policy.ThockCategory != "" && policy.ThockCategory != "seed" && policy.ThockCategory != "sell" {
+		return nil, fmt.Errorf("thock_category must be seed, sell or empty")
+	}
+	if policy.RatePercent <= 0 || policy.RatePercent > 100 {
+		return nil, fmt.Errorf("rate_percent must be greater than 0 and at most 100")
+	}
+	if policy.CapPercent != nil && *policy.CapPercent <= 0 {
+		return nil, fmt.Errorf("cap_percent must be positive")
+	}
+	if policy.CapAmount != nil && *policy.CapAmount <= 0 {
+		return nil, fmt.Errorf("cap_amount must be positive")
+	}
+	if policy.GraceDays < 0 {
+		return nil, fmt.Errorf("grace_days cannot be negative")
+	}
+
+	var err error
+	policy.EffectiveFrom, err = time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid effective_from date, use YYYY-MM-DD")
+	}
+	if req.EffectiveTo != "" {
+		effectiveTo, err := time.Parse("2006-01-02", req.EffectiveTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid effective_to date, use YYYY-MM-DD")
+		}
+		if effectiveTo.Before(policy.EffectiveFrom) {
+			return nil, fmt.Errorf("effective_to must be on or after effective_from")
+		}
+		policy.EffectiveTo = &effectiveTo
+	}
+	if policy.PolicyType == models.RentAdjustmentDiscount && policy.EffectiveTo == nil {
+		return nil, fmt.Errorf("discount policies need an effective_to date (the pay-by date)")
+	}
+	return policy, nil
+}
+
+// Preview returns what a run as of asOf would post, without posting it
+func (s *RentAdjustmentService) Preview(ctx context.Context, asOf time.Time) (*models.RentAdjustmentRun, error) {
+	run, err := s.evaluate(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	run.Preview = true
+	return run, nil
+}
+
+// Run posts every interest charge and discount due as of asOf
+func (s *RentAdjustmentService) Run(ctx context.Context, asOf time.Time, trigger string, userID int) (*models.RentAdjustmentRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, err := s.evaluate(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	run.Trigger = trigger
+
+	posted := run.Adjustments[:0]
+	for _, adj := range run.Adjustments {
+		entryType := models.LedgerEntryTypeCharge
+		debit, credit := adj.Amount, 0.0
+		if adj.PolicyType == models.RentAdjustmentDiscount {
+			entryType = models.LedgerEntryTypeCredit
+			debit, credit = 0, adj.Amount
+		}
+
+		policyID := adj.PolicyID
+		entry, err := s.LedgerService.CreateEntry(ctx, &models.CreateLedgerEntryRequest{
+			CustomerPhone:   adj.CustomerPhone,
+			CustomerName:    adj.CustomerName,
+			CustomerSO:      adj.CustomerSO,
+			EntryType:       entryType,
+			Description:     adj.Description,
+			Debit:           debit,
+			Credit:          credit,
+			ReferenceID:     &policyID,
+			ReferenceType:   models.LedgerReferenceRentAdjustment,
+			CreatedByUserID: userID,
+			IdempotencyKey:  adj.IdempotencyKey,
+		})
+		if errors.Is(err, repositories.ErrDuplicateLedgerEntry) {
+			continue // Posted by a concurrent run
+		}
+		if err != nil {
+			run.Adjustments = posted
+			return run, fmt.Errorf("failed to post %s for thock %s: %w", adj.PolicyType, adj.ThockNumber, err)
+		}
+		adj.LedgerEntryID = &entry.ID
+		posted = append(posted, adj)
+	}
+	run.Adjustments = posted
+	run.InterestTotal, run.DiscountTotal = adjustmentTotals(posted)
+
+	if len(posted) > 0 {
+		cache.InvalidatePaymentCaches(ctx)
+	}
+	if err := s.PolicyRepo.RecordRun(ctx, run, userID); err != nil {
+		return run, fmt.Errorf("failed to record run: %w", err)
+	}
+	log.Printf("[RentAdjustment] Run as of %s: %d thocks checked, %d postings (interest %.2f, discount %.2f)",
+		timeutil.FormatIST(asOf, "2006-01-02"), run.ThocksChecked, len(posted), run.InterestTotal, run.DiscountTotal)
+	return run, nil
+}
+
+// evaluate works out the postings due as of asOf
+func (s *RentAdjustmentService) evaluate(ctx context.Context, asOf time.Time) (*models.RentAdjustmentRun, error) {
+	run := &models.RentAdjustmentRun{AsOf: asOf, Adjustments: []models.RentAdjustment{}}
+
+	policies, err := s.PolicyRepo.List(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	var inForce []*models.RentAdjustmentPolicy
+	for _, p := range policies {
+		if !policyStart(p).After(asOf) {
+			inForce = append(inForce, p)
+		}
+	}
+	run.PoliciesChecked = len(inForce)
+	if len(inForce) == 0 {
+		return run, nil
+	}
+
+	entries, err := s.EntryRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entries: %w", err)
+	}
+	interestPosted, err := s.LedgerService.LedgerRepo.GetPostedByKeyPrefix(ctx, "interest:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load posted interest: %w", err)
+	}
+	discountPosted, err := s.LedgerService.LedgerRepo.GetPostedByKeyPrefix(ctx, "discount:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load posted discounts: %w", err)
+	}
+
+	entryByID := make(map[int]*models.Entry, len(entries))
+	var customerIDs []int
+	for _, e := range entries {
+		if e.CustomerID == 0 {
+			continue
+		}
+		if _, seen := entryByID[e.ID]; !seen {
+			entryByID[e.ID] = e
+		}
+		customerIDs = append(customerIDs, e.CustomerID)
+	}
+	sort.Ints(customerIDs)
+
+	for i, customerID := range customerIDs {
+		if i > 0 && customerIDs[i-1] == customerID {
+			continue
+		}
+		outstanding, err := s.AllocationService.GetOutstandingByCustomerID(ctx, customerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load outstanding of customer %d: %w", customerID, err)
+		}
+		history := s.outstandingHistory(ctx, customerID)
+
+		for _, thock := range outstanding.Thocks {
+			entry := entryByID[thock.EntryID]
+			if entry == nil {
+				continue
+			}
+			run.ThocksChecked++
+			scope := fmt.Sprintf("%d-%d", entry.ID, entry.CreatedAt.Unix())
+
+			for _, p := range inForce {
+				if p.ThockCategory != "" && p.ThockCategory != entry.ThockCategory {
+					continue
+				}
+				switch p.PolicyType {
+				case models.RentAdjustmentInterest:
+					due, err := interestDue(p, entry, thock, scope, asOf, interestPosted, history)
+					if err != nil {
+						return nil, fmt.Errorf("failed to work out interest for thock %s: %w", entry.ThockNumber, err)
+					}
+					run.Adjustments = append(run.Adjustments, due...)
+				case models.RentAdjustmentDiscount:
+					if adj := discountDue(p, entry, thock, scope, asOf, discountPosted); adj != nil {
+						run.Adjustments = append(run.Adjustments, *adj)
+					}
+				}
+			}
+		}
+	}
+
+	run.InterestTotal, run.DiscountTotal = adjustmentTotals(run.Adjustments)
+	return run, nil
+}
+
+// outstandingHistory returns a lookup of a customer's thock outstanding at the start
+// of a month. Each month is loaded once per run.
+func (s *RentAdjustmentService) outstandingHistory(ctx context.Context, customerID int) func(entryID int, at time.Time) (float64, error) {
+	loaded := make(map[time.Time]*models.CustomerOutstanding)
+	return func(entryID int, at time.Time) (float64, error) {
+		outstanding, ok := loaded[at]
+		if !ok {
+			var err error
+			if outstanding, err = s.AllocationService.GetOutstandingAt(ctx, customerID, at); err != nil {
+				return 0, err
+			}
+			loaded[at] = outstanding
+		}
+		for _, t := range outstanding.Thocks {
+			if t.EntryID == entryID {
+				return t.Outstanding, nil
+			}
+		}
+		return 0, nil
+	}
+}
+
+// interestDue returns the monthly interest charges of a thock not posted yet. Each
+// month is charged on the outstanding the thock had when the month started.
+func interestDue(p *models.RentAdjustmentPolicy, entry *models.Entry, thock models.ThockOutstanding, scope string, asOf time.Time, posted map[string]float64, outstandingAt func(entryID int, at time.Time) (float64, error)) ([]models.RentAdjustment, error) {
+	if thock.Charged < 0.01 {
+		return nil, nil // No rent billed yet
+	}
+
+	end := asOf
+	if p.EffectiveTo != nil && policyEnd(p).Before(end) {
+		end = policyEnd(p)
+	}
+	limit := policyCap(p, thock.Charged)
+
+	// Interest already charged to this thock under this policy counts towards the cap
+	prefix := fmt.Sprintf("interest:%d:%s:", p.ID, scope)
+	var charged float64
+	for key, amount := range posted {
+		if strings.HasPrefix(key, prefix) {
+			charged += amount
+		}
+	}
+
+	// A thock stored after the policy starts only falls due from its own storage date
+	dueOn := policyStart(p)
+	if stored := timeutil.StartOfDay(entry.CreatedAt); stored.After(dueOn) {
+		dueOn = stored
+	}
+	dueOn = dueOn.AddDate(0, 0, p.GraceDays)
+
+	var due []models.RentAdjustment
+	overdueFrom := dueOn.AddDate(0, 0, 1)
+	for month := 0; ; month++ {
+		monthStart := overdueFrom.AddDate(0, month, 0)
+		if monthStart.After(end) {
+			break
+		}
+		period := timeutil.FormatIST(monthStart, "2006-01-02")
+		if _, done := posted[prefix+period]; done {
+			continue
+		}
+		if limit-charged < 0.01 {
+			break // Cap reached
+		}
+
+		base, err := outstandingAt(entry.ID, monthStart)
+		if err != nil {
+			return nil, err
+		}
+		amount := roundRupees(min(base*p.RatePercent/100, limit-charged))
+		if amount < 0.01 {
+			continue // Nothing outstanding when this month started
+		}
+		charged += amount
+		due = append(due, models.RentAdjustment{
+			PolicyID:      p.ID,
+			PolicyName:    p.Name,
+			PolicyType:    p.PolicyType,
+			CustomerID:    entry.CustomerID,
+			CustomerPhone: entry.Phone,
+			CustomerName:  entry.Name,
+			CustomerSO:    entry.SO,
+			EntryID:       entry.ID,
+			ThockNumber:   entry.ThockNumber,
+			Period:        period,
+			Base:          base,
+			Amount:        amount,
+			Description: fmt.Sprintf("Late payment interest %.2f%% on thock %s (outstanding %.2f, month from %s) - %s",
+				p.RatePercent, entry.ThockNumber, base, period, p.Name),
+			IdempotencyKey: prefix + period,
+		})
+	}
+	return due, nil
+}
+
+// discountDue returns the discount of a thock paid inside the policy window, or nil
+func discountDue(p *models.RentAdjustmentPolicy, entry *models.Entry, thock models.ThockOutstanding, scope string, asOf time.Time, posted map[string]float64) *models.RentAdjustment {
+	if thock.Charged < 0.01 || thock.Outstanding >= 0.01 || asOf.After(policyEnd(p)) {
+		return nil
+	}
+	key := fmt.Sprintf("discount:%d:%s", p.ID, scope)
+	if _, done := posted[key]; done {
+		return nil
+	}
+
+	amount := roundRupees(min(thock.Charged*p.RatePercent/100, policyCap(p, thock.Charged)))
+	if amount < 0.01 {
+		return nil
+	}
+	return &models.RentAdjustment{
+		PolicyID:      p.ID,
+		PolicyName:    p.Name,
+		PolicyType:    p.PolicyType,
+		CustomerID:    entry.CustomerID,
+		CustomerPhone: entry.Phone,
+		CustomerName:  entry.Name,
+		CustomerSO:    entry.SO,
+		EntryID:       entry.ID,
+		ThockNumber:   entry.ThockNumber,
+		Base:          thock.Charged,
+		Amount:        amount,
+		Description: fmt.Sprintf("Early payment discount %.2f%% on thock %s (rent %.2f) - %s",
+			p.RatePercent, entry.ThockNumber, thock.Charged, p.Name),
+		IdempotencyKey: key,
+	}
+}
+
+// policyCap returns the most a policy may post for a thock with this rent
+func policyCap(p *models.RentAdjustmentPolicy, rent float64) float64 {
+	limit := math.Inf(1)
+	if p.CapPercent != nil {
+		limit = rent * *p.CapPercent / 100
+	}
+	if p.CapAmount != nil {
+		limit = min(limit, *p.CapAmount)
+	}
+	return limit
+}
+
+// policyStart returns 00:00 IST on the policy's effective_from date
+func policyStart(p *models.RentAdjustmentPolicy) time.Time {
+	return time.Date(p.EffectiveFrom.Year(), p.EffectiveFrom.Month(), p.EffectiveFrom.Day(), 0, 0, 0, 0, timeutil.IST)
+}
+
+// policyEnd returns the last instant of the policy's effective_to date (far future when open ended)
+func policyEnd(p *models.RentAdjustmentPolicy) time.Time {
+	if p.EffectiveTo == nil {
+		return time.Date(9999, 12, 31, 0, 0, 0, 0, timeutil.IST)
+	}
+	to := *p.EffectiveTo
+	return time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, timeutil.IST).AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+func adjustmentTotals(adjustments []models.RentAdjustment) (interest, discount float64) {
+	for _, adj := range adjustments {
+		if adj.PolicyType == models.RentAdjustmentDiscount {
+			discount += adj.Amount
+		} else {
+			interest += adj.Amount
+		}
+	}
+	return roundRupees(interest), roundRupees(discount)
+}
+
+// Start evaluates the policies once a day (IST) in the background
+func (s *RentAdjustmentService) Start() {
+	go func() {
+		log.Println("[RentAdjustment] Scheduler started (daily)")
+		s.runScheduled()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				log.Println("[RentAdjustment] Scheduler stopped")
+				return
+			case <-ticker.C:
+				s.runScheduled()
+			}
+		}
+	}()
+}
+
+// Stop stops the background scheduler
+func (s *RentAdjustmentService) Stop() {
+	close(s.stopCh)
+}
+
+// runScheduled runs the daily evaluation unless it is disabled or already done today
+func (s *RentAdjustmentService) runScheduled() {
+	ctx := context.Background()
+
+	if setting, err := s.SettingsRepo.Get(ctx, "rent_adjustments_enabled"); err == nil && setting != nil && setting.SettingValue != "true" {
+		return
+	}
+
+	now := timeutil.Now()
+	last, err := s.PolicyRepo.GetLastRunAt(ctx, models.RentAdjustmentTriggerScheduled)
+	if err != nil {
+		log.Printf("[RentAdjustment] Failed to check last run: %v", err)
+		return
+	}
+	if last != nil && timeutil.StartOfDay(*last).Equal(timeutil.StartOfDay(now)) {
+		return
+	}
+
+	if _, err := s.Run(ctx, now, models.RentAdjustmentTriggerScheduled, 0); err != nil {
+		log.Printf("[RentAdjustment] Scheduled run failed: %v", err)
+	}
+}
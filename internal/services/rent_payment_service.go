@@ -18,6 +18,11 @@ func (s *RentPaymentService) CreatePayment(ctx context.Context, payment *models.
 	return s.Repo.Create(ctx, payment)
 }
 
+// CreatePaymentWithAllocations records a payment together with its split across thocks
+func (s *RentPaymentService) CreatePaymentWithAllocations(ctx context.Context, payment *models.RentPayment, allocations []models.PaymentAllocation) error {
+	return s.Repo.CreateWithAllocations(ctx, payment, allocations)
+}
+
 func (s *RentPaymentService) GetPaymentsByEntryID(ctx context.Context, entryID int) ([]*models.RentPayment, error) {
 	return s.Repo.GetByEntryID(ctx, entryID)
 }
@@ -33,3 +38,7 @@ func (s *RentPaymentService) ListPayments(ctx context.Context) ([]*models.RentPa
 func (s *RentPaymentService) GetPaymentByReceiptNumber(ctx context.Context, receiptNumber string) (*models.RentPayment, error) {
 	return s.Repo.GetByReceiptNumber(ctx, receiptNumber)
 }
+
+func (s *RentPaymentService) GetPaymentByID(ctx context.Context, id int) (*models.RentPayment, error) {
+	return s.Repo.GetByID(ctx, id)
+}
@@ -6,7 +6,6 @@ import (
 	"context"
 	"encoding/csv"
 	"fmt"
-	"strconv"
 	"sync"
 	"time"
 
@@ -29,6 +28,7 @@ type CustomerReportData struct {
 	TotalPaid   float64
 	Balance     float64
 	ThockCount  int
+	Weights     []*models.ThockWeight
 }
 
 // DailySummaryData holds data for daily summary report
@@ -51,6 +51,8 @@ type ReportService struct {
 	RoomEntryRepo   *repositories.RoomEntryRepository
 	RentPaymentRepo *repositories.RentPaymentRepository
 	SettingsRepo    *repositories.SystemSettingRepository
+	TariffService   *TariffService
+	WeighmentRepo   *repositories.WeighmentRepository
 }
 
 // NewReportService creates a new report service
@@ -61,6 +63,7 @@ func NewReportService(
 	roomEntryRepo *repositories.RoomEntryRepository,
 	rentPaymentRepo *repositories.RentPaymentRepository,
 	settingsRepo *repositories.SystemSettingRepository,
+	tariffService *TariffService,
 ) *ReportService {
 	return &ReportService{
 		DB:              db,
@@ -69,24 +72,22 @@ func NewReportService(
 		RoomEntryRepo:   roomEntryRepo,
 		RentPaymentRepo: rentPaymentRepo,
 		SettingsRepo:    settingsRepo,
+		TariffService:   tariffService,
 	}
 }
 
-// GetRentRate fetches the current rent rate from settings
-func (s *ReportService) GetRentRate(ctx context.Context) (float64, error) {
-	setting, err := s.SettingsRepo.Get(ctx, "rent_rate_per_bag")
-	if err != nil {
-		return 2.50, nil // Default rate
-	}
-	rate, err := strconv.ParseFloat(setting.SettingValue, 64)
-	if err != nil {
-		return 2.50, nil
-	}
-	return rate, nil
+// SetWeighmentRepo enables the weighbridge weights section of customer reports
+func (s *ReportService) SetWeighmentRepo(repo *repositories.WeighmentRepository) {
+	s.WeighmentRepo = repo
+}
+
+// GetRateCard loads the rent rate cards used to price every thock in a report
+func (s *ReportService) GetRateCard(ctx context.Context) (*RateCard, error) {
+	return s.TariffService.LoadRateCard(ctx)
 }
 
 // GetCustomerReportData fetches all data for a customer
-func (s *ReportService) GetCustomerReportData(ctx context.Context, phone string, rentRate float64) (*CustomerReportData, error) {
+func (s *ReportService) GetCustomerReportData(ctx context.Context, phone string, rateCard *RateCard) (*CustomerReportData, error) {
 	customer, err := s.CustomerRepo.GetByPhone(ctx, phone)
 	if err != nil {
 		return nil, fmt.Errorf("customer not found: %w", err)
@@ -102,17 +103,21 @@ func (s *ReportService) GetCustomerReportData(ctx context.Context, phone string,
 	var roomEntries []*models.RoomEntry
 	var payments []*models.RentPayment
 	var totalQty int
-	var totalPaid float64
+	var totalRent, totalPaid float64
 	thockSet := make(map[string]bool)
+	now := timeutil.Now()
 
 	for _, entry := range entries {
 		// Get room entries for this entry
 		reList, err := s.RoomEntryRepo.ListByThockNumber(ctx, entry.ThockNumber)
 		if err == nil {
 			roomEntries = append(roomEntries, reList...)
+			var entryQty int
 			for _, re := range reList {
-				totalQty += re.Quantity
+				entryQty += re.Quantity
 			}
+			totalQty += entryQty
+			totalRent += rateCard.EntryRent(entry, entryQty, now)
 		}
 		thockSet[entry.ThockNumber] = true
 
@@ -126,9 +131,20 @@ func (s *ReportService) GetCustomerReportData(ctx context.Context, phone string,
 		}
 	}
 
-	totalRent := float64(totalQty) * rentRate
 	balance := totalRent - totalPaid
 
+	var weights []*models.ThockWeight
+	if s.WeighmentRepo != nil && len(thockSet) > 0 {
+		thocks := make([]string, 0, len(thockSet))
+		for thock := range thockSet {
+			thocks = append(thocks, thock)
+		}
+		weights, err = s.WeighmentRepo.ThockWeights(ctx, thocks)
+		if err != nil {
+			weights = nil
+		}
+	}
+
 	return &CustomerReportData{
 		Customer:    customer,
 		Entries:     entries,
@@ -139,12 +155,16 @@ func (s *ReportService) GetCustomerReportData(ctx context.Context, phone string,
 		TotalPaid:   totalPaid,
 		Balance:     balance,
 		ThockCount:  len(thockSet),
+		Weights:     weights,
 	}, nil
 }
 
 // GetAllCustomerReportData fetches data for all customers
 func (s *ReportService) GetAllCustomerReportData(ctx context.Context, filter string) ([]*CustomerReportData, error) {
-	rentRate, _ := s.GetRentRate(ctx)
+	rateCard, err := s.GetRateCard(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	customers, err := s.CustomerRepo.List(ctx)
 	if err != nil {
@@ -172,7 +192,7 @@ func (s *ReportService) GetAllCustomerReportData(ctx context.Context, filter str
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				data, err := s.GetCustomerReportData(ctx, job.customer.Phone, rentRate)
+				data, err := s.GetCustomerReportData(ctx, job.customer.Phone, rateCard)
 				results <- result{index: job.index, data: data, err: err}
 			}
 		}()
@@ -283,6 +303,50 @@ func (s *ReportService) GenerateCustomerPDF(data *CustomerReportData) ([]byte, e
 	}
 	pdf.Ln(5)
 
+	// Weighbridge weights of weighed thocks
+	var weighed []*models.ThockWeight
+	for _, w := range data.Weights {
+		if w.NetWeightInKg != nil || w.OutboundWeighings > 0 {
+			weighed = append(weighed, w)
+		}
+	}
+	if len(weighed) > 0 {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.SetFillColor(240, 240, 240)
+		pdf.CellFormat(190, 8, "Weights", "1", 1, "L", true, 0, "")
+
+		pdf.SetFont("Arial", "B", 10)
+		pdf.SetFillColor(200, 200, 200)
+		pdf.CellFormat(40, 7, "Thock No", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(25, 7, "Bags In", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(35, 7, "Net In (kg)", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, 7, "Avg kg/Bag", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(25, 7, "Bags Out", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(35, 7, "Net Out (kg)", "1", 1, "C", true, 0, "")
+
+		pdf.SetFont("Arial", "", 10)
+		for _, w := range weighed {
+			netIn, avg, bagsOut, netOut := "-", "-", "-", "-"
+			if w.NetWeightInKg != nil {
+				netIn = fmt.Sprintf("%.2f", *w.NetWeightInKg)
+			}
+			if w.AvgBagWeightKg != nil {
+				avg = fmt.Sprintf("%.2f", *w.AvgBagWeightKg)
+			}
+			if w.OutboundWeighings > 0 {
+				bagsOut = fmt.Sprintf("%d", w.BagsOut)
+				netOut = fmt.Sprintf("%.2f", w.NetWeightOutKg)
+			}
+			pdf.CellFormat(40, 6, w.ThockNumber, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(25, 6, fmt.Sprintf("%d", w.BagsIn), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(35, 6, netIn, "1", 0, "R", false, 0, "")
+			pdf.CellFormat(30, 6, avg, "1", 0, "R", false, 0, "")
+			pdf.CellFormat(25, 6, bagsOut, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(35, 6, netOut, "1", 1, "R", false, 0, "")
+		}
+		pdf.Ln(5)
+	}
+
 	// Financial Summary
 	pdf.SetFont("Arial", "B", 12)
 	pdf.CellFormat(190, 8, "Financial Summary", "1", 1, "L", true, 0, "")
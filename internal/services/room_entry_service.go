@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
 	"strconv"
 
 	"cold-backend/internal/models"
@@ -16,6 +17,8 @@ type RoomEntryService struct {
 	EntryEventRepo     *repositories.EntryEventRepository
 	PrinterService     *PrinterService
 	MediaRepo          *repositories.RoomEntryMediaRepository
+	Layout             *WarehouseLayoutService
+	StockLedger        *GatarStockService
 }
 
 func NewRoomEntryService(roomEntryRepo *repositories.RoomEntryRepository, roomEntryGatarRepo *repositories.RoomEntryGatarRepository, entryRepo *repositories.EntryRepository, entryEventRepo *repositories.EntryEventRepository, printerService *PrinterService, mediaRepo *repositories.RoomEntryMediaRepository) *RoomEntryService {
@@ -29,6 +32,16 @@ func NewRoomEntryService(roomEntryRepo *repositories.RoomEntryRepository, roomEn
 	}
 }
 
+// SetLayoutService enables validation of room, floor and gatars against the warehouse layout
+func (s *RoomEntryService) SetLayoutService(layout *WarehouseLayoutService) {
+	s.Layout = layout
+}
+
+// SetStockLedger enables posting room entries and edits to the per-gatar stock ledger
+func (s *RoomEntryService) SetStockLedger(ledger *GatarStockService) {
+	s.StockLedger = ledger
+}
+
 func (s *RoomEntryService) GetMediaByRoomEntryID(ctx context.Context, roomEntryID int) (map[string][]models.RoomEntryMedia, error) {
 	allMedia, err := s.MediaRepo.ListByRoomEntryID(ctx, roomEntryID)
 	if err != nil {
@@ -37,17 +50,22 @@ func (s *RoomEntryService) GetMediaByRoomEntryID(ctx context.Context, roomEntryI
 
 	entryMedia := []models.RoomEntryMedia{}
 	editMedia := []models.RoomEntryMedia{}
+	inspectionMedia := []models.RoomEntryMedia{}
 	for _, m := range allMedia {
-		if m.MediaType == "edit" {
+		switch m.MediaType {
+		case "edit":
 			editMedia = append(editMedia, m)
-		} else {
+		case "inspection":
+			inspectionMedia = append(inspectionMedia, m)
+		default:
 			entryMedia = append(entryMedia, m)
 		}
 	}
 
 	return map[string][]models.RoomEntryMedia{
-		"entry_media": entryMedia,
-		"edit_media":  editMedia,
+		"entry_media":      entryMedia,
+		"edit_media":       editMedia,
+		"inspection_media": inspectionMedia,
 	}, nil
 }
 
@@ -76,6 +94,11 @@ func (s *RoomEntryService) CreateRoomEntry(ctx context.Context, req *models.Crea
 	if req.Quantity < 1 {
 		return nil, errors.New("quantity must be at least 1")
 	}
+	if s.Layout != nil {
+		if err := s.Layout.ValidatePlacement(ctx, req.RoomNo, req.Floor, req.GateNo, req.Gatars, ""); err != nil {
+			return nil, err
+		}
+	}
 
 	// Check if entry exists
 	entry, err := s.EntryRepo.Get(ctx, req.EntryID)
@@ -118,17 +141,22 @@ func (s *RoomEntryService) CreateRoomEntry(ctx context.Context, req *models.Crea
 		CreatedByUserID:   userID,
 	}
 
-	if err := s.RoomEntryRepo.Create(ctx, roomEntry); err != nil {
-		return nil, err
-	}
-
-	// Save per-gatar quantities if provided
-	if len(req.Gatars) > 0 {
-		if err := s.RoomEntryGatarRepo.CreateBatch(ctx, roomEntry.ID, req.Gatars); err != nil {
-			// Log error but don't fail the whole operation
-			// The main room entry is already created
+	// Save the room entry, its per-gatar quantities and stock ledger rows together; the
+	// capacity check runs in the same transaction with the gatars locked
+	var movements []*models.GatarStockMovement
+	if s.StockLedger != nil {
+		movements, err = s.StockLedger.RoomEntryMovements(ctx, roomEntry, req.Gatars)
+		if err != nil {
+			return nil, err
 		}
 	}
+	var check repositories.GatarSpaceCheck
+	if s.Layout != nil {
+		check = s.Layout.spaceCheck(ctx, &roomEntry.CapacityWarnings)
+	}
+	if err := s.RoomEntryRepo.CreateWithStock(ctx, roomEntry, req.Gatars, movements, check); err != nil {
+		return nil, err
+	}
 
 	// Create event to track room entry completion
 	event := &models.EntryEvent{
@@ -142,13 +170,16 @@ func (s *RoomEntryService) CreateRoomEntry(ctx context.Context, req *models.Crea
 	// Create event (don't fail if this fails)
 	s.EntryEventRepo.Create(ctx, event)
 
-	// Print label with thock number and customer name (if label count > 0)
+	// Print label with thock number and customer name at the entry's counter (if label count > 0)
 	if s.PrinterService != nil && req.LabelCount > 0 {
 		labelCount := req.LabelCount
 		thockNumber := req.ThockNumber
 		customerName := entry.Name
+		counter := req.Counter
 		go func() {
-			_ = s.PrinterService.Print2Up(thockNumber, customerName, labelCount)
+			if _, err := s.PrinterService.PrintThockLabels(context.Background(), counter, 0, thockNumber, customerName, labelCount, userID); err != nil {
+				log.Printf("[RoomEntry] Failed to print labels for thock %s: %v", thockNumber, err)
+			}
 		}()
 	}
 
@@ -211,6 +242,16 @@ func (s *RoomEntryService) UpdateRoomEntry(ctx context.Context, id int, req *mod
 	if req.Quantity < 1 {
 		return nil, errors.New("quantity must be at least 1")
 	}
+	if s.Layout != nil {
+		// Gatars this entry already occupies stay valid if they have been disabled since
+		previousGateNo := ""
+		if roomEntry.RoomNo == req.RoomNo && roomEntry.Floor == req.Floor {
+			previousGateNo = roomEntry.GateNo
+		}
+		if err := s.Layout.ValidatePlacement(ctx, req.RoomNo, req.Floor, req.GateNo, req.Gatars, previousGateNo); err != nil {
+			return nil, err
+		}
+	}
 
 	// Update fields
 	roomEntry.RoomNo = req.RoomNo
@@ -220,17 +261,21 @@ func (s *RoomEntryService) UpdateRoomEntry(ctx context.Context, id int, req *mod
 	roomEntry.Quantity = req.Quantity
 	roomEntry.QuantityBreakdown = req.QuantityBreakdown
 
-	// Update in database
-	if err := s.RoomEntryRepo.Update(ctx, id, roomEntry); err != nil {
-		return nil, err
-	}
-
-	// Update per-gatar quantities if provided
-	if len(req.Gatars) > 0 {
-		if err := s.RoomEntryGatarRepo.UpdateBatch(ctx, id, req.Gatars); err != nil {
-			// Log error but don't fail the whole operation
+	// Update in database, with per-gatar quantities (if provided) and the stock ledger
+	var plan func([]*models.GatarBalance) []*models.GatarStockMovement
+	if s.StockLedger != nil {
+		plan, err = s.StockLedger.RoomEntryEditPlan(ctx, roomEntry, req.Gatars)
+		if err != nil {
+			return nil, err
 		}
 	}
+	var check repositories.GatarSpaceCheck
+	if s.Layout != nil {
+		check = s.Layout.spaceCheck(ctx, &roomEntry.CapacityWarnings)
+	}
+	if err := s.RoomEntryRepo.UpdateWithStock(ctx, id, roomEntry, req.Gatars, plan, check); err != nil {
+		return nil, err
+	}
 
 	return roomEntry, nil
 }
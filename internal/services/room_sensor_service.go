@@ -0,0 +1,423 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/monitoring"
+	"cold-backend/internal/repositories"
+)
+
+// ErrInvalidSensorReading is returned for sensor readings that cannot be stored
+var ErrInvalidSensorReading = errors.New("invalid sensor reading")
+
+const (
+	sensorAlertType     = "room_sensor"
+	maxSensorBatch      = 500
+	sensorThresholdsTTL = time.Minute
+)
+
+// Sensor metrics in alert_thresholds; the defaults apply until the TimescaleDB migration seeds them
+var defaultSensorThresholds = []models.AlertThreshold{
+	{MetricName: "room_temperature_high", DisplayName: "Room Temperature High (°C)", WarningThreshold: 4, CriticalThreshold: 6, Comparison: "gt", Enabled: true, CooldownMinutes: 30},
+	{MetricName: "room_temperature_low", DisplayName: "Room Temperature Low (°C)", WarningThreshold: 1, CriticalThreshold: 0, Comparison: "lt", Enabled: true, CooldownMinutes: 30},
+	{MetricName: "room_humidity_high", DisplayName: "Room Humidity High (%)", WarningThreshold: 95, CriticalThreshold: 98, Comparison: "gt", Enabled: true, CooldownMinutes: 60},
+	{MetricName: "room_humidity_low", DisplayName: "Room Humidity Low (%)", WarningThreshold: 85, CriticalThreshold: 80, Comparison: "lt", Enabled: true, CooldownMinutes: 60},
+}
+
+// SensorStatus is a sensor's latest reading with its alert state
+type SensorStatus struct {
+	monitoring.SensorReading
+	Status string `json:"status"` // ok, warning, critical
+	Stale  bool   `json:"stale"`  // nothing heard for 15 minutes
+}
+
+// RoomSensorService stores cold-room temperature/humidity readings and raises alerts when a
+// room drifts out of its band. Readings arrive over HTTP or MQTT and live in TimescaleDB.
+type RoomSensorService struct {
+	Store       *monitoring.TimescaleStore
+	Layout      *WarehouseLayoutService
+	SettingRepo *repositories.SystemSettingRepository
+	MetricsRepo *repositories.MetricsRepository
+	Notifier    *NotificationService
+
+	mu             sync.Mutex
+	thresholds     []models.AlertThreshold
+	thresholdsRead time.Time
+}
+
+func NewRoomSensorService(store *monitoring.TimescaleStore, layout *WarehouseLayoutService, settingRepo *repositories.SystemSettingRepository) *RoomSensorService {
+	return &RoomSensorService{Store: store, Layout: layout, SettingRepo: settingRepo}
+}
+
+// SetMetricsRepo enables threshold alerts (monitoring_alerts/alert_thresholds in TimescaleDB)
+func (s *RoomSensorService) SetMetricsRepo(repo *repositories.MetricsRepository) {
+	s.MetricsRepo = repo
+}
+
+// SetNotificationService enables SMS paging for critical sensor alerts
+func (s *RoomSensorService) SetNotificationService(notifier *NotificationService) {
+	s.Notifier = notifier
+}
+
+// ValidIngestToken checks a sensor's token against the sensor_ingest_token setting
+func (s *RoomSensorService) ValidIngestToken(ctx context.Context, token string) bool {
+	if token == "" || s.SettingRepo == nil {
+		return false
+	}
+	setting, err := s.SettingRepo.Get(ctx, "sensor_ingest_token")
+	if err != nil || setting == nil || setting.SettingValue == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(setting.SettingValue)) == 1
+}
+
+// Ingest validates and stores a batch of readings, then checks the newest reading of each
+// sensor against the alert thresholds
+func (s *RoomSensorService) Ingest(ctx context.Context, readings []monitoring.SensorReading) (int, error) {
+	if len(readings) == 0 {
+		return 0, fmt.Errorf("%w: no readings", ErrInvalidSensorReading)
+	}
+	if len(readings) > maxSensorBatch {
+		return 0, fmt.Errorf("%w: at most %d readings per request", ErrInvalidSensorReading, maxSensorBatch)
+	}
+
+	now := time.Now()
+	known := make(map[string]bool)
+	newest := make(map[string]monitoring.SensorReading)
+	for i := range readings {
+		r := &readings[i]
+		r.SensorID = strings.TrimSpace(r.SensorID)
+		r.RoomNo = strings.TrimSpace(r.RoomNo)
+		r.Floor = strings.TrimSpace(r.Floor)
+		if err := validateSensorReading(r, now); err != nil {
+			return 0, err
+		}
+		if r.SensorID == "" {
+			r.SensorID = "room-" + r.RoomNo
+			if r.Floor != "" {
+				r.SensorID += "-floor-" + r.Floor
+			}
+		}
+
+		location := r.RoomNo + "/" + r.Floor
+		if !known[location] {
+			if err := s.checkLocation(ctx, r.RoomNo, r.Floor); err != nil {
+				return 0, err
+			}
+			known[location] = true
+		}
+		key := r.SensorID + "|" + location
+		if prev, ok := newest[key]; !ok || r.Time.After(prev.Time) {
+			newest[key] = *r
+		}
+	}
+
+	if err := s.Store.RecordSensorReadings(ctx, readings); err != nil {
+		return 0, err
+	}
+	for _, r := range newest {
+		s.checkThresholds(ctx, r)
+	}
+	return len(readings), nil
+}
+
+// HandleMQTTMessage ingests a message from topic .../{room}/{floor}/{sensor}. The payload is a
+// reading or an array of readings; fields missing from the payload are taken from the topic.
+func (s *RoomSensorService) HandleMQTTMessage(topic string, payload []byte) {
+	var readings []monitoring.SensorReading
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(payload, &readings); err != nil {
+			log.Printf("[Sensors] Ignoring MQTT message on %s: %v", topic, err)
+			return
+		}
+	} else {
+		var r monitoring.SensorReading
+		if err := json.Unmarshal(payload, &r); err != nil {
+			log.Printf("[Sensors] Ignoring MQTT message on %s: %v", topic, err)
+			return
+		}
+		readings = append(readings, r)
+	}
+
+	parts := strings.Split(topic, "/")
+	for i := range readings {
+		r := &readings[i]
+		if n := len(parts); n >= 3 {
+			if r.RoomNo == "" {
+				r.RoomNo = parts[n-3]
+			}
+			if r.Floor == "" {
+				r.Floor = parts[n-2]
+			}
+			if r.SensorID == "" {
+				r.SensorID = parts[n-1]
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if _, err := s.Ingest(ctx, readings); err != nil {
+		log.Printf("[Sensors] MQTT message on %s not stored: %v", topic, err)
+	}
+}
+
+// StartMQTT subscribes to sensor readings on an MQTT broker until ctx ends
+func (s *RoomSensorService) StartMQTT(ctx context.Context, cfg monitoring.MQTTConfig) {
+	go monitoring.NewMQTTSubscriber(cfg, s.HandleMQTTMessage).Run(ctx)
+}
+
+// Latest returns the last reading of every sensor heard from in the past day
+func (s *RoomSensorService) Latest(ctx context.Context) ([]SensorStatus, error) {
+	readings, err := s.Store.GetLatestSensorReadings(ctx, 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	thresholds := s.sensorThresholds(ctx)
+	statuses := make([]SensorStatus, 0, len(readings))
+	for _, r := range readings {
+		status := SensorStatus{SensorReading: r, Status: "ok", Stale: time.Since(r.Time) > 15*time.Minute}
+		for _, t := range thresholds {
+			if value, ok := sensorValue(r, t.MetricName); ok {
+				status.Status = worseSeverity(status.Status, breachSeverity(t, value))
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Trend returns a room's (or floor's) readings over the past hours, bucketed for charting
+func (s *RoomSensorService) Trend(ctx context.Context, roomNo, floor string, hours int) ([]monitoring.SensorTrendPoint, error) {
+	if roomNo == "" {
+		return nil, fmt.Errorf("%w: room is required", ErrInvalidSensorReading)
+	}
+	hours = max(1, min(hours, 24*90))
+	bucket := 5 * time.Minute
+	switch {
+	case hours > 24*7:
+		bucket = 3 * time.Hour
+	case hours > 24:
+		bucket = 30 * time.Minute
+	}
+	return s.Store.GetSensorTrend(ctx, roomNo, floor, time.Duration(hours)*time.Hour, bucket)
+}
+
+// Thresholds returns the sensor alert thresholds in effect
+func (s *RoomSensorService) Thresholds(ctx context.Context) []models.AlertThreshold {
+	return s.sensorThresholds(ctx)
+}
+
+func validateSensorReading(r *monitoring.SensorReading, now time.Time) error {
+	if r.RoomNo == "" {
+		return fmt.Errorf("%w: room_no is required", ErrInvalidSensorReading)
+	}
+	if r.TemperatureC == nil && r.HumidityPct == nil {
+		return fmt.Errorf("%w: sensor %s sent neither temperature nor humidity", ErrInvalidSensorReading, r.SensorID)
+	}
+	if r.TemperatureC != nil && (*r.TemperatureC < -40 || *r.TemperatureC > 60) {
+		return fmt.Errorf("%w: temperature %.1f°C is out of range", ErrInvalidSensorReading, *r.TemperatureC)
+	}
+	if r.HumidityPct != nil && (*r.HumidityPct < 0 || *r.HumidityPct > 100) {
+		return fmt.Errorf("%w: humidity %.1f%% is out of range", ErrInvalidSensorReading, *r.HumidityPct)
+	}
+	switch {
+	case r.Time.IsZero():
+		r.Time = now
+	case r.Time.After(now.Add(5 * time.Minute)):
+		return fmt.Errorf("%w: reading time %s is in the future", ErrInvalidSensorReading, r.Time.Format(time.RFC3339))
+	case r.Time.Before(now.Add(-7 * 24 * time.Hour)):
+		return fmt.Errorf("%w: reading time %s is more than 7 days old", ErrInvalidSensorReading, r.Time.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkLocation makes sure the room (and floor, if given) is in the warehouse layout
+func (s *RoomSensorService) checkLocation(ctx context.Context, roomNo, floor string) error {
+	if s.Layout == nil {
+		return nil
+	}
+	var err error
+	if floor == "" {
+		_, err = s.Layout.Repo.GetRoom(ctx, roomNo)
+	} else {
+		_, err = s.Layout.Repo.GetFloor(ctx, roomNo, floor)
+	}
+	if errors.Is(err, repositories.ErrLayoutNotFound) {
+		if floor == "" {
+			return fmt.Errorf("%w: room %s is not in the warehouse layout", ErrInvalidSensorReading, roomNo)
+		}
+		return fmt.Errorf("%w: room %s has no floor %s", ErrInvalidSensorReading, roomNo, floor)
+	}
+	return err
+}
+
+// checkThresholds raises, escalates or resolves the alerts of one reading. Alerts for the same
+// sensor and metric repeat only after the threshold's cooldown unless the severity rises.
+// Alerts that pagesOn selects are paged by SMS.
+func (s *RoomSensorService) checkThresholds(ctx context.Context, r monitoring.SensorReading) {
+	if s.MetricsRepo == nil {
+		return
+	}
+	source := sensorSource(r)
+	for _, t := range s.sensorThresholds(ctx) {
+		value, ok := sensorValue(r, t.MetricName)
+		if !ok || !t.Enabled {
+			continue
+		}
+		severity := breachSeverity(t, value)
+		open, err := s.MetricsRepo.GetLastOpenAlert(ctx, sensorAlertType, source, t.MetricName)
+		if err != nil {
+			log.Printf("[Sensors] Could not read open alerts for %s: %v", source, err)
+			return
+		}
+
+		if severity == "ok" {
+			if open != nil {
+				if _, err := s.MetricsRepo.ResolveAlertsFor(ctx, sensorAlertType, source, t.MetricName); err != nil {
+					log.Printf("[Sensors] Could not resolve %s alerts for %s: %v", t.MetricName, source, err)
+				}
+			}
+			continue
+		}
+		cooldown := time.Duration(t.CooldownMinutes) * time.Minute
+		if open != nil && worseSeverity(open.Severity, severity) == open.Severity && time.Since(open.Time) < cooldown {
+			continue
+		}
+
+		limit := t.WarningThreshold
+		if severity == "critical" {
+			limit = t.CriticalThreshold
+		}
+		metricName, metricValue, thresholdValue := t.MetricName, value, limit
+		alert := &models.MonitoringAlert{
+			AlertType:      sensorAlertType,
+			Severity:       severity,
+			Source:         source,
+			Title:          fmt.Sprintf("%s: %s", sensorLocation(r), t.DisplayName),
+			Message:        sensorAlertMessage(r, t, value, limit),
+			MetricName:     &metricName,
+			MetricValue:    &metricValue,
+			ThresholdValue: &thresholdValue,
+		}
+		if err := s.MetricsRepo.InsertAlert(ctx, alert); err != nil {
+			log.Printf("[Sensors] Could not record alert for %s: %v", source, err)
+			continue
+		}
+		log.Printf("[Sensors] %s alert: %s", severity, alert.Message)
+
+		if pagesOn(t, severity) && s.Notifier != nil {
+			message := fmt.Sprintf("COLD STORE ALERT: %s at %s", alert.Message, r.Time.Local().Format("02 Jan 15:04"))
+			if err := s.Notifier.NotifySensorAlert(ctx, message); err != nil {
+				log.Printf("[Sensors] Could not page sensor alert: %v", err)
+			}
+		}
+	}
+}
+
+// pagesOn reports whether an alert of this severity pages someone. A room warmer than the
+// storage band pages from the warning threshold (4°C by default); other metrics page when critical.
+func pagesOn(t models.AlertThreshold, severity string) bool {
+	return severity == "critical" || t.MetricName == "room_temperature_high"
+}
+
+// sensorThresholds returns the room_* thresholds from alert_thresholds, falling back to the
+// defaults for any that are missing. Cached for a minute.
+func (s *RoomSensorService) sensorThresholds(ctx context.Context) []models.AlertThreshold {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.thresholds != nil && time.Since(s.thresholdsRead) < sensorThresholdsTTL {
+		return s.thresholds
+	}
+
+	configured := make(map[string]models.AlertThreshold)
+	if s.MetricsRepo != nil {
+		all, err := s.MetricsRepo.GetAlertThresholds(ctx)
+		if err != nil {
+			log.Printf("[Sensors] Could not load alert thresholds, using defaults: %v", err)
+		}
+		for _, t := range all {
+			configured[t.MetricName] = t
+		}
+	}
+	thresholds := make([]models.AlertThreshold, 0, len(defaultSensorThresholds))
+	for _, d := range defaultSensorThresholds {
+		if t, ok := configured[d.MetricName]; ok {
+			thresholds = append(thresholds, t)
+		} else {
+			thresholds = append(thresholds, d)
+		}
+	}
+	s.thresholds, s.thresholdsRead = thresholds, time.Now()
+	return thresholds
+}
+
+func sensorValue(r monitoring.SensorReading, metricName string) (float64, bool) {
+	switch {
+	case strings.HasPrefix(metricName, "room_temperature") && r.TemperatureC != nil:
+		return *r.TemperatureC, true
+	case strings.HasPrefix(metricName, "room_humidity") && r.HumidityPct != nil:
+		return *r.HumidityPct, true
+	}
+	return 0, false
+}
+
+func breachSeverity(t models.AlertThreshold, value float64) string {
+	if !t.Enabled {
+		return "ok"
+	}
+	beyond := func(limit float64) bool {
+		if t.Comparison == "lt" {
+			return value < limit
+		}
+		return value > limit
+	}
+	switch {
+	case beyond(t.CriticalThreshold):
+		return "critical"
+	case beyond(t.WarningThreshold):
+		return "warning"
+	}
+	return "ok"
+}
+
+func worseSeverity(a, b string) string {
+	rank := map[string]int{"ok": 0, "warning": 1, "critical": 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+func sensorLocation(r monitoring.SensorReading) string {
+	if r.Floor == "" {
+		return "Room " + r.RoomNo
+	}
+	return fmt.Sprintf("Room %s Floor %s", r.RoomNo, r.Floor)
+}
+
+func sensorSource(r monitoring.SensorReading) string {
+	return sensorLocation(r) + " / " + r.SensorID
+}
+
+func sensorAlertMessage(r monitoring.SensorReading, t models.AlertThreshold, value, limit float64) string {
+	what, unit := "temperature", "°C"
+	if strings.HasPrefix(t.MetricName, "room_humidity") {
+		what, unit = "humidity", "%"
+	}
+	direction := "above"
+	if t.Comparison == "lt" {
+		direction = "below"
+	}
+	return fmt.Sprintf("%s %s %.1f%s is %s %.1f%s (sensor %s)", sensorLocation(r), what, value, unit, direction, limit, unit, r.SensorID)
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"sync"
+
+	"cold-backend/internal/labelcode"
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrScanNotFound is returned when a valid code refers to a thock or gate pass that no longer exists
+	ErrScanNotFound = errors.New("scanned thock or gate pass not found")
+	// ErrScanOtherSeason is returned for codes printed in an earlier season
+	ErrScanOtherSeason = errors.New("code was printed in another season")
+)
+
+// qrImageSize is the side in pixels of QR images served for printing
+const qrImageSize = 240
+
+// ScanService signs the QR codes printed on labels and gate passes and resolves scanned
+// codes to the thock, where its bags are and what the scanning user can do next
+type ScanService struct {
+	KeyRepo         *repositories.QRKeyRepository
+	EntryRepo       *repositories.EntryRepository
+	GatePassRepo    *repositories.GatePassRepository
+	GatarStockRepo  *repositories.GatarStockRepository
+	ThockNumberRepo *repositories.ThockNumberRepository
+	PrinterService  *PrinterService // Optional: prints gate pass slips
+
+	mu   sync.Mutex
+	keys labelcode.Keys
+}
+
+func NewScanService(
+	keyRepo *repositories.QRKeyRepository,
+	entryRepo *repositories.EntryRepository,
+	gatePassRepo *repositories.GatePassRepository,
+	gatarStockRepo *repositories.GatarStockRepository,
+	thockNumberRepo *repositories.ThockNumberRepository,
+) *ScanService {
+	return &ScanService{
+		KeyRepo:         keyRepo,
+		EntryRepo:       entryRepo,
+		GatePassRepo:    gatePassRepo,
+		GatarStockRepo:  gatarStockRepo,
+		ThockNumberRepo: thockNumberRepo,
+	}
+}
+
+// SetPrinterService enables printing gate pass slips
+func (s *ScanService) SetPrinterService(printerService *PrinterService) {
+	s.PrinterService = printerService
+}
+
+// loadKeys returns the signing keys, reading them again when reload is set
+func (s *ScanService) loadKeys(ctx context.Context, reload bool) (labelcode.Keys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil || reload {
+		keys, err := s.KeyRepo.LoadKeys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load QR signing keys: %w", err)
+		}
+		s.keys = keys
+	}
+	return s.keys, nil
+}
+
+func (s *ScanService) sign(ctx context.Context, kind, ref string) (string, error) {
+	season, err := s.ThockNumberRepo.CurrentSeason(ctx)
+	if err != nil {
+		return "", err
+	}
+	keys, err := s.loadKeys(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	return keys.Sign(kind, season, ref)
+}
+
+// ThockCode returns the signed payload printed on a thock's bag labels
+func (s *ScanService) ThockCode(ctx context.Context, thockNumber string) (string, error) {
+	return s.sign(ctx, labelcode.KindThock, thockNumber)
+}
+
+// GatePassCode returns the signed payload printed on a gate pass
+func (s *ScanService) GatePassCode(ctx context.Context, gatePassID int) (string, error) {
+	return s.sign(ctx, labelcode.KindGatePass, strconv.Itoa(gatePassID))
+}
+
+// QRCode returns a payload with its image for a print page
+func (s *ScanService) QRCode(payload string) (*models.QRCodeResponse, error) {
+	img, err := labelcode.PNG(payload, qrImageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &models.QRCodeResponse{
+		Payload: payload,
+		PNG:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(img),
+	}, nil
+}
+
+// RotateKey makes a new key sign codes from now on; codes already printed keep scanning
+func (s *ScanService) RotateKey(ctx context.Context, userID int) (int, error) {
+	id, err := s.KeyRepo.AddKey(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.loadKeys(ctx, true); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// verify checks a scanned payload, reloading the keys once in case another server rotated them
+func (s *ScanService) verify(ctx context.Context, payload string) (*labelcode.Code, error) {
+	keys, err := s.loadKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	code, err := keys.Verify(payload)
+	if errors.Is(err, labelcode.ErrUnknownKey) {
+		if keys, err = s.loadKeys(ctx, true); err != nil {
+			return nil, err
+		}
+		code, err = keys.Verify(payload)
+	}
+	return code, err
+}
+
+// Resolve turns a scanned payload into the thock, its locations and the actions the
+// user's role allows next
+func (s *ScanService) Resolve(ctx context.Context, payload, role string) (*models.ScanResult, error) {
+	code, err := s.verify(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	season, err := s.ThockNumberRepo.CurrentSeason(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if code.Season != season {
+		return nil, fmt.Errorf("%w: printed in season %s, current season is %s", ErrScanOtherSeason, code.Season, season)
+	}
+
+	result := &models.ScanResult{Season: code.Season}
+	thockNumber := code.Ref
+	if code.Kind == labelcode.KindGatePass {
+		id, err := strconv.Atoi(code.Ref)
+		if err != nil {
+			return nil, labelcode.ErrInvalidCode
+		}
+		gatePass, err := s.GatePassRepo.GetGatePass(ctx, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrScanNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Kind = models.ScanKindGatePass
+		result.GatePass = gatePass
+		thockNumber = gatePass.ThockNumber
+	} else {
+		result.Kind = models.ScanKindThock
+	}
+
+	entry, err := s.EntryRepo.FindByScannedThock(ctx, thockNumber)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Entry = entry
+	result.ThockNumber = entry.ThockNumber
+
+	if result.Locations, err = s.GatarStockRepo.ListBalances(ctx, entry.ThockNumber); err != nil {
+		return nil, err
+	}
+	if result.Locations == nil {
+		result.Locations = []*models.GatarBalance{}
+	}
+	for _, loc := range result.Locations {
+		result.StoredBags += loc.Quantity
+	}
+
+	if result.OpenGatePasses, err = s.GatePassRepo.ListOpenByThock(ctx, entry.ThockNumber); err != nil {
+		return nil, err
+	}
+	if result.OpenGatePasses == nil {
+		result.OpenGatePasses = []*models.GatePass{}
+	}
+
+	result.Actions = scanActions(result, role)
+	return result, nil
+}
+
+// gatePassRemaining returns the bags still to be picked up on a gate pass
+func gatePassRemaining(gp *models.GatePass) int {
+	allowed := gp.RequestedQuantity
+	if gp.ApprovedQuantity != nil {
+		allowed = *gp.ApprovedQuantity
+	}
+	return allowed - gp.TotalPickedUp
+}
+
+// gatePassActions lists what can be done with one gate pass
+func gatePassActions(gp *models.GatePass) []models.ScanAction {
+	id := strconv.Itoa(gp.ID)
+	switch gp.Status {
+	case models.GatePassStatusRequested:
+		return []models.ScanAction{{
+			Action:   models.ScanActionApproveGatePass,
+			Method:   "PUT",
+			Endpoint: "/api/gate-passes/" + id + "/approve",
+			Prefill:  map[string]interface{}{"approved_quantity": gp.RequestedQuantity},
+		}}
+	case models.GatePassStatusApproved, models.GatePassStatusPartiallyPicked:
+		if gp.ApprovalExpiresAt != nil && timeutil.Now().After(*gp.ApprovalExpiresAt) {
+			return nil
+		}
+		var actions []models.ScanAction
+		if remaining := gatePassRemaining(gp); remaining > 0 {
+			actions = append(actions, models.ScanAction{
+				Action:      models.ScanActionPickup,
+				Method:      "POST",
+				Endpoint:    "/api/gate-passes/pickup",
+				Prefill:     map[string]interface{}{"gate_pass_id": gp.ID},
+				MaxQuantity: remaining,
+			})
+		}
+		if gp.TotalPickedUp > 0 {
+			actions = append(actions, models.ScanAction{
+				Action:   models.ScanActionCompleteGatePass,
+				Method:   "POST",
+				Endpoint: "/api/gate-passes/" + id + "/complete",
+			})
+		}
+		return actions
+	}
+	return nil
+}
+
+// scanActions lists the next steps for a scanned thock. Guards only look things up.
+func scanActions(result *models.ScanResult, role string) []models.ScanAction {
+	actions := []models.ScanAction{}
+	if role != "employee" && role != "admin" {
+		return actions
+	}
+	entry := result.Entry
+
+	if result.GatePass != nil {
+		return append(actions, gatePassActions(result.GatePass)...)
+	}
+
+	if remaining := entry.ExpectedQuantity - entry.ActualQuantity; remaining > 0 {
+		actions = append(actions, models.ScanAction{
+			Action:      models.ScanActionRoomEntry,
+			Method:      "POST",
+			Endpoint:    "/api/room-entries",
+			Prefill:     map[string]interface{}{"entry_id": entry.ID, "thock_number": entry.ThockNumber},
+			MaxQuantity: remaining,
+		})
+	}
+	for _, gp := range result.OpenGatePasses {
+		actions = append(actions, gatePassActions(gp)...)
+	}
+	if result.StoredBags > 0 {
+		actions = append(actions,
+			models.ScanAction{
+				Action:      models.ScanActionTransfer,
+				Method:      "POST",
+				Endpoint:    "/api/stock-transfers",
+				Prefill:     map[string]interface{}{"thock_number": entry.ThockNumber},
+				MaxQuantity: result.StoredBags,
+			},
+			models.ScanAction{
+				Action:   models.ScanActionInspect,
+				Method:   "POST",
+				Endpoint: "/api/quality-inspections",
+				Prefill:  map[string]interface{}{"thock_number": entry.ThockNumber},
+			})
+	}
+	actions = append(actions, models.ScanAction{
+		Action:   models.ScanActionPrintLabels,
+		Method:   "POST",
+		Endpoint: "/api/print",
+		Prefill:  map[string]interface{}{"line1": entry.ThockNumber, "line2": entry.Name},
+	})
+	return actions
+}
+
+// PrintGatePass prints a gate pass slip with its QR code at a counter's receipt printer
+func (s *ScanService) PrintGatePass(ctx context.Context, gatePassID int, counter string, userID int) (*models.PrintJob, error) {
+	if s.PrinterService == nil {
+		return nil, errors.New("printing is not available")
+	}
+	gatePass, err := s.GatePassRepo.GetGatePass(ctx, gatePassID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, err := s.GatePassCode(ctx, gatePass.ID)
+	if err != nil {
+		return nil, err
+	}
+	qr, err := s.QRCode(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	customerName := ""
+	if entry, err := s.EntryRepo.GetByThockNumber(ctx, gatePass.ThockNumber); err == nil {
+		customerName = entry.Name
+	}
+	approved := gatePass.RequestedQuantity
+	if gatePass.ApprovedQuantity != nil {
+		approved = *gatePass.ApprovedQuantity
+	}
+	gate := ""
+	if gatePass.GateNo != nil {
+		gate = *gatePass.GateNo
+	}
+	validUntil := ""
+	if gatePass.ApprovalExpiresAt != nil {
+		validUntil = timeutil.FormatIST(*gatePass.ApprovalExpiresAt, "02-01-2006 15:04")
+	}
+
+	lines := []models.ReceiptLine{
+		{Left: "Thock", Right: gatePass.ThockNumber, Bold: true},
+		{Left: "Customer", Right: customerName},
+		{Left: "Bags", Right: strconv.Itoa(approved)},
+		{Left: "Picked up", Right: strconv.Itoa(gatePass.TotalPickedUp)},
+		{Left: "Gate", Right: gate},
+		{Left: "Status", Right: gatePass.Status},
+		{Left: "Valid until", Right: validUntil},
+	}
+	rows := ""
+	for _, line := range lines {
+		rows += fmt.Sprintf("<tr><td>%s</td><td><b>%s</b></td></tr>", html.EscapeString(line.Left), html.EscapeString(line.Right))
+	}
+	title := fmt.Sprintf("Gate Pass #%d", gatePass.ID)
+
+	return s.PrinterService.PrintReceipt(ctx, &models.PrintReceiptRequest{
+		Counter:   counter,
+		Reference: title,
+		Title:     title,
+		Lines:     lines,
+		Code:      payload,
+		Footer:    "Scan at the gate",
+		HTML: fmt.Sprintf(`<html><body style="font-family:sans-serif"><h2>%s</h2><table>%s</table>`+
+			`<p><img src="%s" width="160" height="160" alt="QR"></p></body></html>`, title, rows, qr.PNG),
+	}, userID)
+}
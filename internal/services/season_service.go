@@ -23,6 +23,10 @@ type SeasonService struct {
 	pool       *pgxpool.Pool
 	tsdbPool   *pgxpool.Pool
 	jwtManager *auth.JWTManager
+
+	rentAccrual  *RentAccrualService                 // Optional: trues up rent before archiving
+	thockNumbers *repositories.ThockNumberRepository // Optional: starts the new season's thock number series
+	approvals    *ApprovalService                    // Optional: approval policy for season requests
 }
 
 // NewSeasonService creates a new season service
@@ -42,6 +46,35 @@ func NewSeasonService(
 	}
 }
 
+// SetRentAccrualService makes season reset post the final rent charges before archiving
+func (s *SeasonService) SetRentAccrualService(rentAccrual *RentAccrualService) {
+	s.rentAccrual = rentAccrual
+}
+
+// SetThockNumberRepo makes season reset number the new season's thocks from a fresh series
+func (s *SeasonService) SetThockNumberRepo(repo *repositories.ThockNumberRepository) {
+	s.thockNumbers = repo
+}
+
+// SetApprovalService routes season request approvals through the approval policies
+func (s *SeasonService) SetApprovalService(approvals *ApprovalService) {
+	s.approvals = approvals
+	approvals.OnExpiry(models.ApprovalFlowSeason, func(ctx context.Context, id int) error {
+		return s.seasonRepo.UpdateStatus(ctx, id, "expired", nil)
+	})
+}
+
+// seasonApprovalSubject describes a season request to the approval engine
+func seasonApprovalSubject(req *models.SeasonRequest) *models.ApprovalSubject {
+	initiatedBy := req.InitiatedByUserID
+	return &models.ApprovalSubject{
+		Flow:        models.ApprovalFlowSeason,
+		SubjectID:   req.ID,
+		Summary:     fmt.Sprintf("Start new season %s (archives and clears all business data)", req.SeasonName),
+		RequestedBy: &initiatedBy,
+	}
+}
+
 // InitiateNewSeason creates a new season request (requires admin password verification)
 func (s *SeasonService) InitiateNewSeason(ctx context.Context, userID int, req *models.InitiateSeasonRequest) (*models.SeasonRequest, error) {
 	// Verify user is admin
@@ -72,7 +105,16 @@ func (s *SeasonService) InitiateNewSeason(ctx context.Context, userID int, req *
 		Notes:             req.Notes,
 	}
 
-	return s.seasonRepo.Create(ctx, seasonReq)
+	created, err := s.seasonRepo.Create(ctx, seasonReq)
+	if err != nil {
+		return nil, err
+	}
+	if s.approvals != nil {
+		if _, err := s.approvals.Submit(ctx, seasonApprovalSubject(created)); err != nil {
+			log.Printf("[Season] Failed to open approval for season request %d: %v", created.ID, err)
+		}
+	}
+	return created, nil
 }
 
 // GetPendingRequests returns all pending season requests
@@ -254,18 +296,46 @@ func (s *SeasonService) GetArchivedData(ctx context.Context, seasonName string)
 	return data, nil
 }
 
-// ApproveRequest approves a season request and executes the archive/clear process
-func (s *SeasonService) ApproveRequest(ctx context.Context, requestID int, approverUserID int, password string) error {
+// ApproveRequest approves a season request and executes the archive/clear process.
+// With an approval policy in place it returns the approval, still pending while more
+// approvers are needed; the reset only starts once it is approved.
+func (s *SeasonService) ApproveRequest(ctx context.Context, requestID int, approverUserID int, creds *models.ApprovalCredentials) (*models.ApprovalRequest, error) {
 	// Get the request
 	req, err := s.seasonRepo.GetByID(ctx, requestID)
 	if err != nil {
-		return errors.New("season request not found")
+		return nil, errors.New("season request not found")
 	}
 
 	if req.Status != "pending" {
-		return errors.New("request is not in pending status")
+		return nil, errors.New("request is not in pending status")
+	}
+
+	var approval *models.ApprovalRequest
+	if s.approvals != nil {
+		approval, err = s.approvals.Approve(ctx, seasonApprovalSubject(req), approverUserID, creds)
+		if err != nil {
+			return nil, err
+		}
+		if approvalPending(approval) {
+			return approval, nil
+		}
+	} else if err := s.verifyApprover(ctx, req, approverUserID, creds); err != nil {
+		return nil, err
+	}
+
+	// Update status to approved
+	if err := s.seasonRepo.UpdateStatus(ctx, requestID, "approved", &approverUserID); err != nil {
+		return nil, err
 	}
 
+	// Execute archive and clear process in background
+	go s.executeSeasonReset(requestID, req.SeasonName)
+
+	return approval, nil
+}
+
+// verifyApprover checks the approver without the approval engine
+func (s *SeasonService) verifyApprover(ctx context.Context, req *models.SeasonRequest, approverUserID int, creds *models.ApprovalCredentials) error {
 	// Verify approver is admin and different from initiator
 	approver, err := s.userRepo.Get(ctx, approverUserID)
 	if err != nil {
@@ -283,23 +353,17 @@ func (s *SeasonService) ApproveRequest(ctx context.Context, requestID int, appro
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(approver.PasswordHash), []byte(password)); err != nil {
-		return errors.New("invalid password")
+	if creds == nil || creds.Password == "" {
+		return errors.New("password is required for verification")
 	}
-
-	// Update status to approved
-	if err := s.seasonRepo.UpdateStatus(ctx, requestID, "approved", &approverUserID); err != nil {
-		return err
+	if err := bcrypt.CompareHashAndPassword([]byte(approver.PasswordHash), []byte(creds.Password)); err != nil {
+		return errors.New("invalid password")
 	}
-
-	// Execute archive and clear process in background
-	go s.executeSeasonReset(requestID, req.SeasonName)
-
 	return nil
 }
 
 // RejectRequest rejects a season request
-func (s *SeasonService) RejectRequest(ctx context.Context, requestID int, rejecterUserID int, reason string) error {
+func (s *SeasonService) RejectRequest(ctx context.Context, requestID int, rejecterUserID int, reason, ipAddress string) error {
 	// Get the request
 	req, err := s.seasonRepo.GetByID(ctx, requestID)
 	if err != nil {
@@ -320,6 +384,12 @@ func (s *SeasonService) RejectRequest(ctx context.Context, requestID int, reject
 		return errors.New("only admins can reject season requests")
 	}
 
+	if s.approvals != nil {
+		if _, err := s.approvals.Reject(ctx, seasonApprovalSubject(req), rejecterUserID, reason, ipAddress); err != nil {
+			return err
+		}
+	}
+
 	return s.seasonRepo.RejectRequest(ctx, requestID, rejecterUserID, reason)
 }
 
@@ -339,6 +409,16 @@ func (s *SeasonService) executeSeasonReset(requestID int, seasonName string) {
 		return
 	}
 
+	// Charge the remaining rent for the closing season while entries still exist
+	if s.rentAccrual != nil {
+		log.Println("[Season] Posting season-close rent charges...")
+		if _, err := s.rentAccrual.CloseSeason(ctx, 0); err != nil {
+			log.Printf("[Season] Failed to post season-close rent: %v", err)
+			s.seasonRepo.UpdateCompletion(ctx, requestID, "failed", "", nil, fmt.Sprintf("Failed to post season-close rent: %v", err))
+			return
+		}
+	}
+
 	// Archive app data to same database
 	log.Println("[Season] Archiving entries...")
 	summary.Entries, _ = s.archiveEntries(ctx, seasonName)
@@ -419,6 +499,15 @@ func (s *SeasonService) executeSeasonReset(requestID int, seasonName string) {
 	s.pool.Exec(ctx, "ALTER SEQUENCE rent_payments_id_seq RESTART WITH 1")
 	s.pool.Exec(ctx, "ALTER SEQUENCE invoices_id_seq RESTART WITH 1")
 
+	// Number the new season's thocks from its own series; the old season's log stays for auditors
+	if s.thockNumbers != nil {
+		if err := s.thockNumbers.StartSeason(ctx, seasonName, nil, 0); err != nil {
+			log.Printf("[Season] Failed to start thock number season %s: %v", seasonName, err)
+		} else {
+			log.Printf("[Season] Thock numbering switched to season %s", seasonName)
+		}
+	}
+
 	// Clear timeseries data if available
 	if s.tsdbPool != nil {
 		log.Println("[Season] Clearing timeseries data...")
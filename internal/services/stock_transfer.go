@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// ErrInvalidTransfer is returned for stock transfers that cannot be carried out
+var ErrInvalidTransfer = errors.New("invalid stock transfer")
+
+// Transfer moves bags of a thock from one gatar to another (on any floor or room). The bags
+// keep their room entry; the ledger gets a transfer_out and transfer_in row per room entry.
+func (s *GatarStockService) Transfer(ctx context.Context, req *models.StockTransferRequest, userID int) (*models.StockTransfer, error) {
+	req.ThockNumber = strings.TrimSpace(req.ThockNumber)
+	if req.ThockNumber == "" {
+		return nil, fmt.Errorf("%w: thock number is required", ErrInvalidTransfer)
+	}
+	if req.Quantity < 1 {
+		return nil, fmt.Errorf("%w: quantity must be at least 1", ErrInvalidTransfer)
+	}
+	if req.FromGatar == req.ToGatar {
+		return nil, fmt.Errorf("%w: source and destination gatar are the same", ErrInvalidTransfer)
+	}
+
+	balances, err := s.Repo.ListBalances(ctx, req.ThockNumber)
+	if err != nil {
+		return nil, err
+	}
+	var source []*models.GatarBalance
+	available := 0
+	for _, b := range balances {
+		if b.GatarNo == req.FromGatar && b.Quantity > 0 {
+			source = append(source, b)
+			available += b.Quantity
+		}
+	}
+	if available < req.Quantity {
+		return nil, fmt.Errorf("%w: thock %s has %d bags in gatar %d", ErrInvalidTransfer, req.ThockNumber, available, req.FromGatar)
+	}
+
+	to, err := s.Layout.LocateGatar(ctx, req.ToGatar)
+	if errors.Is(err, repositories.ErrLayoutNotFound) {
+		return nil, fmt.Errorf("%w: gatar %d is not in the warehouse layout", ErrInvalidTransfer, req.ToGatar)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Layout.ValidatePlacement(ctx, to.RoomNo, to.Floor, strconv.Itoa(to.GatarNo), nil, ""); err != nil {
+		return nil, err
+	}
+
+	transfer := &models.StockTransfer{
+		ThockNumber:   req.ThockNumber,
+		FromRoomNo:    source[0].RoomNo,
+		FromFloor:     source[0].Floor,
+		FromGatar:     req.FromGatar,
+		ToRoomNo:      to.RoomNo,
+		ToFloor:       to.Floor,
+		ToGatar:       to.GatarNo,
+		Quantity:      req.Quantity,
+		Reason:        strings.TrimSpace(req.Reason),
+		MovedByUserID: userID,
+	}
+
+	// Oldest room entries move first
+	var movements []*models.GatarStockMovement
+	remaining := req.Quantity
+	for _, b := range source {
+		qty := min(b.Quantity, remaining)
+		if qty == 0 {
+			break
+		}
+		remaining -= qty
+		movements = append(movements,
+			&models.GatarStockMovement{
+				RoomEntryID:     b.RoomEntryID,
+				ThockNumber:     b.ThockNumber,
+				RoomNo:          b.RoomNo,
+				Floor:           b.Floor,
+				GatarNo:         b.GatarNo,
+				Quantity:        -qty,
+				MovementType:    models.GatarMovementTransferOut,
+				SourceType:      "stock_transfer",
+				Notes:           transfer.Reason,
+				CreatedByUserID: &userID,
+			},
+			&models.GatarStockMovement{
+				RoomEntryID:     b.RoomEntryID,
+				ThockNumber:     b.ThockNumber,
+				RoomNo:          to.RoomNo,
+				Floor:           to.Floor,
+				GatarNo:         to.GatarNo,
+				Quantity:        qty,
+				MovementType:    models.GatarMovementTransferIn,
+				SourceType:      "stock_transfer",
+				Notes:           transfer.Reason,
+				CreatedByUserID: &userID,
+			})
+	}
+
+	err = s.Repo.CreateTransfer(ctx, transfer, movements, s.Layout.spaceCheck(ctx, &transfer.CapacityWarnings))
+	if errors.Is(err, repositories.ErrGatarStockShort) {
+		// Another transfer or pickup took the bags since the balances were read
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTransfer, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.logTransferEvents(ctx, transfer, movements)
+	return transfer, nil
+}
+
+// logTransferEvents adds a STOCK_MOVED event to the timeline of each entry whose bags moved
+func (s *GatarStockService) logTransferEvents(ctx context.Context, transfer *models.StockTransfer, movements []*models.GatarStockMovement) {
+	if s.RoomEntryRepo == nil || s.EntryEventRepo == nil {
+		return
+	}
+	for _, m := range movements {
+		if m.MovementType != models.GatarMovementTransferIn {
+			continue
+		}
+		roomEntry, err := s.RoomEntryRepo.Get(ctx, m.RoomEntryID)
+		if err != nil {
+			continue
+		}
+		notes := fmt.Sprintf("%d bags moved from Room %s, Floor %s, Gatar %d to Room %s, Floor %s, Gatar %d",
+			m.Quantity, transfer.FromRoomNo, transfer.FromFloor, transfer.FromGatar,
+			transfer.ToRoomNo, transfer.ToFloor, transfer.ToGatar)
+		if transfer.Reason != "" {
+			notes += " (" + transfer.Reason + ")"
+		}
+		// Don't fail the transfer if the event fails
+		s.EntryEventRepo.Create(ctx, &models.EntryEvent{
+			EntryID:         roomEntry.EntryID,
+			EventType:       models.EventTypeStockMoved,
+			Status:          models.StatusInStorage,
+			Notes:           notes,
+			CreatedByUserID: transfer.MovedByUserID,
+		})
+	}
+}
+
+// ListTransfers returns recent stock transfers, optionally for one thock
+func (s *GatarStockService) ListTransfers(ctx context.Context, thockNumber string) ([]*models.StockTransfer, error) {
+	return s.Repo.ListTransfers(ctx, strings.TrimSpace(thockNumber), 500)
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+)
+
+// TariffService prices stored thocks against the versioned rent rate cards.
+// Every rent calculation (reports, customer portal, account summary, invoices)
+// goes through a RateCard loaded here so balances agree across screens.
+type TariffService struct {
+	TariffRepo   *repositories.RentTariffRepository
+	SettingsRepo *repositories.SystemSettingRepository
+}
+
+func NewTariffService(tariffRepo *repositories.RentTariffRepository, settingsRepo *repositories.SystemSettingRepository) *TariffService {
+	return &TariffService{
+		TariffRepo:   tariffRepo,
+		SettingsRepo: settingsRepo,
+	}
+}
+
+// RateCard is an in-memory snapshot of the active rate cards
+// Load once per request/report and reuse it for every thock
+type RateCard struct {
+	tariffs      []*models.RentTariff // Ascending by effective_from
+	fallbackRate float64              // Legacy flat setting, used only when no rate card exists
+}
+
+// LoadRateCard loads all active rate cards into a RateCard snapshot
+func (s *TariffService) LoadRateCard(ctx context.Context) (*RateCard, error) {
+	tariffs, err := s.TariffRepo.List(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rent tariffs: %w", err)
+	}
+
+	sort.SliceStable(tariffs, func(i, j int) bool {
+		return tariffs[i].EffectiveFrom.Before(tariffs[j].EffectiveFrom)
+	})
+
+	return &RateCard{
+		tariffs:      tariffs,
+		fallbackRate: s.legacyRate(ctx),
+	}, nil
+}
+
+// legacyRate reads the old flat rent setting (rent_per_item, then rent_rate_per_bag)
+func (s *TariffService) legacyRate(ctx context.Context) float64 {
+	if s.SettingsRepo == nil {
+		return 0
+	}
+	for _, key := range []string{"rent_per_item", "rent_rate_per_bag"} {
+		setting, err := s.SettingsRepo.Get(ctx, key)
+		if err != nil || setting == nil {
+			continue
+		}
+		// The value might be stored as a plain number or as JSON
+		value := strings.Trim(strings.TrimSpace(setting.SettingValue), `"`)
+		if rate, err := strconv.ParseFloat(value, 64); err == nil {
+			return rate
+		}
+		var rate float64
+		if err := json.Unmarshal([]byte(setting.SettingValue), &rate); err == nil {
+			return rate
+		}
+	}
+	return 0
+}
+
+// ListTariffs returns all rate cards, newest first
+func (s *TariffService) ListTariffs(ctx context.Context, activeOnly bool) ([]*models.RentTariff, error) {
+	return s.TariffRepo.List(ctx, activeOnly)
+}
+
+// GetTariff returns a single rate card
+func (s *TariffService) GetTariff(ctx context.Context, id int) (*models.RentTariff, error) {
+	return s.TariffRepo.Get(ctx, id)
+}
+
+// CreateTariff validates and publishes a new rate card version
+func (s *TariffService) CreateTariff(ctx context.Context, req *models.CreateRentTariffRequest, userID int) (*models.RentTariff, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("tariff name is required")
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid effective_from date, use YYYY-MM-DD")
+	}
+
+	accrualMode := strings.TrimSpace(req.AccrualMode)
+	if accrualMode == "" {
+		accrualMode = models.RentAccrualOnStorage
+	}
+	if accrualMode != models.RentAccrualOnStorage && accrualMode != models.RentAccrualMonthly {
+		return nil, fmt.Errorf("accrual_mode must be %s or %s", models.RentAccrualOnStorage, models.RentAccrualMonthly)
+	}
+
+	if len(req.Rates) == 0 {
+		return nil, fmt.Errorf("tariff must have at least one rate")
+	}
+
+	hasGeneric := false
+	rates := make([]models.RentTariffRate, 0, len(req.Rates))
+	for i, rate := range req.Rates {
+		rate.ThockCategory = strings.ToLower(strings.TrimSpace(rate.ThockCategory))
+		rate.Variety = strings.TrimSpace(rate.Variety)
+
+		if rate.ThockCategory != "" && rate.ThockCategory != "seed" && rate.ThockCategory != "sell" {
+			return nil, fmt.Errorf("rate %d: thock_category must be seed, sell or empty", i+1)
+		}
+		if rate.RatePerBag < 0 {
+			return nil, fmt.Errorf("rate %d: rate_per_bag cannot be negative", i+1)
+		}
+		if rate.MinMonths < 0 {
+			return nil, fmt.Errorf("rate %d: min_months cannot be negative", i+1)
+		}
+		if rate.MaxMonths != nil && *rate.MaxMonths < rate.MinMonths {
+			return nil, fmt.Errorf("rate %d: max_months must be >= min_months", i+1)
+		}
+		if rate.ThockCategory == "" && rate.Variety == "" && rate.MinMonths == 0 && rate.MaxMonths == nil {
+			hasGeneric = true
+		}
+		rates = append(rates, rate)
+	}
+
+	// A catch-all line guarantees every thock gets a price from this card
+	if !hasGeneric {
+		return nil, fmt.Errorf("tariff must include a base rate (no category, no variety, min_months 0, no max_months)")
+	}
+
+	tariff := &models.RentTariff{
+		Name:          name,
+		EffectiveFrom: effectiveFrom,
+		AccrualMode:   accrualMode,
+		Notes:         req.Notes,
+		Rates:         rates,
+	}
+	if userID > 0 {
+		tariff.CreatedByUserID = &userID
+	}
+
+	if err := s.TariffRepo.Create(ctx, tariff); err != nil {
+		return nil, err
+	}
+	return tariff, nil
+}
+
+// SetTariffActive activates or retires a rate card
+func (s *TariffService) SetTariffActive(ctx context.Context, id int, active bool) error {
+	return s.TariffRepo.SetActive(ctx, id, active)
+}
+
+// QuoteEntry prices a quantity of an entry as of the given time
+func (s *TariffService) QuoteEntry(ctx context.Context, entry *models.Entry, quantity int, asOf time.Time) (*models.RentQuote, error) {
+	card, err := s.LoadRateCard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	quote := card.Quote(entry.ThockCategory, entry.Remark, entry.CreatedAt, asOf, quantity)
+	return &quote, nil
+}
+
+// StorageMonths returns the number of started months between storage and asOf (minimum 1)
+func StorageMonths(storedAt, asOf time.Time) int {
+	s := timeutil.ToIST(storedAt)
+	a := timeutil.ToIST(asOf)
+	months := (a.Year()-s.Year())*12 + int(a.Month()) - int(s.Month())
+	if a.Day() > s.Day() {
+		months++ // A partially used month counts as a full month
+	}
+	if months < 1 {
+		months = 1
+	}
+	return months
+}
+
+// tariffFor returns the rate card in force on the storage date
+func (c *RateCard) tariffFor(storedAt time.Time) *models.RentTariff {
+	if len(c.tariffs) == 0 {
+		return nil
+	}
+	ist := timeutil.ToIST(storedAt)
+	storedDate := time.Date(ist.Year(), ist.Month(), ist.Day(), 0, 0, 0, 0, time.UTC)
+
+	selected := c.tariffs[0] // Thocks stored before the first card use the earliest card
+	for _, t := range c.tariffs {
+		if t.EffectiveFrom.After(storedDate) {
+			break
+		}
+		selected = t
+	}
+	return selected
+}
+
+// Quote prices a thock. remark is the entry's comma-separated variety list.
+func (c *RateCard) Quote(category, remark string, storedAt, asOf time.Time, quantity int) models.RentQuote {
+	months := StorageMonths(storedAt, asOf)
+	quote := models.RentQuote{
+		ThockCategory: category,
+		StorageMonths: months,
+		RatePerBag:    c.fallbackRate,
+		Quantity:      quantity,
+	}
+
+	tariff := c.tariffFor(storedAt)
+	if tariff != nil {
+		quote.TariffID = tariff.ID
+		quote.TariffName = tariff.Name
+		if rate, ok := matchRate(tariff.Rates, category, remark, months); ok {
+			quote.RatePerBag = rate.RatePerBag
+			quote.Variety = rate.Variety
+		}
+	}
+
+	quote.Amount = float64(quantity) * quote.RatePerBag
+	return quote
+}
+
+// RateFor returns the per-bag rent for an entry as of the given time
+func (c *RateCard) RateFor(entry *models.Entry, asOf time.Time) float64 {
+	return c.Quote(entry.ThockCategory, entry.Remark, entry.CreatedAt, asOf, 1).RatePerBag
+}
+
+// EntryRent returns the rent for a quantity of an entry as of the given time
+func (c *RateCard) EntryRent(entry *models.Entry, quantity int, asOf time.Time) float64 {
+	return float64(quantity) * c.RateFor(entry, asOf)
+}
+
+// AccrualMode returns how rent for an entry is posted to the ledger
+func (c *RateCard) AccrualMode(entry *models.Entry) string {
+	if tariff := c.tariffFor(entry.CreatedAt); tariff != nil && tariff.AccrualMode != "" {
+		return tariff.AccrualMode
+	}
+	return models.RentAccrualOnStorage
+}
+
+// DefaultRate returns the base rate of the card in force today (for display as rent_per_item)
+func (c *RateCard) DefaultRate() float64 {
+	tariff := c.tariffFor(timeutil.Now())
+	if tariff == nil {
+		return c.fallbackRate
+	}
+	if rate, ok := matchRate(tariff.Rates, "", "", 1); ok {
+		return rate.RatePerBag
+	}
+	return c.fallbackRate
+}
+
+// matchRate picks the most specific rate line for category/variety/slab
+// Specificity: category+variety > variety > category > base rate
+func matchRate(rates []models.RentTariffRate, category, remark string, months int) (models.RentTariffRate, bool) {
+	varieties := make(map[string]bool)
+	for _, v := range strings.Split(remark, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			varieties[v] = true
+		}
+	}
+	category = strings.ToLower(strings.TrimSpace(category))
+
+	var best models.RentTariffRate
+	bestScore := -1
+	for _, rate := range rates {
+		if rate.ThockCategory != "" && rate.ThockCategory != category {
+			continue
+		}
+		if rate.Variety != "" && !varieties[strings.ToLower(rate.Variety)] {
+			continue
+		}
+		if months < rate.MinMonths || (rate.MaxMonths != nil && months > *rate.MaxMonths) {
+			continue
+		}
+
+		score := 0
+		if rate.Variety != "" {
+			score += 2
+		}
+		if rate.ThockCategory != "" {
+			score++
+		}
+		if score > bestScore || (score == bestScore && rate.MinMonths > best.MinMonths) {
+			best = rate
+			bestScore = score
+		}
+	}
+	return best, bestScore >= 0
+}
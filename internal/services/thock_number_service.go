@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// ErrInvalidThockNumberRequest is returned for reservations, voids and season starts that make no sense
+var ErrInvalidThockNumberRequest = errors.New("invalid thock number request")
+
+// Most numbers one reservation may hold back
+const maxThockReservation = 100
+
+// ThockNumberService manages the per-season thock number series: reserving and voiding
+// numbers, starting a new season's series and reporting numbers that went to no entry
+type ThockNumberService struct {
+	Repo        *repositories.ThockNumberRepository
+	SettingRepo *repositories.SystemSettingRepository
+}
+
+func NewThockNumberService(repo *repositories.ThockNumberRepository, settingRepo *repositories.SystemSettingRepository) *ThockNumberService {
+	return &ThockNumberService{Repo: repo, SettingRepo: settingRepo}
+}
+
+func validateThockCategory(category string) error {
+	if category != "seed" && category != "sell" {
+		return fmt.Errorf("%w: category must be 'seed' or 'sell'", ErrInvalidThockNumberRequest)
+	}
+	return nil
+}
+
+// Reserve holds numbers of the current season back for later entries
+func (s *ThockNumberService) Reserve(ctx context.Context, req *models.ReserveThockNumbersRequest, userID int) ([]*models.ThockNumberRecord, error) {
+	if err := validateThockCategory(req.Category); err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidThockNumberRequest)
+	}
+	if req.Number < 0 || (req.Number > 0 && req.Count > 1) {
+		return nil, fmt.Errorf("%w: give either a number or a count", ErrInvalidThockNumberRequest)
+	}
+	count := req.Count
+	if req.Number == 0 {
+		if count <= 0 {
+			count = 1
+		}
+		if count > maxThockReservation {
+			return nil, fmt.Errorf("%w: at most %d numbers can be reserved at once", ErrInvalidThockNumberRequest, maxThockReservation)
+		}
+	}
+
+	return s.Repo.Reserve(ctx, req.Category, count, req.Number, loadSkipRanges(ctx, s.SettingRepo, req.Category), reason, userID)
+}
+
+// Void withdraws a number of the current season for good
+func (s *ThockNumberService) Void(ctx context.Context, req *models.VoidThockNumberRequest, userID int) (*models.ThockNumberRecord, error) {
+	if err := validateThockCategory(req.Category); err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidThockNumberRequest)
+	}
+	if req.Number <= 0 {
+		return nil, fmt.Errorf("%w: number must be positive", ErrInvalidThockNumberRequest)
+	}
+	return s.Repo.Void(ctx, req.Category, req.Number, reason, userID)
+}
+
+// StartSeason switches numbering to a new season's series
+func (s *ThockNumberService) StartSeason(ctx context.Context, req *models.StartThockSeasonRequest, userID int) error {
+	season := strings.TrimSpace(req.Season)
+	if season == "" || len(season) > 100 {
+		return fmt.Errorf("%w: season name is required (up to 100 characters)", ErrInvalidThockNumberRequest)
+	}
+	if req.SeedStart < 0 || req.SellStart < 0 {
+		return fmt.Errorf("%w: start numbers cannot be negative", ErrInvalidThockNumberRequest)
+	}
+	return s.Repo.StartSeason(ctx, season, map[string]int{"seed": req.SeedStart, "sell": req.SellStart}, userID)
+}
+
+// CurrentSeason returns the season new thocks are numbered in
+func (s *ThockNumberService) CurrentSeason(ctx context.Context) (string, error) {
+	return s.Repo.CurrentSeason(ctx)
+}
+
+// Report lists a season's series and every number in it that went to no entry.
+// season "" is the current season; category "" covers both.
+func (s *ThockNumberService) Report(ctx context.Context, season, category, status string) (*models.ThockNumberReport, error) {
+	if category != "" {
+		if err := validateThockCategory(category); err != nil {
+			return nil, err
+		}
+	}
+	switch status {
+	case "", models.ThockNumberIssued, models.ThockNumberReserved, models.ThockNumberVoided, models.ThockNumberSkipped:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidThockNumberRequest, status)
+	}
+
+	current, err := s.Repo.CurrentSeason(ctx)
+	if err != nil {
+		return nil, err
+	}
+	season = strings.TrimSpace(season)
+	if season == "" {
+		season = current
+	}
+
+	series, err := s.Repo.ListSeries(ctx, season)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.Repo.ListRecords(ctx, season, category, status)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ThockNumberReport{
+		Season:  season,
+		Series:  []*models.ThockNumberSeries{},
+		Records: records,
+		Missing: map[string][]int{},
+	}
+	if report.Records == nil {
+		report.Records = []*models.ThockNumberRecord{}
+	}
+	for _, ser := range series {
+		if category != "" && ser.Category != category {
+			continue
+		}
+		// Only the current season still hands out numbers
+		if season == current {
+			next, err := s.Repo.PeekNext(ctx, ser.Category, loadSkipRanges(ctx, s.SettingRepo, ser.Category))
+			if err != nil {
+				return nil, err
+			}
+			ser.NextNumber = next
+		}
+		report.Series = append(report.Series, ser)
+
+		if ser.Missing > 0 {
+			missing, err := s.Repo.ListMissing(ctx, season, ser.Category)
+			if err != nil {
+				return nil, err
+			}
+			report.Missing[ser.Category] = missing
+		}
+	}
+	return report, nil
+}
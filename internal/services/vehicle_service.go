@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/timeutil"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrInvalidVehicleVisit is returned for visits that cannot be booked in, linked or out
+	ErrInvalidVehicleVisit = errors.New("invalid vehicle visit")
+	// ErrVehicleQuantityMismatch is returned when a vehicle carries a different number of
+	// bags than were picked up on its gate passes and the guard did not accept the difference
+	ErrVehicleQuantityMismatch = errors.New("loaded quantity does not match gate passes")
+)
+
+// Vehicles inside longer than this are flagged unless vehicle_overdue_minutes says otherwise
+const defaultVehicleOverdueMinutes = 240
+
+var (
+	vehicleNoRegex   = regexp.MustCompile(`^[A-Z0-9]{4,12}$`)
+	driverPhoneRegex = regexp.MustCompile(`^[0-9]{10}$`)
+)
+
+// VehicleService keeps the register of trucks from the guard gate to their exit and checks
+// what a truck carries out against the bags picked up on its gate passes
+type VehicleService struct {
+	Repo           *repositories.VehicleRepository
+	GuardEntryRepo *repositories.GuardEntryRepository
+	EntryRepo      *repositories.EntryRepository
+	GatePassRepo   *repositories.GatePassRepository
+	SettingRepo    *repositories.SystemSettingRepository
+}
+
+func NewVehicleService(repo *repositories.VehicleRepository, guardEntryRepo *repositories.GuardEntryRepository, entryRepo *repositories.EntryRepository, gatePassRepo *repositories.GatePassRepository, settingRepo *repositories.SystemSettingRepository) *VehicleService {
+	return &VehicleService{
+		Repo:           repo,
+		GuardEntryRepo: guardEntryRepo,
+		EntryRepo:      entryRepo,
+		GatePassRepo:   gatePassRepo,
+		SettingRepo:    settingRepo,
+	}
+}
+
+// NormalizeVehicleNo upper-cases a vehicle number and drops spaces and dashes,
+// so "mp 09-ab 1234" and "MP09AB1234" are the same vehicle
+func NormalizeVehicleNo(vehicleNo string) string {
+	return strings.NewReplacer(" ", "", "-", "", ".", "").Replace(strings.ToUpper(strings.TrimSpace(vehicleNo)))
+}
+
+// CheckIn books a vehicle in at the guard gate
+func (s *VehicleService) CheckIn(ctx context.Context, req *models.VehicleCheckInRequest, userID int) (*models.VehicleVisit, error) {
+	v := &models.VehicleVisit{
+		VehicleNo:   NormalizeVehicleNo(req.VehicleNo),
+		DriverName:  strings.TrimSpace(req.DriverName),
+		DriverPhone: strings.TrimSpace(req.DriverPhone),
+		Purpose:     req.Purpose,
+		Remarks:     strings.TrimSpace(req.Remarks),
+		InByUserID:  &userID,
+	}
+	if !vehicleNoRegex.MatchString(v.VehicleNo) {
+		return nil, fmt.Errorf("%w: vehicle number must be 4 to 12 letters and digits", ErrInvalidVehicleVisit)
+	}
+
+	if req.GuardEntryID != nil {
+		guardEntry, err := s.GuardEntryRepo.Get(ctx, *req.GuardEntryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: guard entry %d not found", ErrInvalidVehicleVisit, *req.GuardEntryID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		v.GuardEntryID = &guardEntry.ID
+		if v.DriverPhone == "" {
+			v.DriverPhone = guardEntry.DriverNo
+		}
+	}
+	if v.DriverPhone != "" && !driverPhoneRegex.MatchString(v.DriverPhone) {
+		return nil, fmt.Errorf("%w: driver phone must be exactly 10 digits", ErrInvalidVehicleVisit)
+	}
+
+	for _, id := range req.EntryIDs {
+		if err := s.checkEntry(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range req.GatePassIDs {
+		if err := s.checkGatePass(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	switch v.Purpose {
+	case models.VehiclePurposeDelivery, models.VehiclePurposeCollection:
+	case "":
+		v.Purpose = models.VehiclePurposeDelivery
+		if len(req.GatePassIDs) > 0 {
+			v.Purpose = models.VehiclePurposeCollection
+		}
+	default:
+		return nil, fmt.Errorf("%w: purpose must be delivery or collection", ErrInvalidVehicleVisit)
+	}
+
+	if err := s.Repo.CheckIn(ctx, v, req.EntryIDs, req.GatePassIDs); err != nil {
+		if errors.Is(err, repositories.ErrVehicleAlreadyInside) {
+			return nil, fmt.Errorf("%w: %s is already inside", ErrInvalidVehicleVisit, v.VehicleNo)
+		}
+		return nil, err
+	}
+	return s.Repo.Get(ctx, v.ID)
+}
+
+func (s *VehicleService) checkEntry(ctx context.Context, id int) error {
+	_, err := s.EntryRepo.Get(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: entry %d not found", ErrInvalidVehicleVisit, id)
+	}
+	return err
+}
+
+// checkGatePass allows gate passes bags can still be picked up on, or that wait for approval
+func (s *VehicleService) checkGatePass(ctx context.Context, id int) error {
+	gp, err := s.GatePassRepo.GetGatePass(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: gate pass %d not found", ErrInvalidVehicleVisit, id)
+	}
+	if err != nil {
+		return err
+	}
+	switch gp.Status {
+	case models.GatePassStatusRequested, models.GatePassStatusApproved, models.GatePassStatusPartiallyPicked:
+		return nil
+	}
+	return fmt.Errorf("%w: gate pass %d is %s", ErrInvalidVehicleVisit, id, gp.Status)
+}
+
+// Link adds an entry or a gate pass to a vehicle still inside
+func (s *VehicleService) Link(ctx context.Context, id int, req *models.VehicleLinkRequest) (*models.VehicleVisit, error) {
+	if (req.EntryID == nil) == (req.GatePassID == nil) {
+		return nil, fmt.Errorf("%w: give either entry_id or gate_pass_id", ErrInvalidVehicleVisit)
+	}
+	if req.EntryID != nil {
+		if err := s.checkEntry(ctx, *req.EntryID); err != nil {
+			return nil, err
+		}
+	} else if err := s.checkGatePass(ctx, *req.GatePassID); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.AddLink(ctx, id, req.EntryID, req.GatePassID); err != nil {
+		return nil, err
+	}
+	return s.Repo.Get(ctx, id)
+}
+
+// Get returns a vehicle visit
+func (s *VehicleService) Get(ctx context.Context, id int) (*models.VehicleVisit, error) {
+	return s.Repo.Get(ctx, id)
+}
+
+// List returns the register for the days from..to (IST), optionally for one vehicle
+func (s *VehicleService) List(ctx context.Context, vehicleNo string, from, to time.Time) ([]*models.VehicleVisit, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to date is before from date", ErrInvalidVehicleVisit)
+	}
+	return s.Repo.List(ctx, NormalizeVehicleNo(vehicleNo), timeutil.StartOfDay(from), timeutil.EndOfDay(to), 500)
+}
+
+// ListByGatePass returns the vehicles that collected on a gate pass
+func (s *VehicleService) ListByGatePass(ctx context.Context, gatePassID int) ([]*models.VehicleVisit, error) {
+	return s.Repo.ListByGatePass(ctx, gatePassID)
+}
+
+// Inside returns the dashboard of vehicles currently inside
+func (s *VehicleService) Inside(ctx context.Context) (*models.VehiclesInside, error) {
+	visits, err := s.Repo.ListInside(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if visits == nil {
+		visits = []*models.VehicleVisit{}
+	}
+
+	dash := &models.VehiclesInside{
+		Count:       len(visits),
+		OverdueMins: s.overdueMinutes(ctx),
+		Vehicles:    visits,
+	}
+	for _, v := range visits {
+		if v.Purpose == models.VehiclePurposeCollection {
+			dash.Collecting++
+		} else {
+			dash.Delivering++
+		}
+		if v.MinutesInside > dash.OverdueMins {
+			dash.Overdue++
+		}
+	}
+	return dash, nil
+}
+
+func (s *VehicleService) overdueMinutes(ctx context.Context) int {
+	if s.SettingRepo == nil {
+		return defaultVehicleOverdueMinutes
+	}
+	setting, err := s.SettingRepo.Get(ctx, "vehicle_overdue_minutes")
+	if err != nil || setting == nil {
+		return defaultVehicleOverdueMinutes
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(setting.SettingValue))
+	if err != nil || value <= 0 {
+		return defaultVehicleOverdueMinutes
+	}
+	return value
+}
+
+// ExitCheck compares the bags a vehicle carries with the bags its gate pass pickups were
+// loaded onto it. loaded is nil when the guard has not counted yet.
+func (s *VehicleService) ExitCheck(ctx context.Context, id int, loaded *int) (*models.VehicleExitCheck, error) {
+	v, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return exitCheck(v, loaded), nil
+}
+
+func exitCheck(v *models.VehicleVisit, loaded *int) *models.VehicleExitCheck {
+	check := &models.VehicleExitCheck{Visit: v, QuantityLoaded: loaded, Warnings: []string{}}
+	for _, l := range v.Links {
+		if l.GatePassID == nil {
+			continue
+		}
+		check.ExpectedQuantity += l.PickedUp
+		if l.PickedUp == 0 {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("Nothing was picked up on gate pass #%d (thock %s)", *l.GatePassID, l.ThockNumber))
+		}
+		if l.Status == models.GatePassStatusRequested {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("Gate pass #%d is not approved yet", *l.GatePassID))
+		}
+	}
+	if loaded != nil {
+		check.Matches = *loaded == check.ExpectedQuantity
+		if !check.Matches {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("Vehicle carries %d bags but %d were picked up on its gate passes", *loaded, check.ExpectedQuantity))
+		}
+	}
+	return check
+}
+
+// Exit books a vehicle out with the bags counted on it. A count that differs from the bags
+// picked up on its gate passes is refused unless the guard accepts it with remarks.
+func (s *VehicleService) Exit(ctx context.Context, id int, req *models.VehicleExitRequest, userID int) (*models.VehicleExitCheck, error) {
+	if req.QuantityLoaded < 0 {
+		return nil, fmt.Errorf("%w: quantity_loaded cannot be negative", ErrInvalidVehicleVisit)
+	}
+	remarks := strings.TrimSpace(req.Remarks)
+
+	v, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status != models.VehicleInside {
+		return nil, repositories.ErrVehicleNotInside
+	}
+
+	check := exitCheck(v, &req.QuantityLoaded)
+	if !check.Matches {
+		if !req.AcceptMismatch {
+			return check, fmt.Errorf("%w: vehicle carries %d bags, %d were picked up", ErrVehicleQuantityMismatch, req.QuantityLoaded, check.ExpectedQuantity)
+		}
+		if remarks == "" {
+			return nil, fmt.Errorf("%w: remarks are required to let a vehicle out with a different quantity", ErrInvalidVehicleVisit)
+		}
+	}
+
+	if err := s.Repo.Exit(ctx, v, userID, req.QuantityLoaded, check.ExpectedQuantity, !check.Matches, remarks); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// ErrGatarOverfill is returned when a room entry would stack more bags in a gatar than it holds
+var ErrGatarOverfill = errors.New("gatar capacity exceeded")
+
+// Gatar overfill policies (system setting gatar_overfill_policy)
+const (
+	OverfillPolicyReject = "reject"
+	OverfillPolicyWarn   = "warn"
+)
+
+// DefaultGatarCapacity is assumed for gatars that are not in the layout
+const DefaultGatarCapacity = 200
+
+// SetSettingRepo enables the gatar_overfill_policy setting; without it overfills are rejected
+func (s *WarehouseLayoutService) SetSettingRepo(repo *repositories.SystemSettingRepository) {
+	s.SettingsRepo = repo
+}
+
+// gatarCapacities is the bag capacity of each gatar in the layout
+type gatarCapacities map[int]int
+
+func (c gatarCapacities) of(gatarNo int) int {
+	if capacity, ok := c[gatarNo]; ok {
+		return capacity
+	}
+	return DefaultGatarCapacity
+}
+
+// placement returns bags per gatar for a room entry: the recorded per-gatar quantities
+// when present, otherwise quantity spread over gateNo using the breakdown
+func (c gatarCapacities) placement(quantity int, gateNo, breakdown string, recorded map[int]int) map[int]int {
+	result := make(map[int]int)
+	if len(recorded) > 0 {
+		for g, qty := range recorded {
+			result[g] += qty
+		}
+		return result
+	}
+
+	var gatars, capacities []int
+	for _, part := range strings.Split(gateNo, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			gatars = append(gatars, n)
+			capacities = append(capacities, c.of(n))
+		}
+	}
+	for i, qty := range DistributeQuantity(quantity, capacities, parseBreakdown(breakdown)) {
+		result[gatars[i]] += qty
+	}
+	return result
+}
+
+func (s *WarehouseLayoutService) loadCapacities(ctx context.Context) (gatarCapacities, error) {
+	gatars, err := s.Repo.ListAllGatars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	capacities := make(gatarCapacities, len(gatars))
+	for _, g := range gatars {
+		capacities[g.GatarNo] = g.CapacityBags
+	}
+	return capacities, nil
+}
+
+// gatarOccupancy is the number of bags in each gatar, per room entry, from the stock ledger
+type gatarOccupancy struct {
+	capacity gatarCapacities
+	byEntry  map[int]map[int]int // room entry -> gatar -> bags stored
+	thocks   map[string][]int    // thock -> room entry IDs
+}
+
+// occupied returns the bags stored per gatar, leaving out one room entry (0 = none)
+func (o *gatarOccupancy) occupied(excludeRoomEntryID int) map[int]int {
+	totals := make(map[int]int)
+	for roomEntryID, gatars := range o.byEntry {
+		if roomEntryID == excludeRoomEntryID {
+			continue
+		}
+		for g, qty := range gatars {
+			totals[g] += qty
+		}
+	}
+	return totals
+}
+
+// loadOccupancy reads the current stock of every gatar from the ledger
+func (s *WarehouseLayoutService) loadOccupancy(ctx context.Context) (*gatarOccupancy, error) {
+	capacities, err := s.loadCapacities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.StockRepo.ListBalances(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	o := &gatarOccupancy{
+		capacity: capacities,
+		byEntry:  make(map[int]map[int]int),
+		thocks:   make(map[string][]int),
+	}
+	for _, b := range balances {
+		if o.byEntry[b.RoomEntryID] == nil {
+			o.byEntry[b.RoomEntryID] = make(map[int]int)
+			o.thocks[b.ThockNumber] = append(o.thocks[b.ThockNumber], b.RoomEntryID)
+		}
+		o.byEntry[b.RoomEntryID][b.GatarNo] += b.Quantity
+	}
+	return o, nil
+}
+
+// overfillPolicy reads gatar_overfill_policy; anything but "warn" rejects
+func (s *WarehouseLayoutService) overfillPolicy(ctx context.Context) string {
+	if s.SettingsRepo == nil {
+		return OverfillPolicyReject
+	}
+	setting, err := s.SettingsRepo.Get(ctx, "gatar_overfill_policy")
+	if err != nil || setting == nil {
+		return OverfillPolicyReject
+	}
+	if strings.EqualFold(strings.TrimSpace(setting.SettingValue), OverfillPolicyWarn) {
+		return OverfillPolicyWarn
+	}
+	return OverfillPolicyReject
+}
+
+// spaceCheck returns the capacity check for a change that adds bags to gatars; the repository
+// runs it inside the change's transaction. Overfilled gatars fail the change with
+// ErrGatarOverfill, or are put in warnings when the policy is "warn".
+func (s *WarehouseLayoutService) spaceCheck(ctx context.Context, warnings *[]string) repositories.GatarSpaceCheck {
+	policy := s.overfillPolicy(ctx)
+	return func(adding, stock, capacity map[int]int) error {
+		var problems []string
+		for _, g := range sortedGatars(adding) {
+			limit := gatarCapacities(capacity).of(g)
+			if stock[g]+adding[g] > limit {
+				problems = append(problems, fmt.Sprintf("gatar %d holds %d bags, has %d and would get %d more",
+					g, limit, stock[g], adding[g]))
+			}
+		}
+		if len(problems) == 0 {
+			return nil
+		}
+		if policy == OverfillPolicyWarn {
+			*warnings = problems
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrGatarOverfill, strings.Join(problems, "; "))
+	}
+}
+
+// freeGatar is a gatar with the bags it can still take
+type freeGatar struct {
+	gatar    *models.WarehouseGatar
+	occupied int
+	free     int
+}
+
+// SuggestPlacement proposes a contiguous run of gatars on a single floor for req.Quantity bags.
+// Floors already holding the thock come first, then floors in layout order so one room/floor
+// fills up before the next is opened. Within a floor the shortest run wins, nearest to the
+// thock's existing gatars, then lowest gatar number.
+func (s *WarehouseLayoutService) SuggestPlacement(ctx context.Context, req *models.PlacementSuggestionRequest) (*models.PlacementSuggestion, error) {
+	if req.Quantity < 1 {
+		return nil, fmt.Errorf("%w: quantity must be at least 1", ErrInvalidLayout)
+	}
+	if req.Floor != "" && req.RoomNo == "" {
+		return nil, fmt.Errorf("%w: room is required with floor", ErrInvalidLayout)
+	}
+
+	all, err := s.Repo.ListAllGatars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.loadOccupancy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gatar occupancy: %w", err)
+	}
+	occupied := o.occupied(0)
+
+	// Gatars the thock already occupies
+	thockGatars := make(map[int]bool)
+	for _, id := range o.thocks[req.ThockNumber] {
+		for g, qty := range o.byEntry[id] {
+			if qty > 0 {
+				thockGatars[g] = true
+			}
+		}
+	}
+
+	// Group free capacity by floor, keeping layout order
+	type floorKey struct{ room, floor string }
+	var floors []floorKey
+	byFloor := make(map[floorKey][]freeGatar)
+	thockFloors := make(map[floorKey]bool)
+	for _, g := range all {
+		if req.RoomNo != "" && g.RoomNo != req.RoomNo || req.Floor != "" && g.Floor != req.Floor {
+			continue
+		}
+		key := floorKey{g.RoomNo, g.Floor}
+		if _, ok := byFloor[key]; !ok {
+			floors = append(floors, key)
+		}
+		free := 0
+		if !g.IsDisabled {
+			free = max(g.CapacityBags-occupied[g.GatarNo], 0)
+		}
+		byFloor[key] = append(byFloor[key], freeGatar{gatar: g, occupied: occupied[g.GatarNo], free: free})
+		if thockGatars[g.GatarNo] {
+			thockFloors[key] = true
+		}
+	}
+	if len(floors) == 0 {
+		return nil, fmt.Errorf("%w: no gatars in the layout for this room/floor", ErrInvalidLayout)
+	}
+	sort.SliceStable(floors, func(i, j int) bool { return thockFloors[floors[i]] && !thockFloors[floors[j]] })
+
+	var best []freeGatar
+	var bestKey floorKey
+	bestFree := -1
+	for _, key := range floors {
+		if run := bestRun(byFloor[key], req.Quantity, thockGatars); run != nil {
+			return buildSuggestion(req.Quantity, key.room, key.floor, run, ""), nil
+		}
+		// Remember the floor with the largest contiguous space for a partial suggestion
+		if run, free := largestRun(byFloor[key]); free > bestFree {
+			best, bestKey, bestFree = run, key, free
+		}
+	}
+	if bestFree <= 0 {
+		return buildSuggestion(req.Quantity, "", "", nil, "No free gatars in the selected area"), nil
+	}
+	return buildSuggestion(req.Quantity, bestKey.room, bestKey.floor, best,
+		"No single floor has enough contiguous space; split the thock over more room entries"), nil
+}
+
+// bestRun returns the shortest run of consecutive usable gatars holding quantity bags,
+// preferring runs next to the thock's gatars, then the lowest gatar number
+func bestRun(gatars []freeGatar, quantity int, thockGatars map[int]bool) []freeGatar {
+	var best []freeGatar
+	bestDistance := 0
+	for start := range gatars {
+		sum := 0
+		for end := start; end < len(gatars) && gatars[end].free > 0; end++ {
+			sum += gatars[end].free
+			if sum < quantity {
+				continue
+			}
+			run := gatars[start : end+1]
+			distance := runDistance(run, thockGatars)
+			if best == nil || len(run) < len(best) || len(run) == len(best) && distance < bestDistance {
+				best, bestDistance = run, distance
+			}
+			break
+		}
+	}
+	return best
+}
+
+// runDistance is how far a run is from the nearest gatar the thock already occupies
+func runDistance(run []freeGatar, thockGatars map[int]bool) int {
+	if len(thockGatars) == 0 {
+		return 0
+	}
+	first, last := run[0].gatar.GatarNo, run[len(run)-1].gatar.GatarNo
+	distance := -1
+	for g := range thockGatars {
+		d := 0
+		if g < first {
+			d = first - g
+		} else if g > last {
+			d = g - last
+		}
+		if distance < 0 || d < distance {
+			distance = d
+		}
+	}
+	return distance
+}
+
+// largestRun returns the run of consecutive usable gatars with the most free space
+func largestRun(gatars []freeGatar) ([]freeGatar, int) {
+	var best []freeGatar
+	bestFree, start, sum := 0, 0, 0
+	for i, g := range gatars {
+		if g.free == 0 {
+			start, sum = i+1, 0
+			continue
+		}
+		sum += g.free
+		if sum > bestFree {
+			best, bestFree = gatars[start:i+1], sum
+		}
+	}
+	return best, bestFree
+}
+
+func buildSuggestion(quantity int, roomNo, floor string, run []freeGatar, message string) *models.PlacementSuggestion {
+	suggestion := &models.PlacementSuggestion{
+		Quantity: quantity,
+		RoomNo:   roomNo,
+		Floor:    floor,
+		Gatars:   []models.SuggestedGatar{},
+		Message:  message,
+	}
+	remaining := quantity
+	var gateNos, breakdown []string
+	for _, g := range run {
+		if remaining == 0 {
+			break
+		}
+		qty := min(g.free, remaining)
+		remaining -= qty
+		suggestion.Gatars = append(suggestion.Gatars, models.SuggestedGatar{
+			GatarNo:      g.gatar.GatarNo,
+			Quantity:     qty,
+			CapacityBags: g.gatar.CapacityBags,
+			Occupied:     g.occupied,
+		})
+		gateNos = append(gateNos, strconv.Itoa(g.gatar.GatarNo))
+		breakdown = append(breakdown, strconv.Itoa(qty))
+	}
+	suggestion.GateNo = strings.Join(gateNos, ", ")
+	suggestion.QuantityBreakdown = strings.Join(breakdown, ", ")
+	suggestion.Unplaced = remaining
+	return suggestion
+}
+
+// parseBreakdown parses a quantity breakdown such as "25, 24, 24, 16"
+func parseBreakdown(breakdown string) []int {
+	var values []int
+	for _, p := range strings.Split(breakdown, ",") {
+		if val, err := strconv.Atoi(strings.TrimSpace(p)); err == nil {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// DistributeQuantity distributes total bags across gatars based on breakdown.
+// capacities holds the bag capacity of each gatar, in the order the gatars were listed.
+// Logic:
+//  1. If only 1 gatar: all bags go to that gatar
+//  2. If breakdown count matches gatar count: map 1:1
+//  3. If breakdown has more items than gatars: distribute breakdown items sequentially
+//     (each gatar gets bags until its capacity, then overflow to next)
+//  4. Fallback: divide total evenly across gatars
+func DistributeQuantity(totalQty int, capacities []int, breakdown []int) []int {
+	numGatars := len(capacities)
+	result := make([]int, numGatars)
+
+	if numGatars == 0 {
+		return result
+	}
+
+	// Case 1: Single gatar - all bags go to it
+	if numGatars == 1 {
+		result[0] = totalQty
+		return result
+	}
+
+	// Case 2: Breakdown count matches gatar count - direct 1:1 mapping
+	if len(breakdown) == numGatars {
+		copy(result, breakdown)
+		return result
+	}
+
+	// Case 3: More breakdown items than gatars - fill gatars in order up to their capacity
+	if len(breakdown) > numGatars {
+		currentGatar := 0
+		currentGatarBags := 0
+
+		for _, bags := range breakdown {
+			// If current gatar would overflow, try to fit what we can
+			remainingCapacity := max(capacities[currentGatar]-currentGatarBags, 0)
+
+			if bags <= remainingCapacity || currentGatar == numGatars-1 {
+				// Fits in current gatar OR this is the last gatar (must take overflow)
+				result[currentGatar] += bags
+				currentGatarBags += bags
+			} else {
+				// Split between current and next gatar
+				result[currentGatar] += remainingCapacity
+				currentGatar++
+				result[currentGatar] += bags - remainingCapacity
+				currentGatarBags = bags - remainingCapacity
+			}
+
+			// Move to next gatar if current is at capacity
+			if currentGatarBags >= capacities[currentGatar] && currentGatar < numGatars-1 {
+				currentGatar++
+				currentGatarBags = 0
+			}
+		}
+		return result
+	}
+
+	// Case 4: Fewer breakdown items than gatars OR no breakdown - divide evenly
+	baseQty := totalQty / numGatars
+	remainder := totalQty % numGatars
+
+	for i := 0; i < numGatars; i++ {
+		result[i] = baseQty
+		// Distribute remainder across first few gatars
+		if i < remainder {
+			result[i]++
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+)
+
+// ErrInvalidLayout is returned for layout edits or placements that do not fit the layout
+var ErrInvalidLayout = errors.New("invalid warehouse layout")
+
+// WarehouseLayoutService manages rooms, floors and gatars and checks placements against them.
+// Room visualization, room entry validation and stock reports all read the layout from here.
+type WarehouseLayoutService struct {
+	Repo         *repositories.WarehouseLayoutRepository
+	StockRepo    *repositories.GatarStockRepository
+	SettingsRepo *repositories.SystemSettingRepository
+}
+
+func NewWarehouseLayoutService(repo *repositories.WarehouseLayoutRepository, stockRepo *repositories.GatarStockRepository) *WarehouseLayoutService {
+	return &WarehouseLayoutService{Repo: repo, StockRepo: stockRepo}
+}
+
+// ListRooms returns the full layout; activeOnly hides rooms taken out of use
+func (s *WarehouseLayoutService) ListRooms(ctx context.Context, activeOnly bool) ([]*models.WarehouseRoom, error) {
+	rooms, err := s.Repo.ListRooms(ctx)
+	if err != nil || !activeOnly {
+		return rooms, err
+	}
+	active := rooms[:0]
+	for _, room := range rooms {
+		if room.IsActive {
+			active = append(active, room)
+		}
+	}
+	return active, nil
+}
+
+// CreateRoom adds a room
+func (s *WarehouseLayoutService) CreateRoom(ctx context.Context, req *models.WarehouseRoomRequest) (*models.WarehouseRoom, error) {
+	roomNo := strings.TrimSpace(req.RoomNo)
+	if roomNo == "" {
+		return nil, fmt.Errorf("%w: room number is required", ErrInvalidLayout)
+	}
+	room := &models.WarehouseRoom{
+		RoomNo:    roomNo,
+		Name:      strings.TrimSpace(req.Name),
+		SortOrder: req.SortOrder,
+		IsActive:  req.IsActive == nil || *req.IsActive,
+		Notes:     req.Notes,
+		Floors:    []models.WarehouseFloor{},
+	}
+	if room.Name == "" {
+		room.Name = "Room " + roomNo
+	}
+	if err := s.Repo.CreateRoom(ctx, room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// UpdateRoom edits a room's name, order, active flag and notes; the room number cannot change
+func (s *WarehouseLayoutService) UpdateRoom(ctx context.Context, roomNo string, req *models.WarehouseRoomRequest) (*models.WarehouseRoom, error) {
+	room, err := s.Repo.GetRoom(ctx, roomNo)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name = strings.TrimSpace(req.Name); req.Name != "" {
+		room.Name = req.Name
+	}
+	room.SortOrder = req.SortOrder
+	room.Notes = req.Notes
+	if req.IsActive != nil {
+		room.IsActive = *req.IsActive
+	}
+	if err := s.Repo.UpdateRoom(ctx, room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// DeleteRoom removes a room that has never held stock; deactivate it otherwise
+func (s *WarehouseLayoutService) DeleteRoom(ctx context.Context, roomNo string) error {
+	if err := s.ensureUnused(ctx, roomNo, "", 0, 0); err != nil {
+		return err
+	}
+	return s.Repo.DeleteRoom(ctx, roomNo)
+}
+
+// CreateFloor adds a floor to a room
+func (s *WarehouseLayoutService) CreateFloor(ctx context.Context, roomNo string, req *models.WarehouseFloorRequest) (*models.WarehouseFloor, error) {
+	floorNo := strings.TrimSpace(req.Floor)
+	if floorNo == "" {
+		return nil, fmt.Errorf("%w: floor is required", ErrInvalidLayout)
+	}
+	floor := &models.WarehouseFloor{RoomNo: roomNo, Floor: floorNo, SortOrder: req.SortOrder}
+	if err := s.Repo.CreateFloor(ctx, floor); err != nil {
+		return nil, err
+	}
+	return floor, nil
+}
+
+// DeleteFloor removes a floor that has never held stock
+func (s *WarehouseLayoutService) DeleteFloor(ctx context.Context, roomNo, floor string) error {
+	if err := s.ensureUnused(ctx, roomNo, floor, 0, 0); err != nil {
+		return err
+	}
+	return s.Repo.DeleteFloor(ctx, roomNo, floor)
+}
+
+// GetFloor returns a floor with its gatars
+func (s *WarehouseLayoutService) GetFloor(ctx context.Context, roomNo, floor string) (*models.WarehouseFloor, []*models.WarehouseGatar, error) {
+	f, err := s.Repo.GetFloor(ctx, roomNo, floor)
+	if err != nil {
+		return nil, nil, err
+	}
+	gatars, err := s.Repo.ListGatars(ctx, roomNo, floor)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, gatars, nil
+}
+
+// AddGatars adds a range of gatar numbers to a floor
+func (s *WarehouseLayoutService) AddGatars(ctx context.Context, roomNo, floor string, req *models.AddWarehouseGatarsRequest) (int64, error) {
+	if err := validateGatarRange(req.FromGatar, req.ToGatar); err != nil {
+		return 0, err
+	}
+	if req.CapacityBags <= 0 {
+		return 0, fmt.Errorf("%w: capacity must be at least 1 bag", ErrInvalidLayout)
+	}
+	f, err := s.Repo.GetFloor(ctx, roomNo, floor)
+	if err != nil {
+		return 0, err
+	}
+	return s.Repo.AddGatars(ctx, f.ID, req)
+}
+
+// UpdateGatars edits capacity, dimensions or the disabled flag for a range of gatars on a floor
+func (s *WarehouseLayoutService) UpdateGatars(ctx context.Context, roomNo, floor string, req *models.UpdateWarehouseGatarsRequest) (int64, error) {
+	if err := validateGatarRange(req.FromGatar, req.ToGatar); err != nil {
+		return 0, err
+	}
+	if req.CapacityBags != nil && *req.CapacityBags < 0 {
+		return 0, fmt.Errorf("%w: capacity cannot be negative", ErrInvalidLayout)
+	}
+	f, err := s.Repo.GetFloor(ctx, roomNo, floor)
+	if err != nil {
+		return 0, err
+	}
+	return s.Repo.UpdateGatars(ctx, f.ID, req)
+}
+
+// DeleteGatars removes a range of gatars that have never held stock; disable them otherwise
+func (s *WarehouseLayoutService) DeleteGatars(ctx context.Context, roomNo, floor string, from, to int) (int64, error) {
+	if err := validateGatarRange(from, to); err != nil {
+		return 0, err
+	}
+	f, err := s.Repo.GetFloor(ctx, roomNo, floor)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ensureUnused(ctx, roomNo, floor, from, to); err != nil {
+		return 0, err
+	}
+	return s.Repo.DeleteGatars(ctx, f.ID, from, to)
+}
+
+// LocateGatar returns the layout row of a gatar number, or ErrLayoutNotFound
+func (s *WarehouseLayoutService) LocateGatar(ctx context.Context, gatarNo int) (*models.WarehouseGatar, error) {
+	gatars, err := s.Repo.GetGatars(ctx, []int{gatarNo})
+	if err != nil {
+		return nil, err
+	}
+	if len(gatars) == 0 {
+		return nil, fmt.Errorf("gatar %d: %w", gatarNo, repositories.ErrLayoutNotFound)
+	}
+	return gatars[0], nil
+}
+
+// TotalCapacity returns the bag capacity of the building (enabled gatars in active rooms)
+func (s *WarehouseLayoutService) TotalCapacity(ctx context.Context) (int, error) {
+	return s.Repo.TotalCapacity(ctx)
+}
+
+// ValidatePlacement checks that every gatar in gateNo (comma-separated) and gatars exists,
+// belongs to roomNo/floor and is usable. Gatars listed in previousGateNo were already accepted
+// for this room entry and stay valid even if the room or gatar has since been disabled.
+func (s *WarehouseLayoutService) ValidatePlacement(ctx context.Context, roomNo, floor, gateNo string, gatars []models.GatarInput, previousGateNo string) error {
+	room, err := s.Repo.GetRoom(ctx, roomNo)
+	if errors.Is(err, repositories.ErrLayoutNotFound) {
+		return fmt.Errorf("%w: room %s is not in the warehouse layout", ErrInvalidLayout, roomNo)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.Repo.GetFloor(ctx, roomNo, floor); errors.Is(err, repositories.ErrLayoutNotFound) {
+		return fmt.Errorf("%w: room %s has no floor %s", ErrInvalidLayout, roomNo, floor)
+	} else if err != nil {
+		return err
+	}
+
+	requested, err := ParseGatarList(gateNo)
+	if err != nil {
+		return err
+	}
+	for _, g := range gatars {
+		requested = append(requested, g.GatarNo)
+	}
+	previous, _ := ParseGatarList(previousGateNo)
+	accepted := make(map[int]bool, len(previous))
+	for _, g := range previous {
+		accepted[g] = true
+	}
+
+	found, err := s.Repo.GetGatars(ctx, requested)
+	if err != nil {
+		return err
+	}
+	byNo := make(map[int]*models.WarehouseGatar, len(found))
+	for _, g := range found {
+		byNo[g.GatarNo] = g
+	}
+
+	var problems []string
+	seen := make(map[int]bool, len(requested))
+	for _, no := range requested {
+		if seen[no] {
+			continue
+		}
+		seen[no] = true
+		g, ok := byNo[no]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("gatar %d is not in the warehouse layout", no))
+		case g.RoomNo != roomNo || g.Floor != floor:
+			problems = append(problems, fmt.Sprintf("gatar %d is in Room %s Floor %s", no, g.RoomNo, g.Floor))
+		case accepted[no]:
+			// Already stored here before the gatar or room was disabled
+		case !room.IsActive:
+			problems = append(problems, fmt.Sprintf("room %s is not in use", roomNo))
+		case g.IsDisabled:
+			problem := fmt.Sprintf("gatar %d is disabled", no)
+			if g.DisabledReason != "" {
+				problem += " (" + g.DisabledReason + ")"
+			}
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidLayout, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ParseGatarList parses a gatar list separated by commas and/or spaces, such as
+// "112, 114 129" (the same separators the room entry screens accept)
+func ParseGatarList(gateNo string) ([]int, error) {
+	var gatars []int
+	parts := strings.FieldsFunc(gateNo, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: invalid gatar number %q", ErrInvalidLayout, part)
+		}
+		gatars = append(gatars, n)
+	}
+	return gatars, nil
+}
+
+// ensureUnused refuses to delete layout that room entries still point at
+func (s *WarehouseLayoutService) ensureUnused(ctx context.Context, roomNo, floor string, from, to int) error {
+	count, err := s.Repo.CountRoomEntries(ctx, roomNo, floor, from, to)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d room entries are stored there; disable it instead", repositories.ErrLayoutConflict, count)
+	}
+	return nil
+}
+
+func validateGatarRange(from, to int) error {
+	if from <= 0 || to < from {
+		return fmt.Errorf("%w: gatar range %d-%d is not valid", ErrInvalidLayout, from, to)
+	}
+	if to-from >= 1000 {
+		return fmt.Errorf("%w: at most 1000 gatars can be changed at once", ErrInvalidLayout)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"cold-backend/internal/models"
+	"cold-backend/internal/repositories"
+	"cold-backend/internal/weighbridge"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidWeighment is returned for weighments that cannot be opened, weighed or linked
+var ErrInvalidWeighment = errors.New("invalid weighment")
+
+// Trucks heavier than this are taken as a typing or reader error
+const maxTruckWeightKg = 100000
+
+// WeighmentService captures gross/tare truck weights at the guard gate and at gate-pass
+// pickup, and keeps the average bag weight of each thock
+type WeighmentService struct {
+	Repo           *repositories.WeighmentRepository
+	GuardEntryRepo *repositories.GuardEntryRepository
+	GatePassRepo   *repositories.GatePassRepository
+	SettingRepo    *repositories.SystemSettingRepository
+	Simulator      *weighbridge.Simulator
+}
+
+func NewWeighmentService(repo *repositories.WeighmentRepository, guardEntryRepo *repositories.GuardEntryRepository, gatePassRepo *repositories.GatePassRepository, settingRepo *repositories.SystemSettingRepository) *WeighmentService {
+	return &WeighmentService{
+		Repo:           repo,
+		GuardEntryRepo: guardEntryRepo,
+		GatePassRepo:   gatePassRepo,
+		SettingRepo:    settingRepo,
+		Simulator:      weighbridge.NewSimulator(),
+	}
+}
+
+// Create opens an inbound weighment for a guard entry or an outbound one for a gate pass
+func (s *WeighmentService) Create(ctx context.Context, req *models.CreateWeighmentRequest, userID int) (*models.Weighment, error) {
+	if (req.GuardEntryID == nil) == (req.GatePassID == nil) {
+		return nil, fmt.Errorf("%w: give either guard_entry_id or gate_pass_id", ErrInvalidWeighment)
+	}
+	if req.BagCount < 0 {
+		return nil, fmt.Errorf("%w: bag_count cannot be negative", ErrInvalidWeighment)
+	}
+
+	w := &models.Weighment{
+		VehicleNo:       strings.ToUpper(strings.TrimSpace(req.VehicleNo)),
+		BagCount:        req.BagCount,
+		Notes:           strings.TrimSpace(req.Notes),
+		CreatedByUserID: &userID,
+	}
+
+	if req.GuardEntryID != nil {
+		guardEntry, err := s.GuardEntryRepo.Get(ctx, *req.GuardEntryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: guard entry %d not found", ErrInvalidWeighment, *req.GuardEntryID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if existing, err := s.Repo.GetByGuardEntry(ctx, guardEntry.ID); err == nil {
+			return nil, fmt.Errorf("%w: guard entry %d already has weighment %d", ErrInvalidWeighment, guardEntry.ID, existing.ID)
+		} else if !errors.Is(err, repositories.ErrWeighmentNotFound) {
+			return nil, err
+		}
+		w.Direction = models.WeighmentInbound
+		w.GuardEntryID = &guardEntry.ID
+		if w.BagCount == 0 {
+			w.BagCount = guardEntry.TotalQuantity()
+		}
+	} else {
+		gatePass, err := s.GatePassRepo.GetGatePass(ctx, *req.GatePassID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: gate pass %d not found", ErrInvalidWeighment, *req.GatePassID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if gatePass.Status != "approved" && gatePass.Status != "partially_completed" {
+			return nil, fmt.Errorf("%w: gate pass %d is %s", ErrInvalidWeighment, gatePass.ID, gatePass.Status)
+		}
+		w.Direction = models.WeighmentOutbound
+		w.GatePassID = &gatePass.ID
+		w.ThockNumber = gatePass.ThockNumber
+	}
+
+	if err := s.Repo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get returns a weighment
+func (s *WeighmentService) Get(ctx context.Context, id int) (*models.Weighment, error) {
+	return s.Repo.Get(ctx, id)
+}
+
+// GetByGuardEntry returns the inbound weighment of a guard entry
+func (s *WeighmentService) GetByGuardEntry(ctx context.Context, guardEntryID int) (*models.Weighment, error) {
+	return s.Repo.GetByGuardEntry(ctx, guardEntryID)
+}
+
+// List returns recent weighments, optionally for one direction, thock or gate pass
+func (s *WeighmentService) List(ctx context.Context, direction, thockNumber string, gatePassID int) ([]*models.Weighment, error) {
+	if direction != "" && direction != models.WeighmentInbound && direction != models.WeighmentOutbound {
+		return nil, fmt.Errorf("%w: direction must be inbound or outbound", ErrInvalidWeighment)
+	}
+	return s.Repo.List(ctx, direction, strings.TrimSpace(thockNumber), gatePassID, 500)
+}
+
+// Capture records the gross or tare weight of a weighment. A weight given in the request
+// is stored as a manual entry; otherwise the weighbridge is read.
+func (s *WeighmentService) Capture(ctx context.Context, id int, req *models.CaptureWeightRequest, userID int) (*models.Weighment, error) {
+	if req.Stage != models.WeighmentStageGross && req.Stage != models.WeighmentStageTare {
+		return nil, fmt.Errorf("%w: stage must be gross or tare", ErrInvalidWeighment)
+	}
+	if req.WeightKg < 0 || req.WeightKg > maxTruckWeightKg {
+		return nil, fmt.Errorf("%w: weight must be between 0 and %d kg", ErrInvalidWeighment, maxTruckWeightKg)
+	}
+
+	w, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	weightKg, source := req.WeightKg, models.WeightSourceManual
+	if weightKg == 0 {
+		reading, err := s.ReadWeighbridge(ctx)
+		if err != nil {
+			return nil, err
+		}
+		weightKg, source = reading.WeightKg, reading.Source
+	}
+	weightKg = math.Round(weightKg*100) / 100
+
+	// Gross is the loaded truck; it must outweigh the empty truck
+	gross, tare := w.GrossKg, w.TareKg
+	if req.Stage == models.WeighmentStageGross {
+		gross = &weightKg
+	} else {
+		tare = &weightKg
+	}
+	if gross != nil && tare != nil && *gross <= *tare {
+		return nil, fmt.Errorf("%w: gross weight %.2f kg must be more than tare weight %.2f kg", ErrInvalidWeighment, *gross, *tare)
+	}
+
+	if err := s.Repo.SetWeight(ctx, id, req.Stage, weightKg, source, userID); err != nil {
+		return nil, err
+	}
+	w, err = s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A re-weighed inbound truck updates the thocks already linked to it
+	if w.Direction == models.WeighmentInbound && w.AvgBagWeightKg != nil {
+		thocks, err := s.Repo.ListLinkedThocks(ctx, w.ID)
+		if err != nil {
+			log.Printf("[Weighment] Failed to list thocks of weighment %d: %v", w.ID, err)
+		}
+		for _, thock := range thocks {
+			if _, err := s.Repo.LinkEntry(ctx, w.ID, thock, *w.AvgBagWeightKg); err != nil {
+				log.Printf("[Weighment] Failed to update weight of thock %s: %v", thock, err)
+			}
+		}
+	}
+	return w, nil
+}
+
+// LinkThock stores an inbound weighment's average bag weight on a thock. A truck carrying
+// seed and sell bags is linked to both thocks.
+func (s *WeighmentService) LinkThock(ctx context.Context, id int, thockNumber string) (*models.Weighment, error) {
+	thockNumber = strings.TrimSpace(thockNumber)
+	if thockNumber == "" {
+		return nil, fmt.Errorf("%w: thock_number is required", ErrInvalidWeighment)
+	}
+
+	w, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w.Direction != models.WeighmentInbound {
+		return nil, fmt.Errorf("%w: only inbound weighments are linked to thocks", ErrInvalidWeighment)
+	}
+	if w.AvgBagWeightKg == nil {
+		return nil, fmt.Errorf("%w: capture gross and tare weights and the bag count first", ErrInvalidWeighment)
+	}
+
+	found, err := s.Repo.LinkEntry(ctx, w.ID, thockNumber, *w.AvgBagWeightKg)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no entry for thock %s", ErrInvalidWeighment, thockNumber)
+	}
+	return w, nil
+}
+
+// AttachPickup ties an outbound weighment to the pickup it weighed so the bags and net
+// weight count against the thock
+func (s *WeighmentService) AttachPickup(ctx context.Context, id int, pickup *models.GatePassPickup) error {
+	w, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if w.Direction != models.WeighmentOutbound || w.GatePassID == nil || *w.GatePassID != pickup.GatePassID {
+		return fmt.Errorf("%w: weighment %d is not for gate pass %d", ErrInvalidWeighment, id, pickup.GatePassID)
+	}
+	if w.GatePassPickupID != nil && *w.GatePassPickupID != pickup.ID {
+		return fmt.Errorf("%w: weighment %d already belongs to pickup %d", ErrInvalidWeighment, id, *w.GatePassPickupID)
+	}
+	return s.Repo.AttachPickup(ctx, id, pickup.ID, pickup.PickupQuantity)
+}
+
+// ThockWeights returns the inbound and outbound weights of the given thocks
+func (s *WeighmentService) ThockWeights(ctx context.Context, thockNumbers []string) ([]*models.ThockWeight, error) {
+	if len(thockNumbers) == 0 {
+		return nil, nil
+	}
+	return s.Repo.ThockWeights(ctx, thockNumbers)
+}
+
+// Mode returns the configured weighbridge reader mode
+func (s *WeighmentService) Mode(ctx context.Context) string {
+	return s.setting(ctx, "weighbridge_mode", weighbridge.ModeManual)
+}
+
+// ReadWeighbridge takes a stable reading from the configured weighbridge
+func (s *WeighmentService) ReadWeighbridge(ctx context.Context) (*weighbridge.Reading, error) {
+	baud, _ := strconv.Atoi(s.setting(ctx, "weighbridge_baud", "9600"))
+	reader, err := weighbridge.New(weighbridge.Config{
+		Mode:    s.Mode(ctx),
+		Address: s.setting(ctx, "weighbridge_address", ""),
+		Baud:    baud,
+	}, s.Simulator)
+	if err != nil {
+		return nil, err
+	}
+	reading, err := reader.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if reading.WeightKg <= 0 || reading.WeightKg > maxTruckWeightKg {
+		return nil, fmt.Errorf("weighbridge reported %.2f kg - check the platform is loaded", reading.WeightKg)
+	}
+	return reading, nil
+}
+
+// SetSimulatorWeight puts a truck on the simulated weighbridge
+func (s *WeighmentService) SetSimulatorWeight(weightKg float64) error {
+	if weightKg < 0 || weightKg > maxTruckWeightKg {
+		return fmt.Errorf("%w: weight must be between 0 and %d kg", ErrInvalidWeighment, maxTruckWeightKg)
+	}
+	s.Simulator.Set(weightKg)
+	return nil
+}
+
+func (s *WeighmentService) setting(ctx context.Context, key, fallback string) string {
+	if s.SettingRepo == nil {
+		return fallback
+	}
+	setting, err := s.SettingRepo.Get(ctx, key)
+	if err != nil || setting == nil || strings.TrimSpace(setting.SettingValue) == "" {
+		return fallback
+	}
+	return strings.TrimSpace(setting.SettingValue)
+}
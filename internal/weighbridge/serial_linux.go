@@ -0,0 +1,61 @@
+package weighbridge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// SerialReader reads an indicator wired to an RS-232/USB serial port (8N1)
+type SerialReader struct {
+	Device  string
+	Baud    int
+	Timeout time.Duration
+}
+
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+}
+
+func (s *SerialReader) Read(ctx context.Context) (*Reading, error) {
+	speed, ok := baudRates[s.Baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", s.Baud)
+	}
+
+	fd, err := unix.Open(s.Device, unix.O_RDONLY|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", s.Device, err)
+	}
+	// os.File puts the non-blocking fd on the runtime poller so read deadlines work
+	port := os.NewFile(uintptr(fd), s.Device)
+	defer port.Close()
+
+	// Raw mode, 8 data bits, no parity, one stop bit
+	tio := &unix.Termios{
+		Cflag:  unix.CS8 | unix.CREAD | unix.CLOCAL | speed,
+		Ispeed: speed,
+		Ospeed: speed,
+	}
+	tio.Cc[unix.VMIN] = 1
+	tio.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, tio); err != nil {
+		return nil, fmt.Errorf("configure %s: %w", s.Device, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	port.SetReadDeadline(deadline)
+	return readStable(ctx, port, ModeSerial)
+}
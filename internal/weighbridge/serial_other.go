@@ -0,0 +1,20 @@
+//go:build !linux
+
+package weighbridge
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// SerialReader reads an indicator wired to a serial port; only supported on Linux
+type SerialReader struct {
+	Device  string
+	Baud    int
+	Timeout time.Duration
+}
+
+func (s *SerialReader) Read(ctx context.Context) (*Reading, error) {
+	return nil, errors.New("serial weighbridge is only supported on linux - use a serial-to-ethernet converter and tcp mode")
+}
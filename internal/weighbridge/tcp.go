@@ -0,0 +1,29 @@
+package weighbridge
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// TCPReader reads an indicator behind a serial-to-Ethernet converter
+type TCPReader struct {
+	Address string
+	Timeout time.Duration
+}
+
+func (t *TCPReader) Read(ctx context.Context) (*Reading, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+	return readStable(ctx, conn, ModeTCP)
+}
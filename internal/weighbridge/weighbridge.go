@@ -0,0 +1,174 @@
+// Package weighbridge reads truck weights from a weighbridge indicator.
+//
+// Indicators stream ASCII frames continuously over RS-232 or a serial-to-Ethernet
+// converter, e.g. "ST,GS,+0012340kg" or "  12340 kg". A capture waits until several
+// consecutive frames report the same stable weight.
+package weighbridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reader modes, stored in the weighbridge_mode setting
+const (
+	ModeManual    = "manual"
+	ModeTCP       = "tcp"
+	ModeSerial    = "serial"
+	ModeSimulator = "simulator"
+)
+
+var (
+	// ErrManualMode is returned when no weighbridge reader is configured
+	ErrManualMode = errors.New("weighbridge is in manual mode - enter the weight by hand")
+	// ErrNoStableReading is returned when the weight did not settle before the timeout
+	ErrNoStableReading = errors.New("weighbridge reading did not stabilise")
+)
+
+// DefaultTimeout is how long a capture waits for a stable weight
+const DefaultTimeout = 15 * time.Second
+
+// stableFrames is how many consecutive identical stable frames make a capture
+const stableFrames = 3
+
+// Reading is one weight taken from the indicator
+type Reading struct {
+	WeightKg float64   `json:"weight_kg"`
+	Stable   bool      `json:"stable"`
+	Raw      string    `json:"raw"`
+	Source   string    `json:"source"` // tcp, serial or simulator
+	At       time.Time `json:"at"`
+}
+
+// Reader captures a stable weight from a weighbridge
+type Reader interface {
+	Read(ctx context.Context) (*Reading, error)
+}
+
+// Config selects and configures a reader
+type Config struct {
+	Mode    string
+	Address string // host:port for tcp, device path for serial
+	Baud    int
+	Timeout time.Duration
+}
+
+// New returns the reader for cfg.Mode. The simulator is shared so weights set on it
+// are seen by later captures.
+func New(cfg Config, sim *Simulator) (Reader, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	switch cfg.Mode {
+	case "", ModeManual:
+		return nil, ErrManualMode
+	case ModeTCP:
+		if cfg.Address == "" {
+			return nil, errors.New("weighbridge_address is not set")
+		}
+		return &TCPReader{Address: cfg.Address, Timeout: cfg.Timeout}, nil
+	case ModeSerial:
+		if cfg.Address == "" {
+			return nil, errors.New("weighbridge_address is not set")
+		}
+		if cfg.Baud <= 0 {
+			cfg.Baud = 9600
+		}
+		return &SerialReader{Device: cfg.Address, Baud: cfg.Baud, Timeout: cfg.Timeout}, nil
+	case ModeSimulator:
+		if sim == nil {
+			return nil, errors.New("weighbridge simulator is not available")
+		}
+		return sim, nil
+	default:
+		return nil, fmt.Errorf("unknown weighbridge mode %q", cfg.Mode)
+	}
+}
+
+var weightPattern = regexp.MustCompile(`([-+]?\s*\d+(?:\.\d+)?)\s*(kg|KG|Kg|t|T)?`)
+
+// ParseFrame parses one indicator frame. Frames marked "US" (unstable) or "OL"
+// (overload) are returned with Stable false; frames without a status are taken as stable.
+func ParseFrame(frame string) (*Reading, error) {
+	frame = strings.TrimSpace(strings.Trim(frame, "\x02\x03"))
+	if frame == "" {
+		return nil, errors.New("empty frame")
+	}
+	upper := strings.ToUpper(frame)
+
+	m := weightPattern.FindStringSubmatch(frame)
+	if m == nil {
+		return nil, fmt.Errorf("no weight in frame %q", frame)
+	}
+	weight, err := strconv.ParseFloat(strings.ReplaceAll(m[1], " ", ""), 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad weight in frame %q", frame)
+	}
+	if strings.EqualFold(m[2], "t") {
+		weight *= 1000
+	}
+
+	stable := !strings.Contains(upper, "US") && !strings.Contains(upper, "OL") && !strings.Contains(upper, "MOTION")
+	return &Reading{WeightKg: weight, Stable: stable && weight >= 0, Raw: frame, At: time.Now()}, nil
+}
+
+// readStable reads frames from r until stableFrames consecutive stable frames agree
+func readStable(ctx context.Context, r io.Reader, source string) (*Reading, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanFrames)
+
+	var last *Reading
+	count := 0
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		frame := strings.TrimSpace(scanner.Text())
+		if frame == "" {
+			continue // CR LF pairs
+		}
+		reading, err := ParseFrame(frame)
+		if err != nil || !reading.Stable {
+			count = 0
+			continue
+		}
+		if last != nil && reading.WeightKg == last.WeightKg {
+			count++
+		} else {
+			count = 1
+		}
+		last = reading
+		if count >= stableFrames {
+			last.Source = source
+			return last, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, ErrNoStableReading
+		}
+		return nil, err
+	}
+	return nil, ErrNoStableReading
+}
+
+// scanFrames splits the indicator stream on CR, LF or ETX
+func scanFrames(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\r' || b == '\n' || b == 0x03 {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,52 @@
+-- Migration 032: Add versioned rent tariffs (rate cards)
+-- Replaces the flat rent_per_item / rent_rate_per_bag settings with rate cards
+-- that can vary by thock category, variety and storage-duration slab.
+
+CREATE TABLE IF NOT EXISTS rent_tariffs (
+    id                 SERIAL PRIMARY KEY,
+    name               VARCHAR(100) NOT NULL,
+    effective_from     DATE NOT NULL,                 -- Applies to thocks stored on/after this date
+    is_active          BOOLEAN NOT NULL DEFAULT TRUE,
+    notes              TEXT,
+    created_by_user_id INT REFERENCES users(id),
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rent_tariffs_effective
+    ON rent_tariffs (effective_from DESC)
+    WHERE is_active = TRUE;
+
+CREATE TABLE IF NOT EXISTS rent_tariff_rates (
+    id             SERIAL PRIMARY KEY,
+    tariff_id      INT NOT NULL REFERENCES rent_tariffs(id) ON DELETE CASCADE,
+    thock_category VARCHAR(10) NOT NULL DEFAULT '',   -- '' = any, 'seed', 'sell'
+    variety        VARCHAR(100) NOT NULL DEFAULT '',  -- '' = any, matched against entries.remark
+    min_months     INT NOT NULL DEFAULT 0,            -- Storage-duration slab (inclusive)
+    max_months     INT,                               -- NULL = open ended
+    rate_per_bag   DECIMAL(10,2) NOT NULL,
+
+    CONSTRAINT chk_tariff_rate_category CHECK (thock_category IN ('', 'seed', 'sell')),
+    CONSTRAINT chk_tariff_rate_slab CHECK (min_months >= 0 AND (max_months IS NULL OR max_months >= min_months)),
+    CONSTRAINT chk_tariff_rate_amount CHECK (rate_per_bag >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rent_tariff_rates_tariff ON rent_tariff_rates (tariff_id);
+
+COMMENT ON TABLE rent_tariffs IS 'Versioned rent rate cards; the card in force on the storage date prices a thock';
+COMMENT ON COLUMN rent_tariff_rates.rate_per_bag IS 'Rent per bag for the whole storage period when it falls inside this slab';
+
+-- Seed a default rate card from the existing flat settings so balances do not change on upgrade
+INSERT INTO rent_tariffs (name, effective_from, notes)
+SELECT 'Default', DATE '2000-01-01', 'Migrated from rent_per_item setting'
+WHERE NOT EXISTS (SELECT 1 FROM rent_tariffs);
+
+INSERT INTO rent_tariff_rates (tariff_id, rate_per_bag)
+SELECT t.id, COALESCE(
+    (SELECT setting_value::numeric FROM system_settings
+      WHERE setting_key = 'rent_per_item' AND setting_value ~ '^[0-9]+(\.[0-9]+)?$'),
+    (SELECT setting_value::numeric FROM system_settings
+      WHERE setting_key = 'rent_rate_per_bag' AND setting_value ~ '^[0-9]+(\.[0-9]+)?$'),
+    0)
+FROM rent_tariffs t
+WHERE t.name = 'Default'
+  AND NOT EXISTS (SELECT 1 FROM rent_tariff_rates r WHERE r.tariff_id = t.id);
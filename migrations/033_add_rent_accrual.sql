@@ -0,0 +1,51 @@
+-- Migration 033: Automatic rent accrual into the ledger
+-- Rent is posted as CHARGE ledger entries by a scheduled job instead of being
+-- computed on the fly, so running_balance reflects rent actually owed.
+
+-- Idempotency key makes re-running accrual safe (never double-charges)
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(120);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_idempotency_key
+    ON ledger_entries (idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ledger_reference
+    ON ledger_entries (reference_type, reference_id);
+
+-- When a rate card accrues rent:
+--   on_storage: full slab rent is charged when bags are stored, trued up at season close
+--   monthly:    rent to date is charged at each month end
+ALTER TABLE rent_tariffs ADD COLUMN IF NOT EXISTS accrual_mode VARCHAR(20) NOT NULL DEFAULT 'on_storage';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'chk_rent_tariff_accrual_mode'
+        AND conrelid = 'rent_tariffs'::regclass
+    ) THEN
+        ALTER TABLE rent_tariffs ADD CONSTRAINT chk_rent_tariff_accrual_mode
+            CHECK (accrual_mode IN ('on_storage', 'monthly'));
+    END IF;
+END $$;
+
+-- Audit trail of accrual runs (scheduled, manual, backfill, season close)
+CREATE TABLE IF NOT EXISTS rent_accrual_runs (
+    id              SERIAL PRIMARY KEY,
+    trigger         VARCHAR(20) NOT NULL,            -- scheduled, manual, backfill, season_close
+    as_of           TIMESTAMP NOT NULL,
+    entries_checked INT NOT NULL DEFAULT 0,
+    charges_posted  INT NOT NULL DEFAULT 0,
+    amount_posted   DECIMAL(12,2) NOT NULL DEFAULT 0,
+    status          VARCHAR(20) NOT NULL DEFAULT 'running', -- running, completed, failed
+    error_message   TEXT,
+    started_by_user_id INT REFERENCES users(id),
+    started_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rent_accrual_runs_started ON rent_accrual_runs (started_at DESC);
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES ('rent_accrual_enabled', 'true', 'Post rent as CHARGE ledger entries automatically (daily job)')
+ON CONFLICT (setting_key) DO NOTHING;
@@ -0,0 +1,87 @@
+-- Migration 034: Double-entry journal behind the customer ledger
+-- Every ledger entry with an amount posts a balanced journal (two lines)
+-- against a small chart of accounts, so we can produce cash book, bank book,
+-- trial balance and P&L for the CA.
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id           SERIAL PRIMARY KEY,
+    code         VARCHAR(10) NOT NULL UNIQUE,
+    name         VARCHAR(100) NOT NULL,
+    account_type VARCHAR(20) NOT NULL,           -- asset, liability, equity, income, expense
+    is_active    BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_ledger_account_type CHECK (account_type IN ('asset', 'liability', 'equity', 'income', 'expense'))
+);
+
+INSERT INTO ledger_accounts (code, name, account_type) VALUES
+    ('1000', 'Cash in Hand', 'asset'),
+    ('1010', 'Bank (Razorpay)', 'asset'),
+    ('1100', 'Customer Receivables', 'asset'),
+    ('2100', 'Refunds', 'liability'),
+    ('4000', 'Rent Income', 'income'),
+    ('5000', 'Discounts Allowed', 'expense')
+ON CONFLICT (code) DO NOTHING;
+
+-- One journal per ledger entry
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id              SERIAL PRIMARY KEY,
+    ledger_entry_id INT NOT NULL UNIQUE REFERENCES ledger_entries(id) ON DELETE CASCADE,
+    posted_at       TIMESTAMP NOT NULL,           -- Same as ledger_entries.created_at
+    narration       TEXT,
+    customer_phone  VARCHAR(15),
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_posted_at ON journal_entries (posted_at);
+
+CREATE TABLE IF NOT EXISTS journal_lines (
+    id               SERIAL PRIMARY KEY,
+    journal_entry_id INT NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+    account_id       INT NOT NULL REFERENCES ledger_accounts(id),
+    debit            DECIMAL(12,2) NOT NULL DEFAULT 0,
+    credit           DECIMAL(12,2) NOT NULL DEFAULT 0,
+
+    CONSTRAINT chk_journal_line_amount CHECK (debit >= 0 AND credit >= 0 AND (debit = 0 OR credit = 0))
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_lines_entry ON journal_lines (journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_account ON journal_lines (account_id);
+
+-- Backfill journals for existing ledger entries
+--   CHARGE:          Dr Customer Receivables  / Cr Rent Income
+--   REFUND:          Dr Customer Receivables  / Cr Refunds
+--   PAYMENT:         Dr Cash in Hand          / Cr Customer Receivables
+--   ONLINE_PAYMENT:  Dr Bank (Razorpay)       / Cr Customer Receivables
+--   CREDIT:          Dr Discounts Allowed     / Cr Customer Receivables
+--   DEBT_APPROVAL has no amount and posts nothing
+INSERT INTO journal_entries (ledger_entry_id, posted_at, narration, customer_phone)
+SELECT le.id, le.created_at, le.description, le.customer_phone
+FROM ledger_entries le
+WHERE le.entry_type IN ('CHARGE', 'REFUND', 'PAYMENT', 'ONLINE_PAYMENT', 'CREDIT')
+  AND (le.debit > 0 OR le.credit > 0)
+ON CONFLICT (ledger_entry_id) DO NOTHING;
+
+INSERT INTO journal_lines (journal_entry_id, account_id, debit, credit)
+SELECT je.id, a.id, le.debit + le.credit, 0
+FROM journal_entries je
+JOIN ledger_entries le ON le.id = je.ledger_entry_id
+JOIN ledger_accounts a ON a.code = CASE le.entry_type
+        WHEN 'CHARGE' THEN '1100'
+        WHEN 'REFUND' THEN '1100'
+        WHEN 'PAYMENT' THEN '1000'
+        WHEN 'ONLINE_PAYMENT' THEN '1010'
+        WHEN 'CREDIT' THEN '5000'
+    END
+WHERE NOT EXISTS (SELECT 1 FROM journal_lines jl WHERE jl.journal_entry_id = je.id);
+
+INSERT INTO journal_lines (journal_entry_id, account_id, debit, credit)
+SELECT je.id, a.id, 0, le.debit + le.credit
+FROM journal_entries je
+JOIN ledger_entries le ON le.id = je.ledger_entry_id
+JOIN ledger_accounts a ON a.code = CASE le.entry_type
+        WHEN 'CHARGE' THEN '4000'
+        WHEN 'REFUND' THEN '2100'
+        ELSE '1100'
+    END
+WHERE (SELECT COUNT(*) FROM journal_lines jl WHERE jl.journal_entry_id = je.id) = 1;
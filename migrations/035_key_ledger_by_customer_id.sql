@@ -0,0 +1,43 @@
+-- Migration 035: Key ledger, rent payment and debt records by customer ID
+-- Records used to be found by customer_phone only, so changing a customer's
+-- phone or merging customers split or orphaned their history.
+-- customer_phone stays as a denormalized display column.
+
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers(id);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS original_customer_id INT;  -- Set when moved by a merge (for undo)
+ALTER TABLE rent_payments ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers(id);
+ALTER TABLE debt_requests ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers(id);
+ALTER TABLE debt_requests ADD COLUMN IF NOT EXISTS family_member_id INT REFERENCES family_members(id) ON DELETE SET NULL;
+ALTER TABLE debt_requests ADD COLUMN IF NOT EXISTS original_customer_id INT;  -- Set when moved by a merge (for undo)
+
+-- Backfill from phone (customers.phone is unique). Merged customers resolve to
+-- the customer they were merged into.
+UPDATE ledger_entries le
+SET customer_id = COALESCE(c.merged_into_customer_id, c.id)
+FROM customers c
+WHERE le.customer_id IS NULL AND c.phone = le.customer_phone;
+
+UPDATE rent_payments rp
+SET customer_id = COALESCE(c.merged_into_customer_id, c.id)
+FROM customers c
+WHERE rp.customer_id IS NULL AND c.phone = rp.customer_phone;
+
+UPDATE debt_requests dr
+SET customer_id = COALESCE(c.merged_into_customer_id, c.id)
+FROM customers c
+WHERE dr.customer_id IS NULL AND c.phone = dr.customer_phone;
+
+-- Debt requests are per thock: take the family member from the thock's entry
+UPDATE debt_requests dr
+SET family_member_id = e.family_member_id
+FROM entries e
+WHERE dr.family_member_id IS NULL
+  AND e.thock_number = dr.thock_number
+  AND e.customer_id = dr.customer_id;
+
+CREATE INDEX IF NOT EXISTS idx_ledger_customer_id ON ledger_entries(customer_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_customer_family_member ON ledger_entries(customer_id, family_member_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_original_customer_id ON ledger_entries(original_customer_id);
+CREATE INDEX IF NOT EXISTS idx_rent_payments_customer_id ON rent_payments(customer_id);
+CREATE INDEX IF NOT EXISTS idx_debt_requests_customer_id ON debt_requests(customer_id);
+CREATE INDEX IF NOT EXISTS idx_debt_requests_original_customer_id ON debt_requests(original_customer_id);
@@ -0,0 +1,67 @@
+-- Migration 036: GST tax invoices and credit notes
+-- Invoices are priced on the server, taxed as CGST+SGST (intra-state) or IGST
+-- (inter-state) and numbered per financial year without gaps.
+
+-- Customer place of supply (2-digit GST state code) and optional GSTIN
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS state_code VARCHAR(2);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS gstin VARCHAR(15);
+
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS document_type VARCHAR(20) NOT NULL DEFAULT 'TAX_INVOICE';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS financial_year VARCHAR(7);         -- e.g. 2026-27
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS sequence_number INT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'issued';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS customer_gstin VARCHAR(15);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS place_of_supply VARCHAR(2);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS company_gstin VARCHAR(15);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS taxable_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS cgst_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS cgst_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS sgst_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS sgst_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS igst_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS igst_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS original_invoice_id INT REFERENCES invoices(id);  -- Set on credit notes
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+
+-- Totals of older invoices were stored without tax
+ALTER TABLE invoices ALTER COLUMN total_amount TYPE NUMERIC(12,2);
+UPDATE invoices SET taxable_amount = total_amount WHERE taxable_amount = 0 AND total_amount <> 0;
+
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS hsn_sac VARCHAR(8);
+
+DO $$
+BEGIN
+    ALTER TABLE invoices ADD CONSTRAINT chk_invoice_document_type CHECK (document_type IN ('TAX_INVOICE', 'CREDIT_NOTE'));
+    ALTER TABLE invoices ADD CONSTRAINT chk_invoice_status CHECK (status IN ('issued', 'cancelled'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+-- One number per document type and financial year
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_fy_sequence
+    ON invoices (document_type, financial_year, sequence_number)
+    WHERE sequence_number IS NOT NULL;
+-- At most one credit note per invoice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_original_invoice
+    ON invoices (original_invoice_id)
+    WHERE original_invoice_id IS NOT NULL;
+
+-- Gapless counters: the row is locked and incremented inside the invoice's transaction,
+-- so a rolled-back invoice never consumes a number
+CREATE TABLE IF NOT EXISTS invoice_number_series (
+    document_type  VARCHAR(20) NOT NULL,
+    financial_year VARCHAR(7) NOT NULL,
+    last_number    INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (document_type, financial_year)
+);
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('company_name', 'Cold Storage', 'Legal name printed on tax invoices'),
+    ('company_address', '', 'Address printed on tax invoices'),
+    ('company_gstin', '', 'Company GSTIN printed on tax invoices'),
+    ('company_state_code', '', 'Company GST state code (2 digits); taken from GSTIN when empty'),
+    ('invoice_sac_code', '996721', 'SAC code for cold storage rent on tax invoices'),
+    ('invoice_gst_rate', '0', 'GST rate percent on storage rent (0 = exempt)')
+ON CONFLICT (setting_key) DO NOTHING;
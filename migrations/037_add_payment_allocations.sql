@@ -0,0 +1,23 @@
+-- Migration 037: Split rent payments across thocks
+-- One receipt can now settle several entries (thocks), across family members.
+-- Allocations are made oldest-first automatically or entered manually.
+-- Payments without allocations (older receipts, online payments, discounts)
+-- are applied oldest-first when per-thock balances are computed.
+
+CREATE TABLE IF NOT EXISTS payment_allocations (
+    id                   SERIAL PRIMARY KEY,
+    rent_payment_id      INT NOT NULL REFERENCES rent_payments(id) ON DELETE CASCADE,
+    entry_id             INT NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+    customer_id          INT REFERENCES customers(id),
+    thock_number         VARCHAR(100) NOT NULL,
+    family_member_id     INT REFERENCES family_members(id) ON DELETE SET NULL,
+    amount               DECIMAL(12,2) NOT NULL,
+    allocated_by_user_id INT REFERENCES users(id),
+    created_at           TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_payment_allocation_amount CHECK (amount > 0),
+    CONSTRAINT uq_payment_allocation UNIQUE (rent_payment_id, entry_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_allocations_entry ON payment_allocations (entry_id);
+CREATE INDEX IF NOT EXISTS idx_payment_allocations_customer ON payment_allocations (customer_id);
@@ -0,0 +1,59 @@
+-- Migration 038: Late-payment interest and early-payment discount policies
+-- A daily job evaluates active policies against each thock's outstanding rent
+-- and posts the result to the ledger:
+--   interest: rate_percent of the thock's outstanding at the start of every month
+--             it is overdue is charged (CHARGE). A thock falls due grace_days after
+--             effective_from (the due date) or its storage date, whichever is later;
+--             interest stops at effective_to (open ended when NULL)
+--   discount: rate_percent of the thock's rent is credited (CREDIT) once, when the
+--             thock is fully paid between effective_from and effective_to
+-- Postings are keyed "interest:{policy}:{scope}:{month}" / "discount:{policy}:{scope}"
+-- so re-running never posts twice.
+
+CREATE TABLE IF NOT EXISTS rent_adjustment_policies (
+    id                 SERIAL PRIMARY KEY,
+    name               VARCHAR(100) NOT NULL,
+    policy_type        VARCHAR(20) NOT NULL,              -- interest, discount
+    thock_category     VARCHAR(10) NOT NULL DEFAULT '',   -- '' = any, 'seed', 'sell'
+    rate_percent       DECIMAL(6,2) NOT NULL,
+    effective_from     DATE NOT NULL,
+    effective_to       DATE,
+    cap_percent        DECIMAL(6,2),                      -- Max total per thock as % of its rent
+    cap_amount         DECIMAL(12,2),                     -- Max total per thock in rupees
+    grace_days         INT NOT NULL DEFAULT 0,            -- Interest: days after the due date before a thock is overdue
+    is_active          BOOLEAN NOT NULL DEFAULT TRUE,
+    notes              TEXT,
+    created_by_user_id INT REFERENCES users(id),
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_rent_adjustment_type CHECK (policy_type IN ('interest', 'discount')),
+    CONSTRAINT chk_rent_adjustment_category CHECK (thock_category IN ('', 'seed', 'sell')),
+    CONSTRAINT chk_rent_adjustment_rate CHECK (rate_percent > 0 AND rate_percent <= 100),
+    CONSTRAINT chk_rent_adjustment_window CHECK (effective_to IS NULL OR effective_to >= effective_from),
+    CONSTRAINT chk_rent_adjustment_caps CHECK ((cap_percent IS NULL OR cap_percent > 0) AND (cap_amount IS NULL OR cap_amount > 0)),
+    CONSTRAINT chk_rent_adjustment_grace CHECK (grace_days >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rent_adjustment_policies_active
+    ON rent_adjustment_policies (policy_type)
+    WHERE is_active = TRUE;
+
+-- Posting runs; the daily job checks the last scheduled one so a restart does not run it twice
+CREATE TABLE IF NOT EXISTS rent_adjustment_runs (
+    id                 SERIAL PRIMARY KEY,
+    trigger            VARCHAR(20) NOT NULL,              -- scheduled, manual
+    as_of              TIMESTAMP NOT NULL,
+    thocks_checked     INT NOT NULL DEFAULT 0,
+    postings           INT NOT NULL DEFAULT 0,
+    interest_total     DECIMAL(12,2) NOT NULL DEFAULT 0,
+    discount_total     DECIMAL(12,2) NOT NULL DEFAULT 0,
+    started_by_user_id INT REFERENCES users(id),
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rent_adjustment_runs_trigger ON rent_adjustment_runs (trigger, as_of DESC);
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES ('rent_adjustments_enabled', 'true', 'Post interest and discount policies to the ledger automatically (daily job)')
+ON CONFLICT (setting_key) DO NOTHING;
@@ -0,0 +1,22 @@
+-- Migration 039: Tally export ledger names
+-- The Tally XML and CSV journal exports post against these ledger names,
+-- which must match the ledgers in the accountant's Tally company.
+-- Party ledgers are named from tally_party_ledger_format ({name}, {phone}, {so}).
+-- Late-payment interest gets its own account so the journal and the export agree.
+
+INSERT INTO ledger_accounts (code, name, account_type) VALUES
+    ('4100', 'Interest Income', 'income')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('tally_company_name', '', 'Tally company to import into (defaults to company_name)'),
+    ('tally_ledger_cash', 'Cash', 'Tally ledger for cash receipts'),
+    ('tally_ledger_bank', 'Bank', 'Tally ledger for online (Razorpay) receipts'),
+    ('tally_ledger_rent_income', 'Cold Storage Rent', 'Tally income ledger for rent charges'),
+    ('tally_ledger_interest_income', 'Interest on Late Payment', 'Tally income ledger for late-payment interest'),
+    ('tally_ledger_discount', 'Discount Allowed', 'Tally expense ledger for credits and discounts'),
+    ('tally_ledger_refunds', 'Refunds', 'Tally ledger for refunds due to customers'),
+    ('tally_debtors_group', 'Sundry Debtors', 'Tally group for customer (party) ledgers'),
+    ('tally_party_ledger_format', '{name} ({phone})', 'Tally party ledger name; placeholders {name}, {phone}, {so}')
+ON CONFLICT (setting_key) DO NOTHING;
@@ -0,0 +1,71 @@
+-- Migration 040: Bank statement import and reconciliation
+-- Bank statements (CSV/XLSX) are imported line by line. Credits are matched
+-- against rent payments and Razorpay transactions by UTR, amount and date.
+-- Whatever stays unmatched forms the reconciliation queue, where the
+-- accountant links it to an existing record, posts a ledger entry for it or
+-- ignores it (bank interest, transfers between own accounts, ...).
+
+CREATE TABLE IF NOT EXISTS bank_statement_imports (
+    id                  SERIAL PRIMARY KEY,
+    file_name           VARCHAR(255) NOT NULL,
+    bank_account        VARCHAR(100) NOT NULL DEFAULT '',
+    statement_from      DATE,
+    statement_to        DATE,
+    line_count          INT NOT NULL DEFAULT 0,
+    duplicate_count     INT NOT NULL DEFAULT 0,
+    imported_by_user_id INT REFERENCES users(id),
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS bank_statement_lines (
+    id                    SERIAL PRIMARY KEY,
+    import_id             INT NOT NULL REFERENCES bank_statement_imports(id) ON DELETE CASCADE,
+    line_number           INT NOT NULL,
+    txn_date              DATE NOT NULL,
+    description           TEXT NOT NULL DEFAULT '',
+    reference             VARCHAR(100) NOT NULL DEFAULT '',
+    utr                   VARCHAR(50) NOT NULL DEFAULT '',
+    amount                DECIMAL(12,2) NOT NULL,
+    balance               DECIMAL(14,2),
+    -- unmatched | matched (automatic) | linked (by accountant) | posted (ledger entry created) | ignored
+    status                VARCHAR(20) NOT NULL DEFAULT 'unmatched',
+    match_reason          VARCHAR(100) NOT NULL DEFAULT '',
+    rent_payment_id       INT REFERENCES rent_payments(id) ON DELETE SET NULL,
+    online_transaction_id INT REFERENCES online_transactions(id) ON DELETE SET NULL,
+    ledger_entry_id       INT REFERENCES ledger_entries(id) ON DELETE SET NULL,
+    resolved_by_user_id   INT REFERENCES users(id),
+    resolved_at           TIMESTAMP,
+    notes                 TEXT NOT NULL DEFAULT '',
+    -- Same bank line imported again (overlapping statements) is skipped
+    line_hash             VARCHAR(64) NOT NULL,
+    created_at            TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_bank_line_amount CHECK (amount > 0),
+    CONSTRAINT chk_bank_line_status CHECK (status IN ('unmatched', 'matched', 'linked', 'posted', 'ignored')),
+    CONSTRAINT uq_bank_line_hash UNIQUE (line_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_lines_status ON bank_statement_lines (status, txn_date);
+CREATE INDEX IF NOT EXISTS idx_bank_lines_import ON bank_statement_lines (import_id);
+CREATE INDEX IF NOT EXISTS idx_bank_lines_utr ON bank_statement_lines (utr) WHERE utr <> '';
+-- A record can settle only one bank line
+CREATE UNIQUE INDEX IF NOT EXISTS uq_bank_lines_rent_payment ON bank_statement_lines (rent_payment_id) WHERE rent_payment_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_bank_lines_online_tx ON bank_statement_lines (online_transaction_id) WHERE online_transaction_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_bank_lines_ledger_entry ON bank_statement_lines (ledger_entry_id) WHERE ledger_entry_id IS NOT NULL;
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES ('bank_reconciliation_date_window_days', '3', 'Days between a payment and its bank credit still considered a match')
+ON CONFLICT (setting_key) DO NOTHING;
+
+-- Credits posted from the queue (cash deposits, direct UPI and NEFT transfers)
+-- go to their own bank account, keeping Bank (Razorpay) to gateway receipts.
+--   ONLINE_PAYMENT (bank statement): Dr Bank Account / Cr Customer Receivables
+INSERT INTO ledger_accounts (code, name, account_type) VALUES
+    ('1020', 'Bank Account', 'asset')
+ON CONFLICT (code) DO NOTHING;
+
+-- Defaults to the existing bank ledger, where Razorpay settlements land as well
+INSERT INTO system_settings (setting_key, setting_value, description)
+SELECT 'tally_ledger_bank_account', COALESCE((SELECT setting_value FROM system_settings WHERE setting_key = 'tally_ledger_bank'), 'Bank'),
+       'Tally ledger for bank deposits and transfers posted from bank reconciliation'
+ON CONFLICT (setting_key) DO NOTHING;
@@ -0,0 +1,76 @@
+-- Migration 041: Warehouse layout (rooms, floors, gatars)
+-- Replaces the gatar ranges that were hardcoded in the room visualization
+-- handler. Gatar numbers are unique across the building, so a gatar number
+-- alone identifies its room and floor. Capacity is in bags; disabled gatars
+-- (pillars, damaged racks, aisles) are shown but cannot receive stock.
+
+CREATE TABLE IF NOT EXISTS warehouse_rooms (
+    id          SERIAL PRIMARY KEY,
+    room_no     VARCHAR(10) NOT NULL UNIQUE,
+    name        VARCHAR(100) NOT NULL DEFAULT '',
+    sort_order  INT NOT NULL DEFAULT 0,
+    is_active   BOOLEAN NOT NULL DEFAULT TRUE,
+    notes       TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS warehouse_floors (
+    id          SERIAL PRIMARY KEY,
+    room_id     INT NOT NULL REFERENCES warehouse_rooms(id) ON DELETE CASCADE,
+    floor       VARCHAR(10) NOT NULL,
+    sort_order  INT NOT NULL DEFAULT 0,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (room_id, floor)
+);
+
+CREATE TABLE IF NOT EXISTS warehouse_gatars (
+    id              SERIAL PRIMARY KEY,
+    floor_id        INT NOT NULL REFERENCES warehouse_floors(id) ON DELETE CASCADE,
+    gatar_no        INT NOT NULL UNIQUE,
+    capacity_bags   INT NOT NULL DEFAULT 200 CHECK (capacity_bags >= 0),
+    length_ft       DECIMAL(6,2),
+    width_ft        DECIMAL(6,2),
+    height_ft       DECIMAL(6,2),
+    is_disabled     BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled_reason VARCHAR(255) NOT NULL DEFAULT '',
+    notes           TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_gatars_floor ON warehouse_gatars(floor_id, gatar_no);
+
+-- Seed the current building at 200 bags per gatar, the usual stacking density
+-- (DefaultGatarCapacity in the services); adjust individual gatars from the
+-- layout screen.
+INSERT INTO warehouse_rooms (room_no, name, sort_order) VALUES
+    ('1', 'Room 1', 1),
+    ('2', 'Room 2', 2),
+    ('3', 'Room 3', 3),
+    ('4', 'Room 4', 4),
+    ('G', 'Gallery', 5)
+ON CONFLICT (room_no) DO NOTHING;
+
+INSERT INTO warehouse_floors (room_id, floor, sort_order)
+SELECT r.id, f::text, f
+FROM warehouse_rooms r
+CROSS JOIN generate_series(0, 4) AS f
+WHERE r.room_no IN ('1', '2', '3', '4', 'G')
+ON CONFLICT (room_id, floor) DO NOTHING;
+
+INSERT INTO warehouse_gatars (floor_id, gatar_no, capacity_bags)
+SELECT wf.id, g, 200
+FROM (VALUES
+    ('1', '0', 1, 140), ('1', '1', 141, 280), ('1', '2', 281, 420), ('1', '3', 421, 560), ('1', '4', 561, 680),
+    ('2', '0', 681, 820), ('2', '1', 821, 960), ('2', '2', 961, 1100), ('2', '3', 1101, 1240), ('2', '4', 1241, 1360),
+    ('3', '0', 1361, 1500), ('3', '1', 1501, 1640), ('3', '2', 1641, 1780), ('3', '3', 1781, 1920), ('3', '4', 1921, 2040),
+    -- Room 4 floor 0 is split: 80 gatars at 2041-2120 and 60 at 2541-2600
+    ('4', '0', 2041, 2120), ('4', '0', 2541, 2600),
+    ('4', '1', 2121, 2260), ('4', '2', 2261, 2400), ('4', '3', 2401, 2540), ('4', '4', 2601, 2720),
+    ('G', '0', 2727, 2756), ('G', '1', 2757, 2784), ('G', '2', 2785, 2812), ('G', '3', 2813, 2840), ('G', '4', 2841, 2868)
+) AS v(room_no, floor, start_no, end_no)
+JOIN warehouse_rooms wr ON wr.room_no = v.room_no
+JOIN warehouse_floors wf ON wf.room_id = wr.id AND wf.floor = v.floor
+CROSS JOIN LATERAL generate_series(v.start_no, v.end_no) AS g
+ON CONFLICT (gatar_no) DO NOTHING;
@@ -0,0 +1,9 @@
+-- Migration 042: Gatar capacity policy for room entry
+-- reject: room entries that overfill a gatar are refused
+-- warn:   room entries are saved and the overfilled gatars are reported back
+-- Starts as warn so entries are not refused before the seeded capacities have
+-- been checked against the building; switch to reject from the settings page.
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES ('gatar_overfill_policy', 'warn', 'What to do when a room entry overfills a gatar: reject or warn')
+ON CONFLICT (setting_key) DO NOTHING;
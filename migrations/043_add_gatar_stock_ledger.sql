@@ -0,0 +1,202 @@
+-- Migration 043: Per-gatar stock ledger
+-- Every bag that enters or leaves a gatar is a row here: room entries (in),
+-- room entry corrections (edit), gate pass pickups (out) and internal moves
+-- (transfer_in / transfer_out). The balance of a gatar is the sum of its rows,
+-- so per-gatar stock no longer has to be derived from quantity_breakdown
+-- strings. Existing stock is converted at the end of this migration (legacy_in
+-- / legacy_out rows).
+
+CREATE TABLE IF NOT EXISTS gatar_stock_movements (
+    id                 BIGSERIAL PRIMARY KEY,
+    room_entry_id      INT NOT NULL REFERENCES room_entries(id) ON DELETE CASCADE,
+    thock_number       VARCHAR(50) NOT NULL,
+    room_no            VARCHAR(10) NOT NULL,
+    floor              VARCHAR(10) NOT NULL,
+    gatar_no           INT NOT NULL,
+    quantity           INT NOT NULL CHECK (quantity <> 0), -- Positive into the gatar, negative out of it
+    -- in | edit | out | transfer_in | transfer_out | legacy_in | legacy_out
+    movement_type      VARCHAR(20) NOT NULL,
+    source_type        VARCHAR(30) NOT NULL, -- room_entry | gate_pass_pickup | stock_transfer
+    source_id          INT NOT NULL,
+    quality            VARCHAR(10) NOT NULL DEFAULT '',
+    notes              TEXT NOT NULL DEFAULT '',
+    created_by_user_id INT REFERENCES users(id),
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gatar_stock_gatar ON gatar_stock_movements (gatar_no);
+CREATE INDEX IF NOT EXISTS idx_gatar_stock_room_entry ON gatar_stock_movements (room_entry_id, gatar_no);
+CREATE INDEX IF NOT EXISTS idx_gatar_stock_thock ON gatar_stock_movements (thock_number);
+CREATE INDEX IF NOT EXISTS idx_gatar_stock_location ON gatar_stock_movements (room_no, floor, gatar_no);
+CREATE INDEX IF NOT EXISTS idx_gatar_stock_source ON gatar_stock_movements (source_type, source_id);
+
+-- Convert stock recorded before the ledger existed. Room entries are placed
+-- from their per-gatar quantities, otherwise their quantity is spread over the
+-- listed gatars using the quantity breakdown (the rules of DistributeQuantity,
+-- with 200 bags for gatars outside the layout). Pickups, oldest first, are
+-- taken from the gatars they recorded, then from the pickup's room/floor in
+-- gatar order, then from the thock's other gatars.
+DO $$
+DECLARE
+    v_entry     RECORD;
+    v_pickup    RECORD;
+    v_balance   RECORD;
+    v_gatars    INT[];
+    v_caps      INT[];
+    v_parts     INT[];
+    v_result    INT[];
+    v_count     INT;
+    v_current   INT;
+    v_held      INT;
+    v_room_left INT;
+    v_bags      INT;
+    v_remaining INT;
+    v_wanted    INT;
+    v_take      INT;
+BEGIN
+    INSERT INTO gatar_stock_movements (room_entry_id, thock_number, room_no, floor, gatar_no, quantity,
+                                       movement_type, source_type, source_id, notes)
+    SELECT re.id, re.thock_number, re.room_no, re.floor, g.gatar_no, g.quantity,
+           'legacy_in', 'room_entry', re.id, 'Converted from room entry'
+    FROM room_entries re
+    LEFT JOIN entries e ON e.id = re.entry_id
+    JOIN (SELECT room_entry_id, gatar_no, SUM(quantity) AS quantity
+          FROM room_entry_gatars
+          WHERE quantity > 0
+          GROUP BY room_entry_id, gatar_no) g ON g.room_entry_id = re.id
+    WHERE COALESCE(e.status, 'active') != 'deleted'
+      AND NOT EXISTS (SELECT 1 FROM gatar_stock_movements m WHERE m.room_entry_id = re.id)
+    ORDER BY re.id, g.gatar_no;
+
+    FOR v_entry IN
+        SELECT re.id, re.thock_number, re.room_no, re.floor, re.gate_no, re.quantity,
+               COALESCE(re.quantity_breakdown, '') AS breakdown
+        FROM room_entries re
+        LEFT JOIN entries e ON e.id = re.entry_id
+        WHERE COALESCE(e.status, 'active') != 'deleted'
+          AND NOT EXISTS (SELECT 1 FROM gatar_stock_movements m WHERE m.room_entry_id = re.id)
+        ORDER BY re.id
+    LOOP
+        SELECT COALESCE(array_agg(t.gatar_no ORDER BY t.ord), '{}'),
+               COALESCE(array_agg(COALESCE(wg.capacity_bags, 200) ORDER BY t.ord), '{}')
+        INTO v_gatars, v_caps
+        FROM (SELECT CASE WHEN btrim(x) ~ '^[0-9]{1,9}$' THEN btrim(x)::INT END AS gatar_no, ord
+              FROM regexp_split_to_table(v_entry.gate_no, ',') WITH ORDINALITY AS s(x, ord)) t
+        LEFT JOIN warehouse_gatars wg ON wg.gatar_no = t.gatar_no
+        WHERE t.gatar_no IS NOT NULL;
+
+        SELECT COALESCE(array_agg(t.bags ORDER BY t.ord), '{}')
+        INTO v_parts
+        FROM (SELECT CASE WHEN btrim(x) ~ '^[0-9]{1,9}$' THEN btrim(x)::INT END AS bags, ord
+              FROM regexp_split_to_table(v_entry.breakdown, ',') WITH ORDINALITY AS s(x, ord)) t
+        WHERE t.bags IS NOT NULL;
+
+        v_count := COALESCE(array_length(v_gatars, 1), 0);
+        CONTINUE WHEN v_count = 0;
+        v_result := array_fill(0, ARRAY[v_count]);
+
+        IF v_count = 1 THEN
+            v_result[1] := v_entry.quantity;
+        ELSIF COALESCE(array_length(v_parts, 1), 0) = v_count THEN
+            v_result := v_parts;
+        ELSIF COALESCE(array_length(v_parts, 1), 0) > v_count THEN
+            -- Fill the gatars in order up to their capacity
+            v_current := 1;
+            v_held := 0;
+            FOREACH v_bags IN ARRAY v_parts LOOP
+                v_room_left := GREATEST(v_caps[v_current] - v_held, 0);
+                IF v_bags <= v_room_left OR v_current = v_count THEN
+                    v_result[v_current] := v_result[v_current] + v_bags;
+                    v_held := v_held + v_bags;
+                ELSE
+                    v_result[v_current] := v_result[v_current] + v_room_left;
+                    v_current := v_current + 1;
+                    v_result[v_current] := v_result[v_current] + v_bags - v_room_left;
+                    v_held := v_bags - v_room_left;
+                END IF;
+                IF v_held >= v_caps[v_current] AND v_current < v_count THEN
+                    v_current := v_current + 1;
+                    v_held := 0;
+                END IF;
+            END LOOP;
+        ELSE
+            FOR i IN 1..v_count LOOP
+                v_result[i] := v_entry.quantity / v_count + CASE WHEN i <= v_entry.quantity % v_count THEN 1 ELSE 0 END;
+            END LOOP;
+        END IF;
+
+        INSERT INTO gatar_stock_movements (room_entry_id, thock_number, room_no, floor, gatar_no, quantity,
+                                           movement_type, source_type, source_id, notes)
+        SELECT v_entry.id, v_entry.thock_number, v_entry.room_no, v_entry.floor, u.gatar_no, SUM(u.bags),
+               'legacy_in', 'room_entry', v_entry.id, 'Converted from room entry'
+        FROM unnest(v_gatars, v_result) AS u(gatar_no, bags)
+        GROUP BY u.gatar_no
+        HAVING SUM(u.bags) > 0
+        ORDER BY u.gatar_no;
+    END LOOP;
+
+    CREATE TEMP TABLE legacy_pickup_take (
+        room_entry_id INT,
+        room_no       VARCHAR(10),
+        floor         VARCHAR(10),
+        gatar_no      INT,
+        quantity      INT
+    ) ON COMMIT DROP;
+
+    FOR v_pickup IN
+        SELECT p.id, gp.thock_number, COALESCE(p.room_no, '') AS room_no, COALESCE(p.floor, '') AS floor,
+               p.pickup_quantity, p.picked_up_by_user_id
+        FROM gate_pass_pickups p
+        JOIN gate_passes gp ON gp.id = p.gate_pass_id
+        WHERE NOT EXISTS (SELECT 1 FROM gatar_stock_movements m
+                          WHERE m.source_type = 'gate_pass_pickup' AND m.source_id = p.id)
+        ORDER BY p.pickup_time, p.id
+    LOOP
+        DELETE FROM legacy_pickup_take;
+        v_remaining := v_pickup.pickup_quantity;
+
+        -- First pass: the gatars the pickup recorded; second pass: whatever is left
+        FOR v_pass IN 1..2 LOOP
+            FOR v_balance IN
+                SELECT m.room_entry_id, m.room_no, m.floor, m.gatar_no, SUM(m.quantity) AS quantity
+                FROM gatar_stock_movements m
+                JOIN room_entries re ON re.id = m.room_entry_id
+                LEFT JOIN entries e ON e.id = re.entry_id
+                WHERE m.thock_number = v_pickup.thock_number
+                  AND COALESCE(e.status, 'active') != 'deleted'
+                GROUP BY m.room_entry_id, m.room_no, m.floor, m.gatar_no
+                HAVING SUM(m.quantity) > 0
+                ORDER BY (m.room_no = v_pickup.room_no AND m.floor = v_pickup.floor) DESC, m.gatar_no, m.room_entry_id
+            LOOP
+                EXIT WHEN v_remaining = 0;
+                SELECT v_balance.quantity - COALESCE(SUM(t.quantity), 0) INTO v_take
+                FROM legacy_pickup_take t
+                WHERE t.room_entry_id = v_balance.room_entry_id AND t.room_no = v_balance.room_no
+                  AND t.floor = v_balance.floor AND t.gatar_no = v_balance.gatar_no;
+                IF v_pass = 1 THEN
+                    SELECT COALESCE(SUM(g.quantity), 0)
+                           - COALESCE((SELECT SUM(t.quantity) FROM legacy_pickup_take t
+                                       WHERE t.gatar_no = v_balance.gatar_no), 0)
+                    INTO v_wanted
+                    FROM gate_pass_pickup_gatars g
+                    WHERE g.pickup_id = v_pickup.id AND g.gatar_no = v_balance.gatar_no;
+                    v_take := LEAST(v_take, v_wanted);
+                END IF;
+                v_take := LEAST(v_take, v_remaining);
+                IF v_take > 0 THEN
+                    INSERT INTO legacy_pickup_take
+                    VALUES (v_balance.room_entry_id, v_balance.room_no, v_balance.floor, v_balance.gatar_no, v_take);
+                    v_remaining := v_remaining - v_take;
+                END IF;
+            END LOOP;
+        END LOOP;
+
+        INSERT INTO gatar_stock_movements (room_entry_id, thock_number, room_no, floor, gatar_no, quantity,
+                                           movement_type, source_type, source_id, notes, created_by_user_id)
+        SELECT t.room_entry_id, v_pickup.thock_number, t.room_no, t.floor, t.gatar_no, -SUM(t.quantity),
+               'legacy_out', 'gate_pass_pickup', v_pickup.id, 'Converted from pickup', v_pickup.picked_up_by_user_id
+        FROM legacy_pickup_take t
+        GROUP BY t.room_entry_id, t.room_no, t.floor, t.gatar_no;
+    END LOOP;
+END
+$$;
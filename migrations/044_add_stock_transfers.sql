@@ -0,0 +1,22 @@
+-- Migration 044: Internal stock transfers
+-- Bags moved between gatars (and floors/rooms) mid-season. Each transfer posts a
+-- transfer_out and transfer_in pair to gatar_stock_movements with
+-- source_type = 'stock_transfer'; this table keeps who moved what and why.
+
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    id               SERIAL PRIMARY KEY,
+    thock_number     VARCHAR(50) NOT NULL,
+    from_room_no     VARCHAR(10) NOT NULL,
+    from_floor       VARCHAR(10) NOT NULL,
+    from_gatar       INT NOT NULL,
+    to_room_no       VARCHAR(10) NOT NULL,
+    to_floor         VARCHAR(10) NOT NULL,
+    to_gatar         INT NOT NULL,
+    quantity         INT NOT NULL CHECK (quantity > 0),
+    reason           TEXT NOT NULL DEFAULT '',
+    moved_by_user_id INT REFERENCES users(id),
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_thock ON stock_transfers (thock_number, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_created ON stock_transfers (created_at DESC);
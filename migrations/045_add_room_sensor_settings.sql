@@ -0,0 +1,8 @@
+-- Migration 045: Cold-room sensor settings
+-- Readings themselves live in the TimescaleDB metrics database (timescaledb/002)
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('sensor_ingest_token', '', 'Shared secret sensors send in the X-Sensor-Token header; empty disables token ingestion'),
+    ('sensor_alert_phones', '', 'Comma-separated phone numbers paged by SMS about room sensor alerts (room temperature from the warning threshold, other readings when critical); empty pages the active admins')
+ON CONFLICT (setting_key) DO NOTHING;
@@ -0,0 +1,42 @@
+-- Migration 046: Quality inspections per thock/gatar
+-- Periodic checks of stored stock (sprouting, rot, sample weight). Photos are stored as
+-- room_entry_media rows with media_type 'inspection' so they go through the normal media sync.
+
+CREATE TABLE IF NOT EXISTS quality_inspections (
+    id SERIAL PRIMARY KEY,
+    room_entry_id INTEGER NOT NULL REFERENCES room_entries(id) ON DELETE CASCADE,
+    thock_number VARCHAR(100) NOT NULL,
+    room_no VARCHAR(20) NOT NULL,
+    floor VARCHAR(20) NOT NULL,
+    gatar_no INTEGER,                       -- NULL when the whole thock was inspected
+    inspected_on DATE NOT NULL DEFAULT CURRENT_DATE,
+    inspector_user_id INTEGER REFERENCES users(id),
+    inspector_name VARCHAR(100) NOT NULL DEFAULT '',
+    sprouting_pct NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (sprouting_pct BETWEEN 0 AND 100),
+    rot_pct NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (rot_pct BETWEEN 0 AND 100),
+    sample_bags INTEGER NOT NULL DEFAULT 0 CHECK (sample_bags >= 0),
+    sample_weight_kg NUMERIC(10,2) NOT NULL DEFAULT 0 CHECK (sample_weight_kg >= 0),
+    condition VARCHAR(20) NOT NULL CHECK (condition IN ('good', 'fair', 'deteriorating')),
+    notes TEXT NOT NULL DEFAULT '',
+    owner_notified_at TIMESTAMP,
+    owner_notified_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quality_inspections_thock ON quality_inspections(thock_number, inspected_on DESC);
+CREATE INDEX IF NOT EXISTS idx_quality_inspections_room_entry ON quality_inspections(room_entry_id);
+CREATE INDEX IF NOT EXISTS idx_quality_inspections_condition ON quality_inspections(condition, inspected_on DESC);
+
+-- Inspection photos reuse room entry media
+ALTER TABLE room_entry_media ADD COLUMN IF NOT EXISTS inspection_id INTEGER REFERENCES quality_inspections(id) ON DELETE SET NULL;
+ALTER TABLE room_entry_media DROP CONSTRAINT IF EXISTS room_entry_media_media_type_check;
+ALTER TABLE room_entry_media ADD CONSTRAINT room_entry_media_media_type_check
+    CHECK (media_type IN ('entry', 'edit', 'inspection'));
+CREATE INDEX IF NOT EXISTS idx_room_entry_media_inspection ON room_entry_media(inspection_id) WHERE inspection_id IS NOT NULL;
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('inspection_rot_alert_pct', '5', 'Rot percentage at which an inspection marks a thock as deteriorating'),
+    ('inspection_sprouting_alert_pct', '10', 'Sprouting percentage at which an inspection marks a thock as deteriorating'),
+    ('sms_notify_quality_alert', 'true', 'Allow SMS to owners of deteriorating thocks advising early withdrawal')
+ON CONFLICT (setting_key) DO NOTHING;
@@ -0,0 +1,50 @@
+-- Migration 047: Weighbridge weighments
+-- Gross/tare truck weights captured at the guard gate (inbound, per guard entry) and at
+-- gate-pass pickup (outbound). Weights are typed in or read from the weighbridge indicator.
+-- The average bag weight of a thock is stored on its entry once an inbound weighment is linked.
+
+CREATE TABLE IF NOT EXISTS weighments (
+    id SERIAL PRIMARY KEY,
+    direction VARCHAR(10) NOT NULL CHECK (direction IN ('inbound', 'outbound')),
+    guard_entry_id INTEGER REFERENCES guard_entries(id) ON DELETE SET NULL,
+    gate_pass_id INTEGER REFERENCES gate_passes(id) ON DELETE SET NULL,
+    gate_pass_pickup_id INTEGER REFERENCES gate_pass_pickups(id) ON DELETE SET NULL,
+    thock_number VARCHAR(100) NOT NULL DEFAULT '',
+    vehicle_no VARCHAR(30) NOT NULL DEFAULT '',
+    bag_count INTEGER NOT NULL DEFAULT 0 CHECK (bag_count >= 0),
+    gross_kg NUMERIC(10,2) CHECK (gross_kg >= 0),
+    gross_source VARCHAR(20),
+    gross_at TIMESTAMP,
+    gross_by_user_id INTEGER REFERENCES users(id),
+    tare_kg NUMERIC(10,2) CHECK (tare_kg >= 0),
+    tare_source VARCHAR(20),
+    tare_at TIMESTAMP,
+    tare_by_user_id INTEGER REFERENCES users(id),
+    net_kg NUMERIC(10,2) GENERATED ALWAYS AS (gross_kg - tare_kg) STORED,
+    notes TEXT NOT NULL DEFAULT '',
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT weighments_source_check CHECK (
+        (direction = 'inbound' AND gate_pass_id IS NULL) OR
+        (direction = 'outbound' AND guard_entry_id IS NULL)
+    )
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_weighments_guard_entry ON weighments(guard_entry_id) WHERE guard_entry_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_weighments_pickup ON weighments(gate_pass_pickup_id) WHERE gate_pass_pickup_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_weighments_gate_pass ON weighments(gate_pass_id) WHERE gate_pass_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_weighments_thock ON weighments(thock_number) WHERE thock_number != '';
+CREATE INDEX IF NOT EXISTS idx_weighments_created ON weighments(created_at DESC);
+
+-- Inbound weight of each thock
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS inbound_weighment_id INTEGER REFERENCES weighments(id) ON DELETE SET NULL;
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS net_weight_kg NUMERIC(10,2);
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS avg_bag_weight_kg NUMERIC(8,2);
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('weighbridge_mode', 'manual', 'Weighbridge reader: manual, tcp, serial or simulator'),
+    ('weighbridge_address', '', 'Weighbridge indicator address: host:port for tcp, device path (e.g. /dev/ttyUSB0) for serial'),
+    ('weighbridge_baud', '9600', 'Baud rate of the serial weighbridge indicator')
+ON CONFLICT (setting_key) DO NOTHING;
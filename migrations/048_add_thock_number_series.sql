@@ -0,0 +1,72 @@
+-- Migration 048: Thock numbering per season
+-- Each season numbers seed and sell thocks from its own series. A number is taken by locking
+-- its series row in the same transaction that inserts the entry, so concurrent entries never
+-- share a number and a failed insert gives the number back. Every number handed out, reserved,
+-- voided or jumped over is kept in thock_number_log for auditors; the log has no foreign key to
+-- entries so it survives the season reset.
+
+CREATE TABLE IF NOT EXISTS thock_number_series (
+    season VARCHAR(100) NOT NULL,
+    category VARCHAR(10) NOT NULL CHECK (category IN ('seed', 'sell')),
+    start_number INTEGER NOT NULL CHECK (start_number > 0),
+    last_number INTEGER NOT NULL,           -- start_number - 1 until the first number is taken
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (season, category)
+);
+
+CREATE TABLE IF NOT EXISTS thock_number_log (
+    id SERIAL PRIMARY KEY,
+    season VARCHAR(100) NOT NULL,
+    category VARCHAR(10) NOT NULL CHECK (category IN ('seed', 'sell')),
+    number INTEGER NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('issued', 'reserved', 'voided', 'skipped')),
+    entry_id INTEGER,                       -- Entry ids restart every season
+    thock_number VARCHAR(100) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (season, category, number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_thock_number_log_status ON thock_number_log(season, status);
+CREATE INDEX IF NOT EXISTS idx_thock_number_log_entry ON thock_number_log(entry_id) WHERE entry_id IS NOT NULL;
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES ('thock_season', to_char(CURRENT_DATE, 'YYYY'), 'Season whose series numbers new thocks; switched when a new season starts')
+ON CONFLICT (setting_key) DO NOTHING;
+
+-- Carry on the current season from the numbers already used
+INSERT INTO thock_number_series (season, category, start_number, last_number)
+SELECT s.setting_value, c.category, c.start_number,
+       GREATEST(COALESCE((
+           SELECT MAX(CAST(SPLIT_PART(e.thock_number, '/', 1) AS INTEGER))
+           FROM entries e
+           WHERE e.thock_category = c.category AND e.thock_number ~ '^[0-9]+/'
+       ), 0), c.start_number - 1)
+FROM system_settings s
+CROSS JOIN (VALUES ('seed', 1), ('sell', 1501)) AS c(category, start_number)
+WHERE s.setting_key = 'thock_season'
+ON CONFLICT (season, category) DO NOTHING;
+
+INSERT INTO thock_number_log (season, category, number, status, entry_id, thock_number, user_id, created_at)
+SELECT s.setting_value, e.thock_category, CAST(SPLIT_PART(e.thock_number, '/', 1) AS INTEGER), 'issued',
+       e.id, e.thock_number, e.created_by_user_id, e.created_at
+FROM entries e
+CROSS JOIN system_settings s
+WHERE s.setting_key = 'thock_season'
+  AND e.thock_category IN ('seed', 'sell') AND e.thock_number ~ '^[0-9]+/'
+ORDER BY e.id
+ON CONFLICT (season, category, number) DO NOTHING;
+
+-- Numbers already jumped over by the skip range settings
+INSERT INTO thock_number_log (season, category, number, status, reason)
+SELECT ser.season, ser.category, g, 'skipped', 'Skip range setting'
+FROM thock_number_series ser
+JOIN system_settings r ON r.setting_key = 'skip_thock_ranges_' || ser.category
+                      AND r.setting_value ~ '^\s*\[.*\]\s*$'
+CROSS JOIN LATERAL jsonb_array_elements(r.setting_value::jsonb) AS rng
+CROSS JOIN LATERAL generate_series((rng->>'from')::int, LEAST((rng->>'to')::int, ser.last_number)) AS g
+WHERE ser.season = (SELECT setting_value FROM system_settings WHERE setting_key = 'thock_season')
+ON CONFLICT (season, category, number) DO NOTHING;
@@ -0,0 +1,53 @@
+-- Migration 049: Configurable printers and a print queue
+-- Each counter has its own label and receipt printers; a printer with counter '' is the
+-- default for counters without one; a counter's browser names itself in localStorage
+-- (print_counter). Labels are rendered in the printer's language (ZPL,
+-- TSPL, ESC/POS) and sent over raw TCP, or posted to the old print bridge. Every print is a
+-- job in print_jobs and failed jobs are retried with backoff.
+
+CREATE TABLE IF NOT EXISTS printers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    counter VARCHAR(50) NOT NULL DEFAULT '',    -- '' = default for every counter
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('label', 'receipt')),
+    protocol VARCHAR(10) NOT NULL CHECK (protocol IN ('zpl', 'tspl', 'escpos', 'bridge', 'fake')),
+    address VARCHAR(255) NOT NULL DEFAULT '',   -- host[:port] for raw printers, base URL for the bridge
+    label_width_mm INTEGER NOT NULL DEFAULT 50,
+    label_height_mm INTEGER NOT NULL DEFAULT 25,
+    label_columns INTEGER NOT NULL DEFAULT 2 CHECK (label_columns IN (1, 2)),
+    dpi INTEGER NOT NULL DEFAULT 203,
+    code_type VARCHAR(10) NOT NULL DEFAULT 'qr' CHECK (code_type IN ('qr', 'code128', 'none')),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_printers_counter ON printers(kind, counter) WHERE is_active;
+
+CREATE TABLE IF NOT EXISTS print_jobs (
+    id SERIAL PRIMARY KEY,
+    printer_id INTEGER NOT NULL REFERENCES printers(id) ON DELETE CASCADE,
+    job_type VARCHAR(10) NOT NULL CHECK (job_type IN ('label', 'receipt')),
+    reference VARCHAR(100) NOT NULL DEFAULT '', -- Thock number or document printed
+    copies INTEGER NOT NULL DEFAULT 1,
+    payload BYTEA NOT NULL,                     -- Rendered document as sent to the printer
+    status VARCHAR(10) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'printing', 'printed', 'failed', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    printed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_print_jobs_due ON print_jobs(next_attempt_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_print_jobs_created ON print_jobs(created_at DESC);
+
+-- Keep printing through the existing bridge until counters are set up
+INSERT INTO printers (name, counter, kind, protocol, address)
+VALUES ('Label printer (print bridge)', '', 'label', 'bridge', 'http://192.168.15.101:5000'),
+       ('Receipt printer (print bridge)', '', 'receipt', 'bridge', 'http://192.168.15.101:5000')
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Migration 050: Keys for the signed QR codes on bag labels and gate passes
+-- The server creates the first key on first use. Old keys are kept so labels already on
+-- bags keep scanning after a rotation; the newest key signs new codes.
+
+CREATE TABLE IF NOT EXISTS qr_signing_keys (
+    id SERIAL PRIMARY KEY,
+    secret VARCHAR(128) NOT NULL,              -- Hex HMAC key
+    created_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,20 @@
+-- Migration 051: Gate pass state machine
+-- Every status change of a gate pass is recorded with who made it, when and why. Gate
+-- passes can now also be cancelled.
+
+ALTER TABLE gate_passes DROP CONSTRAINT IF EXISTS gate_passes_status_check;
+ALTER TABLE gate_passes ADD CONSTRAINT gate_passes_status_check CHECK (status IN (
+    'pending', 'approved', 'partially_completed', 'completed', 'expired', 'cancelled', 'rejected'
+));
+
+CREATE TABLE IF NOT EXISTS gate_pass_transitions (
+    id SERIAL PRIMARY KEY,
+    gate_pass_id INTEGER NOT NULL REFERENCES gate_passes(id) ON DELETE CASCADE,
+    from_status VARCHAR(30),                   -- NULL for the request that created the gate pass
+    to_status VARCHAR(30) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL, -- NULL for customers and automatic expiry
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gate_pass_transitions_gate_pass ON gate_pass_transitions(gate_pass_id, created_at);
@@ -0,0 +1,32 @@
+-- Migration 052: Gate pass cancellation, amendment and re-issue
+-- Cancelled gate passes keep only the bags already picked up; amendments to the quantity
+-- or gate of an approved gate pass wait for a second person's approval; an expired gate
+-- pass can be re-issued once for the bags it did not deliver.
+
+ALTER TABLE gate_passes ADD COLUMN IF NOT EXISTS reissued_from_id INTEGER REFERENCES gate_passes(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_gate_passes_reissued_from ON gate_passes(reissued_from_id) WHERE reissued_from_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS gate_pass_amendments (
+    id SERIAL PRIMARY KEY,
+    gate_pass_id INTEGER NOT NULL REFERENCES gate_passes(id) ON DELETE CASCADE,
+    old_quantity INTEGER NOT NULL,
+    new_quantity INTEGER NOT NULL,
+    old_gate_no VARCHAR(50) NOT NULL DEFAULT '',
+    new_gate_no VARCHAR(50) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    requested_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    decided_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    decision_remarks TEXT NOT NULL DEFAULT '',
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gate_pass_amendments_gate_pass ON gate_pass_amendments(gate_pass_id);
+-- At most one amendment of a gate pass waits for approval at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_gate_pass_amendments_pending ON gate_pass_amendments(gate_pass_id) WHERE status = 'pending';
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('sms_notify_gate_pass', 'true', 'SMS customers when their gate pass is cancelled, amended or re-issued')
+ON CONFLICT (setting_key) DO NOTHING;
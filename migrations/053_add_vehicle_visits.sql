@@ -0,0 +1,52 @@
+-- Migration 053: Vehicle register
+-- Every truck is booked in by the guard with its number and driver, linked to the guard
+-- entry, entries or gate passes it came for, and booked out with the bags it carries so
+-- the guard can check what leaves against what was picked up on its gate passes.
+
+CREATE TABLE IF NOT EXISTS vehicle_visits (
+    id SERIAL PRIMARY KEY,
+    vehicle_no VARCHAR(20) NOT NULL,
+    driver_name VARCHAR(100) NOT NULL DEFAULT '',
+    driver_phone VARCHAR(15) NOT NULL DEFAULT '',
+    purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('delivery', 'collection')),
+    status VARCHAR(20) NOT NULL DEFAULT 'inside' CHECK (status IN ('inside', 'exited')),
+    guard_entry_id INTEGER REFERENCES guard_entries(id) ON DELETE SET NULL,
+    in_time TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    in_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    remarks TEXT NOT NULL DEFAULT '',
+    out_time TIMESTAMP,
+    out_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    quantity_loaded INTEGER CHECK (quantity_loaded >= 0),
+    expected_quantity INTEGER,
+    quantity_mismatch BOOLEAN NOT NULL DEFAULT FALSE,
+    exit_remarks TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_visits_in_time ON vehicle_visits(in_time DESC);
+CREATE INDEX IF NOT EXISTS idx_vehicle_visits_vehicle_no ON vehicle_visits(vehicle_no);
+-- A vehicle can only be inside once at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_visits_inside ON vehicle_visits(vehicle_no) WHERE status = 'inside';
+
+CREATE TABLE IF NOT EXISTS vehicle_visit_links (
+    id SERIAL PRIMARY KEY,
+    visit_id INTEGER NOT NULL REFERENCES vehicle_visits(id) ON DELETE CASCADE,
+    entry_id INTEGER REFERENCES entries(id) ON DELETE CASCADE,
+    gate_pass_id INTEGER REFERENCES gate_passes(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT vehicle_visit_links_target_check CHECK ((entry_id IS NULL) <> (gate_pass_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_visit_links_entry ON vehicle_visit_links(visit_id, entry_id) WHERE entry_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_visit_links_gate_pass ON vehicle_visit_links(visit_id, gate_pass_id) WHERE gate_pass_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_vehicle_visit_links_gate_pass_id ON vehicle_visit_links(gate_pass_id) WHERE gate_pass_id IS NOT NULL;
+
+-- The vehicle that carried each pickup away, so a visit counts only its own bags
+ALTER TABLE gate_pass_pickups ADD COLUMN IF NOT EXISTS vehicle_visit_id INTEGER REFERENCES vehicle_visits(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_gate_pass_pickups_vehicle_visit ON gate_pass_pickups(vehicle_visit_id) WHERE vehicle_visit_id IS NOT NULL;
+
+INSERT INTO system_settings (setting_key, setting_value, description)
+VALUES
+    ('vehicle_overdue_minutes', '240', 'Flag vehicles inside the premises for longer than this many minutes')
+ON CONFLICT (setting_key) DO NOTHING;
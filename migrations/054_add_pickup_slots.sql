@@ -0,0 +1,28 @@
+-- Migration 054: Pickup slots and the unloading queue
+-- Staff open time slots per day and loading gate with a capacity in bags and trucks.
+-- Customers book one when requesting a gate pass on the portal, staff assign or change it
+-- on approval, and each gate works its queue in slot order. The slot gate is the loading
+-- gate trucks queue at, not the gatar numbers kept in gate_passes.gate_no.
+
+CREATE TABLE IF NOT EXISTS pickup_slots (
+    id SERIAL PRIMARY KEY,
+    slot_date DATE NOT NULL,
+    gate VARCHAR(50) NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    capacity_bags INTEGER NOT NULL DEFAULT 0 CHECK (capacity_bags >= 0),     -- 0 = no limit
+    capacity_trucks INTEGER NOT NULL DEFAULT 0 CHECK (capacity_trucks >= 0), -- 0 = no limit
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT pickup_slots_time_check CHECK (end_time > start_time),
+    CONSTRAINT pickup_slots_unique UNIQUE (slot_date, gate, start_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pickup_slots_date ON pickup_slots(slot_date, gate, start_time);
+
+ALTER TABLE gate_passes ADD COLUMN IF NOT EXISTS pickup_slot_id INTEGER REFERENCES pickup_slots(id) ON DELETE SET NULL;
+-- Order of arrival within a slot's queue
+ALTER TABLE gate_passes ADD COLUMN IF NOT EXISTS slot_booked_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_gate_passes_pickup_slot ON gate_passes(pickup_slot_id) WHERE pickup_slot_id IS NOT NULL;
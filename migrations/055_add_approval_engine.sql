@@ -0,0 +1,72 @@
+-- Migration 055: Approval engine
+-- Gate pass, debt, protected setting and season approvals go through one set of policies.
+-- A policy says who may approve (roles, optionally a named panel of users), how many of them
+-- must (e.g. 2 of 3 admins), whether the requester may approve their own request, whether
+-- the approver confirms with their password and 2FA code, and when the request expires.
+-- A flow can have tiers by amount (rupees for debts, bags for gate passes); the tier with the
+-- highest min_amount not above the request's amount applies. Every approve or reject is kept
+-- in approval_decisions. An open request whose amount or terms change (e.g. a gate pass's
+-- quantity or gate) drops the approvals given so far, so every approver agrees to the same thing.
+
+CREATE TABLE IF NOT EXISTS approval_policies (
+    id SERIAL PRIMARY KEY,
+    flow VARCHAR(30) NOT NULL CHECK (flow IN ('gate_pass', 'debt_request', 'setting_change', 'season_request')),
+    min_amount NUMERIC(14,2) NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+    required_approvals INTEGER NOT NULL DEFAULT 1 CHECK (required_approvals >= 1),
+    approver_roles TEXT[] NOT NULL DEFAULT '{admin}',
+    approver_user_ids INTEGER[] NOT NULL DEFAULT '{}',      -- Empty = anyone with an approver role
+    allow_self_approval BOOLEAN NOT NULL DEFAULT FALSE,
+    self_approval_user_ids INTEGER[] NOT NULL DEFAULT '{}', -- May approve their own requests regardless
+    require_password BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_mode VARCHAR(20) NOT NULL DEFAULT 'none' CHECK (totp_mode IN ('none', 'if_enrolled', 'required')),
+    expiry_hours INTEGER NOT NULL DEFAULT 0 CHECK (expiry_hours >= 0), -- 0 = never
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT approval_policies_tier_unique UNIQUE (flow, min_amount)
+);
+
+CREATE TABLE IF NOT EXISTS approval_requests (
+    id SERIAL PRIMARY KEY,
+    flow VARCHAR(30) NOT NULL,
+    subject_id INTEGER NOT NULL, -- gate_passes, debt_requests, pending_setting_changes or season_requests id
+    summary TEXT NOT NULL DEFAULT '',
+    terms TEXT NOT NULL DEFAULT '', -- What approvers agree to beyond the amount
+    amount NUMERIC(14,2) NOT NULL DEFAULT 0,
+    policy_id INTEGER NOT NULL REFERENCES approval_policies(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'expired', 'cancelled')),
+    requested_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP,
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one open approval per gate pass, debt request, setting change or season request
+CREATE UNIQUE INDEX IF NOT EXISTS idx_approval_requests_open ON approval_requests(flow, subject_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_approval_requests_subject ON approval_requests(flow, subject_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS approval_decisions (
+    id SERIAL PRIMARY KEY,
+    approval_request_id INTEGER NOT NULL REFERENCES approval_requests(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    decision VARCHAR(10) NOT NULL CHECK (decision IN ('approve', 'reject')),
+    comment TEXT NOT NULL DEFAULT '',
+    password_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT approval_decisions_once UNIQUE (approval_request_id, user_id)
+);
+
+-- Base tiers keep each flow's existing rules:
+-- gate passes by one employee or admin; debts by one admin within 24 hours; protected
+-- settings by another admin with password (and 2FA when enrolled) within 24 hours; a new
+-- season by another admin with password, user 2 being allowed to approve their own.
+INSERT INTO approval_policies (flow, required_approvals, approver_roles, allow_self_approval, self_approval_user_ids, require_password, totp_mode, expiry_hours)
+VALUES
+    ('gate_pass', 1, '{employee,admin}', TRUE, '{}', FALSE, 'none', 0),
+    ('debt_request', 1, '{admin}', TRUE, '{}', FALSE, 'none', 24),
+    ('setting_change', 1, '{admin}', FALSE, '{}', TRUE, 'if_enrolled', 24),
+    ('season_request', 1, '{admin}', FALSE, '{2}', TRUE, 'none', 0)
+ON CONFLICT (flow, min_amount) DO NOTHING;
@@ -0,0 +1,39 @@
+-- TimescaleDB Migration: Cold-room sensor readings
+-- Temperature and humidity per room/floor, posted over HTTP or MQTT
+-- Run against the metrics database after 001_create_metrics_tables.sql
+
+-- =====================================================
+-- Room Sensor Readings
+-- =====================================================
+CREATE TABLE IF NOT EXISTS room_sensor_readings (
+    time TIMESTAMPTZ NOT NULL,
+    sensor_id VARCHAR(100) NOT NULL,
+    room_no VARCHAR(20) NOT NULL,
+    floor VARCHAR(20) NOT NULL DEFAULT '',
+    temperature_c DOUBLE PRECISION,
+    humidity_pct DOUBLE PRECISION
+);
+
+SELECT create_hypertable('room_sensor_readings', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_room_sensor_readings_room ON room_sensor_readings (room_no, floor, time DESC);
+CREATE INDEX IF NOT EXISTS idx_room_sensor_readings_sensor ON room_sensor_readings (sensor_id, time DESC);
+
+ALTER TABLE room_sensor_readings SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'room_no, floor, sensor_id'
+);
+SELECT add_compression_policy('room_sensor_readings', INTERVAL '7 days', if_not_exists => TRUE);
+
+-- Keep two years so a full storage season can be compared with the last one
+SELECT add_retention_policy('room_sensor_readings', INTERVAL '730 days', if_not_exists => TRUE);
+
+-- =====================================================
+-- Sensor alert thresholds
+-- =====================================================
+INSERT INTO alert_thresholds (metric_name, display_name, warning_threshold, critical_threshold, comparison, cooldown_minutes, description) VALUES
+    ('room_temperature_high', 'Room Temperature High (°C)', 4, 6, 'gt', 30, 'Cold room warmer than the storage band'),
+    ('room_temperature_low', 'Room Temperature Low (°C)', 1, 0, 'lt', 30, 'Cold room close to freezing'),
+    ('room_humidity_high', 'Room Humidity High (%)', 95, 98, 'gt', 60, 'Relative humidity high enough to cause condensation'),
+    ('room_humidity_low', 'Room Humidity Low (%)', 85, 80, 'lt', 60, 'Relative humidity low enough to dry out stock')
+ON CONFLICT (metric_name) DO NOTHING;